@@ -0,0 +1,5 @@
+# import by allocation ID
+terraform import tfipam_allocation.example allocation_example_0
+
+# import by pool name and CIDR, recording a new allocation if the CIDR isn't allocated yet
+terraform import tfipam_allocation.example pool_example:10.0.0.0/24
@@ -0,0 +1,67 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"net"
+
+	"github.com/hashicorp/terraform-plugin-framework/function"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+)
+
+var _ function.Function = &AggregateCIDRsFunction{}
+
+func NewAggregateCIDRsFunction() function.Function {
+	return &AggregateCIDRsFunction{}
+}
+
+type AggregateCIDRsFunction struct{}
+
+func (f *AggregateCIDRsFunction) Metadata(ctx context.Context, req function.MetadataRequest, resp *function.MetadataResponse) {
+	resp.Name = "aggregate_cidrs"
+}
+
+func (f *AggregateCIDRsFunction) Definition(ctx context.Context, req function.DefinitionRequest, resp *function.DefinitionResponse) {
+	resp.Definition = function.Definition{
+		Summary:             "Summarize CIDR blocks into the fewest covering prefixes",
+		MarkdownDescription: "Merges the CIDRs into the smallest list of CIDR blocks covering exactly the same addresses, e.g. for route advertisement. Blocks contained in another are dropped and adjacent halves of a larger block are joined. IPv4 and IPv6 CIDRs are aggregated separately, and the result is ordered by address with IPv4 first.",
+		Parameters: []function.Parameter{
+			function.ListParameter{
+				Name:                "cidrs",
+				ElementType:         types.StringType,
+				MarkdownDescription: "CIDR blocks to aggregate",
+			},
+		},
+		Return: function.ListReturn{
+			ElementType: types.StringType,
+		},
+	}
+}
+
+func (f *AggregateCIDRsFunction) Run(ctx context.Context, req function.RunRequest, resp *function.RunResponse) {
+	var cidrs []string
+
+	resp.Error = function.ConcatFuncErrors(req.Arguments.Get(ctx, &cidrs))
+	if resp.Error != nil {
+		return
+	}
+
+	blocks := make([]*net.IPNet, 0, len(cidrs))
+	for _, cidr := range cidrs {
+		_, block, err := net.ParseCIDR(cidr)
+		if err != nil {
+			resp.Error = function.NewArgumentFuncError(0, fmt.Sprintf("CIDR '%s' is not valid: %s", cidr, err))
+			return
+		}
+		blocks = append(blocks, block)
+	}
+
+	aggregated := aggregateCIDRs(blocks)
+
+	result := make([]string, 0, len(aggregated))
+	for _, block := range aggregated {
+		result = append(result, block.String())
+	}
+
+	resp.Error = function.ConcatFuncErrors(resp.Result.Set(ctx, result))
+}
@@ -0,0 +1,117 @@
+package provider
+
+import (
+	"fmt"
+	"regexp"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-testing/helper/resource"
+	"github.com/hashicorp/terraform-plugin-testing/knownvalue"
+	"github.com/hashicorp/terraform-plugin-testing/statecheck"
+	"github.com/hashicorp/terraform-plugin-testing/tfversion"
+)
+
+func TestAccAggregateCIDRsFunction_AdjacentHalves(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		PreCheck:                 func() { testAccPreCheck(t) },
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		TerraformVersionChecks: []tfversion.TerraformVersionCheck{
+			tfversion.SkipBelow(tfversion.Version1_8_0),
+		},
+		Steps: []resource.TestStep{
+			{
+				Config: testAccAggregateCIDRsFunctionConfig([]string{"10.0.0.128/25", "10.0.0.0/25"}),
+				ConfigStateChecks: []statecheck.StateCheck{
+					statecheck.ExpectKnownOutputValue(
+						"test",
+						knownvalue.ListExact([]knownvalue.Check{
+							knownvalue.StringExact("10.0.0.0/24"),
+						}),
+					),
+				},
+			},
+		},
+	})
+}
+
+func TestAccAggregateCIDRsFunction_Disjoint(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		PreCheck:                 func() { testAccPreCheck(t) },
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		TerraformVersionChecks: []tfversion.TerraformVersionCheck{
+			tfversion.SkipBelow(tfversion.Version1_8_0),
+		},
+		Steps: []resource.TestStep{
+			// adjacent, but not the two halves of a /23
+			{
+				Config: testAccAggregateCIDRsFunctionConfig([]string{"10.0.2.0/24", "10.0.1.0/24", "192.168.0.0/24"}),
+				ConfigStateChecks: []statecheck.StateCheck{
+					statecheck.ExpectKnownOutputValue(
+						"test",
+						knownvalue.ListExact([]knownvalue.Check{
+							knownvalue.StringExact("10.0.1.0/24"),
+							knownvalue.StringExact("10.0.2.0/24"),
+							knownvalue.StringExact("192.168.0.0/24"),
+						}),
+					),
+				},
+			},
+		},
+	})
+}
+
+func TestAccAggregateCIDRsFunction_ContainedAndMixedFamilies(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		PreCheck:                 func() { testAccPreCheck(t) },
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		TerraformVersionChecks: []tfversion.TerraformVersionCheck{
+			tfversion.SkipBelow(tfversion.Version1_8_0),
+		},
+		Steps: []resource.TestStep{
+			{
+				Config: testAccAggregateCIDRsFunctionConfig([]string{
+					"2001:db8:8000::/33", "10.0.0.0/16", "2001:db8::/33", "10.0.5.0/24",
+				}),
+				ConfigStateChecks: []statecheck.StateCheck{
+					statecheck.ExpectKnownOutputValue(
+						"test",
+						knownvalue.ListExact([]knownvalue.Check{
+							knownvalue.StringExact("10.0.0.0/16"),
+							knownvalue.StringExact("2001:db8::/32"),
+						}),
+					),
+				},
+			},
+		},
+	})
+}
+
+func TestAccAggregateCIDRsFunction_InvalidCIDR(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		PreCheck:                 func() { testAccPreCheck(t) },
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		TerraformVersionChecks: []tfversion.TerraformVersionCheck{
+			tfversion.SkipBelow(tfversion.Version1_8_0),
+		},
+		Steps: []resource.TestStep{
+			{
+				Config:      testAccAggregateCIDRsFunctionConfig([]string{"10.0.0.0/33"}),
+				ExpectError: regexp.MustCompile("is not valid"),
+			},
+		},
+	})
+}
+
+// testAccAggregateCIDRsFunctionConfig generates a Terraform configuration that outputs the result of aggregate_cidrs.
+func testAccAggregateCIDRsFunctionConfig(cidrs []string) string {
+	cidrsConfig := ""
+	for _, cidr := range cidrs {
+		cidrsConfig += fmt.Sprintf("%q, ", cidr)
+	}
+
+	return fmt.Sprintf(`
+output "test" {
+  value = provider::tfipam::aggregate_cidrs([%[1]s])
+}
+`, cidrsConfig)
+}
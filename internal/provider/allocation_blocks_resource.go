@@ -0,0 +1,402 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"net"
+
+	"github.com/hashicorp/go-uuid"
+	"github.com/hashicorp/terraform-plugin-framework/path"
+	"github.com/hashicorp/terraform-plugin-framework/resource"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/int64planmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/listplanmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/planmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/stringplanmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+
+	"terraform-provider-tfipam/internal/provider/storage"
+)
+
+var _ resource.Resource = &AllocationBlocksResource{}
+var _ resource.ResourceWithImportState = &AllocationBlocksResource{}
+
+func NewAllocationBlocksResource() resource.Resource {
+	return &AllocationBlocksResource{}
+}
+
+// AllocationBlocksResource allocates a number of addresses as several non-contiguous CIDRs, for
+// fragmented pools where no single free block is big enough.
+type AllocationBlocksResource struct {
+	provider *IpamProvider
+}
+
+type AllocationBlocksResourceModel struct {
+	ID             types.String `tfsdk:"id"`
+	Key            types.String `tfsdk:"key"`
+	PoolName       types.String `tfsdk:"pool_name"`
+	AddressFamily  types.String `tfsdk:"address_family"`
+	TotalSize      types.Int64  `tfsdk:"total_size"`
+	AllocatedCIDRs types.List   `tfsdk:"allocated_cidrs"`
+}
+
+func (r *AllocationBlocksResource) Metadata(ctx context.Context, req resource.MetadataRequest, resp *resource.MetadataResponse) {
+	resp.TypeName = req.ProviderTypeName + "_allocation_blocks"
+}
+
+func (r *AllocationBlocksResource) Schema(ctx context.Context, req resource.SchemaRequest, resp *resource.SchemaResponse) {
+	resp.Schema = schema.Schema{
+		MarkdownDescription: "IPAM allocation blocks resource for allocating a number of addresses as several CIDRs from the fragmented free space of a pool",
+
+		Attributes: map[string]schema.Attribute{
+			"id": schema.StringAttribute{
+				Optional:            true,
+				Computed:            true,
+				MarkdownDescription: "Identifier of this allocation, unique within its pool. A UUID is generated when not provided. Changing it replaces the allocation",
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.UseStateForUnknown(),
+					stringplanmodifier.RequiresReplace(),
+				},
+			},
+			"key": schema.StringAttribute{
+				Computed:            true,
+				MarkdownDescription: "Internal key the allocation is stored under, a UUID generated when it's created",
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.UseStateForUnknown(),
+				},
+			},
+			"pool_name": schema.StringAttribute{
+				Required:            true,
+				MarkdownDescription: "Name of the pool to allocate from. Changing it replaces the allocation",
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.RequiresReplace(),
+				},
+			},
+			"address_family": schema.StringAttribute{
+				Optional:            true,
+				Computed:            true,
+				MarkdownDescription: "Address family to allocate, 'ipv4' or 'ipv6'. Required for a pool with both IPv4 and IPv6 CIDRs, otherwise it defaults to the pool's address family",
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.UseStateForUnknown(),
+					stringplanmodifier.RequiresReplace(),
+				},
+			},
+			"total_size": schema.Int64Attribute{
+				Required:            true,
+				MarkdownDescription: "Number of addresses to allocate, e.g. 768 for the space of three /24s. Must be a multiple of the smallest block the pool's `max_prefix_length` allows. Changing it replaces the allocation",
+				PlanModifiers: []planmodifier.Int64{
+					int64planmodifier.RequiresReplace(),
+				},
+			},
+			"allocated_cidrs": schema.ListAttribute{
+				ElementType:         types.StringType,
+				Computed:            true,
+				MarkdownDescription: "The allocated CIDR blocks, sorted by address. Together they hold exactly `total_size` addresses",
+				PlanModifiers: []planmodifier.List{
+					listplanmodifier.UseStateForUnknown(),
+				},
+			},
+		},
+	}
+}
+
+func (r *AllocationBlocksResource) Configure(ctx context.Context, req resource.ConfigureRequest, resp *resource.ConfigureResponse) {
+	if req.ProviderData == nil {
+		return
+	}
+
+	provider, ok := req.ProviderData.(*IpamProvider)
+	if !ok {
+		resp.Diagnostics.AddError(
+			"Unexpected Resource Configure Type",
+			fmt.Sprintf("Expected *IpamProvider, got: %T", req.ProviderData),
+		)
+		return
+	}
+
+	r.provider = provider
+}
+
+func (r *AllocationBlocksResource) Create(ctx context.Context, req resource.CreateRequest, resp *resource.CreateResponse) {
+	var data AllocationBlocksResourceModel
+
+	resp.Diagnostics.Append(req.Plan.Get(ctx, &data)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	family := data.AddressFamily.ValueString()
+	if family != "" && family != "ipv4" && family != "ipv6" {
+		resp.Diagnostics.AddAttributeError(
+			path.Root("address_family"),
+			"Invalid Address Family",
+			fmt.Sprintf("address_family must be 'ipv4' or 'ipv6', got '%s'", family),
+		)
+		return
+	}
+
+	totalSize := data.TotalSize.ValueInt64()
+	if totalSize <= 0 {
+		resp.Diagnostics.AddAttributeError(
+			path.Root("total_size"),
+			"Invalid Total Size",
+			fmt.Sprintf("total_size must be a positive number of addresses, got %d", totalSize),
+		)
+		return
+	}
+
+	poolName := data.PoolName.ValueString()
+	allocationID := data.ID.ValueString()
+	if data.ID.IsNull() || data.ID.IsUnknown() {
+		generatedID, err := uuid.GenerateUUID()
+		if err != nil {
+			resp.Diagnostics.AddError(
+				"Allocation ID Generation Failed",
+				fmt.Sprintf("Unable to generate an allocation ID: %s", err),
+			)
+			return
+		}
+		allocationID = generatedID
+	}
+	key, err := newAllocationKey()
+	if err != nil {
+		resp.Diagnostics.AddError(
+			"Allocation Key Generation Failed",
+			err.Error(),
+		)
+		return
+	}
+
+	allocation := &storage.Allocation{
+		Key:      key,
+		ID:       allocationID,
+		PoolName: poolName,
+	}
+	fields := map[string]any{
+		"id":        allocationID,
+		"pool_name": poolName,
+	}
+	err = r.provider.retryOnConflict(ctx, "claim CIDR blocks", fields, func() error {
+		defer r.provider.lockPool(poolName)()
+
+		var err error
+		family, err = r.tryAllocateBlocks(ctx, allocation, family, totalSize)
+		return err
+	})
+	if err != nil {
+		addStorageWriteError(
+			&resp.Diagnostics,
+			"Allocation Failed",
+			fmt.Sprintf("Unable to allocate %d addresses from pool %s: %s", totalSize, poolName, err),
+			err,
+		)
+		return
+	}
+
+	allocatedCIDRs, diag := types.ListValueFrom(ctx, types.StringType, allocation.AllocatedCIDRs)
+	resp.Diagnostics.Append(diag...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	data.ID = types.StringValue(allocationID)
+	data.Key = types.StringValue(key)
+	data.AddressFamily = types.StringValue(family)
+	data.AllocatedCIDRs = allocatedCIDRs
+
+	tflog.Trace(ctx, "created allocation blocks resource", map[string]any{
+		"id":              allocationID,
+		"pool_name":       poolName,
+		"allocated_cidrs": allocation.AllocatedCIDRs,
+	})
+
+	r.provider.notifyAllocation(ctx, &resp.Diagnostics, allocationWebhookCreate, allocation)
+
+	resp.Diagnostics.Append(resp.State.Set(ctx, &data)...)
+}
+
+// tryAllocateBlocks finds free blocks of the pool holding totalSize addresses between them and saves
+// them to storage as one allocation. It returns the address family allocated from, which is the
+// pool's own when family is empty.
+func (r *AllocationBlocksResource) tryAllocateBlocks(ctx context.Context, allocation *storage.Allocation, family string, totalSize int64) (string, error) {
+	poolName := allocation.PoolName
+
+	pool, err := r.provider.storage.GetPool(ctx, poolName)
+	if err != nil {
+		return family, fmt.Errorf("failed to read pool %s: %w", poolName, err)
+	}
+
+	if family == "" {
+		families := poolAddressFamilies(pool)
+		if len(families) > 1 {
+			return family, fmt.Errorf("pool %s has both IPv4 and IPv6 CIDRs, set address_family to choose which to allocate from", poolName)
+		}
+		family = "ipv4"
+		if len(families) == 1 {
+			family = families[0]
+		}
+	}
+
+	bits := 32
+	if family == "ipv6" {
+		bits = 128
+	}
+
+	// every block is at least as big as the longest prefix the pool allows
+	if pool.MaxPrefixLength != 0 && pool.MaxPrefixLength < bits {
+		unit := new(big.Int).Lsh(big.NewInt(1), uint(bits-pool.MaxPrefixLength))
+		if new(big.Int).Rem(big.NewInt(totalSize), unit).Sign() != 0 {
+			return family, fmt.Errorf("total size %d is not a multiple of %s, the size of the smallest /%d blocks pool %s allows", totalSize, unit, pool.MaxPrefixLength, poolName)
+		}
+	}
+
+	allocations, err := r.provider.storage.ListAllocationsByPool(ctx, poolName)
+	if err != nil {
+		return family, fmt.Errorf("failed to list allocations: %w", err)
+	}
+
+	// ids are unique within a pool
+	if existing := allocationWithID(allocations, allocation.ID); existing != nil {
+		return family, fmt.Errorf("allocation id %s already in use by %s in pool %s", allocation.ID, existing.AllocatedCIDR, existing.PoolName)
+	}
+
+	// globally reserved CIDRs are never handed out, so the search treats them as taken
+	free := freeCIDRs(pool, takenCIDRs(allocations, r.provider.globallyReservedCIDRs), bits)
+	blocks := fragmentedCIDRs(free, totalSize, pool.MinPrefixLength, pool.MaxPrefixLength, bits)
+	if blocks == nil {
+		return family, fmt.Errorf("not enough free %s space in pool %s for %d addresses, even split across several blocks", family, poolName, totalSize)
+	}
+
+	cidrs := make([]string, 0, len(blocks))
+	for _, block := range blocks {
+		cidrs = append(cidrs, block.String())
+	}
+	allocation.AllocatedCIDR = cidrs[0]
+	allocation.AllocatedCIDRs = cidrs
+	allocation.PrefixLength, _ = blocks[0].Mask.Size()
+	if err := r.provider.storage.SaveAllocation(ctx, allocation); err != nil {
+		allocation.AllocatedCIDR = ""
+		allocation.AllocatedCIDRs = nil
+		return family, fmt.Errorf("failed to save allocation: %w", err)
+	}
+
+	return family, nil
+}
+
+func (r *AllocationBlocksResource) Read(ctx context.Context, req resource.ReadRequest, resp *resource.ReadResponse) {
+	var data AllocationBlocksResourceModel
+
+	resp.Diagnostics.Append(req.State.Get(ctx, &data)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	allocation, err := r.provider.storage.GetAllocation(ctx, allocationKey(data.Key, data.ID))
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			// allocation was deleted outside Terraform
+			resp.State.RemoveResource(ctx)
+			return
+		}
+		resp.Diagnostics.AddError(
+			"Failed to Read Allocation",
+			fmt.Sprintf("Could not read allocation from storage: %s", err),
+		)
+		return
+	}
+
+	// sync state with storage data, the family and size are derived from the blocks so an imported allocation gets them too
+	cidrs := allocation.CIDRs()
+	totalSize := new(big.Int)
+	for _, cidr := range cidrs {
+		_, cidrNet, err := net.ParseCIDR(cidr)
+		if err != nil {
+			resp.Diagnostics.AddError(
+				"Invalid Allocated CIDR",
+				fmt.Sprintf("Allocation %s has an invalid CIDR %s in storage: %s", allocation.ID, cidr, err),
+			)
+			return
+		}
+		prefixLen, bits := cidrNet.Mask.Size()
+		totalSize.Add(totalSize, new(big.Int).Lsh(big.NewInt(1), uint(bits-prefixLen)))
+		if bits == 128 {
+			data.AddressFamily = types.StringValue("ipv6")
+		} else {
+			data.AddressFamily = types.StringValue("ipv4")
+		}
+	}
+	if totalSize.IsInt64() {
+		data.TotalSize = types.Int64Value(totalSize.Int64())
+	}
+
+	allocatedCIDRs, diag := types.ListValueFrom(ctx, types.StringType, cidrs)
+	resp.Diagnostics.Append(diag...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	data.Key = types.StringValue(allocation.StorageKey())
+	data.PoolName = types.StringValue(allocation.PoolName)
+	data.AllocatedCIDRs = allocatedCIDRs
+
+	resp.Diagnostics.Append(resp.State.Set(ctx, &data)...)
+}
+
+func (r *AllocationBlocksResource) Update(ctx context.Context, req resource.UpdateRequest, resp *resource.UpdateResponse) {
+	// every attribute requires replacement, so there's nothing to update
+	var data AllocationBlocksResourceModel
+
+	resp.Diagnostics.Append(req.Plan.Get(ctx, &data)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	resp.Diagnostics.Append(resp.State.Set(ctx, &data)...)
+}
+
+func (r *AllocationBlocksResource) Delete(ctx context.Context, req resource.DeleteRequest, resp *resource.DeleteResponse) {
+	var data AllocationBlocksResourceModel
+
+	resp.Diagnostics.Append(req.State.Get(ctx, &data)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	key := allocationKey(data.Key, data.ID)
+	err := r.provider.retryOnConflict(ctx, "delete the allocation", map[string]any{"key": key}, func() error {
+		return r.provider.storage.DeleteAllocation(ctx, key)
+	})
+	if err != nil {
+		addStorageWriteError(
+			&resp.Diagnostics,
+			"Failed to Delete Allocation",
+			fmt.Sprintf("Could not delete allocation from storage: %s", err),
+			err,
+		)
+		return
+	}
+
+	tflog.Trace(ctx, "deleted allocation blocks resource", map[string]any{
+		"id":        data.ID.ValueString(),
+		"pool_name": data.PoolName.ValueString(),
+	})
+
+	var cidrs []string
+	resp.Diagnostics.Append(data.AllocatedCIDRs.ElementsAs(ctx, &cidrs, false)...)
+	if resp.Diagnostics.HasError() || len(cidrs) == 0 {
+		return
+	}
+	r.provider.notifyAllocation(ctx, &resp.Diagnostics, allocationWebhookDelete, &storage.Allocation{
+		Key:            allocationKey(data.Key, data.ID),
+		ID:             data.ID.ValueString(),
+		PoolName:       data.PoolName.ValueString(),
+		AllocatedCIDR:  cidrs[0],
+		AllocatedCIDRs: cidrs,
+	})
+}
+
+func (r *AllocationBlocksResource) ImportState(ctx context.Context, req resource.ImportStateRequest, resp *resource.ImportStateResponse) {
+	r.provider.importAllocation(ctx, req, resp)
+}
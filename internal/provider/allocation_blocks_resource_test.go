@@ -0,0 +1,89 @@
+package provider
+
+import (
+	"fmt"
+	"regexp"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-testing/helper/resource"
+	"github.com/hashicorp/terraform-plugin-testing/knownvalue"
+	"github.com/hashicorp/terraform-plugin-testing/statecheck"
+	"github.com/hashicorp/terraform-plugin-testing/tfjsonpath"
+)
+
+func TestAccAllocationBlocksResource_Fragmented(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		PreCheck:                 func() { testAccPreCheck(t) },
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			// only 10.1.0.64/26 and 10.1.0.192/26 are free, so 128 addresses take both of them
+			{
+				Config: testAccAllocationBlocksResourceConfig("blocks-pool", 128),
+				ConfigStateChecks: []statecheck.StateCheck{
+					statecheck.ExpectKnownValue(
+						"tfipam_allocation_blocks.test",
+						tfjsonpath.New("allocated_cidrs"),
+						knownvalue.ListExact([]knownvalue.Check{
+							knownvalue.StringExact("10.1.0.64/26"),
+							knownvalue.StringExact("10.1.0.192/26"),
+						}),
+					),
+					statecheck.ExpectKnownValue(
+						"tfipam_allocation_blocks.test",
+						tfjsonpath.New("address_family"),
+						knownvalue.StringExact("ipv4"),
+					),
+				},
+			},
+			{
+				ResourceName:      "tfipam_allocation_blocks.test",
+				ImportState:       true,
+				ImportStateVerify: true,
+			},
+		},
+	})
+}
+
+func TestAccAllocationBlocksResource_Insufficient(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		PreCheck:                 func() { testAccPreCheck(t) },
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config:      testAccAllocationBlocksResourceConfig("blocks-full-pool", 192),
+				ExpectError: regexp.MustCompile("not enough free ipv4 space"),
+			},
+		},
+	})
+}
+
+// testAccAllocationBlocksResourceConfig generates config with a /24 pool whose first and third /26 are
+// allocated, and an allocation of totalSize addresses from the two /26 left between them.
+func testAccAllocationBlocksResourceConfig(poolName string, totalSize int) string {
+	return fmt.Sprintf(`
+resource "tfipam_pool" "test" {
+  name  = %[1]q
+  cidrs = ["10.1.0.0/24"]
+}
+
+resource "tfipam_allocation" "first" {
+  id             = "%[1]s-first"
+  pool_name      = tfipam_pool.test.name
+  requested_cidr = "10.1.0.0/26"
+}
+
+resource "tfipam_allocation" "third" {
+  id             = "%[1]s-third"
+  pool_name      = tfipam_pool.test.name
+  requested_cidr = "10.1.0.128/26"
+}
+
+resource "tfipam_allocation_blocks" "test" {
+  id         = "%[1]s-blocks"
+  pool_name  = tfipam_pool.test.name
+  total_size = %[2]d
+
+  depends_on = [tfipam_allocation.first, tfipam_allocation.third]
+}
+`, poolName, totalSize)
+}
@@ -2,6 +2,7 @@ package provider
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"terraform-provider-tfipam/internal/provider/storage"
 
@@ -22,9 +23,13 @@ type AllocationDataSource struct {
 
 type AllocationDataSourceModel struct {
 	ID            types.String `tfsdk:"id"`
+	Key           types.String `tfsdk:"key"`
 	PoolName      types.String `tfsdk:"pool_name"`
 	AllocatedCIDR types.String `tfsdk:"allocated_cidr"`
 	PrefixLength  types.Int64  `tfsdk:"prefix_length"`
+	Owner         types.String `tfsdk:"owner"`
+	Metadata      types.Map    `tfsdk:"metadata"`
+	PoolCIDRs     types.List   `tfsdk:"pool_cidrs"`
 }
 
 func (d *AllocationDataSource) Metadata(ctx context.Context, req datasource.MetadataRequest, resp *datasource.MetadataResponse) {
@@ -37,13 +42,17 @@ func (d *AllocationDataSource) Schema(ctx context.Context, req datasource.Schema
 
 		Attributes: map[string]schema.Attribute{
 			"id": schema.StringAttribute{
-				MarkdownDescription: "Unique identifier for the allocation",
+				MarkdownDescription: "Identifier of the allocation, unique within its pool",
 				Required:            true,
 			},
 			"pool_name": schema.StringAttribute{
 				MarkdownDescription: "Name of the pool the allocation belongs to",
 				Required:            true,
 			},
+			"key": schema.StringAttribute{
+				MarkdownDescription: "Internal key the allocation is stored under",
+				Computed:            true,
+			},
 			"allocated_cidr": schema.StringAttribute{
 				MarkdownDescription: "CIDR block allocated to the resource",
 				Computed:            true,
@@ -52,6 +61,20 @@ func (d *AllocationDataSource) Schema(ctx context.Context, req datasource.Schema
 				MarkdownDescription: "Prefix length of the allocated CIDR",
 				Computed:            true,
 			},
+			"owner": schema.StringAttribute{
+				MarkdownDescription: "Team or person accountable for the allocation. Empty when not set",
+				Computed:            true,
+			},
+			"metadata": schema.MapAttribute{
+				MarkdownDescription: "Free-form values attached to the allocation with its `metadata`. Empty when not set",
+				Computed:            true,
+				ElementType:         types.StringType,
+			},
+			"pool_cidrs": schema.ListAttribute{
+				MarkdownDescription: "CIDR blocks of the pool the allocation belongs to",
+				Computed:            true,
+				ElementType:         types.StringType,
+			},
 		},
 	}
 }
@@ -81,9 +104,9 @@ func (d *AllocationDataSource) Read(ctx context.Context, req datasource.ReadRequ
 		return
 	}
 
-	allocation, err := d.provider.storage.GetAllocation(ctx, data.ID.ValueString())
+	allocation, err := d.provider.findAllocationByID(ctx, data.PoolName.ValueString(), data.ID.ValueString())
 	if err != nil {
-		if err == storage.ErrNotFound {
+		if errors.Is(err, storage.ErrNotFound) {
 			// allocation was deleted outside Terraform
 			resp.State.RemoveResource(ctx)
 			return
@@ -96,9 +119,37 @@ func (d *AllocationDataSource) Read(ctx context.Context, req datasource.ReadRequ
 	}
 
 	// sync state with storage data
+	data.Key = types.StringValue(allocation.StorageKey())
 	data.AllocatedCIDR = types.StringValue(allocation.AllocatedCIDR)
 	data.PoolName = types.StringValue(allocation.PoolName)
 	data.PrefixLength = types.Int64Value(int64(allocation.PrefixLength))
+	data.Owner = types.StringValue(allocation.Owner)
+	metadata, diag := allocationMetadataValue(ctx, allocation.Metadata)
+	resp.Diagnostics.Append(diag...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	data.Metadata = metadata
+
+	// include the pool cidrs so consumers get the full context in one read
+	pool, err := d.provider.storage.GetPool(ctx, allocation.PoolName)
+	if err != nil && !errors.Is(err, storage.ErrNotFound) {
+		resp.Diagnostics.AddError(
+			"Failed to Read Pool",
+			fmt.Sprintf("Could not read pool %s from storage: %s", allocation.PoolName, err),
+		)
+		return
+	}
+
+	data.PoolCIDRs = types.ListNull(types.StringType)
+	if pool != nil {
+		poolCIDRs, diag := types.ListValueFrom(ctx, types.StringType, pool.CIDRs)
+		resp.Diagnostics.Append(diag...)
+		if resp.Diagnostics.HasError() {
+			return
+		}
+		data.PoolCIDRs = poolCIDRs
+	}
 
 	resp.Diagnostics.Append(resp.State.Set(ctx, &data)...)
 }
@@ -5,6 +5,7 @@ import (
 	"regexp"
 	"testing"
 
+	"github.com/hashicorp/terraform-plugin-testing/compare"
 	"github.com/hashicorp/terraform-plugin-testing/helper/resource"
 	"github.com/hashicorp/terraform-plugin-testing/knownvalue"
 	"github.com/hashicorp/terraform-plugin-testing/statecheck"
@@ -45,6 +46,46 @@ func TestAccAllocationDataSource_Basic(t *testing.T) {
 	})
 }
 
+func TestAccAllocationDataSource_AllFields(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		PreCheck:                 func() { testAccPreCheck(t) },
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: testAccAllocationDataSourceConfig("all-fields-pool", "all-fields-alloc", 24),
+				ConfigStateChecks: []statecheck.StateCheck{
+					statecheck.CompareValuePairs(
+						"tfipam_allocation.test",
+						tfjsonpath.New("allocated_cidr"),
+						"data.tfipam_allocation.test",
+						tfjsonpath.New("allocated_cidr"),
+						compare.ValuesSame(),
+					),
+					statecheck.CompareValuePairs(
+						"tfipam_allocation.test",
+						tfjsonpath.New("prefix_length"),
+						"data.tfipam_allocation.test",
+						tfjsonpath.New("prefix_length"),
+						compare.ValuesSame(),
+					),
+					statecheck.ExpectKnownValue(
+						"data.tfipam_allocation.test",
+						tfjsonpath.New("pool_name"),
+						knownvalue.StringExact("all-fields-pool"),
+					),
+					statecheck.ExpectKnownValue(
+						"data.tfipam_allocation.test",
+						tfjsonpath.New("pool_cidrs"),
+						knownvalue.ListExact([]knownvalue.Check{
+							knownvalue.StringExact("10.0.0.0/16"),
+						}),
+					),
+				},
+			},
+		},
+	})
+}
+
 func TestAccAllocationDataSource_MultipleAllocations(t *testing.T) {
 	resource.Test(t, resource.TestCase{
 		PreCheck:                 func() { testAccPreCheck(t) },
@@ -2,13 +2,23 @@ package provider
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"math/big"
 	"net"
+	"slices"
+	"strings"
+	"time"
 
+	"github.com/hashicorp/go-uuid"
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/path"
 	"github.com/hashicorp/terraform-plugin-framework/resource"
 	"github.com/hashicorp/terraform-plugin-framework/resource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/booldefault"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/boolplanmodifier"
 	"github.com/hashicorp/terraform-plugin-framework/resource/schema/int64planmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/listplanmodifier"
 	"github.com/hashicorp/terraform-plugin-framework/resource/schema/planmodifier"
 	"github.com/hashicorp/terraform-plugin-framework/resource/schema/stringplanmodifier"
 	"github.com/hashicorp/terraform-plugin-framework/types"
@@ -19,6 +29,12 @@ import (
 
 var _ resource.Resource = &AllocationResource{}
 var _ resource.ResourceWithImportState = &AllocationResource{}
+var _ resource.ResourceWithModifyPlan = &AllocationResource{}
+var _ resource.ResourceWithValidateConfig = &AllocationResource{}
+
+// errNoAvailableCIDR is returned when a pool has no free block for an allocation, which is the only
+// failure an allocation with pool_names fails over to its next pool on.
+var errNoAvailableCIDR = errors.New("no available CIDR blocks")
 
 func NewAllocationResource() resource.Resource {
 	return &AllocationResource{}
@@ -29,10 +45,32 @@ type AllocationResource struct {
 }
 
 type AllocationResourceModel struct {
-	ID            types.String `tfsdk:"id"`
-	PoolName      types.String `tfsdk:"pool_name"`
-	AllocatedCIDR types.String `tfsdk:"allocated_cidr"`
-	PrefixLength  types.Int64  `tfsdk:"prefix_length"`
+	ID               types.String `tfsdk:"id"`
+	Key              types.String `tfsdk:"key"`
+	PoolName         types.String `tfsdk:"pool_name"`
+	PoolNames        types.List   `tfsdk:"pool_names"`
+	AllocatedCIDR    types.String `tfsdk:"allocated_cidr"`
+	PrefixLength     types.Int64  `tfsdk:"prefix_length"`
+	SubnetCount      types.Int64  `tfsdk:"subnet_count"`
+	SubnetPrefix     types.Int64  `tfsdk:"subnet_prefix_length"`
+	Alignment        types.Int64  `tfsdk:"alignment"`
+	RequestedCIDR    types.String `tfsdk:"requested_cidr"`
+	PreferredCIDR    types.String `tfsdk:"preferred_cidr"`
+	PreferredHonored types.Bool   `tfsdk:"preferred_cidr_honored"`
+	AllowOverlap     types.Bool   `tfsdk:"allow_overlap"`
+	FromCIDR         types.String `tfsdk:"from_cidr"`
+	Owner            types.String `tfsdk:"owner"`
+	Metadata         types.Map    `tfsdk:"metadata"`
+	TTLSeconds       types.Int64  `tfsdk:"ttl_seconds"`
+	RenameInPlace    types.Bool   `tfsdk:"rename_in_place"`
+	Sticky           types.Bool   `tfsdk:"sticky"`
+	AllowShortIPv6   types.Bool   `tfsdk:"allow_short_ipv6_prefix"`
+	ExpiresAt        types.String `tfsdk:"expires_at"`
+	NetworkAddress   types.String `tfsdk:"network_address"`
+	BroadcastAddress types.String `tfsdk:"broadcast_address"`
+	AddressCount     types.String `tfsdk:"address_count"`
+	IPVersion        types.Int64  `tfsdk:"ip_version"`
+	BlockIndex       types.Int64  `tfsdk:"block_index"`
 }
 
 func (r *AllocationResource) Metadata(ctx context.Context, req resource.MetadataRequest, resp *resource.MetadataResponse) {
@@ -45,17 +83,43 @@ func (r *AllocationResource) Schema(ctx context.Context, req resource.SchemaRequ
 
 		Attributes: map[string]schema.Attribute{
 			"id": schema.StringAttribute{
-				Required:            true,
-				MarkdownDescription: "Unique identifier for this allocation",
+				Optional:            true,
+				Computed:            true,
+				MarkdownDescription: "Identifier of this allocation, unique within its pool. When not provided it is generated from the provider's `allocation_id_template`, or a UUID without one. Changing it replaces the allocation unless `rename_in_place` is set",
 				PlanModifiers: []planmodifier.String{
-					stringplanmodifier.RequiresReplace(),
+					stringplanmodifier.UseStateForUnknown(),
+					stringplanmodifier.RequiresReplaceIf(
+						requiresReplaceUnlessRenameInPlace,
+						"Changing the id replaces the allocation unless rename_in_place is set.",
+						"Changing the id replaces the allocation unless `rename_in_place` is set.",
+					),
+				},
+			},
+			"key": schema.StringAttribute{
+				Computed:            true,
+				MarkdownDescription: "Internal key the allocation is stored under, a UUID generated when it's created. Unlike `id` it never changes, it's kept when the allocation is renamed or moved to another pool. Allocations created before keys were added have their `id` as key",
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.UseStateForUnknown(),
 				},
 			},
 			"pool_name": schema.StringAttribute{
-				Required:            true,
-				MarkdownDescription: "Name of the pool to allocate from",
+				Optional:            true,
+				Computed:            true,
+				MarkdownDescription: "Name of the pool to allocate from. Changing it moves the allocation to the new pool in place when its CIDR fits there and is free, otherwise the allocation is replaced. With `pool_names` it's the pool the CIDR was allocated from. Exactly one of `pool_name` or `pool_names` must be set",
 				PlanModifiers: []planmodifier.String{
-					stringplanmodifier.RequiresReplace(),
+					stringplanmodifier.UseStateForUnknown(),
+				},
+			},
+			"pool_names": schema.ListAttribute{
+				ElementType:         types.StringType,
+				Optional:            true,
+				MarkdownDescription: "Pools to allocate from in order of preference, for overflowing into other pools. The CIDR is allocated from the first pool with a free block of the size, which is recorded in `pool_name`. Changing the list replaces the allocation unless it still includes the pool the CIDR was allocated from",
+				PlanModifiers: []planmodifier.List{
+					listplanmodifier.RequiresReplaceIf(
+						requiresReplaceUnlessInPoolNames,
+						"Changing pool_names replaces the allocation unless it still includes the pool the CIDR was allocated from.",
+						"Changing `pool_names` replaces the allocation unless it still includes the pool the CIDR was allocated from.",
+					),
 				},
 			},
 			"allocated_cidr": schema.StringAttribute{
@@ -66,12 +130,142 @@ func (r *AllocationResource) Schema(ctx context.Context, req resource.SchemaRequ
 				},
 			},
 			"prefix_length": schema.Int64Attribute{
-				Required:            true,
-				MarkdownDescription: "Prefix length for the allocated CIDR (e.g., 32 for a single IPv4 host)",
+				Optional:            true,
+				Computed:            true,
+				MarkdownDescription: "Prefix length for the allocated CIDR (e.g., 32 for a single IPv4 host). Computed from `requested_cidr` or `subnet_count` when one is set, otherwise taken from the pool's `default_prefix_length` when the allocation is created",
+				PlanModifiers: []planmodifier.Int64{
+					int64planmodifier.UseStateForUnknown(),
+					int64planmodifier.RequiresReplace(),
+				},
+			},
+			"subnet_count": schema.Int64Attribute{
+				Optional:            true,
+				MarkdownDescription: "Number of `subnet_prefix_length` subnets the allocation has to hold, instead of setting `prefix_length`. The smallest block that fits them is allocated, e.g. a /24 for 16 /28s. Can't be combined with `prefix_length` or `requested_cidr`",
+				PlanModifiers: []planmodifier.Int64{
+					int64planmodifier.RequiresReplace(),
+				},
+			},
+			"subnet_prefix_length": schema.Int64Attribute{
+				Optional:            true,
+				MarkdownDescription: "Prefix length of the subnets counted by `subnet_count`, which requires it",
+				PlanModifiers: []planmodifier.Int64{
+					int64planmodifier.RequiresReplace(),
+				},
+			},
+			"alignment": schema.Int64Attribute{
+				Optional:            true,
+				MarkdownDescription: "Prefix length the allocated CIDR's network address must be aligned to, e.g. 24 to only start allocations on a /24 boundary. Must not be longer than `prefix_length`",
 				PlanModifiers: []planmodifier.Int64{
 					int64planmodifier.RequiresReplace(),
 				},
 			},
+			"requested_cidr": schema.StringAttribute{
+				Optional:            true,
+				MarkdownDescription: "Specific CIDR to allocate instead of the next free block. Must be inside one of the pool's CIDRs and not overlap another allocation unless `allow_overlap` is set. An address with host bits set like `10.0.5.3/24` allocates its network `10.0.5.0/24`",
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.RequiresReplace(),
+				},
+			},
+			"preferred_cidr": schema.StringAttribute{
+				Optional:            true,
+				MarkdownDescription: "CIDR to allocate when it's free, e.g. to keep the same address when the allocation is recreated. Unlike `requested_cidr` the allocation doesn't fail when it's taken or outside the pool, the next free block is allocated instead. Sets the prefix length when `prefix_length` isn't set. Only used when the allocation is created, and tried before the CIDR a `sticky` allocation had",
+			},
+			"preferred_cidr_honored": schema.BoolAttribute{
+				Computed:            true,
+				MarkdownDescription: "Whether the allocation got its `preferred_cidr` when it was created. Null without a `preferred_cidr`",
+				PlanModifiers: []planmodifier.Bool{
+					boolplanmodifier.UseStateForUnknown(),
+				},
+			},
+			"allow_overlap": schema.BoolAttribute{
+				Optional:            true,
+				Computed:            true,
+				Default:             booldefault.StaticBool(false),
+				MarkdownDescription: "Record the `requested_cidr` even if it overlaps other allocations in the pool, e.g. for anycast or shared ranges. Only valid together with `requested_cidr`. Defaults to false",
+				PlanModifiers: []planmodifier.Bool{
+					boolplanmodifier.RequiresReplace(),
+				},
+			},
+			"from_cidr": schema.StringAttribute{
+				Optional:            true,
+				MarkdownDescription: "One of the pool's CIDRs to allocate from, e.g. to keep DMZ and internal ranges of a mixed pool apart. When not set every pool CIDR is searched in order",
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.RequiresReplace(),
+				},
+			},
+			"owner": schema.StringAttribute{
+				Optional:            true,
+				MarkdownDescription: "Team or person accountable for the allocation, e.g. for chargeback. Reported by the allocation data sources and `tfipam_pool_stats`. Changing it updates the allocation in place",
+			},
+			"metadata": schema.MapAttribute{
+				ElementType:         types.StringType,
+				Optional:            true,
+				MarkdownDescription: "Free-form string values attached to the allocation, e.g. CMDB or ticket references for other tooling. Stored as is and returned by the `tfipam_allocation` data source, the provider never reads them. Changing it updates the allocation in place",
+			},
+			"ttl_seconds": schema.Int64Attribute{
+				Optional:            true,
+				MarkdownDescription: "Lease of the allocation in seconds, for sandboxes whose allocations should be reclaimable. The allocation isn't released when it expires, it's reported by the `tfipam_expired_allocations` data source and reading it warns. Changing the TTL renews the lease from the time of the apply",
+			},
+			"rename_in_place": schema.BoolAttribute{
+				Optional:            true,
+				Computed:            true,
+				Default:             booldefault.StaticBool(false),
+				MarkdownDescription: "Change the `id` in place, re-keying the stored allocation and keeping its CIDR, instead of replacing the allocation with a new CIDR. Useful when refactoring allocation ids. Defaults to false",
+			},
+			"sticky": schema.BoolAttribute{
+				Optional:            true,
+				Computed:            true,
+				Default:             booldefault.StaticBool(false),
+				MarkdownDescription: "When the allocation is replaced with the same `id`, e.g. after it's tainted, reuse the CIDR it had before as long as it's still free and fits the allocation. Otherwise the next free block is allocated as usual. Defaults to false",
+			},
+			"allow_short_ipv6_prefix": schema.BoolAttribute{
+				Optional:            true,
+				Computed:            true,
+				Default:             booldefault.StaticBool(false),
+				MarkdownDescription: "Allocate an IPv6 block with a shorter prefix length than the provider's `ipv6_min_prefix`, for an allocation that's meant to be that large. Defaults to false",
+			},
+			"expires_at": schema.StringAttribute{
+				Computed:            true,
+				MarkdownDescription: "RFC 3339 time the allocation's lease runs out. Only set when `ttl_seconds` is set",
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.UseStateForUnknown(),
+				},
+			},
+			"network_address": schema.StringAttribute{
+				Computed:            true,
+				MarkdownDescription: "Network address of the allocated CIDR",
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.UseStateForUnknown(),
+				},
+			},
+			"broadcast_address": schema.StringAttribute{
+				Computed:            true,
+				MarkdownDescription: "Broadcast address of the allocated CIDR. Only set for IPv4 allocations",
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.UseStateForUnknown(),
+				},
+			},
+			"address_count": schema.StringAttribute{
+				Computed:            true,
+				MarkdownDescription: "Number of addresses in the allocated CIDR. A string since IPv6 counts overflow a number",
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.UseStateForUnknown(),
+				},
+			},
+			"ip_version": schema.Int64Attribute{
+				Computed:            true,
+				MarkdownDescription: "IP version of the allocated CIDR, 4 or 6. Useful in pools with both IPv4 and IPv6 CIDRs",
+				PlanModifiers: []planmodifier.Int64{
+					int64planmodifier.UseStateForUnknown(),
+				},
+			},
+			"block_index": schema.Int64Attribute{
+				Computed:            true,
+				MarkdownDescription: "Position of the allocated CIDR in the pool CIDR containing it, counted in blocks of its size from 0, e.g. 2 for `10.0.2.0/24` in `10.0.0.0/16`. Useful to derive VLAN IDs or names. Null when the pool no longer contains the CIDR",
+				PlanModifiers: []planmodifier.Int64{
+					int64planmodifier.UseStateForUnknown(),
+				},
+			},
 		},
 	}
 }
@@ -93,6 +287,171 @@ func (r *AllocationResource) Configure(ctx context.Context, req resource.Configu
 	r.provider = provider
 }
 
+func (r *AllocationResource) ValidateConfig(ctx context.Context, req resource.ValidateConfigRequest, resp *resource.ValidateConfigResponse) {
+	var data AllocationResourceModel
+
+	resp.Diagnostics.Append(req.Config.Get(ctx, &data)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	// unknown values can't be checked until apply
+	if data.PoolName.IsUnknown() || data.PoolNames.IsUnknown() {
+		return
+	}
+
+	if data.PoolName.IsNull() == data.PoolNames.IsNull() {
+		resp.Diagnostics.AddAttributeError(
+			path.Root("pool_name"),
+			"Invalid Pool",
+			"Exactly one of pool_name or pool_names must be set",
+		)
+		return
+	}
+	if data.PoolNames.IsNull() {
+		return
+	}
+
+	var poolNames []types.String
+	resp.Diagnostics.Append(data.PoolNames.ElementsAs(ctx, &poolNames, false)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	if len(poolNames) == 0 {
+		resp.Diagnostics.AddAttributeError(
+			path.Root("pool_names"),
+			"Invalid Pool Names",
+			"pool_names must list at least one pool",
+		)
+		return
+	}
+	seen := make(map[string]bool, len(poolNames))
+	for _, poolName := range poolNames {
+		if poolName.IsUnknown() {
+			continue
+		}
+		if poolName.IsNull() || poolName.ValueString() == "" {
+			resp.Diagnostics.AddAttributeError(
+				path.Root("pool_names"),
+				"Invalid Pool Names",
+				"pool_names can't contain an empty pool name",
+			)
+			return
+		}
+		if seen[poolName.ValueString()] {
+			resp.Diagnostics.AddAttributeError(
+				path.Root("pool_names"),
+				"Invalid Pool Names",
+				fmt.Sprintf("pool_names lists pool %s more than once", poolName.ValueString()),
+			)
+			return
+		}
+		seen[poolName.ValueString()] = true
+	}
+}
+
+func (r *AllocationResource) ModifyPlan(ctx context.Context, req resource.ModifyPlanRequest, resp *resource.ModifyPlanResponse) {
+	// the prefix length is planned from the subnets whether the allocation is created or replaced
+	if !req.Plan.Raw.IsNull() {
+		r.planSubnetPrefixLength(ctx, req, resp)
+		if resp.Diagnostics.HasError() {
+			return
+		}
+	}
+
+	// only a pool or TTL change on an existing allocation needs a decision
+	if req.State.Raw.IsNull() || req.Plan.Raw.IsNull() || r.provider == nil {
+		return
+	}
+
+	var plan, state AllocationResourceModel
+	resp.Diagnostics.Append(req.Plan.Get(ctx, &plan)...)
+	resp.Diagnostics.Append(req.State.Get(ctx, &state)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	// a new TTL renews the lease at apply time, and removing it clears the expiry
+	if !plan.TTLSeconds.Equal(state.TTLSeconds) {
+		expiresAt := types.StringUnknown()
+		if plan.TTLSeconds.IsNull() {
+			expiresAt = types.StringNull()
+		}
+		resp.Diagnostics.Append(resp.Plan.SetAttribute(ctx, path.Root("expires_at"), expiresAt)...)
+	}
+
+	if plan.PoolName.Equal(state.PoolName) {
+		return
+	}
+
+	// the index is counted in the new pool's CIDRs
+	resp.Diagnostics.Append(resp.Plan.SetAttribute(ctx, path.Root("block_index"), types.Int64Unknown())...)
+
+	// the target pool can only be checked once its name is known
+	if plan.PoolName.IsUnknown() {
+		resp.RequiresReplace = append(resp.RequiresReplace, path.Root("pool_name"))
+		return
+	}
+
+	allocation, err := r.provider.storage.GetAllocation(ctx, allocationKey(state.Key, state.ID))
+	if err == nil {
+		err = r.checkAllocationMove(ctx, allocation, plan.PoolName.ValueString())
+	}
+	if err != nil {
+		tflog.Debug(ctx, "allocation can't move to the new pool in place, replacing it", map[string]any{
+			"id":        state.ID.ValueString(),
+			"pool_name": plan.PoolName.ValueString(),
+			"reason":    err.Error(),
+		})
+		resp.RequiresReplace = append(resp.RequiresReplace, path.Root("pool_name"))
+	}
+}
+
+// planSubnetPrefixLength plans prefix_length as the smallest block holding subnet_count subnets of
+// subnet_prefix_length.
+func (r *AllocationResource) planSubnetPrefixLength(ctx context.Context, req resource.ModifyPlanRequest, resp *resource.ModifyPlanResponse) {
+	var config AllocationResourceModel
+	resp.Diagnostics.Append(req.Config.Get(ctx, &config)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	if config.SubnetCount.IsNull() && config.SubnetPrefix.IsNull() {
+		return
+	}
+	if config.SubnetCount.IsNull() || config.SubnetPrefix.IsNull() {
+		resp.Diagnostics.AddAttributeError(
+			path.Root("subnet_count"),
+			"Invalid Subnet Count",
+			"subnet_count and subnet_prefix_length must be set together",
+		)
+		return
+	}
+	if !config.PrefixLength.IsNull() || !config.RequestedCIDR.IsNull() {
+		resp.Diagnostics.AddAttributeError(
+			path.Root("subnet_count"),
+			"Invalid Subnet Count",
+			"subnet_count can't be combined with prefix_length or requested_cidr, the prefix length is computed from the subnets",
+		)
+		return
+	}
+
+	prefixLength := types.Int64Unknown()
+	if !config.SubnetCount.IsUnknown() && !config.SubnetPrefix.IsUnknown() {
+		length, err := subnetsPrefixLength(config.SubnetCount.ValueInt64(), config.SubnetPrefix.ValueInt64())
+		if err != nil {
+			resp.Diagnostics.AddAttributeError(
+				path.Root("subnet_count"),
+				"Invalid Subnet Count",
+				err.Error(),
+			)
+			return
+		}
+		prefixLength = types.Int64Value(length)
+	}
+	resp.Diagnostics.Append(resp.Plan.SetAttribute(ctx, path.Root("prefix_length"), prefixLength)...)
+}
+
 func (r *AllocationResource) Create(ctx context.Context, req resource.CreateRequest, resp *resource.CreateResponse) {
 	var data AllocationResourceModel
 
@@ -101,6 +460,127 @@ func (r *AllocationResource) Create(ctx context.Context, req resource.CreateRequ
 		return
 	}
 
+	preferredCIDR := ""
+	if !data.PreferredCIDR.IsNull() && !data.PreferredCIDR.IsUnknown() {
+		if !data.RequestedCIDR.IsNull() {
+			resp.Diagnostics.AddAttributeError(
+				path.Root("preferred_cidr"),
+				"Invalid Preferred CIDR",
+				"preferred_cidr can't be combined with requested_cidr, the requested CIDR already decides where the allocation is",
+			)
+			return
+		}
+		ip, preferredNet, err := net.ParseCIDR(data.PreferredCIDR.ValueString())
+		if err != nil {
+			resp.Diagnostics.AddAttributeError(
+				path.Root("preferred_cidr"),
+				"Invalid Preferred CIDR",
+				fmt.Sprintf("preferred_cidr must be a CIDR like '10.0.1.0/24': %s", err),
+			)
+			return
+		}
+		preferredCIDR = preferredNet.String()
+		if !ip.Equal(preferredNet.IP) {
+			resp.Diagnostics.AddAttributeWarning(
+				path.Root("preferred_cidr"),
+				"Preferred CIDR Normalized",
+				fmt.Sprintf("preferred_cidr %s has host bits set, its network %s is preferred instead", data.PreferredCIDR.ValueString(), preferredCIDR),
+			)
+		}
+
+		// a preference of another size could never be honored
+		preferredPrefixLength, _ := preferredNet.Mask.Size()
+		if data.PrefixLength.IsNull() || data.PrefixLength.IsUnknown() {
+			data.PrefixLength = types.Int64Value(int64(preferredPrefixLength))
+		} else if int(data.PrefixLength.ValueInt64()) != preferredPrefixLength {
+			resp.Diagnostics.AddAttributeError(
+				path.Root("prefix_length"),
+				"Conflicting Prefix Length",
+				fmt.Sprintf("prefix_length /%d doesn't match preferred_cidr %s", data.PrefixLength.ValueInt64(), preferredCIDR),
+			)
+			return
+		}
+	}
+
+	requestedCIDR := ""
+	if !data.RequestedCIDR.IsNull() && !data.RequestedCIDR.IsUnknown() {
+		ip, requestedNet, err := net.ParseCIDR(data.RequestedCIDR.ValueString())
+		if err != nil {
+			resp.Diagnostics.AddAttributeError(
+				path.Root("requested_cidr"),
+				"Invalid Requested CIDR",
+				fmt.Sprintf("requested_cidr must be a CIDR like '10.0.1.0/24': %s", err),
+			)
+			return
+		}
+		// an address inside the block, e.g. a gateway, stands for the block. State keeps the configured
+		// form so plans stay clean, storage and allocated_cidr get the network
+		requestedCIDR = requestedNet.String()
+		if !ip.Equal(requestedNet.IP) {
+			resp.Diagnostics.AddAttributeWarning(
+				path.Root("requested_cidr"),
+				"Requested CIDR Normalized",
+				fmt.Sprintf("requested_cidr %s has host bits set, its network %s is allocated instead", data.RequestedCIDR.ValueString(), requestedCIDR),
+			)
+		}
+
+		requestedPrefixLength, _ := requestedNet.Mask.Size()
+		if data.PrefixLength.IsNull() || data.PrefixLength.IsUnknown() {
+			data.PrefixLength = types.Int64Value(int64(requestedPrefixLength))
+		} else if int(data.PrefixLength.ValueInt64()) != requestedPrefixLength {
+			resp.Diagnostics.AddAttributeError(
+				path.Root("prefix_length"),
+				"Conflicting Prefix Length",
+				fmt.Sprintf("prefix_length /%d doesn't match requested_cidr %s", data.PrefixLength.ValueInt64(), requestedCIDR),
+			)
+			return
+		}
+
+		if !data.Alignment.IsNull() {
+			resp.Diagnostics.AddAttributeError(
+				path.Root("alignment"),
+				"Invalid Alignment",
+				"alignment can't be combined with requested_cidr, the requested CIDR fixes where the allocation starts",
+			)
+			return
+		}
+	} else if data.PrefixLength.IsNull() || data.PrefixLength.IsUnknown() {
+		// left to the pool's default_prefix_length, resolved once the pool is read
+		data.PrefixLength = types.Int64Value(0)
+	}
+
+	fromCIDR := ""
+	if !data.FromCIDR.IsNull() && !data.FromCIDR.IsUnknown() {
+		if requestedCIDR != "" {
+			resp.Diagnostics.AddAttributeError(
+				path.Root("from_cidr"),
+				"Invalid From CIDR",
+				"from_cidr can't be combined with requested_cidr, the requested CIDR already decides where the allocation is",
+			)
+			return
+		}
+		_, fromNet, err := net.ParseCIDR(data.FromCIDR.ValueString())
+		if err != nil {
+			resp.Diagnostics.AddAttributeError(
+				path.Root("from_cidr"),
+				"Invalid From CIDR",
+				fmt.Sprintf("from_cidr must be one of the pool's CIDRs: %s", err),
+			)
+			return
+		}
+		fromCIDR = fromNet.String()
+	}
+
+	allowOverlap := data.AllowOverlap.ValueBool()
+	if allowOverlap && requestedCIDR == "" {
+		resp.Diagnostics.AddAttributeError(
+			path.Root("allow_overlap"),
+			"Invalid Allow Overlap",
+			"allow_overlap only applies to a requested_cidr, automatically allocated blocks never overlap",
+		)
+		return
+	}
+
 	prefixLength := int(data.PrefixLength.ValueInt64())
 	if prefixLength < 0 || prefixLength > 128 {
 		resp.Diagnostics.AddError(
@@ -110,20 +590,92 @@ func (r *AllocationResource) Create(ctx context.Context, req resource.CreateRequ
 		return
 	}
 
+	// the alignment of an allocation using the pool default is checked when that's resolved
+	alignment := int(data.Alignment.ValueInt64())
+	if alignment < 0 || (prefixLength != 0 && alignment > prefixLength) {
+		resp.Diagnostics.AddAttributeError(
+			path.Root("alignment"),
+			"Invalid Alignment",
+			fmt.Sprintf("Alignment must be between 0 and the prefix length /%d, got /%d", prefixLength, alignment),
+		)
+		return
+	}
+
 	// Find the pool and allocate the range
-	poolName := data.PoolName.ValueString()
+	poolNames := []string{data.PoolName.ValueString()}
+	if !data.PoolNames.IsNull() {
+		resp.Diagnostics.Append(data.PoolNames.ElementsAs(ctx, &poolNames, false)...)
+		if resp.Diagnostics.HasError() {
+			return
+		}
+	}
 	allocationID := data.ID.ValueString()
-	allocatedCIDR, err := r.allocateCIDRFromPool(ctx, poolName, allocationID, prefixLength)
-	if err != nil {
-		resp.Diagnostics.AddError(
+	// with an allocation id template the id is left empty and generated once the CIDR is claimed
+	if (data.ID.IsNull() || data.ID.IsUnknown()) && r.provider.allocationIDTemplate == "" {
+		generatedID, err := uuid.GenerateUUID()
+		if err != nil {
+			resp.Diagnostics.AddError(
+				"Allocation ID Generation Failed",
+				fmt.Sprintf("Unable to generate an allocation ID: %s", err),
+			)
+			return
+		}
+		allocationID = generatedID
+	}
+
+	allocation := &storage.Allocation{
+		ID:            allocationID,
+		PrefixLength:  prefixLength,
+		Alignment:     alignment,
+		RequestedCIDR: requestedCIDR,
+		AllowOverlap:  allowOverlap,
+		FromCIDR:      fromCIDR,
+		Owner:         data.Owner.ValueString(),
+	}
+	resp.Diagnostics.Append(data.Metadata.ElementsAs(ctx, &allocation.Metadata, false)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	if !data.TTLSeconds.IsNull() && !data.TTLSeconds.IsUnknown() {
+		if data.TTLSeconds.ValueInt64() <= 0 {
+			resp.Diagnostics.AddAttributeError(
+				path.Root("ttl_seconds"),
+				"Invalid TTL",
+				fmt.Sprintf("ttl_seconds must be a positive number of seconds, got %d", data.TTLSeconds.ValueInt64()),
+			)
+			return
+		}
+		setAllocationLease(allocation, data.TTLSeconds.ValueInt64())
+	}
+	ipv6MinPrefix := r.provider.ipv6MinPrefix
+	if data.AllowShortIPv6.ValueBool() {
+		ipv6MinPrefix = 0
+	}
+	if err := r.allocateCIDRFromPools(ctx, allocation, poolNames, preferredCIDR, data.Sticky.ValueBool(), ipv6MinPrefix); err != nil {
+		addStorageWriteError(
+			&resp.Diagnostics,
 			"Allocation Failed",
-			fmt.Sprintf("Unable to allocate CIDR from pool %s: %s", poolName, err),
+			fmt.Sprintf("Unable to allocate CIDR from pool %s: %s", strings.Join(poolNames, ", "), err),
+			err,
 		)
 		return
 	}
+	poolName := allocation.PoolName
+	allocationID = allocation.ID
+	allocatedCIDR := allocation.AllocatedCIDR
 
 	data.ID = types.StringValue(allocationID)
+	data.Key = types.StringValue(allocation.Key)
+	data.PoolName = types.StringValue(poolName)
 	data.AllocatedCIDR = types.StringValue(allocatedCIDR)
+	data.PrefixLength = types.Int64Value(int64(allocation.PrefixLength))
+	data.PreferredHonored = types.BoolNull()
+	if preferredCIDR != "" {
+		data.PreferredHonored = types.BoolValue(allocatedCIDR == preferredCIDR)
+	}
+	data.ExpiresAt = allocationExpiresAt(allocation)
+	setAllocationAddressDetails(&data)
+	r.setAllocationBlockIndex(ctx, &data)
 
 	tflog.Trace(ctx, "created allocation resource", map[string]any{
 		"id":             allocationID,
@@ -131,6 +683,10 @@ func (r *AllocationResource) Create(ctx context.Context, req resource.CreateRequ
 		"allocated_cidr": allocatedCIDR,
 	})
 
+	resp.Diagnostics.Append(r.checkPoolUtilization(ctx, poolName, allocatedCIDR)...)
+
+	r.provider.notifyAllocation(ctx, &resp.Diagnostics, allocationWebhookCreate, allocation)
+
 	resp.Diagnostics.Append(resp.State.Set(ctx, &data)...)
 }
 
@@ -143,9 +699,9 @@ func (r *AllocationResource) Read(ctx context.Context, req resource.ReadRequest,
 	}
 
 	// Verify allocation still exists in storage
-	allocation, err := r.provider.storage.GetAllocation(ctx, data.ID.ValueString())
+	allocation, err := r.provider.storage.GetAllocation(ctx, allocationKey(data.Key, data.ID))
 	if err != nil {
-		if err == storage.ErrNotFound {
+		if errors.Is(err, storage.ErrNotFound) {
 			// allocation was deleted outside Terraform
 			resp.State.RemoveResource(ctx)
 			return
@@ -157,95 +713,1069 @@ func (r *AllocationResource) Read(ctx context.Context, req resource.ReadRequest,
 		return
 	}
 
-	// sync state with storage data
+	// storage is shared, so flag allocations that were edited outside terraform
+	if !data.AllocatedCIDR.IsNull() && data.AllocatedCIDR.ValueString() != allocation.AllocatedCIDR {
+		tflog.Warn(ctx, "allocated CIDR in storage differs from state, allocation may have been modified outside Terraform", map[string]any{
+			"id":           allocation.ID,
+			"pool_name":    allocation.PoolName,
+			"state_cidr":   data.AllocatedCIDR.ValueString(),
+			"storage_cidr": allocation.AllocatedCIDR,
+		})
+	}
+
+	// sync state with storage data, the key stays the same when the allocation is renamed outside Terraform
+	data.ID = types.StringValue(allocation.ID)
+	data.Key = types.StringValue(allocation.StorageKey())
 	data.AllocatedCIDR = types.StringValue(allocation.AllocatedCIDR)
 	data.PoolName = types.StringValue(allocation.PoolName)
 	data.PrefixLength = types.Int64Value(int64(allocation.PrefixLength))
+	if allocation.Alignment != 0 {
+		data.Alignment = types.Int64Value(int64(allocation.Alignment))
+	}
+	// a requested CIDR configured with host bits set is stored as its network
+	if allocation.RequestedCIDR != "" && cidrNetwork(data.RequestedCIDR.ValueString()) != allocation.RequestedCIDR {
+		data.RequestedCIDR = types.StringValue(allocation.RequestedCIDR)
+	}
+	data.AllowOverlap = types.BoolValue(allocation.AllowOverlap)
+	if allocation.FromCIDR != "" {
+		data.FromCIDR = types.StringValue(allocation.FromCIDR)
+	}
+	// an owner set to "" in config stays "", any other owner follows storage
+	if allocation.Owner != "" || !data.Owner.IsNull() {
+		data.Owner = types.StringValue(allocation.Owner)
+	}
+	// likewise for metadata set to {}
+	if len(allocation.Metadata) > 0 || !data.Metadata.IsNull() {
+		metadata, diags := allocationMetadataValue(ctx, allocation.Metadata)
+		resp.Diagnostics.Append(diags...)
+		if resp.Diagnostics.HasError() {
+			return
+		}
+		data.Metadata = metadata
+	}
+	if allocation.TTLSeconds != 0 {
+		data.TTLSeconds = types.Int64Value(allocation.TTLSeconds)
+	}
+	data.ExpiresAt = allocationExpiresAt(allocation)
+	setAllocationAddressDetails(&data)
+	r.setAllocationBlockIndex(ctx, &data)
+
+	if r.provider.verifyAllocationsInPool {
+		r.warnOutsidePool(ctx, allocation, &resp.Diagnostics)
+	}
+
+	// expired allocations are surfaced, deleting them here would be a surprise in the middle of a plan
+	if allocation.Expired(time.Now()) {
+		resp.Diagnostics.AddWarning(
+			"Allocation Expired",
+			fmt.Sprintf("Allocation %s (%s in pool %s) expired at %s. Destroy it to release the CIDR, or change ttl_seconds to renew it.",
+				allocation.ID, allocation.AllocatedCIDR, allocation.PoolName, data.ExpiresAt.ValueString()),
+		)
+	}
 
 	resp.Diagnostics.Append(resp.State.Set(ctx, &data)...)
 }
 
-func (r *AllocationResource) Update(ctx context.Context, req resource.UpdateRequest, resp *resource.UpdateResponse) {
-	// All attributes require replacement, so this should never be called
-	var data AllocationResourceModel
-
-	resp.Diagnostics.Append(req.Plan.Get(ctx, &data)...)
-	if resp.Diagnostics.HasError() {
+// warnOutsidePool adds a warning when the allocation is no longer inside its pool's CIDRs, like after the
+// pool shrank outside this configuration. The allocation is left alone, it's up to the operator whether
+// the pool or the allocation is wrong.
+func (r *AllocationResource) warnOutsidePool(ctx context.Context, allocation *storage.Allocation, diags *diag.Diagnostics) {
+	pool, err := r.provider.storage.GetPool(ctx, allocation.PoolName)
+	if err != nil {
+		// a missing pool is reported when the allocation changes, refreshing shouldn't fail over it
+		tflog.Warn(ctx, "unable to read pool to verify allocation is inside it", map[string]any{
+			"id":        allocation.ID,
+			"pool_name": allocation.PoolName,
+			"error":     err.Error(),
+		})
 		return
 	}
 
-	resp.Diagnostics.Append(resp.State.Set(ctx, &data)...)
+	if outside := allocationOutsidePool(pool, allocation); len(outside) > 0 {
+		diags.AddWarning(
+			"Allocation Outside Pool",
+			fmt.Sprintf("Allocation %s has %s outside the CIDRs of pool %s (%s). The pool may have been changed outside this configuration, "+
+				"and new allocations of the pool could overlap it.", allocation.ID, strings.Join(outside, ", "), pool.Name, strings.Join(poolCIDRs(pool), ", ")),
+		)
+	}
 }
 
-func (r *AllocationResource) Delete(ctx context.Context, req resource.DeleteRequest, resp *resource.DeleteResponse) {
-	var data AllocationResourceModel
+func (r *AllocationResource) Update(ctx context.Context, req resource.UpdateRequest, resp *resource.UpdateResponse) {
+	// Every attribute but pool_name, owner, metadata, ttl_seconds, rename_in_place, sticky,
+	// allow_short_ipv6_prefix and preferred_cidr requires replacement, as does id unless rename_in_place is
+	// set and pool_names unless it still includes the allocation's pool. So an update
+	// renames the allocation, moves it between pools, renews its lease, changes its owner or metadata, or a
+	// combination of those
+	var data, state AllocationResourceModel
 
-	resp.Diagnostics.Append(req.State.Get(ctx, &data)...)
+	resp.Diagnostics.Append(req.Plan.Get(ctx, &data)...)
+	resp.Diagnostics.Append(req.State.Get(ctx, &state)...)
 	if resp.Diagnostics.HasError() {
 		return
 	}
 
-	if err := r.provider.storage.DeleteAllocation(ctx, data.ID.ValueString()); err != nil {
-		resp.Diagnostics.AddError(
-			"Failed to Delete Allocation",
-			fmt.Sprintf("Could not delete allocation from storage: %s", err),
-		)
-		return
+	id := state.ID.ValueString()
+	key := allocationKey(state.Key, state.ID)
+	data.Key = types.StringValue(key)
+	if !data.ID.Equal(state.ID) {
+		newID := data.ID.ValueString()
+		if err := r.renameAllocation(ctx, key, newID); err != nil {
+			addStorageWriteError(
+				&resp.Diagnostics,
+				"Allocation Rename Failed",
+				fmt.Sprintf("Unable to rename allocation %s to %s: %s", id, newID, err),
+				err,
+			)
+			return
+		}
+
+		tflog.Info(ctx, "renamed allocation", map[string]any{
+			"from_id":        id,
+			"id":             newID,
+			"allocated_cidr": state.AllocatedCIDR.ValueString(),
+		})
+		id = newID
 	}
 
-	tflog.Trace(ctx, "deleted allocation resource", map[string]any{
-		"id":        data.ID.ValueString(),
-		"pool_name": data.PoolName.ValueString(),
-	})
-}
+	if !data.PoolName.Equal(state.PoolName) {
+		poolName := data.PoolName.ValueString()
+		if err := r.moveAllocation(ctx, key, poolName); err != nil {
+			addStorageWriteError(
+				&resp.Diagnostics,
+				"Allocation Move Failed",
+				fmt.Sprintf("Unable to move allocation %s to pool %s: %s. Run terraform plan again, an allocation that no longer fits the new pool is replaced instead.", id, poolName, err),
+				err,
+			)
+			return
+		}
 
-func (r *AllocationResource) ImportState(ctx context.Context, req resource.ImportStateRequest, resp *resource.ImportStateResponse) {
-	// For import we expect the ID to be the allocation ID
-	allocationID := req.ID
-	allocation, err := r.provider.storage.GetAllocation(ctx, allocationID)
+		tflog.Info(ctx, "moved allocation to a new pool", map[string]any{
+			"id":             id,
+			"from_pool_name": state.PoolName.ValueString(),
+			"pool_name":      poolName,
+			"allocated_cidr": state.AllocatedCIDR.ValueString(),
+		})
+	}
+
+	if !data.TTLSeconds.Equal(state.TTLSeconds) {
+		if !data.TTLSeconds.IsNull() && data.TTLSeconds.ValueInt64() <= 0 {
+			resp.Diagnostics.AddAttributeError(
+				path.Root("ttl_seconds"),
+				"Invalid TTL",
+				fmt.Sprintf("ttl_seconds must be a positive number of seconds, got %d", data.TTLSeconds.ValueInt64()),
+			)
+			return
+		}
+
+		ttlSeconds := data.TTLSeconds.ValueInt64()
+		allocation, err := r.updateAllocation(ctx, key, "renew the allocation", func(allocation *storage.Allocation) error {
+			setAllocationLease(allocation, ttlSeconds)
+			return nil
+		})
+		if err != nil {
+			addStorageWriteError(
+				&resp.Diagnostics,
+				"Allocation Renewal Failed",
+				fmt.Sprintf("Unable to update the TTL of allocation %s: %s", id, err),
+				err,
+			)
+			return
+		}
+		data.ExpiresAt = allocationExpiresAt(allocation)
+	}
+
+	if !data.Owner.Equal(state.Owner) {
+		owner := data.Owner.ValueString()
+		if _, err := r.updateAllocation(ctx, key, "update the allocation owner", func(allocation *storage.Allocation) error {
+			allocation.Owner = owner
+			return nil
+		}); err != nil {
+			addStorageWriteError(
+				&resp.Diagnostics,
+				"Allocation Owner Update Failed",
+				fmt.Sprintf("Unable to update the owner of allocation %s: %s", id, err),
+				err,
+			)
+			return
+		}
+	}
+
+	if !data.Metadata.Equal(state.Metadata) {
+		var metadata map[string]string
+		resp.Diagnostics.Append(data.Metadata.ElementsAs(ctx, &metadata, false)...)
+		if resp.Diagnostics.HasError() {
+			return
+		}
+		if _, err := r.updateAllocation(ctx, key, "update the allocation metadata", func(allocation *storage.Allocation) error {
+			allocation.Metadata = metadata
+			return nil
+		}); err != nil {
+			addStorageWriteError(
+				&resp.Diagnostics,
+				"Allocation Metadata Update Failed",
+				fmt.Sprintf("Unable to update the metadata of allocation %s: %s", id, err),
+				err,
+			)
+			return
+		}
+	}
+
+	r.setAllocationBlockIndex(ctx, &data)
+
+	resp.Diagnostics.Append(resp.State.Set(ctx, &data)...)
+}
+
+// allocationMetadataValue returns the metadata of an allocation for state, an empty map when it has none.
+func allocationMetadataValue(ctx context.Context, metadata map[string]string) (types.Map, diag.Diagnostics) {
+	if metadata == nil {
+		metadata = map[string]string{}
+	}
+	return types.MapValueFrom(ctx, types.StringType, metadata)
+}
+
+// renameAllocation changes the id of the allocation stored under the key, keeping its key, pool and CIDR.
+// The new id must not be used by another allocation of the pool.
+func (r *AllocationResource) renameAllocation(ctx context.Context, key string, newID string) error {
+	_, err := r.updateAllocation(ctx, key, "rename the allocation", func(allocation *storage.Allocation) error {
+		if _, err := r.provider.findAllocationByID(ctx, allocation.PoolName, newID); err == nil {
+			return fmt.Errorf("pool %s already has an allocation with id %s", allocation.PoolName, newID)
+		} else if !errors.Is(err, storage.ErrNotFound) {
+			return fmt.Errorf("failed to check for an existing allocation %s: %w", newID, err)
+		}
+
+		allocation.ID = newID
+		return nil
+	})
+	return err
+}
+
+// requiresReplaceUnlessRenameInPlace replaces the allocation on an id change unless rename_in_place is set.
+func requiresReplaceUnlessRenameInPlace(ctx context.Context, req planmodifier.StringRequest, resp *stringplanmodifier.RequiresReplaceIfFuncResponse) {
+	var renameInPlace types.Bool
+	resp.Diagnostics.Append(req.Plan.GetAttribute(ctx, path.Root("rename_in_place"), &renameInPlace)...)
+	resp.RequiresReplace = !renameInPlace.ValueBool()
+}
+
+// requiresReplaceUnlessInPoolNames replaces the allocation on a pool_names change unless the new list
+// still includes the pool it was allocated from. Switching to pool_name is planned like a pool_name change.
+func requiresReplaceUnlessInPoolNames(ctx context.Context, req planmodifier.ListRequest, resp *listplanmodifier.RequiresReplaceIfFuncResponse) {
+	if req.PlanValue.IsNull() {
+		return
+	}
+	if req.PlanValue.IsUnknown() {
+		resp.RequiresReplace = true
+		return
+	}
+
+	var poolName types.String
+	resp.Diagnostics.Append(req.State.GetAttribute(ctx, path.Root("pool_name"), &poolName)...)
+	var poolNames []types.String
+	resp.Diagnostics.Append(req.PlanValue.ElementsAs(ctx, &poolNames, false)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	resp.RequiresReplace = !slices.Contains(poolNames, poolName)
+}
+
+// updateAllocation applies change to the latest stored allocation and saves it, for attributes that
+// are updated in place without affecting the allocated CIDR. A change returning an error isn't saved.
+func (r *AllocationResource) updateAllocation(ctx context.Context, key string, operation string, change func(*storage.Allocation) error) (*storage.Allocation, error) {
+	fields := map[string]any{
+		"key": key,
+	}
+
+	var allocation *storage.Allocation
+	err := r.provider.retryOnConflict(ctx, operation, fields, func() error {
+		var err error
+		allocation, err = r.provider.storage.GetAllocation(ctx, key)
+		if err != nil {
+			return fmt.Errorf("failed to read allocation: %w", err)
+		}
+
+		if err := change(allocation); err != nil {
+			return err
+		}
+		if err := r.provider.storage.SaveAllocation(ctx, allocation); err != nil {
+			return fmt.Errorf("failed to save allocation: %w", err)
+		}
+		return nil
+	})
+	return allocation, err
+}
+
+// setAllocationLease sets the allocation's TTL and an expiry that many seconds from now, or clears both for a TTL of 0.
+// The expiry is kept in UTC whole seconds so it's the same after a round trip through any backend.
+func setAllocationLease(allocation *storage.Allocation, ttlSeconds int64) {
+	allocation.TTLSeconds = ttlSeconds
+	allocation.ExpiresAt = time.Time{}
+	if ttlSeconds > 0 {
+		allocation.ExpiresAt = time.Now().UTC().Truncate(time.Second).Add(time.Duration(ttlSeconds) * time.Second)
+	}
+}
+
+// allocationExpiresAt returns the allocation's expiry for state, null when it never expires.
+func allocationExpiresAt(allocation *storage.Allocation) types.String {
+	if allocation.ExpiresAt.IsZero() {
+		return types.StringNull()
+	}
+	return types.StringValue(allocation.ExpiresAt.UTC().Format(time.RFC3339))
+}
+
+// moveAllocation moves the allocation to another pool without changing its CIDR. Storage may have
+// changed since the plan, so the move is checked again before it's written.
+func (r *AllocationResource) moveAllocation(ctx context.Context, key string, poolName string) error {
+	fields := map[string]any{
+		"key":       key,
+		"pool_name": poolName,
+	}
+	return r.provider.retryOnConflict(ctx, "move the allocation", fields, func() error {
+		defer r.provider.lockPool(poolName)()
+
+		allocation, err := r.provider.storage.GetAllocation(ctx, key)
+		if err != nil {
+			return fmt.Errorf("failed to read allocation: %w", err)
+		}
+		if err := r.checkAllocationMove(ctx, allocation, poolName); err != nil {
+			return err
+		}
+
+		allocation.PoolName = poolName
+		if err := r.provider.storage.SaveAllocation(ctx, allocation); err != nil {
+			return fmt.Errorf("failed to save allocation: %w", err)
+		}
+		return nil
+	})
+}
+
+// checkAllocationMove returns an error explaining why the allocation's CIDR can't move to the pool as is.
+// The CIDR has to be allowed by the pool's prefix limits, fit inside its CIDRs (or its from_cidr) and
+// be free there, unless the allocation allows overlaps. Its id must not be taken in the pool either.
+func (r *AllocationResource) checkAllocationMove(ctx context.Context, allocation *storage.Allocation, poolName string) error {
+	_, allocNet, err := net.ParseCIDR(allocation.AllocatedCIDR)
+	if err != nil {
+		return fmt.Errorf("invalid allocated CIDR %s: %w", allocation.AllocatedCIDR, err)
+	}
+
+	pool, err := r.provider.storage.GetPool(ctx, poolName)
+	if err != nil {
+		return fmt.Errorf("failed to read pool %s: %w", poolName, err)
+	}
+
+	if err := validatePoolPrefixLength(pool, allocation.PrefixLength); err != nil {
+		return err
+	}
+
+	target := pool
+	if allocation.FromCIDR != "" {
+		fromCIDRs, err := selectPoolCIDR(pool, allocation.FromCIDR)
+		if err != nil {
+			return err
+		}
+		target = &storage.Pool{Name: pool.Name, CIDRs: fromCIDRs}
+	}
+	if !cidrInPool(target, allocNet) {
+		return fmt.Errorf("CIDR %s is not contained in any CIDR of pool %s", allocNet, poolName)
+	}
+
+	if err := r.provider.checkGloballyReserved(allocNet); err != nil {
+		return err
+	}
+
+	allocations, err := r.provider.storage.ListAllocationsByPool(ctx, poolName)
+	if err != nil {
+		return fmt.Errorf("failed to list allocations: %w", err)
+	}
+	if other := allocationWithID(allocations, allocation.ID); other != nil && other.StorageKey() != allocation.StorageKey() {
+		return fmt.Errorf("pool %s already has an allocation with id %s", poolName, allocation.ID)
+	}
+
+	if allocation.AllowOverlap {
+		return nil
+	}
+
+	for _, alloc := range allocations {
+		if alloc.StorageKey() == allocation.StorageKey() {
+			continue
+		}
+		for _, cidr := range alloc.CIDRs() {
+			_, otherNet, err := net.ParseCIDR(cidr)
+			if err != nil {
+				continue
+			}
+			if cidrsOverlap(allocNet, []*net.IPNet{otherNet}) {
+				return fmt.Errorf("CIDR %s overlaps allocation %s (%s) in pool %s", allocNet, alloc.ID, cidr, poolName)
+			}
+		}
+	}
+
+	return nil
+}
+
+func (r *AllocationResource) Delete(ctx context.Context, req resource.DeleteRequest, resp *resource.DeleteResponse) {
+	var data AllocationResourceModel
+
+	resp.Diagnostics.Append(req.State.Get(ctx, &data)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	key := allocationKey(data.Key, data.ID)
+	err := r.provider.retryOnConflict(ctx, "delete the allocation", map[string]any{"key": key}, func() error {
+		return r.provider.storage.DeleteAllocation(ctx, key)
+	})
 	if err != nil {
+		addStorageWriteError(
+			&resp.Diagnostics,
+			"Failed to Delete Allocation",
+			fmt.Sprintf("Could not delete allocation from storage: %s", err),
+			err,
+		)
+		return
+	}
+
+	r.provider.releaseCIDR(data.ID.ValueString(), data.PoolName.ValueString(), data.AllocatedCIDR.ValueString())
+	r.logFreedSpace(ctx, data.PoolName.ValueString(), data.AllocatedCIDR.ValueString())
+
+	tflog.Trace(ctx, "deleted allocation resource", map[string]any{
+		"id":        data.ID.ValueString(),
+		"pool_name": data.PoolName.ValueString(),
+	})
+
+	r.provider.notifyAllocation(ctx, &resp.Diagnostics, allocationWebhookDelete, &storage.Allocation{
+		Key:           allocationKey(data.Key, data.ID),
+		ID:            data.ID.ValueString(),
+		PoolName:      data.PoolName.ValueString(),
+		AllocatedCIDR: data.AllocatedCIDR.ValueString(),
+	})
+}
+
+func (r *AllocationResource) ImportState(ctx context.Context, req resource.ImportStateRequest, resp *resource.ImportStateResponse) {
+	// import formats: allocation key, allocation id when only one pool uses it, or pool_name:cidr for
+	// subnets that are known by pool and CIDR
+	allocation, err := r.provider.findAllocation(ctx, req.ID)
+	if errors.Is(err, storage.ErrNotFound) {
+		poolName, cidr, ok := parseAllocationImportID(req.ID)
+		if !ok {
+			resp.Diagnostics.AddError(
+				"Allocation Not Found",
+				fmt.Sprintf("Allocation %s not found in storage. Import ID must be an allocation key or ID, or in format: pool_name:cidr", req.ID),
+			)
+			return
+		}
+		allocation, err = r.importAllocationByCIDR(ctx, poolName, cidr)
+		if err != nil {
+			addStorageWriteError(
+				&resp.Diagnostics,
+				"Failed to Import Allocation",
+				fmt.Sprintf("Could not import %s from pool %s: %s", cidr, poolName, err),
+				err,
+			)
+			return
+		}
+	} else if err != nil {
 		resp.Diagnostics.AddError(
 			"Allocation Not Found",
-			fmt.Sprintf("Allocation %s not found in storage: %s", allocationID, err),
+			fmt.Sprintf("Allocation %s not found in storage: %s", req.ID, err),
 		)
 		return
 	}
 
 	data := AllocationResourceModel{
-		ID:            types.StringValue(allocation.ID),
-		PoolName:      types.StringValue(allocation.PoolName),
-		AllocatedCIDR: types.StringValue(allocation.AllocatedCIDR),
-		PrefixLength:  types.Int64Value(int64(allocation.PrefixLength)),
+		ID:             types.StringValue(allocation.ID),
+		Key:            types.StringValue(allocation.StorageKey()),
+		PoolName:       types.StringValue(allocation.PoolName),
+		AllocatedCIDR:  types.StringValue(allocation.AllocatedCIDR),
+		PrefixLength:   types.Int64Value(int64(allocation.PrefixLength)),
+		Alignment:      types.Int64Null(),
+		RequestedCIDR:  types.StringNull(),
+		AllowOverlap:   types.BoolValue(allocation.AllowOverlap),
+		FromCIDR:       types.StringNull(),
+		Owner:          types.StringNull(),
+		Metadata:       types.MapNull(types.StringType),
+		TTLSeconds:     types.Int64Null(),
+		RenameInPlace:  types.BoolValue(false),
+		Sticky:         types.BoolValue(false),
+		AllowShortIPv6: types.BoolValue(false),
+		ExpiresAt:      allocationExpiresAt(allocation),
+	}
+	if allocation.Alignment != 0 {
+		data.Alignment = types.Int64Value(int64(allocation.Alignment))
+	}
+	if allocation.RequestedCIDR != "" {
+		data.RequestedCIDR = types.StringValue(allocation.RequestedCIDR)
+	}
+	if allocation.FromCIDR != "" {
+		data.FromCIDR = types.StringValue(allocation.FromCIDR)
+	}
+	if allocation.Owner != "" {
+		data.Owner = types.StringValue(allocation.Owner)
+	}
+	if len(allocation.Metadata) > 0 {
+		metadata, diags := allocationMetadataValue(ctx, allocation.Metadata)
+		resp.Diagnostics.Append(diags...)
+		if resp.Diagnostics.HasError() {
+			return
+		}
+		data.Metadata = metadata
+	}
+	if allocation.TTLSeconds != 0 {
+		data.TTLSeconds = types.Int64Value(allocation.TTLSeconds)
 	}
+	setAllocationAddressDetails(&data)
+	r.setAllocationBlockIndex(ctx, &data)
 
 	resp.Diagnostics.Append(resp.State.Set(ctx, &data)...)
 }
 
-// allocateCIDRFromPool finds an available CIDR block in the pool and saves it to storage.
-// This implements a greedy search to find non-overlapping CIDR blocks
-// of the requested size within the pool's CIDR ranges.
-func (r *AllocationResource) allocateCIDRFromPool(ctx context.Context, poolName string, allocationId string, prefixLength int) (string, error) {
+// parseAllocationImportID splits a pool_name:cidr import ID. The split is on the first colon
+// so IPv6 CIDRs work, which means pool names containing a colon can't be imported this way.
+func parseAllocationImportID(id string) (string, string, bool) {
+	poolName, cidr, found := strings.Cut(id, ":")
+	if !found || poolName == "" {
+		return "", "", false
+	}
+	if _, _, err := net.ParseCIDR(cidr); err != nil {
+		return "", "", false
+	}
+	return poolName, cidr, true
+}
+
+// importAllocationByCIDR returns the pool's allocation for the CIDR. When the pool doesn't have
+// one yet, a new allocation with a generated ID is recorded for the CIDR so existing subnets can
+// be brought under management.
+func (r *AllocationResource) importAllocationByCIDR(ctx context.Context, poolName string, cidr string) (*storage.Allocation, error) {
+	_, cidrNet, err := net.ParseCIDR(cidr)
+	if err != nil {
+		return nil, err
+	}
+
+	var allocation *storage.Allocation
+	fields := map[string]any{
+		"pool_name": poolName,
+		"cidr":      cidrNet.String(),
+	}
+	err = r.provider.retryOnConflict(ctx, "record the imported allocation", fields, func() error {
+		defer r.provider.lockPool(poolName)()
+
+		var err error
+		allocation, err = r.tryImportAllocationByCIDR(ctx, poolName, cidrNet)
+		return err
+	})
+	return allocation, err
+}
+
+func (r *AllocationResource) tryImportAllocationByCIDR(ctx context.Context, poolName string, cidrNet *net.IPNet) (*storage.Allocation, error) {
 	pool, err := r.provider.storage.GetPool(ctx, poolName)
 	if err != nil {
-		return "", fmt.Errorf("pool %s not found: %w", poolName, err)
+		return nil, fmt.Errorf("failed to read pool %s: %w", poolName, err)
 	}
 
 	allocations, err := r.provider.storage.ListAllocationsByPool(ctx, poolName)
 	if err != nil {
-		return "", fmt.Errorf("failed to list allocations: %w", err)
+		return nil, fmt.Errorf("failed to list allocations: %w", err)
 	}
 
 	var allocatedCIDRs []*net.IPNet
 	for _, alloc := range allocations {
-		_, allocNet, err := net.ParseCIDR(alloc.AllocatedCIDR)
+		for _, cidr := range alloc.CIDRs() {
+			_, allocNet, err := net.ParseCIDR(cidr)
+			if err != nil {
+				continue
+			}
+			// a block of an allocation made of several CIDRs isn't an allocation of its own, so it only counts as taken
+			if len(alloc.AllocatedCIDRs) == 0 && allocNet.String() == cidrNet.String() {
+				return &alloc, nil
+			}
+			allocatedCIDRs = append(allocatedCIDRs, allocNet)
+		}
+	}
+
+	// no existing allocation, the CIDR has to fit in the pool without overlapping anything
+	if !cidrInPool(pool, cidrNet) {
+		return nil, fmt.Errorf("CIDR %s is not contained in any CIDR of pool %s", cidrNet, poolName)
+	}
+	if cidrsOverlap(cidrNet, allocatedCIDRs) {
+		return nil, fmt.Errorf("CIDR %s overlaps an existing allocation in pool %s", cidrNet, poolName)
+	}
+	if err := r.provider.checkGloballyReserved(cidrNet); err != nil {
+		return nil, err
+	}
+
+	allocationID, err := uuid.GenerateUUID()
+	if err != nil {
+		return nil, fmt.Errorf("unable to generate an allocation ID: %w", err)
+	}
+	key, err := newAllocationKey()
+	if err != nil {
+		return nil, err
+	}
+
+	prefixLength, _ := cidrNet.Mask.Size()
+	allocation := &storage.Allocation{
+		Key:           key,
+		ID:            allocationID,
+		PoolName:      poolName,
+		AllocatedCIDR: cidrNet.String(),
+		PrefixLength:  prefixLength,
+	}
+	if err := r.provider.storage.SaveAllocation(ctx, allocation); err != nil {
+		return nil, fmt.Errorf("failed to save allocation: %w", err)
+	}
+
+	tflog.Info(ctx, "recorded new allocation for imported CIDR", map[string]any{
+		"id":             allocationID,
+		"pool_name":      poolName,
+		"allocated_cidr": allocation.AllocatedCIDR,
+	})
+
+	return allocation, nil
+}
+
+// setAllocationAddressDetails fills in the attributes derived from the allocated CIDR.
+func setAllocationAddressDetails(data *AllocationResourceModel) {
+	data.NetworkAddress = types.StringNull()
+	data.BroadcastAddress = types.StringNull()
+	data.AddressCount = types.StringNull()
+	data.IPVersion = types.Int64Null()
+
+	network, broadcast, count, err := cidrAddressDetails(data.AllocatedCIDR.ValueString())
+	if err != nil {
+		return
+	}
+
+	data.NetworkAddress = types.StringValue(network)
+	data.AddressCount = types.StringValue(count)
+	data.IPVersion = types.Int64Value(ipVersion(data.AllocatedCIDR.ValueString()))
+	if broadcast != "" {
+		data.BroadcastAddress = types.StringValue(broadcast)
+	}
+}
+
+// setAllocationBlockIndex sets block_index from the CIDRs of the allocation's pool. It's informational,
+// so a pool that can't be read leaves it null like a pool that no longer contains the CIDR.
+func (r *AllocationResource) setAllocationBlockIndex(ctx context.Context, data *AllocationResourceModel) {
+	data.BlockIndex = types.Int64Null()
+
+	pool, err := r.provider.storage.GetPool(ctx, data.PoolName.ValueString())
+	if err != nil {
+		tflog.Warn(ctx, "unable to read pool to compute the allocation's block index", map[string]any{
+			"id":        data.ID.ValueString(),
+			"pool_name": data.PoolName.ValueString(),
+			"error":     err.Error(),
+		})
+		return
+	}
+
+	if index, ok := blockIndex(data.AllocatedCIDR.ValueString(), poolCIDRs(pool)); ok {
+		data.BlockIndex = types.Int64Value(index)
+	}
+}
+
+// checkPoolUtilization logs how full the pool is after an allocation, for the address family of the
+// allocated CIDR, and returns a warning when the free space dropped below the provider's exhaustion
+// threshold. It's informational only, so failures are logged and otherwise ignored.
+func (r *AllocationResource) checkPoolUtilization(ctx context.Context, poolName string, allocatedCIDR string) diag.Diagnostics {
+	var diags diag.Diagnostics
+
+	_, allocNet, err := net.ParseCIDR(allocatedCIDR)
+	if err != nil {
+		return diags
+	}
+	_, bits := allocNet.Mask.Size()
+
+	pool, err := r.provider.storage.GetPool(ctx, poolName)
+	if err != nil {
+		tflog.Debug(ctx, "unable to read pool for utilization summary", map[string]any{
+			"pool_name": poolName,
+			"error":     err.Error(),
+		})
+		return diags
+	}
+	allocations, err := r.provider.storage.ListAllocationsByPool(ctx, poolName)
+	if err != nil {
+		tflog.Debug(ctx, "unable to list allocations for utilization summary", map[string]any{
+			"pool_name": poolName,
+			"error":     err.Error(),
+		})
+		return diags
+	}
+
+	family := "ipv4"
+	if bits == 128 {
+		family = "ipv6"
+	}
+	total, used := poolUtilization(pool, allocations, bits)
+	percent := utilizationPercent(total, used)
+	tflog.Info(ctx, "pool utilization after allocation", map[string]any{
+		"pool_name":           poolName,
+		"address_family":      family,
+		"allocated_addresses": used.String(),
+		"total_addresses":     total.String(),
+		"utilization_percent": fmt.Sprintf("%.2f", percent),
+	})
+
+	threshold := r.provider.exhaustionWarnThreshold
+	if threshold > 0 && total.Sign() > 0 && 100-percent < threshold {
+		diags.AddWarning(
+			"Pool Nearly Exhausted",
+			fmt.Sprintf("Pool %s has %.2f%% of its %s addresses free after allocating %s (%s of %s addresses used), below the %g%% warning threshold. "+
+				"Add CIDRs to the pool before it runs out.", poolName, 100-percent, family, allocatedCIDR, used.String(), total.String(), threshold),
+		)
+	}
+
+	return diags
+}
+
+// logFreedSpace logs how much of the pool is free after an allocation was deleted, for the address family
+// of the released CIDR. Like checkPoolUtilization it's informational only, so failures are logged at debug.
+func (r *AllocationResource) logFreedSpace(ctx context.Context, poolName string, releasedCIDR string) {
+	_, releasedNet, err := net.ParseCIDR(releasedCIDR)
+	if err != nil {
+		return
+	}
+	_, bits := releasedNet.Mask.Size()
+
+	pool, err := r.provider.storage.GetPool(ctx, poolName)
+	if err != nil {
+		tflog.Debug(ctx, "unable to read pool for utilization summary", map[string]any{
+			"pool_name": poolName,
+			"error":     err.Error(),
+		})
+		return
+	}
+	allocations, err := r.provider.storage.ListAllocationsByPool(ctx, poolName)
+	if err != nil {
+		tflog.Debug(ctx, "unable to list allocations for utilization summary", map[string]any{
+			"pool_name": poolName,
+			"error":     err.Error(),
+		})
+		return
+	}
+
+	family := "ipv4"
+	if bits == 128 {
+		family = "ipv6"
+	}
+	total, used := poolUtilization(pool, allocations, bits)
+	tflog.Info(ctx, "pool utilization after release", map[string]any{
+		"pool_name":           poolName,
+		"address_family":      family,
+		"released_cidr":       releasedCIDR,
+		"free_addresses":      new(big.Int).Sub(total, used).String(),
+		"total_addresses":     total.String(),
+		"utilization_percent": fmt.Sprintf("%.2f", utilizationPercent(total, used)),
+	})
+}
+
+// allocateCIDRFromPools allocates from the first of the pools with a free block of the allocation's
+// size and sets its pool name to that pool. Only a pool without room fails over to the next one, any other
+// error fails the allocation. A non-empty preferredCIDR is claimed when it's free in a pool, otherwise a
+// sticky allocation being replaced asks each pool for the block it held there before it was deleted.
+func (r *AllocationResource) allocateCIDRFromPools(ctx context.Context, allocation *storage.Allocation, poolNames []string, preferredCIDR string, sticky bool, ipv6MinPrefix int) error {
+	// a prefix length left to the pool default is resolved by each pool
+	prefixLength := allocation.PrefixLength
+
+	var errs []error
+	for _, poolName := range poolNames {
+		allocation.PoolName = poolName
+		allocation.PrefixLength = prefixLength
+
+		previousCIDR := preferredCIDR
+		if sticky {
+			// the released block is taken either way, so it isn't offered to a later replacement
+			if releasedCIDR := r.provider.takeReleasedCIDR(allocation.ID, poolName); previousCIDR == "" {
+				previousCIDR = releasedCIDR
+			}
+		}
+		err := r.allocateCIDRFromPool(ctx, allocation, previousCIDR, ipv6MinPrefix)
+		if !errors.Is(err, errNoAvailableCIDR) {
+			return err
+		}
+
+		tflog.Info(ctx, "pool has no room for the allocation, trying the next pool", map[string]any{
+			"id":        allocation.ID,
+			"pool_name": poolName,
+		})
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
+
+// allocateCIDRFromPool claims a CIDR block in the pool for the allocation and sets its AllocatedCIDR.
+// A non-empty previousCIDR, the allocation's preferred CIDR or the block it held before, is claimed
+// instead of the next free block when it's still free.
+// The claim is a conditional write to storage, if another writer got there first the backend reloads
+// the latest data and the search is retried against it until the provider's claim timeout runs out.
+// Claims of this process are made one pool at a time, since they all share the same storage data.
+func (r *AllocationResource) allocateCIDRFromPool(ctx context.Context, allocation *storage.Allocation, previousCIDR string, ipv6MinPrefix int) error {
+	fields := map[string]any{
+		"id":        allocation.ID,
+		"pool_name": allocation.PoolName,
+	}
+	return r.provider.retryOnConflict(ctx, "claim a CIDR", fields, func() error {
+		// the pool is only locked while claiming, so other claims can go ahead while this one backs off
+		defer r.provider.lockPool(allocation.PoolName)()
+
+		return r.tryAllocateCIDRFromPool(ctx, allocation, previousCIDR, ipv6MinPrefix)
+	})
+}
+
+// tryAllocateCIDRFromPool finds an available CIDR block in the pool and saves it to storage.
+// This implements a greedy search to find non-overlapping CIDR blocks
+// of the requested size within the pool's CIDR ranges. When the allocation has a
+// requested CIDR, only that block is checked. A previous CIDR that's still free is taken before searching.
+// IPv6 blocks with a shorter prefix length than a non-zero ipv6MinPrefix are never claimed.
+// An allocation without a prefix length gets the pool's default prefix length.
+func (r *AllocationResource) tryAllocateCIDRFromPool(ctx context.Context, allocation *storage.Allocation, previousCIDR string, ipv6MinPrefix int) error {
+	poolName := allocation.PoolName
+	alignment := allocation.Alignment
+
+	pool, err := r.provider.storage.GetPool(ctx, poolName)
+	if err != nil {
+		return fmt.Errorf("failed to read pool %s: %w", poolName, err)
+	}
+
+	if allocation.PrefixLength == 0 {
+		if pool.DefaultPrefixLength == 0 {
+			return fmt.Errorf("no prefix_length, requested_cidr or subnet_count is set and pool %s has no default_prefix_length", poolName)
+		}
+		if alignment > pool.DefaultPrefixLength {
+			return fmt.Errorf("alignment /%d is longer than the default prefix length /%d of pool %s", alignment, pool.DefaultPrefixLength, poolName)
+		}
+		allocation.PrefixLength = pool.DefaultPrefixLength
+	}
+	prefixLength := allocation.PrefixLength
+
+	if err := validatePoolPrefixLength(pool, prefixLength); err != nil {
+		return err
+	}
+
+	allocations, err := r.provider.storage.ListAllocationsByPool(ctx, poolName)
+	if err != nil {
+		return fmt.Errorf("failed to list allocations: %w", err)
+	}
+
+	// ids are unique within a pool, a templated id is picked once the CIDR is claimed
+	if allocation.ID != "" {
+		if existing := allocationWithID(allocations, allocation.ID); existing != nil {
+			return fmt.Errorf("allocation id %s already in use by %s in pool %s", allocation.ID, existing.AllocatedCIDR, existing.PoolName)
+		}
+	}
+
+	guardIPv6 := ipv6MinPrefix != 0 && prefixLength < ipv6MinPrefix
+	if allocation.RequestedCIDR != "" {
+		if guardIPv6 && ipVersion(allocation.RequestedCIDR) == 6 {
+			return ipv6MinPrefixError(pool.Name, prefixLength, ipv6MinPrefix)
+		}
+		return r.claimRequestedCIDR(ctx, pool, allocation, allocations)
+	}
+
+	// globally reserved CIDRs are never handed out, so the search treats them as taken
+	allocatedCIDRs := takenCIDRs(allocations, r.provider.globallyReservedCIDRs)
+	allocatedCIDRs = append(allocatedCIDRs, boundarySubnets(pool, prefixLength)...)
+
+	searchCIDRs := prioritizedPoolCIDRs(pool)
+	if allocation.FromCIDR != "" {
+		searchCIDRs, err = selectPoolCIDR(pool, allocation.FromCIDR)
+		if err != nil {
+			return err
+		}
+	}
+
+	// a prefix too short for IPv6 can still be allocated from the pool's IPv4 CIDRs
+	if guardIPv6 {
+		var ipv4CIDRs []string
+		for _, cidr := range searchCIDRs {
+			if ipVersion(cidr) == 4 {
+				ipv4CIDRs = append(ipv4CIDRs, cidr)
+			}
+		}
+		if len(ipv4CIDRs) == 0 || prefixLength > 32 {
+			return ipv6MinPrefixError(pool.Name, prefixLength, ipv6MinPrefix)
+		}
+		searchCIDRs = ipv4CIDRs
+	}
+
+	findCIDR := poolCIDRFinder(pool)
+	candidateCIDR := previousCIDRAvailable(previousCIDR, searchCIDRs, prefixLength, alignment, allocatedCIDRs)
+	if candidateCIDR != nil {
+		tflog.Info(ctx, "claiming the preferred or previous CIDR of the allocation", map[string]any{
+			"id":             allocation.ID,
+			"pool_name":      poolName,
+			"allocated_cidr": candidateCIDR.String(),
+		})
+	} else {
+		candidateCIDR = findCIDR(searchCIDRs, prefixLength, alignment, allocatedCIDRs)
+	}
+	if candidateCIDR == nil && allocation.FromCIDR == "" && pool.AutoExpandCIDR != "" && !(guardIPv6 && ipVersion(pool.AutoExpandCIDR) == 6) {
+		expandedCIDR, err := r.expandPool(ctx, pool, prefixLength, alignment)
+		if err != nil {
+			return err
+		}
+		if expandedCIDR != "" {
+			// the pool now includes the expanded CIDR, whose boundary subnets are skipped too
+			allocatedCIDRs = append(allocatedCIDRs, boundarySubnets(pool, prefixLength)...)
+			candidateCIDR = findCIDR([]string{expandedCIDR}, prefixLength, alignment, allocatedCIDRs)
+		}
+	}
+	if candidateCIDR != nil {
+		// save new allocation to storage
+		allocation.AllocatedCIDR = candidateCIDR.String()
+		if err := r.saveClaimedAllocation(ctx, allocation, allocations); err != nil {
+			allocation.AllocatedCIDR = ""
+			return fmt.Errorf("failed to save allocation: %w", err)
+		}
+
+		return nil
+	}
+
+	where := "pool " + poolName
+	if allocation.FromCIDR != "" {
+		where = fmt.Sprintf("%s of pool %s", allocation.FromCIDR, poolName)
+	}
+	if alignment != 0 {
+		return fmt.Errorf("%w of size /%d aligned to /%d in %s", errNoAvailableCIDR, prefixLength, alignment, where)
+	}
+	return fmt.Errorf("%w of size /%d in %s", errNoAvailableCIDR, prefixLength, where)
+}
+
+// expandPool adds a free block of the pool's auto_expand_cidr to its expanded CIDRs for an allocation
+// that didn't fit, and returns it. Blocks overlapping the CIDRs of any pool or a globally reserved CIDR
+// are skipped. It returns an empty string when the auto expand CIDR has no room left.
+func (r *AllocationResource) expandPool(ctx context.Context, pool *storage.Pool, prefixLength int, alignment int) (string, error) {
+	_, supernet, err := net.ParseCIDR(pool.AutoExpandCIDR)
+	if err != nil {
+		return "", fmt.Errorf("auto_expand_cidr %s of pool %s is not valid: %w", pool.AutoExpandCIDR, pool.Name, err)
+	}
+	supernetPrefix, bits := supernet.Mask.Size()
+	if prefixLength > bits || prefixLength < supernetPrefix {
+		// the allocation is of another address family or larger than the whole auto expand CIDR
+		return "", nil
+	}
+
+	// an allocation larger than the configured blocks gets a block of its own size, and an aligned
+	// one a block that starts on the alignment boundary
+	blockPrefix := pool.AutoExpandPrefixLength
+	if prefixLength < blockPrefix {
+		blockPrefix = prefixLength
+	}
+	if alignment != 0 && alignment < blockPrefix {
+		blockPrefix = alignment
+	}
+
+	pools, err := r.provider.storage.ListPools(ctx)
+	if err != nil {
+		return "", fmt.Errorf("failed to list pools: %w", err)
+	}
+	taken := append([]*net.IPNet{}, r.provider.globallyReservedCIDRs...)
+	for i := range pools {
+		for _, cidr := range poolCIDRs(&pools[i]) {
+			if _, cidrNet, err := net.ParseCIDR(cidr); err == nil {
+				taken = append(taken, cidrNet)
+			}
+		}
+	}
+	sortCIDRs(taken)
+
+	block := findAvailableCIDR(supernet, blockPrefix, 0, taken)
+	if block == nil {
+		tflog.Warn(ctx, "pool can't be expanded, its auto expand CIDR has no free block", map[string]any{
+			"pool_name":        pool.Name,
+			"auto_expand_cidr": pool.AutoExpandCIDR,
+			"prefix_length":    blockPrefix,
+		})
+		return "", nil
+	}
+
+	pool.ExpandedCIDRs = append(pool.ExpandedCIDRs, block.String())
+	if err := r.provider.storage.SavePool(ctx, pool); err != nil {
+		pool.ExpandedCIDRs = pool.ExpandedCIDRs[:len(pool.ExpandedCIDRs)-1]
+		// another writer changed the pool since it was read, so the allocation starts over against it
+		if errors.Is(err, storage.ErrStaleRevision) {
+			return "", fmt.Errorf("pool %s changed while expanding it: %w: %w", pool.Name, storage.ErrConflict, err)
+		}
+		return "", fmt.Errorf("failed to expand pool %s: %w", pool.Name, err)
+	}
+
+	tflog.Info(ctx, "expanded exhausted pool", map[string]any{
+		"pool_name":        pool.Name,
+		"auto_expand_cidr": pool.AutoExpandCIDR,
+		"expanded_cidr":    block.String(),
+	})
+	return block.String(), nil
+}
+
+// saveClaimedAllocation saves an allocation whose CIDR was just claimed, under a newly generated key
+// unless it already has one. An allocation without an id gets one generated from the provider's
+// allocation id template first, unique among the pool's allocations. It's cleared again when the save
+// fails so a retry generates it against the latest data.
+func (r *AllocationResource) saveClaimedAllocation(ctx context.Context, allocation *storage.Allocation, poolAllocations []storage.Allocation) error {
+	if allocation.Key == "" {
+		key, err := newAllocationKey()
+		if err != nil {
+			return err
+		}
+		allocation.Key = key
+	}
+
+	generated := allocation.ID == ""
+	if generated {
+		allocation.ID = r.provider.generateAllocationID(allocation.PoolName, allocation.AllocatedCIDR, poolAllocations)
+	}
+
+	if err := r.provider.storage.SaveAllocation(ctx, allocation); err != nil {
+		if generated {
+			allocation.ID = ""
+		}
+		return err
+	}
+	return nil
+}
+
+// ipv6MinPrefixError is the error for an IPv6 block shorter than the provider's ipv6_min_prefix.
+func ipv6MinPrefixError(poolName string, prefixLength int, ipv6MinPrefix int) error {
+	return fmt.Errorf("prefix length /%d is shorter than the provider's ipv6_min_prefix /%d for IPv6 blocks in pool %s. "+
+		"Set allow_short_ipv6_prefix on the allocation if it's meant to be this large", prefixLength, ipv6MinPrefix, poolName)
+}
+
+// validatePoolPrefixLength checks that the prefix length is valid for the pool's address family
+// and inside the pool's allowed prefix lengths.
+func validatePoolPrefixLength(pool *storage.Pool, prefixLength int) error {
+	// reject prefixes that are too long for every address family in the pool
+	poolBits := 0
+	for _, poolCIDRStr := range poolCIDRs(pool) {
+		_, poolNet, err := net.ParseCIDR(poolCIDRStr)
 		if err != nil {
 			continue
 		}
-		allocatedCIDRs = append(allocatedCIDRs, allocNet)
+		_, bits := poolNet.Mask.Size()
+		if bits > poolBits {
+			poolBits = bits
+		}
+	}
+	if poolBits == 32 && prefixLength > 32 {
+		return fmt.Errorf("prefix length /%d is not valid for IPv4 pool %s, IPv4 prefix lengths must be between 0 and 32", prefixLength, pool.Name)
+	}
+	if poolBits == 128 && prefixLength > 128 {
+		return fmt.Errorf("prefix length /%d is not valid for IPv6 pool %s, IPv6 prefix lengths must be between 0 and 128", prefixLength, pool.Name)
 	}
 
+	// enforce the pool's allowed prefix lengths
+	if pool.MinPrefixLength != 0 && prefixLength < pool.MinPrefixLength {
+		return fmt.Errorf("prefix length /%d is shorter than the minimum /%d allowed by pool %s", prefixLength, pool.MinPrefixLength, pool.Name)
+	}
+	if pool.MaxPrefixLength != 0 && prefixLength > pool.MaxPrefixLength {
+		return fmt.Errorf("prefix length /%d is longer than the maximum /%d allowed by pool %s", prefixLength, pool.MaxPrefixLength, pool.Name)
+	}
+
+	return nil
+}
+
+// findNextCIDR returns the first free block of the prefix length in the pool CIDRs, searched in order,
+// or nil when none of them has room.
+func findNextCIDR(poolCIDRs []string, prefixLength int, alignment int, allocatedCIDRs []*net.IPNet) *net.IPNet {
 	// look for available CIDR block in each pool CIDR
-	for _, poolCIDRStr := range pool.CIDRs {
+	for _, poolCIDRStr := range poolCIDRs {
 		_, poolNet, err := net.ParseCIDR(poolCIDRStr)
 		if err != nil {
 			continue
@@ -259,32 +1789,200 @@ func (r *AllocationResource) allocateCIDRFromPool(ctx context.Context, poolName
 		}
 
 		// search for available cidr
-		candidateCIDR := findAvailableCIDR(poolNet, prefixLength, allocatedCIDRs)
-		if candidateCIDR != nil {
-			allocatedCIDR := candidateCIDR.String()
-
-			// save new allocation to storage
-			allocation := &storage.Allocation{
-				ID:            allocationId,
-				PoolName:      poolName,
-				AllocatedCIDR: allocatedCIDR,
-				PrefixLength:  prefixLength,
+		if candidateCIDR := findAvailableCIDR(poolNet, prefixLength, alignment, allocatedCIDRs); candidateCIDR != nil {
+			return candidateCIDR
+		}
+	}
+
+	return nil
+}
+
+// findBestFitCIDR returns a free block of the prefix length from the smallest free gap of the pool
+// CIDRs it fits in, so larger gaps stay whole for larger allocations. Gaps of the same size are taken
+// in the order of the pool CIDRs. Returns nil when none of them has room.
+func findBestFitCIDR(poolCIDRs []string, prefixLength int, alignment int, allocatedCIDRs []*net.IPNet) *net.IPNet {
+	var best *net.IPNet
+	bestPrefixLen := -1
+	for _, poolCIDRStr := range poolCIDRs {
+		_, poolNet, err := net.ParseCIDR(poolCIDRStr)
+		if err != nil {
+			continue
+		}
+
+		// the free blocks are as large as they can be, so a gap is a block of its own prefix length
+		for _, gap := range freeBlocks(poolNet, allocatedCIDRs) {
+			gapPrefixLen, _ := gap.Mask.Size()
+			if gapPrefixLen > prefixLength || gapPrefixLen <= bestPrefixLen {
+				continue
 			}
+			if candidateCIDR := findAvailableCIDR(gap, prefixLength, alignment, allocatedCIDRs); candidateCIDR != nil {
+				best, bestPrefixLen = candidateCIDR, gapPrefixLen
+			}
+		}
+	}
+
+	return best
+}
+
+// poolCIDRFinder returns the search for a free block that follows the pool's allocation strategy.
+func poolCIDRFinder(pool *storage.Pool) func(poolCIDRs []string, prefixLength int, alignment int, allocatedCIDRs []*net.IPNet) *net.IPNet {
+	if poolStrategy(pool) == poolStrategyBestFit {
+		return findBestFitCIDR
+	}
+	return findNextCIDR
+}
+
+// previousCIDRAvailable returns the previous CIDR when it's still a valid result of the search, a free
+// block of the prefix length and alignment inside one of the pool CIDRs. Otherwise it returns nil.
+func previousCIDRAvailable(previousCIDR string, poolCIDRs []string, prefixLength int, alignment int, allocatedCIDRs []*net.IPNet) *net.IPNet {
+	if previousCIDR == "" {
+		return nil
+	}
+	_, previousNet, err := net.ParseCIDR(previousCIDR)
+	if err != nil {
+		return nil
+	}
+
+	previousPrefixLen, bits := previousNet.Mask.Size()
+	if previousPrefixLen != prefixLength {
+		return nil
+	}
+	if alignment != 0 && !previousNet.IP.Equal(previousNet.IP.Mask(net.CIDRMask(alignment, bits))) {
+		return nil
+	}
+	if cidrsOverlap(previousNet, allocatedCIDRs) {
+		return nil
+	}
 
-			if err := r.provider.storage.SaveAllocation(ctx, allocation); err != nil {
-				return "", fmt.Errorf("failed to save allocation: %w", err)
+	for _, poolCIDRStr := range poolCIDRs {
+		_, poolNet, err := net.ParseCIDR(poolCIDRStr)
+		if err != nil {
+			continue
+		}
+		poolPrefixLen, poolBits := poolNet.Mask.Size()
+		if poolBits == bits && poolPrefixLen <= prefixLength && poolNet.Contains(previousNet.IP) {
+			return previousNet
+		}
+	}
+	return nil
+}
+
+// selectPoolCIDR returns the pool CIDR matching fromCIDR as the only CIDR to search.
+func selectPoolCIDR(pool *storage.Pool, fromCIDR string) ([]string, error) {
+	for _, poolCIDRStr := range pool.CIDRs {
+		_, poolNet, err := net.ParseCIDR(poolCIDRStr)
+		if err != nil {
+			continue
+		}
+		if poolNet.String() == fromCIDR {
+			return []string{poolCIDRStr}, nil
+		}
+	}
+	return nil, fmt.Errorf("from_cidr %s is not one of the CIDRs of pool %s: %s", fromCIDR, pool.Name, strings.Join(pool.CIDRs, ", "))
+}
+
+// claimRequestedCIDR saves the allocation's requested CIDR once it's confirmed to be inside the pool.
+// Overlapping other allocations is only accepted when the allocation explicitly allows it.
+func (r *AllocationResource) claimRequestedCIDR(ctx context.Context, pool *storage.Pool, allocation *storage.Allocation, allocations []storage.Allocation) error {
+	_, requestedNet, err := net.ParseCIDR(allocation.RequestedCIDR)
+	if err != nil {
+		return fmt.Errorf("invalid requested CIDR %s: %w", allocation.RequestedCIDR, err)
+	}
+
+	// reserved CIDRs are cut out of the pool, so this has to be checked before containment to say why
+	if reserved := poolReservedOverlap(pool, requestedNet); reserved != "" {
+		return fmt.Errorf("requested CIDR %s overlaps reserved range %s of pool %s", requestedNet, reserved, pool.Name)
+	}
+
+	if !cidrInPool(pool, requestedNet) {
+		return fmt.Errorf("requested CIDR %s is not contained in any CIDR of pool %s", requestedNet, pool.Name)
+	}
+
+	// allow_overlap only covers other allocations, never the globally reserved CIDRs
+	if err := r.provider.checkGloballyReserved(requestedNet); err != nil {
+		return err
+	}
+
+	var overlapping []string
+	for _, alloc := range allocations {
+		for _, cidr := range alloc.CIDRs() {
+			_, allocNet, err := net.ParseCIDR(cidr)
+			if err != nil {
+				continue
+			}
+			if cidrsOverlap(requestedNet, []*net.IPNet{allocNet}) {
+				overlapping = append(overlapping, fmt.Sprintf("%s (%s)", cidr, alloc.ID))
 			}
+		}
+	}
+	if len(overlapping) > 0 && !allocation.AllowOverlap {
+		return fmt.Errorf("requested CIDR %s overlaps existing allocations in pool %s: %s. Set allow_overlap to allocate it anyway",
+			requestedNet, pool.Name, strings.Join(overlapping, ", "))
+	}
 
-			return allocatedCIDR, nil
+	allocation.AllocatedCIDR = requestedNet.String()
+	if err := r.saveClaimedAllocation(ctx, allocation, allocations); err != nil {
+		allocation.AllocatedCIDR = ""
+		return fmt.Errorf("failed to save allocation: %w", err)
+	}
+
+	if len(overlapping) > 0 {
+		tflog.Warn(ctx, "created allocation that overlaps existing allocations because allow_overlap is set", map[string]any{
+			"id":             allocation.ID,
+			"pool_name":      pool.Name,
+			"allocated_cidr": allocation.AllocatedCIDR,
+			"overlaps":       overlapping,
+		})
+	}
+
+	return nil
+}
+
+// poolReservedOverlap returns the first of the pool's reserved CIDRs that overlaps the CIDR, or "" when
+// none does.
+func poolReservedOverlap(pool *storage.Pool, cidrNet *net.IPNet) string {
+	for _, reserved := range pool.ReservedCIDRs {
+		_, reservedNet, err := net.ParseCIDR(reserved)
+		if err != nil {
+			continue
+		}
+		if cidrsOverlap(cidrNet, []*net.IPNet{reservedNet}) {
+			return reserved
+		}
+	}
+	return ""
+}
+
+// cidrInPool reports whether the whole CIDR fits inside one of the pool's CIDRs.
+func cidrInPool(pool *storage.Pool, cidrNet *net.IPNet) bool {
+	for _, poolCIDRStr := range poolCIDRs(pool) {
+		_, poolNet, err := net.ParseCIDR(poolCIDRStr)
+		if err != nil {
+			continue
+		}
+		if poolNet.Contains(cidrNet.IP) && poolNet.Contains(getLastIPInCIDR(cidrNet)) {
+			return true
 		}
 	}
+	return false
+}
 
-	return "", fmt.Errorf("no available CIDR blocks of size /%d in pool %s", prefixLength, poolName)
+// allocationOutsidePool returns the CIDRs of an allocation that aren't inside any of its pool's CIDRs.
+func allocationOutsidePool(pool *storage.Pool, allocation *storage.Allocation) []string {
+	var outside []string
+	for _, cidr := range allocation.CIDRs() {
+		_, cidrNet, err := net.ParseCIDR(cidr)
+		if err != nil || !cidrInPool(pool, cidrNet) {
+			outside = append(outside, cidr)
+		}
+	}
+	return outside
 }
 
 // findAvailableCIDR searches for an available CIDR block of the requested prefix length
 // within the pool CIDR such that it doesn't overlap with any existing allocations.
-func findAvailableCIDR(poolNet *net.IPNet, prefixLength int, allocatedCIDRs []*net.IPNet) *net.IPNet {
+// A non-zero alignment skips any block whose network address isn't on a boundary of that prefix length.
+func findAvailableCIDR(poolNet *net.IPNet, prefixLength int, alignment int, allocatedCIDRs []*net.IPNet) *net.IPNet {
 	poolPrefixLen, bits := poolNet.Mask.Size()
 
 	// Calculate number of blocks of the requested size that can fit in the pool
@@ -303,6 +2001,7 @@ func findAvailableCIDR(poolNet *net.IPNet, prefixLength int, allocatedCIDRs []*n
 	}
 
 	requestedMask := net.CIDRMask(prefixLength, bits)
+	alignmentMask := net.CIDRMask(alignment, bits)
 
 	// Iterate through all possible CIDR blocks of the requested size within the pool
 	// and check if they overlap with existing allocations
@@ -325,6 +2024,11 @@ func findAvailableCIDR(poolNet *net.IPNet, prefixLength int, allocatedCIDRs []*n
 			continue
 		}
 
+		// skip blocks that don't start on the alignment boundary
+		if alignment != 0 && !candidateNet.IP.Mask(alignmentMask).Equal(candidateNet.IP) {
+			continue
+		}
+
 		// check for overlaps with existing allocations
 		if !cidrsOverlap(candidateNet, allocatedCIDRs) {
 			return candidateNet
@@ -1,14 +1,25 @@
 package provider
 
 import (
+	"context"
 	"fmt"
+	"math/rand"
+	"net"
+	"path/filepath"
 	"regexp"
+	"sync"
 	"testing"
+	"time"
 
+	"github.com/hashicorp/terraform-plugin-testing/compare"
 	"github.com/hashicorp/terraform-plugin-testing/helper/resource"
 	"github.com/hashicorp/terraform-plugin-testing/knownvalue"
+	"github.com/hashicorp/terraform-plugin-testing/plancheck"
 	"github.com/hashicorp/terraform-plugin-testing/statecheck"
+	"github.com/hashicorp/terraform-plugin-testing/terraform"
 	"github.com/hashicorp/terraform-plugin-testing/tfjsonpath"
+
+	"terraform-provider-tfipam/internal/provider/storage"
 )
 
 func TestAccAllocationResource_Basic(t *testing.T) {
@@ -54,6 +65,64 @@ func TestAccAllocationResource_Basic(t *testing.T) {
 	})
 }
 
+func TestAccAllocationResource_AddressDetails(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		PreCheck:                 func() { testAccPreCheck(t) },
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: testAccAllocationResourceConfig("details-pool", "details-alloc", 24),
+				ConfigStateChecks: []statecheck.StateCheck{
+					statecheck.ExpectKnownValue(
+						"tfipam_allocation.test",
+						tfjsonpath.New("network_address"),
+						knownvalue.StringExact("10.0.0.0"),
+					),
+					statecheck.ExpectKnownValue(
+						"tfipam_allocation.test",
+						tfjsonpath.New("broadcast_address"),
+						knownvalue.StringExact("10.0.0.255"),
+					),
+					statecheck.ExpectKnownValue(
+						"tfipam_allocation.test",
+						tfjsonpath.New("address_count"),
+						knownvalue.StringExact("256"),
+					),
+				},
+			},
+		},
+	})
+}
+
+func TestAccAllocationResource_AddressDetails_IPv6(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		PreCheck:                 func() { testAccPreCheck(t) },
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: testAccAllocationResourceConfigIPv6("details-ipv6-pool", "details-ipv6-alloc", 64),
+				ConfigStateChecks: []statecheck.StateCheck{
+					statecheck.ExpectKnownValue(
+						"tfipam_allocation.test",
+						tfjsonpath.New("network_address"),
+						knownvalue.StringExact("2001:db8::"),
+					),
+					statecheck.ExpectKnownValue(
+						"tfipam_allocation.test",
+						tfjsonpath.New("broadcast_address"),
+						knownvalue.Null(),
+					),
+					statecheck.ExpectKnownValue(
+						"tfipam_allocation.test",
+						tfjsonpath.New("address_count"),
+						knownvalue.StringExact("18446744073709551616"),
+					),
+				},
+			},
+		},
+	})
+}
+
 func TestAccAllocationResource_MultipleAllocations(t *testing.T) {
 	resource.Test(t, resource.TestCase{
 		PreCheck:                 func() { testAccPreCheck(t) },
@@ -184,56 +253,55 @@ func TestAccAllocationResource_InvalidPrefixLength_TooLarge(t *testing.T) {
 	})
 }
 
-func TestAccAllocationResource_PrefixLargerThanPool(t *testing.T) {
+func TestAccAllocationResource_InvalidPrefixLength_IPv4Pool(t *testing.T) {
 	resource.Test(t, resource.TestCase{
 		PreCheck:                 func() { testAccPreCheck(t) },
 		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
 		Steps: []resource.TestStep{
 			{
-				Config:      testAccAllocationResourceConfigSmallPool("small-pool", "too-large", 16),
-				ExpectError: regexp.MustCompile("no available CIDR blocks|Allocation Failed"),
+				Config:      testAccAllocationResourceConfig("ipv4-pool", "ipv6-sized-alloc", 40),
+				ExpectError: regexp.MustCompile("not valid for IPv4 pool"),
 			},
 		},
 	})
 }
 
-func TestAccAllocationResource_PoolNotFound(t *testing.T) {
+func TestAccAllocationResource_PrefixLargerThanPool(t *testing.T) {
 	resource.Test(t, resource.TestCase{
 		PreCheck:                 func() { testAccPreCheck(t) },
 		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
 		Steps: []resource.TestStep{
 			{
-				Config:      testAccAllocationResourceConfigNoPool("nonexistent-pool", "test-alloc", 24),
-				ExpectError: regexp.MustCompile("pool.*not found|Allocation Failed"),
+				Config:      testAccAllocationResourceConfigSmallPool("small-pool", "too-large", 16),
+				ExpectError: regexp.MustCompile("no available CIDR blocks|Allocation Failed"),
 			},
 		},
 	})
 }
 
-func TestAccAllocationResource_IDChange(t *testing.T) {
+func TestAccAllocationResource_PoolPrefixLimits(t *testing.T) {
 	resource.Test(t, resource.TestCase{
 		PreCheck:                 func() { testAccPreCheck(t) },
 		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
 		Steps: []resource.TestStep{
-			// Create with original ID
+			// Shorter than the pool minimum
 			{
-				Config: testAccAllocationResourceConfig("id-pool", "original-id", 24),
-				ConfigStateChecks: []statecheck.StateCheck{
-					statecheck.ExpectKnownValue(
-						"tfipam_allocation.test",
-						tfjsonpath.New("id"),
-						knownvalue.StringExact("original-id"),
-					),
-				},
+				Config:      testAccAllocationResourceConfigPrefixLimits("limits-pool", 24, 28, 20),
+				ExpectError: regexp.MustCompile("shorter than the minimum /24 allowed"),
 			},
-			// Change ID (should trigger replacement)
+			// Longer than the pool maximum
 			{
-				Config: testAccAllocationResourceConfig("id-pool", "new-id", 24),
+				Config:      testAccAllocationResourceConfigPrefixLimits("limits-pool", 24, 28, 30),
+				ExpectError: regexp.MustCompile("longer than the maximum /28 allowed"),
+			},
+			// Within the allowed range
+			{
+				Config: testAccAllocationResourceConfigPrefixLimits("limits-pool", 24, 28, 26),
 				ConfigStateChecks: []statecheck.StateCheck{
 					statecheck.ExpectKnownValue(
 						"tfipam_allocation.test",
-						tfjsonpath.New("id"),
-						knownvalue.StringExact("new-id"),
+						tfjsonpath.New("allocated_cidr"),
+						knownvalue.StringExact("10.0.0.0/26"),
 					),
 				},
 			},
@@ -241,30 +309,45 @@ func TestAccAllocationResource_IDChange(t *testing.T) {
 	})
 }
 
-func TestAccAllocationResource_PoolChange(t *testing.T) {
+func TestAccAllocationResource_DefaultPrefixLength(t *testing.T) {
 	resource.Test(t, resource.TestCase{
 		PreCheck:                 func() { testAccPreCheck(t) },
 		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
 		Steps: []resource.TestStep{
-			// Create with original pool
+			// The allocation without a prefix_length inherits the pool's /26, the other keeps its own /28
 			{
-				Config: testAccAllocationResourceConfigTwoPools("pool-1", "pool-2", "test-alloc", 24, "pool-1"),
+				Config: testAccAllocationResourceConfigDefaultPrefixLength("default-prefix-pool", 26),
 				ConfigStateChecks: []statecheck.StateCheck{
 					statecheck.ExpectKnownValue(
-						"tfipam_allocation.test",
-						tfjsonpath.New("pool_name"),
-						knownvalue.StringExact("pool-1"),
+						"tfipam_allocation.inherited",
+						tfjsonpath.New("prefix_length"),
+						knownvalue.Int64Exact(26),
+					),
+					statecheck.ExpectKnownValue(
+						"tfipam_allocation.inherited",
+						tfjsonpath.New("allocated_cidr"),
+						knownvalue.StringExact("10.0.0.0/26"),
+					),
+					statecheck.ExpectKnownValue(
+						"tfipam_allocation.explicit",
+						tfjsonpath.New("allocated_cidr"),
+						knownvalue.StringExact("10.0.0.64/28"),
 					),
 				},
 			},
-			// Change pool (should trigger replacement)
+			// Changing the pool default leaves the existing allocation alone
 			{
-				Config: testAccAllocationResourceConfigTwoPools("pool-1", "pool-2", "test-alloc", 24, "pool-2"),
+				Config: testAccAllocationResourceConfigDefaultPrefixLength("default-prefix-pool", 25),
+				ConfigPlanChecks: resource.ConfigPlanChecks{
+					PreApply: []plancheck.PlanCheck{
+						plancheck.ExpectResourceAction("tfipam_allocation.inherited", plancheck.ResourceActionNoop),
+					},
+				},
 				ConfigStateChecks: []statecheck.StateCheck{
 					statecheck.ExpectKnownValue(
-						"tfipam_allocation.test",
-						tfjsonpath.New("pool_name"),
-						knownvalue.StringExact("pool-2"),
+						"tfipam_allocation.inherited",
+						tfjsonpath.New("allocated_cidr"),
+						knownvalue.StringExact("10.0.0.0/26"),
 					),
 				},
 			},
@@ -272,108 +355,211 @@ func TestAccAllocationResource_PoolChange(t *testing.T) {
 	})
 }
 
-func TestAccAllocationResource_PrefixLengthChange(t *testing.T) {
+func TestAccAllocationResource_NoDefaultPrefixLength(t *testing.T) {
 	resource.Test(t, resource.TestCase{
 		PreCheck:                 func() { testAccPreCheck(t) },
 		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
 		Steps: []resource.TestStep{
-			// Create with /24
-			{
-				Config: testAccAllocationResourceConfig("prefix-pool", "prefix-alloc", 24),
-				ConfigStateChecks: []statecheck.StateCheck{
-					statecheck.ExpectKnownValue(
-						"tfipam_allocation.test",
-						tfjsonpath.New("prefix_length"),
-						knownvalue.Int64Exact(24),
-					),
-				},
-			},
-			// Change to /27 (should trigger replacement)
 			{
-				Config: testAccAllocationResourceConfig("prefix-pool", "prefix-alloc", 27),
-				ConfigStateChecks: []statecheck.StateCheck{
-					statecheck.ExpectKnownValue(
-						"tfipam_allocation.test",
-						tfjsonpath.New("prefix_length"),
-						knownvalue.Int64Exact(27),
-					),
-				},
+				Config: `
+resource "tfipam_pool" "test" {
+  name  = "no-default-prefix-pool"
+  cidrs = ["10.0.0.0/16"]
+}
+
+resource "tfipam_allocation" "test" {
+  id        = "no-default-prefix-alloc"
+  pool_name = tfipam_pool.test.name
+}
+`,
+				ExpectError: regexp.MustCompile("has no default_prefix_length"),
 			},
 		},
 	})
 }
 
-func TestAccAllocationResource_Import(t *testing.T) {
+func TestAccAllocationResource_PoolFailover(t *testing.T) {
 	resource.Test(t, resource.TestCase{
 		PreCheck:                 func() { testAccPreCheck(t) },
 		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
 		Steps: []resource.TestStep{
-			// Create allocation
+			// The primary pool only holds one /24, so the second allocation overflows into the secondary pool
 			{
-				Config: testAccAllocationResourceConfig("import-pool", "import-alloc", 24),
+				Config: testAccAllocationResourceConfigPoolFailover(`[tfipam_pool.primary.name, tfipam_pool.secondary.name]`),
+				ConfigStateChecks: []statecheck.StateCheck{
+					statecheck.ExpectKnownValue(
+						"tfipam_allocation.first",
+						tfjsonpath.New("pool_name"),
+						knownvalue.StringExact("failover-primary"),
+					),
+					statecheck.ExpectKnownValue(
+						"tfipam_allocation.second",
+						tfjsonpath.New("pool_name"),
+						knownvalue.StringExact("failover-secondary"),
+					),
+					statecheck.ExpectKnownValue(
+						"tfipam_allocation.second",
+						tfjsonpath.New("allocated_cidr"),
+						knownvalue.StringExact("10.1.0.0/24"),
+					),
+				},
 			},
-			// Import by ID
+			// Adding a pool to the list keeps the allocation, which is still in one of the pools
 			{
-				ResourceName:      "tfipam_allocation.test",
-				ImportState:       true,
-				ImportStateVerify: true,
-				ImportStateId:     "import-alloc",
+				Config: testAccAllocationResourceConfigPoolFailover(`[tfipam_pool.primary.name, tfipam_pool.secondary.name, tfipam_pool.tertiary.name]`),
+				ConfigPlanChecks: resource.ConfigPlanChecks{
+					PreApply: []plancheck.PlanCheck{
+						plancheck.ExpectResourceAction("tfipam_allocation.second", plancheck.ResourceActionUpdate),
+					},
+				},
+			},
+			// Setting both pool_name and pool_names is rejected
+			{
+				Config: `
+resource "tfipam_allocation" "both" {
+  pool_name     = "failover-primary"
+  pool_names    = ["failover-primary"]
+  prefix_length = 24
+}
+`,
+				ExpectError: regexp.MustCompile("Exactly one of pool_name or pool_names must be set"),
 			},
 		},
 	})
 }
 
-func TestAccAllocationResource_IPv6(t *testing.T) {
+func testAccAllocationResourceConfigPoolFailover(poolNames string) string {
+	return fmt.Sprintf(`
+resource "tfipam_pool" "primary" {
+  name  = "failover-primary"
+  cidrs = ["10.0.0.0/24"]
+}
+
+resource "tfipam_pool" "secondary" {
+  name  = "failover-secondary"
+  cidrs = ["10.1.0.0/16"]
+}
+
+resource "tfipam_pool" "tertiary" {
+  name  = "failover-tertiary"
+  cidrs = ["10.2.0.0/16"]
+}
+
+resource "tfipam_allocation" "first" {
+  id            = "failover-first"
+  pool_name     = tfipam_pool.primary.name
+  prefix_length = 24
+}
+
+resource "tfipam_allocation" "second" {
+  id            = "failover-second"
+  pool_names    = %s
+  prefix_length = 24
+
+  depends_on = [tfipam_allocation.first]
+}
+`, poolNames)
+}
+
+func TestAccAllocationResource_PreferredCIDR(t *testing.T) {
 	resource.Test(t, resource.TestCase{
 		PreCheck:                 func() { testAccPreCheck(t) },
 		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
 		Steps: []resource.TestStep{
+			// A free preferred CIDR is allocated and sets the prefix length, a taken one falls back to the next free block
 			{
-				Config: testAccAllocationResourceConfigIPv6("ipv6-pool", "ipv6-alloc", 64),
+				Config: `
+resource "tfipam_pool" "test" {
+  name  = "preferred-pool"
+  cidrs = ["10.0.0.0/16"]
+}
+
+resource "tfipam_allocation" "honored" {
+  id             = "preferred-honored"
+  pool_name      = tfipam_pool.test.name
+  preferred_cidr = "10.0.5.0/24"
+}
+
+resource "tfipam_allocation" "fallback" {
+  id             = "preferred-fallback"
+  pool_name      = tfipam_pool.test.name
+  prefix_length  = 24
+  preferred_cidr = "10.0.5.0/24"
+
+  depends_on = [tfipam_allocation.honored]
+}
+`,
 				ConfigStateChecks: []statecheck.StateCheck{
 					statecheck.ExpectKnownValue(
-						"tfipam_allocation.test",
-						tfjsonpath.New("id"),
-						knownvalue.StringExact("ipv6-alloc"),
+						"tfipam_allocation.honored",
+						tfjsonpath.New("allocated_cidr"),
+						knownvalue.StringExact("10.0.5.0/24"),
 					),
 					statecheck.ExpectKnownValue(
-						"tfipam_allocation.test",
+						"tfipam_allocation.honored",
 						tfjsonpath.New("prefix_length"),
-						knownvalue.Int64Exact(64),
+						knownvalue.Int64Exact(24),
 					),
 					statecheck.ExpectKnownValue(
-						"tfipam_allocation.test",
+						"tfipam_allocation.honored",
+						tfjsonpath.New("preferred_cidr_honored"),
+						knownvalue.Bool(true),
+					),
+					statecheck.ExpectKnownValue(
+						"tfipam_allocation.fallback",
 						tfjsonpath.New("allocated_cidr"),
-						knownvalue.NotNull(),
+						knownvalue.StringExact("10.0.0.0/24"),
+					),
+					statecheck.ExpectKnownValue(
+						"tfipam_allocation.fallback",
+						tfjsonpath.New("preferred_cidr_honored"),
+						knownvalue.Bool(false),
 					),
 				},
 			},
+			{
+				Config: `
+resource "tfipam_pool" "test" {
+  name  = "preferred-pool"
+  cidrs = ["10.0.0.0/16"]
+}
+
+resource "tfipam_allocation" "conflict" {
+  id             = "preferred-conflict"
+  pool_name      = tfipam_pool.test.name
+  prefix_length  = 28
+  preferred_cidr = "10.0.5.0/24"
+}
+`,
+				ExpectError: regexp.MustCompile("prefix_length /28 doesn't match preferred_cidr 10.0.5.0/24"),
+			},
 		},
 	})
 }
 
-func TestAccAllocationResource_IPv6_MultipleSubnets(t *testing.T) {
+func TestAccAllocationResource_Alignment(t *testing.T) {
 	resource.Test(t, resource.TestCase{
 		PreCheck:                 func() { testAccPreCheck(t) },
 		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
 		Steps: []resource.TestStep{
+			// The aligned /28 skips past the partially used 10.0.0.0/24 to the next /24 boundary
 			{
-				Config: testAccAllocationResourceConfigIPv6Multiple("ipv6-multi-pool"),
+				Config: testAccAllocationResourceConfigAlignment("alignment-pool", 24),
 				ConfigStateChecks: []statecheck.StateCheck{
 					statecheck.ExpectKnownValue(
-						"tfipam_allocation.test_48",
-						tfjsonpath.New("prefix_length"),
-						knownvalue.Int64Exact(48),
+						"tfipam_allocation.unaligned",
+						tfjsonpath.New("allocated_cidr"),
+						knownvalue.StringExact("10.0.0.0/28"),
 					),
 					statecheck.ExpectKnownValue(
-						"tfipam_allocation.test_56",
-						tfjsonpath.New("prefix_length"),
-						knownvalue.Int64Exact(56),
+						"tfipam_allocation.aligned",
+						tfjsonpath.New("allocated_cidr"),
+						knownvalue.StringExact("10.0.1.0/28"),
 					),
 					statecheck.ExpectKnownValue(
-						"tfipam_allocation.test_64",
-						tfjsonpath.New("prefix_length"),
-						knownvalue.Int64Exact(64),
+						"tfipam_allocation.aligned",
+						tfjsonpath.New("alignment"),
+						knownvalue.Int64Exact(24),
 					),
 				},
 			},
@@ -381,60 +567,1692 @@ func TestAccAllocationResource_IPv6_MultipleSubnets(t *testing.T) {
 	})
 }
 
-func TestAccAllocationResource_SequentialAllocations(t *testing.T) {
+func TestAccAllocationResource_InvalidAlignment(t *testing.T) {
 	resource.Test(t, resource.TestCase{
 		PreCheck:                 func() { testAccPreCheck(t) },
 		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
 		Steps: []resource.TestStep{
-			// Start with one allocation
 			{
-				Config: testAccAllocationResourceConfigSequential("seq-pool", 1, 27),
+				Config:      testAccAllocationResourceConfigAlignment("invalid-alignment-pool", 30),
+				ExpectError: regexp.MustCompile("Invalid Alignment"),
+			},
+		},
+	})
+}
+
+func TestAccAllocationResource_RequestedCIDR(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		PreCheck:                 func() { testAccPreCheck(t) },
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			// prefix_length is taken from the requested CIDR
+			{
+				Config: testAccAllocationResourceConfigRequested("requested-pool", "10.0.5.0/24", false),
 				ConfigStateChecks: []statecheck.StateCheck{
 					statecheck.ExpectKnownValue(
-						"tfipam_allocation.test_0",
-						tfjsonpath.New("id"),
-						knownvalue.StringExact("seq-alloc-0"),
+						"tfipam_allocation.requested",
+						tfjsonpath.New("allocated_cidr"),
+						knownvalue.StringExact("10.0.5.0/24"),
+					),
+					statecheck.ExpectKnownValue(
+						"tfipam_allocation.requested",
+						tfjsonpath.New("prefix_length"),
+						knownvalue.Int64Exact(24),
 					),
 				},
 			},
-			// Add second allocation
 			{
-				Config: testAccAllocationResourceConfigSequential("seq-pool", 2, 27),
+				ResourceName:      "tfipam_allocation.requested",
+				ImportState:       true,
+				ImportStateVerify: true,
+				ImportStateId:     "requested-alloc",
+			},
+		},
+	})
+}
+
+func TestAccAllocationResource_RequestedCIDR_HostBits(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		PreCheck:                 func() { testAccPreCheck(t) },
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			// An address inside the block allocates its network, and state keeps the configured form
+			{
+				Config: testAccAllocationResourceConfigRequested("requested-host-pool", "10.0.5.3/24", false),
 				ConfigStateChecks: []statecheck.StateCheck{
 					statecheck.ExpectKnownValue(
-						"tfipam_allocation.test_0",
-						tfjsonpath.New("id"),
-						knownvalue.StringExact("seq-alloc-0"),
+						"tfipam_allocation.requested",
+						tfjsonpath.New("allocated_cidr"),
+						knownvalue.StringExact("10.0.5.0/24"),
 					),
 					statecheck.ExpectKnownValue(
-						"tfipam_allocation.test_1",
-						tfjsonpath.New("id"),
-						knownvalue.StringExact("seq-alloc-1"),
+						"tfipam_allocation.requested",
+						tfjsonpath.New("requested_cidr"),
+						knownvalue.StringExact("10.0.5.3/24"),
 					),
 				},
 			},
-			// Add third allocation
+			// Refreshing doesn't swap in the stored network, so the plan stays empty
 			{
-				Config: testAccAllocationResourceConfigSequential("seq-pool", 3, 27),
-				ConfigStateChecks: []statecheck.StateCheck{
-					statecheck.ExpectKnownValue(
-						"tfipam_allocation.test_2",
-						tfjsonpath.New("id"),
-						knownvalue.StringExact("seq-alloc-2"),
-					),
+				Config: testAccAllocationResourceConfigRequested("requested-host-pool", "10.0.5.3/24", false),
+				ConfigPlanChecks: resource.ConfigPlanChecks{
+					PreApply: []plancheck.PlanCheck{
+						plancheck.ExpectEmptyPlan(),
+					},
 				},
 			},
 		},
 	})
 }
 
-// testAccAllocationResourceConfig generates a Terraform configuration for an allocation resource.
-func testAccAllocationResourceConfig(poolName, allocID string, prefixLength int) string {
-	return fmt.Sprintf(`
-resource "tfipam_pool" "test" {
-  name = %[1]q
-  cidrs = ["10.0.0.0/16"]
-}
+func TestAccAllocationResource_RequestedCIDR_Overlap(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		PreCheck:                 func() { testAccPreCheck(t) },
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config:      testAccAllocationResourceConfigRequested("requested-overlap-pool", "10.0.0.0/25", false),
+				ExpectError: regexp.MustCompile("overlaps existing allocations"),
+			},
+		},
+	})
+}
+
+func TestAccAllocationResource_TTL(t *testing.T) {
+	config := func(ttl string) string {
+		return fmt.Sprintf(`
+resource "tfipam_pool" "test" {
+  name  = "ttl-pool"
+  cidrs = ["10.0.0.0/24"]
+}
+
+resource "tfipam_allocation" "test" {
+  id            = "ttl-alloc"
+  pool_name     = tfipam_pool.test.name
+  prefix_length = 28
+  %s
+}
+`, ttl)
+	}
+
+	resource.Test(t, resource.TestCase{
+		PreCheck:                 func() { testAccPreCheck(t) },
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: config("ttl_seconds = 3600"),
+				ConfigStateChecks: []statecheck.StateCheck{
+					statecheck.ExpectKnownValue(
+						"tfipam_allocation.test",
+						tfjsonpath.New("expires_at"),
+						knownvalue.StringRegexp(regexp.MustCompile(`^\d{4}-\d{2}-\d{2}T\d{2}:\d{2}:\d{2}Z$`)),
+					),
+				},
+			},
+			// removing the TTL clears the expiry in place, the allocation keeps its CIDR
+			{
+				Config: config(""),
+				ConfigPlanChecks: resource.ConfigPlanChecks{
+					PreApply: []plancheck.PlanCheck{
+						plancheck.ExpectResourceAction("tfipam_allocation.test", plancheck.ResourceActionUpdate),
+					},
+				},
+				ConfigStateChecks: []statecheck.StateCheck{
+					statecheck.ExpectKnownValue(
+						"tfipam_allocation.test",
+						tfjsonpath.New("expires_at"),
+						knownvalue.Null(),
+					),
+					statecheck.ExpectKnownValue(
+						"tfipam_allocation.test",
+						tfjsonpath.New("allocated_cidr"),
+						knownvalue.StringExact("10.0.0.0/28"),
+					),
+				},
+			},
+		},
+	})
+}
+
+func TestAccAllocationResource_RenameInPlace(t *testing.T) {
+	config := func(id string) string {
+		return fmt.Sprintf(`
+resource "tfipam_pool" "test" {
+  name  = "rename-pool"
+  cidrs = ["10.0.0.0/24"]
+}
+
+resource "tfipam_allocation" "test" {
+  id              = %q
+  pool_name       = tfipam_pool.test.name
+  prefix_length   = 28
+  rename_in_place = true
+}
+`, id)
+	}
+	// the allocation is stored under the same key before and after the rename
+	keyUnchanged := statecheck.CompareValue(compare.ValuesSame())
+
+	resource.Test(t, resource.TestCase{
+		PreCheck:                 func() { testAccPreCheck(t) },
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: config("rename-old"),
+				ConfigStateChecks: []statecheck.StateCheck{
+					statecheck.ExpectKnownValue(
+						"tfipam_allocation.test",
+						tfjsonpath.New("allocated_cidr"),
+						knownvalue.StringExact("10.0.0.0/28"),
+					),
+					keyUnchanged.AddStateValue("tfipam_allocation.test", tfjsonpath.New("key")),
+				},
+			},
+			// the new id is an in place update that keeps the CIDR
+			{
+				Config: config("rename-new"),
+				ConfigPlanChecks: resource.ConfigPlanChecks{
+					PreApply: []plancheck.PlanCheck{
+						plancheck.ExpectResourceAction("tfipam_allocation.test", plancheck.ResourceActionUpdate),
+					},
+				},
+				ConfigStateChecks: []statecheck.StateCheck{
+					statecheck.ExpectKnownValue(
+						"tfipam_allocation.test",
+						tfjsonpath.New("id"),
+						knownvalue.StringExact("rename-new"),
+					),
+					statecheck.ExpectKnownValue(
+						"tfipam_allocation.test",
+						tfjsonpath.New("allocated_cidr"),
+						knownvalue.StringExact("10.0.0.0/28"),
+					),
+					keyUnchanged.AddStateValue("tfipam_allocation.test", tfjsonpath.New("key")),
+				},
+			},
+			{
+				ResourceName:      "tfipam_allocation.test",
+				ImportState:       true,
+				ImportStateVerify: true,
+				ImportStateId:     "rename-new",
+				// a provider setting of the resource, imports always start with the default
+				ImportStateVerifyIgnore: []string{"rename_in_place"},
+			},
+		},
+	})
+}
+
+func TestAccAllocationResource_Sticky(t *testing.T) {
+	// the first block is taken while the sticky allocation is created, so it gets the second
+	withFirst := `
+resource "tfipam_allocation" "first" {
+  id             = "sticky-first"
+  pool_name      = tfipam_pool.test.name
+  requested_cidr = "10.4.0.0/26"
+}
+`
+	config := func(first string, dependsOn string) string {
+		return fmt.Sprintf(`
+resource "tfipam_pool" "test" {
+  name  = "sticky-pool"
+  cidrs = ["10.4.0.0/24"]
+}
+%s
+resource "tfipam_allocation" "test" {
+  id            = "sticky-alloc"
+  pool_name     = tfipam_pool.test.name
+  prefix_length = 26
+  sticky        = true
+
+  depends_on = [%s]
+}
+`, first, dependsOn)
+	}
+
+	resource.Test(t, resource.TestCase{
+		PreCheck:                 func() { testAccPreCheck(t) },
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: config(withFirst, "tfipam_allocation.first"),
+				ConfigStateChecks: []statecheck.StateCheck{
+					statecheck.ExpectKnownValue(
+						"tfipam_allocation.test",
+						tfjsonpath.New("allocated_cidr"),
+						knownvalue.StringExact("10.4.0.64/26"),
+					),
+				},
+			},
+			// removing the first allocation frees 10.4.0.0/26
+			{
+				Config: config("", ""),
+			},
+			// the first block is free now, but the replaced allocation gets its own block back
+			{
+				Config: config("", ""),
+				Taint:  []string{"tfipam_allocation.test"},
+				ConfigStateChecks: []statecheck.StateCheck{
+					statecheck.ExpectKnownValue(
+						"tfipam_allocation.test",
+						tfjsonpath.New("allocated_cidr"),
+						knownvalue.StringExact("10.4.0.64/26"),
+					),
+				},
+			},
+		},
+	})
+}
+
+func TestAccAllocationResource_Owner(t *testing.T) {
+	config := func(owner string) string {
+		return fmt.Sprintf(`
+resource "tfipam_pool" "test" {
+  name  = "owner-pool"
+  cidrs = ["10.0.0.0/24"]
+}
+
+resource "tfipam_allocation" "test" {
+  id            = "owner-alloc"
+  pool_name     = tfipam_pool.test.name
+  prefix_length = 28
+  owner         = %q
+}
+
+data "tfipam_allocation" "test" {
+  id        = tfipam_allocation.test.id
+  pool_name = tfipam_allocation.test.pool_name
+}
+
+data "tfipam_pool_stats" "test" {
+  pool_name = tfipam_allocation.test.pool_name
+}
+`, owner)
+	}
+
+	resource.Test(t, resource.TestCase{
+		PreCheck:                 func() { testAccPreCheck(t) },
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: config("team-a"),
+				ConfigStateChecks: []statecheck.StateCheck{
+					statecheck.ExpectKnownValue(
+						"data.tfipam_allocation.test",
+						tfjsonpath.New("owner"),
+						knownvalue.StringExact("team-a"),
+					),
+					statecheck.ExpectKnownValue(
+						"data.tfipam_pool_stats.test",
+						tfjsonpath.New("used_addresses_by_owner"),
+						knownvalue.MapExact(map[string]knownvalue.Check{
+							"team-a": knownvalue.StringExact("16"),
+						}),
+					),
+				},
+			},
+			// a new owner is an in place update that keeps the CIDR
+			{
+				Config: config("team-b"),
+				ConfigPlanChecks: resource.ConfigPlanChecks{
+					PreApply: []plancheck.PlanCheck{
+						plancheck.ExpectResourceAction("tfipam_allocation.test", plancheck.ResourceActionUpdate),
+					},
+				},
+				ConfigStateChecks: []statecheck.StateCheck{
+					statecheck.ExpectKnownValue(
+						"tfipam_allocation.test",
+						tfjsonpath.New("allocated_cidr"),
+						knownvalue.StringExact("10.0.0.0/28"),
+					),
+					statecheck.ExpectKnownValue(
+						"data.tfipam_allocation.test",
+						tfjsonpath.New("owner"),
+						knownvalue.StringExact("team-b"),
+					),
+				},
+			},
+			{
+				ResourceName:      "tfipam_allocation.test",
+				ImportState:       true,
+				ImportStateVerify: true,
+				ImportStateId:     "owner-alloc",
+			},
+		},
+	})
+}
+
+func TestAccAllocationResource_Metadata(t *testing.T) {
+	config := func(metadata string) string {
+		return fmt.Sprintf(`
+resource "tfipam_pool" "test" {
+  name  = "metadata-pool"
+  cidrs = ["10.0.0.0/24"]
+}
+
+resource "tfipam_allocation" "test" {
+  id            = "metadata-alloc"
+  pool_name     = tfipam_pool.test.name
+  prefix_length = 28
+  metadata      = %s
+}
+
+data "tfipam_allocation" "test" {
+  id        = tfipam_allocation.test.id
+  pool_name = tfipam_allocation.test.pool_name
+}
+`, metadata)
+	}
+
+	resource.Test(t, resource.TestCase{
+		PreCheck:                 func() { testAccPreCheck(t) },
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: config(`{ cmdb_id = "CI0012345", provisioned_by = jsonencode({ pipeline = "network", run = 42 }) }`),
+				ConfigStateChecks: []statecheck.StateCheck{
+					statecheck.ExpectKnownValue(
+						"data.tfipam_allocation.test",
+						tfjsonpath.New("metadata"),
+						knownvalue.MapExact(map[string]knownvalue.Check{
+							"cmdb_id":        knownvalue.StringExact("CI0012345"),
+							"provisioned_by": knownvalue.StringExact(`{"pipeline":"network","run":42}`),
+						}),
+					),
+				},
+			},
+			// changed metadata is an in place update that keeps the CIDR
+			{
+				Config: config(`{ cmdb_id = "CI0067890" }`),
+				ConfigPlanChecks: resource.ConfigPlanChecks{
+					PreApply: []plancheck.PlanCheck{
+						plancheck.ExpectResourceAction("tfipam_allocation.test", plancheck.ResourceActionUpdate),
+					},
+				},
+				ConfigStateChecks: []statecheck.StateCheck{
+					statecheck.ExpectKnownValue(
+						"tfipam_allocation.test",
+						tfjsonpath.New("allocated_cidr"),
+						knownvalue.StringExact("10.0.0.0/28"),
+					),
+					statecheck.ExpectKnownValue(
+						"data.tfipam_allocation.test",
+						tfjsonpath.New("metadata"),
+						knownvalue.MapExact(map[string]knownvalue.Check{
+							"cmdb_id": knownvalue.StringExact("CI0067890"),
+						}),
+					),
+				},
+			},
+			{
+				ResourceName:      "tfipam_allocation.test",
+				ImportState:       true,
+				ImportStateVerify: true,
+				ImportStateId:     "metadata-alloc",
+			},
+			// removing it clears the stored metadata
+			{
+				Config: config("null"),
+				ConfigStateChecks: []statecheck.StateCheck{
+					statecheck.ExpectKnownValue(
+						"data.tfipam_allocation.test",
+						tfjsonpath.New("metadata"),
+						knownvalue.MapSizeExact(0),
+					),
+				},
+			},
+		},
+	})
+}
+
+func TestTakenCIDRs(t *testing.T) {
+	allocations := []storage.Allocation{
+		{ID: "v6", AllocatedCIDR: "2001:db8::/64"},
+		{ID: "high", AllocatedCIDR: "10.0.0.128/26"},
+		{ID: "blocks", AllocatedCIDR: "10.0.1.0/25", AllocatedCIDRs: []string{"10.0.1.0/25", "10.0.0.64/27"}},
+		{ID: "low", AllocatedCIDR: "10.0.0.0/26"},
+		{ID: "supernet", AllocatedCIDR: "10.0.0.0/24", AllowOverlap: true},
+	}
+	_, reserved, _ := net.ParseCIDR("10.0.0.192/27")
+	want := "[10.0.0.0/24 10.0.0.0/26 10.0.0.64/27 10.0.0.128/26 10.0.0.192/27 10.0.1.0/25 2001:db8::/64]"
+
+	// the taken blocks come out in address order however the allocations were stored
+	rng := rand.New(rand.NewSource(1))
+	for i := 0; i < 20; i++ {
+		shuffled := append([]storage.Allocation{}, allocations...)
+		rng.Shuffle(len(shuffled), func(i, j int) {
+			shuffled[i], shuffled[j] = shuffled[j], shuffled[i]
+		})
+
+		if got := fmt.Sprint(takenCIDRs(shuffled, []*net.IPNet{reserved})); got != want {
+			t.Fatalf("takenCIDRs after shuffle %d = %s, want %s", i, got, want)
+		}
+	}
+}
+
+func TestAccAllocationResource_GloballyReserved(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		PreCheck:                 func() { testAccPreCheck(t) },
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			// the pool contains the reserved /25, so the search has to skip past it
+			{
+				Config: testAccAllocationResourceConfigGloballyReserved("globally-reserved-pool", "10.0.0.0/25") + `
+resource "tfipam_allocation" "test" {
+  id            = "globally-reserved-alloc"
+  pool_name     = tfipam_pool.test.name
+  prefix_length = 26
+}
+`,
+				ConfigStateChecks: []statecheck.StateCheck{
+					statecheck.ExpectKnownValue(
+						"tfipam_allocation.test",
+						tfjsonpath.New("allocated_cidr"),
+						knownvalue.StringExact("10.0.0.128/26"),
+					),
+				},
+			},
+		},
+	})
+}
+
+func TestAccAllocationResource_GloballyReservedRequested(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		PreCheck:                 func() { testAccPreCheck(t) },
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			// allow_overlap doesn't extend to the globally reserved CIDRs
+			{
+				Config: testAccAllocationResourceConfigGloballyReserved("globally-reserved-requested-pool", "10.0.0.0/25") + `
+resource "tfipam_allocation" "test" {
+  id             = "globally-reserved-requested-alloc"
+  pool_name      = tfipam_pool.test.name
+  requested_cidr = "10.0.0.64/26"
+  allow_overlap  = true
+}
+`,
+				ExpectError: regexp.MustCompile("overlaps globally reserved CIDR 10.0.0.0/25"),
+			},
+		},
+	})
+}
+
+func TestAccAllocationResource_IPv6MinPrefix(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		PreCheck:                 func() { testAccPreCheck(t) },
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			// half of the /32 in one allocation is taken for a typo
+			{
+				Config:      testAccAllocationResourceConfigIPv6MinPrefix(false),
+				ExpectError: regexp.MustCompile("shorter than the provider's ipv6_min_prefix /48"),
+			},
+			{
+				Config: testAccAllocationResourceConfigIPv6MinPrefix(true),
+				ConfigStateChecks: []statecheck.StateCheck{
+					statecheck.ExpectKnownValue(
+						"tfipam_allocation.test",
+						tfjsonpath.New("allocated_cidr"),
+						knownvalue.StringExact("2001:db8::/33"),
+					),
+				},
+			},
+		},
+	})
+}
+
+// TestAllocateCIDRFromPool_IPv6MinPrefix checks which blocks the ipv6_min_prefix guard lets through.
+func TestAllocateCIDRFromPool_IPv6MinPrefix(t *testing.T) {
+	tests := map[string]struct {
+		cidrs         []string
+		prefixLength  int
+		requestedCIDR string
+		ipv6MinPrefix int
+		want          string
+		wantErr       bool
+	}{
+		"short prefix":             {cidrs: []string{"2001:db8::/32"}, prefixLength: 33, ipv6MinPrefix: 48, wantErr: true},
+		"short requested cidr":     {cidrs: []string{"2001:db8::/32"}, requestedCIDR: "2001:db8:8000::/33", prefixLength: 33, ipv6MinPrefix: 48, wantErr: true},
+		"minimum prefix":           {cidrs: []string{"2001:db8::/32"}, prefixLength: 48, ipv6MinPrefix: 48, want: "2001:db8::/48"},
+		"guard disabled":           {cidrs: []string{"2001:db8::/32"}, prefixLength: 33, want: "2001:db8::/33"},
+		"ipv4 cidr of mixed pool":  {cidrs: []string{"2001:db8::/32", "10.0.0.0/16"}, prefixLength: 24, ipv6MinPrefix: 48, want: "10.0.0.0/24"},
+		"ipv6 only prefix of pool": {cidrs: []string{"2001:db8::/32", "10.0.0.0/16"}, prefixLength: 40, ipv6MinPrefix: 48, wantErr: true},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			store, err := storage.NewFileStorage(filepath.Join(t.TempDir(), "ipam.json"))
+			if err != nil {
+				t.Fatalf("NewFileStorage: %v", err)
+			}
+			if err := store.SavePool(ctx, &storage.Pool{Name: "guarded", CIDRs: test.cidrs}); err != nil {
+				t.Fatalf("SavePool: %v", err)
+			}
+			r := &AllocationResource{provider: &IpamProvider{storage: store, claimTimeout: time.Minute}}
+
+			allocation := &storage.Allocation{ID: "new", PoolName: "guarded", PrefixLength: test.prefixLength, RequestedCIDR: test.requestedCIDR}
+			err = r.allocateCIDRFromPool(ctx, allocation, "", test.ipv6MinPrefix)
+			if test.wantErr {
+				if err == nil {
+					t.Fatalf("allocated %s, want an error", allocation.AllocatedCIDR)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("allocateCIDRFromPool: %v", err)
+			}
+			if allocation.AllocatedCIDR != test.want {
+				t.Errorf("allocated %s, want %s", allocation.AllocatedCIDR, test.want)
+			}
+		})
+	}
+}
+
+func TestAccAllocationResource_VerifyInPool(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		PreCheck:                 func() { testAccPreCheck(t) },
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: testAccAllocationResourceConfigVerifyInPool("10.0.0.0/24"),
+				ConfigStateChecks: []statecheck.StateCheck{
+					statecheck.ExpectKnownValue(
+						"tfipam_allocation.test",
+						tfjsonpath.New("allocated_cidr"),
+						knownvalue.StringExact("10.0.0.0/26"),
+					),
+				},
+			},
+			// the pool no longer contains the allocation, which only warns on refresh and keeps its CIDR
+			{
+				Config: testAccAllocationResourceConfigVerifyInPool("10.0.1.0/24"),
+				ConfigStateChecks: []statecheck.StateCheck{
+					statecheck.ExpectKnownValue(
+						"tfipam_allocation.test",
+						tfjsonpath.New("allocated_cidr"),
+						knownvalue.StringExact("10.0.0.0/26"),
+					),
+				},
+			},
+		},
+	})
+}
+
+func TestAccAllocationResource_AutoExpand(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		PreCheck:                 func() { testAccPreCheck(t) },
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config:      testAccAllocationResourceConfigAutoExpand(`auto_expand_cidr = "10.0.0.0/16"`),
+				ExpectError: regexp.MustCompile("auto_expand_cidr and auto_expand_prefix_length must be set together"),
+			},
+			{
+				Config:      testAccAllocationResourceConfigAutoExpand("auto_expand_cidr = \"10.0.0.0/16\"\n  auto_expand_prefix_length = 12"),
+				ExpectError: regexp.MustCompile("auto_expand_prefix_length must be between 16 and 32"),
+			},
+			// the pool only has room for the first allocation, the second gets a block added to the pool
+			// that skips the neighbouring pool's CIDR
+			{
+				Config: testAccAllocationResourceConfigAutoExpand("auto_expand_cidr = \"10.0.0.0/16\"\n  auto_expand_prefix_length = 28"),
+				ConfigStateChecks: []statecheck.StateCheck{
+					statecheck.ExpectKnownValue(
+						"tfipam_allocation.first",
+						tfjsonpath.New("allocated_cidr"),
+						knownvalue.StringExact("10.0.0.0/28"),
+					),
+					statecheck.ExpectKnownValue(
+						"tfipam_allocation.second",
+						tfjsonpath.New("allocated_cidr"),
+						knownvalue.StringExact("10.0.0.32/28"),
+					),
+				},
+			},
+			// the pool picks up the added block on refresh
+			{
+				Config: testAccAllocationResourceConfigAutoExpand("auto_expand_cidr = \"10.0.0.0/16\"\n  auto_expand_prefix_length = 28"),
+				ConfigStateChecks: []statecheck.StateCheck{
+					statecheck.ExpectKnownValue(
+						"tfipam_pool.test",
+						tfjsonpath.New("expanded_cidrs"),
+						knownvalue.ListExact([]knownvalue.Check{
+							knownvalue.StringExact("10.0.0.32/28"),
+						}),
+					),
+				},
+			},
+		},
+	})
+}
+
+func TestAccAllocationResource_SkipBoundarySubnets(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		PreCheck:                 func() { testAccPreCheck(t) },
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			// both /17 blocks of the pool are boundary subnets
+			{
+				Config:      testAccAllocationResourceConfigSkipBoundary(17),
+				ExpectError: regexp.MustCompile("no available CIDR blocks of size /17"),
+			},
+			{
+				Config: testAccAllocationResourceConfigSkipBoundary(24),
+				ConfigStateChecks: []statecheck.StateCheck{
+					statecheck.ExpectKnownValue(
+						"tfipam_allocation.test",
+						tfjsonpath.New("allocated_cidr"),
+						knownvalue.StringExact("10.0.1.0/24"),
+					),
+				},
+			},
+		},
+	})
+}
+
+func TestAccAllocationResource_BlockIndex(t *testing.T) {
+	checkIndex := func(name string, index int64) statecheck.StateCheck {
+		return statecheck.ExpectKnownValue(
+			"tfipam_allocation."+name,
+			tfjsonpath.New("block_index"),
+			knownvalue.Int64Exact(index),
+		)
+	}
+
+	resource.Test(t, resource.TestCase{
+		PreCheck:                 func() { testAccPreCheck(t) },
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			// the /24s are the first three blocks of the /16, and 10.1.0.64/26 is the second /26 of 10.1.0.0/24
+			{
+				Config: testAccAllocationResourceConfigBlockIndex,
+				ConfigStateChecks: []statecheck.StateCheck{
+					checkIndex("first", 0),
+					checkIndex("second", 1),
+					checkIndex("third", 2),
+					checkIndex("small", 1),
+				},
+			},
+		},
+	})
+}
+
+func TestAccAllocationResource_IDTemplate(t *testing.T) {
+	checkID := func(name, id string) statecheck.StateCheck {
+		return statecheck.ExpectKnownValue(
+			"tfipam_allocation."+name,
+			tfjsonpath.New("id"),
+			knownvalue.StringExact(id),
+		)
+	}
+
+	resource.Test(t, resource.TestCase{
+		PreCheck:                 func() { testAccPreCheck(t) },
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: testAccAllocationResourceConfigIDTemplate(false),
+				ConfigStateChecks: []statecheck.StateCheck{
+					checkID("first", "alloc-id-template-pool-1"),
+					checkID("second", "alloc-id-template-pool-2"),
+				},
+			},
+			// the fourth allocation's index is taken by an allocation with an explicit id, so it gets the next one
+			{
+				Config: testAccAllocationResourceConfigIDTemplate(true),
+				ConfigStateChecks: []statecheck.StateCheck{
+					checkID("first", "alloc-id-template-pool-1"),
+					checkID("second", "alloc-id-template-pool-2"),
+					checkID("explicit", "alloc-id-template-pool-4"),
+					checkID("fourth", "alloc-id-template-pool-5"),
+				},
+			},
+		},
+	})
+}
+
+func TestAccAllocationResource_SubnetCount(t *testing.T) {
+	checkPrefixLength := func(name string, prefixLength int64) statecheck.StateCheck {
+		return statecheck.ExpectKnownValue(
+			"tfipam_allocation."+name,
+			tfjsonpath.New("prefix_length"),
+			knownvalue.Int64Exact(prefixLength),
+		)
+	}
+
+	resource.Test(t, resource.TestCase{
+		PreCheck:                 func() { testAccPreCheck(t) },
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: testAccAllocationResourceConfigSubnetCount("subnet-count-pool", map[string]int{"one": 1, "three": 3, "sixteen": 16, "seventeen": 17}),
+				ConfigStateChecks: []statecheck.StateCheck{
+					checkPrefixLength("one", 28),
+					checkPrefixLength("three", 26),
+					checkPrefixLength("sixteen", 24),
+					checkPrefixLength("seventeen", 23),
+					statecheck.ExpectKnownValue(
+						"tfipam_allocation.sixteen",
+						tfjsonpath.New("subnet_prefix_length"),
+						knownvalue.Int64Exact(28),
+					),
+				},
+			},
+		},
+	})
+}
+
+func TestAccAllocationResource_SubnetCountInvalid(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		PreCheck:                 func() { testAccPreCheck(t) },
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config:      testAccAllocationResourceConfigSubnetCount("subnet-count-invalid-pool", map[string]int{"none": 0}),
+				PlanOnly:    true,
+				ExpectError: regexp.MustCompile("subnet count must be at least 1, got 0"),
+			},
+		},
+	})
+}
+
+func TestAccAllocationResource_PoolReservedRequested(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		PreCheck:                 func() { testAccPreCheck(t) },
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: testAccPoolResourceConfigSupernet("pool-reserved-requested-pool", "10.0.0.0/16", []string{"10.0.0.0/24"}) + `
+resource "tfipam_allocation" "test" {
+  id             = "pool-reserved-requested-alloc"
+  pool_name      = tfipam_pool.test.name
+  requested_cidr = "10.0.0.0/25"
+}
+`,
+				ExpectError: regexp.MustCompile("requested CIDR 10.0.0.0/25 overlaps reserved range 10.0.0.0/24"),
+			},
+		},
+	})
+}
+
+func TestAccAllocationResource_AllowOverlap(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		PreCheck:                 func() { testAccPreCheck(t) },
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: testAccAllocationResourceConfigRequested("allow-overlap-pool", "10.0.0.0/25", true),
+				ConfigStateChecks: []statecheck.StateCheck{
+					statecheck.ExpectKnownValue(
+						"tfipam_allocation.existing",
+						tfjsonpath.New("allocated_cidr"),
+						knownvalue.StringExact("10.0.0.0/24"),
+					),
+					statecheck.ExpectKnownValue(
+						"tfipam_allocation.requested",
+						tfjsonpath.New("allocated_cidr"),
+						knownvalue.StringExact("10.0.0.0/25"),
+					),
+					statecheck.ExpectKnownValue(
+						"tfipam_allocation.requested",
+						tfjsonpath.New("allow_overlap"),
+						knownvalue.Bool(true),
+					),
+				},
+			},
+		},
+	})
+}
+
+func TestAccAllocationResource_AllowOverlapWithoutRequestedCIDR(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		PreCheck:                 func() { testAccPreCheck(t) },
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: `
+resource "tfipam_pool" "test" {
+  name  = "allow-overlap-auto-pool"
+  cidrs = ["10.0.0.0/16"]
+}
+
+resource "tfipam_allocation" "test" {
+  pool_name     = tfipam_pool.test.name
+  prefix_length = 24
+  allow_overlap = true
+}
+`,
+				ExpectError: regexp.MustCompile("Invalid Allow Overlap"),
+			},
+		},
+	})
+}
+
+func TestAccAllocationResource_FromCIDR(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		PreCheck:                 func() { testAccPreCheck(t) },
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			// the first pool CIDR has room, but the allocation is pinned to the second
+			{
+				Config: testAccAllocationResourceConfigFromCIDR("from-cidr-pool", "192.168.0.0/24"),
+				ConfigStateChecks: []statecheck.StateCheck{
+					statecheck.ExpectKnownValue(
+						"tfipam_allocation.test",
+						tfjsonpath.New("allocated_cidr"),
+						knownvalue.StringExact("192.168.0.0/26"),
+					),
+					statecheck.ExpectKnownValue(
+						"tfipam_allocation.test",
+						tfjsonpath.New("from_cidr"),
+						knownvalue.StringExact("192.168.0.0/24"),
+					),
+				},
+			},
+			{
+				ResourceName:      "tfipam_allocation.test",
+				ImportState:       true,
+				ImportStateVerify: true,
+				ImportStateId:     "from-cidr-alloc",
+			},
+		},
+	})
+}
+
+func TestAccAllocationResource_FromCIDRNotInPool(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		PreCheck:                 func() { testAccPreCheck(t) },
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config:      testAccAllocationResourceConfigFromCIDR("from-cidr-missing-pool", "172.16.0.0/24"),
+				ExpectError: regexp.MustCompile("is not one of the CIDRs of pool"),
+			},
+		},
+	})
+}
+
+func TestAccAllocationResource_NearlyExhausted(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		PreCheck:                 func() { testAccPreCheck(t) },
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			// the /28 leaves 16 of 256 addresses free, below the default 10% threshold, which only warns
+			{
+				Config: testAccAllocationResourceConfigSmallPool("nearly-exhausted-pool", "nearly-exhausted-alloc", 25) + `
+resource "tfipam_allocation" "second" {
+  id            = "nearly-exhausted-second"
+  pool_name     = tfipam_pool.test.name
+  prefix_length = 26
+
+  depends_on = [tfipam_allocation.test]
+}
+
+resource "tfipam_allocation" "third" {
+  id            = "nearly-exhausted-third"
+  pool_name     = tfipam_pool.test.name
+  prefix_length = 27
+
+  depends_on = [tfipam_allocation.second]
+}
+
+resource "tfipam_allocation" "fourth" {
+  id            = "nearly-exhausted-fourth"
+  pool_name     = tfipam_pool.test.name
+  prefix_length = 28
+
+  depends_on = [tfipam_allocation.third]
+}
+`,
+				ConfigStateChecks: []statecheck.StateCheck{
+					statecheck.ExpectKnownValue(
+						"tfipam_allocation.fourth",
+						tfjsonpath.New("allocated_cidr"),
+						knownvalue.StringExact("10.0.0.224/28"),
+					),
+				},
+			},
+		},
+	})
+}
+
+func TestAccAllocationResource_PoolNotFound(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		PreCheck:                 func() { testAccPreCheck(t) },
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config:      testAccAllocationResourceConfigNoPool("nonexistent-pool", "test-alloc", 24),
+				ExpectError: regexp.MustCompile("pool.*not found|Allocation Failed"),
+			},
+		},
+	})
+}
+
+func TestAccAllocationResource_IDChange(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		PreCheck:                 func() { testAccPreCheck(t) },
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			// Create with original ID
+			{
+				Config: testAccAllocationResourceConfig("id-pool", "original-id", 24),
+				ConfigStateChecks: []statecheck.StateCheck{
+					statecheck.ExpectKnownValue(
+						"tfipam_allocation.test",
+						tfjsonpath.New("id"),
+						knownvalue.StringExact("original-id"),
+					),
+				},
+			},
+			// Change ID (should trigger replacement)
+			{
+				Config: testAccAllocationResourceConfig("id-pool", "new-id", 24),
+				ConfigStateChecks: []statecheck.StateCheck{
+					statecheck.ExpectKnownValue(
+						"tfipam_allocation.test",
+						tfjsonpath.New("id"),
+						knownvalue.StringExact("new-id"),
+					),
+				},
+			},
+		},
+	})
+}
+
+func TestAccAllocationResource_GeneratedID(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		PreCheck:                 func() { testAccPreCheck(t) },
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			// Create without an ID, one should be generated
+			{
+				Config: testAccAllocationResourceConfigNoID("generated-id-pool", 24),
+				ConfigStateChecks: []statecheck.StateCheck{
+					statecheck.ExpectKnownValue(
+						"tfipam_allocation.test",
+						tfjsonpath.New("id"),
+						knownvalue.StringRegexp(regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}$`)),
+					),
+					statecheck.ExpectKnownValue(
+						"tfipam_allocation.test",
+						tfjsonpath.New("allocated_cidr"),
+						knownvalue.StringExact("10.0.0.0/24"),
+					),
+				},
+			},
+			// Reapplying the same config should keep the generated ID
+			{
+				Config: testAccAllocationResourceConfigNoID("generated-id-pool", 24),
+				ConfigPlanChecks: resource.ConfigPlanChecks{
+					PreApply: []plancheck.PlanCheck{
+						plancheck.ExpectEmptyPlan(),
+					},
+				},
+			},
+		},
+	})
+}
+
+// TestAllocateCIDRFromPool_Concurrent claims blocks of one pool from many goroutines, the way Terraform
+// creates allocations in parallel, and checks that no two of them got overlapping CIDRs.
+func TestAllocateCIDRFromPool_Concurrent(t *testing.T) {
+	ctx := context.Background()
+	store, err := storage.NewFileStorage(filepath.Join(t.TempDir(), "ipam.json"))
+	if err != nil {
+		t.Fatalf("NewFileStorage: %v", err)
+	}
+	if err := store.SavePool(ctx, &storage.Pool{Name: "concurrent", CIDRs: []string{"10.0.0.0/16"}}); err != nil {
+		t.Fatalf("SavePool: %v", err)
+	}
+	r := &AllocationResource{provider: &IpamProvider{storage: store, claimTimeout: time.Minute}}
+
+	const workers = 64
+	start := make(chan struct{})
+	errs := make(chan error, workers)
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-start
+			// mixed sizes, so a block can also overlap one of another size
+			allocation := &storage.Allocation{
+				ID:           fmt.Sprintf("concurrent-%d", i),
+				PoolName:     "concurrent",
+				PrefixLength: 24 - i%2,
+			}
+			if err := r.allocateCIDRFromPool(ctx, allocation, "", 0); err != nil {
+				errs <- fmt.Errorf("allocation %s: %w", allocation.ID, err)
+			}
+		}()
+	}
+	close(start)
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+
+	allocations, err := store.ListAllocationsByPool(ctx, "concurrent")
+	if err != nil {
+		t.Fatalf("ListAllocationsByPool: %v", err)
+	}
+	if len(allocations) != workers {
+		t.Fatalf("got %d allocations, want %d", len(allocations), workers)
+	}
+	claimed := make([]*net.IPNet, 0, len(allocations))
+	for _, allocation := range allocations {
+		_, cidrNet, err := net.ParseCIDR(allocation.AllocatedCIDR)
+		if err != nil {
+			t.Fatalf("allocation %s has an invalid CIDR %s: %v", allocation.ID, allocation.AllocatedCIDR, err)
+		}
+		if cidrsOverlap(cidrNet, claimed) {
+			t.Errorf("allocation %s got %s, which overlaps another allocation", allocation.ID, cidrNet)
+		}
+		claimed = append(claimed, cidrNet)
+	}
+}
+
+// TestAllocateCIDRFromPool_Strategy allocates a /27 from a pool with a free /25 ahead of a free /27, which
+// first fit splits and best fit leaves whole.
+func TestAllocateCIDRFromPool_Strategy(t *testing.T) {
+	tests := map[string]struct {
+		strategy string
+		want     string
+	}{
+		"default":   {strategy: "", want: "10.0.0.0/27"},
+		"first_fit": {strategy: poolStrategyFirstFit, want: "10.0.0.0/27"},
+		"best_fit":  {strategy: poolStrategyBestFit, want: "10.0.0.192/27"},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			store, err := storage.NewFileStorage(filepath.Join(t.TempDir(), "ipam.json"))
+			if err != nil {
+				t.Fatalf("NewFileStorage: %v", err)
+			}
+			if err := store.SavePool(ctx, &storage.Pool{Name: "strategy", CIDRs: []string{"10.0.0.0/24"}, Strategy: test.strategy}); err != nil {
+				t.Fatalf("SavePool: %v", err)
+			}
+			// leaves 10.0.0.0/25 and 10.0.0.192/27 free
+			for _, cidr := range []string{"10.0.0.128/26", "10.0.0.224/27"} {
+				allocation := &storage.Allocation{ID: cidr, PoolName: "strategy", AllocatedCIDR: cidr}
+				if err := store.SaveAllocation(ctx, allocation); err != nil {
+					t.Fatalf("SaveAllocation: %v", err)
+				}
+			}
+			r := &AllocationResource{provider: &IpamProvider{storage: store, claimTimeout: time.Minute}}
+
+			allocation := &storage.Allocation{ID: "new", PoolName: "strategy", PrefixLength: 27}
+			if err := r.allocateCIDRFromPool(ctx, allocation, "", 0); err != nil {
+				t.Fatalf("allocateCIDRFromPool: %v", err)
+			}
+			if allocation.AllocatedCIDR != test.want {
+				t.Errorf("allocated %s, want %s", allocation.AllocatedCIDR, test.want)
+			}
+		})
+	}
+}
+
+// TestAllocateCIDRFromPool_DefaultPrefixLength allocates with and without a prefix length from pools with
+// and without a default one.
+func TestAllocateCIDRFromPool_DefaultPrefixLength(t *testing.T) {
+	tests := map[string]struct {
+		defaultPrefix int
+		prefixLength  int
+		want          string
+		wantPrefix    int
+		wantErr       bool
+	}{
+		"inherited":  {defaultPrefix: 26, want: "10.0.0.0/26", wantPrefix: 26},
+		"explicit":   {defaultPrefix: 26, prefixLength: 28, want: "10.0.0.0/28", wantPrefix: 28},
+		"no default": {wantErr: true},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			store, err := storage.NewFileStorage(filepath.Join(t.TempDir(), "ipam.json"))
+			if err != nil {
+				t.Fatalf("NewFileStorage: %v", err)
+			}
+			if err := store.SavePool(ctx, &storage.Pool{Name: "defaults", CIDRs: []string{"10.0.0.0/24"}, DefaultPrefixLength: test.defaultPrefix}); err != nil {
+				t.Fatalf("SavePool: %v", err)
+			}
+			r := &AllocationResource{provider: &IpamProvider{storage: store, claimTimeout: time.Minute}}
+
+			allocation := &storage.Allocation{ID: "new", PoolName: "defaults", PrefixLength: test.prefixLength}
+			err = r.allocateCIDRFromPool(ctx, allocation, "", 0)
+			if test.wantErr {
+				if err == nil {
+					t.Fatalf("allocated %s, want an error", allocation.AllocatedCIDR)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("allocateCIDRFromPool: %v", err)
+			}
+			if allocation.AllocatedCIDR != test.want {
+				t.Errorf("allocated %s, want %s", allocation.AllocatedCIDR, test.want)
+			}
+
+			stored, err := store.GetAllocation(ctx, allocation.StorageKey())
+			if err != nil {
+				t.Fatalf("GetAllocation: %v", err)
+			}
+			if stored.PrefixLength != test.wantPrefix {
+				t.Errorf("stored prefix length /%d, want /%d", stored.PrefixLength, test.wantPrefix)
+			}
+		})
+	}
+}
+
+func TestAllocateCIDRFromPools_Failover(t *testing.T) {
+	tests := map[string]struct {
+		poolNames []string
+		wantPool  string
+		want      string
+		wantErr   bool
+	}{
+		"first pool has room":            {poolNames: []string{"secondary", "primary"}, wantPool: "secondary", want: "10.1.0.0/24"},
+		"first pool exhausted":           {poolNames: []string{"primary", "secondary"}, wantPool: "secondary", want: "10.1.0.0/24"},
+		"every pool exhausted":           {poolNames: []string{"primary", "full"}, wantErr: true},
+		"missing pool doesn't fail over": {poolNames: []string{"missing", "secondary"}, wantErr: true},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			store, err := storage.NewFileStorage(filepath.Join(t.TempDir(), "ipam.json"))
+			if err != nil {
+				t.Fatalf("NewFileStorage: %v", err)
+			}
+			for _, pool := range []*storage.Pool{
+				{Name: "primary", CIDRs: []string{"10.0.0.0/24"}},
+				{Name: "full", CIDRs: []string{"10.2.0.0/24"}},
+				{Name: "secondary", CIDRs: []string{"10.1.0.0/16"}},
+			} {
+				if err := store.SavePool(ctx, pool); err != nil {
+					t.Fatalf("SavePool: %v", err)
+				}
+			}
+			r := &AllocationResource{provider: &IpamProvider{storage: store, claimTimeout: time.Minute}}
+			// exhaust primary and full
+			for _, poolName := range []string{"primary", "full"} {
+				if err := r.allocateCIDRFromPool(ctx, &storage.Allocation{ID: "existing", PoolName: poolName, PrefixLength: 24}, "", 0); err != nil {
+					t.Fatalf("allocateCIDRFromPool: %v", err)
+				}
+			}
+
+			allocation := &storage.Allocation{ID: "new", PrefixLength: 24}
+			err = r.allocateCIDRFromPools(ctx, allocation, test.poolNames, "", false, 0)
+			if test.wantErr {
+				if err == nil {
+					t.Fatalf("allocated %s from pool %s, want an error", allocation.AllocatedCIDR, allocation.PoolName)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("allocateCIDRFromPools: %v", err)
+			}
+			if allocation.PoolName != test.wantPool || allocation.AllocatedCIDR != test.want {
+				t.Errorf("allocated %s from pool %s, want %s from pool %s", allocation.AllocatedCIDR, allocation.PoolName, test.want, test.wantPool)
+			}
+
+			stored, err := store.GetAllocation(ctx, allocation.StorageKey())
+			if err != nil {
+				t.Fatalf("GetAllocation: %v", err)
+			}
+			if stored.PoolName != test.wantPool {
+				t.Errorf("stored allocation in pool %s, want %s", stored.PoolName, test.wantPool)
+			}
+		})
+	}
+}
+
+func TestAllocateCIDRFromPools_PreferredCIDR(t *testing.T) {
+	tests := map[string]struct {
+		preferred string
+		want      string
+	}{
+		"free":         {preferred: "10.0.0.128/26", want: "10.0.0.128/26"},
+		"taken":        {preferred: "10.0.0.0/26", want: "10.0.0.64/26"},
+		"outside pool": {preferred: "10.9.0.0/26", want: "10.0.0.64/26"},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			store, err := storage.NewFileStorage(filepath.Join(t.TempDir(), "ipam.json"))
+			if err != nil {
+				t.Fatalf("NewFileStorage: %v", err)
+			}
+			if err := store.SavePool(ctx, &storage.Pool{Name: "preferred", CIDRs: []string{"10.0.0.0/24"}}); err != nil {
+				t.Fatalf("SavePool: %v", err)
+			}
+			r := &AllocationResource{provider: &IpamProvider{storage: store, claimTimeout: time.Minute}}
+			if err := r.allocateCIDRFromPool(ctx, &storage.Allocation{ID: "existing", PoolName: "preferred", PrefixLength: 26}, "", 0); err != nil {
+				t.Fatalf("allocateCIDRFromPool: %v", err)
+			}
+
+			allocation := &storage.Allocation{ID: "new", PrefixLength: 26}
+			if err := r.allocateCIDRFromPools(ctx, allocation, []string{"preferred"}, test.preferred, false, 0); err != nil {
+				t.Fatalf("allocateCIDRFromPools: %v", err)
+			}
+			if allocation.AllocatedCIDR != test.want {
+				t.Errorf("allocated %s, want %s", allocation.AllocatedCIDR, test.want)
+			}
+		})
+	}
+}
+
+func TestAccAllocationResource_DuplicateID(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		PreCheck:                 func() { testAccPreCheck(t) },
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			// Ids are unique within a pool, so allocations of different pools can share one
+			{
+				Config: testAccAllocationResourceConfigDuplicateID("dup-pool-1", "dup-pool-2", "duplicate-id", "pool2"),
+				ConfigStateChecks: []statecheck.StateCheck{
+					statecheck.ExpectKnownValue(
+						"tfipam_allocation.second",
+						tfjsonpath.New("id"),
+						knownvalue.StringExact("duplicate-id"),
+					),
+					statecheck.CompareValuePairs(
+						"tfipam_allocation.first",
+						tfjsonpath.New("key"),
+						"tfipam_allocation.second",
+						tfjsonpath.New("key"),
+						compare.ValuesDiffer(),
+					),
+				},
+			},
+			{
+				Config:      testAccAllocationResourceConfigDuplicateID("dup-pool-1", "dup-pool-2", "duplicate-id", "pool1"),
+				ExpectError: regexp.MustCompile("allocation id duplicate-id already in use"),
+			},
+		},
+	})
+}
+
+func TestAccAllocationResource_PoolChange(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		PreCheck:                 func() { testAccPreCheck(t) },
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			// Create with original pool
+			{
+				Config: testAccAllocationResourceConfigTwoPools("pool-1", "pool-2", "test-alloc", 24, "pool-1"),
+				ConfigStateChecks: []statecheck.StateCheck{
+					statecheck.ExpectKnownValue(
+						"tfipam_allocation.test",
+						tfjsonpath.New("pool_name"),
+						knownvalue.StringExact("pool-1"),
+					),
+				},
+			},
+			// Change pool, the CIDR doesn't fit the new pool so the allocation is replaced
+			{
+				Config: testAccAllocationResourceConfigTwoPools("pool-1", "pool-2", "test-alloc", 24, "pool-2"),
+				ConfigPlanChecks: resource.ConfigPlanChecks{
+					PreApply: []plancheck.PlanCheck{
+						plancheck.ExpectResourceAction("tfipam_allocation.test", plancheck.ResourceActionReplace),
+					},
+				},
+				ConfigStateChecks: []statecheck.StateCheck{
+					statecheck.ExpectKnownValue(
+						"tfipam_allocation.test",
+						tfjsonpath.New("pool_name"),
+						knownvalue.StringExact("pool-2"),
+					),
+				},
+			},
+		},
+	})
+}
+
+func TestAccAllocationResource_PoolMove(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		PreCheck:                 func() { testAccPreCheck(t) },
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: testAccAllocationResourceConfigMove("tfipam_pool.source.name"),
+				ConfigStateChecks: []statecheck.StateCheck{
+					statecheck.ExpectKnownValue(
+						"tfipam_allocation.test",
+						tfjsonpath.New("allocated_cidr"),
+						knownvalue.StringExact("10.0.0.0/24"),
+					),
+				},
+			},
+			// The CIDR is free in the target pool, so the allocation moves in place and keeps it
+			{
+				Config: testAccAllocationResourceConfigMove("tfipam_pool.target.name"),
+				ConfigPlanChecks: resource.ConfigPlanChecks{
+					PreApply: []plancheck.PlanCheck{
+						plancheck.ExpectResourceAction("tfipam_allocation.test", plancheck.ResourceActionUpdate),
+					},
+				},
+				ConfigStateChecks: []statecheck.StateCheck{
+					statecheck.ExpectKnownValue(
+						"tfipam_allocation.test",
+						tfjsonpath.New("pool_name"),
+						knownvalue.StringExact("move-target-pool"),
+					),
+					statecheck.ExpectKnownValue(
+						"tfipam_allocation.test",
+						tfjsonpath.New("allocated_cidr"),
+						knownvalue.StringExact("10.0.0.0/24"),
+					),
+				},
+			},
+		},
+	})
+}
+
+func TestAccAllocationResource_PrefixLengthChange(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		PreCheck:                 func() { testAccPreCheck(t) },
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			// Create with /24
+			{
+				Config: testAccAllocationResourceConfig("prefix-pool", "prefix-alloc", 24),
+				ConfigStateChecks: []statecheck.StateCheck{
+					statecheck.ExpectKnownValue(
+						"tfipam_allocation.test",
+						tfjsonpath.New("prefix_length"),
+						knownvalue.Int64Exact(24),
+					),
+				},
+			},
+			// Change to /27 (should trigger replacement)
+			{
+				Config: testAccAllocationResourceConfig("prefix-pool", "prefix-alloc", 27),
+				ConfigStateChecks: []statecheck.StateCheck{
+					statecheck.ExpectKnownValue(
+						"tfipam_allocation.test",
+						tfjsonpath.New("prefix_length"),
+						knownvalue.Int64Exact(27),
+					),
+				},
+			},
+		},
+	})
+}
+
+func TestAccAllocationResource_Import(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		PreCheck:                 func() { testAccPreCheck(t) },
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			// Create allocation
+			{
+				Config: testAccAllocationResourceConfig("import-pool", "import-alloc", 24),
+			},
+			// Import by ID
+			{
+				ResourceName:      "tfipam_allocation.test",
+				ImportState:       true,
+				ImportStateVerify: true,
+				ImportStateId:     "import-alloc",
+			},
+		},
+	})
+}
+
+func TestAccAllocationResource_ImportByPoolCIDR(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		PreCheck:                 func() { testAccPreCheck(t) },
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			// Create allocation
+			{
+				Config: testAccAllocationResourceConfig("import-cidr-pool", "import-cidr-alloc", 24),
+			},
+			// Import the existing allocation by pool name and CIDR
+			{
+				ResourceName:      "tfipam_allocation.test",
+				ImportState:       true,
+				ImportStateVerify: true,
+				ImportStateId:     "import-cidr-pool:10.0.0.0/24",
+			},
+		},
+	})
+}
+
+func TestAccAllocationResource_ImportByPoolCIDR_NewRecord(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		PreCheck:                 func() { testAccPreCheck(t) },
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			// Create the pool only
+			{
+				Config: testAccPoolResourceConfig("import-new-pool", []string{"10.0.0.0/16"}),
+			},
+			// CIDR isn't allocated yet, import records a new allocation for it
+			{
+				Config:             testAccAllocationResourceConfigNoID("import-new-pool", 24),
+				ResourceName:       "tfipam_allocation.test",
+				ImportState:        true,
+				ImportStatePersist: true,
+				ImportStateId:      "import-new-pool:10.0.5.0/24",
+				ImportStateCheck: func(states []*terraform.InstanceState) error {
+					if len(states) != 1 {
+						return fmt.Errorf("expected 1 imported state, got %d", len(states))
+					}
+					attrs := states[0].Attributes
+					if attrs["allocated_cidr"] != "10.0.5.0/24" {
+						return fmt.Errorf("expected allocated_cidr 10.0.5.0/24, got %s", attrs["allocated_cidr"])
+					}
+					if attrs["id"] == "" {
+						return fmt.Errorf("expected a generated id")
+					}
+					return nil
+				},
+			},
+			// The imported allocation matches the config without changes
+			{
+				Config: testAccAllocationResourceConfigNoID("import-new-pool", 24),
+				ConfigPlanChecks: resource.ConfigPlanChecks{
+					PreApply: []plancheck.PlanCheck{
+						plancheck.ExpectEmptyPlan(),
+					},
+				},
+				ConfigStateChecks: []statecheck.StateCheck{
+					statecheck.ExpectKnownValue(
+						"tfipam_allocation.test",
+						tfjsonpath.New("allocated_cidr"),
+						knownvalue.StringExact("10.0.5.0/24"),
+					),
+				},
+			},
+		},
+	})
+}
+
+func TestAccAllocationResource_ImportByPoolCIDR_Overlap(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		PreCheck:                 func() { testAccPreCheck(t) },
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: testAccAllocationResourceConfig("import-overlap-pool", "import-overlap-alloc", 24),
+			},
+			{
+				ResourceName:  "tfipam_allocation.test",
+				ImportState:   true,
+				ImportStateId: "import-overlap-pool:10.0.0.0/16",
+				ExpectError:   regexp.MustCompile("overlaps an existing allocation"),
+			},
+		},
+	})
+}
+
+func TestAccAllocationResource_IPv6(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		PreCheck:                 func() { testAccPreCheck(t) },
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: testAccAllocationResourceConfigIPv6("ipv6-pool", "ipv6-alloc", 64),
+				ConfigStateChecks: []statecheck.StateCheck{
+					statecheck.ExpectKnownValue(
+						"tfipam_allocation.test",
+						tfjsonpath.New("id"),
+						knownvalue.StringExact("ipv6-alloc"),
+					),
+					statecheck.ExpectKnownValue(
+						"tfipam_allocation.test",
+						tfjsonpath.New("prefix_length"),
+						knownvalue.Int64Exact(64),
+					),
+					statecheck.ExpectKnownValue(
+						"tfipam_allocation.test",
+						tfjsonpath.New("allocated_cidr"),
+						knownvalue.NotNull(),
+					),
+				},
+			},
+		},
+	})
+}
+
+func TestAccAllocationResource_IPv6_MultipleSubnets(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		PreCheck:                 func() { testAccPreCheck(t) },
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: testAccAllocationResourceConfigIPv6Multiple("ipv6-multi-pool"),
+				ConfigStateChecks: []statecheck.StateCheck{
+					statecheck.ExpectKnownValue(
+						"tfipam_allocation.test_48",
+						tfjsonpath.New("prefix_length"),
+						knownvalue.Int64Exact(48),
+					),
+					statecheck.ExpectKnownValue(
+						"tfipam_allocation.test_56",
+						tfjsonpath.New("prefix_length"),
+						knownvalue.Int64Exact(56),
+					),
+					statecheck.ExpectKnownValue(
+						"tfipam_allocation.test_64",
+						tfjsonpath.New("prefix_length"),
+						knownvalue.Int64Exact(64),
+					),
+				},
+			},
+		},
+	})
+}
+
+func TestAccAllocationResource_IPVersion(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		PreCheck:                 func() { testAccPreCheck(t) },
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: `
+resource "tfipam_pool" "test" {
+  name  = "ip-version-pool"
+  cidrs = ["10.5.0.0/24", "2001:db8:5::/48"]
+}
+
+resource "tfipam_allocation" "ipv4" {
+  id            = "ip-version-ipv4"
+  pool_name     = tfipam_pool.test.name
+  prefix_length = 28
+  from_cidr     = "10.5.0.0/24"
+}
+
+resource "tfipam_allocation" "ipv6" {
+  id            = "ip-version-ipv6"
+  pool_name     = tfipam_pool.test.name
+  prefix_length = 64
+  from_cidr     = "2001:db8:5::/48"
+}
+`,
+				ConfigStateChecks: []statecheck.StateCheck{
+					statecheck.ExpectKnownValue(
+						"tfipam_allocation.ipv4",
+						tfjsonpath.New("ip_version"),
+						knownvalue.Int64Exact(4),
+					),
+					statecheck.ExpectKnownValue(
+						"tfipam_allocation.ipv6",
+						tfjsonpath.New("ip_version"),
+						knownvalue.Int64Exact(6),
+					),
+					statecheck.ExpectKnownValue(
+						"tfipam_pool.test",
+						tfjsonpath.New("ip_version"),
+						knownvalue.Null(),
+					),
+				},
+			},
+		},
+	})
+}
+
+func TestAccAllocationResource_SequentialAllocations(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		PreCheck:                 func() { testAccPreCheck(t) },
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			// Start with one allocation
+			{
+				Config: testAccAllocationResourceConfigSequential("seq-pool", 1, 27),
+				ConfigStateChecks: []statecheck.StateCheck{
+					statecheck.ExpectKnownValue(
+						"tfipam_allocation.test_0",
+						tfjsonpath.New("id"),
+						knownvalue.StringExact("seq-alloc-0"),
+					),
+				},
+			},
+			// Add second allocation
+			{
+				Config: testAccAllocationResourceConfigSequential("seq-pool", 2, 27),
+				ConfigStateChecks: []statecheck.StateCheck{
+					statecheck.ExpectKnownValue(
+						"tfipam_allocation.test_0",
+						tfjsonpath.New("id"),
+						knownvalue.StringExact("seq-alloc-0"),
+					),
+					statecheck.ExpectKnownValue(
+						"tfipam_allocation.test_1",
+						tfjsonpath.New("id"),
+						knownvalue.StringExact("seq-alloc-1"),
+					),
+				},
+			},
+			// Add third allocation
+			{
+				Config: testAccAllocationResourceConfigSequential("seq-pool", 3, 27),
+				ConfigStateChecks: []statecheck.StateCheck{
+					statecheck.ExpectKnownValue(
+						"tfipam_allocation.test_2",
+						tfjsonpath.New("id"),
+						knownvalue.StringExact("seq-alloc-2"),
+					),
+				},
+			},
+		},
+	})
+}
+
+// testAccAllocationResourceConfig generates a Terraform configuration for an allocation resource.
+func testAccAllocationResourceConfig(poolName, allocID string, prefixLength int) string {
+	return fmt.Sprintf(`
+resource "tfipam_pool" "test" {
+  name = %[1]q
+  cidrs = ["10.0.0.0/16"]
+}
 
 resource "tfipam_allocation" "test" {
   id            = %[2]q
@@ -444,6 +2262,374 @@ resource "tfipam_allocation" "test" {
 `, poolName, allocID, prefixLength)
 }
 
+// testAccAllocationResourceConfigNoID generates config for an allocation without an explicit ID.
+func testAccAllocationResourceConfigNoID(poolName string, prefixLength int) string {
+	return fmt.Sprintf(`
+resource "tfipam_pool" "test" {
+  name = %[1]q
+  cidrs = ["10.0.0.0/16"]
+}
+
+resource "tfipam_allocation" "test" {
+  pool_name     = tfipam_pool.test.name
+  prefix_length = %[2]d
+}
+`, poolName, prefixLength)
+}
+
+// testAccAllocationResourceConfigDuplicateID generates config for two allocations with the same id, the
+// second in the pool named by secondPool.
+func testAccAllocationResourceConfigDuplicateID(pool1, pool2, allocID, secondPool string) string {
+	return fmt.Sprintf(`
+resource "tfipam_pool" "pool1" {
+  name = %[1]q
+  cidrs = ["10.0.0.0/16"]
+}
+
+resource "tfipam_pool" "pool2" {
+  name = %[2]q
+  cidrs = ["10.1.0.0/16"]
+}
+
+resource "tfipam_allocation" "first" {
+  id            = %[3]q
+  pool_name     = tfipam_pool.pool1.name
+  prefix_length = 24
+}
+
+resource "tfipam_allocation" "second" {
+  id            = %[3]q
+  pool_name     = tfipam_pool.%[4]s.name
+  prefix_length = 24
+  depends_on    = [tfipam_allocation.first]
+}
+`, pool1, pool2, allocID, secondPool)
+}
+
+// testAccAllocationResourceConfigPrefixLimits generates config for a pool with allowed prefix lengths.
+func testAccAllocationResourceConfigPrefixLimits(poolName string, minPrefix, maxPrefix, prefixLength int) string {
+	return fmt.Sprintf(`
+resource "tfipam_pool" "test" {
+  name              = %[1]q
+  cidrs             = ["10.0.0.0/16"]
+  min_prefix_length = %[2]d
+  max_prefix_length = %[3]d
+}
+
+resource "tfipam_allocation" "test" {
+  id            = "limits-alloc"
+  pool_name     = tfipam_pool.test.name
+  prefix_length = %[4]d
+}
+`, poolName, minPrefix, maxPrefix, prefixLength)
+}
+
+// testAccAllocationResourceConfigDefaultPrefixLength generates config with a pool with the default prefix
+// length, an allocation relying on it and one that sets its own /28.
+func testAccAllocationResourceConfigDefaultPrefixLength(poolName string, defaultPrefix int) string {
+	return fmt.Sprintf(`
+resource "tfipam_pool" "test" {
+  name                  = %[1]q
+  cidrs                 = ["10.0.0.0/16"]
+  default_prefix_length = %[2]d
+}
+
+resource "tfipam_allocation" "inherited" {
+  id        = "default-prefix-inherited"
+  pool_name = tfipam_pool.test.name
+}
+
+resource "tfipam_allocation" "explicit" {
+  id            = "default-prefix-explicit"
+  pool_name     = tfipam_pool.test.name
+  prefix_length = 28
+
+  depends_on = [tfipam_allocation.inherited]
+}
+`, poolName, defaultPrefix)
+}
+
+// testAccAllocationResourceConfigAlignment generates config with an unaligned allocation followed by an aligned /28.
+func testAccAllocationResourceConfigAlignment(poolName string, alignment int) string {
+	return fmt.Sprintf(`
+resource "tfipam_pool" "test" {
+  name  = %[1]q
+  cidrs = ["10.0.0.0/16"]
+}
+
+resource "tfipam_allocation" "unaligned" {
+  id            = "unaligned-alloc"
+  pool_name     = tfipam_pool.test.name
+  prefix_length = 28
+}
+
+resource "tfipam_allocation" "aligned" {
+  id            = "aligned-alloc"
+  pool_name     = tfipam_pool.test.name
+  prefix_length = 28
+  alignment     = %[2]d
+
+  depends_on = [tfipam_allocation.unaligned]
+}
+`, poolName, alignment)
+}
+
+// testAccAllocationResourceConfigRequested generates config with an automatic /24 at the start of
+// the pool, followed by an allocation of the requested CIDR.
+func testAccAllocationResourceConfigRequested(poolName, requestedCIDR string, allowOverlap bool) string {
+	return fmt.Sprintf(`
+resource "tfipam_pool" "test" {
+  name  = %[1]q
+  cidrs = ["10.0.0.0/16"]
+}
+
+resource "tfipam_allocation" "existing" {
+  id            = "existing-alloc"
+  pool_name     = tfipam_pool.test.name
+  prefix_length = 24
+}
+
+resource "tfipam_allocation" "requested" {
+  id             = "requested-alloc"
+  pool_name      = tfipam_pool.test.name
+  requested_cidr = %[2]q
+  allow_overlap  = %[3]t
+
+  depends_on = [tfipam_allocation.existing]
+}
+`, poolName, requestedCIDR, allowOverlap)
+}
+
+// testAccAllocationResourceConfigGloballyReserved generates provider config with a globally reserved CIDR and a /24 pool containing it.
+func testAccAllocationResourceConfigGloballyReserved(poolName, reservedCIDR string) string {
+	return fmt.Sprintf(`
+provider "tfipam" {
+  globally_reserved_cidrs = [%[2]q]
+}
+
+resource "tfipam_pool" "test" {
+  name  = %[1]q
+  cidrs = ["10.0.0.0/24"]
+}
+`, poolName, reservedCIDR)
+}
+
+// testAccAllocationResourceConfigIPv6MinPrefix generates provider config guarding IPv6 prefixes shorter than
+// /48, and a /33 allocation from a /32 pool.
+func testAccAllocationResourceConfigIPv6MinPrefix(allowShort bool) string {
+	return fmt.Sprintf(`
+provider "tfipam" {
+  ipv6_min_prefix = 48
+}
+
+resource "tfipam_pool" "test" {
+  name  = "ipv6-min-prefix-pool"
+  cidrs = ["2001:db8::/32"]
+}
+
+resource "tfipam_allocation" "test" {
+  id                      = "ipv6-min-prefix-alloc"
+  pool_name               = tfipam_pool.test.name
+  prefix_length           = 33
+  allow_short_ipv6_prefix = %t
+}
+`, allowShort)
+}
+
+// testAccAllocationResourceConfigVerifyInPool generates provider config verifying allocations are in their
+// pool, and a /26 allocation from a pool of the given CIDR that doesn't depend on it.
+func testAccAllocationResourceConfigVerifyInPool(poolCIDR string) string {
+	return fmt.Sprintf(`
+provider "tfipam" {
+  verify_allocations_in_pool = true
+}
+
+resource "tfipam_pool" "test" {
+  name  = "verify-in-pool"
+  cidrs = [%[1]q]
+}
+
+resource "tfipam_allocation" "test" {
+  id            = "verify-in-pool-alloc"
+  pool_name     = "verify-in-pool"
+  prefix_length = 26
+
+  depends_on = [tfipam_pool.test]
+}
+`, poolCIDR)
+}
+
+// testAccAllocationResourceConfigAutoExpand generates config with a /28 pool using the given auto expand
+// settings next to a pool holding the following /28, and two /28 allocations from the first pool.
+func testAccAllocationResourceConfigAutoExpand(autoExpand string) string {
+	return fmt.Sprintf(`
+resource "tfipam_pool" "test" {
+  name  = "auto-expand-pool"
+  cidrs = ["10.0.0.0/28"]
+  %s
+}
+
+resource "tfipam_pool" "neighbour" {
+  name  = "auto-expand-neighbour"
+  cidrs = ["10.0.0.16/28"]
+}
+
+resource "tfipam_allocation" "first" {
+  id            = "auto-expand-first"
+  pool_name     = tfipam_pool.test.name
+  prefix_length = 28
+}
+
+resource "tfipam_allocation" "second" {
+  id            = "auto-expand-second"
+  pool_name     = tfipam_pool.test.name
+  prefix_length = 28
+
+  depends_on = [tfipam_allocation.first, tfipam_pool.neighbour]
+}
+`, autoExpand)
+}
+
+// testAccAllocationResourceConfigSkipBoundary generates config for an allocation from a pool that skips
+// its boundary subnets.
+func testAccAllocationResourceConfigSkipBoundary(prefixLength int) string {
+	return fmt.Sprintf(`
+resource "tfipam_pool" "test" {
+  name                  = "skip-boundary-pool"
+  cidrs                 = ["10.0.0.0/16"]
+  skip_boundary_subnets = true
+}
+
+resource "tfipam_allocation" "test" {
+  id            = "skip-boundary-alloc"
+  pool_name     = tfipam_pool.test.name
+  prefix_length = %d
+}
+`, prefixLength)
+}
+
+// testAccAllocationResourceConfigBlockIndex has three /24 allocations made one after the other, and a /26
+// from a second pool CIDR starting at a /26 boundary.
+const testAccAllocationResourceConfigBlockIndex = `
+resource "tfipam_pool" "test" {
+  name  = "block-index-pool"
+  cidrs = ["10.0.0.0/16", "10.1.0.0/24"]
+}
+
+resource "tfipam_allocation" "first" {
+  id            = "block-index-first"
+  pool_name     = tfipam_pool.test.name
+  prefix_length = 24
+}
+
+resource "tfipam_allocation" "second" {
+  id            = "block-index-second"
+  pool_name     = tfipam_pool.test.name
+  prefix_length = 24
+
+  depends_on = [tfipam_allocation.first]
+}
+
+resource "tfipam_allocation" "third" {
+  id            = "block-index-third"
+  pool_name     = tfipam_pool.test.name
+  prefix_length = 24
+
+  depends_on = [tfipam_allocation.second]
+}
+
+resource "tfipam_allocation" "small" {
+  id             = "block-index-small"
+  pool_name      = tfipam_pool.test.name
+  requested_cidr = "10.1.0.64/26"
+}
+`
+
+// testAccAllocationResourceConfigIDTemplate generates provider config with an allocation id template and a
+// pool with two allocations without ids, and optionally one with an explicit id followed by another without.
+func testAccAllocationResourceConfigIDTemplate(withExplicit bool) string {
+	config := `
+provider "tfipam" {
+  allocation_id_template = "alloc-$${pool}-$${index}"
+}
+
+resource "tfipam_pool" "test" {
+  name  = "id-template-pool"
+  cidrs = ["10.0.0.0/24"]
+}
+
+resource "tfipam_allocation" "first" {
+  pool_name     = tfipam_pool.test.name
+  prefix_length = 28
+}
+
+resource "tfipam_allocation" "second" {
+  pool_name     = tfipam_pool.test.name
+  prefix_length = 28
+
+  depends_on = [tfipam_allocation.first]
+}
+`
+	if withExplicit {
+		config += `
+resource "tfipam_allocation" "explicit" {
+  id            = "alloc-id-template-pool-4"
+  pool_name     = tfipam_pool.test.name
+  prefix_length = 28
+
+  depends_on = [tfipam_allocation.second]
+}
+
+resource "tfipam_allocation" "fourth" {
+  pool_name     = tfipam_pool.test.name
+  prefix_length = 28
+
+  depends_on = [tfipam_allocation.explicit]
+}
+`
+	}
+	return config
+}
+
+// testAccAllocationResourceConfigSubnetCount generates config with a /16 pool and an allocation with room for
+// each count of /28 subnets, named by its key.
+func testAccAllocationResourceConfigSubnetCount(poolName string, counts map[string]int) string {
+	config := fmt.Sprintf(`
+resource "tfipam_pool" "test" {
+  name  = %q
+  cidrs = ["10.0.0.0/16"]
+}
+`, poolName)
+	for name, count := range counts {
+		config += fmt.Sprintf(`
+resource "tfipam_allocation" %[1]q {
+  id                   = "%[2]s-%[1]s"
+  pool_name            = tfipam_pool.test.name
+  subnet_count         = %[3]d
+  subnet_prefix_length = 28
+}
+`, name, poolName, count)
+	}
+	return config
+}
+
+// testAccAllocationResourceConfigFromCIDR generates config with a two CIDR pool and a /26 allocated from fromCIDR.
+func testAccAllocationResourceConfigFromCIDR(poolName, fromCIDR string) string {
+	return fmt.Sprintf(`
+resource "tfipam_pool" "test" {
+  name  = %[1]q
+  cidrs = ["10.0.0.0/24", "192.168.0.0/24"]
+}
+
+resource "tfipam_allocation" "test" {
+  id            = "from-cidr-alloc"
+  pool_name     = tfipam_pool.test.name
+  prefix_length = 26
+  from_cidr     = %[2]q
+}
+`, poolName, fromCIDR)
+}
+
 // testAccAllocationResourceConfigNoPool generates config without creating the pool first.
 func testAccAllocationResourceConfigNoPool(poolName, allocID string, prefixLength int) string {
 	return fmt.Sprintf(`
@@ -560,6 +2746,28 @@ resource "tfipam_allocation" "test" {
 		}())
 }
 
+// testAccAllocationResourceConfigMove generates config with two pools where the target pool
+// contains the source pool's range, and an allocation in the pool referenced by poolRef.
+func testAccAllocationResourceConfigMove(poolRef string) string {
+	return fmt.Sprintf(`
+resource "tfipam_pool" "source" {
+  name  = "move-source-pool"
+  cidrs = ["10.0.0.0/16"]
+}
+
+resource "tfipam_pool" "target" {
+  name  = "move-target-pool"
+  cidrs = ["10.0.0.0/8"]
+}
+
+resource "tfipam_allocation" "test" {
+  id            = "move-alloc"
+  pool_name     = %[1]s
+  prefix_length = 24
+}
+`, poolRef)
+}
+
 // testAccAllocationResourceConfigIPv6 generates config for IPv6 allocation.
 func testAccAllocationResourceConfigIPv6(poolName, allocID string, prefixLength int) string {
 	return fmt.Sprintf(`
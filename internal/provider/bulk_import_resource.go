@@ -0,0 +1,449 @@
+package provider
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/hashicorp/go-uuid"
+	"github.com/hashicorp/terraform-plugin-framework/path"
+	"github.com/hashicorp/terraform-plugin-framework/resource"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/booldefault"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/boolplanmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/planmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/stringplanmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+
+	"terraform-provider-tfipam/internal/provider/storage"
+)
+
+var _ resource.Resource = &BulkImportResource{}
+
+func NewBulkImportResource() resource.Resource {
+	return &BulkImportResource{}
+}
+
+// BulkImportResource records a batch of existing allocations, e.g. from a spreadsheet of legacy
+// subnets, in storage when it's created. It's one-shot: the allocations aren't managed by it afterwards.
+type BulkImportResource struct {
+	provider *IpamProvider
+}
+
+type BulkImportResourceModel struct {
+	Content     types.String `tfsdk:"content"`
+	Format      types.String `tfsdk:"format"`
+	Strict      types.Bool   `tfsdk:"strict"`
+	ImportedIDs types.List   `tfsdk:"imported_ids"`
+	Errors      types.List   `tfsdk:"errors"`
+}
+
+// bulkImportRow is one allocation of a bulk import.
+type bulkImportRow struct {
+	ID            string `json:"id"`
+	PoolName      string `json:"pool_name"`
+	AllocatedCIDR string `json:"allocated_cidr"`
+	PrefixLength  int    `json:"prefix_length"`
+
+	// a value of the row that couldn't be parsed, reported like any other invalid row
+	parseErr error
+}
+
+// bulkImportAllocation is a validated row waiting to be saved, with its 1-based row number for errors.
+type bulkImportAllocation struct {
+	row        int
+	allocation *storage.Allocation
+}
+
+func (r *BulkImportResource) Metadata(ctx context.Context, req resource.MetadataRequest, resp *resource.MetadataResponse) {
+	resp.TypeName = req.ProviderTypeName + "_bulk_import"
+}
+
+func (r *BulkImportResource) Schema(ctx context.Context, req resource.SchemaRequest, resp *resource.SchemaResponse) {
+	resp.Schema = schema.Schema{
+		MarkdownDescription: "IPAM bulk import resource for recording many existing allocations at once, e.g. from a legacy inventory",
+
+		Attributes: map[string]schema.Attribute{
+			"content": schema.StringAttribute{
+				Required:            true,
+				MarkdownDescription: "Allocations to import, usually read with `file()`. Each has an `id`, `pool_name`, `allocated_cidr` and optional `prefix_length`. A UUID is generated for an empty `id`. Changing it runs the import again, rows that were already imported are skipped, rows without an `id` are matched by their CIDR",
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.RequiresReplace(),
+				},
+			},
+			"format": schema.StringAttribute{
+				Required:            true,
+				MarkdownDescription: "Format of `content`, either `json` for an array of objects or `csv` with a header row naming the columns",
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.RequiresReplace(),
+				},
+			},
+			"strict": schema.BoolAttribute{
+				Optional:            true,
+				Computed:            true,
+				Default:             booldefault.StaticBool(false),
+				MarkdownDescription: "Fail without importing anything when any row is invalid. By default the valid rows are imported and the others are listed in `errors`",
+				PlanModifiers: []planmodifier.Bool{
+					boolplanmodifier.RequiresReplace(),
+				},
+			},
+			"imported_ids": schema.ListAttribute{
+				Computed:            true,
+				ElementType:         types.StringType,
+				MarkdownDescription: "Ids of the allocations that are in storage after the import, including rows that were already imported",
+			},
+			"errors": schema.ListAttribute{
+				Computed:            true,
+				ElementType:         types.StringType,
+				MarkdownDescription: "Why each row that wasn't imported was rejected, prefixed with its row number",
+			},
+		},
+	}
+}
+
+func (r *BulkImportResource) Configure(ctx context.Context, req resource.ConfigureRequest, resp *resource.ConfigureResponse) {
+	if req.ProviderData == nil {
+		return
+	}
+
+	provider, ok := req.ProviderData.(*IpamProvider)
+	if !ok {
+		resp.Diagnostics.AddError(
+			"Unexpected Resource Configure Type",
+			fmt.Sprintf("Expected *IpamProvider, got: %T", req.ProviderData),
+		)
+		return
+	}
+
+	r.provider = provider
+}
+
+func (r *BulkImportResource) Create(ctx context.Context, req resource.CreateRequest, resp *resource.CreateResponse) {
+	var data BulkImportResourceModel
+
+	resp.Diagnostics.Append(req.Plan.Get(ctx, &data)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	rows, err := parseBulkImportRows(data.Format.ValueString(), data.Content.ValueString())
+	if err != nil {
+		resp.Diagnostics.AddAttributeError(
+			path.Root("content"),
+			"Invalid Bulk Import Content",
+			fmt.Sprintf("Could not parse the %s content: %s", data.Format.ValueString(), err),
+		)
+		return
+	}
+
+	// validate every row before writing anything, so strict imports are all or nothing
+	rowErrors := []string{}
+	importedIDs := []string{}
+	var pending []bulkImportAllocation
+	seenIDs := map[[2]string]bool{}
+	batch := map[string][]*net.IPNet{}
+	for i, row := range rows {
+		allocation, alreadyImported, err := r.checkRow(ctx, row, seenIDs, batch)
+		if err != nil {
+			rowErrors = append(rowErrors, fmt.Sprintf("row %d: %s", i+1, err))
+			continue
+		}
+		if alreadyImported {
+			importedIDs = append(importedIDs, allocation.ID)
+			continue
+		}
+		pending = append(pending, bulkImportAllocation{row: i + 1, allocation: allocation})
+	}
+
+	strict := data.Strict.ValueBool()
+	if strict && len(rowErrors) > 0 {
+		resp.Diagnostics.AddError(
+			"Bulk Import Failed",
+			fmt.Sprintf("%d of %d rows are invalid and strict is set, nothing was imported:\n%s", len(rowErrors), len(rows), strings.Join(rowErrors, "\n")),
+		)
+		return
+	}
+
+	for _, p := range pending {
+		if err := r.saveRow(ctx, p.allocation); err != nil {
+			// a read-only backend would reject every other row too, so it stops the import even when not strict
+			if strict || errors.Is(err, storage.ErrReadOnly) {
+				addStorageWriteError(
+					&resp.Diagnostics,
+					"Bulk Import Failed",
+					fmt.Sprintf("row %d: %s. The rows before it were imported: %s", p.row, err, strings.Join(importedIDs, ", ")),
+					err,
+				)
+				return
+			}
+			rowErrors = append(rowErrors, fmt.Sprintf("row %d: %s", p.row, err))
+			continue
+		}
+		importedIDs = append(importedIDs, p.allocation.ID)
+	}
+
+	if len(rowErrors) > 0 {
+		resp.Diagnostics.AddWarning(
+			"Bulk Import Rows Skipped",
+			fmt.Sprintf("%d of %d rows were not imported:\n%s", len(rowErrors), len(rows), strings.Join(rowErrors, "\n")),
+		)
+	}
+
+	tflog.Info(ctx, "bulk imported allocations", map[string]any{
+		"rows":     len(rows),
+		"imported": len(importedIDs),
+		"rejected": len(rowErrors),
+	})
+
+	imported, diag := types.ListValueFrom(ctx, types.StringType, importedIDs)
+	resp.Diagnostics.Append(diag...)
+	importErrors, diag := types.ListValueFrom(ctx, types.StringType, rowErrors)
+	resp.Diagnostics.Append(diag...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	data.ImportedIDs = imported
+	data.Errors = importErrors
+
+	resp.Diagnostics.Append(resp.State.Set(ctx, &data)...)
+}
+
+func (r *BulkImportResource) Read(ctx context.Context, req resource.ReadRequest, resp *resource.ReadResponse) {
+	var data BulkImportResourceModel
+
+	// the import only happens on create, the allocations are managed independently afterwards
+	resp.Diagnostics.Append(req.State.Get(ctx, &data)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	resp.Diagnostics.Append(resp.State.Set(ctx, &data)...)
+}
+
+func (r *BulkImportResource) Update(ctx context.Context, req resource.UpdateRequest, resp *resource.UpdateResponse) {
+	// every attribute requires replacement, so there's nothing to update
+	var data BulkImportResourceModel
+
+	resp.Diagnostics.Append(req.Plan.Get(ctx, &data)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	resp.Diagnostics.Append(resp.State.Set(ctx, &data)...)
+}
+
+func (r *BulkImportResource) Delete(ctx context.Context, req resource.DeleteRequest, resp *resource.DeleteResponse) {
+	// imported allocations stay in storage, releasing them is up to whoever manages them now
+	tflog.Trace(ctx, "deleted bulk import resource")
+}
+
+// checkRow validates a row against storage and the earlier rows of the batch, and returns the allocation to
+// save. alreadyImported is set when storage already has the same allocation, e.g. when the import runs again.
+func (r *BulkImportResource) checkRow(ctx context.Context, row bulkImportRow, seenIDs map[[2]string]bool, batch map[string][]*net.IPNet) (*storage.Allocation, bool, error) {
+	if row.parseErr != nil {
+		return nil, false, row.parseErr
+	}
+	if row.PoolName == "" {
+		return nil, false, fmt.Errorf("pool_name is required")
+	}
+	if row.AllocatedCIDR == "" {
+		return nil, false, fmt.Errorf("allocated_cidr is required")
+	}
+
+	_, cidrNet, err := net.ParseCIDR(row.AllocatedCIDR)
+	if err != nil {
+		return nil, false, fmt.Errorf("allocated_cidr '%s' is not a valid CIDR", row.AllocatedCIDR)
+	}
+	if cidrNet.String() != row.AllocatedCIDR {
+		return nil, false, fmt.Errorf("allocated_cidr %s is not a network address, did you mean %s", row.AllocatedCIDR, cidrNet)
+	}
+	prefixLength, _ := cidrNet.Mask.Size()
+	if row.PrefixLength != 0 && row.PrefixLength != prefixLength {
+		return nil, false, fmt.Errorf("prefix_length %d doesn't match allocated_cidr %s", row.PrefixLength, cidrNet)
+	}
+
+	// ids are unique within a pool, so the same id can be imported into several pools
+	if row.ID != "" {
+		poolID := [2]string{row.PoolName, row.ID}
+		if seenIDs[poolID] {
+			return nil, false, fmt.Errorf("id %s appears more than once for pool %s", row.ID, row.PoolName)
+		}
+		seenIDs[poolID] = true
+	}
+
+	allocation := &storage.Allocation{
+		ID:            row.ID,
+		PoolName:      row.PoolName,
+		AllocatedCIDR: cidrNet.String(),
+		PrefixLength:  prefixLength,
+	}
+
+	if row.ID != "" {
+		existing, err := r.provider.findAllocationByID(ctx, row.PoolName, row.ID)
+		if err == nil {
+			if existing.AllocatedCIDR == allocation.AllocatedCIDR {
+				return allocation, true, nil
+			}
+			return nil, false, fmt.Errorf("allocation %s already exists with %s in pool %s", row.ID, existing.AllocatedCIDR, existing.PoolName)
+		}
+		if !errors.Is(err, storage.ErrNotFound) {
+			return nil, false, fmt.Errorf("failed to read allocation %s: %w", row.ID, err)
+		}
+	}
+
+	pool, err := r.provider.storage.GetPool(ctx, row.PoolName)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to read pool %s: %w", row.PoolName, err)
+	}
+	if err := validatePoolPrefixLength(pool, prefixLength); err != nil {
+		return nil, false, err
+	}
+	if !cidrInPool(pool, cidrNet) {
+		return nil, false, fmt.Errorf("CIDR %s is not contained in any CIDR of pool %s", cidrNet, pool.Name)
+	}
+	if err := r.provider.checkGloballyReserved(cidrNet); err != nil {
+		return nil, false, err
+	}
+
+	allocations, err := r.provider.storage.ListAllocationsByPool(ctx, pool.Name)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to list allocations: %w", err)
+	}
+
+	// without an id a row is matched by its CIDR, so running the import again doesn't duplicate it
+	if row.ID == "" {
+		for _, existing := range allocations {
+			if existing.AllocatedCIDR == allocation.AllocatedCIDR {
+				allocation.ID = existing.ID
+				return allocation, true, nil
+			}
+		}
+
+		allocation.ID, err = uuid.GenerateUUID()
+		if err != nil {
+			return nil, false, fmt.Errorf("unable to generate an allocation ID: %w", err)
+		}
+	}
+	allocation.Key, err = newAllocationKey()
+	if err != nil {
+		return nil, false, err
+	}
+
+	if cidrsOverlap(cidrNet, takenCIDRs(allocations, nil)) {
+		return nil, false, fmt.Errorf("CIDR %s overlaps an existing allocation in pool %s", cidrNet, pool.Name)
+	}
+	if cidrsOverlap(cidrNet, batch[pool.Name]) {
+		return nil, false, fmt.Errorf("CIDR %s overlaps an earlier row in pool %s", cidrNet, pool.Name)
+	}
+
+	batch[pool.Name] = append(batch[pool.Name], cidrNet)
+	return allocation, false, nil
+}
+
+// saveRow saves a validated allocation, checking again that its CIDR is still free in case another
+// writer claimed it since the rows were validated.
+func (r *BulkImportResource) saveRow(ctx context.Context, allocation *storage.Allocation) error {
+	_, cidrNet, err := net.ParseCIDR(allocation.AllocatedCIDR)
+	if err != nil {
+		return fmt.Errorf("invalid CIDR %s: %w", allocation.AllocatedCIDR, err)
+	}
+
+	fields := map[string]any{
+		"id":             allocation.ID,
+		"pool_name":      allocation.PoolName,
+		"allocated_cidr": allocation.AllocatedCIDR,
+	}
+	return r.provider.retryOnConflict(ctx, "import the allocation", fields, func() error {
+		defer r.provider.lockPool(allocation.PoolName)()
+
+		allocations, err := r.provider.storage.ListAllocationsByPool(ctx, allocation.PoolName)
+		if err != nil {
+			return fmt.Errorf("failed to list allocations: %w", err)
+		}
+		if cidrsOverlap(cidrNet, takenCIDRs(allocations, nil)) {
+			return fmt.Errorf("CIDR %s overlaps an existing allocation in pool %s", cidrNet, allocation.PoolName)
+		}
+
+		if err := r.provider.storage.SaveAllocation(ctx, allocation); err != nil {
+			return fmt.Errorf("failed to save allocation: %w", err)
+		}
+		return nil
+	})
+}
+
+// parseBulkImportRows reads the rows of a bulk import. JSON content is an array of row objects, CSV content
+// has a header row naming the columns. A value that can't be parsed only invalidates its own row.
+func parseBulkImportRows(format string, content string) ([]bulkImportRow, error) {
+	switch format {
+	case "json":
+		var rows []bulkImportRow
+		if err := json.Unmarshal([]byte(content), &rows); err != nil {
+			return nil, err
+		}
+		return rows, nil
+	case "csv":
+		return parseBulkImportCSV(content)
+	default:
+		return nil, fmt.Errorf("unsupported format '%s', expected 'json' or 'csv'", format)
+	}
+}
+
+func parseBulkImportCSV(content string) ([]bulkImportRow, error) {
+	reader := csv.NewReader(strings.NewReader(content))
+	reader.TrimLeadingSpace = true
+
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, fmt.Errorf("missing header row")
+	}
+
+	columns := map[string]int{}
+	for i, name := range records[0] {
+		name = strings.TrimSpace(name)
+		switch name {
+		case "id", "pool_name", "allocated_cidr", "prefix_length":
+			columns[name] = i
+		default:
+			return nil, fmt.Errorf("unknown column '%s', expected id, pool_name, allocated_cidr and prefix_length", name)
+		}
+	}
+	for _, required := range []string{"pool_name", "allocated_cidr"} {
+		if _, ok := columns[required]; !ok {
+			return nil, fmt.Errorf("missing required column '%s'", required)
+		}
+	}
+
+	value := func(record []string, column string) string {
+		i, ok := columns[column]
+		if !ok {
+			return ""
+		}
+		return strings.TrimSpace(record[i])
+	}
+
+	rows := make([]bulkImportRow, 0, len(records)-1)
+	for _, record := range records[1:] {
+		row := bulkImportRow{
+			ID:            value(record, "id"),
+			PoolName:      value(record, "pool_name"),
+			AllocatedCIDR: value(record, "allocated_cidr"),
+		}
+		if prefixLength := value(record, "prefix_length"); prefixLength != "" {
+			row.PrefixLength, err = strconv.Atoi(prefixLength)
+			if err != nil {
+				row.parseErr = fmt.Errorf("prefix_length '%s' is not a number", prefixLength)
+			}
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
@@ -0,0 +1,96 @@
+package provider
+
+import (
+	"fmt"
+	"regexp"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-testing/helper/resource"
+	"github.com/hashicorp/terraform-plugin-testing/knownvalue"
+	"github.com/hashicorp/terraform-plugin-testing/statecheck"
+	"github.com/hashicorp/terraform-plugin-testing/tfjsonpath"
+)
+
+func TestAccBulkImportResource_CSV(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		PreCheck:                 func() { testAccPreCheck(t) },
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			// the valid rows are imported and the others are reported without failing the apply
+			{
+				Config: testAccBulkImportResourceConfig("bulk-csv-pool", "csv", false, `id,pool_name,allocated_cidr,prefix_length
+bulk-csv-a,bulk-csv-pool,10.0.0.0/26,26
+bulk-csv-b,bulk-csv-pool,10.0.0.32/27,
+bulk-csv-c,bulk-csv-pool,10.9.0.0/24,24
+bulk-csv-d,bulk-csv-pool,10.0.0.64/26,26
+`) + `
+data "tfipam_allocation" "imported" {
+  id        = "bulk-csv-d"
+  pool_name = tfipam_pool.test.name
+
+  depends_on = [tfipam_bulk_import.test]
+}
+`,
+				ConfigStateChecks: []statecheck.StateCheck{
+					statecheck.ExpectKnownValue(
+						"tfipam_bulk_import.test",
+						tfjsonpath.New("imported_ids"),
+						knownvalue.ListExact([]knownvalue.Check{
+							knownvalue.StringExact("bulk-csv-a"),
+							knownvalue.StringExact("bulk-csv-d"),
+						}),
+					),
+					statecheck.ExpectKnownValue(
+						"tfipam_bulk_import.test",
+						tfjsonpath.New("errors"),
+						knownvalue.ListExact([]knownvalue.Check{
+							knownvalue.StringRegexp(regexp.MustCompile(`^row 2: .*overlaps an earlier row`)),
+							knownvalue.StringRegexp(regexp.MustCompile(`^row 3: .*not contained`)),
+						}),
+					),
+					statecheck.ExpectKnownValue(
+						"data.tfipam_allocation.imported",
+						tfjsonpath.New("allocated_cidr"),
+						knownvalue.StringExact("10.0.0.64/26"),
+					),
+				},
+			},
+		},
+	})
+}
+
+func TestAccBulkImportResource_JSONStrict(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		PreCheck:                 func() { testAccPreCheck(t) },
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: testAccBulkImportResourceConfig("bulk-json-pool", "json", true, `[
+  {"id": "bulk-json-a", "pool_name": "bulk-json-pool", "allocated_cidr": "10.0.0.0/26"},
+  {"id": "bulk-json-b", "pool_name": "bulk-json-pool", "allocated_cidr": "10.0.0.65/26"}
+]`),
+				ExpectError: regexp.MustCompile(`(?s)Bulk Import Failed.*row 2: .*not a network address`),
+			},
+		},
+	})
+}
+
+// testAccBulkImportResourceConfig generates a pool and a bulk import of content into it.
+func testAccBulkImportResourceConfig(poolName, format string, strict bool, content string) string {
+	return fmt.Sprintf(`
+resource "tfipam_pool" "test" {
+  name  = %[1]q
+  cidrs = ["10.0.0.0/24"]
+}
+
+resource "tfipam_bulk_import" "test" {
+  format  = %[2]q
+  strict  = %[3]t
+  content = <<-EOT
+%[4]s
+EOT
+
+  depends_on = [tfipam_pool.test]
+}
+`, poolName, format, strict, content)
+}
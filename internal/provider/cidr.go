@@ -0,0 +1,640 @@
+package provider
+
+import (
+	"bytes"
+	"fmt"
+	"math/big"
+	"math/bits"
+	"net"
+	"sort"
+	"strings"
+
+	"terraform-provider-tfipam/internal/provider/storage"
+)
+
+// subtractCIDRs removes the exclusion CIDRs from the supernet and returns the smallest
+// set of CIDR blocks that covers the remaining address space, ordered by address.
+// Every exclusion must be the same address family as the supernet and fit inside it.
+func subtractCIDRs(supernet *net.IPNet, exclusions []*net.IPNet) ([]*net.IPNet, error) {
+	superPrefixLen, superBits := supernet.Mask.Size()
+
+	for _, exclusion := range exclusions {
+		exclusionPrefixLen, exclusionBits := exclusion.Mask.Size()
+		if exclusionBits != superBits {
+			return nil, fmt.Errorf("CIDR %s is not the same address family as %s", exclusion, supernet)
+		}
+		if !supernet.Contains(exclusion.IP) || exclusionPrefixLen < superPrefixLen {
+			return nil, fmt.Errorf("CIDR %s is not contained in %s", exclusion, supernet)
+		}
+	}
+
+	remaining := []*net.IPNet{supernet}
+	for _, exclusion := range exclusions {
+		var next []*net.IPNet
+		for _, block := range remaining {
+			next = append(next, subtractCIDR(block, exclusion)...)
+		}
+		remaining = next
+	}
+
+	return remaining, nil
+}
+
+// subtractCIDR removes a single exclusion from a block by splitting the block in half
+// until the halves either fall entirely inside or entirely outside of the exclusion.
+func subtractCIDR(block *net.IPNet, exclusion *net.IPNet) []*net.IPNet {
+	blockPrefixLen, _ := block.Mask.Size()
+	exclusionPrefixLen, _ := exclusion.Mask.Size()
+
+	// no overlap, block is untouched
+	if !block.Contains(exclusion.IP) && !exclusion.Contains(block.IP) {
+		return []*net.IPNet{block}
+	}
+
+	// block is entirely covered by the exclusion
+	if exclusionPrefixLen <= blockPrefixLen {
+		return nil
+	}
+
+	lower, upper := splitCIDR(block)
+	return append(subtractCIDR(lower, exclusion), subtractCIDR(upper, exclusion)...)
+}
+
+// splitCIDR divides a CIDR block into its two halves.
+func splitCIDR(block *net.IPNet) (*net.IPNet, *net.IPNet) {
+	prefixLen, bits := block.Mask.Size()
+	mask := net.CIDRMask(prefixLen+1, bits)
+
+	lowerIP := make(net.IP, len(block.IP))
+	copy(lowerIP, block.IP)
+
+	// the upper half has the first host bit of the original block set
+	upperIP := make(net.IP, len(block.IP))
+	copy(upperIP, block.IP)
+	upperIP[prefixLen/8] |= 0x80 >> uint(prefixLen%8)
+
+	return &net.IPNet{IP: lowerIP, Mask: mask}, &net.IPNet{IP: upperIP, Mask: mask}
+}
+
+// parseIPRange parses a "start-end" address range. Both ends are inclusive, must be the
+// same address family and start can't be after end.
+func parseIPRange(ipRange string) (net.IP, net.IP, error) {
+	startStr, endStr, ok := strings.Cut(ipRange, "-")
+	if !ok {
+		return nil, nil, fmt.Errorf("range %s is not in start-end format", ipRange)
+	}
+
+	start := net.ParseIP(strings.TrimSpace(startStr))
+	if start == nil {
+		return nil, nil, fmt.Errorf("range %s has an invalid start address", ipRange)
+	}
+	end := net.ParseIP(strings.TrimSpace(endStr))
+	if end == nil {
+		return nil, nil, fmt.Errorf("range %s has an invalid end address", ipRange)
+	}
+
+	// IPv4 addresses are kept in their 4 byte form so the masks built from them match
+	if start4, end4 := start.To4(), end.To4(); start4 != nil || end4 != nil {
+		if start4 == nil || end4 == nil {
+			return nil, nil, fmt.Errorf("range %s mixes IPv4 and IPv6 addresses", ipRange)
+		}
+		start, end = start4, end4
+	}
+
+	if new(big.Int).SetBytes(start).Cmp(new(big.Int).SetBytes(end)) > 0 {
+		return nil, nil, fmt.Errorf("range %s starts after it ends", ipRange)
+	}
+
+	return start, end, nil
+}
+
+// rangeCIDRs returns the smallest set of CIDR blocks that exactly covers the address range
+// from start to end, ordered by address. Both addresses must be the same length.
+func rangeCIDRs(start, end net.IP) []*net.IPNet {
+	bits := len(start) * 8
+	current := new(big.Int).SetBytes(start)
+	last := new(big.Int).SetBytes(end)
+
+	var blocks []*net.IPNet
+	for current.Cmp(last) <= 0 {
+		// grow the block while its network address stays on current and it ends inside the range
+		hostBits := 0
+		for hostBits < bits && current.Bit(hostBits) == 0 {
+			blockEnd := new(big.Int).Lsh(big.NewInt(1), uint(hostBits+1))
+			blockEnd.Add(blockEnd, current).Sub(blockEnd, big.NewInt(1))
+			if blockEnd.Cmp(last) > 0 {
+				break
+			}
+			hostBits++
+		}
+
+		ip := make(net.IP, len(start))
+		current.FillBytes(ip)
+		blocks = append(blocks, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits-hostBits, bits)})
+
+		current.Add(current, new(big.Int).Lsh(big.NewInt(1), uint(hostBits)))
+	}
+
+	return blocks
+}
+
+// poolCIDRs returns every CIDR block the pool allocates from, its CIDRs and the ones it was expanded
+// with followed by the blocks covering its ranges. Ranges that fail to parse are skipped.
+func poolCIDRs(pool *storage.Pool) []string {
+	if len(pool.Ranges) == 0 && len(pool.ExpandedCIDRs) == 0 {
+		return pool.CIDRs
+	}
+
+	cidrs := append([]string{}, pool.CIDRs...)
+	cidrs = append(cidrs, pool.ExpandedCIDRs...)
+	for _, ipRange := range pool.Ranges {
+		start, end, err := parseIPRange(ipRange)
+		if err != nil {
+			continue
+		}
+		for _, block := range rangeCIDRs(start, end) {
+			cidrs = append(cidrs, block.String())
+		}
+	}
+	return cidrs
+}
+
+// boundarySubnets returns the first and last block of the prefix length in each IPv4 CIDR of the pool,
+// its all-zeros and all-ones subnets, when the pool skips them. The search treats them as taken. A block
+// as big as its CIDR isn't a subnet of it, so it's never skipped.
+func boundarySubnets(pool *storage.Pool, prefixLength int) []*net.IPNet {
+	if !pool.SkipBoundarySubnets {
+		return nil
+	}
+
+	var boundaries []*net.IPNet
+	for _, cidrs := range [][]string{pool.CIDRs, pool.ExpandedCIDRs} {
+		for _, cidr := range cidrs {
+			_, cidrNet, err := net.ParseCIDR(cidr)
+			if err != nil {
+				continue
+			}
+			ones, bits := cidrNet.Mask.Size()
+			if bits != 32 || prefixLength <= ones || prefixLength > bits {
+				continue
+			}
+			mask := net.CIDRMask(prefixLength, bits)
+			boundaries = append(boundaries,
+				&net.IPNet{IP: cidrNet.IP, Mask: mask},
+				&net.IPNet{IP: getLastIPInCIDR(cidrNet).Mask(mask), Mask: mask},
+			)
+		}
+	}
+	return boundaries
+}
+
+// prioritizedPoolCIDRs returns the pool's CIDR blocks in the order allocations fill them, by ascending
+// priority and then in the order poolCIDRs lists them.
+func prioritizedPoolCIDRs(pool *storage.Pool) []string {
+	cidrs := poolCIDRs(pool)
+	if len(pool.CIDRPriorities) == 0 {
+		return cidrs
+	}
+
+	cidrs = append([]string{}, cidrs...)
+	sort.SliceStable(cidrs, func(i, j int) bool {
+		return pool.CIDRPriorities[cidrs[i]] < pool.CIDRPriorities[cidrs[j]]
+	})
+	return cidrs
+}
+
+// reverseDNSZones returns the reverse DNS zones covering the CIDR, ordered by address. Zones are
+// delegated on octet boundaries for IPv4 and nibble boundaries for IPv6, so a prefix between two
+// boundaries is covered by several zones. An IPv4 prefix longer than /24 returns the /24 zone it's in.
+func reverseDNSZones(cidrNet *net.IPNet) []string {
+	prefixLen, bits := cidrNet.Mask.Size()
+
+	// IPv4 zones are labelled per octet, IPv6 zones per nibble
+	labelBits, suffix := 8, "in-addr.arpa"
+	ip := cidrNet.IP.To4()
+	if bits == 128 {
+		labelBits, suffix = 4, "ip6.arpa"
+		ip = cidrNet.IP.To16()
+	}
+
+	zoneLen := (prefixLen + labelBits - 1) / labelBits * labelBits
+	if bits == 32 && zoneLen > 24 {
+		zoneLen = 24
+	}
+
+	count := 1
+	if zoneLen > prefixLen {
+		count = 1 << (zoneLen - prefixLen)
+	}
+
+	labelCount := zoneLen / labelBits
+	zones := make([]string, 0, count)
+	for i := 0; i < count; i++ {
+		labels := make([]string, 0, labelCount+1)
+		for n := labelCount - 1; n >= 0; n-- {
+			var value int
+			if bits == 128 {
+				value = int(ip[n/2]>>4) & 0xf
+				if n%2 == 1 {
+					value = int(ip[n/2]) & 0xf
+				}
+			} else {
+				value = int(ip[n])
+			}
+			// the prefix is aligned, so the zone index only ever lands in the last label's free bits
+			if n == labelCount-1 {
+				value += i
+			}
+
+			if bits == 128 {
+				labels = append(labels, fmt.Sprintf("%x", value))
+			} else {
+				labels = append(labels, fmt.Sprintf("%d", value))
+			}
+		}
+		zones = append(zones, strings.Join(append(labels, suffix), "."))
+	}
+
+	return zones
+}
+
+// cidrNetwork returns the network of a CIDR, e.g. 10.0.5.0/24 for 10.0.5.3/24, or an empty string when
+// it isn't a valid CIDR.
+func cidrNetwork(cidr string) string {
+	_, network, err := net.ParseCIDR(cidr)
+	if err != nil {
+		return ""
+	}
+	return network.String()
+}
+
+// cidrAddressDetails returns the network address, broadcast address and number of addresses in a CIDR.
+// IPv6 has no broadcast address, so it's returned as an empty string for IPv6 CIDRs.
+func cidrAddressDetails(cidr string) (string, string, string, error) {
+	_, cidrNet, err := net.ParseCIDR(cidr)
+	if err != nil {
+		return "", "", "", err
+	}
+
+	prefixLen, bits := cidrNet.Mask.Size()
+	addressCount := new(big.Int).Lsh(big.NewInt(1), uint(bits-prefixLen))
+
+	broadcast := ""
+	if bits == 32 {
+		broadcast = getLastIPInCIDR(cidrNet).String()
+	}
+
+	return cidrNet.IP.String(), broadcast, addressCount.String(), nil
+}
+
+// blockIndex returns the offset of the CIDR from the start of the pool CIDR containing it, counted in
+// blocks of the CIDR's size, e.g. 2 for 10.0.2.0/24 in 10.0.0.0/16. It returns false when none of the
+// pool CIDRs contains the CIDR, or the index doesn't fit an int64 in a large IPv6 pool.
+func blockIndex(cidr string, poolCIDRs []string) (int64, bool) {
+	_, cidrNet, err := net.ParseCIDR(cidr)
+	if err != nil {
+		return 0, false
+	}
+	prefixLen, bits := cidrNet.Mask.Size()
+
+	for _, poolCIDR := range poolCIDRs {
+		_, poolNet, err := net.ParseCIDR(poolCIDR)
+		if err != nil {
+			continue
+		}
+		poolPrefixLen, poolBits := poolNet.Mask.Size()
+		if poolBits != bits || poolPrefixLen > prefixLen || !poolNet.Contains(cidrNet.IP) {
+			continue
+		}
+
+		offset := new(big.Int).Sub(new(big.Int).SetBytes(cidrNet.IP), new(big.Int).SetBytes(poolNet.IP))
+		index := offset.Rsh(offset, uint(bits-prefixLen))
+		if !index.IsInt64() {
+			return 0, false
+		}
+		return index.Int64(), true
+	}
+	return 0, false
+}
+
+// ipVersion returns 4 or 6 for the address family of the CIDR, or 0 when it isn't a valid CIDR.
+func ipVersion(cidr string) int64 {
+	_, cidrNet, err := net.ParseCIDR(cidr)
+	if err != nil {
+		return 0
+	}
+	if _, bits := cidrNet.Mask.Size(); bits == 128 {
+		return 6
+	}
+	return 4
+}
+
+// poolIPVersion returns 4 or 6 when every CIDR the pool allocates from is in that address family,
+// or 0 for a pool that mixes families or has no CIDRs.
+func poolIPVersion(pool *storage.Pool) int64 {
+	var version int64
+	for _, cidr := range poolCIDRs(pool) {
+		cidrVersion := ipVersion(cidr)
+		if cidrVersion == 0 {
+			continue
+		}
+		if version != 0 && cidrVersion != version {
+			return 0
+		}
+		version = cidrVersion
+	}
+	return version
+}
+
+// poolUtilization returns the total number of addresses in the pool's CIDRs and the number
+// of those addresses taken by allocations, counting only CIDRs with the given address length
+// (32 for IPv4, 128 for IPv6) so the two families are never mixed in one figure.
+func poolUtilization(pool *storage.Pool, allocations []storage.Allocation, bits int) (*big.Int, *big.Int) {
+	total := new(big.Int)
+	var poolNets []*net.IPNet
+	for _, cidr := range poolCIDRs(pool) {
+		_, poolNet, err := net.ParseCIDR(cidr)
+		if err != nil {
+			continue
+		}
+		prefixLen, poolBits := poolNet.Mask.Size()
+		if poolBits != bits {
+			continue
+		}
+		total.Add(total, new(big.Int).Lsh(big.NewInt(1), uint(bits-prefixLen)))
+		poolNets = append(poolNets, poolNet)
+	}
+
+	used := new(big.Int)
+	for _, alloc := range allocations {
+		for _, cidr := range alloc.CIDRs() {
+			_, allocNet, err := net.ParseCIDR(cidr)
+			if err != nil {
+				continue
+			}
+			prefixLen, allocBits := allocNet.Mask.Size()
+			if allocBits != bits {
+				continue
+			}
+			// allocations left over from a CIDR that was removed from the pool don't count against it
+			for _, poolNet := range poolNets {
+				if poolNet.Contains(allocNet.IP) {
+					used.Add(used, new(big.Int).Lsh(big.NewInt(1), uint(bits-prefixLen)))
+					break
+				}
+			}
+		}
+	}
+
+	return total, used
+}
+
+// subnetsPrefixLength returns the prefix length of the smallest block that splits into count subnets
+// of subnetPrefixLength, e.g. 24 for 16 /28s and 23 for 17 of them.
+func subnetsPrefixLength(count int64, subnetPrefixLength int64) (int64, error) {
+	if count < 1 {
+		return 0, fmt.Errorf("subnet count must be at least 1, got %d", count)
+	}
+	if subnetPrefixLength < 0 || subnetPrefixLength > 128 {
+		return 0, fmt.Errorf("subnet prefix length must be between 0 and 128, got %d", subnetPrefixLength)
+	}
+
+	// every bit it takes to number the subnets doubles the block
+	prefixLength := subnetPrefixLength - int64(bits.Len64(uint64(count-1)))
+	if prefixLength < 0 {
+		return 0, fmt.Errorf("%d /%d subnets don't fit in the address space", count, subnetPrefixLength)
+	}
+	return prefixLength, nil
+}
+
+// takenCIDRs returns the blocks a search for free space has to avoid, the allocations' CIDRs plus
+// the reserved ones, sorted by network address and then prefix length. Backends list allocations in
+// map order, so sorting keeps the search and its result the same whatever order they come back in.
+func takenCIDRs(allocations []storage.Allocation, reserved []*net.IPNet) []*net.IPNet {
+	taken := make([]*net.IPNet, 0, len(allocations)+len(reserved))
+	for _, alloc := range allocations {
+		for _, cidr := range alloc.CIDRs() {
+			_, allocNet, err := net.ParseCIDR(cidr)
+			if err != nil {
+				continue
+			}
+			taken = append(taken, allocNet)
+		}
+	}
+	taken = append(taken, reserved...)
+
+	sortCIDRs(taken)
+	return taken
+}
+
+// sortCIDRs sorts blocks in place by network address and then prefix length, IPv4 before IPv6.
+func sortCIDRs(blocks []*net.IPNet) {
+	sort.SliceStable(blocks, func(i, j int) bool {
+		return cidrLess(blocks[i], blocks[j])
+	})
+}
+
+// cidrLess reports whether a sorts before b in the order of sortCIDRs.
+func cidrLess(a, b *net.IPNet) bool {
+	// IPv4 sorts before IPv6 since its addresses are shorter
+	if len(a.IP) != len(b.IP) {
+		return len(a.IP) < len(b.IP)
+	}
+	if cmp := bytes.Compare(a.IP, b.IP); cmp != 0 {
+		return cmp < 0
+	}
+	aLen, _ := a.Mask.Size()
+	bLen, _ := b.Mask.Size()
+	return aLen < bLen
+}
+
+// allocatedAddressCount returns the total number of addresses across the allocations.
+func allocatedAddressCount(allocations []storage.Allocation) *big.Int {
+	total := new(big.Int)
+	for _, alloc := range allocations {
+		for _, cidr := range alloc.CIDRs() {
+			_, allocNet, err := net.ParseCIDR(cidr)
+			if err != nil {
+				continue
+			}
+			prefixLen, bits := allocNet.Mask.Size()
+			total.Add(total, new(big.Int).Lsh(big.NewInt(1), uint(bits-prefixLen)))
+		}
+	}
+	return total
+}
+
+// utilizationPercent returns used as a percentage of total, or 0 for an empty pool.
+func utilizationPercent(total, used *big.Int) float64 {
+	if total.Sign() == 0 {
+		return 0
+	}
+	percent, _ := new(big.Float).Quo(
+		new(big.Float).Mul(new(big.Float).SetInt(used), big.NewFloat(100)),
+		new(big.Float).SetInt(total),
+	).Float64()
+	return percent
+}
+
+// largestFreeCIDR returns the largest block of the pool's CIDRs with the given address length
+// (32 for IPv4, 128 for IPv6) that doesn't overlap any taken block, or nil when there is none.
+// Ties go to the lowest address, in the order of the pool's CIDRs.
+func largestFreeCIDR(pool *storage.Pool, taken []*net.IPNet, bits int) *net.IPNet {
+	var largest *net.IPNet
+	largestPrefixLen := bits + 1
+	for _, block := range freeCIDRs(pool, taken, bits) {
+		if prefixLen, _ := block.Mask.Size(); prefixLen < largestPrefixLen {
+			largest, largestPrefixLen = block, prefixLen
+		}
+	}
+	return largest
+}
+
+// freeCIDRs returns the free space of the pool's CIDRs with the given address length as the
+// largest blocks that don't overlap any taken block, in the order of the pool's CIDRs.
+func freeCIDRs(pool *storage.Pool, taken []*net.IPNet, bits int) []*net.IPNet {
+	var free []*net.IPNet
+	for _, cidr := range poolCIDRs(pool) {
+		_, poolNet, err := net.ParseCIDR(cidr)
+		if err != nil {
+			continue
+		}
+		if _, poolBits := poolNet.Mask.Size(); poolBits != bits {
+			continue
+		}
+		free = append(free, freeBlocks(poolNet, taken)...)
+	}
+	return free
+}
+
+// freeBlocks returns the free space of a CIDR as the largest blocks that don't overlap any taken
+// block, ordered by address.
+func freeBlocks(cidr *net.IPNet, taken []*net.IPNet) []*net.IPNet {
+	_, bits := cidr.Mask.Size()
+
+	// CIDRs either nest or don't overlap, so a taken block overlapping the CIDR either covers all of
+	// it or sits inside it
+	var exclusions []*net.IPNet
+	for _, block := range taken {
+		if _, blockBits := block.Mask.Size(); blockBits != bits {
+			continue
+		}
+		if block.Contains(cidr.IP) {
+			return nil
+		}
+		if cidr.Contains(block.IP) {
+			exclusions = append(exclusions, block)
+		}
+	}
+
+	blocks, err := subtractCIDRs(cidr, exclusions)
+	if err != nil {
+		return nil
+	}
+	return blocks
+}
+
+// fragmentedCIDRs picks free blocks holding exactly size addresses between them, for an allocation too
+// big for any single free block. Every block's prefix length is kept between minPrefixLen and
+// maxPrefixLen, 0 meaning no limit. The largest blocks are placed first, each in the smallest free block
+// it fits in, so large free blocks are only broken up when nothing smaller will do. Returns nil when
+// the free space can't hold size.
+func fragmentedCIDRs(free []*net.IPNet, size int64, minPrefixLen, maxPrefixLen, bits int) []*net.IPNet {
+	free = append([]*net.IPNet{}, free...)
+	sortCIDRs(free)
+
+	// blocks smaller than the pool allows can't be used, and without enough space in the rest the
+	// search below would keep splitting the blocks it's looking for down to single addresses
+	usable := new(big.Int)
+	for _, block := range free {
+		if prefixLen, _ := block.Mask.Size(); maxPrefixLen == 0 || prefixLen <= maxPrefixLen {
+			usable.Add(usable, new(big.Int).Lsh(big.NewInt(1), uint(bits-prefixLen)))
+		}
+	}
+	if size <= 0 || usable.Cmp(big.NewInt(size)) < 0 {
+		return nil
+	}
+
+	// one block for every bit set in size, largest first
+	var pending []int
+	for shift := 62; shift >= 0; shift-- {
+		if size&(int64(1)<<shift) != 0 {
+			pending = append(pending, bits-shift)
+		}
+	}
+
+	var blocks []*net.IPNet
+	for len(pending) > 0 {
+		prefixLen := pending[0]
+		if prefixLen < 0 || prefixLen > bits || (maxPrefixLen != 0 && prefixLen > maxPrefixLen) {
+			return nil
+		}
+
+		// a block bigger than the pool allows, or than any free block, is made of two of the next size down
+		fit, fitPrefixLen := -1, -1
+		if minPrefixLen == 0 || prefixLen >= minPrefixLen {
+			for i, block := range free {
+				if blockPrefixLen, _ := block.Mask.Size(); blockPrefixLen <= prefixLen && blockPrefixLen > fitPrefixLen {
+					fit, fitPrefixLen = i, blockPrefixLen
+				}
+			}
+		}
+		if fit == -1 {
+			pending = append([]int{prefixLen + 1, prefixLen + 1}, pending[1:]...)
+			continue
+		}
+
+		block := &net.IPNet{IP: free[fit].IP, Mask: net.CIDRMask(prefixLen, bits)}
+		blocks = append(blocks, block)
+		free = append(append(free[:fit:fit], subtractCIDR(free[fit], block)...), free[fit+1:]...)
+		sortCIDRs(free)
+		pending = pending[1:]
+	}
+
+	sortCIDRs(blocks)
+	return blocks
+}
+
+// aggregateCIDRs merges the blocks into the smallest set of CIDRs covering exactly the same addresses,
+// dropping blocks contained in another and joining adjacent halves of a larger block. IPv4 and IPv6
+// are aggregated separately, and the result is ordered by address with IPv4 first.
+func aggregateCIDRs(blocks []*net.IPNet) []*net.IPNet {
+	sorted := append([]*net.IPNet{}, blocks...)
+	sortCIDRs(sorted)
+
+	var merged []*net.IPNet
+	for _, block := range sorted {
+		// sorting puts a block after any block it's contained in
+		if len(merged) > 0 {
+			top := merged[len(merged)-1]
+			if len(top.IP) == len(block.IP) && top.Contains(block.IP) {
+				continue
+			}
+		}
+		merged = append(merged, block)
+
+		// the new block may complete its parent, which may in turn complete the next one up
+		for len(merged) >= 2 {
+			lower, upper := merged[len(merged)-2], merged[len(merged)-1]
+			parent := cidrParent(lower)
+			if parent == nil || !parent.IP.Equal(lower.IP) || len(lower.IP) != len(upper.IP) {
+				break
+			}
+			if _, parentUpper := splitCIDR(parent); parentUpper.String() != upper.String() {
+				break
+			}
+			merged = append(merged[:len(merged)-2], parent)
+		}
+	}
+	return merged
+}
+
+// cidrParent returns the block one prefix length shorter that contains the CIDR, or nil for a /0.
+func cidrParent(block *net.IPNet) *net.IPNet {
+	prefixLen, bits := block.Mask.Size()
+	if prefixLen == 0 {
+		return nil
+	}
+	mask := net.CIDRMask(prefixLen-1, bits)
+	return &net.IPNet{IP: block.IP.Mask(mask), Mask: mask}
+}
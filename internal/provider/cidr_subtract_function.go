@@ -0,0 +1,82 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"net"
+
+	"github.com/hashicorp/terraform-plugin-framework/function"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+)
+
+var _ function.Function = &CIDRSubtractFunction{}
+
+func NewCIDRSubtractFunction() function.Function {
+	return &CIDRSubtractFunction{}
+}
+
+type CIDRSubtractFunction struct{}
+
+func (f *CIDRSubtractFunction) Metadata(ctx context.Context, req function.MetadataRequest, resp *function.MetadataResponse) {
+	resp.Name = "cidr_subtract"
+}
+
+func (f *CIDRSubtractFunction) Definition(ctx context.Context, req function.DefinitionRequest, resp *function.DefinitionResponse) {
+	resp.Definition = function.Definition{
+		Summary:             "Subtract CIDR blocks from a supernet",
+		MarkdownDescription: "Removes the exclusion CIDRs from the supernet and returns the smallest list of CIDR blocks covering the remaining address space, ordered by address. Every exclusion must be the same address family as the supernet and fit inside it.",
+		Parameters: []function.Parameter{
+			function.StringParameter{
+				Name:                "supernet",
+				MarkdownDescription: "CIDR block to subtract from",
+			},
+			function.ListParameter{
+				Name:                "exclusions",
+				ElementType:         types.StringType,
+				MarkdownDescription: "CIDR blocks to remove from the supernet",
+			},
+		},
+		Return: function.ListReturn{
+			ElementType: types.StringType,
+		},
+	}
+}
+
+func (f *CIDRSubtractFunction) Run(ctx context.Context, req function.RunRequest, resp *function.RunResponse) {
+	var supernet string
+	var exclusions []string
+
+	resp.Error = function.ConcatFuncErrors(req.Arguments.Get(ctx, &supernet, &exclusions))
+	if resp.Error != nil {
+		return
+	}
+
+	_, supernetNet, err := net.ParseCIDR(supernet)
+	if err != nil {
+		resp.Error = function.NewArgumentFuncError(0, fmt.Sprintf("CIDR '%s' is not valid: %s", supernet, err))
+		return
+	}
+
+	exclusionNets := make([]*net.IPNet, 0, len(exclusions))
+	for _, cidr := range exclusions {
+		_, exclusionNet, err := net.ParseCIDR(cidr)
+		if err != nil {
+			resp.Error = function.NewArgumentFuncError(1, fmt.Sprintf("CIDR '%s' is not valid: %s", cidr, err))
+			return
+		}
+		exclusionNets = append(exclusionNets, exclusionNet)
+	}
+
+	remaining, err := subtractCIDRs(supernetNet, exclusionNets)
+	if err != nil {
+		resp.Error = function.NewArgumentFuncError(1, err.Error())
+		return
+	}
+
+	result := make([]string, 0, len(remaining))
+	for _, block := range remaining {
+		result = append(result, block.String())
+	}
+
+	resp.Error = function.ConcatFuncErrors(resp.Result.Set(ctx, result))
+}
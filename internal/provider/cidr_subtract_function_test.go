@@ -0,0 +1,129 @@
+package provider
+
+import (
+	"fmt"
+	"regexp"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-testing/helper/resource"
+	"github.com/hashicorp/terraform-plugin-testing/knownvalue"
+	"github.com/hashicorp/terraform-plugin-testing/statecheck"
+	"github.com/hashicorp/terraform-plugin-testing/tfversion"
+)
+
+func TestAccCIDRSubtractFunction_IPv4(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		PreCheck:                 func() { testAccPreCheck(t) },
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		TerraformVersionChecks: []tfversion.TerraformVersionCheck{
+			tfversion.SkipBelow(tfversion.Version1_8_0),
+		},
+		Steps: []resource.TestStep{
+			{
+				Config: testAccCIDRSubtractFunctionConfig("10.0.0.0/22", []string{"10.0.0.0/24", "10.0.3.0/24"}),
+				ConfigStateChecks: []statecheck.StateCheck{
+					statecheck.ExpectKnownOutputValue(
+						"test",
+						knownvalue.ListExact([]knownvalue.Check{
+							knownvalue.StringExact("10.0.1.0/24"),
+							knownvalue.StringExact("10.0.2.0/24"),
+						}),
+					),
+				},
+			},
+		},
+	})
+}
+
+func TestAccCIDRSubtractFunction_IPv6(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		PreCheck:                 func() { testAccPreCheck(t) },
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		TerraformVersionChecks: []tfversion.TerraformVersionCheck{
+			tfversion.SkipBelow(tfversion.Version1_8_0),
+		},
+		Steps: []resource.TestStep{
+			{
+				Config: testAccCIDRSubtractFunctionConfig("2001:db8::/32", []string{"2001:db8::/34"}),
+				ConfigStateChecks: []statecheck.StateCheck{
+					statecheck.ExpectKnownOutputValue(
+						"test",
+						knownvalue.ListExact([]knownvalue.Check{
+							knownvalue.StringExact("2001:db8:4000::/34"),
+							knownvalue.StringExact("2001:db8:8000::/33"),
+						}),
+					),
+				},
+			},
+		},
+	})
+}
+
+func TestAccCIDRSubtractFunction_NoExclusions(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		PreCheck:                 func() { testAccPreCheck(t) },
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		TerraformVersionChecks: []tfversion.TerraformVersionCheck{
+			tfversion.SkipBelow(tfversion.Version1_8_0),
+		},
+		Steps: []resource.TestStep{
+			{
+				Config: testAccCIDRSubtractFunctionConfig("10.0.0.0/16", []string{}),
+				ConfigStateChecks: []statecheck.StateCheck{
+					statecheck.ExpectKnownOutputValue(
+						"test",
+						knownvalue.ListExact([]knownvalue.Check{
+							knownvalue.StringExact("10.0.0.0/16"),
+						}),
+					),
+				},
+			},
+		},
+	})
+}
+
+func TestAccCIDRSubtractFunction_ExclusionOutsideSupernet(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		PreCheck:                 func() { testAccPreCheck(t) },
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		TerraformVersionChecks: []tfversion.TerraformVersionCheck{
+			tfversion.SkipBelow(tfversion.Version1_8_0),
+		},
+		Steps: []resource.TestStep{
+			{
+				Config:      testAccCIDRSubtractFunctionConfig("10.0.0.0/16", []string{"10.1.0.0/24"}),
+				ExpectError: regexp.MustCompile("is not contained in"),
+			},
+		},
+	})
+}
+
+func TestAccCIDRSubtractFunction_MixedFamilies(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		PreCheck:                 func() { testAccPreCheck(t) },
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		TerraformVersionChecks: []tfversion.TerraformVersionCheck{
+			tfversion.SkipBelow(tfversion.Version1_8_0),
+		},
+		Steps: []resource.TestStep{
+			{
+				Config:      testAccCIDRSubtractFunctionConfig("10.0.0.0/16", []string{"2001:db8::/64"}),
+				ExpectError: regexp.MustCompile("not the same address family"),
+			},
+		},
+	})
+}
+
+// testAccCIDRSubtractFunctionConfig generates a Terraform configuration that outputs the result of cidr_subtract.
+func testAccCIDRSubtractFunctionConfig(supernet string, exclusions []string) string {
+	exclusionsConfig := ""
+	for _, cidr := range exclusions {
+		exclusionsConfig += fmt.Sprintf("%q, ", cidr)
+	}
+
+	return fmt.Sprintf(`
+output "test" {
+  value = provider::tfipam::cidr_subtract(%[1]q, [%[2]s])
+}
+`, supernet, exclusionsConfig)
+}
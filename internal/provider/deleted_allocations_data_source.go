@@ -0,0 +1,179 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-framework/attr"
+	"github.com/hashicorp/terraform-plugin-framework/datasource"
+	"github.com/hashicorp/terraform-plugin-framework/datasource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+
+	"terraform-provider-tfipam/internal/provider/storage"
+)
+
+var _ datasource.DataSource = &DeletedAllocationsDataSource{}
+
+func NewDeletedAllocationsDataSource() datasource.DataSource {
+	return &DeletedAllocationsDataSource{}
+}
+
+// DeletedAllocationsDataSource lists the released allocations soft delete keeps until they're purged, to
+// audit releases and recover from an accidental one.
+type DeletedAllocationsDataSource struct {
+	provider *IpamProvider
+}
+
+type DeletedAllocationsDataSourceModel struct {
+	PoolName    types.String `tfsdk:"pool_name"`
+	Allocations types.List   `tfsdk:"allocations"`
+}
+
+var deletedAllocationsDataSourceAllocationType = types.ObjectType{
+	AttrTypes: map[string]attr.Type{
+		"id":             types.StringType,
+		"key":            types.StringType,
+		"pool_name":      types.StringType,
+		"allocated_cidr": types.StringType,
+		"owner":          types.StringType,
+		"deleted_at":     types.StringType,
+		"purge_after":    types.StringType,
+	},
+}
+
+func (d *DeletedAllocationsDataSource) Metadata(ctx context.Context, req datasource.MetadataRequest, resp *datasource.MetadataResponse) {
+	resp.TypeName = req.ProviderTypeName + "_deleted_allocations"
+}
+
+func (d *DeletedAllocationsDataSource) Schema(ctx context.Context, req datasource.SchemaRequest, resp *datasource.SchemaResponse) {
+	resp.Schema = schema.Schema{
+		MarkdownDescription: "IPAM deleted allocations data source for the released allocations kept by the provider's `soft_delete_retention` until they're purged",
+
+		Attributes: map[string]schema.Attribute{
+			"pool_name": schema.StringAttribute{
+				MarkdownDescription: "Only return deleted allocations from this pool",
+				Optional:            true,
+			},
+			"allocations": schema.ListNestedAttribute{
+				MarkdownDescription: "Deleted allocations, most recently released first",
+				Computed:            true,
+				NestedObject: schema.NestedAttributeObject{
+					Attributes: map[string]schema.Attribute{
+						"id": schema.StringAttribute{
+							MarkdownDescription: "Allocation identifier",
+							Computed:            true,
+						},
+						"key": schema.StringAttribute{
+							MarkdownDescription: "Internal key the allocation is stored under",
+							Computed:            true,
+						},
+						"pool_name": schema.StringAttribute{
+							MarkdownDescription: "Name of the pool the allocation was from",
+							Computed:            true,
+						},
+						"allocated_cidr": schema.StringAttribute{
+							MarkdownDescription: "CIDR block the allocation held",
+							Computed:            true,
+						},
+						"owner": schema.StringAttribute{
+							MarkdownDescription: "Team or person accountable for the allocation. Empty when not set",
+							Computed:            true,
+						},
+						"deleted_at": schema.StringAttribute{
+							MarkdownDescription: "RFC 3339 time the allocation was released",
+							Computed:            true,
+						},
+						"purge_after": schema.StringAttribute{
+							MarkdownDescription: "RFC 3339 time from which the `tfipam_purge_deleted_allocations` action purges the allocation",
+							Computed:            true,
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+func (d *DeletedAllocationsDataSource) Configure(ctx context.Context, req datasource.ConfigureRequest, resp *datasource.ConfigureResponse) {
+	if req.ProviderData == nil {
+		return
+	}
+
+	provider, ok := req.ProviderData.(*IpamProvider)
+	if !ok {
+		resp.Diagnostics.AddError(
+			"Unexpected Resource Configure Type",
+			fmt.Sprintf("Expected *IpamProvider, got: %T", req.ProviderData),
+		)
+		return
+	}
+
+	d.provider = provider
+}
+
+func (d *DeletedAllocationsDataSource) Read(ctx context.Context, req datasource.ReadRequest, resp *datasource.ReadResponse) {
+	var data DeletedAllocationsDataSourceModel
+
+	resp.Diagnostics.Append(req.Config.Get(ctx, &data)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	softDeleter := d.provider.softDeleter(&resp.Diagnostics)
+	if softDeleter == nil {
+		return
+	}
+
+	allocations, err := softDeleter.ListDeletedAllocations(ctx)
+	if err != nil {
+		resp.Diagnostics.AddError(
+			"Failed to List Deleted Allocations",
+			fmt.Sprintf("Could not list deleted allocations from storage: %s", err),
+		)
+		return
+	}
+
+	deleted := make([]storage.Allocation, 0, len(allocations))
+	for _, allocation := range allocations {
+		if poolName := data.PoolName.ValueString(); poolName == "" || allocation.PoolName == poolName {
+			deleted = append(deleted, allocation)
+		}
+	}
+
+	// most recent first, the releases someone may still want to undo
+	sort.Slice(deleted, func(i, j int) bool {
+		if !deleted[i].DeletedAt.Equal(deleted[j].DeletedAt) {
+			return deleted[i].DeletedAt.After(deleted[j].DeletedAt)
+		}
+		return deleted[i].ID < deleted[j].ID
+	})
+
+	allocationValues := make([]attr.Value, 0, len(deleted))
+	for _, allocation := range deleted {
+		allocationValue, diag := types.ObjectValue(deletedAllocationsDataSourceAllocationType.AttrTypes, map[string]attr.Value{
+			"id":             types.StringValue(allocation.ID),
+			"key":            types.StringValue(allocation.StorageKey()),
+			"pool_name":      types.StringValue(allocation.PoolName),
+			"allocated_cidr": types.StringValue(allocation.AllocatedCIDR),
+			"owner":          types.StringValue(allocation.Owner),
+			"deleted_at":     types.StringValue(allocation.DeletedAt.UTC().Format(time.RFC3339)),
+			"purge_after":    types.StringValue(allocation.DeletedAt.Add(softDeleter.Retention()).UTC().Format(time.RFC3339)),
+		})
+		resp.Diagnostics.Append(diag...)
+		if resp.Diagnostics.HasError() {
+			return
+		}
+		allocationValues = append(allocationValues, allocationValue)
+	}
+
+	allocationsList, diag := types.ListValue(deletedAllocationsDataSourceAllocationType, allocationValues)
+	resp.Diagnostics.Append(diag...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	data.Allocations = allocationsList
+
+	resp.Diagnostics.Append(resp.State.Set(ctx, &data)...)
+}
@@ -0,0 +1,107 @@
+package provider
+
+import (
+	"fmt"
+	"regexp"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-framework/providerserver"
+	"github.com/hashicorp/terraform-plugin-go/tfprotov6"
+	"github.com/hashicorp/terraform-plugin-testing/helper/acctest"
+	"github.com/hashicorp/terraform-plugin-testing/helper/resource"
+	"github.com/hashicorp/terraform-plugin-testing/knownvalue"
+	"github.com/hashicorp/terraform-plugin-testing/statecheck"
+	"github.com/hashicorp/terraform-plugin-testing/tfjsonpath"
+)
+
+// testAccSoftDeleteProviderFactories serve a provider instance of their own. Storage is only set up on an
+// instance's first configure, so soft delete can't be turned on for the shared one.
+var testAccSoftDeleteProviderFactories = map[string]func() (tfprotov6.ProviderServer, error){
+	"tfipam": providerserver.NewProtocol6WithError(New("test")()),
+}
+
+func TestAccDeletedAllocationsDataSource(t *testing.T) {
+	// deleted allocations outlive the test, so every run gets a pool of its own
+	poolName := acctest.RandomWithPrefix("deleted-pool")
+
+	resource.Test(t, resource.TestCase{
+		PreCheck:                 func() { testAccPreCheck(t) },
+		ProtoV6ProviderFactories: testAccSoftDeleteProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: testAccDeletedAllocationsDataSourceConfig(poolName, "released"),
+			},
+			// releases the allocation, the data source was read before that
+			{
+				Config: testAccDeletedAllocationsDataSourceConfig(poolName, ""),
+			},
+			// the released allocation is listed, and its CIDR is handed out again
+			{
+				Config: testAccDeletedAllocationsDataSourceConfig(poolName, "replacement"),
+				ConfigStateChecks: []statecheck.StateCheck{
+					statecheck.ExpectKnownValue(
+						"data.tfipam_deleted_allocations.test",
+						tfjsonpath.New("allocations"),
+						knownvalue.ListExact([]knownvalue.Check{
+							knownvalue.ObjectPartial(map[string]knownvalue.Check{
+								"id":             knownvalue.StringExact("deleted-released"),
+								"pool_name":      knownvalue.StringExact(poolName),
+								"allocated_cidr": knownvalue.StringExact("10.3.0.0/26"),
+								"deleted_at":     knownvalue.NotNull(),
+								"purge_after":    knownvalue.NotNull(),
+							}),
+						}),
+					),
+					statecheck.ExpectKnownValue(
+						"tfipam_allocation.replacement",
+						tfjsonpath.New("allocated_cidr"),
+						knownvalue.StringExact("10.3.0.0/26"),
+					),
+				},
+			},
+		},
+	})
+}
+
+func TestAccDeletedAllocationsDataSource_NotEnabled(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		PreCheck:                 func() { testAccPreCheck(t) },
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config:      `data "tfipam_deleted_allocations" "test" {}`,
+				ExpectError: regexp.MustCompile("Soft Delete Not Enabled"),
+			},
+		},
+	})
+}
+
+// testAccDeletedAllocationsDataSourceConfig generates provider config with soft delete and a pool with a /26
+// allocation of the given name, or none when it's empty.
+func testAccDeletedAllocationsDataSourceConfig(poolName, allocation string) string {
+	var allocationConfig string
+	if allocation != "" {
+		allocationConfig = fmt.Sprintf(`
+resource "tfipam_allocation" %[1]q {
+  id            = "deleted-%[1]s"
+  pool_name     = tfipam_pool.test.name
+  prefix_length = 26
+}
+`, allocation)
+	}
+
+	return fmt.Sprintf(`
+provider "tfipam" {
+  soft_delete_retention = "1h"
+}
+
+resource "tfipam_pool" "test" {
+  name  = %q
+  cidrs = ["10.3.0.0/24"]
+}
+`, poolName) + allocationConfig + `
+data "tfipam_deleted_allocations" "test" {
+  pool_name = tfipam_pool.test.name
+}
+`
+}
@@ -0,0 +1,168 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-framework/attr"
+	"github.com/hashicorp/terraform-plugin-framework/datasource"
+	"github.com/hashicorp/terraform-plugin-framework/datasource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+
+	"terraform-provider-tfipam/internal/provider/storage"
+)
+
+var _ datasource.DataSource = &ExpiredAllocationsDataSource{}
+
+func NewExpiredAllocationsDataSource() datasource.DataSource {
+	return &ExpiredAllocationsDataSource{}
+}
+
+// ExpiredAllocationsDataSource lists allocations whose TTL has run out, so a cleanup pipeline can release them.
+type ExpiredAllocationsDataSource struct {
+	provider *IpamProvider
+}
+
+type ExpiredAllocationsDataSourceModel struct {
+	PoolName    types.String `tfsdk:"pool_name"`
+	Allocations types.List   `tfsdk:"allocations"`
+}
+
+var expiredAllocationsDataSourceAllocationType = types.ObjectType{
+	AttrTypes: map[string]attr.Type{
+		"id":             types.StringType,
+		"pool_name":      types.StringType,
+		"allocated_cidr": types.StringType,
+		"owner":          types.StringType,
+		"expires_at":     types.StringType,
+	},
+}
+
+func (d *ExpiredAllocationsDataSource) Metadata(ctx context.Context, req datasource.MetadataRequest, resp *datasource.MetadataResponse) {
+	resp.TypeName = req.ProviderTypeName + "_expired_allocations"
+}
+
+func (d *ExpiredAllocationsDataSource) Schema(ctx context.Context, req datasource.SchemaRequest, resp *datasource.SchemaResponse) {
+	resp.Schema = schema.Schema{
+		MarkdownDescription: "IPAM expired allocations data source for finding allocations whose `ttl_seconds` has run out",
+
+		Attributes: map[string]schema.Attribute{
+			"pool_name": schema.StringAttribute{
+				MarkdownDescription: "Only return expired allocations from this pool",
+				Optional:            true,
+			},
+			"allocations": schema.ListNestedAttribute{
+				MarkdownDescription: "Expired allocations, sorted by expiry and then id",
+				Computed:            true,
+				NestedObject: schema.NestedAttributeObject{
+					Attributes: map[string]schema.Attribute{
+						"id": schema.StringAttribute{
+							MarkdownDescription: "Allocation identifier",
+							Computed:            true,
+						},
+						"pool_name": schema.StringAttribute{
+							MarkdownDescription: "Name of the pool the allocation is from",
+							Computed:            true,
+						},
+						"allocated_cidr": schema.StringAttribute{
+							MarkdownDescription: "Allocated CIDR block",
+							Computed:            true,
+						},
+						"owner": schema.StringAttribute{
+							MarkdownDescription: "Team or person accountable for the allocation. Empty when not set",
+							Computed:            true,
+						},
+						"expires_at": schema.StringAttribute{
+							MarkdownDescription: "RFC 3339 time the allocation expired",
+							Computed:            true,
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+func (d *ExpiredAllocationsDataSource) Configure(ctx context.Context, req datasource.ConfigureRequest, resp *datasource.ConfigureResponse) {
+	if req.ProviderData == nil {
+		return
+	}
+
+	provider, ok := req.ProviderData.(*IpamProvider)
+	if !ok {
+		resp.Diagnostics.AddError(
+			"Unexpected Resource Configure Type",
+			fmt.Sprintf("Expected *IpamProvider, got: %T", req.ProviderData),
+		)
+		return
+	}
+
+	d.provider = provider
+}
+
+func (d *ExpiredAllocationsDataSource) Read(ctx context.Context, req datasource.ReadRequest, resp *datasource.ReadResponse) {
+	var data ExpiredAllocationsDataSourceModel
+
+	resp.Diagnostics.Append(req.Config.Get(ctx, &data)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	var allocations []storage.Allocation
+	var err error
+	if poolName := data.PoolName.ValueString(); poolName != "" {
+		allocations, err = d.provider.storage.ListAllocationsByPool(ctx, poolName)
+	} else {
+		allocations, err = d.provider.storage.ListAllocations(ctx)
+	}
+	if err != nil {
+		resp.Diagnostics.AddError(
+			"Failed to List Allocations",
+			fmt.Sprintf("Could not list allocations from storage: %s", err),
+		)
+		return
+	}
+
+	now := time.Now()
+	expired := make([]storage.Allocation, 0)
+	for _, allocation := range allocations {
+		if allocation.Expired(now) {
+			expired = append(expired, allocation)
+		}
+	}
+
+	// oldest first, so a cleanup pipeline working through the list releases the longest expired first
+	sort.Slice(expired, func(i, j int) bool {
+		if !expired[i].ExpiresAt.Equal(expired[j].ExpiresAt) {
+			return expired[i].ExpiresAt.Before(expired[j].ExpiresAt)
+		}
+		return expired[i].ID < expired[j].ID
+	})
+
+	allocationValues := make([]attr.Value, 0, len(expired))
+	for _, allocation := range expired {
+		allocationValue, diag := types.ObjectValue(expiredAllocationsDataSourceAllocationType.AttrTypes, map[string]attr.Value{
+			"id":             types.StringValue(allocation.ID),
+			"pool_name":      types.StringValue(allocation.PoolName),
+			"allocated_cidr": types.StringValue(allocation.AllocatedCIDR),
+			"owner":          types.StringValue(allocation.Owner),
+			"expires_at":     allocationExpiresAt(&allocation),
+		})
+		resp.Diagnostics.Append(diag...)
+		if resp.Diagnostics.HasError() {
+			return
+		}
+		allocationValues = append(allocationValues, allocationValue)
+	}
+
+	allocationsList, diag := types.ListValue(expiredAllocationsDataSourceAllocationType, allocationValues)
+	resp.Diagnostics.Append(diag...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	data.Allocations = allocationsList
+
+	resp.Diagnostics.Append(resp.State.Set(ctx, &data)...)
+}
@@ -0,0 +1,73 @@
+package provider
+
+import (
+	"testing"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-testing/helper/resource"
+	"github.com/hashicorp/terraform-plugin-testing/knownvalue"
+	"github.com/hashicorp/terraform-plugin-testing/statecheck"
+	"github.com/hashicorp/terraform-plugin-testing/tfjsonpath"
+)
+
+func TestAccExpiredAllocationsDataSource(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		PreCheck:                 func() { testAccPreCheck(t) },
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: testAccExpiredAllocationsDataSourceConfig(),
+			},
+			// only the allocation with the short TTL has expired by now
+			{
+				PreConfig: func() { time.Sleep(2 * time.Second) },
+				Config:    testAccExpiredAllocationsDataSourceConfig(),
+				ConfigStateChecks: []statecheck.StateCheck{
+					statecheck.ExpectKnownValue(
+						"data.tfipam_expired_allocations.test",
+						tfjsonpath.New("allocations"),
+						knownvalue.ListExact([]knownvalue.Check{
+							knownvalue.ObjectPartial(map[string]knownvalue.Check{
+								"id":             knownvalue.StringExact("expired-short"),
+								"pool_name":      knownvalue.StringExact("expired-pool"),
+								"allocated_cidr": knownvalue.StringExact("10.0.0.0/28"),
+							}),
+						}),
+					),
+				},
+			},
+		},
+	})
+}
+
+// testAccExpiredAllocationsDataSourceConfig generates a configuration with a short and a long lived allocation.
+func testAccExpiredAllocationsDataSourceConfig() string {
+	return `
+resource "tfipam_pool" "test" {
+  name  = "expired-pool"
+  cidrs = ["10.0.0.0/24"]
+}
+
+resource "tfipam_allocation" "short" {
+  id            = "expired-short"
+  pool_name     = tfipam_pool.test.name
+  prefix_length = 28
+  ttl_seconds   = 1
+}
+
+resource "tfipam_allocation" "long" {
+  id            = "expired-long"
+  pool_name     = tfipam_pool.test.name
+  prefix_length = 28
+  ttl_seconds   = 3600
+
+  depends_on = [tfipam_allocation.short]
+}
+
+data "tfipam_expired_allocations" "test" {
+  pool_name = tfipam_pool.test.name
+
+  depends_on = [tfipam_allocation.long]
+}
+`
+}
@@ -0,0 +1,122 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/hashicorp/terraform-plugin-framework/datasource"
+	"github.com/hashicorp/terraform-plugin-framework/datasource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+
+	"terraform-provider-tfipam/internal/provider/storage"
+)
+
+var _ datasource.DataSource = &ExportDataSource{}
+
+func NewExportDataSource() datasource.DataSource {
+	return &ExportDataSource{}
+}
+
+// ExportDataSource returns every pool and allocation in storage as one JSON document, for snapshots and
+// external tools.
+type ExportDataSource struct {
+	provider *IpamProvider
+}
+
+type ExportDataSourceModel struct {
+	Pretty types.Bool   `tfsdk:"pretty"`
+	JSON   types.String `tfsdk:"json"`
+}
+
+// ipamExport is the document the export data source returns. Pools and allocations are written with
+// the same fields as in storage.
+type ipamExport struct {
+	Pools       []storage.Pool       `json:"pools"`
+	Allocations []storage.Allocation `json:"allocations"`
+}
+
+func (d *ExportDataSource) Metadata(ctx context.Context, req datasource.MetadataRequest, resp *datasource.MetadataResponse) {
+	resp.TypeName = req.ProviderTypeName + "_export"
+}
+
+func (d *ExportDataSource) Schema(ctx context.Context, req datasource.SchemaRequest, resp *datasource.SchemaResponse) {
+	resp.Schema = schema.Schema{
+		MarkdownDescription: "IPAM export data source for reading every pool and allocation as JSON, e.g. to snapshot them or feed a dashboard",
+
+		Attributes: map[string]schema.Attribute{
+			"pretty": schema.BoolAttribute{
+				MarkdownDescription: "Indent the JSON for reading. Defaults to false, which writes it on one line",
+				Optional:            true,
+			},
+			"json": schema.StringAttribute{
+				MarkdownDescription: "JSON object with the `pools` sorted by name and the `allocations` sorted by id",
+				Computed:            true,
+			},
+		},
+	}
+}
+
+func (d *ExportDataSource) Configure(ctx context.Context, req datasource.ConfigureRequest, resp *datasource.ConfigureResponse) {
+	if req.ProviderData == nil {
+		return
+	}
+
+	provider, ok := req.ProviderData.(*IpamProvider)
+	if !ok {
+		resp.Diagnostics.AddError(
+			"Unexpected Resource Configure Type",
+			fmt.Sprintf("Expected *IpamProvider, got: %T", req.ProviderData),
+		)
+		return
+	}
+
+	d.provider = provider
+}
+
+func (d *ExportDataSource) Read(ctx context.Context, req datasource.ReadRequest, resp *datasource.ReadResponse) {
+	var data ExportDataSourceModel
+
+	resp.Diagnostics.Append(req.Config.Get(ctx, &data)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	// one snapshot, so every allocation in the export comes with its pool
+	snapshot, err := d.provider.storage.Snapshot(ctx)
+	if err != nil {
+		resp.Diagnostics.AddError(
+			"Failed to Read Storage",
+			fmt.Sprintf("Could not read a snapshot of the pools and allocations in storage: %s", err),
+		)
+		return
+	}
+	pools, allocations := snapshot.Pools, snapshot.Allocations
+
+	// backends list in map order, sorting keeps the export the same as long as storage is
+	sort.Slice(pools, func(i, j int) bool {
+		return pools[i].Name < pools[j].Name
+	})
+	sort.Slice(allocations, func(i, j int) bool {
+		return allocations[i].ID < allocations[j].ID
+	})
+
+	export := ipamExport{Pools: pools, Allocations: allocations}
+	var encoded []byte
+	if data.Pretty.ValueBool() {
+		encoded, err = json.MarshalIndent(export, "", "  ")
+	} else {
+		encoded, err = json.Marshal(export)
+	}
+	if err != nil {
+		resp.Diagnostics.AddError(
+			"Failed to Encode Export",
+			fmt.Sprintf("Could not encode pools and allocations as JSON: %s", err),
+		)
+		return
+	}
+	data.JSON = types.StringValue(string(encoded))
+
+	resp.Diagnostics.Append(resp.State.Set(ctx, &data)...)
+}
@@ -0,0 +1,89 @@
+package provider
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-testing/helper/resource"
+	"github.com/hashicorp/terraform-plugin-testing/knownvalue"
+	"github.com/hashicorp/terraform-plugin-testing/statecheck"
+	"github.com/hashicorp/terraform-plugin-testing/tfjsonpath"
+)
+
+func TestAccExportDataSource(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		PreCheck:                 func() { testAccPreCheck(t) },
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: testAccExportDataSourceConfig(false),
+				ConfigStateChecks: []statecheck.StateCheck{
+					statecheck.ExpectKnownValue(
+						"data.tfipam_export.test",
+						tfjsonpath.New("json"),
+						knownvalue.StringFunc(checkExportRoundTrip),
+					),
+				},
+			},
+			// indenting only changes the formatting
+			{
+				Config: testAccExportDataSourceConfig(true),
+				ConfigStateChecks: []statecheck.StateCheck{
+					statecheck.ExpectKnownValue(
+						"data.tfipam_export.test",
+						tfjsonpath.New("json"),
+						knownvalue.StringFunc(checkExportRoundTrip),
+					),
+				},
+			},
+		},
+	})
+}
+
+// checkExportRoundTrip unmarshals the export and checks it holds the pool and allocation of the test config.
+func checkExportRoundTrip(value string) error {
+	var export ipamExport
+	if err := json.Unmarshal([]byte(value), &export); err != nil {
+		return fmt.Errorf("export doesn't unmarshal: %w", err)
+	}
+
+	var pool, allocation bool
+	for _, p := range export.Pools {
+		if p.Name == "export-pool" && len(p.CIDRs) == 1 && p.CIDRs[0] == "10.3.0.0/24" {
+			pool = true
+		}
+	}
+	for _, a := range export.Allocations {
+		if a.ID == "export-alloc" && a.PoolName == "export-pool" && a.AllocatedCIDR == "10.3.0.0/26" && a.Owner == "networking" {
+			allocation = true
+		}
+	}
+	if !pool || !allocation {
+		return fmt.Errorf("export is missing the test pool or allocation: %s", value)
+	}
+	return nil
+}
+
+// testAccExportDataSourceConfig generates config with a pool, an allocation and an export of storage.
+func testAccExportDataSourceConfig(pretty bool) string {
+	return fmt.Sprintf(`
+resource "tfipam_pool" "test" {
+  name  = "export-pool"
+  cidrs = ["10.3.0.0/24"]
+}
+
+resource "tfipam_allocation" "test" {
+  id            = "export-alloc"
+  pool_name     = tfipam_pool.test.name
+  prefix_length = 26
+  owner         = "networking"
+}
+
+data "tfipam_export" "test" {
+  pretty = %t
+
+  depends_on = [tfipam_allocation.test]
+}
+`, pretty)
+}
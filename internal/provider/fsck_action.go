@@ -0,0 +1,295 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/terraform-plugin-framework/action"
+	"github.com/hashicorp/terraform-plugin-framework/action/schema"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+
+	"terraform-provider-tfipam/internal/provider/storage"
+)
+
+var _ action.Action = &FsckAction{}
+var _ action.ActionWithConfigure = &FsckAction{}
+
+func NewFsckAction() action.Action {
+	return &FsckAction{}
+}
+
+// FsckAction checks the shared storage for allocations that are inconsistent with their pools, and
+// optionally deletes the ones that can't be valid.
+type FsckAction struct {
+	provider *IpamProvider
+}
+
+type FsckActionModel struct {
+	PoolName types.String `tfsdk:"pool_name"`
+	Repair   types.Bool   `tfsdk:"repair"`
+}
+
+// problems an fsck finding can report
+const (
+	fsckOrphaned    = "orphaned"
+	fsckOutsidePool = "outside_pool"
+	fsckOverlap     = "overlap"
+)
+
+// fsckFinding is one problem with an allocation.
+type fsckFinding struct {
+	Problem  string `json:"problem"`
+	ID       string `json:"id"`
+	Key      string `json:"key"`
+	PoolName string `json:"pool_name"`
+	CIDR     string `json:"cidr"`
+
+	// the other allocation of an overlap
+	OverlapsID   string `json:"overlaps_id,omitempty"`
+	OverlapsCIDR string `json:"overlaps_cidr,omitempty"`
+
+	// set when repair deleted the allocation
+	Deleted bool `json:"deleted,omitempty"`
+}
+
+// fsckReport is the structured result of a check, sent to Terraform as JSON.
+type fsckReport struct {
+	Pools       int           `json:"pools"`
+	Allocations int           `json:"allocations"`
+	Findings    []fsckFinding `json:"findings"`
+}
+
+func (a *FsckAction) Metadata(ctx context.Context, req action.MetadataRequest, resp *action.MetadataResponse) {
+	resp.TypeName = req.ProviderTypeName + "_fsck"
+}
+
+func (a *FsckAction) Schema(ctx context.Context, req action.SchemaRequest, resp *action.SchemaResponse) {
+	resp.Schema = schema.Schema{
+		MarkdownDescription: "IPAM consistency check action that reports allocations whose pool is missing, that are outside their pool's CIDRs, or that overlap each other",
+
+		Attributes: map[string]schema.Attribute{
+			"pool_name": schema.StringAttribute{
+				Optional:            true,
+				MarkdownDescription: "Only check the allocations of this pool. Every allocation is checked when not set",
+			},
+			"repair": schema.BoolAttribute{
+				Optional:            true,
+				MarkdownDescription: "Delete allocations whose pool is missing or that are outside their pool's CIDRs. Overlapping allocations are only reported, since which of them to keep is up to the operator. Defaults to false",
+			},
+		},
+	}
+}
+
+func (a *FsckAction) Configure(ctx context.Context, req action.ConfigureRequest, resp *action.ConfigureResponse) {
+	if req.ProviderData == nil {
+		return
+	}
+
+	provider, ok := req.ProviderData.(*IpamProvider)
+	if !ok {
+		resp.Diagnostics.AddError(
+			"Unexpected Action Configure Type",
+			fmt.Sprintf("Expected *IpamProvider, got: %T", req.ProviderData),
+		)
+		return
+	}
+
+	a.provider = provider
+}
+
+func (a *FsckAction) Invoke(ctx context.Context, req action.InvokeRequest, resp *action.InvokeResponse) {
+	var data FsckActionModel
+
+	resp.Diagnostics.Append(req.Config.Get(ctx, &data)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	pools, err := a.provider.storage.ListPools(ctx)
+	if err != nil {
+		resp.Diagnostics.AddError(
+			"Failed to List Pools",
+			fmt.Sprintf("Could not list pools from storage: %s", err),
+		)
+		return
+	}
+
+	var allocations []storage.Allocation
+	if poolName := data.PoolName.ValueString(); poolName != "" {
+		allocations, err = a.provider.storage.ListAllocationsByPool(ctx, poolName)
+	} else {
+		allocations, err = a.provider.storage.ListAllocations(ctx)
+	}
+	if err != nil {
+		resp.Diagnostics.AddError(
+			"Failed to List Allocations",
+			fmt.Sprintf("Could not list allocations from storage: %s", err),
+		)
+		return
+	}
+
+	report := checkAllocations(pools, allocations)
+
+	if data.Repair.ValueBool() {
+		for i, finding := range report.Findings {
+			if finding.Problem == fsckOverlap {
+				continue
+			}
+			if err := a.provider.storage.DeleteAllocation(ctx, finding.Key); err != nil && !errors.Is(err, storage.ErrNotFound) {
+				addStorageWriteError(
+					&resp.Diagnostics,
+					"Failed to Delete Allocation",
+					fmt.Sprintf("Could not delete %s allocation %s from storage: %s", finding.Problem, finding.ID, err),
+					err,
+				)
+				break
+			}
+			report.Findings[i].Deleted = true
+
+			tflog.Info(ctx, "deleted inconsistent allocation", map[string]any{
+				"id":        finding.ID,
+				"key":       finding.Key,
+				"pool_name": finding.PoolName,
+				"cidr":      finding.CIDR,
+				"problem":   finding.Problem,
+			})
+		}
+	}
+
+	message, err := json.Marshal(report)
+	if err != nil {
+		resp.Diagnostics.AddError(
+			"Failed to Encode Report",
+			fmt.Sprintf("Could not encode the consistency check report: %s", err),
+		)
+		return
+	}
+	resp.SendProgress(action.InvokeProgressEvent{Message: string(message)})
+
+	tflog.Info(ctx, "checked storage consistency", map[string]any{
+		"pools":       report.Pools,
+		"allocations": report.Allocations,
+		"findings":    len(report.Findings),
+	})
+
+	var remaining []string
+	for _, finding := range report.Findings {
+		if finding.Deleted {
+			continue
+		}
+		line := fmt.Sprintf("- %s: allocation %s (%s) in pool %s", finding.Problem, finding.ID, finding.CIDR, finding.PoolName)
+		if finding.Problem == fsckOverlap {
+			line += fmt.Sprintf(" overlaps allocation %s (%s)", finding.OverlapsID, finding.OverlapsCIDR)
+		}
+		remaining = append(remaining, line)
+	}
+	if len(remaining) > 0 {
+		resp.Diagnostics.AddWarning(
+			"Storage Inconsistencies Found",
+			fmt.Sprintf("The consistency check found %d problem(s) in storage:\n%s\n\nSet repair to delete orphaned allocations and allocations outside their pool. "+
+				"Overlapping allocations have to be resolved by destroying or changing one of them.", len(remaining), strings.Join(remaining, "\n")),
+		)
+	}
+}
+
+// checkAllocations returns a report of the allocations whose pool is missing, that aren't inside their
+// pool's CIDRs, and that overlap another allocation of the same pool without allowing it. Findings
+// are ordered by allocation id, and overlaps come last.
+func checkAllocations(pools []storage.Pool, allocations []storage.Allocation) fsckReport {
+	report := fsckReport{Pools: len(pools), Allocations: len(allocations), Findings: []fsckFinding{}}
+
+	poolsByName := make(map[string]*storage.Pool, len(pools))
+	for i := range pools {
+		poolsByName[pools[i].Name] = &pools[i]
+	}
+
+	sorted := append([]storage.Allocation{}, allocations...)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].ID < sorted[j].ID
+	})
+
+	// blocks of each pool that have to be checked for overlaps
+	type allocatedBlock struct {
+		allocation *storage.Allocation
+		block      *net.IPNet
+	}
+	blocksByPool := make(map[string][]allocatedBlock)
+
+	for i := range sorted {
+		allocation := &sorted[i]
+		pool, ok := poolsByName[allocation.PoolName]
+		if !ok {
+			report.Findings = append(report.Findings, fsckFinding{
+				Problem:  fsckOrphaned,
+				ID:       allocation.ID,
+				Key:      allocation.StorageKey(),
+				PoolName: allocation.PoolName,
+				CIDR:     strings.Join(allocation.CIDRs(), ","),
+			})
+			continue
+		}
+
+		var blocks []allocatedBlock
+		for _, cidr := range allocation.CIDRs() {
+			_, block, err := net.ParseCIDR(cidr)
+			if err != nil || !cidrInPool(pool, block) {
+				report.Findings = append(report.Findings, fsckFinding{
+					Problem:  fsckOutsidePool,
+					ID:       allocation.ID,
+					Key:      allocation.StorageKey(),
+					PoolName: allocation.PoolName,
+					CIDR:     cidr,
+				})
+				blocks = nil
+				break
+			}
+			blocks = append(blocks, allocatedBlock{allocation: allocation, block: block})
+		}
+		if !allocation.AllowOverlap {
+			blocksByPool[allocation.PoolName] = append(blocksByPool[allocation.PoolName], blocks...)
+		}
+	}
+
+	poolNames := make([]string, 0, len(blocksByPool))
+	for poolName := range blocksByPool {
+		poolNames = append(poolNames, poolName)
+	}
+	sort.Strings(poolNames)
+
+	for _, poolName := range poolNames {
+		blocks := blocksByPool[poolName]
+		sort.SliceStable(blocks, func(i, j int) bool {
+			return cidrLess(blocks[i].block, blocks[j].block)
+		})
+
+		// CIDRs either nest or don't overlap, so once sorted a block overlaps an earlier one exactly
+		// when one of the blocks still enclosing it contains its address
+		var enclosing []allocatedBlock
+		for _, current := range blocks {
+			for len(enclosing) > 0 && !enclosing[len(enclosing)-1].block.Contains(current.block.IP) {
+				enclosing = enclosing[:len(enclosing)-1]
+			}
+			if len(enclosing) > 0 {
+				other := enclosing[len(enclosing)-1]
+				report.Findings = append(report.Findings, fsckFinding{
+					Problem:      fsckOverlap,
+					ID:           current.allocation.ID,
+					Key:          current.allocation.StorageKey(),
+					PoolName:     poolName,
+					CIDR:         current.block.String(),
+					OverlapsID:   other.allocation.ID,
+					OverlapsCIDR: other.block.String(),
+				})
+			}
+			enclosing = append(enclosing, current)
+		}
+	}
+
+	return report
+}
@@ -0,0 +1,51 @@
+package provider
+
+import (
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-testing/helper/resource"
+	"github.com/hashicorp/terraform-plugin-testing/tfversion"
+)
+
+func TestAccFsckAction_Report(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		TerraformVersionChecks: []tfversion.TerraformVersionCheck{
+			tfversion.SkipBelow(tfversion.Version1_14_0),
+		},
+		PreCheck:                 func() { testAccPreCheck(t) },
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: `
+resource "tfipam_pool" "test" {
+  name  = "fsck-pool"
+  cidrs = ["10.2.0.0/24"]
+}
+
+resource "tfipam_allocation" "test" {
+  id            = "fsck-allocation"
+  pool_name     = tfipam_pool.test.name
+  prefix_length = 26
+}
+
+action "tfipam_fsck" "test" {
+  config {
+    pool_name = tfipam_pool.test.name
+  }
+}
+
+resource "terraform_data" "trigger" {
+  input = tfipam_allocation.test.allocated_cidr
+
+  lifecycle {
+    action_trigger {
+      events  = [after_create]
+      actions = [action.tfipam_fsck.test]
+    }
+  }
+}
+`,
+			},
+		},
+	})
+}
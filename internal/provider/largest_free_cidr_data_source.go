@@ -0,0 +1,174 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+
+	"github.com/hashicorp/terraform-plugin-framework/datasource"
+	"github.com/hashicorp/terraform-plugin-framework/datasource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/path"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+
+	"terraform-provider-tfipam/internal/provider/storage"
+)
+
+var _ datasource.DataSource = &LargestFreeCIDRDataSource{}
+
+func NewLargestFreeCIDRDataSource() datasource.DataSource {
+	return &LargestFreeCIDRDataSource{}
+}
+
+// LargestFreeCIDRDataSource finds the biggest block still free in a pool, for sizing an allocation
+// to whatever space is left.
+type LargestFreeCIDRDataSource struct {
+	provider *IpamProvider
+}
+
+type LargestFreeCIDRDataSourceModel struct {
+	PoolName      types.String `tfsdk:"pool_name"`
+	AddressFamily types.String `tfsdk:"address_family"`
+	CIDR          types.String `tfsdk:"cidr"`
+	PrefixLength  types.Int64  `tfsdk:"prefix_length"`
+}
+
+func (d *LargestFreeCIDRDataSource) Metadata(ctx context.Context, req datasource.MetadataRequest, resp *datasource.MetadataResponse) {
+	resp.TypeName = req.ProviderTypeName + "_largest_free_cidr"
+}
+
+func (d *LargestFreeCIDRDataSource) Schema(ctx context.Context, req datasource.SchemaRequest, resp *datasource.SchemaResponse) {
+	resp.Schema = schema.Schema{
+		MarkdownDescription: "IPAM largest free CIDR data source for finding the biggest block that can still be allocated from a pool. Nothing is allocated",
+
+		Attributes: map[string]schema.Attribute{
+			"pool_name": schema.StringAttribute{
+				MarkdownDescription: "Name of the IP pool",
+				Required:            true,
+			},
+			"address_family": schema.StringAttribute{
+				MarkdownDescription: "Address family to search, either 'ipv4' or 'ipv6'. Required when the pool has both IPv4 and IPv6 CIDRs, otherwise defaults to the family of the pool",
+				Optional:            true,
+				Computed:            true,
+			},
+			"cidr": schema.StringAttribute{
+				MarkdownDescription: "Largest free CIDR block. When several blocks tie, the one with the lowest address is returned",
+				Computed:            true,
+			},
+			"prefix_length": schema.Int64Attribute{
+				MarkdownDescription: "Prefix length of `cidr`",
+				Computed:            true,
+			},
+		},
+	}
+}
+
+func (d *LargestFreeCIDRDataSource) Configure(ctx context.Context, req datasource.ConfigureRequest, resp *datasource.ConfigureResponse) {
+	if req.ProviderData == nil {
+		return
+	}
+
+	provider, ok := req.ProviderData.(*IpamProvider)
+	if !ok {
+		resp.Diagnostics.AddError(
+			"Unexpected Resource Configure Type",
+			fmt.Sprintf("Expected *IpamProvider, got: %T", req.ProviderData),
+		)
+		return
+	}
+
+	d.provider = provider
+}
+
+func (d *LargestFreeCIDRDataSource) Read(ctx context.Context, req datasource.ReadRequest, resp *datasource.ReadResponse) {
+	var data LargestFreeCIDRDataSourceModel
+
+	resp.Diagnostics.Append(req.Config.Get(ctx, &data)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	family := data.AddressFamily.ValueString()
+	if family != "" && family != "ipv4" && family != "ipv6" {
+		resp.Diagnostics.AddAttributeError(
+			path.Root("address_family"),
+			"Invalid Address Family",
+			fmt.Sprintf("address_family must be 'ipv4' or 'ipv6', got '%s'", family),
+		)
+		return
+	}
+
+	poolName := data.PoolName.ValueString()
+	pool, err := d.provider.storage.GetPool(ctx, poolName)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			resp.Diagnostics.AddError(
+				"Pool Not Found",
+				fmt.Sprintf("Pool %s does not exist in storage", poolName),
+			)
+			return
+		}
+		resp.Diagnostics.AddError(
+			"Failed to Read Pool",
+			fmt.Sprintf("Could not read pool from storage: %s", err),
+		)
+		return
+	}
+
+	if family == "" {
+		families := poolAddressFamilies(pool)
+		if len(families) > 1 {
+			resp.Diagnostics.AddAttributeError(
+				path.Root("address_family"),
+				"Address Family Required",
+				fmt.Sprintf("Pool %s has both IPv4 and IPv6 CIDRs, set address_family to choose which to search", poolName),
+			)
+			return
+		}
+		family = "ipv4"
+		if len(families) == 1 {
+			family = families[0]
+		}
+	}
+
+	bits := 32
+	if family == "ipv6" {
+		bits = 128
+	}
+
+	allocations, err := d.provider.storage.ListAllocationsByPool(ctx, poolName)
+	if err != nil {
+		resp.Diagnostics.AddError(
+			"Failed to Read Pool Allocations",
+			fmt.Sprintf("Could not list allocations of pool %s: %s", poolName, err),
+		)
+		return
+	}
+
+	largest := largestFreeCIDR(pool, takenCIDRs(allocations, d.provider.globallyReservedCIDRs), bits)
+
+	// a block smaller than the pool allows can't be allocated, so it doesn't count as free
+	prefixLength := 0
+	if largest != nil {
+		prefixLength, _ = largest.Mask.Size()
+	}
+	if largest == nil || (pool.MaxPrefixLength != 0 && prefixLength > pool.MaxPrefixLength) {
+		resp.Diagnostics.AddError(
+			"Pool Exhausted",
+			fmt.Sprintf("Pool %s has no free %s space left to allocate", poolName, family),
+		)
+		return
+	}
+
+	// a block bigger than the pool allows is cut down to the biggest allocation that fits in it
+	if pool.MinPrefixLength != 0 && prefixLength < pool.MinPrefixLength {
+		prefixLength = pool.MinPrefixLength
+		largest = &net.IPNet{IP: largest.IP, Mask: net.CIDRMask(prefixLength, bits)}
+	}
+
+	data.AddressFamily = types.StringValue(family)
+	data.CIDR = types.StringValue(largest.String())
+	data.PrefixLength = types.Int64Value(int64(prefixLength))
+
+	resp.Diagnostics.Append(resp.State.Set(ctx, &data)...)
+}
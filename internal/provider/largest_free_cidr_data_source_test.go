@@ -0,0 +1,84 @@
+package provider
+
+import (
+	"fmt"
+	"regexp"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-testing/helper/resource"
+	"github.com/hashicorp/terraform-plugin-testing/knownvalue"
+	"github.com/hashicorp/terraform-plugin-testing/statecheck"
+	"github.com/hashicorp/terraform-plugin-testing/tfjsonpath"
+)
+
+func TestAccLargestFreeCIDRDataSource_Basic(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		PreCheck:                 func() { testAccPreCheck(t) },
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			// 10.0.0.32/27, 10.0.0.64/26 and 10.0.0.128/26 are free, the lower of the two /26 wins
+			{
+				Config: testAccLargestFreeCIDRDataSourceConfig("largest-free-pool", "10.0.0.0/27", "10.0.0.192/26"),
+				ConfigStateChecks: []statecheck.StateCheck{
+					statecheck.ExpectKnownValue(
+						"data.tfipam_largest_free_cidr.test",
+						tfjsonpath.New("cidr"),
+						knownvalue.StringExact("10.0.0.64/26"),
+					),
+					statecheck.ExpectKnownValue(
+						"data.tfipam_largest_free_cidr.test",
+						tfjsonpath.New("prefix_length"),
+						knownvalue.Int64Exact(26),
+					),
+					statecheck.ExpectKnownValue(
+						"data.tfipam_largest_free_cidr.test",
+						tfjsonpath.New("address_family"),
+						knownvalue.StringExact("ipv4"),
+					),
+				},
+			},
+		},
+	})
+}
+
+func TestAccLargestFreeCIDRDataSource_Exhausted(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		PreCheck:                 func() { testAccPreCheck(t) },
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config:      testAccLargestFreeCIDRDataSourceConfig("largest-free-full-pool", "10.0.0.0/25", "10.0.0.128/25"),
+				ExpectError: regexp.MustCompile("Pool Exhausted"),
+			},
+		},
+	})
+}
+
+// testAccLargestFreeCIDRDataSourceConfig generates config with a /24 pool holding two allocations
+// of the given CIDRs, and a lookup of the largest block left between them.
+func testAccLargestFreeCIDRDataSourceConfig(poolName, firstCIDR, secondCIDR string) string {
+	return fmt.Sprintf(`
+resource "tfipam_pool" "test" {
+  name  = %[1]q
+  cidrs = ["10.0.0.0/24"]
+}
+
+resource "tfipam_allocation" "first" {
+  id             = "%[1]s-first"
+  pool_name      = tfipam_pool.test.name
+  requested_cidr = %[2]q
+}
+
+resource "tfipam_allocation" "second" {
+  id             = "%[1]s-second"
+  pool_name      = tfipam_pool.test.name
+  requested_cidr = %[3]q
+}
+
+data "tfipam_largest_free_cidr" "test" {
+  pool_name = tfipam_pool.test.name
+
+  depends_on = [tfipam_allocation.first, tfipam_allocation.second]
+}
+`, poolName, firstCIDR, secondCIDR)
+}
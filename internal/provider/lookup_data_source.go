@@ -0,0 +1,232 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/terraform-plugin-framework/datasource"
+	"github.com/hashicorp/terraform-plugin-framework/datasource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/path"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+
+	"terraform-provider-tfipam/internal/provider/storage"
+)
+
+var _ datasource.DataSource = &LookupDataSource{}
+
+func NewLookupDataSource() datasource.DataSource {
+	return &LookupDataSource{}
+}
+
+// LookupDataSource finds the allocation holding an IP address, for tracing an address seen in logs or
+// alerts back to its pool and owner.
+type LookupDataSource struct {
+	provider *IpamProvider
+}
+
+type LookupDataSourceModel struct {
+	IP            types.String `tfsdk:"ip"`
+	ID            types.String `tfsdk:"id"`
+	Key           types.String `tfsdk:"key"`
+	PoolName      types.String `tfsdk:"pool_name"`
+	AllocatedCIDR types.String `tfsdk:"allocated_cidr"`
+	MatchedCIDR   types.String `tfsdk:"matched_cidr"`
+	PrefixLength  types.Int64  `tfsdk:"prefix_length"`
+	Owner         types.String `tfsdk:"owner"`
+	PoolCIDRs     types.List   `tfsdk:"pool_cidrs"`
+}
+
+func (d *LookupDataSource) Metadata(ctx context.Context, req datasource.MetadataRequest, resp *datasource.MetadataResponse) {
+	resp.TypeName = req.ProviderTypeName + "_lookup"
+}
+
+func (d *LookupDataSource) Schema(ctx context.Context, req datasource.SchemaRequest, resp *datasource.SchemaResponse) {
+	resp.Schema = schema.Schema{
+		MarkdownDescription: "IPAM lookup data source for finding the allocation whose CIDR contains an IP address, and its pool",
+
+		Attributes: map[string]schema.Attribute{
+			"ip": schema.StringAttribute{
+				MarkdownDescription: "IPv4 or IPv6 address to look up, without a prefix length",
+				Required:            true,
+			},
+			"id": schema.StringAttribute{
+				MarkdownDescription: "Identifier of the allocation containing the address",
+				Computed:            true,
+			},
+			"key": schema.StringAttribute{
+				MarkdownDescription: "Internal key the allocation is stored under",
+				Computed:            true,
+			},
+			"pool_name": schema.StringAttribute{
+				MarkdownDescription: "Name of the pool the allocation belongs to",
+				Computed:            true,
+			},
+			"allocated_cidr": schema.StringAttribute{
+				MarkdownDescription: "CIDR block allocated to the allocation",
+				Computed:            true,
+			},
+			"matched_cidr": schema.StringAttribute{
+				MarkdownDescription: "Block of the allocation that contains the address. The same as `allocated_cidr` unless the allocation is made of several blocks",
+				Computed:            true,
+			},
+			"prefix_length": schema.Int64Attribute{
+				MarkdownDescription: "Prefix length of the allocated CIDR",
+				Computed:            true,
+			},
+			"owner": schema.StringAttribute{
+				MarkdownDescription: "Team or person accountable for the allocation. Empty when not set",
+				Computed:            true,
+			},
+			"pool_cidrs": schema.ListAttribute{
+				MarkdownDescription: "CIDR blocks of the pool the allocation belongs to",
+				Computed:            true,
+				ElementType:         types.StringType,
+			},
+		},
+	}
+}
+
+func (d *LookupDataSource) Configure(ctx context.Context, req datasource.ConfigureRequest, resp *datasource.ConfigureResponse) {
+	if req.ProviderData == nil {
+		return
+	}
+
+	provider, ok := req.ProviderData.(*IpamProvider)
+	if !ok {
+		resp.Diagnostics.AddError(
+			"Unexpected Resource Configure Type",
+			fmt.Sprintf("Expected *IpamProvider, got: %T", req.ProviderData),
+		)
+		return
+	}
+
+	d.provider = provider
+}
+
+func (d *LookupDataSource) Read(ctx context.Context, req datasource.ReadRequest, resp *datasource.ReadResponse) {
+	var data LookupDataSourceModel
+
+	resp.Diagnostics.Append(req.Config.Get(ctx, &data)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	ip := net.ParseIP(data.IP.ValueString())
+	if ip == nil {
+		resp.Diagnostics.AddAttributeError(
+			path.Root("ip"),
+			"Invalid IP Address",
+			fmt.Sprintf("'%s' is not a valid IPv4 or IPv6 address. Use the address alone, without a prefix length", data.IP.ValueString()),
+		)
+		return
+	}
+
+	allocations, err := d.provider.storage.ListAllocations(ctx)
+	if err != nil {
+		resp.Diagnostics.AddError(
+			"Failed to List Allocations",
+			fmt.Sprintf("Could not list allocations from storage: %s", err),
+		)
+		return
+	}
+
+	matches := containingAllocations(allocations, ip)
+	if len(matches) == 0 {
+		resp.Diagnostics.AddAttributeError(
+			path.Root("ip"),
+			"No Allocation Contains IP",
+			fmt.Sprintf("No allocation in any pool contains %s", ip),
+		)
+		return
+	}
+	if len(matches) > 1 {
+		holders := make([]string, 0, len(matches))
+		for _, match := range matches {
+			holders = append(holders, fmt.Sprintf("%s (%s) in pool %s", match.allocation.ID, match.cidr, match.allocation.PoolName))
+		}
+		resp.Diagnostics.AddAttributeError(
+			path.Root("ip"),
+			"Multiple Allocations Contain IP",
+			fmt.Sprintf("%s is contained in %d overlapping allocations: %s", ip, len(matches), strings.Join(holders, ", ")),
+		)
+		return
+	}
+	allocation, matchedCIDR := matches[0].allocation, matches[0].cidr
+
+	data.ID = types.StringValue(allocation.ID)
+	data.Key = types.StringValue(allocation.StorageKey())
+	data.PoolName = types.StringValue(allocation.PoolName)
+	data.AllocatedCIDR = types.StringValue(allocation.AllocatedCIDR)
+	data.MatchedCIDR = types.StringValue(matchedCIDR)
+	data.PrefixLength = types.Int64Value(int64(allocation.PrefixLength))
+	data.Owner = types.StringValue(allocation.Owner)
+
+	pool, err := d.provider.storage.GetPool(ctx, allocation.PoolName)
+	if err != nil && !errors.Is(err, storage.ErrNotFound) {
+		resp.Diagnostics.AddError(
+			"Failed to Read Pool",
+			fmt.Sprintf("Could not read pool %s from storage: %s", allocation.PoolName, err),
+		)
+		return
+	}
+
+	// the pool may be gone when its allocation was orphaned
+	data.PoolCIDRs = types.ListNull(types.StringType)
+	if pool != nil {
+		poolCIDRs, diag := types.ListValueFrom(ctx, types.StringType, pool.CIDRs)
+		resp.Diagnostics.Append(diag...)
+		if resp.Diagnostics.HasError() {
+			return
+		}
+		data.PoolCIDRs = poolCIDRs
+	}
+
+	resp.Diagnostics.Append(resp.State.Set(ctx, &data)...)
+}
+
+// allocationMatch is an allocation holding an address, with the block of it that does.
+type allocationMatch struct {
+	allocation storage.Allocation
+	cidr       string
+}
+
+// containingAllocations returns the allocations with a block containing the IP, ordered by pool and id.
+// The allocation of a reservation is left out when the reserved pool has an allocation containing the
+// IP too, since that one is what the address is handed out to.
+func containingAllocations(allocations []storage.Allocation, ip net.IP) []allocationMatch {
+	var matches []allocationMatch
+	for _, allocation := range allocations {
+		for _, cidr := range allocation.CIDRs() {
+			_, cidrNet, err := net.ParseCIDR(cidr)
+			if err != nil || !cidrNet.Contains(ip) {
+				continue
+			}
+			matches = append(matches, allocationMatch{allocation: allocation, cidr: cidr})
+			break
+		}
+	}
+
+	matchedPools := make(map[string]bool, len(matches))
+	for _, match := range matches {
+		matchedPools[match.allocation.PoolName] = true
+	}
+	kept := matches[:0]
+	for _, match := range matches {
+		if match.allocation.ReservedPool != "" && matchedPools[match.allocation.ReservedPool] {
+			continue
+		}
+		kept = append(kept, match)
+	}
+
+	sort.Slice(kept, func(i, j int) bool {
+		if kept[i].allocation.PoolName != kept[j].allocation.PoolName {
+			return kept[i].allocation.PoolName < kept[j].allocation.PoolName
+		}
+		return kept[i].allocation.ID < kept[j].allocation.ID
+	})
+	return kept
+}
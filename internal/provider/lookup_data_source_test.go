@@ -0,0 +1,183 @@
+package provider
+
+import (
+	"fmt"
+	"net"
+	"regexp"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-testing/helper/resource"
+	"github.com/hashicorp/terraform-plugin-testing/knownvalue"
+	"github.com/hashicorp/terraform-plugin-testing/statecheck"
+	"github.com/hashicorp/terraform-plugin-testing/tfjsonpath"
+
+	"terraform-provider-tfipam/internal/provider/storage"
+)
+
+func TestAccLookupDataSource_Basic(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		PreCheck:                 func() { testAccPreCheck(t) },
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: testAccLookupDataSourceConfig("lookup-pool-v4", "198.51.100.0/24", 26, "198.51.100.70"),
+				ConfigStateChecks: []statecheck.StateCheck{
+					statecheck.ExpectKnownValue(
+						"data.tfipam_lookup.test",
+						tfjsonpath.New("ip"),
+						knownvalue.StringExact("198.51.100.70"),
+					),
+					statecheck.ExpectKnownValue(
+						"data.tfipam_lookup.test",
+						tfjsonpath.New("id"),
+						knownvalue.StringExact("lookup-pool-v4-second"),
+					),
+					statecheck.ExpectKnownValue(
+						"data.tfipam_lookup.test",
+						tfjsonpath.New("pool_name"),
+						knownvalue.StringExact("lookup-pool-v4"),
+					),
+					statecheck.ExpectKnownValue(
+						"data.tfipam_lookup.test",
+						tfjsonpath.New("allocated_cidr"),
+						knownvalue.StringExact("198.51.100.64/26"),
+					),
+					statecheck.ExpectKnownValue(
+						"data.tfipam_lookup.test",
+						tfjsonpath.New("owner"),
+						knownvalue.StringExact("network-team"),
+					),
+					statecheck.ExpectKnownValue(
+						"data.tfipam_lookup.test",
+						tfjsonpath.New("pool_cidrs"),
+						knownvalue.ListExact([]knownvalue.Check{
+							knownvalue.StringExact("198.51.100.0/24"),
+						}),
+					),
+				},
+			},
+		},
+	})
+}
+
+func TestAccLookupDataSource_IPv6(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		PreCheck:                 func() { testAccPreCheck(t) },
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: testAccLookupDataSourceConfig("lookup-pool-v6", "2001:db8:ab::/48", 64, "2001:db8:ab:1::1"),
+				ConfigStateChecks: []statecheck.StateCheck{
+					statecheck.ExpectKnownValue(
+						"data.tfipam_lookup.test",
+						tfjsonpath.New("ip"),
+						knownvalue.StringExact("2001:db8:ab:1::1"),
+					),
+					statecheck.ExpectKnownValue(
+						"data.tfipam_lookup.test",
+						tfjsonpath.New("id"),
+						knownvalue.StringExact("lookup-pool-v6-second"),
+					),
+					statecheck.ExpectKnownValue(
+						"data.tfipam_lookup.test",
+						tfjsonpath.New("allocated_cidr"),
+						knownvalue.StringExact("2001:db8:ab:1::/64"),
+					),
+				},
+			},
+		},
+	})
+}
+
+func TestAccLookupDataSource_NotFound(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		PreCheck:                 func() { testAccPreCheck(t) },
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: `
+data "tfipam_lookup" "test" {
+  ip = "203.0.113.7"
+}
+`,
+				ExpectError: regexp.MustCompile("No Allocation Contains IP"),
+			},
+			{
+				Config: `
+data "tfipam_lookup" "test" {
+  ip = "203.0.113.0/24"
+}
+`,
+				ExpectError: regexp.MustCompile("Invalid IP Address"),
+			},
+		},
+	})
+}
+
+func TestContainingAllocations(t *testing.T) {
+	allocations := []storage.Allocation{
+		{ID: "web", PoolName: "blue", AllocatedCIDR: "10.0.0.0/24"},
+		{ID: "db", PoolName: "blue", AllocatedCIDR: "10.0.1.0/25", AllocatedCIDRs: []string{"10.0.1.0/25", "10.0.3.0/25"}},
+		{ID: "overlap", PoolName: "blue", AllocatedCIDR: "10.0.0.128/25", AllowOverlap: true},
+		{ID: "v6", PoolName: "green", AllocatedCIDR: "2001:db8::/64"},
+		{ID: "reserved", PoolName: "green", AllocatedCIDR: "10.1.0.0/24", ReservedPool: "team"},
+		{ID: "team-web", PoolName: "team", AllocatedCIDR: "10.1.0.0/26"},
+	}
+
+	tests := map[string]struct {
+		ip   string
+		want []string
+	}{
+		"single":                   {ip: "10.0.0.5", want: []string{"web 10.0.0.0/24"}},
+		"second block":             {ip: "10.0.3.10", want: []string{"db 10.0.3.0/25"}},
+		"overlapping":              {ip: "10.0.0.200", want: []string{"overlap 10.0.0.128/25", "web 10.0.0.0/24"}},
+		"ipv6":                     {ip: "2001:db8::1", want: []string{"v6 2001:db8::/64"}},
+		"reserved pool allocation": {ip: "10.1.0.10", want: []string{"team-web 10.1.0.0/26"}},
+		"reservation":              {ip: "10.1.0.100", want: []string{"reserved 10.1.0.0/24"}},
+		"none":                     {ip: "10.2.0.1", want: nil},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			var got []string
+			for _, match := range containingAllocations(allocations, net.ParseIP(test.ip)) {
+				got = append(got, match.allocation.ID+" "+match.cidr)
+			}
+			if fmt.Sprint(got) != fmt.Sprint(test.want) {
+				t.Errorf("containingAllocations(%s) = %v, want %v", test.ip, got, test.want)
+			}
+		})
+	}
+}
+
+// testAccLookupDataSourceConfig generates config with two allocations of the prefix length made one after
+// the other from a pool with the CIDR, and a lookup of the IP once they exist.
+func testAccLookupDataSourceConfig(poolName string, cidr string, prefixLength int, ip string) string {
+	return fmt.Sprintf(`
+resource "tfipam_pool" "test" {
+  name  = %[1]q
+  cidrs = [%[2]q]
+}
+
+resource "tfipam_allocation" "first" {
+  id            = "%[1]s-first"
+  pool_name     = tfipam_pool.test.name
+  prefix_length = %[3]d
+}
+
+resource "tfipam_allocation" "second" {
+  id            = "%[1]s-second"
+  pool_name     = tfipam_pool.test.name
+  prefix_length = %[3]d
+  owner         = "network-team"
+
+  depends_on = [tfipam_allocation.first]
+}
+
+data "tfipam_lookup" "test" {
+  ip = %[4]q
+
+  depends_on = [tfipam_allocation.second]
+}
+`, poolName, cidr, prefixLength, ip)
+}
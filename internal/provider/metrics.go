@@ -0,0 +1,150 @@
+package provider
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+
+	"terraform-provider-tfipam/internal/provider/storage"
+)
+
+// how long closing the metrics server waits for scrapes that are still being served
+const metricsShutdownTimeout = 5 * time.Second
+
+// metricsServer serves Prometheus gauges of the pools and allocations in storage over HTTP for as
+// long as the provider runs.
+type metricsServer struct {
+	server *http.Server
+	addr   string
+}
+
+// startMetricsServer listens on addr and serves the provider's metrics at /metrics in the background.
+// The listener is opened before returning, so an address that can't be bound fails here.
+func startMetricsServer(ctx context.Context, addr string, p *IpamProvider) (*metricsServer, error) {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/metrics", func(w http.ResponseWriter, r *http.Request) {
+		// render into a buffer so a storage error can still be sent as a 500
+		var metrics bytes.Buffer
+		if err := p.writeMetrics(r.Context(), &metrics); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		_, _ = metrics.WriteTo(w)
+	})
+
+	m := &metricsServer{
+		server: &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second},
+		addr:   listener.Addr().String(),
+	}
+	go func() {
+		if err := m.server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			tflog.Warn(ctx, "metrics server stopped", map[string]any{
+				"addr":  m.addr,
+				"error": err.Error(),
+			})
+		}
+	}()
+
+	return m, nil
+}
+
+// Close stops the server, letting scrapes in progress finish for a few seconds.
+func (m *metricsServer) Close() error {
+	ctx, cancel := context.WithTimeout(context.Background(), metricsShutdownTimeout)
+	defer cancel()
+	return m.server.Shutdown(ctx)
+}
+
+// writeMetrics writes the pool and allocation gauges read from storage in the Prometheus text format.
+// Address counts are split by family, so a pool with IPv4 and IPv6 CIDRs has a series for each.
+func (p *IpamProvider) writeMetrics(ctx context.Context, w io.Writer) error {
+	store := p.storage
+	if store == nil {
+		return errors.New("storage is not configured")
+	}
+
+	snapshot, err := store.Snapshot(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to read storage snapshot: %w", err)
+	}
+	pools, allocations := snapshot.Pools, snapshot.Allocations
+	sort.Slice(pools, func(i, j int) bool {
+		return pools[i].Name < pools[j].Name
+	})
+
+	allocationsByPool := make(map[string][]storage.Allocation)
+	for _, allocation := range allocations {
+		allocationsByPool[allocation.PoolName] = append(allocationsByPool[allocation.PoolName], allocation)
+	}
+
+	fmt.Fprintf(w, "# HELP tfipam_pools Number of pools in storage.\n# TYPE tfipam_pools gauge\ntfipam_pools %d\n", len(pools))
+	fmt.Fprintf(w, "# HELP tfipam_allocations Number of allocations in storage.\n# TYPE tfipam_allocations gauge\ntfipam_allocations %d\n", len(allocations))
+
+	fmt.Fprint(w, "# HELP tfipam_pool_allocations Number of allocations in the pool.\n# TYPE tfipam_pool_allocations gauge\n")
+	for _, pool := range pools {
+		fmt.Fprintf(w, "tfipam_pool_allocations{pool=\"%s\"} %d\n", metricsLabel(pool.Name), len(allocationsByPool[pool.Name]))
+	}
+
+	type familyUsage struct {
+		labels  string
+		total   string
+		used    string
+		percent float64
+	}
+	var usage []familyUsage
+	for i := range pools {
+		pool := &pools[i]
+		for _, family := range []struct {
+			name string
+			bits int
+		}{{"ipv4", 32}, {"ipv6", 128}} {
+			total, used := poolUtilization(pool, allocationsByPool[pool.Name], family.bits)
+			if total.Sign() == 0 {
+				continue
+			}
+			usage = append(usage, familyUsage{
+				labels:  fmt.Sprintf("pool=\"%s\",family=\"%s\"", metricsLabel(pool.Name), family.name),
+				total:   total.String(),
+				used:    used.String(),
+				percent: utilizationPercent(total, used),
+			})
+		}
+	}
+
+	fmt.Fprint(w, "# HELP tfipam_pool_addresses Number of addresses in the pool's CIDRs.\n# TYPE tfipam_pool_addresses gauge\n")
+	for _, u := range usage {
+		fmt.Fprintf(w, "tfipam_pool_addresses{%s} %s\n", u.labels, u.total)
+	}
+	fmt.Fprint(w, "# HELP tfipam_pool_allocated_addresses Number of the pool's addresses taken by allocations.\n# TYPE tfipam_pool_allocated_addresses gauge\n")
+	for _, u := range usage {
+		fmt.Fprintf(w, "tfipam_pool_allocated_addresses{%s} %s\n", u.labels, u.used)
+	}
+	fmt.Fprint(w, "# HELP tfipam_pool_utilization_percent Percentage of the pool's addresses taken by allocations.\n# TYPE tfipam_pool_utilization_percent gauge\n")
+	for _, u := range usage {
+		fmt.Fprintf(w, "tfipam_pool_utilization_percent{%s} %g\n", u.labels, u.percent)
+	}
+
+	return nil
+}
+
+// metricsLabelEscaper escapes a label value for the Prometheus text format.
+var metricsLabelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func metricsLabel(value string) string {
+	return metricsLabelEscaper.Replace(value)
+}
@@ -0,0 +1,183 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/hashicorp/terraform-plugin-framework/datasource"
+	"github.com/hashicorp/terraform-plugin-framework/datasource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/path"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+
+	"terraform-provider-tfipam/internal/provider/storage"
+)
+
+// maxNextCIDRs caps how many blocks a single tfipam_next_cidrs lookup computes.
+const maxNextCIDRs = 256
+
+var _ datasource.DataSource = &NextCIDRsDataSource{}
+
+func NewNextCIDRsDataSource() datasource.DataSource {
+	return &NextCIDRsDataSource{}
+}
+
+type NextCIDRsDataSource struct {
+	provider *IpamProvider
+}
+
+type NextCIDRsDataSourceModel struct {
+	PoolName     types.String `tfsdk:"pool_name"`
+	PrefixLength types.Int64  `tfsdk:"prefix_length"`
+	CIDRCount    types.Int64  `tfsdk:"cidr_count"`
+	CIDRs        types.List   `tfsdk:"cidrs"`
+}
+
+func (d *NextCIDRsDataSource) Metadata(ctx context.Context, req datasource.MetadataRequest, resp *datasource.MetadataResponse) {
+	resp.TypeName = req.ProviderTypeName + "_next_cidrs"
+}
+
+func (d *NextCIDRsDataSource) Schema(ctx context.Context, req datasource.SchemaRequest, resp *datasource.SchemaResponse) {
+	resp.Schema = schema.Schema{
+		MarkdownDescription: "IPAM next CIDRs data source for previewing the next free blocks of a pool without allocating them",
+
+		Attributes: map[string]schema.Attribute{
+			"pool_name": schema.StringAttribute{
+				MarkdownDescription: "Name of the IP pool",
+				Required:            true,
+			},
+			"prefix_length": schema.Int64Attribute{
+				MarkdownDescription: "Prefix length of the blocks",
+				Required:            true,
+			},
+			"cidr_count": schema.Int64Attribute{
+				MarkdownDescription: fmt.Sprintf("Number of blocks to return, between 1 and %d", maxNextCIDRs),
+				Required:            true,
+			},
+			"cidrs": schema.ListAttribute{
+				MarkdownDescription: "The next free, non-overlapping blocks in the order allocations would receive them",
+				Computed:            true,
+				ElementType:         types.StringType,
+			},
+		},
+	}
+}
+
+func (d *NextCIDRsDataSource) Configure(ctx context.Context, req datasource.ConfigureRequest, resp *datasource.ConfigureResponse) {
+	if req.ProviderData == nil {
+		return
+	}
+
+	provider, ok := req.ProviderData.(*IpamProvider)
+	if !ok {
+		resp.Diagnostics.AddError(
+			"Unexpected Resource Configure Type",
+			fmt.Sprintf("Expected *IpamProvider, got: %T", req.ProviderData),
+		)
+		return
+	}
+
+	d.provider = provider
+}
+
+func (d *NextCIDRsDataSource) Read(ctx context.Context, req datasource.ReadRequest, resp *datasource.ReadResponse) {
+	var data NextCIDRsDataSourceModel
+
+	resp.Diagnostics.Append(req.Config.Get(ctx, &data)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	count := int(data.CIDRCount.ValueInt64())
+	if count < 1 || count > maxNextCIDRs {
+		resp.Diagnostics.AddAttributeError(
+			path.Root("cidr_count"),
+			"Invalid CIDR Count",
+			fmt.Sprintf("cidr_count must be between 1 and %d, got %d", maxNextCIDRs, count),
+		)
+		return
+	}
+
+	prefixLength := int(data.PrefixLength.ValueInt64())
+	if prefixLength < 0 || prefixLength > 128 {
+		resp.Diagnostics.AddAttributeError(
+			path.Root("prefix_length"),
+			"Invalid Prefix Length",
+			fmt.Sprintf("Prefix length must be between 0 and 128, got %d", prefixLength),
+		)
+		return
+	}
+
+	poolName := data.PoolName.ValueString()
+	pool, err := d.provider.storage.GetPool(ctx, poolName)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			resp.Diagnostics.AddError(
+				"Pool Not Found",
+				fmt.Sprintf("Pool %s does not exist in storage", poolName),
+			)
+			return
+		}
+		resp.Diagnostics.AddError(
+			"Failed to Read Pool",
+			fmt.Sprintf("Could not read pool from storage: %s", err),
+		)
+		return
+	}
+
+	if err := validatePoolPrefixLength(pool, prefixLength); err != nil {
+		resp.Diagnostics.AddAttributeError(
+			path.Root("prefix_length"),
+			"Invalid Prefix Length",
+			err.Error(),
+		)
+		return
+	}
+
+	allocations, err := d.provider.storage.ListAllocationsByPool(ctx, poolName)
+	if err != nil {
+		resp.Diagnostics.AddError(
+			"Failed to Read Pool Allocations",
+			fmt.Sprintf("Could not list allocations of pool %s: %s", poolName, err),
+		)
+		return
+	}
+
+	cidrs := d.provider.nextFreeCIDRs(pool, allocations, prefixLength, count)
+	if len(cidrs) < count {
+		resp.Diagnostics.AddError(
+			"Not Enough Free CIDRs",
+			fmt.Sprintf("Pool %s only has %d free blocks of size /%d, %d were requested", poolName, len(cidrs), prefixLength, count),
+		)
+		return
+	}
+
+	cidrsList, diags := types.ListValueFrom(ctx, types.StringType, cidrs)
+	resp.Diagnostics.Append(diags...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	data.CIDRs = cidrsList
+
+	resp.Diagnostics.Append(resp.State.Set(ctx, &data)...)
+}
+
+// nextFreeCIDRs searches the pool the way an allocation does for up to limit free blocks of the prefix
+// length, in the order they'd be allocated. Nothing is written, each block found is only treated as
+// taken for the rest of the search.
+func (p *IpamProvider) nextFreeCIDRs(pool *storage.Pool, allocations []storage.Allocation, prefixLength int, limit int) []string {
+	allocatedCIDRs := takenCIDRs(allocations, p.globallyReservedCIDRs)
+	allocatedCIDRs = append(allocatedCIDRs, boundarySubnets(pool, prefixLength)...)
+
+	findCIDR := poolCIDRFinder(pool)
+	cidrs := make([]string, 0, limit)
+	for len(cidrs) < limit {
+		next := findCIDR(prioritizedPoolCIDRs(pool), prefixLength, 0, allocatedCIDRs)
+		if next == nil {
+			break
+		}
+		cidrs = append(cidrs, next.String())
+		allocatedCIDRs = append(allocatedCIDRs, next)
+	}
+	return cidrs
+}
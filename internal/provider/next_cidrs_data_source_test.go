@@ -0,0 +1,131 @@
+package provider
+
+import (
+	"fmt"
+	"regexp"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-testing/helper/resource"
+	"github.com/hashicorp/terraform-plugin-testing/knownvalue"
+	"github.com/hashicorp/terraform-plugin-testing/statecheck"
+	"github.com/hashicorp/terraform-plugin-testing/tfjsonpath"
+)
+
+func TestAccNextCIDRsDataSource_Basic(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		PreCheck:                 func() { testAccPreCheck(t) },
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			// the existing /26 is skipped and the remaining three are returned in order
+			{
+				Config: testAccNextCIDRsDataSourceConfig("next-cidrs-pool", 3),
+				ConfigStateChecks: []statecheck.StateCheck{
+					statecheck.ExpectKnownValue(
+						"data.tfipam_next_cidrs.test",
+						tfjsonpath.New("cidrs"),
+						knownvalue.ListExact([]knownvalue.Check{
+							knownvalue.StringExact("10.0.0.64/26"),
+							knownvalue.StringExact("10.0.0.128/26"),
+							knownvalue.StringExact("10.0.0.192/26"),
+						}),
+					),
+				},
+			},
+		},
+	})
+}
+
+func TestAccNextCIDRsDataSource_Prioritized(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		PreCheck:                 func() { testAccPreCheck(t) },
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			// the second CIDR has the higher priority, so its blocks come first like they do for allocations
+			{
+				Config: testAccNextCIDRsDataSourceConfigPrioritized("next-cidrs-prioritized-pool"),
+				ConfigStateChecks: []statecheck.StateCheck{
+					statecheck.ExpectKnownValue(
+						"data.tfipam_next_cidrs.test",
+						tfjsonpath.New("cidrs"),
+						knownvalue.ListExact([]knownvalue.Check{
+							knownvalue.StringExact("10.4.1.0/25"),
+							knownvalue.StringExact("10.4.1.128/25"),
+							knownvalue.StringExact("10.4.0.0/25"),
+						}),
+					),
+				},
+			},
+		},
+	})
+}
+
+func TestAccNextCIDRsDataSource_NotEnoughFree(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		PreCheck:                 func() { testAccPreCheck(t) },
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config:      testAccNextCIDRsDataSourceConfig("next-cidrs-full-pool", 4),
+				ExpectError: regexp.MustCompile("Not Enough Free CIDRs"),
+			},
+		},
+	})
+}
+
+func TestAccNextCIDRsDataSource_InvalidCount(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		PreCheck:                 func() { testAccPreCheck(t) },
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config:      testAccNextCIDRsDataSourceConfig("next-cidrs-count-pool", 0),
+				ExpectError: regexp.MustCompile("Invalid CIDR Count"),
+			},
+		},
+	})
+}
+
+// testAccNextCIDRsDataSourceConfig generates config with a /24 pool that has its first /26 allocated,
+// and a lookup of the next /26 blocks.
+func testAccNextCIDRsDataSourceConfig(poolName string, count int) string {
+	return fmt.Sprintf(`
+resource "tfipam_pool" "test" {
+  name  = %[1]q
+  cidrs = ["10.0.0.0/24"]
+}
+
+resource "tfipam_allocation" "test" {
+  id            = "%[1]s-alloc"
+  pool_name     = tfipam_pool.test.name
+  prefix_length = 26
+}
+
+data "tfipam_next_cidrs" "test" {
+  pool_name     = tfipam_pool.test.name
+  prefix_length = 26
+  cidr_count    = %[2]d
+
+  depends_on = [tfipam_allocation.test]
+}
+`, poolName, count)
+}
+
+// testAccNextCIDRsDataSourceConfigPrioritized generates config with a pool whose second CIDR is filled
+// first, and a lookup of the next three /25 blocks.
+func testAccNextCIDRsDataSourceConfigPrioritized(poolName string) string {
+	return fmt.Sprintf(`
+resource "tfipam_pool" "test" {
+  name = %[1]q
+  prioritized_cidrs = [
+    { cidr = "10.4.0.0/24", priority = 10 },
+    { cidr = "10.4.1.0/24", priority = 1 },
+  ]
+}
+
+data "tfipam_next_cidrs" "test" {
+  pool_name     = tfipam_pool.test.name
+  prefix_length = 25
+  cidr_count    = 3
+}
+`, poolName)
+}
@@ -0,0 +1,226 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/hashicorp/terraform-plugin-framework/attr"
+	"github.com/hashicorp/terraform-plugin-framework/datasource"
+	"github.com/hashicorp/terraform-plugin-framework/datasource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+
+	"terraform-provider-tfipam/internal/provider/storage"
+)
+
+var _ datasource.DataSource = &PoolAllocationsDataSource{}
+
+func NewPoolAllocationsDataSource() datasource.DataSource {
+	return &PoolAllocationsDataSource{}
+}
+
+// PoolAllocationsDataSource lists every allocation of a pool along with the commands to import them,
+// for adopting existing allocations into a configuration.
+type PoolAllocationsDataSource struct {
+	provider *IpamProvider
+}
+
+type PoolAllocationsDataSourceModel struct {
+	PoolName     types.String `tfsdk:"pool_name"`
+	ResourceName types.String `tfsdk:"resource_name"`
+	Allocations  types.List   `tfsdk:"allocations"`
+	ImportScript types.String `tfsdk:"import_script"`
+	ImportBlocks types.String `tfsdk:"import_blocks"`
+}
+
+// defaultImportResourceName is the resource name import addresses use when resource_name isn't set
+const defaultImportResourceName = "imported"
+
+var poolAllocationsDataSourceAllocationType = types.ObjectType{
+	AttrTypes: map[string]attr.Type{
+		"id":               types.StringType,
+		"key":              types.StringType,
+		"allocated_cidrs":  types.ListType{ElemType: types.StringType},
+		"resource_address": types.StringType,
+	},
+}
+
+func (d *PoolAllocationsDataSource) Metadata(ctx context.Context, req datasource.MetadataRequest, resp *datasource.MetadataResponse) {
+	resp.TypeName = req.ProviderTypeName + "_pool_allocations"
+}
+
+func (d *PoolAllocationsDataSource) Schema(ctx context.Context, req datasource.SchemaRequest, resp *datasource.SchemaResponse) {
+	resp.Schema = schema.Schema{
+		MarkdownDescription: "IPAM pool allocations data source for listing every allocation of a pool and generating the commands to import them",
+
+		Attributes: map[string]schema.Attribute{
+			"pool_name": schema.StringAttribute{
+				MarkdownDescription: "Name of the IP pool",
+				Required:            true,
+			},
+			"resource_name": schema.StringAttribute{
+				MarkdownDescription: "Name of the resource the allocations are imported into, as instances keyed by allocation id, e.g. `tfipam_allocation.imported[\"web\"]`. Defaults to 'imported'",
+				Optional:            true,
+			},
+			"allocations": schema.ListNestedAttribute{
+				MarkdownDescription: "Allocations of the pool, sorted by id",
+				Computed:            true,
+				NestedObject: schema.NestedAttributeObject{
+					Attributes: map[string]schema.Attribute{
+						"id": schema.StringAttribute{
+							MarkdownDescription: "Allocation identifier",
+							Computed:            true,
+						},
+						"key": schema.StringAttribute{
+							MarkdownDescription: "Internal key the allocation is stored under, which the import commands use as import ID",
+							Computed:            true,
+						},
+						"allocated_cidrs": schema.ListAttribute{
+							MarkdownDescription: "Every CIDR block of the allocation, more than one for an allocation made by `tfipam_allocation_blocks`",
+							Computed:            true,
+							ElementType:         types.StringType,
+						},
+						"resource_address": schema.StringAttribute{
+							MarkdownDescription: "Address to import the allocation into, a `tfipam_allocation`, or a `tfipam_allocation_blocks` or `tfipam_reservation` instance for allocations made by those",
+							Computed:            true,
+						},
+					},
+				},
+			},
+			"import_script": schema.StringAttribute{
+				MarkdownDescription: "Shell script with a `terraform import` command for each allocation, importing it by key",
+				Computed:            true,
+			},
+			"import_blocks": schema.StringAttribute{
+				MarkdownDescription: "Terraform `import` blocks for each allocation, to write to a .tf file instead of running the script",
+				Computed:            true,
+			},
+		},
+	}
+}
+
+func (d *PoolAllocationsDataSource) Configure(ctx context.Context, req datasource.ConfigureRequest, resp *datasource.ConfigureResponse) {
+	if req.ProviderData == nil {
+		return
+	}
+
+	provider, ok := req.ProviderData.(*IpamProvider)
+	if !ok {
+		resp.Diagnostics.AddError(
+			"Unexpected Resource Configure Type",
+			fmt.Sprintf("Expected *IpamProvider, got: %T", req.ProviderData),
+		)
+		return
+	}
+
+	d.provider = provider
+}
+
+func (d *PoolAllocationsDataSource) Read(ctx context.Context, req datasource.ReadRequest, resp *datasource.ReadResponse) {
+	var data PoolAllocationsDataSourceModel
+
+	resp.Diagnostics.Append(req.Config.Get(ctx, &data)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	poolName := data.PoolName.ValueString()
+	if _, err := d.provider.storage.GetPool(ctx, poolName); err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			resp.Diagnostics.AddError(
+				"Pool Not Found",
+				fmt.Sprintf("Pool %s does not exist in storage", poolName),
+			)
+			return
+		}
+		resp.Diagnostics.AddError(
+			"Failed to Read Pool",
+			fmt.Sprintf("Could not read pool from storage: %s", err),
+		)
+		return
+	}
+
+	allocations, err := d.provider.storage.ListAllocationsByPool(ctx, poolName)
+	if err != nil {
+		resp.Diagnostics.AddError(
+			"Failed to List Allocations",
+			fmt.Sprintf("Could not list allocations for pool %s: %s", poolName, err),
+		)
+		return
+	}
+	sort.Slice(allocations, func(i, j int) bool {
+		return allocations[i].ID < allocations[j].ID
+	})
+
+	resourceName := data.ResourceName.ValueString()
+	if resourceName == "" {
+		resourceName = defaultImportResourceName
+	}
+
+	var script, blocks strings.Builder
+	allocationValues := make([]attr.Value, 0, len(allocations))
+	for _, allocation := range allocations {
+		address := allocationImportAddress(&allocation, resourceName)
+		// the key is imported rather than the id, which other pools may use too
+		key := allocation.StorageKey()
+		fmt.Fprintf(&script, "terraform import %s %s\n", shellQuote(address), shellQuote(key))
+		fmt.Fprintf(&blocks, "import {\n  to = %s\n  id = %s\n}\n\n", address, hclQuote(key))
+
+		cidrs, diag := types.ListValueFrom(ctx, types.StringType, allocation.CIDRs())
+		resp.Diagnostics.Append(diag...)
+		if resp.Diagnostics.HasError() {
+			return
+		}
+		allocationValue, diag := types.ObjectValue(poolAllocationsDataSourceAllocationType.AttrTypes, map[string]attr.Value{
+			"id":               types.StringValue(allocation.ID),
+			"key":              types.StringValue(key),
+			"allocated_cidrs":  cidrs,
+			"resource_address": types.StringValue(address),
+		})
+		resp.Diagnostics.Append(diag...)
+		if resp.Diagnostics.HasError() {
+			return
+		}
+		allocationValues = append(allocationValues, allocationValue)
+	}
+
+	allocationsList, diag := types.ListValue(poolAllocationsDataSourceAllocationType, allocationValues)
+	resp.Diagnostics.Append(diag...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	data.Allocations = allocationsList
+	data.ImportScript = types.StringValue(script.String())
+	data.ImportBlocks = types.StringValue(strings.TrimSuffix(blocks.String(), "\n"))
+
+	resp.Diagnostics.Append(resp.State.Set(ctx, &data)...)
+}
+
+// allocationImportAddress returns the address of the resource instance an allocation is imported into,
+// keyed by its id. Allocations of several blocks can only be managed by tfipam_allocation_blocks, and
+// the allocations of reservations by tfipam_reservation.
+func allocationImportAddress(allocation *storage.Allocation, resourceName string) string {
+	resourceType := "tfipam_allocation"
+	switch {
+	case allocation.ReservedPool != "":
+		resourceType = "tfipam_reservation"
+	case len(allocation.AllocatedCIDRs) > 0:
+		resourceType = "tfipam_allocation_blocks"
+	}
+	return fmt.Sprintf("%s.%s[%s]", resourceType, resourceName, hclQuote(allocation.ID))
+}
+
+// hclQuote returns s as an HCL string literal, with template sequences escaped so they're kept literally.
+func hclQuote(s string) string {
+	quoted := strconv.Quote(s)
+	quoted = strings.ReplaceAll(quoted, "${", "$${")
+	return strings.ReplaceAll(quoted, "%{", "%%{")
+}
+
+// shellQuote returns s single quoted for a POSIX shell.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
@@ -0,0 +1,97 @@
+package provider
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-testing/helper/resource"
+	"github.com/hashicorp/terraform-plugin-testing/knownvalue"
+	"github.com/hashicorp/terraform-plugin-testing/statecheck"
+	"github.com/hashicorp/terraform-plugin-testing/tfjsonpath"
+)
+
+func TestAccPoolAllocationsDataSource(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		PreCheck:                 func() { testAccPreCheck(t) },
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: testAccPoolAllocationsDataSourceConfig,
+				ConfigStateChecks: []statecheck.StateCheck{
+					statecheck.ExpectKnownValue(
+						"data.tfipam_pool_allocations.test",
+						tfjsonpath.New("allocations"),
+						knownvalue.ListExact([]knownvalue.Check{
+							knownvalue.ObjectExact(map[string]knownvalue.Check{
+								"id":  knownvalue.StringExact("adopt-blocks"),
+								"key": knownvalue.NotNull(),
+								"allocated_cidrs": knownvalue.ListExact([]knownvalue.Check{
+									knownvalue.StringExact("10.2.0.64/26"),
+									knownvalue.StringExact("10.2.0.192/26"),
+								}),
+								"resource_address": knownvalue.StringExact(`tfipam_allocation_blocks.legacy["adopt-blocks"]`),
+							}),
+							knownvalue.ObjectExact(map[string]knownvalue.Check{
+								"id":               knownvalue.StringExact("adopt-first"),
+								"key":              knownvalue.NotNull(),
+								"allocated_cidrs":  knownvalue.ListExact([]knownvalue.Check{knownvalue.StringExact("10.2.0.0/26")}),
+								"resource_address": knownvalue.StringExact(`tfipam_allocation.legacy["adopt-first"]`),
+							}),
+							knownvalue.ObjectExact(map[string]knownvalue.Check{
+								"id":               knownvalue.StringExact("adopt-third"),
+								"key":              knownvalue.NotNull(),
+								"allocated_cidrs":  knownvalue.ListExact([]knownvalue.Check{knownvalue.StringExact("10.2.0.128/26")}),
+								"resource_address": knownvalue.StringExact(`tfipam_allocation.legacy["adopt-third"]`),
+							}),
+						}),
+					),
+					statecheck.ExpectKnownValue(
+						"data.tfipam_pool_allocations.test",
+						tfjsonpath.New("import_script"),
+						// allocations are imported by their generated keys
+						knownvalue.StringRegexp(regexp.MustCompile(`^terraform import 'tfipam_allocation_blocks.legacy\["adopt-blocks"\]' '[0-9a-f-]{36}'
+terraform import 'tfipam_allocation.legacy\["adopt-first"\]' '[0-9a-f-]{36}'
+terraform import 'tfipam_allocation.legacy\["adopt-third"\]' '[0-9a-f-]{36}'
+$`)),
+					),
+				},
+			},
+		},
+	})
+}
+
+// testAccPoolAllocationsDataSourceConfig has a pool with two single CIDR allocations and an allocation of
+// blocks filling the space between them.
+const testAccPoolAllocationsDataSourceConfig = `
+resource "tfipam_pool" "test" {
+  name  = "adopt-pool"
+  cidrs = ["10.2.0.0/24"]
+}
+
+resource "tfipam_allocation" "first" {
+  id             = "adopt-first"
+  pool_name      = tfipam_pool.test.name
+  requested_cidr = "10.2.0.0/26"
+}
+
+resource "tfipam_allocation" "third" {
+  id             = "adopt-third"
+  pool_name      = tfipam_pool.test.name
+  requested_cidr = "10.2.0.128/26"
+}
+
+resource "tfipam_allocation_blocks" "blocks" {
+  id         = "adopt-blocks"
+  pool_name  = tfipam_pool.test.name
+  total_size = 128
+
+  depends_on = [tfipam_allocation.first, tfipam_allocation.third]
+}
+
+data "tfipam_pool_allocations" "test" {
+  pool_name     = tfipam_pool.test.name
+  resource_name = "legacy"
+
+  depends_on = [tfipam_allocation_blocks.blocks]
+}
+`
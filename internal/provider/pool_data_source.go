@@ -2,16 +2,23 @@ package provider
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"terraform-provider-tfipam/internal/provider/storage"
 
 	"github.com/hashicorp/terraform-plugin-framework/datasource"
 	"github.com/hashicorp/terraform-plugin-framework/datasource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/path"
 	"github.com/hashicorp/terraform-plugin-framework/types"
 )
 
 var _ datasource.DataSource = &PoolDataSource{}
 
+// maxPoolFreeBlocks caps how many free blocks a tfipam_pool lookup returns, since a large IPv6 pool
+// can hold more blocks of a size than are worth listing.
+const maxPoolFreeBlocks = 256
+
 func NewPoolDataSource() datasource.DataSource {
 	return &PoolDataSource{}
 }
@@ -21,8 +28,15 @@ type PoolDataSource struct {
 }
 
 type PoolDataSourceModel struct {
-	Name  types.String `tfsdk:"name"`
-	CIDRs types.List   `tfsdk:"cidrs"`
+	Name                    types.String `tfsdk:"name"`
+	CIDRs                   types.List   `tfsdk:"cidrs"`
+	Ranges                  types.List   `tfsdk:"ranges"`
+	AllocationCount         types.Int64  `tfsdk:"allocation_count"`
+	TotalAllocatedAddresses types.String `tfsdk:"total_allocated_addresses"`
+	IncludeFreeBlocks       types.Bool   `tfsdk:"include_free_blocks"`
+	NextPrefixLength        types.Int64  `tfsdk:"next_prefix_length"`
+	FreeBlocks              types.List   `tfsdk:"free_blocks"`
+	FreeBlocksTruncated     types.Bool   `tfsdk:"free_blocks_truncated"`
 }
 
 func (d *PoolDataSource) Metadata(ctx context.Context, req datasource.MetadataRequest, resp *datasource.MetadataResponse) {
@@ -43,6 +57,36 @@ func (d *PoolDataSource) Schema(ctx context.Context, req datasource.SchemaReques
 				Computed:            true,
 				ElementType:         types.StringType,
 			},
+			"ranges": schema.ListAttribute{
+				MarkdownDescription: "Address ranges in the pool in `start-end` format",
+				Computed:            true,
+				ElementType:         types.StringType,
+			},
+			"allocation_count": schema.Int64Attribute{
+				MarkdownDescription: "Number of allocations in the pool",
+				Computed:            true,
+			},
+			"total_allocated_addresses": schema.StringAttribute{
+				MarkdownDescription: "Total number of addresses across the pool's allocations. A string since IPv6 counts overflow a number",
+				Computed:            true,
+			},
+			"include_free_blocks": schema.BoolAttribute{
+				MarkdownDescription: "Return the pool's free blocks of `next_prefix_length` in `free_blocks`. Defaults to false",
+				Optional:            true,
+			},
+			"next_prefix_length": schema.Int64Attribute{
+				MarkdownDescription: "Prefix length of the free blocks returned with `include_free_blocks`, which requires it",
+				Optional:            true,
+			},
+			"free_blocks": schema.ListAttribute{
+				MarkdownDescription: fmt.Sprintf("Free blocks of `next_prefix_length` in the order they'd be allocated, up to %d. Null unless `include_free_blocks` is set", maxPoolFreeBlocks),
+				Computed:            true,
+				ElementType:         types.StringType,
+			},
+			"free_blocks_truncated": schema.BoolAttribute{
+				MarkdownDescription: fmt.Sprintf("Whether the pool has more free blocks than the %d returned in `free_blocks`. Null unless `include_free_blocks` is set", maxPoolFreeBlocks),
+				Computed:            true,
+			},
 		},
 	}
 }
@@ -76,7 +120,7 @@ func (d *PoolDataSource) Read(ctx context.Context, req datasource.ReadRequest, r
 	pool, err := d.provider.storage.GetPool(ctx, data.Name.ValueString())
 	if err != nil {
 		// handle not found error by removing resource from state
-		if err == storage.ErrNotFound {
+		if errors.Is(err, storage.ErrNotFound) {
 			resp.State.RemoveResource(ctx)
 			return
 		}
@@ -95,5 +139,84 @@ func (d *PoolDataSource) Read(ctx context.Context, req datasource.ReadRequest, r
 	}
 	data.CIDRs = cidrs
 
+	ranges, diag := types.ListValueFrom(ctx, types.StringType, pool.Ranges)
+	resp.Diagnostics.Append(diag...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	data.Ranges = ranges
+
+	data.AllocationCount, data.TotalAllocatedAddresses, err = poolAllocationCounters(ctx, d.provider.storage, pool.Name)
+	if err != nil {
+		resp.Diagnostics.AddError(
+			"Failed to Read Pool Allocations",
+			fmt.Sprintf("Could not list allocations of pool %s: %s", pool.Name, err),
+		)
+		return
+	}
+
+	data.FreeBlocks = types.ListNull(types.StringType)
+	data.FreeBlocksTruncated = types.BoolNull()
+	if data.IncludeFreeBlocks.ValueBool() {
+		resp.Diagnostics.Append(d.readFreeBlocks(ctx, pool, &data)...)
+		if resp.Diagnostics.HasError() {
+			return
+		}
+	}
+
 	resp.Diagnostics.Append(resp.State.Set(ctx, &data)...)
 }
+
+// readFreeBlocks sets the free blocks of next_prefix_length, searched the same way as an allocation of
+// that size.
+func (d *PoolDataSource) readFreeBlocks(ctx context.Context, pool *storage.Pool, data *PoolDataSourceModel) diag.Diagnostics {
+	var diags diag.Diagnostics
+
+	if data.NextPrefixLength.IsNull() {
+		diags.AddAttributeError(
+			path.Root("next_prefix_length"),
+			"Missing Next Prefix Length",
+			"next_prefix_length must be set to list the free blocks of that size with include_free_blocks",
+		)
+		return diags
+	}
+	prefixLength := int(data.NextPrefixLength.ValueInt64())
+	if prefixLength < 0 || prefixLength > 128 {
+		diags.AddAttributeError(
+			path.Root("next_prefix_length"),
+			"Invalid Prefix Length",
+			fmt.Sprintf("Prefix length must be between 0 and 128, got %d", prefixLength),
+		)
+		return diags
+	}
+	if err := validatePoolPrefixLength(pool, prefixLength); err != nil {
+		diags.AddAttributeError(
+			path.Root("next_prefix_length"),
+			"Invalid Prefix Length",
+			err.Error(),
+		)
+		return diags
+	}
+
+	allocations, err := d.provider.storage.ListAllocationsByPool(ctx, pool.Name)
+	if err != nil {
+		diags.AddError(
+			"Failed to Read Pool Allocations",
+			fmt.Sprintf("Could not list allocations of pool %s: %s", pool.Name, err),
+		)
+		return diags
+	}
+
+	// one more than the cap tells whether there are more
+	cidrs := d.provider.nextFreeCIDRs(pool, allocations, prefixLength, maxPoolFreeBlocks+1)
+	truncated := len(cidrs) > maxPoolFreeBlocks
+	if truncated {
+		cidrs = cidrs[:maxPoolFreeBlocks]
+	}
+
+	freeBlocks, listDiags := types.ListValueFrom(ctx, types.StringType, cidrs)
+	diags.Append(listDiags...)
+	data.FreeBlocks = freeBlocks
+	data.FreeBlocksTruncated = types.BoolValue(truncated)
+	return diags
+}
@@ -176,6 +176,117 @@ func TestAccPoolDataSource_WithAllocations(t *testing.T) {
 	})
 }
 
+func TestAccPoolDataSource_AllocationCounters(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		PreCheck:                 func() { testAccPreCheck(t) },
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: testAccPoolDataSourceConfigWithAllocations("pool-counters", []string{"10.0.0.0/16"}) + `
+data "tfipam_pool" "after_allocations" {
+  name       = tfipam_pool.test.name
+  depends_on = [tfipam_allocation.test1, tfipam_allocation.test2]
+}
+`,
+				ConfigStateChecks: []statecheck.StateCheck{
+					statecheck.ExpectKnownValue(
+						"data.tfipam_pool.after_allocations",
+						tfjsonpath.New("allocation_count"),
+						knownvalue.Int64Exact(2),
+					),
+					// a /24 and a /27
+					statecheck.ExpectKnownValue(
+						"data.tfipam_pool.after_allocations",
+						tfjsonpath.New("total_allocated_addresses"),
+						knownvalue.StringExact("288"),
+					),
+				},
+			},
+		},
+	})
+}
+
+func TestAccPoolDataSource_FreeBlocks(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		PreCheck:                 func() { testAccPreCheck(t) },
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			// The /24 and /27 allocations leave two free /24s, and the free blocks stay null without the flag
+			{
+				Config: testAccPoolDataSourceConfigWithAllocations("pool-free-blocks", []string{"10.0.0.0/22"}) + `
+data "tfipam_pool" "free_blocks" {
+  name                = tfipam_pool.test.name
+  include_free_blocks = true
+  next_prefix_length  = 24
+  depends_on          = [tfipam_allocation.test1, tfipam_allocation.test2]
+}
+`,
+				ConfigStateChecks: []statecheck.StateCheck{
+					statecheck.ExpectKnownValue(
+						"data.tfipam_pool.free_blocks",
+						tfjsonpath.New("free_blocks"),
+						knownvalue.ListExact([]knownvalue.Check{
+							knownvalue.StringExact("10.0.2.0/24"),
+							knownvalue.StringExact("10.0.3.0/24"),
+						}),
+					),
+					statecheck.ExpectKnownValue(
+						"data.tfipam_pool.free_blocks",
+						tfjsonpath.New("free_blocks_truncated"),
+						knownvalue.Bool(false),
+					),
+					statecheck.ExpectKnownValue(
+						"data.tfipam_pool.test",
+						tfjsonpath.New("free_blocks"),
+						knownvalue.Null(),
+					),
+				},
+			},
+			// An IPv6 pool's blocks are capped
+			{
+				Config: `
+resource "tfipam_pool" "ipv6" {
+  name  = "pool-free-blocks-ipv6"
+  cidrs = ["2001:db8::/48"]
+}
+
+data "tfipam_pool" "ipv6" {
+  name                = tfipam_pool.ipv6.name
+  include_free_blocks = true
+  next_prefix_length  = 64
+}
+`,
+				ConfigStateChecks: []statecheck.StateCheck{
+					statecheck.ExpectKnownValue(
+						"data.tfipam_pool.ipv6",
+						tfjsonpath.New("free_blocks"),
+						knownvalue.ListSizeExact(maxPoolFreeBlocks),
+					),
+					statecheck.ExpectKnownValue(
+						"data.tfipam_pool.ipv6",
+						tfjsonpath.New("free_blocks_truncated"),
+						knownvalue.Bool(true),
+					),
+				},
+			},
+			{
+				Config: `
+resource "tfipam_pool" "ipv6" {
+  name  = "pool-free-blocks-ipv6"
+  cidrs = ["2001:db8::/48"]
+}
+
+data "tfipam_pool" "ipv6" {
+  name                = tfipam_pool.ipv6.name
+  include_free_blocks = true
+}
+`,
+				ExpectError: regexp.MustCompile("Missing Next Prefix Length"),
+			},
+		},
+	})
+}
+
 func TestAccPoolDataSource_UpdateResource(t *testing.T) {
 	resource.Test(t, resource.TestCase{
 		PreCheck:                 func() { testAccPreCheck(t) },
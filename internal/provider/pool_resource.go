@@ -2,14 +2,21 @@ package provider
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net"
+	"slices"
 	"strings"
 
+	"github.com/hashicorp/terraform-plugin-framework/attr"
+	"github.com/hashicorp/terraform-plugin-framework/diag"
 	"github.com/hashicorp/terraform-plugin-framework/path"
 	"github.com/hashicorp/terraform-plugin-framework/resource"
 	"github.com/hashicorp/terraform-plugin-framework/resource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/booldefault"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/listplanmodifier"
 	"github.com/hashicorp/terraform-plugin-framework/resource/schema/planmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/stringdefault"
 	"github.com/hashicorp/terraform-plugin-framework/resource/schema/stringplanmodifier"
 	"github.com/hashicorp/terraform-plugin-framework/types"
 	"github.com/hashicorp/terraform-plugin-log/tflog"
@@ -19,6 +26,14 @@ import (
 
 var _ resource.Resource = &PoolResource{}
 var _ resource.ResourceWithImportState = &PoolResource{}
+var _ resource.ResourceWithValidateConfig = &PoolResource{}
+var _ resource.ResourceWithModifyPlan = &PoolResource{}
+
+// allocation strategies of a pool, see storage.Pool.Strategy
+const (
+	poolStrategyFirstFit = "first_fit"
+	poolStrategyBestFit  = "best_fit"
+)
 
 func NewPoolResource() resource.Resource {
 	return &PoolResource{}
@@ -29,8 +44,38 @@ type PoolResource struct {
 }
 
 type PoolResourceModel struct {
-	Name  types.String `tfsdk:"name"`
-	CIDRs types.List   `tfsdk:"cidrs"`
+	Name             types.String `tfsdk:"name"`
+	CIDRs            types.List   `tfsdk:"cidrs"`
+	PrioritizedCIDRs types.List   `tfsdk:"prioritized_cidrs"`
+	Supernet         types.String `tfsdk:"supernet"`
+	ReservedCIDRs    types.List   `tfsdk:"reserved_cidrs"`
+	Ranges           types.List   `tfsdk:"ranges"`
+	MinPrefixLength  types.Int64  `tfsdk:"min_prefix_length"`
+	MaxPrefixLength  types.Int64  `tfsdk:"max_prefix_length"`
+	DefaultPrefix    types.Int64  `tfsdk:"default_prefix_length"`
+	AutoExpandCIDR   types.String `tfsdk:"auto_expand_cidr"`
+	AutoExpandPrefix types.Int64  `tfsdk:"auto_expand_prefix_length"`
+	ExpandedCIDRs    types.List   `tfsdk:"expanded_cidrs"`
+	SkipBoundary     types.Bool   `tfsdk:"skip_boundary_subnets"`
+	Strategy         types.String `tfsdk:"strategy"`
+	ForceDestroy     types.Bool   `tfsdk:"force_destroy"`
+	Revision         types.Int64  `tfsdk:"revision"`
+	IPVersion        types.Int64  `tfsdk:"ip_version"`
+
+	AllocationCount         types.Int64  `tfsdk:"allocation_count"`
+	TotalAllocatedAddresses types.String `tfsdk:"total_allocated_addresses"`
+}
+
+type PoolPrioritizedCIDRModel struct {
+	CIDR     types.String `tfsdk:"cidr"`
+	Priority types.Int64  `tfsdk:"priority"`
+}
+
+var poolPrioritizedCIDRType = types.ObjectType{
+	AttrTypes: map[string]attr.Type{
+		"cidr":     types.StringType,
+		"priority": types.Int64Type,
+	},
 }
 
 func (r *PoolResource) Metadata(ctx context.Context, req resource.MetadataRequest, resp *resource.MetadataResponse) {
@@ -51,8 +96,101 @@ func (r *PoolResource) Schema(ctx context.Context, req resource.SchemaRequest, r
 			},
 			"cidrs": schema.ListAttribute{
 				ElementType:         types.StringType,
-				Required:            true,
-				MarkdownDescription: "List of CIDR blocks in the pool",
+				Optional:            true,
+				Computed:            true,
+				MarkdownDescription: "List of CIDR blocks in the pool, filled in order. Each block may only be listed once. At most one of `cidrs`, `supernet` or `prioritized_cidrs` may be set, and at least one of them or `ranges`. Computed from `supernet` and `reserved_cidrs` when `supernet` is used, and from `prioritized_cidrs` when that is used",
+			},
+			"prioritized_cidrs": schema.ListNestedAttribute{
+				Optional:            true,
+				MarkdownDescription: "CIDR blocks in the pool with the order allocations fill them in, as an alternative to `cidrs`. CIDRs with a lower priority are allocated from first, and CIDRs with the same priority in the order listed",
+				NestedObject: schema.NestedAttributeObject{
+					Attributes: map[string]schema.Attribute{
+						"cidr": schema.StringAttribute{
+							Required:            true,
+							MarkdownDescription: "CIDR block in the pool",
+						},
+						"priority": schema.Int64Attribute{
+							Required:            true,
+							MarkdownDescription: "Fill priority of the CIDR block, lower values are allocated from first. Ranges in `ranges` have priority 0",
+						},
+					},
+				},
+			},
+			"supernet": schema.StringAttribute{
+				Optional:            true,
+				MarkdownDescription: "CIDR block the pool is carved from. The pool CIDRs are the supernet minus `reserved_cidrs`",
+			},
+			"reserved_cidrs": schema.ListAttribute{
+				ElementType:         types.StringType,
+				Optional:            true,
+				MarkdownDescription: "CIDR blocks inside `supernet` that are excluded from the pool. An allocation requesting a CIDR that overlaps one of them is rejected. Requires `supernet`",
+			},
+			"ranges": schema.ListAttribute{
+				ElementType:         types.StringType,
+				Optional:            true,
+				MarkdownDescription: "Address ranges in the pool in `start-end` format, e.g. `10.0.0.10-10.0.0.250`. Both ends are inclusive. Allocations are made from the CIDR blocks covering each range, alongside `cidrs`",
+			},
+			"min_prefix_length": schema.Int64Attribute{
+				Optional:            true,
+				MarkdownDescription: "Smallest prefix length (largest block) that allocations from this pool may request",
+			},
+			"max_prefix_length": schema.Int64Attribute{
+				Optional:            true,
+				MarkdownDescription: "Largest prefix length (smallest block) that allocations from this pool may request",
+			},
+			"default_prefix_length": schema.Int64Attribute{
+				Optional:            true,
+				MarkdownDescription: "Prefix length of allocations from this pool that set neither `prefix_length`, `requested_cidr` nor `subnet_count`. It's resolved when the allocation is created, so changing it doesn't affect existing allocations. Must be between `min_prefix_length` and `max_prefix_length`. Without it those allocations fail",
+			},
+			"auto_expand_cidr": schema.StringAttribute{
+				Optional:            true,
+				MarkdownDescription: "CIDR block the pool grows from when it's exhausted. When an allocation doesn't fit, a free block of `auto_expand_prefix_length` is carved from it and added to `expanded_cidrs`, and the allocation is retried. Blocks overlapping the CIDRs of any pool are skipped. Requires `auto_expand_prefix_length`",
+			},
+			"auto_expand_prefix_length": schema.Int64Attribute{
+				Optional:            true,
+				MarkdownDescription: "Prefix length of the blocks added to the pool from `auto_expand_cidr`. An allocation larger than that gets a block of its own size. Requires `auto_expand_cidr`",
+			},
+			"expanded_cidrs": schema.ListAttribute{
+				ElementType:         types.StringType,
+				Computed:            true,
+				MarkdownDescription: "CIDR blocks added to the pool from `auto_expand_cidr`, in the order they were added. They stay in the pool when `auto_expand_cidr` is removed",
+				PlanModifiers: []planmodifier.List{
+					listplanmodifier.UseStateForUnknown(),
+				},
+			},
+			"skip_boundary_subnets": schema.BoolAttribute{
+				Optional:            true,
+				Computed:            true,
+				Default:             booldefault.StaticBool(false),
+				MarkdownDescription: "Never allocate the first or last block of each IPv4 CIDR of the pool, its all-zeros and all-ones subnets, for networks with older routers that can't use them. A block the size of the whole CIDR is still allocated. Defaults to false",
+			},
+			"strategy": schema.StringAttribute{
+				Optional:            true,
+				Computed:            true,
+				Default:             stringdefault.StaticString(poolStrategyFirstFit),
+				MarkdownDescription: "How new allocations pick a free block. `first_fit` takes the lowest free block in the order of the pool's CIDRs. `best_fit` takes the smallest free gap the allocation fits in, keeping larger gaps whole for larger allocations, which limits fragmentation in long-lived pools with allocations of varied sizes. Defaults to `first_fit`",
+			},
+			"force_destroy": schema.BoolAttribute{
+				Optional:            true,
+				Computed:            true,
+				Default:             booldefault.StaticBool(false),
+				MarkdownDescription: "When true, deleting the pool also deletes all of its allocations. Defaults to false, which refuses to delete a pool that still has allocations.",
+			},
+			"revision": schema.Int64Attribute{
+				Computed:            true,
+				MarkdownDescription: "Revision of the pool in storage, incremented on every change. Updates based on an older revision are rejected so concurrent edits aren't lost",
+			},
+			"ip_version": schema.Int64Attribute{
+				Computed:            true,
+				MarkdownDescription: "IP version of the pool, 4 or 6, when all of its CIDRs and ranges are in the same address family. Null for a pool with both IPv4 and IPv6 CIDRs",
+			},
+			"allocation_count": schema.Int64Attribute{
+				Computed:            true,
+				MarkdownDescription: "Number of allocations in the pool. Computed on every read, so it follows allocations made after the pool was last changed",
+			},
+			"total_allocated_addresses": schema.StringAttribute{
+				Computed:            true,
+				MarkdownDescription: "Total number of addresses across the pool's allocations. A string since IPv6 counts overflow a number",
 			},
 		},
 	}
@@ -75,6 +213,123 @@ func (r *PoolResource) Configure(ctx context.Context, req resource.ConfigureRequ
 	r.provider = provider
 }
 
+func (r *PoolResource) ValidateConfig(ctx context.Context, req resource.ValidateConfigRequest, resp *resource.ValidateConfigResponse) {
+	var data PoolResourceModel
+
+	resp.Diagnostics.Append(req.Config.Get(ctx, &data)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	if strategy := data.Strategy.ValueString(); !data.Strategy.IsUnknown() && !data.Strategy.IsNull() &&
+		strategy != poolStrategyFirstFit && strategy != poolStrategyBestFit {
+		resp.Diagnostics.AddAttributeError(
+			path.Root("strategy"),
+			"Invalid Allocation Strategy",
+			fmt.Sprintf("strategy must be %q or %q, got %q", poolStrategyFirstFit, poolStrategyBestFit, strategy),
+		)
+	}
+
+	// unknown values can't be checked until apply
+	if data.CIDRs.IsUnknown() || data.Supernet.IsUnknown() || data.PrioritizedCIDRs.IsUnknown() {
+		return
+	}
+
+	definitions := 0
+	for _, value := range []attr.Value{data.CIDRs, data.Supernet, data.PrioritizedCIDRs} {
+		if !value.IsNull() {
+			definitions++
+		}
+	}
+	if definitions > 1 {
+		resp.Diagnostics.AddAttributeError(
+			path.Root("cidrs"),
+			"Invalid Pool Definition",
+			"Only one of cidrs, supernet or prioritized_cidrs can be set",
+		)
+		return
+	}
+
+	if definitions == 0 && data.Ranges.IsNull() {
+		resp.Diagnostics.AddAttributeError(
+			path.Root("cidrs"),
+			"Invalid Pool Definition",
+			"At least one of cidrs, supernet, prioritized_cidrs or ranges must be set",
+		)
+		return
+	}
+
+	if !data.ReservedCIDRs.IsNull() && data.Supernet.IsNull() {
+		resp.Diagnostics.AddAttributeError(
+			path.Root("reserved_cidrs"),
+			"Invalid Pool Definition",
+			"reserved_cidrs can only be used together with supernet",
+		)
+	}
+
+	resp.Diagnostics.Append(validateAutoExpand(&data)...)
+}
+
+func (r *PoolResource) ModifyPlan(ctx context.Context, req resource.ModifyPlanRequest, resp *resource.ModifyPlanResponse) {
+	// nothing to compute on destroy
+	if req.Plan.Raw.IsNull() {
+		return
+	}
+
+	var data PoolResourceModel
+
+	resp.Diagnostics.Append(req.Plan.Get(ctx, &data)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	// renaming replaces the pool, which is blocked while the old pool still has allocations
+	if !req.State.Raw.IsNull() && r.provider != nil {
+		var state PoolResourceModel
+		resp.Diagnostics.Append(req.State.Get(ctx, &state)...)
+		if resp.Diagnostics.HasError() {
+			return
+		}
+
+		if !data.Name.IsUnknown() && data.Name.ValueString() != state.Name.ValueString() && !state.ForceDestroy.ValueBool() {
+			allocations, err := r.provider.storage.ListAllocationsByPool(ctx, state.Name.ValueString())
+			if err == nil && len(allocations) > 0 {
+				resp.Diagnostics.AddAttributeWarning(
+					path.Root("name"),
+					"Pool Rename Requires Empty Pool",
+					fmt.Sprintf("Renaming pool %s replaces it, and it currently has %d allocations. Allocations that reference the pool by attribute are replaced along with it, "+
+						"any others must be deleted first or the pool delete will fail.", state.Name.ValueString(), len(allocations)),
+				)
+			}
+		}
+	}
+
+	// cidrs are only computed from a supernet or prioritized cidrs, once they're fully known
+	if data.Supernet.IsNull() && data.PrioritizedCIDRs.IsNull() {
+		return
+	}
+	for _, value := range []attr.Value{data.Supernet, data.ReservedCIDRs, data.PrioritizedCIDRs} {
+		tfValue, err := value.ToTerraformValue(ctx)
+		if err != nil || !tfValue.IsFullyKnown() {
+			return
+		}
+	}
+
+	// show the computed pool cidrs in the plan
+	cidrs, diags := resolvePoolCIDRs(ctx, &data)
+	resp.Diagnostics.Append(diags...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	cidrsList, diags := types.ListValueFrom(ctx, types.StringType, cidrs)
+	resp.Diagnostics.Append(diags...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	resp.Diagnostics.Append(resp.Plan.SetAttribute(ctx, path.Root("cidrs"), cidrsList)...)
+}
+
 func (r *PoolResource) Create(ctx context.Context, req resource.CreateRequest, resp *resource.CreateResponse) {
 	var data PoolResourceModel
 
@@ -84,35 +339,83 @@ func (r *PoolResource) Create(ctx context.Context, req resource.CreateRequest, r
 	}
 
 	// validate cidrs
-	var cidrs []string
-	resp.Diagnostics.Append(data.CIDRs.ElementsAs(ctx, &cidrs, false)...)
+	cidrs, diags := resolvePoolCIDRs(ctx, &data)
+	resp.Diagnostics.Append(diags...)
 	if resp.Diagnostics.HasError() {
 		return
 	}
 
-	for _, cidr := range cidrs {
-		if _, _, err := net.ParseCIDR(cidr); err != nil {
-			resp.Diagnostics.AddError(
-				"Invalid CIDR",
-				fmt.Sprintf("CIDR '%s' is not valid: %s", cidr, err),
-			)
-			return
-		}
+	cidrsList, diags := types.ListValueFrom(ctx, types.StringType, cidrs)
+	resp.Diagnostics.Append(diags...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	data.CIDRs = cidrsList
+
+	ranges, diags := resolvePoolRanges(ctx, &data)
+	resp.Diagnostics.Append(diags...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	priorities, diags := resolvePoolCIDRPriorities(ctx, &data)
+	resp.Diagnostics.Append(diags...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	reserved, diags := resolvePoolReservedCIDRs(ctx, &data)
+	resp.Diagnostics.Append(diags...)
+	if resp.Diagnostics.HasError() {
+		return
 	}
 
 	// save pool to storage
 	pool := &storage.Pool{
-		Name:  data.Name.ValueString(),
-		CIDRs: cidrs,
+		Name:                data.Name.ValueString(),
+		CIDRs:               cidrs,
+		Ranges:              ranges,
+		MinPrefixLength:     int(data.MinPrefixLength.ValueInt64()),
+		MaxPrefixLength:     int(data.MaxPrefixLength.ValueInt64()),
+		DefaultPrefixLength: int(data.DefaultPrefix.ValueInt64()),
+		CIDRPriorities:      priorities,
+		ReservedCIDRs:       reserved,
+
+		AutoExpandCIDR:         data.AutoExpandCIDR.ValueString(),
+		AutoExpandPrefixLength: int(data.AutoExpandPrefix.ValueInt64()),
+		SkipBoundarySubnets:    data.SkipBoundary.ValueBool(),
+		Strategy:               data.Strategy.ValueString(),
 	}
 
-	if err := r.provider.storage.SavePool(ctx, pool); err != nil {
-		resp.Diagnostics.AddError(
+	resp.Diagnostics.Append(validatePrefixLimits(&data, poolCIDRs(pool))...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	if err := r.savePool(ctx, pool); err != nil {
+		if errors.Is(err, storage.ErrStaleRevision) {
+			resp.Diagnostics.AddError(
+				"Pool Modified Concurrently",
+				fmt.Sprintf("Pool %s was created in storage by another writer: %s. Import the existing pool or choose a different name.", pool.Name, err),
+			)
+			return
+		}
+		addStorageWriteError(
+			&resp.Diagnostics,
 			"Failed to Save Pool",
 			fmt.Sprintf("Could not save pool to storage: %s", err),
+			err,
 		)
 		return
 	}
+	data.Revision = types.Int64Value(pool.Revision)
+	data.IPVersion = poolIPVersionValue(pool)
+	resp.Diagnostics.Append(setExpandedCIDRs(ctx, &data, pool)...)
+
+	resp.Diagnostics.Append(r.setAllocationCounters(ctx, &data)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
 
 	tflog.Trace(ctx, "created pool resource", map[string]interface{}{
 		"name": data.Name.ValueString(),
@@ -131,7 +434,7 @@ func (r *PoolResource) Read(ctx context.Context, req resource.ReadRequest, resp
 
 	pool, err := r.provider.storage.GetPool(ctx, data.Name.ValueString())
 	if err != nil {
-		if err == storage.ErrNotFound {
+		if errors.Is(err, storage.ErrNotFound) {
 			// pool was deleted outside terraform, remove from state
 			resp.State.RemoveResource(ctx)
 			return
@@ -151,50 +454,155 @@ func (r *PoolResource) Read(ctx context.Context, req resource.ReadRequest, resp
 	}
 	data.CIDRs = cidrs
 
+	// ranges are optional, so an empty list in storage stays null in state
+	if len(pool.Ranges) > 0 || !data.Ranges.IsNull() {
+		ranges, diag := types.ListValueFrom(ctx, types.StringType, pool.Ranges)
+		resp.Diagnostics.Append(diag...)
+		if resp.Diagnostics.HasError() {
+			return
+		}
+		data.Ranges = ranges
+	}
+
+	// prioritized cidrs are only in state for pools that were given priorities
+	if len(pool.CIDRPriorities) > 0 || !data.PrioritizedCIDRs.IsNull() {
+		prioritized := make([]PoolPrioritizedCIDRModel, 0, len(pool.CIDRs))
+		for _, cidr := range pool.CIDRs {
+			prioritized = append(prioritized, PoolPrioritizedCIDRModel{
+				CIDR:     types.StringValue(cidr),
+				Priority: types.Int64Value(int64(pool.CIDRPriorities[cidr])),
+			})
+		}
+		prioritizedList, diag := types.ListValueFrom(ctx, poolPrioritizedCIDRType, prioritized)
+		resp.Diagnostics.Append(diag...)
+		if resp.Diagnostics.HasError() {
+			return
+		}
+		data.PrioritizedCIDRs = prioritizedList
+	}
+
+	data.MinPrefixLength = syncPrefixLimit(data.MinPrefixLength, pool.MinPrefixLength)
+	data.MaxPrefixLength = syncPrefixLimit(data.MaxPrefixLength, pool.MaxPrefixLength)
+	data.DefaultPrefix = syncPrefixLimit(data.DefaultPrefix, pool.DefaultPrefixLength)
+	if pool.AutoExpandCIDR != "" || !data.AutoExpandCIDR.IsNull() {
+		data.AutoExpandCIDR = types.StringValue(pool.AutoExpandCIDR)
+	}
+	data.AutoExpandPrefix = syncPrefixLimit(data.AutoExpandPrefix, pool.AutoExpandPrefixLength)
+	data.SkipBoundary = types.BoolValue(pool.SkipBoundarySubnets)
+	data.Strategy = types.StringValue(poolStrategy(pool))
+	data.Revision = types.Int64Value(pool.Revision)
+	data.IPVersion = poolIPVersionValue(pool)
+	resp.Diagnostics.Append(setExpandedCIDRs(ctx, &data, pool)...)
+
+	resp.Diagnostics.Append(r.setAllocationCounters(ctx, &data)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
 	resp.Diagnostics.Append(resp.State.Set(ctx, &data)...)
 }
 
 func (r *PoolResource) Update(ctx context.Context, req resource.UpdateRequest, resp *resource.UpdateResponse) {
-	var data PoolResourceModel
+	var data, state PoolResourceModel
 
 	resp.Diagnostics.Append(req.Plan.Get(ctx, &data)...)
+	resp.Diagnostics.Append(req.State.Get(ctx, &state)...)
 	if resp.Diagnostics.HasError() {
 		return
 	}
 
 	// validate cidrs
-	var cidrs []string
-	resp.Diagnostics.Append(data.CIDRs.ElementsAs(ctx, &cidrs, false)...)
+	cidrs, diags := resolvePoolCIDRs(ctx, &data)
+	resp.Diagnostics.Append(diags...)
 	if resp.Diagnostics.HasError() {
 		return
 	}
 
-	for _, cidr := range cidrs {
-		if _, _, err := net.ParseCIDR(cidr); err != nil {
-			resp.Diagnostics.AddError(
-				"Invalid CIDR",
-				fmt.Sprintf("CIDR '%s' is not valid: %s", cidr, err),
-			)
-			return
-		}
+	cidrsList, diags := types.ListValueFrom(ctx, types.StringType, cidrs)
+	resp.Diagnostics.Append(diags...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	data.CIDRs = cidrsList
+
+	ranges, diags := resolvePoolRanges(ctx, &data)
+	resp.Diagnostics.Append(diags...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	priorities, diags := resolvePoolCIDRPriorities(ctx, &data)
+	resp.Diagnostics.Append(diags...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	reserved, diags := resolvePoolReservedCIDRs(ctx, &data)
+	resp.Diagnostics.Append(diags...)
+	if resp.Diagnostics.HasError() {
+		return
 	}
 
 	// TODO: Check for allocations that would be invalidated by CIDR changes to the pool
 
-	// Update pool in storage
+	// Update pool in storage, based on the revision that was last read into state
 	pool := &storage.Pool{
-		Name:  data.Name.ValueString(),
-		CIDRs: cidrs,
+		Name:                data.Name.ValueString(),
+		CIDRs:               cidrs,
+		Ranges:              ranges,
+		MinPrefixLength:     int(data.MinPrefixLength.ValueInt64()),
+		MaxPrefixLength:     int(data.MaxPrefixLength.ValueInt64()),
+		DefaultPrefixLength: int(data.DefaultPrefix.ValueInt64()),
+		CIDRPriorities:      priorities,
+		ReservedCIDRs:       reserved,
+		Revision:            state.Revision.ValueInt64(),
+
+		AutoExpandCIDR:         data.AutoExpandCIDR.ValueString(),
+		AutoExpandPrefixLength: int(data.AutoExpandPrefix.ValueInt64()),
+		SkipBoundarySubnets:    data.SkipBoundary.ValueBool(),
+		Strategy:               data.Strategy.ValueString(),
 	}
 
-	if err := r.provider.storage.SavePool(ctx, pool); err != nil {
-		resp.Diagnostics.AddError(
+	// blocks added by auto expansion may hold allocations, so they're kept. The revision check makes
+	// sure the state they're taken from is current
+	if !state.ExpandedCIDRs.IsNull() && !state.ExpandedCIDRs.IsUnknown() {
+		resp.Diagnostics.Append(state.ExpandedCIDRs.ElementsAs(ctx, &pool.ExpandedCIDRs, false)...)
+		if resp.Diagnostics.HasError() {
+			return
+		}
+	}
+
+	resp.Diagnostics.Append(validatePrefixLimits(&data, poolCIDRs(pool))...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	if err := r.savePool(ctx, pool); err != nil {
+		if errors.Is(err, storage.ErrStaleRevision) {
+			resp.Diagnostics.AddError(
+				"Pool Modified Concurrently",
+				fmt.Sprintf("Pool %s was changed in storage by another writer since it was last read: %s. Run terraform plan again to refresh the pool and review the changes.", pool.Name, err),
+			)
+			return
+		}
+		addStorageWriteError(
+			&resp.Diagnostics,
 			"Failed to Update Pool",
 			fmt.Sprintf("Could not update pool in storage: %s", err),
+			err,
 		)
 		return
 	}
 
+	data.Revision = types.Int64Value(pool.Revision)
+	data.IPVersion = poolIPVersionValue(pool)
+	resp.Diagnostics.Append(setExpandedCIDRs(ctx, &data, pool)...)
+
+	resp.Diagnostics.Append(r.setAllocationCounters(ctx, &data)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
 	tflog.Trace(ctx, "updated pool resource", map[string]interface{}{
 		"name": data.Name.ValueString(),
 	})
@@ -222,74 +630,587 @@ func (r *PoolResource) Delete(ctx context.Context, req resource.DeleteRequest, r
 		return
 	}
 
-	if len(allocations) > 0 {
+	if len(allocations) > 0 && !data.ForceDestroy.ValueBool() {
 		resp.Diagnostics.AddError(
 			"Cannot Delete Pool",
-			fmt.Sprintf("Pool %s has %d active allocations. Please delete all allocations before deleting the pool.", poolName, len(allocations)),
+			fmt.Sprintf("Pool %s has %d active allocations. Please delete all allocations before deleting or renaming the pool, or set force_destroy to delete them with the pool.", poolName, len(allocations)),
 		)
 		return
 	}
 
-	err = r.provider.storage.DeletePool(ctx, poolName)
+	// force destroy removes any remaining allocations with the pool, in one write where the backend
+	// supports it so a failure can't leave the pool with only some of them. Another writer may have
+	// claimed a block since the check, so a retry checks the allocations again.
+	err = r.provider.retryOnConflict(ctx, "delete the pool", map[string]any{"name": poolName}, func() error {
+		allocations, err = r.provider.storage.ListAllocationsByPool(ctx, poolName)
+		if err != nil {
+			return fmt.Errorf("failed to check for allocations: %w", err)
+		}
+		if len(allocations) == 0 {
+			return r.provider.storage.DeletePool(ctx, poolName)
+		}
+		if !data.ForceDestroy.ValueBool() {
+			return fmt.Errorf("pool %s has %d active allocations", poolName, len(allocations))
+		}
+		return r.provider.storage.DeletePoolWithAllocations(ctx, poolName)
+	})
 	if err != nil {
-		resp.Diagnostics.AddError(
+		addStorageWriteError(
+			&resp.Diagnostics,
 			"Failed to Delete Pool",
 			fmt.Sprintf("Could not delete pool from storage: %s", err),
+			err,
 		)
 		return
 	}
 
+	for _, alloc := range allocations {
+		tflog.Info(ctx, "force destroy removed allocation", map[string]interface{}{
+			"id":             alloc.ID,
+			"pool_name":      poolName,
+			"allocated_cidr": alloc.AllocatedCIDR,
+		})
+	}
+
 	tflog.Trace(ctx, "deleted pool resource", map[string]interface{}{
 		"name": poolName,
 	})
 }
 
 func (r *PoolResource) ImportState(ctx context.Context, req resource.ImportStateRequest, resp *resource.ImportStateResponse) {
-	// import format: name:cidr1,cidr2,cidr3
-	parts := strings.SplitN(req.ID, ":", 2)
-	if len(parts) != 2 {
+	// import formats: name to adopt a pool that's already in storage, or name:cidr1,cidr2,cidr3 which
+	// also creates the pool when it's missing
+	name, cidrsPart, hasCIDRs := strings.Cut(req.ID, ":")
+	if name == "" || (hasCIDRs && strings.TrimSpace(cidrsPart) == "") {
 		resp.Diagnostics.AddError(
 			"Invalid Import ID",
-			"Import ID must be in format: name:cidr1,cidr2,cidr3",
+			"Import ID must be in format: name or name:cidr1,cidr2,cidr3",
 		)
 		return
 	}
 
-	name := parts[0]
-	cidrList := strings.Split(parts[1], ",")
-
 	// validate cidrs
-	cidrs := make([]string, 0, len(cidrList))
-	for _, cidr := range cidrList {
-		trimmed := strings.TrimSpace(cidr)
-		if _, _, err := net.ParseCIDR(trimmed); err != nil {
-			resp.Diagnostics.AddError(
-				"Invalid CIDR",
-				fmt.Sprintf("CIDR '%s' is not valid: %s", cidr, err),
-			)
+	var cidrs []string
+	if hasCIDRs {
+		for i, cidr := range strings.Split(cidrsPart, ",") {
+			trimmed := strings.TrimSpace(cidr)
+			// a doubled or trailing comma is more likely a typo than a CIDR meant to be left out
+			if trimmed == "" {
+				resp.Diagnostics.AddError(
+					"Empty CIDR",
+					fmt.Sprintf("CIDR %d of import ID %q is empty. Remove the extra comma, the format is name:cidr1,cidr2,cidr3", i+1, req.ID),
+				)
+				return
+			}
+			if _, _, err := net.ParseCIDR(trimmed); err != nil {
+				resp.Diagnostics.AddError(
+					"Invalid CIDR",
+					fmt.Sprintf("CIDR '%s' is not valid: %s", cidr, err),
+				)
+				return
+			}
+			cidrs = append(cidrs, trimmed)
+		}
+		if diag := duplicateCIDRError(cidrs); diag != nil {
+			resp.Diagnostics.Append(diag)
 			return
 		}
-		cidrs = append(cidrs, trimmed)
-	}
-
-	pool := &storage.Pool{
-		Name:  name,
-		CIDRs: cidrs,
 	}
 
-	if err := r.provider.storage.SavePool(ctx, pool); err != nil {
+	// an existing pool is adopted as is and never overwritten, the import ID's CIDRs only have to match it
+	pool, err := r.provider.storage.GetPool(ctx, name)
+	switch {
+	case err == nil:
+		if hasCIDRs && !sameCIDRs(pool.CIDRs, cidrs) {
+			resp.Diagnostics.AddError(
+				"Pool CIDRs Mismatch",
+				fmt.Sprintf("Pool %s already exists in storage with CIDRs %s, not %s. Import it with ID %q to adopt the stored pool, or change its CIDRs after importing.",
+					name, strings.Join(pool.CIDRs, ","), strings.Join(cidrs, ","), name),
+			)
+			return
+		}
+	case errors.Is(err, storage.ErrNotFound):
+		if !hasCIDRs {
+			resp.Diagnostics.AddError(
+				"Pool Not Found",
+				fmt.Sprintf("Pool %s does not exist in storage. Import it with ID name:cidr1,cidr2 to create it", name),
+			)
+			return
+		}
+		pool = &storage.Pool{
+			Name:  name,
+			CIDRs: cidrs,
+		}
+		if err := r.savePool(ctx, pool); err != nil {
+			if errors.Is(err, storage.ErrStaleRevision) {
+				resp.Diagnostics.AddError(
+					"Pool Modified Concurrently",
+					fmt.Sprintf("Pool %s was created in storage by another writer while importing: %s. Import it again to adopt it.", name, err),
+				)
+				return
+			}
+			addStorageWriteError(
+				&resp.Diagnostics,
+				"Failed to Import Pool",
+				fmt.Sprintf("Could not save imported pool to storage: %s", err),
+				err,
+			)
+			return
+		}
+	default:
 		resp.Diagnostics.AddError(
 			"Failed to Import Pool",
-			fmt.Sprintf("Could not save imported pool to storage: %s", err),
+			fmt.Sprintf("Could not read pool from storage: %s", err),
 		)
 		return
 	}
 
 	resp.Diagnostics.Append(resp.State.SetAttribute(ctx, path.Root("name"), name)...)
-	cidrsList, diag := types.ListValueFrom(ctx, types.StringType, cidrs)
+	cidrsList, diag := types.ListValueFrom(ctx, types.StringType, pool.CIDRs)
 	resp.Diagnostics.Append(diag...)
 	if resp.Diagnostics.HasError() {
 		return
 	}
 	resp.Diagnostics.Append(resp.State.SetAttribute(ctx, path.Root("cidrs"), cidrsList)...)
+	resp.Diagnostics.Append(resp.State.SetAttribute(ctx, path.Root("force_destroy"), false)...)
+	resp.Diagnostics.Append(resp.State.SetAttribute(ctx, path.Root("revision"), pool.Revision)...)
+}
+
+// setExpandedCIDRs sets expanded_cidrs in state to the blocks auto expansion added to the pool.
+func setExpandedCIDRs(ctx context.Context, data *PoolResourceModel, pool *storage.Pool) diag.Diagnostics {
+	expanded := pool.ExpandedCIDRs
+	if expanded == nil {
+		expanded = []string{}
+	}
+	expandedList, diags := types.ListValueFrom(ctx, types.StringType, expanded)
+	data.ExpandedCIDRs = expandedList
+	return diags
+}
+
+// sameCIDRs reports whether both lists hold the same CIDRs, in any order.
+func sameCIDRs(a, b []string) bool {
+	return slices.Equal(slices.Sorted(slices.Values(a)), slices.Sorted(slices.Values(b)))
+}
+
+// poolIPVersionValue returns the pool's IP version for state, null for a pool that mixes address families.
+func poolIPVersionValue(pool *storage.Pool) types.Int64 {
+	version := poolIPVersion(pool)
+	if version == 0 {
+		return types.Int64Null()
+	}
+	return types.Int64Value(version)
+}
+
+// setAllocationCounters fills in the pool's allocation counters from the allocations currently in storage.
+func (r *PoolResource) setAllocationCounters(ctx context.Context, data *PoolResourceModel) diag.Diagnostics {
+	var diags diag.Diagnostics
+
+	count, addresses, err := poolAllocationCounters(ctx, r.provider.storage, data.Name.ValueString())
+	if err != nil {
+		diags.AddError(
+			"Failed to Read Pool Allocations",
+			fmt.Sprintf("Could not list allocations of pool %s: %s", data.Name.ValueString(), err),
+		)
+		return diags
+	}
+
+	data.AllocationCount = count
+	data.TotalAllocatedAddresses = addresses
+	return diags
+}
+
+// poolAllocationCounters returns the number of allocations in the pool and the total number of addresses they hold.
+func poolAllocationCounters(ctx context.Context, store storage.Storage, poolName string) (types.Int64, types.String, error) {
+	allocations, err := store.ListAllocationsByPool(ctx, poolName)
+	if err != nil {
+		return types.Int64Null(), types.StringNull(), err
+	}
+
+	return types.Int64Value(int64(len(allocations))), types.StringValue(allocatedAddressCount(allocations).String()), nil
+}
+
+// savePool writes the pool, retrying when the write loses a race with a change to another part of
+// storage. A concurrent change to this pool itself isn't retried, SavePool reports it as a stale revision.
+func (r *PoolResource) savePool(ctx context.Context, pool *storage.Pool) error {
+	fields := map[string]any{
+		"name": pool.Name,
+	}
+	return r.provider.retryOnConflict(ctx, "save the pool", fields, func() error {
+		return r.provider.storage.SavePool(ctx, pool)
+	})
+}
+
+// resolvePoolCIDRs returns the validated CIDRs for a pool. Pools defined by a supernet
+// get the supernet minus the reserved CIDRs, pools defined by prioritized CIDRs get those CIDRs
+// in the order listed, otherwise the configured list is used as is.
+func resolvePoolCIDRs(ctx context.Context, data *PoolResourceModel) ([]string, diag.Diagnostics) {
+	var diags diag.Diagnostics
+
+	if !data.PrioritizedCIDRs.IsNull() {
+		priorities, diags := resolvePoolCIDRPriorities(ctx, data)
+		if diags.HasError() {
+			return nil, diags
+		}
+
+		var prioritized []PoolPrioritizedCIDRModel
+		diags.Append(data.PrioritizedCIDRs.ElementsAs(ctx, &prioritized, false)...)
+		if diags.HasError() {
+			return nil, diags
+		}
+
+		cidrs := make([]string, 0, len(priorities))
+		for _, entry := range prioritized {
+			cidrs = append(cidrs, entry.CIDR.ValueString())
+		}
+		return cidrs, diags
+	}
+
+	if data.Supernet.IsNull() {
+		// a pool made only of ranges has no CIDRs of its own
+		cidrs := []string{}
+		if data.CIDRs.IsNull() || data.CIDRs.IsUnknown() {
+			return cidrs, diags
+		}
+
+		diags.Append(data.CIDRs.ElementsAs(ctx, &cidrs, false)...)
+		if diags.HasError() {
+			return nil, diags
+		}
+
+		for _, cidr := range cidrs {
+			if _, _, err := net.ParseCIDR(cidr); err != nil {
+				diags.AddError(
+					"Invalid CIDR",
+					fmt.Sprintf("CIDR '%s' is not valid: %s", cidr, err),
+				)
+				return nil, diags
+			}
+		}
+		if diag := duplicateCIDRError(cidrs); diag != nil {
+			diags.Append(diag)
+			return nil, diags
+		}
+
+		return cidrs, diags
+	}
+
+	supernet := data.Supernet.ValueString()
+	_, supernetNet, err := net.ParseCIDR(supernet)
+	if err != nil {
+		diags.AddError(
+			"Invalid CIDR",
+			fmt.Sprintf("CIDR '%s' is not valid: %s", supernet, err),
+		)
+		return nil, diags
+	}
+
+	var reserved []string
+	if !data.ReservedCIDRs.IsNull() {
+		diags.Append(data.ReservedCIDRs.ElementsAs(ctx, &reserved, false)...)
+		if diags.HasError() {
+			return nil, diags
+		}
+	}
+
+	reservedNets := make([]*net.IPNet, 0, len(reserved))
+	for _, cidr := range reserved {
+		_, reservedNet, err := net.ParseCIDR(cidr)
+		if err != nil {
+			diags.AddError(
+				"Invalid CIDR",
+				fmt.Sprintf("CIDR '%s' is not valid: %s", cidr, err),
+			)
+			return nil, diags
+		}
+		reservedNets = append(reservedNets, reservedNet)
+	}
+
+	remaining, err := subtractCIDRs(supernetNet, reservedNets)
+	if err != nil {
+		diags.AddError(
+			"Invalid Reserved CIDR",
+			fmt.Sprintf("Could not reserve CIDRs from supernet %s: %s", supernet, err),
+		)
+		return nil, diags
+	}
+
+	if len(remaining) == 0 {
+		diags.AddError(
+			"Invalid Reserved CIDR",
+			fmt.Sprintf("Reserved CIDRs cover all of supernet %s, leaving nothing to allocate", supernet),
+		)
+		return nil, diags
+	}
+
+	cidrs := make([]string, 0, len(remaining))
+	for _, block := range remaining {
+		cidrs = append(cidrs, block.String())
+	}
+
+	return cidrs, diags
+}
+
+// duplicateCIDRError returns an error for the first CIDR of the list that's the same block as an earlier
+// one, which would count its addresses twice, or nil when every block is listed once.
+func duplicateCIDRError(cidrs []string) diag.Diagnostic {
+	seen := make(map[string]string, len(cidrs))
+	for _, cidr := range cidrs {
+		_, cidrNet, err := net.ParseCIDR(cidr)
+		if err != nil {
+			continue
+		}
+		first, ok := seen[cidrNet.String()]
+		if !ok {
+			seen[cidrNet.String()] = cidr
+			continue
+		}
+		if first == cidr {
+			return diag.NewAttributeErrorDiagnostic(
+				path.Root("cidrs"),
+				"Duplicate CIDR",
+				fmt.Sprintf("CIDR '%s' is listed more than once in the pool's CIDRs. Remove the duplicate.", cidr),
+			)
+		}
+		return diag.NewAttributeErrorDiagnostic(
+			path.Root("cidrs"),
+			"Duplicate CIDR",
+			fmt.Sprintf("CIDR '%s' is the same block as '%s', listed earlier in the pool's CIDRs. Remove the duplicate.", cidr, first),
+		)
+	}
+	return nil
+}
+
+// resolvePoolReservedCIDRs returns the networks of reserved_cidrs, or nil for a pool without them.
+func resolvePoolReservedCIDRs(ctx context.Context, data *PoolResourceModel) ([]string, diag.Diagnostics) {
+	var diags diag.Diagnostics
+
+	if data.ReservedCIDRs.IsNull() || data.ReservedCIDRs.IsUnknown() {
+		return nil, diags
+	}
+
+	var reserved []string
+	diags.Append(data.ReservedCIDRs.ElementsAs(ctx, &reserved, false)...)
+	if diags.HasError() {
+		return nil, diags
+	}
+
+	networks := make([]string, 0, len(reserved))
+	for _, cidr := range reserved {
+		_, reservedNet, err := net.ParseCIDR(cidr)
+		if err != nil {
+			diags.AddError(
+				"Invalid CIDR",
+				fmt.Sprintf("CIDR '%s' is not valid: %s", cidr, err),
+			)
+			return nil, diags
+		}
+		networks = append(networks, reservedNet.String())
+	}
+	return networks, diags
+}
+
+// resolvePoolCIDRPriorities returns the priority of each CIDR in prioritized_cidrs after checking
+// the CIDRs parse and are listed once, or nil for a pool without prioritized CIDRs.
+func resolvePoolCIDRPriorities(ctx context.Context, data *PoolResourceModel) (map[string]int, diag.Diagnostics) {
+	var diags diag.Diagnostics
+
+	if data.PrioritizedCIDRs.IsNull() {
+		return nil, diags
+	}
+
+	var prioritized []PoolPrioritizedCIDRModel
+	diags.Append(data.PrioritizedCIDRs.ElementsAs(ctx, &prioritized, false)...)
+	if diags.HasError() {
+		return nil, diags
+	}
+
+	priorities := make(map[string]int, len(prioritized))
+	for _, entry := range prioritized {
+		cidr := entry.CIDR.ValueString()
+		if _, _, err := net.ParseCIDR(cidr); err != nil {
+			diags.AddAttributeError(
+				path.Root("prioritized_cidrs"),
+				"Invalid CIDR",
+				fmt.Sprintf("CIDR '%s' is not valid: %s", cidr, err),
+			)
+			return nil, diags
+		}
+		if _, ok := priorities[cidr]; ok {
+			diags.AddAttributeError(
+				path.Root("prioritized_cidrs"),
+				"Duplicate CIDR",
+				fmt.Sprintf("CIDR '%s' is listed more than once", cidr),
+			)
+			return nil, diags
+		}
+		priorities[cidr] = int(entry.Priority.ValueInt64())
+	}
+
+	return priorities, diags
+}
+
+// resolvePoolRanges returns the pool's configured ranges after checking that each one parses.
+func resolvePoolRanges(ctx context.Context, data *PoolResourceModel) ([]string, diag.Diagnostics) {
+	var diags diag.Diagnostics
+
+	if data.Ranges.IsNull() {
+		return nil, diags
+	}
+
+	var ranges []string
+	diags.Append(data.Ranges.ElementsAs(ctx, &ranges, false)...)
+	if diags.HasError() {
+		return nil, diags
+	}
+
+	for _, ipRange := range ranges {
+		if _, _, err := parseIPRange(ipRange); err != nil {
+			diags.AddAttributeError(
+				path.Root("ranges"),
+				"Invalid Range",
+				fmt.Sprintf("Range '%s' is not valid: %s", ipRange, err),
+			)
+			return nil, diags
+		}
+	}
+
+	return ranges, diags
+}
+
+// validatePrefixLimits checks that the pool's allowed prefix lengths are ordered
+// and fit the address family of the pool CIDRs, including the blocks covering its ranges.
+func validatePrefixLimits(data *PoolResourceModel, cidrs []string) diag.Diagnostics {
+	var diags diag.Diagnostics
+
+	// the limits have to work for the smallest address family in the pool
+	maxBits := 0
+	for _, cidr := range cidrs {
+		_, cidrNet, err := net.ParseCIDR(cidr)
+		if err != nil {
+			continue
+		}
+		_, bits := cidrNet.Mask.Size()
+		if maxBits == 0 || bits < maxBits {
+			maxBits = bits
+		}
+	}
+
+	if maxBits == 0 {
+		maxBits = 128
+	}
+
+	family := "IPv6"
+	if maxBits == 32 {
+		family = "IPv4"
+	}
+
+	for _, limit := range []struct {
+		name  string
+		value types.Int64
+	}{
+		{"min_prefix_length", data.MinPrefixLength},
+		{"max_prefix_length", data.MaxPrefixLength},
+		{"default_prefix_length", data.DefaultPrefix},
+	} {
+		if limit.value.IsNull() {
+			continue
+		}
+		if limit.value.ValueInt64() < 0 || limit.value.ValueInt64() > int64(maxBits) {
+			diags.AddAttributeError(
+				path.Root(limit.name),
+				"Invalid Prefix Length",
+				fmt.Sprintf("%s must be between 0 and %d for an %s pool, got %d", limit.name, maxBits, family, limit.value.ValueInt64()),
+			)
+		}
+	}
+
+	if !data.MinPrefixLength.IsNull() && !data.MaxPrefixLength.IsNull() && data.MinPrefixLength.ValueInt64() > data.MaxPrefixLength.ValueInt64() {
+		diags.AddAttributeError(
+			path.Root("min_prefix_length"),
+			"Invalid Prefix Length",
+			fmt.Sprintf("min_prefix_length (%d) must be less than or equal to max_prefix_length (%d)", data.MinPrefixLength.ValueInt64(), data.MaxPrefixLength.ValueInt64()),
+		)
+	}
+
+	// allocations get the default without asking for it, so it has to be a size they could ask for
+	if !data.DefaultPrefix.IsNull() {
+		defaultPrefix := data.DefaultPrefix.ValueInt64()
+		if !data.MinPrefixLength.IsNull() && defaultPrefix < data.MinPrefixLength.ValueInt64() {
+			diags.AddAttributeError(
+				path.Root("default_prefix_length"),
+				"Invalid Prefix Length",
+				fmt.Sprintf("default_prefix_length (%d) must be greater than or equal to min_prefix_length (%d)", defaultPrefix, data.MinPrefixLength.ValueInt64()),
+			)
+		}
+		if !data.MaxPrefixLength.IsNull() && defaultPrefix > data.MaxPrefixLength.ValueInt64() {
+			diags.AddAttributeError(
+				path.Root("default_prefix_length"),
+				"Invalid Prefix Length",
+				fmt.Sprintf("default_prefix_length (%d) must be less than or equal to max_prefix_length (%d)", defaultPrefix, data.MaxPrefixLength.ValueInt64()),
+			)
+		}
+	}
+
+	return diags
+}
+
+// validateAutoExpand checks that auto_expand_cidr is a valid CIDR and that the blocks carved from it fit
+// inside it.
+func validateAutoExpand(data *PoolResourceModel) diag.Diagnostics {
+	var diags diag.Diagnostics
+
+	if data.AutoExpandCIDR.IsUnknown() || data.AutoExpandPrefix.IsUnknown() {
+		return diags
+	}
+	if data.AutoExpandCIDR.IsNull() != data.AutoExpandPrefix.IsNull() {
+		diags.AddAttributeError(
+			path.Root("auto_expand_cidr"),
+			"Invalid Pool Definition",
+			"auto_expand_cidr and auto_expand_prefix_length must be set together",
+		)
+		return diags
+	}
+	if data.AutoExpandCIDR.IsNull() {
+		return diags
+	}
+
+	_, supernet, err := net.ParseCIDR(data.AutoExpandCIDR.ValueString())
+	if err != nil {
+		diags.AddAttributeError(
+			path.Root("auto_expand_cidr"),
+			"Invalid CIDR",
+			fmt.Sprintf("CIDR '%s' is not valid: %s", data.AutoExpandCIDR.ValueString(), err),
+		)
+		return diags
+	}
+
+	ones, bits := supernet.Mask.Size()
+	prefixLength := data.AutoExpandPrefix.ValueInt64()
+	if prefixLength < int64(ones) || prefixLength > int64(bits) {
+		diags.AddAttributeError(
+			path.Root("auto_expand_prefix_length"),
+			"Invalid Prefix Length",
+			fmt.Sprintf("auto_expand_prefix_length must be between %d and %d to carve blocks from %s, got %d", ones, bits, supernet, prefixLength),
+		)
+	}
+
+	return diags
+}
+
+// poolStrategy returns the allocation strategy of the pool, first fit for a pool saved before
+// strategies were added.
+func poolStrategy(pool *storage.Pool) string {
+	if pool.Strategy == "" {
+		return poolStrategyFirstFit
+	}
+	return pool.Strategy
+}
+
+// syncPrefixLimit returns the stored prefix limit for state. Storage uses 0 for no limit,
+// so an unset limit stays null rather than showing up as drift.
+func syncPrefixLimit(current types.Int64, stored int) types.Int64 {
+	if stored == 0 && current.IsNull() {
+		return current
+	}
+	return types.Int64Value(int64(stored))
 }
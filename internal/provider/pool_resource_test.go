@@ -1,6 +1,8 @@
 package provider
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"regexp"
 	"testing"
@@ -9,8 +11,11 @@ import (
 	"github.com/hashicorp/terraform-plugin-go/tfprotov6"
 	"github.com/hashicorp/terraform-plugin-testing/helper/resource"
 	"github.com/hashicorp/terraform-plugin-testing/knownvalue"
+	"github.com/hashicorp/terraform-plugin-testing/plancheck"
 	"github.com/hashicorp/terraform-plugin-testing/statecheck"
 	"github.com/hashicorp/terraform-plugin-testing/tfjsonpath"
+
+	"terraform-provider-tfipam/internal/provider/storage"
 )
 
 // testAccProtoV6ProviderFactories are used to instantiate a provider during
@@ -74,6 +79,12 @@ func TestAccPoolResource_Basic(t *testing.T) {
 							knownvalue.StringExact("192.168.1.0/24"),
 						}),
 					),
+					// a pool with both families has no single ip version
+					statecheck.ExpectKnownValue(
+						"tfipam_pool.test",
+						tfjsonpath.New("ip_version"),
+						knownvalue.Null(),
+					),
 				},
 			},
 			// Delete testing automatically occurs in TestCase
@@ -179,6 +190,33 @@ func TestAccPoolResource_InvalidCIDR(t *testing.T) {
 	})
 }
 
+func TestAccPoolResource_DuplicateCIDR(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		PreCheck:                 func() { testAccPreCheck(t) },
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config:      testAccPoolResourceConfig("duplicate-pool", []string{"10.0.0.0/16", "10.0.0.0/16"}),
+				ExpectError: regexp.MustCompile("CIDR '10.0.0.0/16' is listed more than once"),
+			},
+			{
+				Config: testAccPoolResourceConfig("duplicate-pool", []string{"10.0.0.0/16"}),
+			},
+			// the same block written with host bits set is a duplicate too
+			{
+				Config:      testAccPoolResourceConfig("duplicate-pool", []string{"10.0.0.0/16", "10.0.5.0/16"}),
+				ExpectError: regexp.MustCompile("CIDR '10.0.5.0/16' is the same block as '10.0.0.0/16'"),
+			},
+			{
+				ResourceName:  "tfipam_pool.test",
+				ImportState:   true,
+				ImportStateId: "duplicate-import:172.16.0.0/12,172.16.0.0/12",
+				ExpectError:   regexp.MustCompile("Duplicate CIDR"),
+			},
+		},
+	})
+}
+
 func TestAccPoolResource_NameChange(t *testing.T) {
 	resource.Test(t, resource.TestCase{
 		PreCheck:                 func() { testAccPreCheck(t) },
@@ -276,6 +314,82 @@ func TestAccPoolResource_ImportWithSpaces(t *testing.T) {
 				ImportStateId:                        "import-spaces: 10.0.0.0/16 , 192.168.1.0/24 ",
 				ImportStateVerifyIdentifierAttribute: "name",
 			},
+			// Empty CIDRs from doubled or trailing commas are rejected rather than dropped
+			{
+				ResourceName:  "tfipam_pool.test",
+				ImportState:   true,
+				ImportStateId: "import-spaces:10.0.0.0/16,,192.168.1.0/24",
+				ExpectError:   regexp.MustCompile("CIDR 2 of import ID .* is empty"),
+			},
+			{
+				ResourceName:  "tfipam_pool.test",
+				ImportState:   true,
+				ImportStateId: "import-spaces:10.0.0.0/16, 192.168.1.0/24, ",
+				ExpectError:   regexp.MustCompile("CIDR 3 of import ID .* is empty"),
+			},
+		},
+	})
+}
+
+func TestAccPoolResource_ImportByName(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		PreCheck:                 func() { testAccPreCheck(t) },
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: testAccPoolResourceConfig("import-by-name", []string{"10.0.0.0/16", "192.168.1.0/24"}),
+			},
+			// the stored pool is adopted without repeating its CIDRs
+			{
+				ResourceName:                         "tfipam_pool.test",
+				ImportState:                          true,
+				ImportStateVerify:                    true,
+				ImportStateId:                        "import-by-name",
+				ImportStateVerifyIdentifierAttribute: "name",
+			},
+			// the same CIDRs in another order still match
+			{
+				ResourceName:                         "tfipam_pool.test",
+				ImportState:                          true,
+				ImportStateVerify:                    true,
+				ImportStateId:                        "import-by-name:192.168.1.0/24,10.0.0.0/16",
+				ImportStateVerifyIdentifierAttribute: "name",
+			},
+			// a name alone can't create a pool that isn't in storage
+			{
+				ResourceName:  "tfipam_pool.test",
+				ImportState:   true,
+				ImportStateId: "import-by-name-missing",
+				ExpectError:   regexp.MustCompile("Pool Not Found"),
+			},
+		},
+	})
+}
+
+func TestAccPoolResource_ImportCIDRsMismatch(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		PreCheck:                 func() { testAccPreCheck(t) },
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: testAccPoolResourceConfig("import-mismatch", []string{"10.0.0.0/16"}),
+			},
+			// importing with other CIDRs fails instead of overwriting the stored pool
+			{
+				ResourceName:  "tfipam_pool.test",
+				ImportState:   true,
+				ImportStateId: "import-mismatch:172.16.0.0/12",
+				ExpectError:   regexp.MustCompile("Pool CIDRs Mismatch"),
+			},
+			// so the stored pool still has its original CIDRs
+			{
+				Config: testAccPoolResourceConfig("import-mismatch", []string{"10.0.0.0/16"}),
+				ConfigPlanChecks: resource.ConfigPlanChecks{
+					PreApply: []plancheck.PlanCheck{
+						plancheck.ExpectEmptyPlan(),
+					},
+				},
+			},
 		},
 	})
 }
@@ -305,6 +419,381 @@ func TestAccPoolResource_WithAllocations(t *testing.T) {
 	})
 }
 
+func TestAccPoolResource_AllocationCounters(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		PreCheck:                 func() { testAccPreCheck(t) },
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			// The pool is created before its allocation, so it starts out empty
+			{
+				Config: testAccPoolResourceConfigWithAllocation("counter-pool", []string{"10.0.0.0/16"}),
+				ConfigStateChecks: []statecheck.StateCheck{
+					statecheck.ExpectKnownValue(
+						"tfipam_pool.test",
+						tfjsonpath.New("allocation_count"),
+						knownvalue.Int64Exact(0),
+					),
+				},
+			},
+			// The counters follow the allocation on the next read without any change to the pool
+			{
+				Config: testAccPoolResourceConfigWithAllocation("counter-pool", []string{"10.0.0.0/16"}),
+				ConfigStateChecks: []statecheck.StateCheck{
+					statecheck.ExpectKnownValue(
+						"tfipam_pool.test",
+						tfjsonpath.New("allocation_count"),
+						knownvalue.Int64Exact(1),
+					),
+					statecheck.ExpectKnownValue(
+						"tfipam_pool.test",
+						tfjsonpath.New("total_allocated_addresses"),
+						knownvalue.StringExact("256"),
+					),
+				},
+			},
+		},
+	})
+}
+
+func TestAccPoolResource_ForceDestroy(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		PreCheck:                 func() { testAccPreCheck(t) },
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			// Create pool with force_destroy and an allocation in it
+			{
+				Config: testAccPoolResourceConfigForceDestroy("force-destroy-pool", true),
+				ConfigStateChecks: []statecheck.StateCheck{
+					statecheck.ExpectKnownValue(
+						"tfipam_pool.test",
+						tfjsonpath.New("force_destroy"),
+						knownvalue.Bool(true),
+					),
+				},
+			},
+			// Remove the pool, the allocation is deleted from storage along with it
+			// so the follow up plan wants to recreate the allocation
+			{
+				Config:             testAccPoolResourceConfigForceDestroyAllocationOnly("force-destroy-pool"),
+				ExpectNonEmptyPlan: true,
+			},
+		},
+	})
+}
+
+func TestAccPoolResource_Supernet(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		PreCheck:                 func() { testAccPreCheck(t) },
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			// Create from a supernet with reserved blocks carved out
+			{
+				Config: testAccPoolResourceConfigSupernet("supernet-pool", "10.0.0.0/22", []string{"10.0.0.0/24"}),
+				ConfigStateChecks: []statecheck.StateCheck{
+					statecheck.ExpectKnownValue(
+						"tfipam_pool.test",
+						tfjsonpath.New("cidrs"),
+						knownvalue.ListExact([]knownvalue.Check{
+							knownvalue.StringExact("10.0.1.0/24"),
+							knownvalue.StringExact("10.0.2.0/23"),
+						}),
+					),
+				},
+			},
+			// Reserve another block
+			{
+				Config: testAccPoolResourceConfigSupernet("supernet-pool", "10.0.0.0/22", []string{"10.0.0.0/24", "10.0.3.0/24"}),
+				ConfigStateChecks: []statecheck.StateCheck{
+					statecheck.ExpectKnownValue(
+						"tfipam_pool.test",
+						tfjsonpath.New("cidrs"),
+						knownvalue.ListExact([]knownvalue.Check{
+							knownvalue.StringExact("10.0.1.0/24"),
+							knownvalue.StringExact("10.0.2.0/24"),
+						}),
+					),
+				},
+			},
+		},
+	})
+}
+
+func TestAccPoolResource_SupernetReservedOutside(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		PreCheck:                 func() { testAccPreCheck(t) },
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config:      testAccPoolResourceConfigSupernet("supernet-pool", "10.0.0.0/22", []string{"10.1.0.0/24"}),
+				ExpectError: regexp.MustCompile("Invalid Reserved CIDR"),
+			},
+		},
+	})
+}
+
+func TestAccPoolResource_Ranges(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		PreCheck:                 func() { testAccPreCheck(t) },
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			// the range starts mid block, so the first /29 comes from the /28 covering .16-.31
+			{
+				Config: testAccPoolResourceConfigRanges("range-pool", "10.0.0.10-10.0.0.250"),
+				ConfigStateChecks: []statecheck.StateCheck{
+					statecheck.ExpectKnownValue(
+						"tfipam_pool.test",
+						tfjsonpath.New("cidrs"),
+						knownvalue.ListExact([]knownvalue.Check{}),
+					),
+					statecheck.ExpectKnownValue(
+						"tfipam_pool.test",
+						tfjsonpath.New("ranges"),
+						knownvalue.ListExact([]knownvalue.Check{
+							knownvalue.StringExact("10.0.0.10-10.0.0.250"),
+						}),
+					),
+					statecheck.ExpectKnownValue(
+						"tfipam_allocation.test",
+						tfjsonpath.New("allocated_cidr"),
+						knownvalue.StringExact("10.0.0.16/29"),
+					),
+				},
+			},
+		},
+	})
+}
+
+func TestAccPoolResource_PrioritizedCIDRs(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		PreCheck:                 func() { testAccPreCheck(t) },
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			// 10.3.1.0/24 has the lowest priority, so it's filled before the CIDR listed first
+			{
+				Config: testAccPoolResourceConfigPrioritized("prioritized-pool"),
+				ConfigStateChecks: []statecheck.StateCheck{
+					statecheck.ExpectKnownValue(
+						"tfipam_pool.test",
+						tfjsonpath.New("cidrs"),
+						knownvalue.ListExact([]knownvalue.Check{
+							knownvalue.StringExact("10.3.0.0/24"),
+							knownvalue.StringExact("10.3.1.0/24"),
+						}),
+					),
+					statecheck.ExpectKnownValue(
+						"tfipam_allocation.first",
+						tfjsonpath.New("allocated_cidr"),
+						knownvalue.StringExact("10.3.1.0/25"),
+					),
+					statecheck.ExpectKnownValue(
+						"tfipam_allocation.second",
+						tfjsonpath.New("allocated_cidr"),
+						knownvalue.StringExact("10.3.1.128/25"),
+					),
+					statecheck.ExpectKnownValue(
+						"tfipam_allocation.third",
+						tfjsonpath.New("allocated_cidr"),
+						knownvalue.StringExact("10.3.0.0/25"),
+					),
+				},
+			},
+			{
+				ResourceName:                         "tfipam_pool.test",
+				ImportState:                          true,
+				ImportStateId:                        "prioritized-pool",
+				ImportStateVerify:                    true,
+				ImportStateVerifyIdentifierAttribute: "name",
+			},
+		},
+	})
+}
+
+func TestAccPoolResource_PrioritizedCIDRsConflict(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		PreCheck:                 func() { testAccPreCheck(t) },
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: `
+resource "tfipam_pool" "test" {
+  name  = "prioritized-conflict-pool"
+  cidrs = ["10.3.0.0/24"]
+  prioritized_cidrs = [
+    { cidr = "10.3.1.0/24", priority = 1 },
+  ]
+}
+`,
+				ExpectError: regexp.MustCompile("Only one of cidrs, supernet or prioritized_cidrs can be set"),
+			},
+		},
+	})
+}
+
+func TestAccPoolResource_InvalidRange(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		PreCheck:                 func() { testAccPreCheck(t) },
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config:      testAccPoolResourceConfigRanges("invalid-range-pool", "10.0.0.250-10.0.0.10"),
+				ExpectError: regexp.MustCompile("starts after it ends"),
+			},
+			{
+				Config:      testAccPoolResourceConfigRanges("invalid-range-pool", "10.0.0.10-2001:db8::1"),
+				ExpectError: regexp.MustCompile("mixes IPv4 and IPv6 addresses"),
+			},
+		},
+	})
+}
+
+func TestAccPoolResource_InvalidStrategy(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		PreCheck:                 func() { testAccPreCheck(t) },
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: `
+resource "tfipam_pool" "test" {
+  name     = "invalid-strategy-pool"
+  cidrs    = ["10.0.0.0/16"]
+  strategy = "worst_fit"
+}
+`,
+				ExpectError: regexp.MustCompile("Invalid Allocation Strategy"),
+			},
+		},
+	})
+}
+
+func TestAccPoolResource_InvalidPrefixLimits(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		PreCheck:                 func() { testAccPreCheck(t) },
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			// Min greater than max
+			{
+				Config:      testAccPoolResourceConfigPrefixLimits("limits-pool", "10.0.0.0/16", 28, 24),
+				ExpectError: regexp.MustCompile("must be less than or equal to max_prefix_length"),
+			},
+			// Beyond the IPv4 address length
+			{
+				Config:      testAccPoolResourceConfigPrefixLimits("limits-pool", "10.0.0.0/16", 24, 40),
+				ExpectError: regexp.MustCompile("must be between 0 and 32 for an IPv4 pool"),
+			},
+			// Default outside the allowed range
+			{
+				Config: `
+resource "tfipam_pool" "test" {
+  name                  = "limits-pool"
+  cidrs                 = ["10.0.0.0/16"]
+  min_prefix_length     = 24
+  max_prefix_length     = 28
+  default_prefix_length = 29
+}
+`,
+				ExpectError: regexp.MustCompile("default_prefix_length \\(29\\) must be less than or equal to"),
+			},
+		},
+	})
+}
+
+func TestAccPoolResource_RenameWithAllocations(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		PreCheck:                 func() { testAccPreCheck(t) },
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			// Allocation references the pool by attribute, so it's replaced with the pool
+			{
+				Config: testAccPoolResourceConfigWithAllocation("rename-pool", []string{"10.0.0.0/16"}),
+			},
+			{
+				Config: testAccPoolResourceConfigWithAllocation("renamed-pool", []string{"10.0.0.0/16"}),
+				ConfigStateChecks: []statecheck.StateCheck{
+					statecheck.ExpectKnownValue(
+						"tfipam_allocation.test",
+						tfjsonpath.New("pool_name"),
+						knownvalue.StringExact("renamed-pool"),
+					),
+				},
+			},
+			// Allocation pinned to the old pool name blocks the rename
+			{
+				Config: testAccPoolResourceConfigRenamePinned("renamed-pool", "renamed-pool"),
+			},
+			{
+				Config:      testAccPoolResourceConfigRenamePinned("renamed-again-pool", "renamed-pool"),
+				ExpectError: regexp.MustCompile("Cannot Delete Pool"),
+			},
+		},
+	})
+}
+
+func TestAccPoolResource_Revision(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		PreCheck:                 func() { testAccPreCheck(t) },
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: testAccPoolResourceConfig("revision-pool", []string{"10.0.0.0/16"}),
+				ConfigStateChecks: []statecheck.StateCheck{
+					statecheck.ExpectKnownValue(
+						"tfipam_pool.test",
+						tfjsonpath.New("revision"),
+						knownvalue.Int64Exact(1),
+					),
+				},
+			},
+			{
+				Config: testAccPoolResourceConfig("revision-pool", []string{"10.0.0.0/16", "10.1.0.0/16"}),
+				ConfigStateChecks: []statecheck.StateCheck{
+					statecheck.ExpectKnownValue(
+						"tfipam_pool.test",
+						tfjsonpath.New("revision"),
+						knownvalue.Int64Exact(2),
+					),
+				},
+			},
+			// Another writer edits the pool, a write based on the old revision is rejected
+			// and the change is picked up on refresh before it's reverted to the config
+			{
+				PreConfig: func() {
+					store, err := storage.NewFileStorage("")
+					if err != nil {
+						t.Fatalf("failed to open storage: %s", err)
+					}
+					defer store.Close()
+
+					stale := &storage.Pool{Name: "revision-pool", CIDRs: []string{"10.2.0.0/16"}, Revision: 1}
+					if err := store.SavePool(context.Background(), stale); !errors.Is(err, storage.ErrStaleRevision) {
+						t.Fatalf("expected stale revision error, got: %v", err)
+					}
+
+					current := &storage.Pool{Name: "revision-pool", CIDRs: []string{"10.2.0.0/16"}, Revision: 2}
+					if err := store.SavePool(context.Background(), current); err != nil {
+						t.Fatalf("failed to save pool: %s", err)
+					}
+				},
+				Config: testAccPoolResourceConfig("revision-pool", []string{"10.0.0.0/16", "10.1.0.0/16"}),
+				ConfigStateChecks: []statecheck.StateCheck{
+					statecheck.ExpectKnownValue(
+						"tfipam_pool.test",
+						tfjsonpath.New("cidrs"),
+						knownvalue.ListExact([]knownvalue.Check{
+							knownvalue.StringExact("10.0.0.0/16"),
+							knownvalue.StringExact("10.1.0.0/16"),
+						}),
+					),
+					statecheck.ExpectKnownValue(
+						"tfipam_pool.test",
+						tfjsonpath.New("revision"),
+						knownvalue.Int64Exact(4),
+					),
+				},
+			},
+		},
+	})
+}
+
 func TestAccPoolResource_IPv6(t *testing.T) {
 	resource.Test(t, resource.TestCase{
 		PreCheck:                 func() { testAccPreCheck(t) },
@@ -325,6 +814,11 @@ func TestAccPoolResource_IPv6(t *testing.T) {
 							knownvalue.StringExact("2001:db8::/32"),
 						}),
 					),
+					statecheck.ExpectKnownValue(
+						"tfipam_pool.test",
+						tfjsonpath.New("ip_version"),
+						knownvalue.Int64Exact(6),
+					),
 				},
 			},
 		},
@@ -400,3 +894,130 @@ resource "tfipam_allocation" "test" {
 }
 `, name, cidrsConfig)
 }
+
+// testAccPoolResourceConfigForceDestroy generates a configuration with a force destroy pool and an allocation.
+func testAccPoolResourceConfigForceDestroy(name string, forceDestroy bool) string {
+	return fmt.Sprintf(`
+resource "tfipam_pool" "test" {
+  name          = %[1]q
+  cidrs         = ["10.0.0.0/16"]
+  force_destroy = %[2]t
+}
+
+resource "tfipam_allocation" "test" {
+  id            = "force-destroy-allocation"
+  pool_name     = %[1]q
+  prefix_length = 24
+  depends_on    = [tfipam_pool.test]
+}
+`, name, forceDestroy)
+}
+
+// testAccPoolResourceConfigForceDestroyAllocationOnly generates a configuration with only the allocation.
+func testAccPoolResourceConfigForceDestroyAllocationOnly(name string) string {
+	return fmt.Sprintf(`
+resource "tfipam_allocation" "test" {
+  id            = "force-destroy-allocation"
+  pool_name     = %[1]q
+  prefix_length = 24
+}
+`, name)
+}
+
+// testAccPoolResourceConfigSupernet generates a configuration for a pool defined by a supernet and reserved CIDRs.
+func testAccPoolResourceConfigSupernet(name string, supernet string, reserved []string) string {
+	reservedConfig := ""
+	for _, cidr := range reserved {
+		reservedConfig += fmt.Sprintf("    %q,\n", cidr)
+	}
+
+	return fmt.Sprintf(`
+resource "tfipam_pool" "test" {
+  name     = %[1]q
+  supernet = %[2]q
+  reserved_cidrs = [
+%[3]s  ]
+}
+`, name, supernet, reservedConfig)
+}
+
+// testAccPoolResourceConfigRanges generates a configuration for a pool defined only by an address range, with a /29 allocation.
+func testAccPoolResourceConfigRanges(name string, ipRange string) string {
+	return fmt.Sprintf(`
+resource "tfipam_pool" "test" {
+  name   = %[1]q
+  ranges = [%[2]q]
+}
+
+resource "tfipam_allocation" "test" {
+  id            = "%[1]s-alloc"
+  pool_name     = tfipam_pool.test.name
+  prefix_length = 29
+}
+`, name, ipRange)
+}
+
+// testAccPoolResourceConfigPrioritized generates a configuration for a pool whose second CIDR is
+// filled first, with three /25 allocations made one after the other.
+func testAccPoolResourceConfigPrioritized(name string) string {
+	return fmt.Sprintf(`
+resource "tfipam_pool" "test" {
+  name = %[1]q
+  prioritized_cidrs = [
+    { cidr = "10.3.0.0/24", priority = 10 },
+    { cidr = "10.3.1.0/24", priority = 1 },
+  ]
+}
+
+resource "tfipam_allocation" "first" {
+  id            = "%[1]s-first"
+  pool_name     = tfipam_pool.test.name
+  prefix_length = 25
+}
+
+resource "tfipam_allocation" "second" {
+  id            = "%[1]s-second"
+  pool_name     = tfipam_pool.test.name
+  prefix_length = 25
+
+  depends_on = [tfipam_allocation.first]
+}
+
+resource "tfipam_allocation" "third" {
+  id            = "%[1]s-third"
+  pool_name     = tfipam_pool.test.name
+  prefix_length = 25
+
+  depends_on = [tfipam_allocation.second]
+}
+`, name)
+}
+
+// testAccPoolResourceConfigPrefixLimits generates a configuration for a pool with allowed prefix lengths.
+func testAccPoolResourceConfigPrefixLimits(name string, cidr string, minPrefix, maxPrefix int) string {
+	return fmt.Sprintf(`
+resource "tfipam_pool" "test" {
+  name              = %[1]q
+  cidrs             = [%[2]q]
+  min_prefix_length = %[3]d
+  max_prefix_length = %[4]d
+}
+`, name, cidr, minPrefix, maxPrefix)
+}
+
+// testAccPoolResourceConfigRenamePinned generates a configuration with an allocation that uses a literal pool name.
+func testAccPoolResourceConfigRenamePinned(name string, allocationPoolName string) string {
+	return fmt.Sprintf(`
+resource "tfipam_pool" "test" {
+  name  = %[1]q
+  cidrs = ["10.0.0.0/16"]
+}
+
+resource "tfipam_allocation" "test" {
+  id            = "test-allocation"
+  pool_name     = %[2]q
+  prefix_length = 24
+  depends_on    = [tfipam_pool.test]
+}
+`, name, allocationPoolName)
+}
@@ -0,0 +1,241 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"net"
+
+	"github.com/hashicorp/terraform-plugin-framework/datasource"
+	"github.com/hashicorp/terraform-plugin-framework/datasource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/path"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+
+	"terraform-provider-tfipam/internal/provider/storage"
+)
+
+var _ datasource.DataSource = &PoolStatsDataSource{}
+
+func NewPoolStatsDataSource() datasource.DataSource {
+	return &PoolStatsDataSource{}
+}
+
+type PoolStatsDataSource struct {
+	provider *IpamProvider
+}
+
+type PoolStatsDataSourceModel struct {
+	PoolName           types.String  `tfsdk:"pool_name"`
+	ExcludeIDs         types.Set     `tfsdk:"exclude_allocation_ids"`
+	AddressFamily      types.String  `tfsdk:"address_family"`
+	TotalAddresses     types.String  `tfsdk:"total_addresses"`
+	UsedAddresses      types.String  `tfsdk:"used_addresses"`
+	FreeAddresses      types.String  `tfsdk:"free_addresses"`
+	UtilizationPercent types.Float64 `tfsdk:"utilization_percent"`
+	UsedByOwner        types.Map     `tfsdk:"used_addresses_by_owner"`
+}
+
+func (d *PoolStatsDataSource) Metadata(ctx context.Context, req datasource.MetadataRequest, resp *datasource.MetadataResponse) {
+	resp.TypeName = req.ProviderTypeName + "_pool_stats"
+}
+
+func (d *PoolStatsDataSource) Schema(ctx context.Context, req datasource.SchemaRequest, resp *datasource.SchemaResponse) {
+	resp.Schema = schema.Schema{
+		MarkdownDescription: "IPAM pool stats data source for checking how much free space is left in a pool",
+
+		Attributes: map[string]schema.Attribute{
+			"pool_name": schema.StringAttribute{
+				MarkdownDescription: "Name of the IP pool",
+				Required:            true,
+			},
+			"exclude_allocation_ids": schema.SetAttribute{
+				MarkdownDescription: "IDs of allocations to leave out of the counts, as if they were deleted. Previews the free space destroying them leaves, e.g. in a plan that removes them",
+				Optional:            true,
+				ElementType:         types.StringType,
+			},
+			"address_family": schema.StringAttribute{
+				MarkdownDescription: "Address family to report on, either 'ipv4' or 'ipv6'. Required when the pool has both IPv4 and IPv6 CIDRs, otherwise defaults to the family of the pool",
+				Optional:            true,
+				Computed:            true,
+			},
+			"total_addresses": schema.StringAttribute{
+				MarkdownDescription: "Number of addresses in the pool's CIDRs. A string since IPv6 counts overflow a number",
+				Computed:            true,
+			},
+			"used_addresses": schema.StringAttribute{
+				MarkdownDescription: "Number of addresses taken by allocations",
+				Computed:            true,
+			},
+			"free_addresses": schema.StringAttribute{
+				MarkdownDescription: "Number of addresses not taken by allocations",
+				Computed:            true,
+			},
+			"utilization_percent": schema.Float64Attribute{
+				MarkdownDescription: "Used addresses as a percentage of the total",
+				Computed:            true,
+			},
+			"used_addresses_by_owner": schema.MapAttribute{
+				MarkdownDescription: "Number of addresses taken by allocations, keyed by the allocations' `owner`. Allocations without an owner aren't included. Strings since IPv6 counts overflow a number",
+				Computed:            true,
+				ElementType:         types.StringType,
+			},
+		},
+	}
+}
+
+func (d *PoolStatsDataSource) Configure(ctx context.Context, req datasource.ConfigureRequest, resp *datasource.ConfigureResponse) {
+	if req.ProviderData == nil {
+		return
+	}
+
+	provider, ok := req.ProviderData.(*IpamProvider)
+	if !ok {
+		resp.Diagnostics.AddError(
+			"Unexpected Resource Configure Type",
+			fmt.Sprintf("Expected *IpamProvider, got: %T", req.ProviderData),
+		)
+		return
+	}
+
+	d.provider = provider
+}
+
+func (d *PoolStatsDataSource) Read(ctx context.Context, req datasource.ReadRequest, resp *datasource.ReadResponse) {
+	var data PoolStatsDataSourceModel
+
+	resp.Diagnostics.Append(req.Config.Get(ctx, &data)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	family := data.AddressFamily.ValueString()
+	if family != "" && family != "ipv4" && family != "ipv6" {
+		resp.Diagnostics.AddAttributeError(
+			path.Root("address_family"),
+			"Invalid Address Family",
+			fmt.Sprintf("address_family must be 'ipv4' or 'ipv6', got '%s'", family),
+		)
+		return
+	}
+
+	// the pool and its allocations from one snapshot, so the totals add up while others allocate
+	poolName := data.PoolName.ValueString()
+	snapshot, err := d.provider.storage.Snapshot(ctx)
+	if err != nil {
+		resp.Diagnostics.AddError(
+			"Failed to Read Storage",
+			fmt.Sprintf("Could not read a snapshot of the pools and allocations in storage: %s", err),
+		)
+		return
+	}
+	pool := snapshot.Pool(poolName)
+	if pool == nil {
+		resp.Diagnostics.AddError(
+			"Pool Not Found",
+			fmt.Sprintf("Pool %s does not exist in storage", poolName),
+		)
+		return
+	}
+
+	if family == "" {
+		families := poolAddressFamilies(pool)
+		if len(families) > 1 {
+			resp.Diagnostics.AddAttributeError(
+				path.Root("address_family"),
+				"Address Family Required",
+				fmt.Sprintf("Pool %s has both IPv4 and IPv6 CIDRs, set address_family to choose which to report on", poolName),
+			)
+			return
+		}
+		family = "ipv4"
+		if len(families) == 1 {
+			family = families[0]
+		}
+	}
+
+	bits := 32
+	if family == "ipv6" {
+		bits = 128
+	}
+
+	allocations := snapshot.AllocationsByPool(poolName)
+
+	if !data.ExcludeIDs.IsNull() && !data.ExcludeIDs.IsUnknown() {
+		var excludeIDs []string
+		resp.Diagnostics.Append(data.ExcludeIDs.ElementsAs(ctx, &excludeIDs, false)...)
+		if resp.Diagnostics.HasError() {
+			return
+		}
+		allocations = excludeAllocations(allocations, excludeIDs)
+	}
+
+	total, used := poolUtilization(pool, allocations, bits)
+	free := new(big.Int).Sub(total, used)
+
+	data.AddressFamily = types.StringValue(family)
+	data.TotalAddresses = types.StringValue(total.String())
+	data.UsedAddresses = types.StringValue(used.String())
+	data.FreeAddresses = types.StringValue(free.String())
+	data.UtilizationPercent = types.Float64Value(utilizationPercent(total, used))
+
+	byOwner := map[string][]storage.Allocation{}
+	for _, allocation := range allocations {
+		if allocation.Owner != "" {
+			byOwner[allocation.Owner] = append(byOwner[allocation.Owner], allocation)
+		}
+	}
+	usedByOwner := make(map[string]string, len(byOwner))
+	for owner, ownerAllocations := range byOwner {
+		_, ownerUsed := poolUtilization(pool, ownerAllocations, bits)
+		usedByOwner[owner] = ownerUsed.String()
+	}
+	usedByOwnerValue, diag := types.MapValueFrom(ctx, types.StringType, usedByOwner)
+	resp.Diagnostics.Append(diag...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	data.UsedByOwner = usedByOwnerValue
+
+	resp.Diagnostics.Append(resp.State.Set(ctx, &data)...)
+}
+
+// excludeAllocations returns the allocations whose id isn't one of ids.
+func excludeAllocations(allocations []storage.Allocation, ids []string) []storage.Allocation {
+	excluded := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		excluded[id] = true
+	}
+
+	kept := make([]storage.Allocation, 0, len(allocations))
+	for _, allocation := range allocations {
+		if !excluded[allocation.ID] {
+			kept = append(kept, allocation)
+		}
+	}
+	return kept
+}
+
+// poolAddressFamilies returns the address families ("ipv4", "ipv6") of the pool's CIDRs.
+func poolAddressFamilies(pool *storage.Pool) []string {
+	var hasIPv4, hasIPv6 bool
+	for _, cidr := range poolCIDRs(pool) {
+		_, poolNet, err := net.ParseCIDR(cidr)
+		if err != nil {
+			continue
+		}
+		if _, bits := poolNet.Mask.Size(); bits == 128 {
+			hasIPv6 = true
+		} else {
+			hasIPv4 = true
+		}
+	}
+
+	var families []string
+	if hasIPv4 {
+		families = append(families, "ipv4")
+	}
+	if hasIPv6 {
+		families = append(families, "ipv6")
+	}
+	return families
+}
@@ -0,0 +1,173 @@
+package provider
+
+import (
+	"fmt"
+	"regexp"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-testing/helper/resource"
+	"github.com/hashicorp/terraform-plugin-testing/knownvalue"
+	"github.com/hashicorp/terraform-plugin-testing/statecheck"
+	"github.com/hashicorp/terraform-plugin-testing/tfjsonpath"
+)
+
+func TestAccPoolStatsDataSource_IPv4(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		PreCheck:                 func() { testAccPreCheck(t) },
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: testAccPoolStatsDataSourceConfig("stats-pool", []string{"10.0.0.0/24"}, 26, ""),
+				ConfigStateChecks: []statecheck.StateCheck{
+					statecheck.ExpectKnownValue(
+						"data.tfipam_pool_stats.test",
+						tfjsonpath.New("address_family"),
+						knownvalue.StringExact("ipv4"),
+					),
+					statecheck.ExpectKnownValue(
+						"data.tfipam_pool_stats.test",
+						tfjsonpath.New("total_addresses"),
+						knownvalue.StringExact("256"),
+					),
+					statecheck.ExpectKnownValue(
+						"data.tfipam_pool_stats.test",
+						tfjsonpath.New("used_addresses"),
+						knownvalue.StringExact("64"),
+					),
+					statecheck.ExpectKnownValue(
+						"data.tfipam_pool_stats.test",
+						tfjsonpath.New("free_addresses"),
+						knownvalue.StringExact("192"),
+					),
+					statecheck.ExpectKnownValue(
+						"data.tfipam_pool_stats.test",
+						tfjsonpath.New("utilization_percent"),
+						knownvalue.Float64Exact(25),
+					),
+				},
+			},
+		},
+	})
+}
+
+func TestAccPoolStatsDataSource_IPv6(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		PreCheck:                 func() { testAccPreCheck(t) },
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: testAccPoolStatsDataSourceConfig("stats-pool-ipv6", []string{"2001:db8::/32"}, 34, ""),
+				ConfigStateChecks: []statecheck.StateCheck{
+					statecheck.ExpectKnownValue(
+						"data.tfipam_pool_stats.test",
+						tfjsonpath.New("address_family"),
+						knownvalue.StringExact("ipv6"),
+					),
+					statecheck.ExpectKnownValue(
+						"data.tfipam_pool_stats.test",
+						tfjsonpath.New("total_addresses"),
+						knownvalue.StringExact("79228162514264337593543950336"),
+					),
+					statecheck.ExpectKnownValue(
+						"data.tfipam_pool_stats.test",
+						tfjsonpath.New("free_addresses"),
+						knownvalue.StringExact("59421121885698253195157962752"),
+					),
+				},
+			},
+		},
+	})
+}
+
+func TestAccPoolStatsDataSource_MixedFamilies(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		PreCheck:                 func() { testAccPreCheck(t) },
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			// The family has to be chosen when the pool has both
+			{
+				Config:      testAccPoolStatsDataSourceConfig("stats-pool-mixed", []string{"10.0.0.0/24", "2001:db8::/32"}, 26, ""),
+				ExpectError: regexp.MustCompile("Address Family Required"),
+			},
+			{
+				Config: testAccPoolStatsDataSourceConfig("stats-pool-mixed", []string{"10.0.0.0/24", "2001:db8::/32"}, 26, "ipv4"),
+				ConfigStateChecks: []statecheck.StateCheck{
+					statecheck.ExpectKnownValue(
+						"data.tfipam_pool_stats.test",
+						tfjsonpath.New("total_addresses"),
+						knownvalue.StringExact("256"),
+					),
+				},
+			},
+		},
+	})
+}
+
+func TestAccPoolStatsDataSource_ExcludeAllocations(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		PreCheck:                 func() { testAccPreCheck(t) },
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			// the allocation's space counts as free as if it were already deleted
+			{
+				Config: testAccPoolStatsDataSourceConfig("stats-pool-exclude", []string{"10.0.0.0/24"}, 26, "") +
+					`
+data "tfipam_pool_stats" "without" {
+  pool_name              = tfipam_pool.test.name
+  exclude_allocation_ids = [tfipam_allocation.test.id]
+}
+`,
+				ConfigStateChecks: []statecheck.StateCheck{
+					statecheck.ExpectKnownValue(
+						"data.tfipam_pool_stats.without",
+						tfjsonpath.New("free_addresses"),
+						knownvalue.StringExact("256"),
+					),
+					statecheck.ExpectKnownValue(
+						"data.tfipam_pool_stats.without",
+						tfjsonpath.New("utilization_percent"),
+						knownvalue.Float64Exact(0),
+					),
+					statecheck.ExpectKnownValue(
+						"data.tfipam_pool_stats.test",
+						tfjsonpath.New("free_addresses"),
+						knownvalue.StringExact("192"),
+					),
+				},
+			},
+		},
+	})
+}
+
+// testAccPoolStatsDataSourceConfig generates a configuration with a pool, one allocation and a pool stats data source.
+func testAccPoolStatsDataSourceConfig(poolName string, cidrs []string, prefixLength int, addressFamily string) string {
+	cidrsConfig := ""
+	for _, cidr := range cidrs {
+		cidrsConfig += fmt.Sprintf("%q, ", cidr)
+	}
+
+	addressFamilyConfig := ""
+	if addressFamily != "" {
+		addressFamilyConfig = fmt.Sprintf("address_family = %q", addressFamily)
+	}
+
+	return fmt.Sprintf(`
+resource "tfipam_pool" "test" {
+  name  = %[1]q
+  cidrs = [%[2]s]
+}
+
+resource "tfipam_allocation" "test" {
+  id            = "%[1]s-alloc"
+  pool_name     = tfipam_pool.test.name
+  prefix_length = %[3]d
+}
+
+data "tfipam_pool_stats" "test" {
+  pool_name = tfipam_pool.test.name
+  %[4]s
+
+  depends_on = [tfipam_allocation.test]
+}
+`, poolName, cidrsConfig, prefixLength, addressFamilyConfig)
+}
@@ -0,0 +1,164 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/terraform-plugin-framework/attr"
+	"github.com/hashicorp/terraform-plugin-framework/datasource"
+	"github.com/hashicorp/terraform-plugin-framework/datasource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+)
+
+var _ datasource.DataSource = &PoolsDataSource{}
+
+func NewPoolsDataSource() datasource.DataSource {
+	return &PoolsDataSource{}
+}
+
+type PoolsDataSource struct {
+	provider *IpamProvider
+}
+
+type PoolsDataSourceModel struct {
+	NamePrefix types.String `tfsdk:"name_prefix"`
+	NameGlob   types.String `tfsdk:"name_glob"`
+	Pools      types.List   `tfsdk:"pools"`
+}
+
+var poolsDataSourcePoolType = types.ObjectType{
+	AttrTypes: map[string]attr.Type{
+		"name":  types.StringType,
+		"cidrs": types.ListType{ElemType: types.StringType},
+	},
+}
+
+func (d *PoolsDataSource) Metadata(ctx context.Context, req datasource.MetadataRequest, resp *datasource.MetadataResponse) {
+	resp.TypeName = req.ProviderTypeName + "_pools"
+}
+
+func (d *PoolsDataSource) Schema(ctx context.Context, req datasource.SchemaRequest, resp *datasource.SchemaResponse) {
+	resp.Schema = schema.Schema{
+		MarkdownDescription: "IPAM pools data source for discovering pools by name",
+
+		Attributes: map[string]schema.Attribute{
+			"name_prefix": schema.StringAttribute{
+				MarkdownDescription: "Only return pools whose name starts with this prefix",
+				Optional:            true,
+			},
+			"name_glob": schema.StringAttribute{
+				MarkdownDescription: "Only return pools whose name matches this glob pattern, e.g. 'prod-*'",
+				Optional:            true,
+			},
+			"pools": schema.ListNestedAttribute{
+				MarkdownDescription: "Matching pools, sorted by name",
+				Computed:            true,
+				NestedObject: schema.NestedAttributeObject{
+					Attributes: map[string]schema.Attribute{
+						"name": schema.StringAttribute{
+							MarkdownDescription: "Name of the IP pool",
+							Computed:            true,
+						},
+						"cidrs": schema.ListAttribute{
+							MarkdownDescription: "CIDR blocks in the pool",
+							Computed:            true,
+							ElementType:         types.StringType,
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+func (d *PoolsDataSource) Configure(ctx context.Context, req datasource.ConfigureRequest, resp *datasource.ConfigureResponse) {
+	if req.ProviderData == nil {
+		return
+	}
+
+	provider, ok := req.ProviderData.(*IpamProvider)
+	if !ok {
+		resp.Diagnostics.AddError(
+			"Unexpected Resource Configure Type",
+			fmt.Sprintf("Expected *IpamProvider, got: %T", req.ProviderData),
+		)
+		return
+	}
+
+	d.provider = provider
+}
+
+func (d *PoolsDataSource) Read(ctx context.Context, req datasource.ReadRequest, resp *datasource.ReadResponse) {
+	var data PoolsDataSourceModel
+
+	resp.Diagnostics.Append(req.Config.Get(ctx, &data)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	namePrefix := data.NamePrefix.ValueString()
+	nameGlob := data.NameGlob.ValueString()
+	if nameGlob != "" {
+		if _, err := path.Match(nameGlob, ""); err != nil {
+			resp.Diagnostics.AddError(
+				"Invalid Name Glob",
+				fmt.Sprintf("Glob pattern '%s' is not valid: %s", nameGlob, err),
+			)
+			return
+		}
+	}
+
+	pools, err := d.provider.storage.ListPools(ctx)
+	if err != nil {
+		resp.Diagnostics.AddError(
+			"Failed to List Pools",
+			fmt.Sprintf("Could not list pools from storage: %s", err),
+		)
+		return
+	}
+
+	// storage doesn't guarantee an order, sort so results are stable between reads
+	sort.Slice(pools, func(i, j int) bool {
+		return pools[i].Name < pools[j].Name
+	})
+
+	poolValues := make([]attr.Value, 0, len(pools))
+	for _, pool := range pools {
+		if !strings.HasPrefix(pool.Name, namePrefix) {
+			continue
+		}
+		if nameGlob != "" {
+			if matched, _ := path.Match(nameGlob, pool.Name); !matched {
+				continue
+			}
+		}
+
+		cidrs, diag := types.ListValueFrom(ctx, types.StringType, pool.CIDRs)
+		resp.Diagnostics.Append(diag...)
+		if resp.Diagnostics.HasError() {
+			return
+		}
+
+		poolValue, diag := types.ObjectValue(poolsDataSourcePoolType.AttrTypes, map[string]attr.Value{
+			"name":  types.StringValue(pool.Name),
+			"cidrs": cidrs,
+		})
+		resp.Diagnostics.Append(diag...)
+		if resp.Diagnostics.HasError() {
+			return
+		}
+		poolValues = append(poolValues, poolValue)
+	}
+
+	poolsList, diag := types.ListValue(poolsDataSourcePoolType, poolValues)
+	resp.Diagnostics.Append(diag...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	data.Pools = poolsList
+
+	resp.Diagnostics.Append(resp.State.Set(ctx, &data)...)
+}
@@ -0,0 +1,132 @@
+package provider
+
+import (
+	"fmt"
+	"regexp"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-testing/helper/resource"
+	"github.com/hashicorp/terraform-plugin-testing/knownvalue"
+	"github.com/hashicorp/terraform-plugin-testing/statecheck"
+	"github.com/hashicorp/terraform-plugin-testing/tfjsonpath"
+)
+
+func TestAccPoolsDataSource_NamePrefix(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		PreCheck:                 func() { testAccPreCheck(t) },
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: testAccPoolsDataSourceConfig(`name_prefix = "pools-prod-"`),
+				ConfigStateChecks: []statecheck.StateCheck{
+					statecheck.ExpectKnownValue(
+						"data.tfipam_pools.test",
+						tfjsonpath.New("pools"),
+						knownvalue.ListExact([]knownvalue.Check{
+							knownvalue.ObjectExact(map[string]knownvalue.Check{
+								"name": knownvalue.StringExact("pools-prod-a"),
+								"cidrs": knownvalue.ListExact([]knownvalue.Check{
+									knownvalue.StringExact("10.0.0.0/16"),
+								}),
+							}),
+							knownvalue.ObjectExact(map[string]knownvalue.Check{
+								"name": knownvalue.StringExact("pools-prod-b"),
+								"cidrs": knownvalue.ListExact([]knownvalue.Check{
+									knownvalue.StringExact("10.1.0.0/16"),
+								}),
+							}),
+						}),
+					),
+				},
+			},
+		},
+	})
+}
+
+func TestAccPoolsDataSource_NameGlob(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		PreCheck:                 func() { testAccPreCheck(t) },
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: testAccPoolsDataSourceConfig(`name_glob = "pools-*-b"`),
+				ConfigStateChecks: []statecheck.StateCheck{
+					statecheck.ExpectKnownValue(
+						"data.tfipam_pools.test",
+						tfjsonpath.New("pools"),
+						knownvalue.ListExact([]knownvalue.Check{
+							knownvalue.ObjectPartial(map[string]knownvalue.Check{
+								"name": knownvalue.StringExact("pools-prod-b"),
+							}),
+							knownvalue.ObjectPartial(map[string]knownvalue.Check{
+								"name": knownvalue.StringExact("pools-staging-b"),
+							}),
+						}),
+					),
+				},
+			},
+		},
+	})
+}
+
+func TestAccPoolsDataSource_NoMatches(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		PreCheck:                 func() { testAccPreCheck(t) },
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: testAccPoolsDataSourceConfig(`name_prefix = "pools-dev-"`),
+				ConfigStateChecks: []statecheck.StateCheck{
+					statecheck.ExpectKnownValue(
+						"data.tfipam_pools.test",
+						tfjsonpath.New("pools"),
+						knownvalue.ListSizeExact(0),
+					),
+				},
+			},
+		},
+	})
+}
+
+func TestAccPoolsDataSource_InvalidGlob(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		PreCheck:                 func() { testAccPreCheck(t) },
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config:      testAccPoolsDataSourceConfig(`name_glob = "pools-[prod"`),
+				ExpectError: regexp.MustCompile("Invalid Name Glob"),
+			},
+		},
+	})
+}
+
+// testAccPoolsDataSourceConfig generates a configuration with several pools and a filtered pools data source.
+func testAccPoolsDataSourceConfig(filter string) string {
+	return fmt.Sprintf(`
+resource "tfipam_pool" "prod_a" {
+  name  = "pools-prod-a"
+  cidrs = ["10.0.0.0/16"]
+}
+
+resource "tfipam_pool" "prod_b" {
+  name  = "pools-prod-b"
+  cidrs = ["10.1.0.0/16"]
+}
+
+resource "tfipam_pool" "staging_b" {
+  name  = "pools-staging-b"
+  cidrs = ["10.2.0.0/16"]
+}
+
+data "tfipam_pools" "test" {
+  %[1]s
+
+  depends_on = [
+    tfipam_pool.prod_a,
+    tfipam_pool.prod_b,
+    tfipam_pool.staging_b,
+  ]
+}
+`, filter)
+}
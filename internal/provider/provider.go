@@ -2,12 +2,25 @@ package provider
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 
+	"github.com/hashicorp/go-uuid"
 	"github.com/hashicorp/terraform-plugin-framework/action"
 	"github.com/hashicorp/terraform-plugin-framework/datasource"
+	"github.com/hashicorp/terraform-plugin-framework/diag"
 	"github.com/hashicorp/terraform-plugin-framework/ephemeral"
 	"github.com/hashicorp/terraform-plugin-framework/function"
+	"github.com/hashicorp/terraform-plugin-framework/path"
 	"github.com/hashicorp/terraform-plugin-framework/provider"
 	"github.com/hashicorp/terraform-plugin-framework/provider/schema"
 	"github.com/hashicorp/terraform-plugin-framework/resource"
@@ -17,10 +30,19 @@ import (
 	"terraform-provider-tfipam/internal/provider/storage"
 )
 
+const defaultClaimTimeout = 30 * time.Second
+
+// placeholders an allocation_id_template is expanded with
+var allocationIDPlaceholder = regexp.MustCompile(`\$\{([^}]*)\}`)
+
+// percentage of free space in a pool below which allocations warn that the pool is nearly exhausted
+const defaultExhaustionWarnThreshold = 10.0
+
 var _ provider.Provider = &IpamProvider{}
 var _ provider.ProviderWithFunctions = &IpamProvider{}
 var _ provider.ProviderWithEphemeralResources = &IpamProvider{}
 var _ provider.ProviderWithActions = &IpamProvider{}
+var _ provider.ProviderWithValidateConfig = &IpamProvider{}
 
 type IpamProvider struct {
 	// version is set to the provider version on release, "dev" when the
@@ -30,23 +52,100 @@ type IpamProvider struct {
 
 	// storage backend for persistent state
 	storage storage.Storage
+	// type of the storage backend, e.g. "file" or "aws_s3"
+	storageType string
+
+	// how long a write keeps retrying when another writer changes storage first
+	claimTimeout time.Duration
+
+	// free space percentage below which a new allocation warns about pool exhaustion, 0 disables the warning
+	exhaustionWarnThreshold float64
+
+	// CIDRs no allocation may overlap, whatever pool it's in
+	globallyReservedCIDRs []*net.IPNet
+
+	// shortest prefix length an IPv6 block may be claimed with, 0 allows any
+	ipv6MinPrefix int
+
+	// whether reading an allocation warns when it's no longer inside its pool's CIDRs
+	verifyAllocationsInPool bool
+
+	// template the ids of allocations without one are generated from, UUIDs are generated when empty
+	allocationIDTemplate string
+
+	// notified of allocations created and deleted when allocation_webhook_url is set, nil otherwise
+	allocationWebhook *allocationWebhook
+
+	// serves gauges of the storage contents when metrics_addr is set, nil otherwise
+	metrics *metricsServer
+
+	// blocks of the allocations deleted during this run, keyed by allocation id, so a sticky
+	// allocation that's replaced gets its block back when it's recreated
+	releasedMu    sync.Mutex
+	releasedCIDRs map[string]releasedCIDR
+
+	// one lock per pool name, held while a block of the pool is claimed, so allocations Terraform
+	// creates in parallel don't all take the block they found free
+	poolLocksMu sync.Mutex
+	poolLocks   map[string]*sync.Mutex
+}
+
+// releasedCIDR is the block an allocation held in a pool before it was deleted.
+type releasedCIDR struct {
+	poolName string
+	cidr     string
 }
 
 // provider data model.
 type IpamProviderModel struct {
-	StorageType           types.String `tfsdk:"storage_type"`
-	FilePath              types.String `tfsdk:"file_path"`
-	AzureConnectionString types.String `tfsdk:"azure_connection_string"`
-	AzureContainerName    types.String `tfsdk:"azure_container_name"`
-	AzureBlobName         types.String `tfsdk:"azure_blob_name"`
-	S3Region              types.String `tfsdk:"s3_region"`
-	S3BucketName          types.String `tfsdk:"s3_bucket_name"`
-	S3ObjectKey           types.String `tfsdk:"s3_object_key"`
-	S3AccessKeyID         types.String `tfsdk:"s3_access_key_id"`
-	S3SecretAccessKey     types.String `tfsdk:"s3_secret_access_key"`
-	S3SessionToken        types.String `tfsdk:"s3_session_token"`
-	S3EndpointURL         types.String `tfsdk:"s3_endpoint_url"`
-	S3SkipTLSVerify       types.Bool   `tfsdk:"s3_skip_tls_verify"`
+	StorageType               types.String  `tfsdk:"storage_type"`
+	StorageKeyPrefix          types.String  `tfsdk:"storage_key_prefix"`
+	CompactJSON               types.Bool    `tfsdk:"compact_json"`
+	StorageContentType        types.String  `tfsdk:"storage_content_type"`
+	StorageCacheControl       types.String  `tfsdk:"storage_cache_control"`
+	ShardedStorage            types.Bool    `tfsdk:"sharded_storage"`
+	Namespace                 types.String  `tfsdk:"namespace"`
+	AuditObjectKey            types.String  `tfsdk:"audit_object_key"`
+	FilePath                  types.String  `tfsdk:"file_path"`
+	FallbackFilePath          types.String  `tfsdk:"fallback_file_path"`
+	SQLitePath                types.String  `tfsdk:"sqlite_path"`
+	AzureConnectionString     types.String  `tfsdk:"azure_connection_string"`
+	AzureConnectionStringFile types.String  `tfsdk:"azure_connection_string_file"`
+	AzureAccountURL           types.String  `tfsdk:"azure_account_url"`
+	AzureContainerName        types.String  `tfsdk:"azure_container_name"`
+	AzureBlobName             types.String  `tfsdk:"azure_blob_name"`
+	S3Region                  types.String  `tfsdk:"s3_region"`
+	S3BucketName              types.String  `tfsdk:"s3_bucket_name"`
+	S3ObjectKey               types.String  `tfsdk:"s3_object_key"`
+	S3AccessKeyID             types.String  `tfsdk:"s3_access_key_id"`
+	S3AccessKeyIDFile         types.String  `tfsdk:"s3_access_key_id_file"`
+	S3SecretAccessKey         types.String  `tfsdk:"s3_secret_access_key"`
+	S3SecretAccessKeyFile     types.String  `tfsdk:"s3_secret_access_key_file"`
+	S3SessionToken            types.String  `tfsdk:"s3_session_token"`
+	S3SessionTokenFile        types.String  `tfsdk:"s3_session_token_file"`
+	S3Profile                 types.String  `tfsdk:"s3_profile"`
+	S3RoleARN                 types.String  `tfsdk:"s3_role_arn"`
+	S3RoleSessionName         types.String  `tfsdk:"s3_role_session_name"`
+	S3ExternalID              types.String  `tfsdk:"s3_external_id"`
+	S3SSE                     types.String  `tfsdk:"s3_sse"`
+	S3KMSKeyID                types.String  `tfsdk:"s3_kms_key_id"`
+	S3EndpointURL             types.String  `tfsdk:"s3_endpoint_url"`
+	S3SkipTLSVerify           types.Bool    `tfsdk:"s3_skip_tls_verify"`
+	ClaimTimeout              types.String  `tfsdk:"claim_timeout"`
+	OperationTimeout          types.String  `tfsdk:"operation_timeout"`
+	LogStorageOperations      types.Bool    `tfsdk:"log_storage_operations"`
+	LockMaxWait               types.String  `tfsdk:"lock_max_wait"`
+	SoftDeleteRetention       types.String  `tfsdk:"soft_delete_retention"`
+	ReadMaxRetries            types.Int64   `tfsdk:"read_max_retries"`
+	WriteMaxRetries           types.Int64   `tfsdk:"write_max_retries"`
+	ExhaustionWarnThreshold   types.Float64 `tfsdk:"exhaustion_warn_threshold"`
+	GloballyReservedCIDRs     types.List    `tfsdk:"globally_reserved_cidrs"`
+	IPv6MinPrefix             types.Int64   `tfsdk:"ipv6_min_prefix"`
+	VerifyAllocationsInPool   types.Bool    `tfsdk:"verify_allocations_in_pool"`
+	AllocationIDTemplate      types.String  `tfsdk:"allocation_id_template"`
+	MetricsAddr               types.String  `tfsdk:"metrics_addr"`
+	AllocationWebhookURL      types.String  `tfsdk:"allocation_webhook_url"`
+	AllocationWebhookStrict   types.Bool    `tfsdk:"allocation_webhook_strict"`
 }
 
 func (p *IpamProvider) Metadata(ctx context.Context, req provider.MetadataRequest, resp *provider.MetadataResponse) {
@@ -60,16 +159,60 @@ func (p *IpamProvider) Schema(ctx context.Context, req provider.SchemaRequest, r
 		Attributes: map[string]schema.Attribute{
 			"storage_type": schema.StringAttribute{
 				Optional:            true,
-				MarkdownDescription: "Storage backend type. Supported values: 'file' (default), 'azure_blob' (Azure Blob Storage), 'aws_s3' (AWS S3)",
+				MarkdownDescription: "Storage backend type. Supported values: 'file' (default), 'azure_blob' (Azure Blob Storage), 'aws_s3' (AWS S3), 'sqlite' (local SQLite database), 'state' (no backend, data is kept in `tfipam_registry` resources in Terraform state)",
+			},
+			"storage_key_prefix": schema.StringAttribute{
+				Optional:            true,
+				MarkdownDescription: "Prefix prepended to the blob name or object key of the 'azure_blob' and 'aws_s3' backends, e.g. 'ipam/prod'. Lets one bucket or container hold many isolated IPAM datasets. Environment variables are expanded in the prefix, file path, blob name and object key, use `$${VAR}` to keep Terraform from interpolating them",
+			},
+			"compact_json": schema.BoolAttribute{
+				Optional:            true,
+				MarkdownDescription: "Write the 'file', 'azure_blob' and 'aws_s3' storage data as compact JSON instead of indented. Reduces object size and transfer time for large stores. Defaults to false",
+			},
+			"storage_content_type": schema.StringAttribute{
+				Optional:            true,
+				MarkdownDescription: "Content-Type of the 'azure_blob' and 'aws_s3' storage objects, shown by the object store and sent when the object is served. Defaults to 'application/json'",
+			},
+			"storage_cache_control": schema.StringAttribute{
+				Optional:            true,
+				MarkdownDescription: "Cache-Control header of the 'azure_blob' and 'aws_s3' storage objects, e.g. 'no-cache' when they're served through a CDN. Not set by default",
+			},
+			"sharded_storage": schema.BoolAttribute{
+				Optional:            true,
+				MarkdownDescription: "Keep each pool's allocations in their own object next to the 'file', 'azure_blob' or 'aws_s3' storage data instead of one shared object, so changes to different pools never conflict. Pools stay in the main object. Defaults to false",
+			},
+			"namespace": schema.StringAttribute{
+				Optional:            true,
+				MarkdownDescription: "Keeps this configuration's pools and allocations apart from other namespaces in the same storage backend, e.g. 'dev' and 'prod', so each can have a pool of the same name. Entries are stored as '<namespace>/<name>' and only those in the namespace are visible. Can't contain '/'. Everything in the backend is visible when not set",
+			},
+			"audit_object_key": schema.StringAttribute{
+				Optional:            true,
+				MarkdownDescription: "Enables a JSON lines audit log with an entry for every allocation and release. A blob name or object key in the same container or bucket for the 'azure_blob' and 'aws_s3' backends, or a file path for the 'file' and 'sqlite' backends. Disabled by default",
 			},
 			"file_path": schema.StringAttribute{
 				Optional:            true,
-				MarkdownDescription: "Path to storage file for 'file' storage backend. Required for 'file' backend. Defaults to '.terraform/ipam-storage.json'",
+				MarkdownDescription: "Path to storage file for 'file' storage backend. A relative path and `${module_path}` are resolved against the root module directory. Defaults to '.terraform/ipam-storage.json'",
+			},
+			"fallback_file_path": schema.StringAttribute{
+				Optional:            true,
+				MarkdownDescription: "Path to a local file every change is mirrored to, read from when the storage backend can't be reached so plans and data sources keep working. Changes fail while the backend is down. The file is synced with the backend whenever the provider starts. A relative path and `${module_path}` are resolved against the root module directory. Can't be used with the 'state' backend. Not set by default",
+			},
+			"sqlite_path": schema.StringAttribute{
+				Optional:            true,
+				MarkdownDescription: "Path to the database file for the 'sqlite' storage backend. Created if it doesn't exist. A relative path and `${module_path}` are resolved against the root module directory. Defaults to '.terraform/ipam-storage.db'",
 			},
 			"azure_connection_string": schema.StringAttribute{
 				Optional:            true,
 				Sensitive:           true,
-				MarkdownDescription: "Connection string for Azure Blob Storage. Required for 'azure_blob' backend.",
+				MarkdownDescription: "Connection string for Azure Blob Storage. Required for 'azure_blob' backend unless `azure_account_url` is set.",
+			},
+			"azure_connection_string_file": schema.StringAttribute{
+				Optional:            true,
+				MarkdownDescription: "Path to a file containing the Azure Blob Storage connection string, e.g. a mounted Kubernetes secret. Ignored when `azure_connection_string` is set.",
+			},
+			"azure_account_url": schema.StringAttribute{
+				Optional:            true,
+				MarkdownDescription: "Blob service URL of the storage account, e.g. 'https://myaccount.blob.core.windows.net'. Authenticates with Azure AD instead of a connection string, using workload identity, managed identity, environment variables or the Azure CLI. A connection string takes precedence when both are set.",
 			},
 			"azure_container_name": schema.StringAttribute{
 				Optional:            true,
@@ -96,16 +239,53 @@ func (p *IpamProvider) Schema(ctx context.Context, req provider.SchemaRequest, r
 				Sensitive:           true,
 				MarkdownDescription: "AWS Access Key ID. Optional - uses default AWS credential chain if not provided.",
 			},
+			"s3_access_key_id_file": schema.StringAttribute{
+				Optional:            true,
+				MarkdownDescription: "Path to a file containing the AWS Access Key ID. Ignored when `s3_access_key_id` is set.",
+			},
 			"s3_secret_access_key": schema.StringAttribute{
 				Optional:            true,
 				Sensitive:           true,
 				MarkdownDescription: "AWS Secret Access Key. Required if s3_access_key_id is provided.",
 			},
+			"s3_secret_access_key_file": schema.StringAttribute{
+				Optional:            true,
+				MarkdownDescription: "Path to a file containing the AWS Secret Access Key. Ignored when `s3_secret_access_key` is set.",
+			},
 			"s3_session_token": schema.StringAttribute{
 				Optional:            true,
 				Sensitive:           true,
 				MarkdownDescription: "AWS Session Token. Optional - for temporary credentials.",
 			},
+			"s3_session_token_file": schema.StringAttribute{
+				Optional:            true,
+				MarkdownDescription: "Path to a file containing the AWS Session Token. Ignored when `s3_session_token` is set.",
+			},
+			"s3_profile": schema.StringAttribute{
+				Optional:            true,
+				MarkdownDescription: "Named profile from the shared AWS config and credentials files, e.g. ~/.aws/config. Ignored when access keys are provided, and the default AWS credential chain is used when neither is set.",
+			},
+			"s3_role_arn": schema.StringAttribute{
+				Optional:            true,
+				MarkdownDescription: "ARN of an IAM role to assume for access to the S3 bucket, e.g. a bucket in a central account. The role is assumed with the access keys, profile or default credential chain.",
+			},
+			"s3_role_session_name": schema.StringAttribute{
+				Optional:            true,
+				MarkdownDescription: "Session name of the assumed role, shown in CloudTrail. Requires `s3_role_arn`. Defaults to 'terraform-provider-tfipam'.",
+			},
+			"s3_external_id": schema.StringAttribute{
+				Optional:            true,
+				Sensitive:           true,
+				MarkdownDescription: "External ID to pass when assuming the role, if its trust policy requires one. Requires `s3_role_arn`.",
+			},
+			"s3_sse": schema.StringAttribute{
+				Optional:            true,
+				MarkdownDescription: "Server-side encryption of the objects the provider writes, 'AES256', 'aws:kms' or 'aws:kms:dsse'. Defaults to the bucket's default encryption.",
+			},
+			"s3_kms_key_id": schema.StringAttribute{
+				Optional:            true,
+				MarkdownDescription: "ID or ARN of the KMS key to encrypt the objects with. Requires `s3_sse` to be 'aws:kms' or 'aws:kms:dsse'. Defaults to the AWS managed key.",
+			},
 			"s3_endpoint_url": schema.StringAttribute{
 				Optional:            true,
 				MarkdownDescription: "Custom S3 endpoint URL. Optional - for S3 compatible services like MinIO or LocalStack.",
@@ -114,10 +294,204 @@ func (p *IpamProvider) Schema(ctx context.Context, req provider.SchemaRequest, r
 				Optional:            true,
 				MarkdownDescription: "Skip TLS certificate verification. Optional - can be useful with self signed certificates on S3 compatible services",
 			},
+			"claim_timeout": schema.StringAttribute{
+				Optional:            true,
+				MarkdownDescription: "How long an allocation or pool change keeps retrying when another provider instance writes to the shared storage at the same time, e.g. '30s' or '2m'. Defaults to '30s'",
+			},
+			"operation_timeout": schema.StringAttribute{
+				Optional:            true,
+				MarkdownDescription: "Maximum duration of a single storage operation, like loading or saving the S3 object, e.g. '30s' or '1m'. A backend that doesn't respond in time fails the operation with an error instead of hanging the apply. Defaults to no timeout",
+			},
+			"log_storage_operations": schema.BoolAttribute{
+				Optional:            true,
+				MarkdownDescription: "Log every storage operation, like getting, saving, deleting or listing pools and allocations, at debug level with its pool name or allocation key and how long it took. Works the same for every backend and helps find the storage calls that slow down an apply. Shown with `TF_LOG=DEBUG` or `TF_LOG_PROVIDER=DEBUG`. Defaults to false",
+			},
+			"lock_max_wait": schema.StringAttribute{
+				Optional:            true,
+				MarkdownDescription: "How long a write waits for another process to release its lock on the storage before failing, e.g. '30s' or '2m', so parallel runs queue instead of erroring. Only the 'file' and 'sqlite' backends lock, the other backends retry conflicting writes for `claim_timeout` instead. Defaults to '5s'",
+			},
+			"soft_delete_retention": schema.StringAttribute{
+				Optional:            true,
+				MarkdownDescription: "How long released allocations are kept before they're purged, e.g. '720h'. A released allocation frees its CIDR and id right away, but stays listed by the `tfipam_deleted_allocations` data source for auditing and recovering from an accidental release until the `tfipam_purge_deleted_allocations` action purges it after this long. Released allocations are deleted right away when not set",
+			},
+			"read_max_retries": schema.Int64Attribute{
+				Optional:            true,
+				MarkdownDescription: "How many times the azure_blob and aws_s3 backends retry a failed read, like loading the data or checking the bucket. Reads are safe to retry, so this can be raised for flaky networks. Set to 0 to disable retries. Defaults to the cloud SDK's default",
+			},
+			"write_max_retries": schema.Int64Attribute{
+				Optional:            true,
+				MarkdownDescription: "How many times the azure_blob and aws_s3 backends retry a failed write, like uploading the data or the audit log. Writes are conditional, so a retried write that already succeeded is reported as a conflict and checked again instead of overwriting. Set to 0 to disable retries. Defaults to the cloud SDK's default",
+			},
+			"exhaustion_warn_threshold": schema.Float64Attribute{
+				Optional:            true,
+				MarkdownDescription: "Percentage of free addresses in a pool below which creating an allocation adds a warning that the pool is nearly exhausted. Set to 0 to disable the warning. Defaults to 10",
+			},
+			"globally_reserved_cidrs": schema.ListAttribute{
+				ElementType:         types.StringType,
+				Optional:            true,
+				MarkdownDescription: "CIDR blocks no allocation may overlap, regardless of the pools they're in, e.g. infrastructure or link-local ranges. Searches for a free block skip them and allocations that request an overlapping CIDR fail",
+			},
+			"ipv6_min_prefix": schema.Int64Attribute{
+				Optional:            true,
+				MarkdownDescription: "Shortest prefix length new IPv6 allocations and reservations may have, e.g. 48, guarding against a typo like a /33 from a /32 taking half of a pool. Allocations with `allow_short_ipv6_prefix` set are exempt. Searches of pools with both IPv4 and IPv6 CIDRs only look in the IPv4 ones for a shorter prefix. Not checked when not set",
+			},
+			"verify_allocations_in_pool": schema.BoolAttribute{
+				Optional:            true,
+				MarkdownDescription: "Check on every refresh that each allocation is still inside its pool's current CIDRs, and add a warning for the ones that aren't, e.g. after a pool's CIDRs were shrunk outside this configuration. Costs a pool read per allocation. Defaults to false",
+			},
+			"allocation_id_template": schema.StringAttribute{
+				Optional:            true,
+				MarkdownDescription: "Template the ids of `tfipam_allocation` resources without an `id` are generated from, instead of a UUID, e.g. `alloc-$${pool}-$${index}`. `$${pool}` is replaced with the pool name, `$${index}` with the number of allocations in the pool including the new one, and `$${cidr}` with the allocated CIDR with its '/' replaced by '-'. Escape the placeholders with `$$` so Terraform doesn't interpolate them. A generated id that's already in use gets the next index, or a '-2', '-3', ... suffix when the template has no `$${index}`",
+			},
+			"metrics_addr": schema.StringAttribute{
+				Optional:            true,
+				MarkdownDescription: "Address to serve Prometheus metrics on while the provider runs, e.g. '127.0.0.1:9090'. The gauges at `/metrics` count the pools and allocations in storage and the addresses and utilization of each pool, read from storage on every scrape. Disabled when not set",
+			},
+			"allocation_webhook_url": schema.StringAttribute{
+				Optional:            true,
+				MarkdownDescription: "HTTP or HTTPS URL a JSON payload is POSTed to whenever a `tfipam_allocation` or `tfipam_allocation_blocks` resource is created or deleted, once the change is saved to storage, e.g. to update DNS or monitoring. A request that fails or gets a non-2xx response only adds a warning unless `allocation_webhook_strict` is set. Disabled when not set",
+			},
+			"allocation_webhook_strict": schema.BoolAttribute{
+				Optional:            true,
+				MarkdownDescription: "Fail the create or delete of an allocation when its `allocation_webhook_url` request fails, instead of only warning. The change is already saved to storage by then, a created allocation is tainted and replaced on the next apply. Defaults to false",
+			},
 		},
 	}
 }
 
+func (p *IpamProvider) ValidateConfig(ctx context.Context, req provider.ValidateConfigRequest, resp *provider.ValidateConfigResponse) {
+	var data IpamProviderModel
+
+	resp.Diagnostics.Append(req.Config.Get(ctx, &data)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	// the required attributes depend on the storage type, so nothing can be checked until it's known
+	if data.StorageType.IsUnknown() {
+		return
+	}
+	storageType := "file"
+	if !data.StorageType.IsNull() {
+		storageType = data.StorageType.ValueString()
+	}
+
+	// the state backend's data comes from Terraform state, which has nothing to fall back from
+	if storageType == "state" && storageAttributeSet(data.FallbackFilePath) {
+		resp.Diagnostics.AddAttributeError(
+			path.Root("fallback_file_path"),
+			"Invalid Storage Attribute",
+			"fallback_file_path can't be used with storage_type 'state'",
+		)
+	}
+
+	switch storageType {
+	case "file", "sqlite", "state":
+		// every attribute of these backends has a default
+	case "azure_blob":
+		// a connection string, or an account url to authenticate to with Azure AD
+		if !storageAttributeSet(data.AzureConnectionString, data.AzureConnectionStringFile, data.AzureAccountURL) {
+			resp.Diagnostics.AddAttributeError(
+				path.Root("azure_connection_string"),
+				"Missing Storage Attribute",
+				fmt.Sprintf("storage_type '%s' requires azure_connection_string, azure_connection_string_file or azure_account_url to be set", storageType),
+			)
+		}
+		requireStorageAttribute(&resp.Diagnostics, storageType, "azure_container_name", data.AzureContainerName)
+	case "aws_s3":
+		requireStorageAttribute(&resp.Diagnostics, storageType, "s3_region", data.S3Region)
+		requireStorageAttribute(&resp.Diagnostics, storageType, "s3_bucket_name", data.S3BucketName)
+
+		// static credentials are a pair, without them the default AWS credential chain is used
+		hasAccessKeyID := storageAttributeSet(data.S3AccessKeyID, data.S3AccessKeyIDFile)
+		hasSecretAccessKey := storageAttributeSet(data.S3SecretAccessKey, data.S3SecretAccessKeyFile)
+		if hasAccessKeyID && !hasSecretAccessKey {
+			resp.Diagnostics.AddAttributeError(
+				path.Root("s3_secret_access_key"),
+				"Missing Storage Attribute",
+				"s3_secret_access_key or s3_secret_access_key_file must be set when s3_access_key_id is set",
+			)
+		}
+		if hasSecretAccessKey && !hasAccessKeyID {
+			resp.Diagnostics.AddAttributeError(
+				path.Root("s3_access_key_id"),
+				"Missing Storage Attribute",
+				"s3_access_key_id or s3_access_key_id_file must be set when s3_secret_access_key is set",
+			)
+		}
+
+		// the session name and external id only apply to an assumed role
+		if !storageAttributeSet(data.S3RoleARN) {
+			for _, attribute := range []struct {
+				name  string
+				value types.String
+			}{
+				{"s3_role_session_name", data.S3RoleSessionName},
+				{"s3_external_id", data.S3ExternalID},
+			} {
+				if storageAttributeSet(attribute.value) {
+					resp.Diagnostics.AddAttributeError(
+						path.Root(attribute.name),
+						"Missing Storage Attribute",
+						fmt.Sprintf("s3_role_arn must be set when %s is set", attribute.name),
+					)
+				}
+			}
+		}
+
+		sse := data.S3SSE.ValueString()
+		switch {
+		case data.S3SSE.IsUnknown():
+		case sse != "" && sse != "AES256" && sse != "aws:kms" && sse != "aws:kms:dsse":
+			resp.Diagnostics.AddAttributeError(
+				path.Root("s3_sse"),
+				"Invalid Server-Side Encryption",
+				fmt.Sprintf("s3_sse must be one of 'AES256', 'aws:kms' or 'aws:kms:dsse', got '%s'", sse),
+			)
+		case storageAttributeSet(data.S3KMSKeyID) && sse != "aws:kms" && sse != "aws:kms:dsse":
+			resp.Diagnostics.AddAttributeError(
+				path.Root("s3_kms_key_id"),
+				"Invalid Server-Side Encryption",
+				"s3_sse must be 'aws:kms' or 'aws:kms:dsse' when s3_kms_key_id is set",
+			)
+		}
+	default:
+		resp.Diagnostics.AddAttributeError(
+			path.Root("storage_type"),
+			"Invalid Storage Type",
+			fmt.Sprintf("storage_type must be one of 'file', 'azure_blob', 'aws_s3', 'sqlite' or 'state', got '%s'", storageType),
+		)
+	}
+}
+
+// requireStorageAttribute reports an attribute the storage type needs but the config doesn't set. values
+// are the attribute's value followed by the value of its _file variant, when it has one.
+func requireStorageAttribute(diags *diag.Diagnostics, storageType, attribute string, values ...types.String) {
+	if storageAttributeSet(values...) {
+		return
+	}
+	names := attribute
+	if len(values) > 1 {
+		names = fmt.Sprintf("%s or %s_file", attribute, attribute)
+	}
+	diags.AddAttributeError(
+		path.Root(attribute),
+		"Missing Storage Attribute",
+		fmt.Sprintf("storage_type '%s' requires %s to be set", storageType, names),
+	)
+}
+
+// storageAttributeSet reports whether either value is set to something other than an empty string. Unknown
+// values count as set, they're checked again once the backend is constructed.
+func storageAttributeSet(values ...types.String) bool {
+	for _, value := range values {
+		if value.IsUnknown() || value.ValueString() != "" {
+			return true
+		}
+	}
+	return false
+}
+
 func (p *IpamProvider) Configure(ctx context.Context, req provider.ConfigureRequest, resp *provider.ConfigureResponse) {
 	var data IpamProviderModel
 	resp.Diagnostics.Append(req.Config.Get(ctx, &data)...)
@@ -126,6 +500,99 @@ func (p *IpamProvider) Configure(ctx context.Context, req provider.ConfigureRequ
 		return
 	}
 
+	p.claimTimeout = defaultClaimTimeout
+	if !data.ClaimTimeout.IsNull() && !data.ClaimTimeout.IsUnknown() {
+		claimTimeout, err := time.ParseDuration(data.ClaimTimeout.ValueString())
+		if err != nil || claimTimeout < 0 {
+			resp.Diagnostics.AddAttributeError(
+				path.Root("claim_timeout"),
+				"Invalid Claim Timeout",
+				fmt.Sprintf("claim_timeout must be a non-negative duration like '30s', got '%s'", data.ClaimTimeout.ValueString()),
+			)
+			return
+		}
+		p.claimTimeout = claimTimeout
+	}
+
+	p.exhaustionWarnThreshold = defaultExhaustionWarnThreshold
+	if !data.ExhaustionWarnThreshold.IsNull() && !data.ExhaustionWarnThreshold.IsUnknown() {
+		threshold := data.ExhaustionWarnThreshold.ValueFloat64()
+		if threshold < 0 || threshold > 100 {
+			resp.Diagnostics.AddAttributeError(
+				path.Root("exhaustion_warn_threshold"),
+				"Invalid Exhaustion Warn Threshold",
+				fmt.Sprintf("exhaustion_warn_threshold must be a percentage between 0 and 100, got %g", threshold),
+			)
+			return
+		}
+		p.exhaustionWarnThreshold = threshold
+	}
+
+	p.globallyReservedCIDRs = nil
+	if !data.GloballyReservedCIDRs.IsNull() && !data.GloballyReservedCIDRs.IsUnknown() {
+		var reserved []string
+		resp.Diagnostics.Append(data.GloballyReservedCIDRs.ElementsAs(ctx, &reserved, false)...)
+		if resp.Diagnostics.HasError() {
+			return
+		}
+		for _, cidr := range reserved {
+			_, reservedNet, err := net.ParseCIDR(cidr)
+			if err != nil {
+				resp.Diagnostics.AddAttributeError(
+					path.Root("globally_reserved_cidrs"),
+					"Invalid Globally Reserved CIDR",
+					fmt.Sprintf("CIDR '%s' is not valid: %s", cidr, err),
+				)
+				return
+			}
+			p.globallyReservedCIDRs = append(p.globallyReservedCIDRs, reservedNet)
+		}
+	}
+
+	p.ipv6MinPrefix = 0
+	if !data.IPv6MinPrefix.IsNull() && !data.IPv6MinPrefix.IsUnknown() {
+		minPrefix := data.IPv6MinPrefix.ValueInt64()
+		if minPrefix < 1 || minPrefix > 128 {
+			resp.Diagnostics.AddAttributeError(
+				path.Root("ipv6_min_prefix"),
+				"Invalid IPv6 Min Prefix",
+				fmt.Sprintf("ipv6_min_prefix must be a prefix length between 1 and 128, got %d", minPrefix),
+			)
+			return
+		}
+		p.ipv6MinPrefix = int(minPrefix)
+	}
+
+	p.verifyAllocationsInPool = data.VerifyAllocationsInPool.ValueBool()
+
+	p.allocationWebhook = nil
+	if webhookURL := data.AllocationWebhookURL.ValueString(); webhookURL != "" {
+		parsed, err := url.Parse(webhookURL)
+		if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+			resp.Diagnostics.AddAttributeError(
+				path.Root("allocation_webhook_url"),
+				"Invalid Allocation Webhook URL",
+				fmt.Sprintf("allocation_webhook_url must be an http or https URL, got '%s'", webhookURL),
+			)
+			return
+		}
+		p.allocationWebhook = newAllocationWebhook(webhookURL, data.AllocationWebhookStrict.ValueBool())
+	}
+
+	p.allocationIDTemplate = data.AllocationIDTemplate.ValueString()
+	for _, match := range allocationIDPlaceholder.FindAllStringSubmatch(p.allocationIDTemplate, -1) {
+		switch match[1] {
+		case "pool", "index", "cidr":
+		default:
+			resp.Diagnostics.AddAttributeError(
+				path.Root("allocation_id_template"),
+				"Invalid Allocation ID Template",
+				fmt.Sprintf("allocation_id_template has unknown placeholder '%s', supported placeholders are ${pool}, ${index} and ${cidr}", match[0]),
+			)
+			return
+		}
+	}
+
 	// set up storage backend
 	if p.storage == nil {
 		storageType := "file"
@@ -137,15 +604,133 @@ func (p *IpamProvider) Configure(ctx context.Context, req provider.ConfigureRequ
 			Type: storageType,
 		}
 
+		if !data.OperationTimeout.IsNull() && !data.OperationTimeout.IsUnknown() {
+			operationTimeout, err := time.ParseDuration(data.OperationTimeout.ValueString())
+			if err != nil || operationTimeout < 0 {
+				resp.Diagnostics.AddAttributeError(
+					path.Root("operation_timeout"),
+					"Invalid Operation Timeout",
+					fmt.Sprintf("operation_timeout must be a non-negative duration like '30s', got '%s'", data.OperationTimeout.ValueString()),
+				)
+				return
+			}
+			storageConfig.OperationTimeout = operationTimeout
+		}
+
+		if !data.LogStorageOperations.IsNull() && !data.LogStorageOperations.IsUnknown() {
+			storageConfig.LogOperations = data.LogStorageOperations.ValueBool()
+		}
+
+		if !data.LockMaxWait.IsNull() && !data.LockMaxWait.IsUnknown() {
+			lockMaxWait, err := time.ParseDuration(data.LockMaxWait.ValueString())
+			if err != nil || lockMaxWait < 0 {
+				resp.Diagnostics.AddAttributeError(
+					path.Root("lock_max_wait"),
+					"Invalid Lock Max Wait",
+					fmt.Sprintf("lock_max_wait must be a non-negative duration like '30s', got '%s'", data.LockMaxWait.ValueString()),
+				)
+				return
+			}
+			storageConfig.LockMaxWait = lockMaxWait
+		}
+
+		if !data.SoftDeleteRetention.IsNull() && !data.SoftDeleteRetention.IsUnknown() {
+			retention, err := time.ParseDuration(data.SoftDeleteRetention.ValueString())
+			if err != nil || retention <= 0 {
+				resp.Diagnostics.AddAttributeError(
+					path.Root("soft_delete_retention"),
+					"Invalid Soft Delete Retention",
+					fmt.Sprintf("soft_delete_retention must be a positive duration like '720h', got '%s'", data.SoftDeleteRetention.ValueString()),
+				)
+				return
+			}
+			storageConfig.SoftDeleteRetention = retention
+		}
+
+		if !data.ReadMaxRetries.IsNull() && !data.ReadMaxRetries.IsUnknown() {
+			maxRetries, err := storageMaxRetries(data.ReadMaxRetries.ValueInt64())
+			if err != nil {
+				resp.Diagnostics.AddAttributeError(
+					path.Root("read_max_retries"),
+					"Invalid Retry Count",
+					fmt.Sprintf("read_max_retries %s", err),
+				)
+				return
+			}
+			storageConfig.ReadMaxRetries = maxRetries
+		}
+		if !data.WriteMaxRetries.IsNull() && !data.WriteMaxRetries.IsUnknown() {
+			maxRetries, err := storageMaxRetries(data.WriteMaxRetries.ValueInt64())
+			if err != nil {
+				resp.Diagnostics.AddAttributeError(
+					path.Root("write_max_retries"),
+					"Invalid Retry Count",
+					fmt.Sprintf("write_max_retries %s", err),
+				)
+				return
+			}
+			storageConfig.WriteMaxRetries = maxRetries
+		}
+
+		if !data.StorageKeyPrefix.IsNull() && !data.StorageKeyPrefix.IsUnknown() {
+			storageConfig.KeyPrefix = data.StorageKeyPrefix.ValueString()
+		}
+
+		if !data.CompactJSON.IsNull() && !data.CompactJSON.IsUnknown() {
+			storageConfig.CompactJSON = data.CompactJSON.ValueBool()
+		}
+		if !data.StorageContentType.IsNull() && !data.StorageContentType.IsUnknown() {
+			storageConfig.ContentType = data.StorageContentType.ValueString()
+		}
+		if !data.StorageCacheControl.IsNull() && !data.StorageCacheControl.IsUnknown() {
+			storageConfig.CacheControl = data.StorageCacheControl.ValueString()
+		}
+		if !data.ShardedStorage.IsNull() && !data.ShardedStorage.IsUnknown() {
+			storageConfig.Sharded = data.ShardedStorage.ValueBool()
+		}
+		if !data.Namespace.IsNull() && !data.Namespace.IsUnknown() {
+			namespace := data.Namespace.ValueString()
+			if namespace == "" || strings.Contains(namespace, "/") {
+				resp.Diagnostics.AddAttributeError(
+					path.Root("namespace"),
+					"Invalid Namespace",
+					fmt.Sprintf("namespace must be a non-empty name without '/', got '%s'", namespace),
+				)
+				return
+			}
+			storageConfig.Namespace = namespace
+		}
+
+		if !data.AuditObjectKey.IsNull() && !data.AuditObjectKey.IsUnknown() {
+			storageConfig.AuditObjectKey = data.AuditObjectKey.ValueString()
+		}
+
 		// File backend config
 		if !data.FilePath.IsNull() && !data.FilePath.IsUnknown() {
 			storageConfig.FilePath = data.FilePath.ValueString()
 		}
 
+		// a local file mirroring the backend, written like the file backend's
+		if !data.FallbackFilePath.IsNull() && !data.FallbackFilePath.IsUnknown() {
+			storageConfig.Fallbacks = []storage.Config{{
+				Type:        "file",
+				FilePath:    data.FallbackFilePath.ValueString(),
+				CompactJSON: storageConfig.CompactJSON,
+			}}
+		}
+
+		// SQLite backend config
+		if !data.SQLitePath.IsNull() && !data.SQLitePath.IsUnknown() {
+			storageConfig.SQLitePath = data.SQLitePath.ValueString()
+		}
+
 		// Azure backend config
 		if !data.AzureConnectionString.IsNull() && !data.AzureConnectionString.IsUnknown() {
 			storageConfig.AzureConnectionString = data.AzureConnectionString.ValueString()
 		}
+		if !data.AzureAccountURL.IsNull() && !data.AzureAccountURL.IsUnknown() {
+			storageConfig.AzureAccountURL = data.AzureAccountURL.ValueString()
+		}
 		if !data.AzureContainerName.IsNull() && !data.AzureContainerName.IsUnknown() {
 			storageConfig.AzureContainerName = data.AzureContainerName.ValueString()
 		}
@@ -172,6 +757,24 @@ func (p *IpamProvider) Configure(ctx context.Context, req provider.ConfigureRequ
 		if !data.S3SessionToken.IsNull() && !data.S3SessionToken.IsUnknown() {
 			storageConfig.S3SessionToken = data.S3SessionToken.ValueString()
 		}
+		if !data.S3Profile.IsNull() && !data.S3Profile.IsUnknown() {
+			storageConfig.S3Profile = data.S3Profile.ValueString()
+		}
+		if !data.S3RoleARN.IsNull() && !data.S3RoleARN.IsUnknown() {
+			storageConfig.S3RoleARN = data.S3RoleARN.ValueString()
+		}
+		if !data.S3RoleSessionName.IsNull() && !data.S3RoleSessionName.IsUnknown() {
+			storageConfig.S3RoleSessionName = data.S3RoleSessionName.ValueString()
+		}
+		if !data.S3ExternalID.IsNull() && !data.S3ExternalID.IsUnknown() {
+			storageConfig.S3ExternalID = data.S3ExternalID.ValueString()
+		}
+		if !data.S3SSE.IsNull() && !data.S3SSE.IsUnknown() {
+			storageConfig.S3SSE = data.S3SSE.ValueString()
+		}
+		if !data.S3KMSKeyID.IsNull() && !data.S3KMSKeyID.IsUnknown() {
+			storageConfig.S3KMSKeyID = data.S3KMSKeyID.ValueString()
+		}
 		if !data.S3EndpointURL.IsNull() && !data.S3EndpointURL.IsUnknown() {
 			storageConfig.S3EndpointURL = data.S3EndpointURL.ValueString()
 		}
@@ -179,8 +782,59 @@ func (p *IpamProvider) Configure(ctx context.Context, req provider.ConfigureRequ
 			storageConfig.S3SkipTLSVerify = data.S3SkipTLSVerify.ValueBool()
 		}
 
+		// credentials can also be read from files like mounted secrets. An explicit attribute
+		// wins over its file, and when neither is set the backend falls back to its environment.
+		secretFiles := []struct {
+			attribute string
+			value     types.String
+			file      types.String
+			target    *string
+		}{
+			{"azure_connection_string_file", data.AzureConnectionString, data.AzureConnectionStringFile, &storageConfig.AzureConnectionString},
+			{"s3_access_key_id_file", data.S3AccessKeyID, data.S3AccessKeyIDFile, &storageConfig.S3AccessKeyID},
+			{"s3_secret_access_key_file", data.S3SecretAccessKey, data.S3SecretAccessKeyFile, &storageConfig.S3SecretAccessKey},
+			{"s3_session_token_file", data.S3SessionToken, data.S3SessionTokenFile, &storageConfig.S3SessionToken},
+		}
+		for _, secret := range secretFiles {
+			if !secret.value.IsNull() || secret.file.IsNull() || secret.file.IsUnknown() {
+				continue
+			}
+			value, err := readSecretFile(secret.file.ValueString())
+			if err != nil {
+				resp.Diagnostics.AddAttributeError(
+					path.Root(secret.attribute),
+					"Failed to Read Credentials File",
+					fmt.Sprintf("Could not read %s: %s", secret.attribute, err),
+				)
+				return
+			}
+			*secret.target = value
+		}
+
+		storageConfig.ResolveKeys()
+		tflog.Debug(ctx, "Resolved storage keys", map[string]any{
+			"file_path":        storageConfig.FilePath,
+			"sqlite_path":      storageConfig.SQLitePath,
+			"azure_blob_name":  storageConfig.AzureBlobName,
+			"s3_object_key":    storageConfig.S3ObjectKey,
+			"audit_object_key": storageConfig.AuditObjectKey,
+		})
+		for _, fallback := range storageConfig.Fallbacks {
+			tflog.Debug(ctx, "Resolved fallback storage keys", map[string]any{
+				"file_path": fallback.FilePath,
+			})
+		}
+
 		var err error
+		p.storageType = storageConfig.Type
 		p.storage, err = storage.Factory(ctx, storageConfig)
+		if errors.Is(err, storage.ErrAccessDenied) {
+			resp.Diagnostics.AddError(
+				"Storage Access Denied",
+				fmt.Sprintf("The configured credentials can't access the %s storage backend: %s", storageConfig.Type, err),
+			)
+			return
+		}
 		if err != nil {
 			resp.Diagnostics.AddError(
 				"Storage Initialization Failed",
@@ -189,14 +843,53 @@ func (p *IpamProvider) Configure(ctx context.Context, req provider.ConfigureRequ
 			return
 		}
 
+		// fail fast on a backend that can't be reached, instead of on the first resource operation.
+		// The storage is dropped so the next Configure sets it up again.
+		if err := p.storage.Ping(ctx); err != nil {
+			p.storage.Close()
+			p.storage = nil
+
+			if errors.Is(err, storage.ErrAccessDenied) {
+				resp.Diagnostics.AddError(
+					"Storage Access Denied",
+					fmt.Sprintf("The configured credentials can't access the %s storage backend: %s", storageConfig.Type, err),
+				)
+				return
+			}
+			resp.Diagnostics.AddError(
+				"Storage Unreachable",
+				fmt.Sprintf("The %s storage backend didn't respond to a health check: %s", storageConfig.Type, err),
+			)
+			return
+		}
+
 		tflog.Debug(ctx, "Storage backend initialized", map[string]any{
 			"type": storageConfig.Type,
 		})
 	}
 
+	// the server reads storage, so it's only started once that's set up
+	if addr := data.MetricsAddr.ValueString(); addr != "" && p.metrics == nil {
+		metrics, err := startMetricsServer(ctx, addr, p)
+		if err != nil {
+			resp.Diagnostics.AddAttributeError(
+				path.Root("metrics_addr"),
+				"Metrics Server Failed",
+				fmt.Sprintf("Unable to serve metrics on '%s': %s", addr, err),
+			)
+			return
+		}
+		p.metrics = metrics
+
+		tflog.Info(ctx, "serving metrics", map[string]any{
+			"addr": metrics.addr,
+		})
+	}
+
 	// Pass provider instance to resources so they can access storage
 	resp.ResourceData = p
 	resp.DataSourceData = p
+	resp.ActionData = p
 
 	tflog.Debug(ctx, "Provider configured successfully", map[string]any{
 		"provider_ptr": fmt.Sprintf("%p", p),
@@ -207,6 +900,10 @@ func (p *IpamProvider) Resources(ctx context.Context) []func() resource.Resource
 	return []func() resource.Resource{
 		NewPoolResource,
 		NewAllocationResource,
+		NewAllocationBlocksResource,
+		NewRegistryResource,
+		NewBulkImportResource,
+		NewReservationResource,
 	}
 }
 
@@ -218,15 +915,342 @@ func (p *IpamProvider) DataSources(ctx context.Context) []func() datasource.Data
 	return []func() datasource.DataSource{
 		NewPoolDataSource,
 		NewAllocationDataSource,
+		NewPoolsDataSource,
+		NewPoolStatsDataSource,
+		NewNextCIDRsDataSource,
+		NewExpiredAllocationsDataSource,
+		NewLargestFreeCIDRDataSource,
+		NewPoolAllocationsDataSource,
+		NewExportDataSource,
+		NewDeletedAllocationsDataSource,
+		NewLookupDataSource,
 	}
 }
 
 func (p *IpamProvider) Functions(ctx context.Context) []func() function.Function {
-	return []func() function.Function{}
+	return []func() function.Function{
+		NewAggregateCIDRsFunction,
+		NewCIDRSubtractFunction,
+		NewReverseDNSZonesFunction,
+	}
 }
 
 func (p *IpamProvider) Actions(ctx context.Context) []func() action.Action {
-	return []func() action.Action{}
+	return []func() action.Action{
+		NewFsckAction,
+		NewPurgeDeletedAllocationsAction,
+		NewStorageSelftestAction,
+	}
+}
+
+// lockPool waits until no other claim of this process is in progress in the pool, and returns the
+// function releasing the pool again. Writers in other processes are caught by the backends, which
+// reject a write based on data another writer changed with storage.ErrConflict.
+func (p *IpamProvider) lockPool(poolName string) func() {
+	p.poolLocksMu.Lock()
+	if p.poolLocks == nil {
+		p.poolLocks = make(map[string]*sync.Mutex)
+	}
+	lock, ok := p.poolLocks[poolName]
+	if !ok {
+		lock = &sync.Mutex{}
+		p.poolLocks[poolName] = lock
+	}
+	p.poolLocksMu.Unlock()
+
+	lock.Lock()
+	return lock.Unlock
+}
+
+// retryOnConflict runs fn until it returns something other than storage.ErrConflict or the claim
+// timeout runs out. Backends reload the latest data before returning ErrConflict, so fn is
+// expected to re-check its preconditions against storage on every attempt.
+func (p *IpamProvider) retryOnConflict(ctx context.Context, operation string, fields map[string]any, fn func() error) error {
+	deadline := time.Now().Add(p.claimTimeout)
+	for attempt := 1; ; attempt++ {
+		err := fn()
+		if !errors.Is(err, storage.ErrConflict) {
+			return err
+		}
+
+		if time.Now().After(deadline) {
+			return fmt.Errorf("could not %s within %s: %w", operation, p.claimTimeout, err)
+		}
+
+		logFields := map[string]any{
+			"operation": operation,
+			"attempt":   attempt,
+		}
+		for k, v := range fields {
+			logFields[k] = v
+		}
+		tflog.Debug(ctx, "storage write conflicted with another writer, retrying", logFields)
+
+		backoff := time.Duration(attempt) * 100 * time.Millisecond
+		if backoff > 2*time.Second {
+			backoff = 2 * time.Second
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+	}
+}
+
+// storageMaxRetries converts a configured retry count to storage.Config's, where 0 keeps the SDK's default
+// and a negative number disables retries.
+func storageMaxRetries(configured int64) (int, error) {
+	if configured < 0 {
+		return 0, fmt.Errorf("must be 0 or more, got %d", configured)
+	}
+	if configured == 0 {
+		return -1, nil
+	}
+	return int(configured), nil
+}
+
+// addStorageWriteError reports a failed storage write. A read-only backend gets its own error, since the
+// apply can't succeed until it accepts writes again while reads, and so plans and data sources, keep working.
+// So does a write that failed because the pool or allocation it needs is gone.
+func addStorageWriteError(diags *diag.Diagnostics, summary, detail string, err error) {
+	switch {
+	case errors.Is(err, storage.ErrPoolNotFound):
+		diags.AddError(
+			"Pool Not Found",
+			fmt.Sprintf("%s\n\nThe pool doesn't exist in storage. It may not have been created yet, or was deleted outside Terraform.", detail),
+		)
+	case errors.Is(err, storage.ErrAllocationNotFound):
+		diags.AddError(
+			"Allocation Not Found",
+			fmt.Sprintf("%s\n\nThe allocation doesn't exist in storage. It may have been released outside Terraform.", detail),
+		)
+	case errors.Is(err, storage.ErrLocked):
+		diags.AddError(
+			"Storage Locked",
+			fmt.Sprintf("%s\n\nAnother process held the lock on the storage for longer than lock_max_wait, e.g. a parallel apply "+
+				"sharing the same storage file or SQLite database. Run the apply again, or raise lock_max_wait to let parallel runs wait for each other.", detail),
+		)
+	case errors.Is(err, storage.ErrReadOnly):
+		diags.AddError(
+			"Storage Is Read-Only",
+			fmt.Sprintf("%s\n\nThe storage backend is rejecting writes, e.g. a bucket policy or role that only allows reads, "+
+				"an immutability policy, or a read-only database replica. Reads still work, so plans, refreshes and data sources "+
+				"keep working. Run the apply again once the backend accepts writes.", detail),
+		)
+	default:
+		diags.AddError(summary, detail)
+	}
+}
+
+// softDeleter returns the storage's soft delete operations, or adds an error when soft_delete_retention
+// isn't set and released allocations are deleted right away.
+func (p *IpamProvider) softDeleter(diags *diag.Diagnostics) storage.SoftDeleter {
+	softDeleter, ok := p.storage.(storage.SoftDeleter)
+	if !ok {
+		diags.AddError(
+			"Soft Delete Not Enabled",
+			"Released allocations are deleted right away, so there are no deleted allocations to look up or purge. "+
+				"Set soft_delete_retention in the provider configuration to keep them.",
+		)
+		return nil
+	}
+	return softDeleter
+}
+
+// checkGloballyReserved returns an error when the CIDR overlaps one of the globally reserved CIDRs.
+func (p *IpamProvider) checkGloballyReserved(cidrNet *net.IPNet) error {
+	for _, reserved := range p.globallyReservedCIDRs {
+		if cidrsOverlap(cidrNet, []*net.IPNet{reserved}) {
+			return fmt.Errorf("CIDR %s overlaps globally reserved CIDR %s", cidrNet, reserved)
+		}
+	}
+	return nil
+}
+
+// newAllocationKey returns the storage key of a new allocation. Keys are never shown as the allocation's id,
+// so it can be renamed or share its id with an allocation of another pool.
+func newAllocationKey() (string, error) {
+	key, err := uuid.GenerateUUID()
+	if err != nil {
+		return "", fmt.Errorf("unable to generate an allocation key: %w", err)
+	}
+	return key, nil
+}
+
+// allocationKey returns the storage key of an allocation in state. State written before allocations had
+// keys only has the id, which is the key the allocation was migrated to.
+func allocationKey(key types.String, id types.String) string {
+	if key.IsNull() || key.IsUnknown() || key.ValueString() == "" {
+		return id.ValueString()
+	}
+	return key.ValueString()
+}
+
+// allocationWithID returns the allocation with the id, or nil when there's none. Ids are unique within
+// a pool, so the allocations should be of a single pool.
+func allocationWithID(allocations []storage.Allocation, id string) *storage.Allocation {
+	for i := range allocations {
+		if allocations[i].ID == id {
+			return &allocations[i]
+		}
+	}
+	return nil
+}
+
+// findAllocationByID returns the pool's allocation with the id, or storage.ErrAllocationNotFound.
+func (p *IpamProvider) findAllocationByID(ctx context.Context, poolName string, id string) (*storage.Allocation, error) {
+	allocations, err := p.storage.ListAllocationsByPool(ctx, poolName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list allocations: %w", err)
+	}
+	if allocation := allocationWithID(allocations, id); allocation != nil {
+		return allocation, nil
+	}
+	return nil, fmt.Errorf("allocation %s of pool %s: %w", id, poolName, storage.ErrAllocationNotFound)
+}
+
+// findAllocation returns the allocation stored under the key. An allocation id is accepted too as long as
+// a single pool has an allocation with it, so ids keep working where a key is expected, e.g. in import IDs.
+func (p *IpamProvider) findAllocation(ctx context.Context, keyOrID string) (*storage.Allocation, error) {
+	allocation, err := p.storage.GetAllocation(ctx, keyOrID)
+	if !errors.Is(err, storage.ErrNotFound) {
+		return allocation, err
+	}
+
+	allocations, err := p.storage.ListAllocations(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list allocations: %w", err)
+	}
+	var matches []storage.Allocation
+	for _, candidate := range allocations {
+		if candidate.ID == keyOrID {
+			matches = append(matches, candidate)
+		}
+	}
+	switch len(matches) {
+	case 0:
+		return nil, storage.ErrAllocationNotFound
+	case 1:
+		return &matches[0], nil
+	}
+	pools := make([]string, 0, len(matches))
+	for _, match := range matches {
+		pools = append(pools, match.PoolName)
+	}
+	sort.Strings(pools)
+	return nil, fmt.Errorf("id %s is used by allocations of pools %s, use the key of the allocation instead", keyOrID, strings.Join(pools, ", "))
+}
+
+// importAllocation sets the id and key of a resource managing a single allocation from its import ID,
+// the allocation's key or its id, so Read finds the allocation.
+func (p *IpamProvider) importAllocation(ctx context.Context, req resource.ImportStateRequest, resp *resource.ImportStateResponse) {
+	allocation, err := p.findAllocation(ctx, req.ID)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			resp.Diagnostics.AddError(
+				"Allocation Not Found",
+				fmt.Sprintf("Allocation %s not found in storage. Import ID must be an allocation key or ID", req.ID),
+			)
+			return
+		}
+		resp.Diagnostics.AddError(
+			"Failed to Import Allocation",
+			fmt.Sprintf("Could not read allocation %s from storage: %s", req.ID, err),
+		)
+		return
+	}
+
+	resp.Diagnostics.Append(resp.State.SetAttribute(ctx, path.Root("id"), allocation.ID)...)
+	resp.Diagnostics.Append(resp.State.SetAttribute(ctx, path.Root("key"), allocation.StorageKey())...)
+}
+
+// expandAllocationIDTemplate returns the allocation id the template resolves to for an allocation of the
+// CIDR in the pool.
+func expandAllocationIDTemplate(template string, poolName string, cidr string, index int) string {
+	return allocationIDPlaceholder.ReplaceAllStringFunc(template, func(placeholder string) string {
+		switch placeholder {
+		case "${pool}":
+			return poolName
+		case "${index}":
+			return strconv.Itoa(index)
+		case "${cidr}":
+			return strings.ReplaceAll(cidr, "/", "-")
+		}
+		return placeholder
+	})
+}
+
+// generateAllocationID returns an id that none of the pool's allocations has for an allocation of the CIDR
+// from the allocation id template. The index starts after the pool's existing allocations and counts up
+// while the id is taken, a template without an index gets a numbered suffix instead.
+func (p *IpamProvider) generateAllocationID(poolName string, cidr string, poolAllocations []storage.Allocation) string {
+	index := len(poolAllocations) + 1
+	id := expandAllocationIDTemplate(p.allocationIDTemplate, poolName, cidr, index)
+	base := id
+	for suffix := 2; ; suffix++ {
+		if allocationWithID(poolAllocations, id) == nil {
+			return id
+		}
+
+		if strings.Contains(p.allocationIDTemplate, "${index}") {
+			index++
+			id = expandAllocationIDTemplate(p.allocationIDTemplate, poolName, cidr, index)
+		} else {
+			id = fmt.Sprintf("%s-%d", base, suffix)
+		}
+	}
+}
+
+// releaseCIDR remembers the block of a deleted allocation for the rest of the run.
+func (p *IpamProvider) releaseCIDR(id string, poolName string, cidr string) {
+	p.releasedMu.Lock()
+	defer p.releasedMu.Unlock()
+
+	if p.releasedCIDRs == nil {
+		p.releasedCIDRs = make(map[string]releasedCIDR)
+	}
+	p.releasedCIDRs[id] = releasedCIDR{poolName: poolName, cidr: cidr}
+}
+
+// takeReleasedCIDR returns the block the allocation held in the pool before it was deleted during
+// this run, or "" when there is none. The block is only handed out once.
+func (p *IpamProvider) takeReleasedCIDR(id string, poolName string) string {
+	p.releasedMu.Lock()
+	defer p.releasedMu.Unlock()
+
+	released, ok := p.releasedCIDRs[id]
+	if !ok || released.poolName != poolName {
+		return ""
+	}
+	delete(p.releasedCIDRs, id)
+	return released.cidr
+}
+
+// readSecretFile returns the contents of a credentials file without trailing newlines.
+func readSecretFile(filePath string) (string, error) {
+	contents, err := os.ReadFile(filePath)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(string(contents), "\r\n"), nil
+}
+
+// Close stops the metrics server and closes the storage backend, flushing anything it still holds in
+// memory. main calls it once Terraform shuts the provider down, and calling it again returns nil.
+func (p *IpamProvider) Close() error {
+	var metricsErr error
+	if p.metrics != nil {
+		metricsErr = p.metrics.Close()
+		p.metrics = nil
+	}
+
+	if p.storage == nil {
+		return metricsErr
+	}
+	err := p.storage.Close()
+	p.storage = nil
+	return errors.Join(metricsErr, err)
 }
 
 func New(version string) func() provider.Provider {
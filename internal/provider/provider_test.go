@@ -0,0 +1,95 @@
+package provider
+
+import (
+	"fmt"
+	"regexp"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-testing/helper/resource"
+)
+
+func TestAccProvider_ValidateConfig(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		PreCheck:                 func() { testAccPreCheck(t) },
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config:      testAccProviderConfig(`storage_type = "etcd"`),
+				PlanOnly:    true,
+				ExpectError: regexp.MustCompile("Invalid Storage Type"),
+			},
+			{
+				Config:      testAccProviderConfig(`storage_type = "azure_blob"` + "\n" + `azure_container_name = "ipam"`),
+				PlanOnly:    true,
+				ExpectError: regexp.MustCompile(`requires azure_connection_string,\s+azure_connection_string_file or azure_account_url to be set`),
+			},
+			{
+				Config:      testAccProviderConfig(`storage_type = "azure_blob"` + "\n" + `azure_connection_string_file = "/run/secrets/azure"`),
+				PlanOnly:    true,
+				ExpectError: regexp.MustCompile(`requires azure_container_name to be set`),
+			},
+			{
+				Config:      testAccProviderConfig(`storage_type = "aws_s3"` + "\n" + `s3_bucket_name = "ipam"`),
+				PlanOnly:    true,
+				ExpectError: regexp.MustCompile(`requires s3_region to be set`),
+			},
+			{
+				Config:      testAccProviderConfig(`storage_type = "aws_s3"` + "\n" + `s3_region = "us-east-1"`),
+				PlanOnly:    true,
+				ExpectError: regexp.MustCompile(`requires s3_bucket_name to be set`),
+			},
+			{
+				Config: testAccProviderConfig(`storage_type = "aws_s3"` + "\n" + `s3_region = "us-east-1"` + "\n" +
+					`s3_bucket_name = "ipam"` + "\n" + `s3_access_key_id = "AKIAEXAMPLE"`),
+				PlanOnly:    true,
+				ExpectError: regexp.MustCompile(`s3_secret_access_key or s3_secret_access_key_file must be set`),
+			},
+			{
+				Config: testAccProviderConfig(`storage_type = "aws_s3"` + "\n" + `s3_region = "us-east-1"` + "\n" +
+					`s3_bucket_name = "ipam"` + "\n" + `s3_external_id = "ext"`),
+				PlanOnly:    true,
+				ExpectError: regexp.MustCompile(`s3_role_arn must be set when s3_external_id is set`),
+			},
+			{
+				Config: testAccProviderConfig(`storage_type = "aws_s3"` + "\n" + `s3_region = "us-east-1"` + "\n" +
+					`s3_bucket_name = "ipam"` + "\n" + `s3_kms_key_id = "alias/ipam"`),
+				PlanOnly:    true,
+				ExpectError: regexp.MustCompile(`s3_sse must be 'aws:kms' or 'aws:kms:dsse' when s3_kms_key_id is set`),
+			},
+			{
+				Config:      testAccProviderConfig(`storage_type = "state"` + "\n" + `fallback_file_path = "mirror.json"`),
+				PlanOnly:    true,
+				ExpectError: regexp.MustCompile(`fallback_file_path can't be used with storage_type 'state'`),
+			},
+			{
+				Config:      testAccProviderConfig(`allocation_id_template = "alloc-$${name}"`),
+				PlanOnly:    true,
+				ExpectError: regexp.MustCompile(`unknown placeholder '\$\{name\}'`),
+			},
+			{
+				Config:      testAccProviderConfig(`metrics_addr = "not-an-address"`),
+				PlanOnly:    true,
+				ExpectError: regexp.MustCompile("Metrics Server Failed"),
+			},
+			{
+				Config:      testAccProviderConfig(`allocation_webhook_url = "ftp://hooks.example.com/ipam"`),
+				PlanOnly:    true,
+				ExpectError: regexp.MustCompile("Invalid Allocation Webhook URL"),
+			},
+		},
+	})
+}
+
+// testAccProviderConfig generates config with the given provider attributes and a pool to plan.
+func testAccProviderConfig(attributes string) string {
+	return fmt.Sprintf(`
+provider "tfipam" {
+%s
+}
+
+resource "tfipam_pool" "test" {
+  name  = "provider-validate-pool"
+  cidrs = ["10.0.0.0/24"]
+}
+`, attributes)
+}
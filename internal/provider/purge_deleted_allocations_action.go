@@ -0,0 +1,127 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-framework/action"
+	"github.com/hashicorp/terraform-plugin-framework/action/schema"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+
+	"terraform-provider-tfipam/internal/provider/storage"
+)
+
+var _ action.Action = &PurgeDeletedAllocationsAction{}
+var _ action.ActionWithConfigure = &PurgeDeletedAllocationsAction{}
+
+func NewPurgeDeletedAllocationsAction() action.Action {
+	return &PurgeDeletedAllocationsAction{}
+}
+
+// PurgeDeletedAllocationsAction deletes the soft deleted allocations that were released longer than the
+// provider's soft_delete_retention ago.
+type PurgeDeletedAllocationsAction struct {
+	provider *IpamProvider
+}
+
+type PurgeDeletedAllocationsActionModel struct {
+	PoolName types.String `tfsdk:"pool_name"`
+}
+
+func (a *PurgeDeletedAllocationsAction) Metadata(ctx context.Context, req action.MetadataRequest, resp *action.MetadataResponse) {
+	resp.TypeName = req.ProviderTypeName + "_purge_deleted_allocations"
+}
+
+func (a *PurgeDeletedAllocationsAction) Schema(ctx context.Context, req action.SchemaRequest, resp *action.SchemaResponse) {
+	resp.Schema = schema.Schema{
+		MarkdownDescription: "IPAM purge action that deletes the released allocations kept by the provider's `soft_delete_retention` once they're older than the retention",
+
+		Attributes: map[string]schema.Attribute{
+			"pool_name": schema.StringAttribute{
+				Optional:            true,
+				MarkdownDescription: "Only purge the deleted allocations of this pool. Every pool's are purged when not set",
+			},
+		},
+	}
+}
+
+func (a *PurgeDeletedAllocationsAction) Configure(ctx context.Context, req action.ConfigureRequest, resp *action.ConfigureResponse) {
+	if req.ProviderData == nil {
+		return
+	}
+
+	provider, ok := req.ProviderData.(*IpamProvider)
+	if !ok {
+		resp.Diagnostics.AddError(
+			"Unexpected Action Configure Type",
+			fmt.Sprintf("Expected *IpamProvider, got: %T", req.ProviderData),
+		)
+		return
+	}
+
+	a.provider = provider
+}
+
+func (a *PurgeDeletedAllocationsAction) Invoke(ctx context.Context, req action.InvokeRequest, resp *action.InvokeResponse) {
+	var data PurgeDeletedAllocationsActionModel
+
+	resp.Diagnostics.Append(req.Config.Get(ctx, &data)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	softDeleter := a.provider.softDeleter(&resp.Diagnostics)
+	if softDeleter == nil {
+		return
+	}
+
+	allocations, err := softDeleter.ListDeletedAllocations(ctx)
+	if err != nil {
+		resp.Diagnostics.AddError(
+			"Failed to List Deleted Allocations",
+			fmt.Sprintf("Could not list deleted allocations from storage: %s", err),
+		)
+		return
+	}
+
+	now := time.Now()
+	var purged []string
+	for _, allocation := range allocations {
+		if poolName := data.PoolName.ValueString(); poolName != "" && allocation.PoolName != poolName {
+			continue
+		}
+		if now.Before(allocation.DeletedAt.Add(softDeleter.Retention())) {
+			continue
+		}
+
+		// another run may have purged it in the meantime
+		if err := softDeleter.PurgeAllocation(ctx, allocation.StorageKey()); err != nil && !errors.Is(err, storage.ErrNotFound) {
+			addStorageWriteError(
+				&resp.Diagnostics,
+				"Failed to Purge Allocation",
+				fmt.Sprintf("Could not purge deleted allocation %s of pool %s from storage: %s", allocation.ID, allocation.PoolName, err),
+				err,
+			)
+			break
+		}
+		purged = append(purged, fmt.Sprintf("%s (%s) in pool %s", allocation.ID, allocation.AllocatedCIDR, allocation.PoolName))
+
+		tflog.Info(ctx, "purged deleted allocation", map[string]any{
+			"id":         allocation.ID,
+			"key":        allocation.StorageKey(),
+			"pool_name":  allocation.PoolName,
+			"cidr":       strings.Join(allocation.CIDRs(), ","),
+			"deleted_at": allocation.DeletedAt.UTC().Format(time.RFC3339),
+		})
+	}
+
+	message := fmt.Sprintf("Purged %d deleted allocation(s) released more than %s ago", len(purged), softDeleter.Retention())
+	if len(purged) > 0 {
+		message += ":\n- " + strings.Join(purged, "\n- ")
+	}
+	resp.SendProgress(action.InvokeProgressEvent{Message: message})
+}
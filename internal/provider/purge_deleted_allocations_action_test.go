@@ -0,0 +1,100 @@
+package provider
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-framework/providerserver"
+	"github.com/hashicorp/terraform-plugin-go/tfprotov6"
+	"github.com/hashicorp/terraform-plugin-testing/helper/acctest"
+	"github.com/hashicorp/terraform-plugin-testing/helper/resource"
+	"github.com/hashicorp/terraform-plugin-testing/knownvalue"
+	"github.com/hashicorp/terraform-plugin-testing/statecheck"
+	"github.com/hashicorp/terraform-plugin-testing/tfjsonpath"
+	"github.com/hashicorp/terraform-plugin-testing/tfversion"
+)
+
+// testAccPurgeProviderFactories serve a provider instance of their own with a short soft delete retention,
+// see testAccSoftDeleteProviderFactories.
+var testAccPurgeProviderFactories = map[string]func() (tfprotov6.ProviderServer, error){
+	"tfipam": providerserver.NewProtocol6WithError(New("test")()),
+}
+
+func TestAccPurgeDeletedAllocationsAction(t *testing.T) {
+	poolName := acctest.RandomWithPrefix("purge-pool")
+
+	resource.Test(t, resource.TestCase{
+		TerraformVersionChecks: []tfversion.TerraformVersionCheck{
+			tfversion.SkipBelow(tfversion.Version1_14_0),
+		},
+		PreCheck:                 func() { testAccPreCheck(t) },
+		ProtoV6ProviderFactories: testAccPurgeProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: testAccPurgeDeletedAllocationsActionConfig(poolName, `
+resource "tfipam_allocation" "test" {
+  id            = "purge-allocation"
+  pool_name     = tfipam_pool.test.name
+  prefix_length = 26
+}
+`),
+			},
+			// releases the allocation
+			{
+				Config: testAccPurgeDeletedAllocationsActionConfig(poolName, ""),
+			},
+			// purges it once the retention ran out
+			{
+				PreConfig: func() { time.Sleep(2 * time.Second) },
+				Config: testAccPurgeDeletedAllocationsActionConfig(poolName, `
+resource "terraform_data" "trigger" {
+  input = tfipam_pool.test.name
+
+  lifecycle {
+    action_trigger {
+      events  = [after_create]
+      actions = [action.tfipam_purge_deleted_allocations.test]
+    }
+  }
+}
+`),
+			},
+			{
+				Config: testAccPurgeDeletedAllocationsActionConfig(poolName, ""),
+				ConfigStateChecks: []statecheck.StateCheck{
+					statecheck.ExpectKnownValue(
+						"data.tfipam_deleted_allocations.test",
+						tfjsonpath.New("allocations"),
+						knownvalue.ListSizeExact(0),
+					),
+				},
+			},
+		},
+	})
+}
+
+// testAccPurgeDeletedAllocationsActionConfig generates provider config with a one second soft delete
+// retention, a pool, the purge action and the pool's deleted allocations, followed by extra config.
+func testAccPurgeDeletedAllocationsActionConfig(poolName, extra string) string {
+	return fmt.Sprintf(`
+provider "tfipam" {
+  soft_delete_retention = "1s"
+}
+
+resource "tfipam_pool" "test" {
+  name  = %q
+  cidrs = ["10.4.0.0/24"]
+}
+
+action "tfipam_purge_deleted_allocations" "test" {
+  config {
+    pool_name = tfipam_pool.test.name
+  }
+}
+
+data "tfipam_deleted_allocations" "test" {
+  pool_name = tfipam_pool.test.name
+}
+`, poolName) + extra
+}
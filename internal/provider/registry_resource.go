@@ -0,0 +1,324 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sort"
+
+	"github.com/hashicorp/terraform-plugin-framework/attr"
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/path"
+	"github.com/hashicorp/terraform-plugin-framework/resource"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/planmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/stringplanmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+
+	"terraform-provider-tfipam/internal/provider/storage"
+)
+
+var _ resource.Resource = &RegistryResource{}
+var _ resource.ResourceWithModifyPlan = &RegistryResource{}
+
+func NewRegistryResource() resource.Resource {
+	return &RegistryResource{}
+}
+
+// RegistryResource keeps a whole IPAM dataset, pools and allocations, in its own Terraform state
+// instead of a storage backend. Every allocation is made from the previous state of the registry,
+// so the CIDRs are known at plan time and never depend on anything outside the resource.
+type RegistryResource struct{}
+
+type RegistryResourceModel struct {
+	Name           types.String `tfsdk:"name"`
+	Pools          types.Map    `tfsdk:"pools"`
+	Allocations    types.Map    `tfsdk:"allocations"`
+	AllocatedCIDRs types.Map    `tfsdk:"allocated_cidrs"`
+}
+
+type RegistryAllocationModel struct {
+	PoolName     types.String `tfsdk:"pool_name"`
+	PrefixLength types.Int64  `tfsdk:"prefix_length"`
+}
+
+// registryAllocation is the pool and block size requested by one allocation of a registry.
+type registryAllocation struct {
+	PoolName     string
+	PrefixLength int
+}
+
+func (r *RegistryResource) Metadata(ctx context.Context, req resource.MetadataRequest, resp *resource.MetadataResponse) {
+	resp.TypeName = req.ProviderTypeName + "_registry"
+}
+
+func (r *RegistryResource) Schema(ctx context.Context, req resource.SchemaRequest, resp *resource.SchemaResponse) {
+	resp.Schema = schema.Schema{
+		MarkdownDescription: "IPAM registry resource holding pools and allocations entirely in Terraform state, without a storage backend",
+
+		Attributes: map[string]schema.Attribute{
+			"name": schema.StringAttribute{
+				Required:            true,
+				MarkdownDescription: "Name of the registry",
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.RequiresReplace(),
+				},
+			},
+			"pools": schema.MapAttribute{
+				ElementType:         types.ListType{ElemType: types.StringType},
+				Required:            true,
+				MarkdownDescription: "CIDR blocks of each pool in the registry, keyed by pool name",
+			},
+			"allocations": schema.MapNestedAttribute{
+				Optional:            true,
+				MarkdownDescription: "Allocations to make from the registry's pools, keyed by allocation id",
+				NestedObject: schema.NestedAttributeObject{
+					Attributes: map[string]schema.Attribute{
+						"pool_name": schema.StringAttribute{
+							Required:            true,
+							MarkdownDescription: "Name of the pool in `pools` to allocate from",
+						},
+						"prefix_length": schema.Int64Attribute{
+							Required:            true,
+							MarkdownDescription: "Prefix length of the CIDR block to allocate",
+						},
+					},
+				},
+			},
+			"allocated_cidrs": schema.MapAttribute{
+				ElementType:         types.StringType,
+				Computed:            true,
+				MarkdownDescription: "Allocated CIDR block of each allocation, keyed by allocation id. An allocation keeps its CIDR as long as its pool and prefix length don't change and the CIDR is still in the pool",
+			},
+		},
+	}
+}
+
+func (r *RegistryResource) ModifyPlan(ctx context.Context, req resource.ModifyPlanRequest, resp *resource.ModifyPlanResponse) {
+	// nothing to compute on destroy
+	if req.Plan.Raw.IsNull() {
+		return
+	}
+
+	var data RegistryResourceModel
+	resp.Diagnostics.Append(req.Plan.Get(ctx, &data)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	// the allocations can only be worked out once every pool and allocation is known
+	for _, value := range []attr.Value{data.Pools, data.Allocations} {
+		tfValue, err := value.ToTerraformValue(ctx)
+		if err != nil || !tfValue.IsFullyKnown() {
+			return
+		}
+	}
+
+	var state *RegistryResourceModel
+	if !req.State.Raw.IsNull() {
+		state = &RegistryResourceModel{}
+		resp.Diagnostics.Append(req.State.Get(ctx, state)...)
+		if resp.Diagnostics.HasError() {
+			return
+		}
+	}
+
+	// show the allocated cidrs in the plan
+	resp.Diagnostics.Append(r.allocate(ctx, &data, state)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	resp.Diagnostics.Append(resp.Plan.SetAttribute(ctx, path.Root("allocated_cidrs"), data.AllocatedCIDRs)...)
+}
+
+func (r *RegistryResource) Create(ctx context.Context, req resource.CreateRequest, resp *resource.CreateResponse) {
+	var data RegistryResourceModel
+
+	resp.Diagnostics.Append(req.Plan.Get(ctx, &data)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	resp.Diagnostics.Append(r.allocate(ctx, &data, nil)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	tflog.Trace(ctx, "created registry resource", map[string]interface{}{
+		"name": data.Name.ValueString(),
+	})
+
+	resp.Diagnostics.Append(resp.State.Set(ctx, &data)...)
+}
+
+func (r *RegistryResource) Read(ctx context.Context, req resource.ReadRequest, resp *resource.ReadResponse) {
+	var data RegistryResourceModel
+
+	// state is the only copy of the registry, so there's nothing to refresh
+	resp.Diagnostics.Append(req.State.Get(ctx, &data)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	resp.Diagnostics.Append(resp.State.Set(ctx, &data)...)
+}
+
+func (r *RegistryResource) Update(ctx context.Context, req resource.UpdateRequest, resp *resource.UpdateResponse) {
+	var data, state RegistryResourceModel
+
+	resp.Diagnostics.Append(req.Plan.Get(ctx, &data)...)
+	resp.Diagnostics.Append(req.State.Get(ctx, &state)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	resp.Diagnostics.Append(r.allocate(ctx, &data, &state)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	tflog.Trace(ctx, "updated registry resource", map[string]interface{}{
+		"name": data.Name.ValueString(),
+	})
+
+	resp.Diagnostics.Append(resp.State.Set(ctx, &data)...)
+}
+
+func (r *RegistryResource) Delete(ctx context.Context, req resource.DeleteRequest, resp *resource.DeleteResponse) {
+	// the registry only exists in state, which terraform removes
+	tflog.Trace(ctx, "deleted registry resource")
+}
+
+// allocate sets data.AllocatedCIDRs from the configured pools and allocations, keeping the CIDRs
+// that state already holds for unchanged allocations. state is nil when the registry is created.
+func (r *RegistryResource) allocate(ctx context.Context, data *RegistryResourceModel, state *RegistryResourceModel) diag.Diagnostics {
+	var diags diag.Diagnostics
+
+	pools := map[string][]string{}
+	diags.Append(data.Pools.ElementsAs(ctx, &pools, false)...)
+	if diags.HasError() {
+		return diags
+	}
+
+	allocations, d := registryAllocations(ctx, data.Allocations)
+	diags.Append(d...)
+	if diags.HasError() {
+		return diags
+	}
+
+	previousCIDRs := map[string]string{}
+	previousAllocations := map[string]registryAllocation{}
+	if state != nil {
+		if !state.AllocatedCIDRs.IsNull() && !state.AllocatedCIDRs.IsUnknown() {
+			diags.Append(state.AllocatedCIDRs.ElementsAs(ctx, &previousCIDRs, false)...)
+		}
+		previousAllocations, d = registryAllocations(ctx, state.Allocations)
+		diags.Append(d...)
+		if diags.HasError() {
+			return diags
+		}
+	}
+
+	cidrs, err := allocateRegistry(pools, allocations, previousCIDRs, previousAllocations)
+	if err != nil {
+		diags.AddAttributeError(
+			path.Root("allocations"),
+			"Registry Allocation Failed",
+			fmt.Sprintf("Could not allocate CIDRs in registry %s: %s", data.Name.ValueString(), err),
+		)
+		return diags
+	}
+
+	allocatedCIDRs, d := types.MapValueFrom(ctx, types.StringType, cidrs)
+	diags.Append(d...)
+	data.AllocatedCIDRs = allocatedCIDRs
+	return diags
+}
+
+// registryAllocations converts the allocations attribute, which may be null, to a map by allocation id.
+func registryAllocations(ctx context.Context, value types.Map) (map[string]registryAllocation, diag.Diagnostics) {
+	var diags diag.Diagnostics
+
+	allocations := map[string]registryAllocation{}
+	if value.IsNull() || value.IsUnknown() {
+		return allocations, diags
+	}
+
+	var models map[string]RegistryAllocationModel
+	diags.Append(value.ElementsAs(ctx, &models, false)...)
+	if diags.HasError() {
+		return nil, diags
+	}
+
+	for id, model := range models {
+		allocations[id] = registryAllocation{
+			PoolName:     model.PoolName.ValueString(),
+			PrefixLength: int(model.PrefixLength.ValueInt64()),
+		}
+	}
+	return allocations, diags
+}
+
+// allocateRegistry returns the CIDR of every allocation by id. Allocations whose pool and prefix length
+// are unchanged keep their previous CIDR while it's still inside the pool, the others get the next free
+// block of their pool, handed out in order of allocation id so the result is the same on every run.
+func allocateRegistry(pools map[string][]string, allocations map[string]registryAllocation, previousCIDRs map[string]string, previousAllocations map[string]registryAllocation) (map[string]string, error) {
+	for name, cidrs := range pools {
+		for _, cidr := range cidrs {
+			if _, _, err := net.ParseCIDR(cidr); err != nil {
+				return nil, fmt.Errorf("CIDR '%s' of pool %s is not valid: %w", cidr, name, err)
+			}
+		}
+	}
+
+	ids := make([]string, 0, len(allocations))
+	for id, allocation := range allocations {
+		cidrs, ok := pools[allocation.PoolName]
+		if !ok {
+			return nil, fmt.Errorf("allocation %s uses pool %s, which isn't one of the registry's pools", id, allocation.PoolName)
+		}
+		if err := validatePoolPrefixLength(&storage.Pool{Name: allocation.PoolName, CIDRs: cidrs}, allocation.PrefixLength); err != nil {
+			return nil, fmt.Errorf("allocation %s: %w", id, err)
+		}
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	result := make(map[string]string, len(allocations))
+	taken := map[string][]*net.IPNet{}
+
+	// keep what's already allocated first, so new allocations can't take those blocks
+	var pending []string
+	for _, id := range ids {
+		allocation := allocations[id]
+		previousCIDR, ok := previousCIDRs[id]
+		if !ok || previousAllocations[id] != allocation {
+			pending = append(pending, id)
+			continue
+		}
+
+		_, previousNet, err := net.ParseCIDR(previousCIDR)
+		pool := &storage.Pool{Name: allocation.PoolName, CIDRs: pools[allocation.PoolName]}
+		if err != nil || !cidrInPool(pool, previousNet) || cidrsOverlap(previousNet, taken[allocation.PoolName]) {
+			pending = append(pending, id)
+			continue
+		}
+
+		result[id] = previousNet.String()
+		taken[allocation.PoolName] = append(taken[allocation.PoolName], previousNet)
+	}
+
+	for _, id := range pending {
+		allocation := allocations[id]
+		next := findNextCIDR(pools[allocation.PoolName], allocation.PrefixLength, 0, taken[allocation.PoolName])
+		if next == nil {
+			return nil, fmt.Errorf("no available CIDR blocks of size /%d in pool %s for allocation %s", allocation.PrefixLength, allocation.PoolName, id)
+		}
+
+		result[id] = next.String()
+		taken[allocation.PoolName] = append(taken[allocation.PoolName], next)
+	}
+
+	return result, nil
+}
@@ -0,0 +1,129 @@
+package provider
+
+import (
+	"fmt"
+	"regexp"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-testing/helper/resource"
+	"github.com/hashicorp/terraform-plugin-testing/knownvalue"
+	"github.com/hashicorp/terraform-plugin-testing/plancheck"
+	"github.com/hashicorp/terraform-plugin-testing/statecheck"
+	"github.com/hashicorp/terraform-plugin-testing/tfjsonpath"
+)
+
+func TestAccRegistryResource_Basic(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		PreCheck:                 func() { testAccPreCheck(t) },
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: testAccRegistryResourceConfig(`
+    web = { pool_name = "main", prefix_length = 26 }
+    db  = { pool_name = "main", prefix_length = 25 }
+`),
+				ConfigStateChecks: []statecheck.StateCheck{
+					statecheck.ExpectKnownValue(
+						"tfipam_registry.test",
+						tfjsonpath.New("allocated_cidrs"),
+						knownvalue.MapExact(map[string]knownvalue.Check{
+							"db":  knownvalue.StringExact("10.0.0.0/25"),
+							"web": knownvalue.StringExact("10.0.0.128/26"),
+						}),
+					),
+				},
+			},
+			// a new allocation takes the next free block, the existing ones keep theirs
+			{
+				Config: testAccRegistryResourceConfig(`
+    web   = { pool_name = "main", prefix_length = 26 }
+    db    = { pool_name = "main", prefix_length = 25 }
+    cache = { pool_name = "main", prefix_length = 27 }
+`),
+				ConfigPlanChecks: resource.ConfigPlanChecks{
+					PreApply: []plancheck.PlanCheck{
+						plancheck.ExpectResourceAction("tfipam_registry.test", plancheck.ResourceActionUpdate),
+						plancheck.ExpectKnownValue(
+							"tfipam_registry.test",
+							tfjsonpath.New("allocated_cidrs").AtMapKey("cache"),
+							knownvalue.StringExact("10.0.0.192/27"),
+						),
+					},
+				},
+				ConfigStateChecks: []statecheck.StateCheck{
+					statecheck.ExpectKnownValue(
+						"tfipam_registry.test",
+						tfjsonpath.New("allocated_cidrs"),
+						knownvalue.MapExact(map[string]knownvalue.Check{
+							"cache": knownvalue.StringExact("10.0.0.192/27"),
+							"db":    knownvalue.StringExact("10.0.0.0/25"),
+							"web":   knownvalue.StringExact("10.0.0.128/26"),
+						}),
+					),
+				},
+			},
+			// releasing the /25 doesn't move the others
+			{
+				Config: testAccRegistryResourceConfig(`
+    web   = { pool_name = "main", prefix_length = 26 }
+    cache = { pool_name = "main", prefix_length = 27 }
+`),
+				ConfigStateChecks: []statecheck.StateCheck{
+					statecheck.ExpectKnownValue(
+						"tfipam_registry.test",
+						tfjsonpath.New("allocated_cidrs"),
+						knownvalue.MapExact(map[string]knownvalue.Check{
+							"cache": knownvalue.StringExact("10.0.0.192/27"),
+							"web":   knownvalue.StringExact("10.0.0.128/26"),
+						}),
+					),
+				},
+			},
+		},
+	})
+}
+
+func TestAccRegistryResource_UnknownPool(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		PreCheck:                 func() { testAccPreCheck(t) },
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: testAccRegistryResourceConfig(`
+    web = { pool_name = "missing", prefix_length = 26 }
+`),
+				ExpectError: regexp.MustCompile("isn't one of the registry's pools"),
+			},
+		},
+	})
+}
+
+func TestAccRegistryResource_Exhausted(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		PreCheck:                 func() { testAccPreCheck(t) },
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: testAccRegistryResourceConfig(`
+    first  = { pool_name = "main", prefix_length = 24 }
+    second = { pool_name = "main", prefix_length = 26 }
+`),
+				ExpectError: regexp.MustCompile("no available CIDR blocks of size /26 in pool main"),
+			},
+		},
+	})
+}
+
+// testAccRegistryResourceConfig generates a registry with a single /24 pool named main and the given allocations.
+func testAccRegistryResourceConfig(allocations string) string {
+	return fmt.Sprintf(`
+resource "tfipam_registry" "test" {
+  name = "test-registry"
+  pools = {
+    main = ["10.0.0.0/24"]
+  }
+  allocations = {
+%[1]s  }
+}
+`, allocations)
+}
@@ -0,0 +1,351 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/hashicorp/go-uuid"
+	"github.com/hashicorp/terraform-plugin-framework/path"
+	"github.com/hashicorp/terraform-plugin-framework/resource"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/int64planmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/planmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/stringplanmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+
+	"terraform-provider-tfipam/internal/provider/storage"
+)
+
+var _ resource.Resource = &ReservationResource{}
+var _ resource.ResourceWithImportState = &ReservationResource{}
+
+func NewReservationResource() resource.Resource {
+	return &ReservationResource{}
+}
+
+// ReservationResource claims a block of a pool up front and creates a pool of just that block, so the
+// allocations made from it are contiguous however other allocations of the pool interleave.
+type ReservationResource struct {
+	provider *IpamProvider
+}
+
+type ReservationResourceModel struct {
+	ID           types.String `tfsdk:"id"`
+	Key          types.String `tfsdk:"key"`
+	Name         types.String `tfsdk:"name"`
+	PoolName     types.String `tfsdk:"pool_name"`
+	PrefixLength types.Int64  `tfsdk:"prefix_length"`
+	ReservedCIDR types.String `tfsdk:"reserved_cidr"`
+}
+
+func (r *ReservationResource) Metadata(ctx context.Context, req resource.MetadataRequest, resp *resource.MetadataResponse) {
+	resp.TypeName = req.ProviderTypeName + "_reservation"
+}
+
+func (r *ReservationResource) Schema(ctx context.Context, req resource.SchemaRequest, resp *resource.SchemaResponse) {
+	resp.Schema = schema.Schema{
+		MarkdownDescription: "IPAM reservation resource for claiming a block of a pool up front and allocating contiguous CIDRs from it",
+
+		Attributes: map[string]schema.Attribute{
+			"id": schema.StringAttribute{
+				Optional:            true,
+				Computed:            true,
+				MarkdownDescription: "Identifier of the allocation holding the reserved block, unique within `pool_name`. A UUID is generated when not provided. Changing it replaces the reservation",
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.UseStateForUnknown(),
+					stringplanmodifier.RequiresReplace(),
+				},
+			},
+			"key": schema.StringAttribute{
+				Computed:            true,
+				MarkdownDescription: "Internal key the allocation holding the reserved block is stored under, a UUID generated when it's created",
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.UseStateForUnknown(),
+				},
+			},
+			"name": schema.StringAttribute{
+				Required:            true,
+				MarkdownDescription: "Name of the pool the reservation creates with the reserved block as its only CIDR. Allocations with this `pool_name` are carved from the reserved block. Must not be the name of another pool. Changing it replaces the reservation",
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.RequiresReplace(),
+				},
+			},
+			"pool_name": schema.StringAttribute{
+				Required:            true,
+				MarkdownDescription: "Name of the pool to reserve the block in. Changing it replaces the reservation",
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.RequiresReplace(),
+				},
+			},
+			"prefix_length": schema.Int64Attribute{
+				Required:            true,
+				MarkdownDescription: "Prefix length of the reserved block, e.g. 22 for room for four /24s. Changing it replaces the reservation",
+				PlanModifiers: []planmodifier.Int64{
+					int64planmodifier.RequiresReplace(),
+				},
+			},
+			"reserved_cidr": schema.StringAttribute{
+				Computed:            true,
+				MarkdownDescription: "The reserved CIDR block",
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.UseStateForUnknown(),
+				},
+			},
+		},
+	}
+}
+
+func (r *ReservationResource) Configure(ctx context.Context, req resource.ConfigureRequest, resp *resource.ConfigureResponse) {
+	if req.ProviderData == nil {
+		return
+	}
+
+	provider, ok := req.ProviderData.(*IpamProvider)
+	if !ok {
+		resp.Diagnostics.AddError(
+			"Unexpected Resource Configure Type",
+			fmt.Sprintf("Expected *IpamProvider, got: %T", req.ProviderData),
+		)
+		return
+	}
+
+	r.provider = provider
+}
+
+func (r *ReservationResource) Create(ctx context.Context, req resource.CreateRequest, resp *resource.CreateResponse) {
+	var data ReservationResourceModel
+
+	resp.Diagnostics.Append(req.Plan.Get(ctx, &data)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	prefixLength := int(data.PrefixLength.ValueInt64())
+	if prefixLength < 0 || prefixLength > 128 {
+		resp.Diagnostics.AddAttributeError(
+			path.Root("prefix_length"),
+			"Invalid Prefix Length",
+			fmt.Sprintf("Prefix length must be between 0 and 128, got %d", prefixLength),
+		)
+		return
+	}
+
+	name := data.Name.ValueString()
+	poolName := data.PoolName.ValueString()
+	if name == poolName {
+		resp.Diagnostics.AddAttributeError(
+			path.Root("name"),
+			"Invalid Reservation Name",
+			"name must differ from pool_name, it names the new pool the reserved block is allocated from",
+		)
+		return
+	}
+
+	// the reservation's pool is created here, so it can't take over a pool that's already in use
+	if _, err := r.provider.storage.GetPool(ctx, name); err == nil {
+		resp.Diagnostics.AddAttributeError(
+			path.Root("name"),
+			"Pool Already Exists",
+			fmt.Sprintf("Pool %s already exists in storage. Choose another name for the reservation's pool.", name),
+		)
+		return
+	} else if !errors.Is(err, storage.ErrNotFound) {
+		resp.Diagnostics.AddError(
+			"Failed to Read Pool",
+			fmt.Sprintf("Could not check for pool %s in storage: %s", name, err),
+		)
+		return
+	}
+
+	reservationID := data.ID.ValueString()
+	if data.ID.IsNull() || data.ID.IsUnknown() {
+		generatedID, err := uuid.GenerateUUID()
+		if err != nil {
+			resp.Diagnostics.AddError(
+				"Reservation ID Generation Failed",
+				fmt.Sprintf("Unable to generate a reservation ID: %s", err),
+			)
+			return
+		}
+		reservationID = generatedID
+	}
+
+	// the reserved block is claimed like any other allocation, so it takes up space in the pool
+	allocation := &storage.Allocation{
+		ID:           reservationID,
+		PoolName:     poolName,
+		PrefixLength: prefixLength,
+		ReservedPool: name,
+	}
+	allocations := &AllocationResource{provider: r.provider}
+	if err := allocations.allocateCIDRFromPool(ctx, allocation, "", r.provider.ipv6MinPrefix); err != nil {
+		addStorageWriteError(
+			&resp.Diagnostics,
+			"Reservation Failed",
+			fmt.Sprintf("Unable to reserve a /%d from pool %s: %s", prefixLength, poolName, err),
+			err,
+		)
+		return
+	}
+
+	pool := &storage.Pool{
+		Name:  name,
+		CIDRs: []string{allocation.AllocatedCIDR},
+	}
+	fields := map[string]any{
+		"name": name,
+	}
+	err := r.provider.retryOnConflict(ctx, "save the reservation's pool", fields, func() error {
+		return r.provider.storage.SavePool(ctx, pool)
+	})
+	if err != nil {
+		// give the block back rather than leaving it reserved without a pool to allocate from
+		if deleteErr := r.provider.storage.DeleteAllocation(ctx, allocation.Key); deleteErr != nil {
+			tflog.Warn(ctx, "unable to release reserved block after its pool couldn't be saved", map[string]any{
+				"id":        reservationID,
+				"pool_name": poolName,
+				"error":     deleteErr.Error(),
+			})
+		}
+		addStorageWriteError(
+			&resp.Diagnostics,
+			"Reservation Failed",
+			fmt.Sprintf("Unable to create pool %s for the reserved block %s: %s", name, allocation.AllocatedCIDR, err),
+			err,
+		)
+		return
+	}
+
+	data.ID = types.StringValue(reservationID)
+	data.Key = types.StringValue(allocation.Key)
+	data.ReservedCIDR = types.StringValue(allocation.AllocatedCIDR)
+
+	tflog.Trace(ctx, "created reservation resource", map[string]any{
+		"id":            reservationID,
+		"name":          name,
+		"pool_name":     poolName,
+		"reserved_cidr": allocation.AllocatedCIDR,
+	})
+
+	resp.Diagnostics.Append(resp.State.Set(ctx, &data)...)
+}
+
+func (r *ReservationResource) Read(ctx context.Context, req resource.ReadRequest, resp *resource.ReadResponse) {
+	var data ReservationResourceModel
+
+	resp.Diagnostics.Append(req.State.Get(ctx, &data)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	allocation, err := r.provider.storage.GetAllocation(ctx, allocationKey(data.Key, data.ID))
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			// reservation was released outside Terraform
+			resp.State.RemoveResource(ctx)
+			return
+		}
+		resp.Diagnostics.AddError(
+			"Failed to Read Reservation",
+			fmt.Sprintf("Could not read reservation from storage: %s", err),
+		)
+		return
+	}
+
+	if allocation.ReservedPool == "" {
+		resp.Diagnostics.AddError(
+			"Not a Reservation",
+			fmt.Sprintf("Allocation %s in pool %s is not a reservation. Manage it with tfipam_allocation instead.", allocation.ID, allocation.PoolName),
+		)
+		return
+	}
+
+	// sync state with storage data, everything is derived from the allocation so an import gets it too
+	data.Key = types.StringValue(allocation.StorageKey())
+	data.Name = types.StringValue(allocation.ReservedPool)
+	data.PoolName = types.StringValue(allocation.PoolName)
+	data.PrefixLength = types.Int64Value(int64(allocation.PrefixLength))
+	data.ReservedCIDR = types.StringValue(allocation.AllocatedCIDR)
+
+	resp.Diagnostics.Append(resp.State.Set(ctx, &data)...)
+}
+
+func (r *ReservationResource) Update(ctx context.Context, req resource.UpdateRequest, resp *resource.UpdateResponse) {
+	// every attribute requires replacement, so there's nothing to update
+	var data ReservationResourceModel
+
+	resp.Diagnostics.Append(req.Plan.Get(ctx, &data)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	resp.Diagnostics.Append(resp.State.Set(ctx, &data)...)
+}
+
+func (r *ReservationResource) Delete(ctx context.Context, req resource.DeleteRequest, resp *resource.DeleteResponse) {
+	var data ReservationResourceModel
+
+	resp.Diagnostics.Append(req.State.Get(ctx, &data)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	name := data.Name.ValueString()
+	allocations, err := r.provider.storage.ListAllocationsByPool(ctx, name)
+	if err != nil {
+		resp.Diagnostics.AddError(
+			"Failed to Check Allocations",
+			fmt.Sprintf("Could not check for allocations: %s", err),
+		)
+		return
+	}
+	if len(allocations) > 0 {
+		resp.Diagnostics.AddError(
+			"Cannot Delete Reservation",
+			fmt.Sprintf("Pool %s of the reservation has %d active allocations. Please delete them before deleting the reservation.", name, len(allocations)),
+		)
+		return
+	}
+
+	err = r.provider.retryOnConflict(ctx, "delete the reservation's pool", map[string]any{"name": name}, func() error {
+		if err := r.provider.storage.DeletePool(ctx, name); err != nil && !errors.Is(err, storage.ErrNotFound) {
+			return err
+		}
+		return nil
+	})
+	if err != nil {
+		addStorageWriteError(
+			&resp.Diagnostics,
+			"Failed to Delete Pool",
+			fmt.Sprintf("Could not delete pool %s of the reservation from storage: %s", name, err),
+			err,
+		)
+		return
+	}
+
+	key := allocationKey(data.Key, data.ID)
+	err = r.provider.retryOnConflict(ctx, "release the reserved block", map[string]any{"key": key}, func() error {
+		return r.provider.storage.DeleteAllocation(ctx, key)
+	})
+	if err != nil {
+		addStorageWriteError(
+			&resp.Diagnostics,
+			"Failed to Delete Reservation",
+			fmt.Sprintf("Could not release the reserved block from storage: %s", err),
+			err,
+		)
+		return
+	}
+
+	tflog.Trace(ctx, "deleted reservation resource", map[string]any{
+		"id":        data.ID.ValueString(),
+		"name":      name,
+		"pool_name": data.PoolName.ValueString(),
+	})
+}
+
+func (r *ReservationResource) ImportState(ctx context.Context, req resource.ImportStateRequest, resp *resource.ImportStateResponse) {
+	r.provider.importAllocation(ctx, req, resp)
+}
@@ -0,0 +1,120 @@
+package provider
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-testing/helper/resource"
+	"github.com/hashicorp/terraform-plugin-testing/knownvalue"
+	"github.com/hashicorp/terraform-plugin-testing/statecheck"
+	"github.com/hashicorp/terraform-plugin-testing/tfjsonpath"
+)
+
+func TestAccReservationResource(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		PreCheck:                 func() { testAccPreCheck(t) },
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			// the reservation takes the first /24, so the pool's next allocation comes after it while the
+			// allocations from the reservation stay inside it
+			{
+				Config: testAccReservationResourceConfig,
+				ConfigStateChecks: []statecheck.StateCheck{
+					statecheck.ExpectKnownValue(
+						"tfipam_reservation.test",
+						tfjsonpath.New("reserved_cidr"),
+						knownvalue.StringExact("10.4.0.0/24"),
+					),
+					statecheck.ExpectKnownValue(
+						"tfipam_allocation.outside",
+						tfjsonpath.New("allocated_cidr"),
+						knownvalue.StringExact("10.4.1.0/26"),
+					),
+					statecheck.ExpectKnownValue(
+						"tfipam_allocation.first",
+						tfjsonpath.New("allocated_cidr"),
+						knownvalue.StringExact("10.4.0.0/26"),
+					),
+					statecheck.ExpectKnownValue(
+						"tfipam_allocation.second",
+						tfjsonpath.New("allocated_cidr"),
+						knownvalue.StringExact("10.4.0.64/26"),
+					),
+				},
+			},
+			{
+				ResourceName:      "tfipam_reservation.test",
+				ImportState:       true,
+				ImportStateVerify: true,
+			},
+		},
+	})
+}
+
+func TestAccReservationResource_PoolExists(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		PreCheck:                 func() { testAccPreCheck(t) },
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: `
+resource "tfipam_pool" "test" {
+  name  = "reservation-exists-pool"
+  cidrs = ["10.5.0.0/16"]
+}
+
+resource "tfipam_pool" "existing" {
+  name  = "reservation-exists-taken"
+  cidrs = ["192.168.0.0/24"]
+}
+
+resource "tfipam_reservation" "test" {
+  id            = "reservation-exists"
+  name          = tfipam_pool.existing.name
+  pool_name     = tfipam_pool.test.name
+  prefix_length = 24
+}
+`,
+				ExpectError: regexp.MustCompile("Pool Already Exists"),
+			},
+		},
+	})
+}
+
+// testAccReservationResourceConfig reserves a /24 of a pool with two allocations from the reservation, and
+// one from the pool that's created after the reservation.
+const testAccReservationResourceConfig = `
+resource "tfipam_pool" "test" {
+  name  = "reservation-pool"
+  cidrs = ["10.4.0.0/16"]
+}
+
+resource "tfipam_reservation" "test" {
+  id            = "reservation-block"
+  name          = "reservation-subnets"
+  pool_name     = tfipam_pool.test.name
+  prefix_length = 24
+}
+
+resource "tfipam_allocation" "outside" {
+  id            = "reservation-outside"
+  pool_name     = tfipam_pool.test.name
+  prefix_length = 26
+
+  depends_on = [tfipam_reservation.test]
+}
+
+resource "tfipam_allocation" "first" {
+  id            = "reservation-first"
+  pool_name     = tfipam_reservation.test.name
+  prefix_length = 26
+}
+
+resource "tfipam_allocation" "second" {
+  id            = "reservation-second"
+  pool_name     = tfipam_reservation.test.name
+  prefix_length = 26
+
+  depends_on = [tfipam_allocation.first]
+}
+`
@@ -0,0 +1,55 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"net"
+
+	"github.com/hashicorp/terraform-plugin-framework/function"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+)
+
+var _ function.Function = &ReverseDNSZonesFunction{}
+
+func NewReverseDNSZonesFunction() function.Function {
+	return &ReverseDNSZonesFunction{}
+}
+
+type ReverseDNSZonesFunction struct{}
+
+func (f *ReverseDNSZonesFunction) Metadata(ctx context.Context, req function.MetadataRequest, resp *function.MetadataResponse) {
+	resp.Name = "reverse_dns_zones"
+}
+
+func (f *ReverseDNSZonesFunction) Definition(ctx context.Context, req function.DefinitionRequest, resp *function.DefinitionResponse) {
+	resp.Definition = function.Definition{
+		Summary:             "Reverse DNS zones covering a CIDR block",
+		MarkdownDescription: "Returns the `in-addr.arpa` or `ip6.arpa` zone names covering the CIDR block, ordered by address. Zones fall on octet boundaries for IPv4 and nibble boundaries for IPv6, so a prefix between two boundaries returns every zone it spans, e.g. a `/23` returns two `/24` zones. An IPv4 prefix longer than `/24` returns the single `/24` zone containing it.",
+		Parameters: []function.Parameter{
+			function.StringParameter{
+				Name:                "cidr",
+				MarkdownDescription: "CIDR block to find the reverse DNS zones for",
+			},
+		},
+		Return: function.ListReturn{
+			ElementType: types.StringType,
+		},
+	}
+}
+
+func (f *ReverseDNSZonesFunction) Run(ctx context.Context, req function.RunRequest, resp *function.RunResponse) {
+	var cidr string
+
+	resp.Error = function.ConcatFuncErrors(req.Arguments.Get(ctx, &cidr))
+	if resp.Error != nil {
+		return
+	}
+
+	_, cidrNet, err := net.ParseCIDR(cidr)
+	if err != nil {
+		resp.Error = function.NewArgumentFuncError(0, fmt.Sprintf("CIDR '%s' is not valid: %s", cidr, err))
+		return
+	}
+
+	resp.Error = function.ConcatFuncErrors(resp.Result.Set(ctx, reverseDNSZones(cidrNet)))
+}
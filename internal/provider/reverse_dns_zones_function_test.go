@@ -0,0 +1,121 @@
+package provider
+
+import (
+	"fmt"
+	"regexp"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-testing/helper/resource"
+	"github.com/hashicorp/terraform-plugin-testing/knownvalue"
+	"github.com/hashicorp/terraform-plugin-testing/statecheck"
+	"github.com/hashicorp/terraform-plugin-testing/tfversion"
+)
+
+func TestAccReverseDNSZonesFunction_IPv4(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		PreCheck:                 func() { testAccPreCheck(t) },
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		TerraformVersionChecks: []tfversion.TerraformVersionCheck{
+			tfversion.SkipBelow(tfversion.Version1_8_0),
+		},
+		Steps: []resource.TestStep{
+			{
+				Config: testAccReverseDNSZonesFunctionConfig("10.1.2.0/24"),
+				ConfigStateChecks: []statecheck.StateCheck{
+					statecheck.ExpectKnownOutputValue(
+						"test",
+						knownvalue.ListExact([]knownvalue.Check{
+							knownvalue.StringExact("2.1.10.in-addr.arpa"),
+						}),
+					),
+				},
+			},
+			// a prefix between octet boundaries spans several zones
+			{
+				Config: testAccReverseDNSZonesFunctionConfig("10.1.2.0/23"),
+				ConfigStateChecks: []statecheck.StateCheck{
+					statecheck.ExpectKnownOutputValue(
+						"test",
+						knownvalue.ListExact([]knownvalue.Check{
+							knownvalue.StringExact("2.1.10.in-addr.arpa"),
+							knownvalue.StringExact("3.1.10.in-addr.arpa"),
+						}),
+					),
+				},
+			},
+			// anything smaller than a /24 is covered by the /24 zone it's in
+			{
+				Config: testAccReverseDNSZonesFunctionConfig("10.1.2.128/26"),
+				ConfigStateChecks: []statecheck.StateCheck{
+					statecheck.ExpectKnownOutputValue(
+						"test",
+						knownvalue.ListExact([]knownvalue.Check{
+							knownvalue.StringExact("2.1.10.in-addr.arpa"),
+						}),
+					),
+				},
+			},
+		},
+	})
+}
+
+func TestAccReverseDNSZonesFunction_IPv6(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		PreCheck:                 func() { testAccPreCheck(t) },
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		TerraformVersionChecks: []tfversion.TerraformVersionCheck{
+			tfversion.SkipBelow(tfversion.Version1_8_0),
+		},
+		Steps: []resource.TestStep{
+			{
+				Config: testAccReverseDNSZonesFunctionConfig("2001:db8:abcd:12::/64"),
+				ConfigStateChecks: []statecheck.StateCheck{
+					statecheck.ExpectKnownOutputValue(
+						"test",
+						knownvalue.ListExact([]knownvalue.Check{
+							knownvalue.StringExact("2.1.0.0.d.c.b.a.8.b.d.0.1.0.0.2.ip6.arpa"),
+						}),
+					),
+				},
+			},
+			// a prefix between nibble boundaries spans several zones
+			{
+				Config: testAccReverseDNSZonesFunctionConfig("2001:db8::/31"),
+				ConfigStateChecks: []statecheck.StateCheck{
+					statecheck.ExpectKnownOutputValue(
+						"test",
+						knownvalue.ListExact([]knownvalue.Check{
+							knownvalue.StringExact("8.b.d.0.1.0.0.2.ip6.arpa"),
+							knownvalue.StringExact("9.b.d.0.1.0.0.2.ip6.arpa"),
+						}),
+					),
+				},
+			},
+		},
+	})
+}
+
+func TestAccReverseDNSZonesFunction_InvalidCIDR(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		PreCheck:                 func() { testAccPreCheck(t) },
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		TerraformVersionChecks: []tfversion.TerraformVersionCheck{
+			tfversion.SkipBelow(tfversion.Version1_8_0),
+		},
+		Steps: []resource.TestStep{
+			{
+				Config:      testAccReverseDNSZonesFunctionConfig("10.1.2.0"),
+				ExpectError: regexp.MustCompile("is not valid"),
+			},
+		},
+	})
+}
+
+// testAccReverseDNSZonesFunctionConfig generates a Terraform configuration that outputs the result of reverse_dns_zones.
+func testAccReverseDNSZonesFunctionConfig(cidr string) string {
+	return fmt.Sprintf(`
+output "test" {
+  value = provider::tfipam::reverse_dns_zones(%[1]q)
+}
+`, cidr)
+}
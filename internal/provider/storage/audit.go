@@ -0,0 +1,136 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+)
+
+// AuditEntry is one line of the allocation audit log.
+type AuditEntry struct {
+	Timestamp time.Time `json:"timestamp"`
+	Action    string    `json:"action"` // "allocate", "release" or "purge" of a soft deleted allocation
+	Key       string    `json:"key,omitempty"`
+	ID        string    `json:"id"`
+	PoolName  string    `json:"pool_name"`
+	CIDR      string    `json:"cidr"` // comma separated for an allocation made of several blocks
+}
+
+// auditAppender is implemented by backends that can append lines to an audit log stored next to their data.
+type auditAppender interface {
+	appendAudit(ctx context.Context, key string, line []byte) error
+}
+
+// auditedStorage records every allocation change of the wrapped backend in a JSON lines audit log.
+type auditedStorage struct {
+	Storage
+	appender auditAppender
+	key      string
+}
+
+func newAuditedStorage(backend Storage, key string) (Storage, error) {
+	appender, ok := backend.(auditAppender)
+	if !ok {
+		return nil, fmt.Errorf("storage backend %T doesn't support an audit log", backend)
+	}
+	return &auditedStorage{Storage: backend, appender: appender, key: key}, nil
+}
+
+func (as *auditedStorage) SaveAllocation(ctx context.Context, allocation *Allocation) error {
+	if err := as.Storage.SaveAllocation(ctx, allocation); err != nil {
+		return err
+	}
+
+	// soft delete releases an allocation by saving it as deleted
+	action := "allocate"
+	if allocation.Deleted() {
+		action = "release"
+	}
+	as.record(ctx, action, allocation)
+	return nil
+}
+
+func (as *auditedStorage) DeleteAllocation(ctx context.Context, key string) error {
+	// look the allocation up first so the release entry has its id, pool and CIDR
+	allocation, err := as.Storage.GetAllocation(ctx, key)
+	if err != nil {
+		return err
+	}
+
+	if err := as.Storage.DeleteAllocation(ctx, key); err != nil {
+		return err
+	}
+
+	// a soft deleted allocation was already logged as released
+	action := "release"
+	if allocation.Deleted() {
+		action = "purge"
+	}
+	as.record(ctx, action, allocation)
+	return nil
+}
+
+func (as *auditedStorage) DeletePoolWithAllocations(ctx context.Context, name string) error {
+	// list the allocations first so each gets a release entry
+	allocations, err := as.Storage.ListAllocationsByPool(ctx, name)
+	if err != nil {
+		return err
+	}
+
+	if err := as.Storage.DeletePoolWithAllocations(ctx, name); err != nil {
+		return err
+	}
+
+	for i := range allocations {
+		action := "release"
+		if allocations[i].Deleted() {
+			action = "purge"
+		}
+		as.record(ctx, action, &allocations[i])
+	}
+	return nil
+}
+
+// record appends an entry for a change that has already been written. The change can't be undone
+// at this point, so a failed append is logged rather than failing the operation.
+func (as *auditedStorage) record(ctx context.Context, action string, allocation *Allocation) {
+	line, err := json.Marshal(AuditEntry{
+		Timestamp: time.Now().UTC(),
+		Action:    action,
+		Key:       allocation.StorageKey(),
+		ID:        allocation.ID,
+		PoolName:  allocation.PoolName,
+		CIDR:      strings.Join(allocation.CIDRs(), ","),
+	})
+	if err == nil {
+		err = as.appender.appendAudit(ctx, as.key, append(line, '\n'))
+	}
+	if err != nil {
+		tflog.Warn(ctx, "failed to write audit log entry", map[string]any{
+			"action":    action,
+			"id":        allocation.ID,
+			"pool_name": allocation.PoolName,
+			"cidr":      strings.Join(allocation.CIDRs(), ","),
+			"error":     err.Error(),
+		})
+	}
+}
+
+// appendAuditFile appends a line to a local audit log file, creating it if needed.
+func appendAuditFile(path string, line []byte) error {
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open audit log: %w", err)
+	}
+	defer f.Close()
+
+	if _, err := f.Write(line); err != nil {
+		return fmt.Errorf("failed to write audit log: %w", err)
+	}
+	return nil
+}
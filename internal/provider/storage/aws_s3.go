@@ -12,9 +12,13 @@ import (
 	"sync"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
 	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials/stscreds"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
 	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+	"github.com/aws/smithy-go"
 )
 
 type S3Storage struct {
@@ -23,6 +27,80 @@ type S3Storage struct {
 	objectKey  string
 	mu         sync.RWMutex
 	data       *s3Data
+
+	// write compact JSON instead of indented
+	compactJSON bool
+	// Content-Type and Cache-Control of the uploaded object
+	headers objectHeaders
+
+	// retries of failed reads and writes, 0 keeps the SDK's default and a negative number disables them
+	readMaxRetries  int
+	writeMaxRetries int
+
+	// server-side encryption of written objects, empty leaves it to the bucket's default encryption
+	sse      types.ServerSideEncryption
+	kmsKeyID string
+
+	// changes held in memory while writes are batched, uploaded together on Commit
+	batch writeBatch
+
+	// etag of the object the data was loaded from, empty if the object doesn't exist yet
+	etag string
+}
+
+// default session name of an assumed role, shows up in CloudTrail for the calls the provider makes
+const defaultS3RoleSessionName = "terraform-provider-tfipam"
+
+// loadS3Config loads the AWS config of the S3 backend. Its credentials are the access keys when they're
+// provided, otherwise the named shared config profile, otherwise the default credential chain. With a
+// role ARN those credentials are only used to assume the role, and the backend uses the role's.
+func loadS3Config(ctx context.Context, region, accessKeyID, secretAccessKey, sessionToken, profile, roleARN, roleSessionName, externalID string) (aws.Config, error) {
+	var cfg aws.Config
+	var err error
+
+	// load config with credentials if provided, otherwise the named shared config profile or the default config
+	if accessKeyID != "" && secretAccessKey != "" {
+		cfg, err = config.LoadDefaultConfig(ctx,
+			config.WithRegion(region),
+			config.WithCredentialsProvider(aws.CredentialsProviderFunc(func(ctx context.Context) (aws.Credentials, error) {
+				return aws.Credentials{
+					AccessKeyID:     accessKeyID,
+					SecretAccessKey: secretAccessKey,
+					SessionToken:    sessionToken,
+				}, nil
+			})),
+		)
+	} else if profile != "" {
+		// Use the profile's settings from ~/.aws/config and ~/.aws/credentials
+		cfg, err = config.LoadDefaultConfig(ctx,
+			config.WithRegion(region),
+			config.WithSharedConfigProfile(profile),
+		)
+	} else {
+		// Use default credential chain (env vars, ~/.aws/credentials, IAM role, etc)
+		cfg, err = config.LoadDefaultConfig(ctx, config.WithRegion(region))
+	}
+	if err != nil {
+		return aws.Config{}, err
+	}
+
+	if roleARN == "" {
+		return cfg, nil
+	}
+
+	// the role's temporary credentials are cached and refreshed before they expire
+	if roleSessionName == "" {
+		roleSessionName = defaultS3RoleSessionName
+	}
+	assumeRole := stscreds.NewAssumeRoleProvider(sts.NewFromConfig(cfg), roleARN, func(o *stscreds.AssumeRoleOptions) {
+		o.RoleSessionName = roleSessionName
+		if externalID != "" {
+			o.ExternalID = aws.String(externalID)
+		}
+	})
+	cfg.Credentials = aws.NewCredentialsCache(assumeRole)
+
+	return cfg, nil
 }
 
 type s3Data struct {
@@ -37,9 +115,16 @@ type s3Data struct {
 // accessKeyID: AWS Access Key ID (optional, uses default credential chain if empty)
 // secretAccessKey: AWS Secret Access Key (optional, required if accessKeyID is provided)
 // sessionToken: AWS Session Token (optional, for temporary credentials)
+// profile: Shared config profile (optional, used instead of the default credential chain when no access key is provided)
+// roleARN: IAM role to assume with the credentials above (optional, e.g. for a bucket in another account)
+// roleSessionName: Session name of the assumed role (optional, defaults to "terraform-provider-tfipam")
+// externalID: External ID the role's trust policy requires (optional)
 // endpointURL: Custom S3 endpoint URL (optional, for S3 compatible services like MinIO or LocalStack)
 // skipTLSVerify: Skip TLS certificate verification (optional).
-func NewS3Storage(region, bucketName, objectKey, accessKeyID, secretAccessKey, sessionToken, endpointURL string, skipTLSVerify bool) (*S3Storage, error) {
+// readMaxRetries, writeMaxRetries: Retries of failed reads and writes (optional, 0 keeps the SDK's default, negative disables them).
+//
+// ctx bounds the initial bucket check and load.
+func NewS3Storage(ctx context.Context, region, bucketName, objectKey, accessKeyID, secretAccessKey, sessionToken, profile, roleARN, roleSessionName, externalID, endpointURL string, skipTLSVerify bool, readMaxRetries, writeMaxRetries int) (*S3Storage, error) {
 	if region == "" {
 		return nil, errors.New("aws region is required")
 	}
@@ -57,27 +142,11 @@ func NewS3Storage(region, bucketName, objectKey, accessKeyID, secretAccessKey, s
 		return nil, errors.New("aws access key id is required when secret access key is provided")
 	}
 
-	ctx := context.Background()
-	var cfg aws.Config
-	var err error
-
-	// load config with credentials if provided otherwise use default config
-	if accessKeyID != "" && secretAccessKey != "" {
-		cfg, err = config.LoadDefaultConfig(ctx,
-			config.WithRegion(region),
-			config.WithCredentialsProvider(aws.CredentialsProviderFunc(func(ctx context.Context) (aws.Credentials, error) {
-				return aws.Credentials{
-					AccessKeyID:     accessKeyID,
-					SecretAccessKey: secretAccessKey,
-					SessionToken:    sessionToken,
-				}, nil
-			})),
-		)
-	} else {
-		// Use default credential chain (env vars, ~/.aws/credentials, IAM role, etc)
-		cfg, err = config.LoadDefaultConfig(ctx, config.WithRegion(region))
+	if roleARN == "" && (roleSessionName != "" || externalID != "") {
+		return nil, errors.New("aws role arn is required when a role session name or external id is provided")
 	}
 
+	cfg, err := loadS3Config(ctx, region, accessKeyID, secretAccessKey, sessionToken, profile, roleARN, roleSessionName, externalID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to load aws config: %w", err)
 	}
@@ -105,15 +174,22 @@ func NewS3Storage(region, bucketName, objectKey, accessKeyID, secretAccessKey, s
 	}
 
 	s3s := &S3Storage{
-		client:     client,
-		bucketName: bucketName,
-		objectKey:  objectKey,
+		client:          client,
+		bucketName:      bucketName,
+		objectKey:       objectKey,
+		readMaxRetries:  readMaxRetries,
+		writeMaxRetries: writeMaxRetries,
 		data: &s3Data{
 			Pools:       make(map[string]*Pool),
 			Allocations: make(map[string]*Allocation),
 		},
 	}
 
+	// fail fast if the bucket is missing or the credentials can't reach it
+	if err := s3s.Ping(ctx); err != nil {
+		return nil, err
+	}
+
 	// try to load existing data. If object doesn't exist, it'll be created on first save
 	if err := s3s.load(ctx); err != nil {
 		var nsk *types.NoSuchKey
@@ -125,14 +201,70 @@ func NewS3Storage(region, bucketName, objectKey, accessKeyID, secretAccessKey, s
 	return s3s, nil
 }
 
+// Ping verifies the bucket exists and is reachable with the configured credentials.
+// A missing object is fine since it's created on first save, a missing bucket is not.
+func (s3s *S3Storage) Ping(ctx context.Context) error {
+	_, err := s3s.client.HeadBucket(ctx, &s3.HeadBucketInput{
+		Bucket: aws.String(s3s.bucketName),
+	}, s3RetryOptions(s3s.readMaxRetries)...)
+	if err == nil {
+		return nil
+	}
+
+	var notFound *types.NotFound
+	if errors.As(err, &notFound) {
+		return fmt.Errorf("s3 bucket %s does not exist: %w", s3s.bucketName, err)
+	}
+
+	var respErr *awshttp.ResponseError
+	if errors.As(err, &respErr) && (respErr.HTTPStatusCode() == http.StatusForbidden || respErr.HTTPStatusCode() == http.StatusUnauthorized) {
+		return fmt.Errorf("s3 bucket %s: %w, check the configured credentials and bucket policy", s3s.bucketName, ErrAccessDenied)
+	}
+
+	return fmt.Errorf("failed to reach s3 bucket %s: %w", s3s.bucketName, err)
+}
+
+// openShard opens the object next to this one that holds a pool's allocations in sharded mode.
+// A missing object is fine since it's created on first save.
+func (s3s *S3Storage) openShard(ctx context.Context, poolName string) (Storage, error) {
+	shard := &S3Storage{
+		client:          s3s.client,
+		bucketName:      s3s.bucketName,
+		objectKey:       shardObjectKey(s3s.objectKey, poolName),
+		compactJSON:     s3s.compactJSON,
+		headers:         s3s.headers,
+		readMaxRetries:  s3s.readMaxRetries,
+		writeMaxRetries: s3s.writeMaxRetries,
+		sse:             s3s.sse,
+		kmsKeyID:        s3s.kmsKeyID,
+		data: &s3Data{
+			Pools:       make(map[string]*Pool),
+			Allocations: make(map[string]*Allocation),
+		},
+	}
+
+	if err := shard.load(ctx); err != nil {
+		var nsk *types.NoSuchKey
+		if !errors.As(err, &nsk) {
+			return nil, fmt.Errorf("failed to load storage object %s: %w", shard.objectKey, err)
+		}
+	}
+	return shard, nil
+}
+
 func (s3s *S3Storage) load(ctx context.Context) error {
 	s3s.mu.Lock()
 	defer s3s.mu.Unlock()
 
+	return s3s.reload(ctx)
+}
+
+// reload replaces the in-memory data with the latest object. Callers must hold the lock.
+func (s3s *S3Storage) reload(ctx context.Context) error {
 	result, err := s3s.client.GetObject(ctx, &s3.GetObjectInput{
 		Bucket: aws.String(s3s.bucketName),
 		Key:    aws.String(s3s.objectKey),
-	})
+	}, s3RetryOptions(s3s.readMaxRetries)...)
 	if err != nil {
 		return err
 	}
@@ -143,34 +275,145 @@ func (s3s *S3Storage) load(ctx context.Context) error {
 		return fmt.Errorf("failed to read s3 object data: %w", err)
 	}
 
-	return json.Unmarshal(data, s3s.data)
+	fresh := &s3Data{
+		Pools:       make(map[string]*Pool),
+		Allocations: make(map[string]*Allocation),
+	}
+	if err := json.Unmarshal(data, fresh); err != nil {
+		return err
+	}
+	migrateAllocationKeys(fresh.Allocations)
+
+	s3s.data = fresh
+	s3s.etag = aws.ToString(result.ETag)
+	return nil
+}
+
+// setS3Encryption sets the server-side encryption of written objects. sse is "AES256", "aws:kms" or
+// "aws:kms:dsse", and a KMS key id is only valid with one of the KMS types. Empty values leave the
+// encryption to the bucket's default.
+func (s3s *S3Storage) setS3Encryption(sse, kmsKeyID string) error {
+	switch types.ServerSideEncryption(sse) {
+	case "", types.ServerSideEncryptionAes256:
+		if kmsKeyID != "" {
+			return errors.New("s3 kms key id requires server-side encryption aws:kms or aws:kms:dsse")
+		}
+	case types.ServerSideEncryptionAwsKms, types.ServerSideEncryptionAwsKmsDsse:
+	default:
+		return fmt.Errorf("unsupported s3 server-side encryption %q, must be AES256, aws:kms or aws:kms:dsse", sse)
+	}
+
+	s3s.sse = types.ServerSideEncryption(sse)
+	s3s.kmsKeyID = kmsKeyID
+	return nil
+}
+
+// setEncryption adds the configured server-side encryption to a write.
+func (s3s *S3Storage) setEncryption(input *s3.PutObjectInput) {
+	if s3s.sse != "" {
+		input.ServerSideEncryption = s3s.sse
+	}
+	if s3s.kmsKeyID != "" {
+		input.SSEKMSKeyId = aws.String(s3s.kmsKeyID)
+	}
+}
+
+// setHeaders adds the configured Content-Type and Cache-Control to a write of the data.
+func (s3s *S3Storage) setHeaders(input *s3.PutObjectInput) {
+	contentType := s3s.headers.contentType
+	if contentType == "" {
+		contentType = defaultContentType
+	}
+	input.ContentType = aws.String(contentType)
+	if s3s.headers.cacheControl != "" {
+		input.CacheControl = aws.String(s3s.headers.cacheControl)
+	}
 }
 
 func (s3s *S3Storage) save(ctx context.Context) error {
-	data, err := json.MarshalIndent(s3s.data, "", "  ")
+	data, err := marshalData(s3s.data, s3s.compactJSON)
 	if err != nil {
 		return fmt.Errorf("failed to marshal storage data: %w", err)
 	}
 
-	_, err = s3s.client.PutObject(ctx, &s3.PutObjectInput{
+	// conditional write so a concurrent writer can't be silently overwritten
+	input := &s3.PutObjectInput{
 		Bucket: aws.String(s3s.bucketName),
 		Key:    aws.String(s3s.objectKey),
 		Body:   bytes.NewReader(data),
-	})
+	}
+	s3s.setEncryption(input)
+	s3s.setHeaders(input)
+	if s3s.etag != "" {
+		input.IfMatch = aws.String(s3s.etag)
+	} else {
+		input.IfNoneMatch = aws.String("*")
+	}
+
+	result, err := s3s.client.PutObject(ctx, input, s3RetryOptions(s3s.writeMaxRetries)...)
 	if err != nil {
+		var respErr *awshttp.ResponseError
+		if errors.As(err, &respErr) && (respErr.HTTPStatusCode() == http.StatusPreconditionFailed || respErr.HTTPStatusCode() == http.StatusConflict) {
+			if err := s3s.reload(ctx); err != nil {
+				return fmt.Errorf("failed to reload s3 object after conflict: %w", err)
+			}
+			return ErrConflict
+		}
+		if isS3ReadOnlyError(err) {
+			// the object in memory has the rejected change, put it back to what's stored so reads stay accurate
+			if reloadErr := s3s.reload(ctx); reloadErr != nil {
+				var nsk *types.NoSuchKey
+				if !errors.As(reloadErr, &nsk) {
+					return fmt.Errorf("failed to reload s3 object after a rejected write: %w", reloadErr)
+				}
+				s3s.data = &s3Data{
+					Pools:       make(map[string]*Pool),
+					Allocations: make(map[string]*Allocation),
+				}
+			}
+			return fmt.Errorf("failed to upload s3 object %s to bucket %s: %w: %w", s3s.objectKey, s3s.bucketName, ErrReadOnly, err)
+		}
 		return fmt.Errorf("failed to upload s3 object: %w", err)
 	}
 
+	s3s.etag = aws.ToString(result.ETag)
 	return nil
 }
 
+// s3RetryOptions overrides the client's retries for a single request. The SDK counts attempts, so
+// the first try is added to the retries, and 0 returns no override to keep the SDK's default.
+func s3RetryOptions(maxRetries int) []func(*s3.Options) {
+	if maxRetries == 0 {
+		return nil
+	}
+	attempts := max(maxRetries, 0) + 1
+	return []func(*s3.Options){func(o *s3.Options) {
+		o.RetryMaxAttempts = attempts
+	}}
+}
+
+// isS3ReadOnlyError reports whether a write was rejected by the bucket's permissions rather than failing,
+// e.g. a bucket policy denying s3:PutObject while the bucket is failed over.
+func isS3ReadOnlyError(err error) bool {
+	var apiErr smithy.APIError
+	if errors.As(err, &apiErr) {
+		switch apiErr.ErrorCode() {
+		case "AccessDenied", "AllAccessDisabled", "AccountProblem":
+			return true
+		}
+	}
+
+	var respErr *awshttp.ResponseError
+	return errors.As(err, &respErr) && respErr.HTTPStatusCode() == http.StatusForbidden
+}
+
 func (s3s *S3Storage) GetPool(ctx context.Context, name string) (*Pool, error) {
 	s3s.mu.RLock()
 	defer s3s.mu.RUnlock()
 
 	pool, exists := s3s.data.Pools[name]
 	if !exists {
-		return nil, ErrNotFound
+		return nil, ErrPoolNotFound
 	}
 
 	// return copy
@@ -195,11 +438,24 @@ func (s3s *S3Storage) SavePool(ctx context.Context, pool *Pool) error {
 	s3s.mu.Lock()
 	defer s3s.mu.Unlock()
 
+	revision, err := nextPoolRevision(s3s.data.Pools[pool.Name], pool)
+	if err != nil {
+		return err
+	}
+
 	// save a copy
 	poolCopy := *pool
+	poolCopy.Revision = revision
 	s3s.data.Pools[pool.Name] = &poolCopy
 
-	return s3s.save(ctx)
+	if !s3s.batch.record(savePoolChange(poolCopy)) {
+		if err := s3s.save(ctx); err != nil {
+			return err
+		}
+	}
+
+	pool.Revision = revision
+	return nil
 }
 
 func (s3s *S3Storage) DeletePool(ctx context.Context, name string) error {
@@ -207,20 +463,43 @@ func (s3s *S3Storage) DeletePool(ctx context.Context, name string) error {
 	defer s3s.mu.Unlock()
 
 	if _, exists := s3s.data.Pools[name]; !exists {
-		return ErrNotFound
+		return ErrPoolNotFound
 	}
 
 	delete(s3s.data.Pools, name)
+	if s3s.batch.record(deletePoolChange(name)) {
+		return nil
+	}
+	return s3s.save(ctx)
+}
+
+// DeletePoolWithAllocations removes the pool and its allocations in memory and then writes the object
+// once, so they're all deleted or none are.
+func (s3s *S3Storage) DeletePoolWithAllocations(ctx context.Context, name string) error {
+	s3s.mu.Lock()
+	defer s3s.mu.Unlock()
+
+	if _, exists := s3s.data.Pools[name]; !exists {
+		return ErrPoolNotFound
+	}
+
+	change := deletePoolWithAllocationsChange(name)
+	if err := change(s3s.data.Pools, s3s.data.Allocations); err != nil {
+		return err
+	}
+	if s3s.batch.record(change) {
+		return nil
+	}
 	return s3s.save(ctx)
 }
 
-func (s3s *S3Storage) GetAllocation(ctx context.Context, id string) (*Allocation, error) {
+func (s3s *S3Storage) GetAllocation(ctx context.Context, key string) (*Allocation, error) {
 	s3s.mu.RLock()
 	defer s3s.mu.RUnlock()
 
-	allocation, exists := s3s.data.Allocations[id]
+	allocation, exists := s3s.data.Allocations[key]
 	if !exists {
-		return nil, ErrNotFound
+		return nil, ErrAllocationNotFound
 	}
 
 	// return copy
@@ -255,30 +534,131 @@ func (s3s *S3Storage) ListAllocationsByPool(ctx context.Context, poolName string
 	return allocations, nil
 }
 
+func (s3s *S3Storage) Snapshot(ctx context.Context) (*Snapshot, error) {
+	s3s.mu.RLock()
+	defer s3s.mu.RUnlock()
+
+	// copies of both under the one lock, so no write lands in between
+	snapshot := &Snapshot{
+		Pools:       make([]Pool, 0, len(s3s.data.Pools)),
+		Allocations: make([]Allocation, 0, len(s3s.data.Allocations)),
+	}
+	for _, pool := range s3s.data.Pools {
+		snapshot.Pools = append(snapshot.Pools, *pool)
+	}
+	for _, alloc := range s3s.data.Allocations {
+		snapshot.Allocations = append(snapshot.Allocations, *alloc)
+	}
+
+	return snapshot, nil
+}
+
 func (s3s *S3Storage) SaveAllocation(ctx context.Context, allocation *Allocation) error {
 	s3s.mu.Lock()
 	defer s3s.mu.Unlock()
 
 	// save a copy
+	allocation.Key = allocation.StorageKey()
 	allocCopy := *allocation
-	s3s.data.Allocations[allocation.ID] = &allocCopy
+	s3s.data.Allocations[allocation.Key] = &allocCopy
 
+	if s3s.batch.record(saveAllocationChange(allocCopy)) {
+		return nil
+	}
 	return s3s.save(ctx)
 }
 
-func (s3s *S3Storage) DeleteAllocation(ctx context.Context, id string) error {
+func (s3s *S3Storage) DeleteAllocation(ctx context.Context, key string) error {
 	s3s.mu.Lock()
 	defer s3s.mu.Unlock()
 
-	if _, exists := s3s.data.Allocations[id]; !exists {
-		return ErrNotFound
+	if _, exists := s3s.data.Allocations[key]; !exists {
+		return ErrAllocationNotFound
 	}
 
-	delete(s3s.data.Allocations, id)
+	delete(s3s.data.Allocations, key)
+	if s3s.batch.record(deleteAllocationChange(key)) {
+		return nil
+	}
 	return s3s.save(ctx)
 }
 
-func (s3s *S3Storage) Close() error {
-	// AWS SDK doesn't require explicit cleanup
+// Begin batches the writes until Commit, which uploads the object once instead of once per change.
+func (s3s *S3Storage) Begin(ctx context.Context) error {
+	s3s.mu.Lock()
+	defer s3s.mu.Unlock()
+
+	s3s.batch.active = true
 	return nil
 }
+
+// Commit uploads the object with the changes made since Begin, applying them again on top of another writer's
+// data if it saved first.
+func (s3s *S3Storage) Commit(ctx context.Context) error {
+	s3s.mu.Lock()
+	defer s3s.mu.Unlock()
+
+	return commitBatch(s3s.batch.end(), func() error {
+		return s3s.save(ctx)
+	}, func() (map[string]*Pool, map[string]*Allocation) {
+		return s3s.data.Pools, s3s.data.Allocations
+	}, func(pools map[string]*Pool, allocations map[string]*Allocation) {
+		s3s.data.Pools, s3s.data.Allocations = pools, allocations
+	})
+}
+
+func (s3s *S3Storage) Close() error {
+	// changes are uploaded before the call that made them returns unless writes are batched, so only
+	// an open batch is left to flush. The AWS SDK doesn't require explicit cleanup
+	return s3s.Commit(context.Background())
+}
+
+// appendAudit appends to the audit log object. S3 objects can't be appended to in place, so the
+// object is rewritten with a conditional write and retried if another writer appended first.
+func (s3s *S3Storage) appendAudit(ctx context.Context, key string, line []byte) error {
+	for attempt := 0; attempt < 5; attempt++ {
+		var existing []byte
+		etag := ""
+
+		result, err := s3s.client.GetObject(ctx, &s3.GetObjectInput{
+			Bucket: aws.String(s3s.bucketName),
+			Key:    aws.String(key),
+		}, s3RetryOptions(s3s.readMaxRetries)...)
+		if err == nil {
+			existing, err = io.ReadAll(result.Body)
+			result.Body.Close()
+			if err != nil {
+				return fmt.Errorf("failed to read audit log object: %w", err)
+			}
+			etag = aws.ToString(result.ETag)
+		} else {
+			var nsk *types.NoSuchKey
+			if !errors.As(err, &nsk) {
+				return fmt.Errorf("failed to read audit log object: %w", err)
+			}
+		}
+
+		input := &s3.PutObjectInput{
+			Bucket: aws.String(s3s.bucketName),
+			Key:    aws.String(key),
+			Body:   bytes.NewReader(append(existing, line...)),
+		}
+		s3s.setEncryption(input)
+		if etag != "" {
+			input.IfMatch = aws.String(etag)
+		} else {
+			input.IfNoneMatch = aws.String("*")
+		}
+
+		_, err = s3s.client.PutObject(ctx, input, s3RetryOptions(s3s.writeMaxRetries)...)
+		if err == nil {
+			return nil
+		}
+		var respErr *awshttp.ResponseError
+		if !errors.As(err, &respErr) || (respErr.HTTPStatusCode() != http.StatusPreconditionFailed && respErr.HTTPStatusCode() != http.StatusConflict) {
+			return fmt.Errorf("failed to upload audit log object: %w", err)
+		}
+	}
+
+	return fmt.Errorf("failed to append to audit log object: %w", ErrConflict)
+}
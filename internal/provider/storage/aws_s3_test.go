@@ -0,0 +1,182 @@
+package storage
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials/stscreds"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// setSharedConfig points the AWS SDK at a shared config file with a profile holding static keys, and
+// clears the environment so the default chain can't pick up anything else.
+func setSharedConfig(t *testing.T) {
+	t.Helper()
+
+	dir := t.TempDir()
+	configFile := filepath.Join(dir, "config")
+	contents := "[profile ipam]\naws_access_key_id = PROFILEKEY\naws_secret_access_key = PROFILESECRET\n"
+	if err := os.WriteFile(configFile, []byte(contents), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Setenv("AWS_CONFIG_FILE", configFile)
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", filepath.Join(dir, "credentials"))
+	t.Setenv("AWS_PROFILE", "")
+	t.Setenv("AWS_ACCESS_KEY_ID", "")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "")
+	t.Setenv("AWS_SESSION_TOKEN", "")
+}
+
+func TestLoadS3Config_Credentials(t *testing.T) {
+	setSharedConfig(t)
+	ctx := context.Background()
+
+	tests := []struct {
+		name        string
+		accessKeyID string
+		secretKey   string
+		profile     string
+		wantKeyID   string
+	}{
+		{name: "access keys win over the profile", accessKeyID: "STATICKEY", secretKey: "STATICSECRET", profile: "ipam", wantKeyID: "STATICKEY"},
+		{name: "profile", profile: "ipam", wantKeyID: "PROFILEKEY"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cfg, err := loadS3Config(ctx, "us-east-1", test.accessKeyID, test.secretKey, "", test.profile, "", "", "")
+			if err != nil {
+				t.Fatalf("loadS3Config: %v", err)
+			}
+			creds, err := cfg.Credentials.Retrieve(ctx)
+			if err != nil {
+				t.Fatalf("Retrieve: %v", err)
+			}
+			if creds.AccessKeyID != test.wantKeyID {
+				t.Errorf("access key id = %q, want %q", creds.AccessKeyID, test.wantKeyID)
+			}
+		})
+	}
+}
+
+func TestLoadS3Config_MissingProfile(t *testing.T) {
+	setSharedConfig(t)
+
+	if _, err := loadS3Config(context.Background(), "us-east-1", "", "", "", "missing", "", "", ""); err == nil {
+		t.Fatal("expected an error for a profile that isn't in the shared config")
+	}
+}
+
+func TestLoadS3Config_AssumeRole(t *testing.T) {
+	setSharedConfig(t)
+
+	cfg, err := loadS3Config(context.Background(), "us-east-1", "STATICKEY", "STATICSECRET", "", "", "arn:aws:iam::123456789012:role/ipam", "", "ext")
+	if err != nil {
+		t.Fatalf("loadS3Config: %v", err)
+	}
+
+	cache, ok := cfg.Credentials.(*aws.CredentialsCache)
+	if !ok {
+		t.Fatalf("credentials are %T, want a credentials cache", cfg.Credentials)
+	}
+	if !cache.IsCredentialsProvider((*stscreds.AssumeRoleProvider)(nil)) {
+		t.Error("credentials don't assume the role")
+	}
+}
+
+func TestSetS3Encryption(t *testing.T) {
+	tests := []struct {
+		name     string
+		sse      string
+		kmsKeyID string
+		wantErr  bool
+	}{
+		{name: "bucket default"},
+		{name: "aes256", sse: "AES256"},
+		{name: "kms with key", sse: "aws:kms", kmsKeyID: "alias/ipam"},
+		{name: "kms without key", sse: "aws:kms:dsse"},
+		{name: "unsupported", sse: "aws:fsx", wantErr: true},
+		{name: "key without kms", sse: "AES256", kmsKeyID: "alias/ipam", wantErr: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s3s := &S3Storage{}
+			err := s3s.setS3Encryption(test.sse, test.kmsKeyID)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("setS3Encryption error = %v, want error %v", err, test.wantErr)
+			}
+			if err != nil {
+				return
+			}
+
+			input := &s3.PutObjectInput{}
+			s3s.setEncryption(input)
+			if string(input.ServerSideEncryption) != test.sse {
+				t.Errorf("server-side encryption = %q, want %q", input.ServerSideEncryption, test.sse)
+			}
+			if aws.ToString(input.SSEKMSKeyId) != test.kmsKeyID {
+				t.Errorf("kms key id = %q, want %q", aws.ToString(input.SSEKMSKeyId), test.kmsKeyID)
+			}
+		})
+	}
+}
+
+func TestS3Storage_UploadHeaders(t *testing.T) {
+	setSharedConfig(t)
+	ctx := context.Background()
+
+	tests := []struct {
+		name             string
+		headers          objectHeaders
+		wantContentType  string
+		wantCacheControl string
+	}{
+		{name: "json by default", wantContentType: "application/json"},
+		{name: "configured", headers: objectHeaders{contentType: "application/vnd.ipam+json", cacheControl: "no-cache"}, wantContentType: "application/vnd.ipam+json", wantCacheControl: "no-cache"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// an S3 compatible endpoint with an empty bucket that records the headers of uploads
+			var mu sync.Mutex
+			var uploaded http.Header
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				switch r.Method {
+				case http.MethodHead:
+				case http.MethodGet:
+					w.WriteHeader(http.StatusNotFound)
+					_, _ = w.Write([]byte(`<Error><Code>NoSuchKey</Code><Message>missing</Message></Error>`))
+				case http.MethodPut:
+					mu.Lock()
+					uploaded = r.Header.Clone()
+					mu.Unlock()
+					w.Header().Set("ETag", `"1"`)
+				}
+			}))
+			defer server.Close()
+
+			s3s, err := NewS3Storage(ctx, "us-east-1", "ipam", "ipam.json", "KEY", "SECRET", "", "", "", "", "", server.URL, false, -1, -1)
+			if err != nil {
+				t.Fatalf("NewS3Storage: %v", err)
+			}
+			s3s.headers = test.headers
+			if err := s3s.SavePool(ctx, &Pool{Name: "blue", CIDRs: []string{"10.0.0.0/16"}}); err != nil {
+				t.Fatalf("SavePool: %v", err)
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			if got := uploaded.Get("Content-Type"); got != test.wantContentType {
+				t.Errorf("Content-Type = %q, want %q", got, test.wantContentType)
+			}
+			if got := uploaded.Get("Cache-Control"); got != test.wantCacheControl {
+				t.Errorf("Cache-Control = %q, want %q", got, test.wantCacheControl)
+			}
+		})
+	}
+}
@@ -7,9 +7,16 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"net/http"
 	"sync"
 
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/streaming"
+	"github.com/Azure/azure-sdk-for-go/sdk/azidentity"
 	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/appendblob"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/blob"
 	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/bloberror"
 )
 
@@ -19,6 +26,21 @@ type AzureBlobStorage struct {
 	blobName      string
 	mu            sync.RWMutex
 	data          *blobData
+
+	// write compact JSON instead of indented
+	compactJSON bool
+	// Content-Type and Cache-Control of the uploaded blob
+	headers objectHeaders
+
+	// retries of failed reads and writes, 0 keeps the SDK's default and a negative number disables them
+	readMaxRetries  int
+	writeMaxRetries int
+
+	// changes held in memory while writes are batched, uploaded together on Commit
+	batch writeBatch
+
+	// etag of the blob the data was loaded from, empty if the blob doesn't exist yet
+	etag azcore.ETag
 }
 
 type blobData struct {
@@ -27,12 +49,16 @@ type blobData struct {
 }
 
 // NewAzureBlobStorage creates a new Azure Blob Storage backend
-// connectionString: Azure Storage connection string
+// connectionString: Azure Storage connection string (optional, accountURL is used when empty)
+// accountURL: Blob service URL of the storage account, e.g. "https://account.blob.core.windows.net", authenticated with Azure AD
 // containerName: Name of the blob container
-// blobName: Name of the blob file (e.g. "ipam-storage.json").
-func NewAzureBlobStorage(connectionString, containerName, blobName string) (*AzureBlobStorage, error) {
-	if connectionString == "" {
-		return nil, errors.New("azure connection string is required")
+// blobName: Name of the blob file (e.g. "ipam-storage.json")
+// readMaxRetries, writeMaxRetries: Retries of failed reads and writes (optional, 0 keeps the SDK's default, negative disables them).
+//
+// ctx bounds the initial container check and load.
+func NewAzureBlobStorage(ctx context.Context, connectionString, accountURL, containerName, blobName string, readMaxRetries, writeMaxRetries int) (*AzureBlobStorage, error) {
+	if connectionString == "" && accountURL == "" {
+		return nil, errors.New("azure connection string or account url is required")
 	}
 	if containerName == "" {
 		return nil, errors.New("azure container name is required")
@@ -41,23 +67,29 @@ func NewAzureBlobStorage(connectionString, containerName, blobName string) (*Azu
 		blobName = "ipam-storage.json"
 	}
 
-	client, err := azblob.NewClientFromConnectionString(connectionString, nil)
+	client, err := newAzureBlobClient(connectionString, accountURL)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create azure blob client: %w", err)
 	}
 
 	abs := &AzureBlobStorage{
-		client:        client,
-		containerName: containerName,
-		blobName:      blobName,
+		client:          client,
+		containerName:   containerName,
+		blobName:        blobName,
+		readMaxRetries:  readMaxRetries,
+		writeMaxRetries: writeMaxRetries,
 		data: &blobData{
 			Pools:       make(map[string]*Pool),
 			Allocations: make(map[string]*Allocation),
 		},
 	}
 
+	// fail fast if the container is missing or the credentials can't reach it
+	if err := abs.Ping(ctx); err != nil {
+		return nil, err
+	}
+
 	// try to load existing data, if it doesn't exist it'll be created on first save
-	ctx := context.Background()
 	if err := abs.load(ctx); err != nil {
 		if !bloberror.HasCode(err, bloberror.BlobNotFound) {
 			return nil, fmt.Errorf("failed to load storage blob: %w", err)
@@ -67,11 +99,77 @@ func NewAzureBlobStorage(connectionString, containerName, blobName string) (*Azu
 	return abs, nil
 }
 
+// newAzureBlobClient creates a client from the connection string when there is one. Otherwise it
+// authenticates to the account URL with Azure AD through DefaultAzureCredential, which picks up
+// workload identity, managed identity, environment variables or an Azure CLI login, so no secret
+// has to be configured.
+func newAzureBlobClient(connectionString, accountURL string) (*azblob.Client, error) {
+	if connectionString != "" {
+		return azblob.NewClientFromConnectionString(connectionString, nil)
+	}
+
+	credential, err := azidentity.NewDefaultAzureCredential(nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load azure ad credentials: %w", err)
+	}
+	return azblob.NewClient(accountURL, credential, nil)
+}
+
+// Ping verifies the container exists and is reachable with the configured credentials.
+// A missing blob is fine since it's created on first save, a missing container is not.
+func (abs *AzureBlobStorage) Ping(ctx context.Context) error {
+	_, err := abs.client.ServiceClient().NewContainerClient(abs.containerName).GetProperties(azureRetryContext(ctx, abs.readMaxRetries), nil)
+	if err == nil {
+		return nil
+	}
+
+	if bloberror.HasCode(err, bloberror.ContainerNotFound) {
+		return fmt.Errorf("azure container %s does not exist: %w", abs.containerName, err)
+	}
+
+	if bloberror.HasCode(err, bloberror.AuthenticationFailed, bloberror.AuthorizationFailure,
+		bloberror.AuthorizationPermissionMismatch, bloberror.InvalidAuthenticationInfo) {
+		return fmt.Errorf("azure container %s: %w, check the configured connection string", abs.containerName, ErrAccessDenied)
+	}
+
+	return fmt.Errorf("failed to reach azure container %s: %w", abs.containerName, err)
+}
+
+// openShard opens the blob next to this one that holds a pool's allocations in sharded mode.
+// A missing blob is fine since it's created on first save.
+func (abs *AzureBlobStorage) openShard(ctx context.Context, poolName string) (Storage, error) {
+	shard := &AzureBlobStorage{
+		client:          abs.client,
+		containerName:   abs.containerName,
+		blobName:        shardObjectKey(abs.blobName, poolName),
+		compactJSON:     abs.compactJSON,
+		headers:         abs.headers,
+		readMaxRetries:  abs.readMaxRetries,
+		writeMaxRetries: abs.writeMaxRetries,
+		data: &blobData{
+			Pools:       make(map[string]*Pool),
+			Allocations: make(map[string]*Allocation),
+		},
+	}
+
+	if err := shard.load(ctx); err != nil {
+		if !bloberror.HasCode(err, bloberror.BlobNotFound) {
+			return nil, fmt.Errorf("failed to load storage blob %s: %w", shard.blobName, err)
+		}
+	}
+	return shard, nil
+}
+
 func (abs *AzureBlobStorage) load(ctx context.Context) error {
 	abs.mu.Lock()
 	defer abs.mu.Unlock()
 
-	downloadResponse, err := abs.client.DownloadStream(ctx, abs.containerName, abs.blobName, nil)
+	return abs.reload(ctx)
+}
+
+// reload replaces the in-memory data with the latest blob. Callers must hold the lock.
+func (abs *AzureBlobStorage) reload(ctx context.Context) error {
+	downloadResponse, err := abs.client.DownloadStream(azureRetryContext(ctx, abs.readMaxRetries), abs.containerName, abs.blobName, nil)
 	if err != nil {
 		return err
 	}
@@ -82,31 +180,115 @@ func (abs *AzureBlobStorage) load(ctx context.Context) error {
 		return fmt.Errorf("failed to read blob data: %w", err)
 	}
 
-	return json.Unmarshal(data, abs.data)
+	fresh := &blobData{
+		Pools:       make(map[string]*Pool),
+		Allocations: make(map[string]*Allocation),
+	}
+	if err := json.Unmarshal(data, fresh); err != nil {
+		return err
+	}
+	migrateAllocationKeys(fresh.Allocations)
+
+	abs.data = fresh
+	if downloadResponse.ETag != nil {
+		abs.etag = *downloadResponse.ETag
+	}
+	return nil
+}
+
+// httpHeaders returns the configured Content-Type and Cache-Control of the blob.
+func (abs *AzureBlobStorage) httpHeaders() *blob.HTTPHeaders {
+	contentType := abs.headers.contentType
+	if contentType == "" {
+		contentType = defaultContentType
+	}
+	headers := &blob.HTTPHeaders{BlobContentType: &contentType}
+	if abs.headers.cacheControl != "" {
+		headers.BlobCacheControl = &abs.headers.cacheControl
+	}
+	return headers
 }
 
 func (abs *AzureBlobStorage) save(ctx context.Context) error {
-	data, err := json.MarshalIndent(abs.data, "", "  ")
+	data, err := marshalData(abs.data, abs.compactJSON)
 	if err != nil {
 		return fmt.Errorf("failed to marshal storage data: %w", err)
 	}
 
-	_, err = abs.client.UploadStream(ctx, abs.containerName, abs.blobName,
-		bytes.NewReader(data), nil)
+	// conditional write so a concurrent writer can't be silently overwritten
+	conditions := &blob.ModifiedAccessConditions{}
+	if abs.etag != "" {
+		conditions.IfMatch = &abs.etag
+	} else {
+		etagAny := azcore.ETagAny
+		conditions.IfNoneMatch = &etagAny
+	}
+
+	result, err := abs.client.UploadStream(azureRetryContext(ctx, abs.writeMaxRetries), abs.containerName, abs.blobName,
+		bytes.NewReader(data), &azblob.UploadStreamOptions{
+			HTTPHeaders: abs.httpHeaders(),
+			AccessConditions: &blob.AccessConditions{
+				ModifiedAccessConditions: conditions,
+			},
+		})
 	if err != nil {
+		if bloberror.HasCode(err, bloberror.ConditionNotMet, bloberror.BlobAlreadyExists) {
+			if err := abs.reload(ctx); err != nil {
+				return fmt.Errorf("failed to reload blob after conflict: %w", err)
+			}
+			return ErrConflict
+		}
+		if isAzureReadOnlyError(err) {
+			// the blob in memory has the rejected change, put it back to what's stored so reads stay accurate
+			if reloadErr := abs.reload(ctx); reloadErr != nil {
+				if !bloberror.HasCode(reloadErr, bloberror.BlobNotFound) {
+					return fmt.Errorf("failed to reload blob after a rejected write: %w", reloadErr)
+				}
+				abs.data = &blobData{
+					Pools:       make(map[string]*Pool),
+					Allocations: make(map[string]*Allocation),
+				}
+			}
+			return fmt.Errorf("failed to upload blob %s to container %s: %w: %w", abs.blobName, abs.containerName, ErrReadOnly, err)
+		}
 		return fmt.Errorf("failed to upload blob: %w", err)
 	}
 
+	if result.ETag != nil {
+		abs.etag = *result.ETag
+	}
 	return nil
 }
 
+// azureRetryContext overrides the client's retries for the requests made with the returned context.
+// 0 returns ctx as is to keep the SDK's default.
+func azureRetryContext(ctx context.Context, maxRetries int) context.Context {
+	if maxRetries == 0 {
+		return ctx
+	}
+	return policy.WithRetryOptions(ctx, policy.RetryOptions{MaxRetries: int32(maxRetries)})
+}
+
+// isAzureReadOnlyError reports whether a write was rejected by the account's permissions or policies rather
+// than failing, e.g. a role that only grants Storage Blob Data Reader or an immutability policy on the container.
+func isAzureReadOnlyError(err error) bool {
+	if bloberror.HasCode(err, bloberror.AuthorizationPermissionMismatch, bloberror.AuthorizationFailure,
+		bloberror.InsufficientAccountPermissions, bloberror.BlobImmutableDueToPolicy, bloberror.LeaseIDMissing,
+		bloberror.AccountIsDisabled, bloberror.ContainerDisabled) {
+		return true
+	}
+
+	var respErr *azcore.ResponseError
+	return errors.As(err, &respErr) && respErr.StatusCode == http.StatusForbidden
+}
+
 func (abs *AzureBlobStorage) GetPool(ctx context.Context, name string) (*Pool, error) {
 	abs.mu.RLock()
 	defer abs.mu.RUnlock()
 
 	pool, exists := abs.data.Pools[name]
 	if !exists {
-		return nil, ErrNotFound
+		return nil, ErrPoolNotFound
 	}
 
 	// return copy
@@ -131,11 +313,24 @@ func (abs *AzureBlobStorage) SavePool(ctx context.Context, pool *Pool) error {
 	abs.mu.Lock()
 	defer abs.mu.Unlock()
 
+	revision, err := nextPoolRevision(abs.data.Pools[pool.Name], pool)
+	if err != nil {
+		return err
+	}
+
 	// save a copy
 	poolCopy := *pool
+	poolCopy.Revision = revision
 	abs.data.Pools[pool.Name] = &poolCopy
 
-	return abs.save(ctx)
+	if !abs.batch.record(savePoolChange(poolCopy)) {
+		if err := abs.save(ctx); err != nil {
+			return err
+		}
+	}
+
+	pool.Revision = revision
+	return nil
 }
 
 func (abs *AzureBlobStorage) DeletePool(ctx context.Context, name string) error {
@@ -143,20 +338,43 @@ func (abs *AzureBlobStorage) DeletePool(ctx context.Context, name string) error
 	defer abs.mu.Unlock()
 
 	if _, exists := abs.data.Pools[name]; !exists {
-		return ErrNotFound
+		return ErrPoolNotFound
 	}
 
 	delete(abs.data.Pools, name)
+	if abs.batch.record(deletePoolChange(name)) {
+		return nil
+	}
 	return abs.save(ctx)
 }
 
-func (abs *AzureBlobStorage) GetAllocation(ctx context.Context, id string) (*Allocation, error) {
+// DeletePoolWithAllocations removes the pool and its allocations in memory and then writes the object
+// once, so they're all deleted or none are.
+func (abs *AzureBlobStorage) DeletePoolWithAllocations(ctx context.Context, name string) error {
+	abs.mu.Lock()
+	defer abs.mu.Unlock()
+
+	if _, exists := abs.data.Pools[name]; !exists {
+		return ErrPoolNotFound
+	}
+
+	change := deletePoolWithAllocationsChange(name)
+	if err := change(abs.data.Pools, abs.data.Allocations); err != nil {
+		return err
+	}
+	if abs.batch.record(change) {
+		return nil
+	}
+	return abs.save(ctx)
+}
+
+func (abs *AzureBlobStorage) GetAllocation(ctx context.Context, key string) (*Allocation, error) {
 	abs.mu.RLock()
 	defer abs.mu.RUnlock()
 
-	allocation, exists := abs.data.Allocations[id]
+	allocation, exists := abs.data.Allocations[key]
 	if !exists {
-		return nil, ErrNotFound
+		return nil, ErrAllocationNotFound
 	}
 
 	// return copy
@@ -191,29 +409,101 @@ func (abs *AzureBlobStorage) ListAllocationsByPool(ctx context.Context, poolName
 	return allocations, nil
 }
 
+func (abs *AzureBlobStorage) Snapshot(ctx context.Context) (*Snapshot, error) {
+	abs.mu.RLock()
+	defer abs.mu.RUnlock()
+
+	// copies of both under the one lock, so no write lands in between
+	snapshot := &Snapshot{
+		Pools:       make([]Pool, 0, len(abs.data.Pools)),
+		Allocations: make([]Allocation, 0, len(abs.data.Allocations)),
+	}
+	for _, pool := range abs.data.Pools {
+		snapshot.Pools = append(snapshot.Pools, *pool)
+	}
+	for _, alloc := range abs.data.Allocations {
+		snapshot.Allocations = append(snapshot.Allocations, *alloc)
+	}
+
+	return snapshot, nil
+}
+
 func (abs *AzureBlobStorage) SaveAllocation(ctx context.Context, allocation *Allocation) error {
 	abs.mu.Lock()
 	defer abs.mu.Unlock()
 
+	allocation.Key = allocation.StorageKey()
 	allocCopy := *allocation
-	abs.data.Allocations[allocation.ID] = &allocCopy
+	abs.data.Allocations[allocation.Key] = &allocCopy
 
+	if abs.batch.record(saveAllocationChange(allocCopy)) {
+		return nil
+	}
 	return abs.save(ctx)
 }
 
-func (abs *AzureBlobStorage) DeleteAllocation(ctx context.Context, id string) error {
+func (abs *AzureBlobStorage) DeleteAllocation(ctx context.Context, key string) error {
 	abs.mu.Lock()
 	defer abs.mu.Unlock()
 
-	if _, exists := abs.data.Allocations[id]; !exists {
-		return ErrNotFound
+	if _, exists := abs.data.Allocations[key]; !exists {
+		return ErrAllocationNotFound
 	}
 
-	delete(abs.data.Allocations, id)
+	delete(abs.data.Allocations, key)
+	if abs.batch.record(deleteAllocationChange(key)) {
+		return nil
+	}
 	return abs.save(ctx)
 }
 
+// Begin batches the writes until Commit, which uploads the blob once instead of once per change.
+func (abs *AzureBlobStorage) Begin(ctx context.Context) error {
+	abs.mu.Lock()
+	defer abs.mu.Unlock()
+
+	abs.batch.active = true
+	return nil
+}
+
+// Commit uploads the blob with the changes made since Begin, applying them again on top of another writer's
+// data if it saved first.
+func (abs *AzureBlobStorage) Commit(ctx context.Context) error {
+	abs.mu.Lock()
+	defer abs.mu.Unlock()
+
+	return commitBatch(abs.batch.end(), func() error {
+		return abs.save(ctx)
+	}, func() (map[string]*Pool, map[string]*Allocation) {
+		return abs.data.Pools, abs.data.Allocations
+	}, func(pools map[string]*Pool, allocations map[string]*Allocation) {
+		abs.data.Pools, abs.data.Allocations = pools, allocations
+	})
+}
+
 func (abs *AzureBlobStorage) Close() error {
-	// Azure SDK doesn't require explicit cleanup
+	// changes are uploaded before the call that made them returns unless writes are batched, so only
+	// an open batch is left to flush. The Azure SDK doesn't require explicit cleanup
+	return abs.Commit(context.Background())
+}
+
+// appendAudit appends to an append blob in the same container, so concurrent writers never overwrite each other.
+func (abs *AzureBlobStorage) appendAudit(ctx context.Context, key string, line []byte) error {
+	client := abs.client.ServiceClient().NewContainerClient(abs.containerName).NewAppendBlobClient(key)
+
+	ctx = azureRetryContext(ctx, abs.writeMaxRetries)
+	etagAny := azcore.ETagAny
+	_, err := client.Create(ctx, &appendblob.CreateOptions{
+		AccessConditions: &blob.AccessConditions{
+			ModifiedAccessConditions: &blob.ModifiedAccessConditions{IfNoneMatch: &etagAny},
+		},
+	})
+	if err != nil && !bloberror.HasCode(err, bloberror.BlobAlreadyExists, bloberror.ConditionNotMet) {
+		return fmt.Errorf("failed to create audit log blob: %w", err)
+	}
+
+	if _, err := client.AppendBlock(ctx, streaming.NopCloser(bytes.NewReader(line)), nil); err != nil {
+		return fmt.Errorf("failed to append to audit log blob: %w", err)
+	}
 	return nil
 }
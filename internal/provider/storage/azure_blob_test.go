@@ -0,0 +1,23 @@
+package storage
+
+import "testing"
+
+func TestAzureBlobStorage_HTTPHeaders(t *testing.T) {
+	abs := &AzureBlobStorage{}
+	headers := abs.httpHeaders()
+	if headers.BlobContentType == nil || *headers.BlobContentType != "application/json" {
+		t.Errorf("default content type = %v, want application/json", headers.BlobContentType)
+	}
+	if headers.BlobCacheControl != nil {
+		t.Errorf("default cache control = %q, want none", *headers.BlobCacheControl)
+	}
+
+	abs.headers = objectHeaders{contentType: "application/vnd.ipam+json", cacheControl: "no-cache"}
+	headers = abs.httpHeaders()
+	if headers.BlobContentType == nil || *headers.BlobContentType != "application/vnd.ipam+json" {
+		t.Errorf("content type = %v, want application/vnd.ipam+json", headers.BlobContentType)
+	}
+	if headers.BlobCacheControl == nil || *headers.BlobCacheControl != "no-cache" {
+		t.Errorf("cache control = %v, want no-cache", headers.BlobCacheControl)
+	}
+}
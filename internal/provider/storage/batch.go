@@ -0,0 +1,155 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"net"
+)
+
+// maximum number of times a commit applies its batch again on top of another writer's data
+const maxCommitAttempts = 5
+
+// batchedChange applies one change made while writes were batched to a store's pools and allocations.
+type batchedChange func(pools map[string]*Pool, allocations map[string]*Allocation) error
+
+// writeBatch holds the changes a JSON blob backend made in memory since Begin. They're uploaded in a
+// single write on Commit, and kept so they can be applied again if another writer saved first.
+type writeBatch struct {
+	active  bool
+	changes []batchedChange
+}
+
+// record keeps a change for the commit, and reports whether writes are batched. When they're not the
+// caller saves the change right away.
+func (b *writeBatch) record(change batchedChange) bool {
+	if !b.active {
+		return false
+	}
+	b.changes = append(b.changes, change)
+	return true
+}
+
+// end stops batching and returns the changes made since Begin.
+func (b *writeBatch) end() []batchedChange {
+	changes := b.changes
+	b.active = false
+	b.changes = nil
+	return changes
+}
+
+// commitBatch saves a backend's data holding the batched changes. When another writer saved first, save
+// reloads its data and returns ErrConflict, and the changes are applied again on top of the reloaded
+// data. They're applied to copies, so a change that no longer applies leaves the reloaded data as is.
+func commitBatch(changes []batchedChange, save func() error, data func() (map[string]*Pool, map[string]*Allocation), setData func(map[string]*Pool, map[string]*Allocation)) error {
+	if len(changes) == 0 {
+		return nil
+	}
+
+	for attempt := 0; attempt < maxCommitAttempts; attempt++ {
+		err := save()
+		if !errors.Is(err, ErrConflict) {
+			return err
+		}
+
+		pools, allocations := data()
+		pools, allocations = maps.Clone(pools), maps.Clone(allocations)
+		for _, change := range changes {
+			if err := change(pools, allocations); err != nil {
+				return fmt.Errorf("batched changes can't be applied on top of another writer's: %w", err)
+			}
+		}
+		setData(pools, allocations)
+	}
+	return ErrConflict
+}
+
+// savePoolChange saves the pool again, as long as nobody else changed it since the batch did.
+func savePoolChange(pool Pool) batchedChange {
+	return func(pools map[string]*Pool, allocations map[string]*Allocation) error {
+		existing := pools[pool.Name]
+		var current int64
+		if existing != nil {
+			current = existing.Revision
+		}
+		if current != pool.Revision-1 {
+			return fmt.Errorf("pool %s is at revision %d but the batch saved revision %d: %w", pool.Name, current, pool.Revision, ErrStaleRevision)
+		}
+		poolCopy := pool
+		pools[pool.Name] = &poolCopy
+		return nil
+	}
+}
+
+func deletePoolChange(name string) batchedChange {
+	return func(pools map[string]*Pool, allocations map[string]*Allocation) error {
+		delete(pools, name)
+		return nil
+	}
+}
+
+// deletePoolWithAllocationsChange deletes the pool and every allocation of it, including allocations of
+// the pool another writer saved in the meantime.
+func deletePoolWithAllocationsChange(name string) batchedChange {
+	return func(pools map[string]*Pool, allocations map[string]*Allocation) error {
+		delete(pools, name)
+		for key, allocation := range allocations {
+			if allocation.PoolName == name {
+				delete(allocations, key)
+			}
+		}
+		return nil
+	}
+}
+
+// saveAllocationChange saves the allocation again, unless another writer allocated an overlapping CIDR of
+// the pool in the meantime.
+func saveAllocationChange(allocation Allocation) batchedChange {
+	return func(pools map[string]*Pool, allocations map[string]*Allocation) error {
+		if !allocation.AllowOverlap && !allocation.Deleted() {
+			if other := overlappingAllocation(allocations, &allocation); other != nil {
+				return fmt.Errorf("allocation %s overlaps allocation %s of pool %s saved by another writer: %w", allocation.ID, other.ID, allocation.PoolName, ErrConflict)
+			}
+		}
+		allocCopy := allocation
+		allocations[allocation.Key] = &allocCopy
+		return nil
+	}
+}
+
+func deleteAllocationChange(key string) batchedChange {
+	return func(pools map[string]*Pool, allocations map[string]*Allocation) error {
+		delete(allocations, key)
+		return nil
+	}
+}
+
+// overlappingAllocation returns another allocation of the same pool with a CIDR overlapping one of the
+// allocation's, or nil when there's none.
+func overlappingAllocation(allocations map[string]*Allocation, allocation *Allocation) *Allocation {
+	var blocks []*net.IPNet
+	for _, cidr := range allocation.CIDRs() {
+		if _, block, err := net.ParseCIDR(cidr); err == nil {
+			blocks = append(blocks, block)
+		}
+	}
+
+	for _, other := range allocations {
+		if other.StorageKey() == allocation.StorageKey() || other.PoolName != allocation.PoolName || other.AllowOverlap || other.Deleted() {
+			continue
+		}
+		for _, cidr := range other.CIDRs() {
+			_, otherBlock, err := net.ParseCIDR(cidr)
+			if err != nil {
+				continue
+			}
+			for _, block := range blocks {
+				// CIDRs either nest or don't overlap
+				if block.Contains(otherBlock.IP) || otherBlock.Contains(block.IP) {
+					return other
+				}
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,129 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFileStorage_BatchedWrites(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "ipam.json")
+
+	fs, err := NewFileStorage(path)
+	if err != nil {
+		t.Fatalf("NewFileStorage: %v", err)
+	}
+	if err := fs.Begin(ctx); err != nil {
+		t.Fatalf("Begin: %v", err)
+	}
+
+	if err := fs.SavePool(ctx, &Pool{Name: "batched", CIDRs: []string{"10.0.0.0/24"}}); err != nil {
+		t.Fatalf("SavePool: %v", err)
+	}
+	for _, allocation := range []Allocation{
+		{ID: "first", PoolName: "batched", AllocatedCIDR: "10.0.0.0/26", PrefixLength: 26},
+		{ID: "second", PoolName: "batched", AllocatedCIDR: "10.0.0.64/26", PrefixLength: 26},
+	} {
+		if err := fs.SaveAllocation(ctx, &allocation); err != nil {
+			t.Fatalf("SaveAllocation %s: %v", allocation.ID, err)
+		}
+	}
+	if err := fs.DeleteAllocation(ctx, "first"); err != nil {
+		t.Fatalf("DeleteAllocation: %v", err)
+	}
+
+	// reads see the batch before anything is written
+	if _, err := fs.GetAllocation(ctx, "second"); err != nil {
+		t.Fatalf("GetAllocation before Commit: %v", err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatalf("storage file was written before Commit: %v", err)
+	}
+
+	if err := fs.Commit(ctx); err != nil {
+		t.Fatalf("Commit: %v", err)
+	}
+
+	reloaded, err := NewFileStorage(path)
+	if err != nil {
+		t.Fatalf("NewFileStorage: %v", err)
+	}
+	allocations, err := reloaded.ListAllocations(ctx)
+	if err != nil {
+		t.Fatalf("ListAllocations: %v", err)
+	}
+	if len(allocations) != 1 || allocations[0].ID != "second" {
+		t.Errorf("committed allocations = %v, want only second", allocations)
+	}
+}
+
+func TestFileStorage_BatchedWritesConflict(t *testing.T) {
+	ctx := context.Background()
+
+	tests := []struct {
+		name    string
+		other   Allocation
+		wantErr error
+	}{
+		{name: "other writer's allocation is kept", other: Allocation{ID: "other", PoolName: "shared", AllocatedCIDR: "10.0.0.128/26"}},
+		{name: "overlapping allocation fails the commit", other: Allocation{ID: "overlap", PoolName: "shared", AllocatedCIDR: "10.0.0.0/27"}, wantErr: ErrConflict},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "ipam.json")
+			setup, err := NewFileStorage(path)
+			if err != nil {
+				t.Fatalf("NewFileStorage: %v", err)
+			}
+			if err := setup.SavePool(ctx, &Pool{Name: "shared", CIDRs: []string{"10.0.0.0/24"}}); err != nil {
+				t.Fatalf("SavePool: %v", err)
+			}
+
+			batched, err := NewFileStorage(path)
+			if err != nil {
+				t.Fatalf("NewFileStorage: %v", err)
+			}
+			other, err := NewFileStorage(path)
+			if err != nil {
+				t.Fatalf("NewFileStorage: %v", err)
+			}
+
+			if err := batched.Begin(ctx); err != nil {
+				t.Fatalf("Begin: %v", err)
+			}
+			mine := Allocation{ID: "mine", PoolName: "shared", AllocatedCIDR: "10.0.0.0/26"}
+			if err := batched.SaveAllocation(ctx, &mine); err != nil {
+				t.Fatalf("SaveAllocation: %v", err)
+			}
+
+			// another writer saves while the batch is open
+			if err := other.SaveAllocation(ctx, &test.other); err != nil {
+				t.Fatalf("SaveAllocation of the other writer: %v", err)
+			}
+
+			err = batched.Commit(ctx)
+			if test.wantErr != nil {
+				if !errors.Is(err, test.wantErr) {
+					t.Fatalf("Commit error = %v, want %v", err, test.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Commit: %v", err)
+			}
+
+			reloaded, err := NewFileStorage(path)
+			if err != nil {
+				t.Fatalf("NewFileStorage: %v", err)
+			}
+			for _, id := range []string{mine.ID, test.other.ID} {
+				if _, err := reloaded.GetAllocation(ctx, id); err != nil {
+					t.Errorf("GetAllocation %s after Commit: %v", id, err)
+				}
+			}
+		})
+	}
+}
@@ -1,18 +1,35 @@
 package storage
 
 import (
+	"bytes"
 	"context"
+	"crypto/sha256"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
 	"sync"
+	"syscall"
+	"time"
 )
 
 type FileStorage struct {
 	filePath string
 	mu       sync.RWMutex
 	data     *fileData
+
+	// write compact JSON instead of indented
+	compactJSON bool
+
+	// how long a write waits for another process's lock on the file, 0 for the default
+	lockMaxWait time.Duration
+
+	// changes held in memory while writes are batched, written together on Commit
+	batch writeBatch
+
+	// checksum of the file contents the data was loaded from, nil if the file doesn't exist yet
+	checksum []byte
 }
 
 type fileData struct {
@@ -24,16 +41,10 @@ type fileData struct {
 
 func NewFileStorage(filePath string) (*FileStorage, error) {
 	if filePath == "" {
-		// default to .terraform directory in current working directory
-		cwd, err := os.Getwd()
-		if err != nil {
-			return nil, fmt.Errorf("failed to get working directory: %w", err)
+		var err error
+		if filePath, err = defaultStoragePath("ipam-storage.json", true); err != nil {
+			return nil, err
 		}
-		terraformDir := filepath.Join(cwd, ".terraform")
-		if err := os.MkdirAll(terraformDir, 0755); err != nil {
-			return nil, fmt.Errorf("failed to create .terraform directory: %w", err)
-		}
-		filePath = filepath.Join(terraformDir, "ipam-storage.json")
 	}
 
 	fs := &FileStorage{
@@ -52,51 +63,171 @@ func NewFileStorage(filePath string) (*FileStorage, error) {
 	return fs, nil
 }
 
+// defaultStoragePath returns the path of fileName in the .terraform directory of the current working
+// directory, creating the directory when create is set.
+func defaultStoragePath(fileName string, create bool) (string, error) {
+	cwd, err := os.Getwd()
+	if err != nil {
+		return "", fmt.Errorf("failed to get working directory: %w", err)
+	}
+	terraformDir := filepath.Join(cwd, ".terraform")
+	if create {
+		if err := os.MkdirAll(terraformDir, 0755); err != nil {
+			return "", fmt.Errorf("failed to create .terraform directory: %w", err)
+		}
+	}
+	return filepath.Join(terraformDir, fileName), nil
+}
+
+// openShard opens the file next to this one that holds a pool's allocations in sharded mode.
+func (fs *FileStorage) openShard(ctx context.Context, poolName string) (Storage, error) {
+	shard, err := NewFileStorage(filepath.FromSlash(shardObjectKey(fs.filePath, poolName)))
+	if err != nil {
+		return nil, err
+	}
+	shard.compactJSON = fs.compactJSON
+	shard.lockMaxWait = fs.lockMaxWait
+	return shard, nil
+}
+
 func (fs *FileStorage) load() error {
 	fs.mu.Lock()
 	defer fs.mu.Unlock()
 
+	return fs.reload()
+}
+
+// reload replaces the in-memory data with the latest file contents. Callers must hold the lock.
+func (fs *FileStorage) reload() error {
 	data, err := os.ReadFile(fs.filePath)
 	if err != nil {
 		return err
 	}
 
-	return json.Unmarshal(data, fs.data)
+	fresh := &fileData{
+		Pools:       make(map[string]*Pool),
+		Allocations: make(map[string]*Allocation),
+	}
+	if err := json.Unmarshal(data, fresh); err != nil {
+		return err
+	}
+	migrateAllocationKeys(fresh.Allocations)
+
+	fs.data = fresh
+	checksum := sha256.Sum256(data)
+	fs.checksum = checksum[:]
+	return nil
+}
+
+// changedOnDisk reports whether another process wrote the file since it was last loaded or saved.
+func (fs *FileStorage) changedOnDisk() bool {
+	current, err := os.ReadFile(fs.filePath)
+	if err != nil {
+		// a missing file is only a change if we had loaded one
+		return !os.IsNotExist(err) || fs.checksum != nil
+	}
+
+	checksum := sha256.Sum256(current)
+	return !bytes.Equal(checksum[:], fs.checksum)
 }
 
-func (fs *FileStorage) save() error {
+func (fs *FileStorage) save(ctx context.Context) error {
 	// make directory if it doesnt exist
 	dir := filepath.Dir(fs.filePath)
 	if err := os.MkdirAll(dir, 0755); err != nil {
-		return fmt.Errorf("failed to create directory: %w", err)
+		return fs.writeError("failed to create directory", err)
 	}
 
-	data, err := json.MarshalIndent(fs.data, "", "  ")
+	data, err := marshalData(fs.data, fs.compactJSON)
 	if err != nil {
 		return fmt.Errorf("failed to marshal storage data: %w", err)
 	}
 
-	// Write to tmp file first, then rename for atomicity
-	tempFile := fs.filePath + ".tmp"
-	if err := os.WriteFile(tempFile, data, 0644); err != nil {
-		return fmt.Errorf("failed to write storage file: %w", err)
+	// processes on this host sharing the file take turns from the check below until the rename, so
+	// none of them can replace the file between another's check and write
+	unlock, err := lockFile(ctx, fs.filePath+".lock", fs.lockMaxWait)
+	if err != nil {
+		return fs.writeError("failed to lock storage file", err)
+	}
+	defer unlock()
+
+	// another process on this host wrote the file since we read it
+	if fs.changedOnDisk() {
+		if err := fs.reload(); err != nil {
+			if !os.IsNotExist(err) {
+				return fmt.Errorf("failed to reload storage file after conflict: %w", err)
+			}
+			// file was removed, start over from empty storage
+			fs.data = &fileData{
+				Pools:       make(map[string]*Pool),
+				Allocations: make(map[string]*Allocation),
+			}
+			fs.checksum = nil
+		}
+		return ErrConflict
 	}
 
-	if err := os.Rename(tempFile, fs.filePath); err != nil {
-		os.Remove(tempFile) // cleanup tmp file on error
-		return fmt.Errorf("failed to rename storage file: %w", err)
+	// Write to a tmp file of our own first, then rename for atomicity
+	if err := writeFileAtomic(fs.filePath, data); err != nil {
+		return fs.writeError("failed to write storage file", err)
 	}
 
+	checksum := sha256.Sum256(data)
+	fs.checksum = checksum[:]
 	return nil
 }
 
+// writeFileAtomic writes data to a new temporary file next to path and renames it into place, so readers
+// see either the old or the new contents and no other writer's temporary file is ever renamed instead.
+func writeFileAtomic(path string, data []byte) error {
+	tempFile, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	// cleanup tmp file on error, after the rename there's nothing left to remove
+	defer os.Remove(tempFile.Name())
+
+	if _, err := tempFile.Write(data); err != nil {
+		tempFile.Close()
+		return err
+	}
+	if err := tempFile.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tempFile.Name(), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tempFile.Name(), path)
+}
+
+// writeError wraps a failed write of the storage file. A read-only filesystem or a directory the provider
+// can't write to is reported as ErrReadOnly, and the rejected change is dropped from memory so reads
+// keep returning what's on disk.
+func (fs *FileStorage) writeError(msg string, err error) error {
+	if !errors.Is(err, os.ErrPermission) && !errors.Is(err, syscall.EROFS) {
+		return fmt.Errorf("%s: %w", msg, err)
+	}
+
+	if reloadErr := fs.reload(); reloadErr != nil {
+		if !os.IsNotExist(reloadErr) {
+			return fmt.Errorf("failed to reload storage file after a rejected write: %w", reloadErr)
+		}
+		fs.data = &fileData{
+			Pools:       make(map[string]*Pool),
+			Allocations: make(map[string]*Allocation),
+		}
+		fs.checksum = nil
+	}
+	return fmt.Errorf("%s: %w: %w", msg, ErrReadOnly, err)
+}
+
 func (fs *FileStorage) GetPool(ctx context.Context, name string) (*Pool, error) {
 	fs.mu.RLock()
 	defer fs.mu.RUnlock()
 
 	pool, exists := fs.data.Pools[name]
 	if !exists {
-		return nil, ErrNotFound
+		return nil, ErrPoolNotFound
 	}
 
 	// returns copy
@@ -121,11 +252,24 @@ func (fs *FileStorage) SavePool(ctx context.Context, pool *Pool) error {
 	fs.mu.Lock()
 	defer fs.mu.Unlock()
 
+	revision, err := nextPoolRevision(fs.data.Pools[pool.Name], pool)
+	if err != nil {
+		return err
+	}
+
 	// make a copy to store
 	poolCopy := *pool
+	poolCopy.Revision = revision
 	fs.data.Pools[pool.Name] = &poolCopy
 
-	return fs.save()
+	if !fs.batch.record(savePoolChange(poolCopy)) {
+		if err := fs.save(ctx); err != nil {
+			return err
+		}
+	}
+
+	pool.Revision = revision
+	return nil
 }
 
 func (fs *FileStorage) DeletePool(ctx context.Context, name string) error {
@@ -133,20 +277,43 @@ func (fs *FileStorage) DeletePool(ctx context.Context, name string) error {
 	defer fs.mu.Unlock()
 
 	if _, exists := fs.data.Pools[name]; !exists {
-		return ErrNotFound
+		return ErrPoolNotFound
 	}
 
 	delete(fs.data.Pools, name)
-	return fs.save()
+	if fs.batch.record(deletePoolChange(name)) {
+		return nil
+	}
+	return fs.save(ctx)
 }
 
-func (fs *FileStorage) GetAllocation(ctx context.Context, id string) (*Allocation, error) {
+// DeletePoolWithAllocations removes the pool and its allocations in memory and then writes the object
+// once, so they're all deleted or none are.
+func (fs *FileStorage) DeletePoolWithAllocations(ctx context.Context, name string) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	if _, exists := fs.data.Pools[name]; !exists {
+		return ErrPoolNotFound
+	}
+
+	change := deletePoolWithAllocationsChange(name)
+	if err := change(fs.data.Pools, fs.data.Allocations); err != nil {
+		return err
+	}
+	if fs.batch.record(change) {
+		return nil
+	}
+	return fs.save(ctx)
+}
+
+func (fs *FileStorage) GetAllocation(ctx context.Context, key string) (*Allocation, error) {
 	fs.mu.RLock()
 	defer fs.mu.RUnlock()
 
-	allocation, exists := fs.data.Allocations[id]
+	allocation, exists := fs.data.Allocations[key]
 	if !exists {
-		return nil, ErrNotFound
+		return nil, ErrAllocationNotFound
 	}
 
 	// Return copy
@@ -181,29 +348,98 @@ func (fs *FileStorage) ListAllocationsByPool(ctx context.Context, poolName strin
 	return allocations, nil
 }
 
+func (fs *FileStorage) Snapshot(ctx context.Context) (*Snapshot, error) {
+	fs.mu.RLock()
+	defer fs.mu.RUnlock()
+
+	// copies of both under the one lock, so no write lands in between
+	snapshot := &Snapshot{
+		Pools:       make([]Pool, 0, len(fs.data.Pools)),
+		Allocations: make([]Allocation, 0, len(fs.data.Allocations)),
+	}
+	for _, pool := range fs.data.Pools {
+		snapshot.Pools = append(snapshot.Pools, *pool)
+	}
+	for _, alloc := range fs.data.Allocations {
+		snapshot.Allocations = append(snapshot.Allocations, *alloc)
+	}
+
+	return snapshot, nil
+}
+
 func (fs *FileStorage) SaveAllocation(ctx context.Context, allocation *Allocation) error {
 	fs.mu.Lock()
 	defer fs.mu.Unlock()
 
+	allocation.Key = allocation.StorageKey()
 	allocCopy := *allocation
-	fs.data.Allocations[allocation.ID] = &allocCopy
+	fs.data.Allocations[allocation.Key] = &allocCopy
 
-	return fs.save()
+	if fs.batch.record(saveAllocationChange(allocCopy)) {
+		return nil
+	}
+	return fs.save(ctx)
 }
 
-func (fs *FileStorage) DeleteAllocation(ctx context.Context, id string) error {
+func (fs *FileStorage) DeleteAllocation(ctx context.Context, key string) error {
 	fs.mu.Lock()
 	defer fs.mu.Unlock()
 
-	if _, exists := fs.data.Allocations[id]; !exists {
-		return ErrNotFound
+	if _, exists := fs.data.Allocations[key]; !exists {
+		return ErrAllocationNotFound
 	}
 
-	delete(fs.data.Allocations, id)
-	return fs.save()
+	delete(fs.data.Allocations, key)
+	if fs.batch.record(deleteAllocationChange(key)) {
+		return nil
+	}
+	return fs.save(ctx)
 }
 
-func (fs *FileStorage) Close() error {
-	// file storage doesn't need any cleanup
+// Ping verifies the directory of the storage file exists, so a mistyped file_path fails before
+// the first save instead of after resources have been planned.
+func (fs *FileStorage) Ping(ctx context.Context) error {
+	dir := filepath.Dir(fs.filePath)
+	info, err := os.Stat(dir)
+	if err != nil {
+		return fmt.Errorf("storage file directory %s is not accessible: %w", dir, err)
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("storage file directory %s is not a directory", dir)
+	}
+	return nil
+}
+
+// Begin batches the writes until Commit, which writes the file once instead of once per change.
+func (fs *FileStorage) Begin(ctx context.Context) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	fs.batch.active = true
 	return nil
 }
+
+// Commit writes the file with the changes made since Begin, applying them again on top of another writer's
+// data if it saved first.
+func (fs *FileStorage) Commit(ctx context.Context) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	return commitBatch(fs.batch.end(), func() error {
+		return fs.save(ctx)
+	}, func() (map[string]*Pool, map[string]*Allocation) {
+		return fs.data.Pools, fs.data.Allocations
+	}, func(pools map[string]*Pool, allocations map[string]*Allocation) {
+		fs.data.Pools, fs.data.Allocations = pools, allocations
+	})
+}
+
+func (fs *FileStorage) Close() error {
+	// changes are written before the call that made them returns unless writes are batched, so only
+	// an open batch is left to flush
+	return fs.Commit(context.Background())
+}
+
+func (fs *FileStorage) appendAudit(ctx context.Context, key string, line []byte) error {
+	return appendAuditFile(key, line)
+}
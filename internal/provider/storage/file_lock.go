@@ -0,0 +1,50 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+)
+
+// lockRetryInterval is how often a write waiting for another process's lock on a storage file tries again
+const lockRetryInterval = 50 * time.Millisecond
+
+// lockFile takes an exclusive lock on the file at path, creating it if needed, and returns the function
+// releasing it. While another process holds the lock it tries again until maxWait runs out, failing with
+// ErrLocked, or ctx is done. A maxWait of 0 waits for the default of 5s.
+func lockFile(ctx context.Context, path string, maxWait time.Duration) (func(), error) {
+	if maxWait <= 0 {
+		maxWait = defaultLockMaxWait
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	deadline := time.NewTimer(maxWait)
+	defer deadline.Stop()
+	for {
+		unlock, err := tryLockFile(f)
+		if err != nil {
+			f.Close()
+			return nil, err
+		}
+		if unlock != nil {
+			return func() {
+				unlock()
+				f.Close()
+			}, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			f.Close()
+			return nil, ctx.Err()
+		case <-deadline.C:
+			f.Close()
+			return nil, fmt.Errorf("%w: %s was held for longer than %s", ErrLocked, path, maxWait)
+		case <-time.After(lockRetryInterval):
+		}
+	}
+}
@@ -0,0 +1,376 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestFileStorage_MigratesAllocationKeys(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "ipam.json")
+
+	// data written before keys, with allocations stored under their id
+	legacy := `{
+  "pools": {"blue": {"name": "blue", "cidrs": ["10.0.0.0/16"]}},
+  "allocations": {"web": {"id": "web", "pool_name": "blue", "allocated_cidr": "10.0.0.0/24", "prefix_length": 24}}
+}`
+	if err := os.WriteFile(path, []byte(legacy), 0644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+
+	fs, err := NewFileStorage(path)
+	if err != nil {
+		t.Fatalf("NewFileStorage: %v", err)
+	}
+	allocation, err := fs.GetAllocation(ctx, "web")
+	if err != nil {
+		t.Fatalf("GetAllocation by the old id: %v", err)
+	}
+	if allocation.Key != "web" {
+		t.Errorf("migrated allocation key = %q, want its id", allocation.Key)
+	}
+
+	// another pool can use the same id under its own key, and the key is written with the data
+	other := Allocation{Key: "other-key", ID: "web", PoolName: "green", AllocatedCIDR: "10.1.0.0/24", PrefixLength: 24}
+	if err := fs.SaveAllocation(ctx, &other); err != nil {
+		t.Fatalf("SaveAllocation with a shared id: %v", err)
+	}
+
+	reloaded, err := NewFileStorage(path)
+	if err != nil {
+		t.Fatalf("NewFileStorage: %v", err)
+	}
+	for _, key := range []string{"web", "other-key"} {
+		allocation, err := reloaded.GetAllocation(ctx, key)
+		if err != nil {
+			t.Fatalf("GetAllocation %s after reloading: %v", key, err)
+		}
+		if allocation.Key != key || allocation.ID != "web" {
+			t.Errorf("allocation %s = %+v, want key %s and id web", key, allocation, key)
+		}
+	}
+}
+
+func TestFileStorage_AllocationMetadata(t *testing.T) {
+	store, err := NewFileStorage(filepath.Join(t.TempDir(), "ipam.json"))
+	if err != nil {
+		t.Fatalf("NewFileStorage: %v", err)
+	}
+	testAllocationMetadataRoundTrip(t, store)
+}
+
+// testAllocationMetadataRoundTrip saves an allocation with metadata, changes it and clears it, and checks
+// that every read returns what was saved.
+func testAllocationMetadataRoundTrip(t *testing.T, store Storage) {
+	t.Helper()
+	ctx := context.Background()
+
+	check := func(want map[string]string) {
+		t.Helper()
+		stored, err := store.GetAllocation(ctx, "web-key")
+		if err != nil {
+			t.Fatalf("GetAllocation: %v", err)
+		}
+		if !maps.Equal(stored.Metadata, want) {
+			t.Errorf("metadata = %v, want %v", stored.Metadata, want)
+		}
+	}
+
+	allocation := &Allocation{
+		Key:           "web-key",
+		ID:            "web",
+		PoolName:      "blue",
+		AllocatedCIDR: "10.0.0.0/24",
+		PrefixLength:  24,
+		Metadata:      map[string]string{"cmdb_id": "CI0012345", "ticket": "NET-42", "note": `{"nested": "json"}`},
+	}
+	if err := store.SaveAllocation(ctx, allocation); err != nil {
+		t.Fatalf("SaveAllocation: %v", err)
+	}
+	check(allocation.Metadata)
+
+	allocation.Metadata = map[string]string{"cmdb_id": "CI0067890"}
+	if err := store.SaveAllocation(ctx, allocation); err != nil {
+		t.Fatalf("SaveAllocation with changed metadata: %v", err)
+	}
+	check(allocation.Metadata)
+
+	allocation.Metadata = nil
+	if err := store.SaveAllocation(ctx, allocation); err != nil {
+		t.Fatalf("SaveAllocation without metadata: %v", err)
+	}
+	check(nil)
+}
+
+func TestFileStorage_Snapshot(t *testing.T) {
+	store, err := NewFileStorage(filepath.Join(t.TempDir(), "ipam.json"))
+	if err != nil {
+		t.Fatalf("NewFileStorage: %v", err)
+	}
+	testSnapshotConsistent(t, store)
+}
+
+// testSnapshotConsistent takes snapshots while another goroutine saves pools each followed by an
+// allocation in it, and checks that no snapshot has an allocation without its pool, and that a
+// snapshot doesn't change with later writes.
+func testSnapshotConsistent(t *testing.T, store Storage) {
+	t.Helper()
+	ctx := context.Background()
+	const writes = 50
+
+	var wg sync.WaitGroup
+	writeErr := make(chan error, 1)
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < writes; i++ {
+			pool := &Pool{Name: fmt.Sprintf("pool-%d", i), CIDRs: []string{fmt.Sprintf("10.%d.0.0/16", i)}}
+			if err := store.SavePool(ctx, pool); err != nil {
+				writeErr <- err
+				return
+			}
+			allocation := &Allocation{ID: fmt.Sprintf("web-%d", i), PoolName: pool.Name, AllocatedCIDR: fmt.Sprintf("10.%d.0.0/24", i), PrefixLength: 24}
+			if err := store.SaveAllocation(ctx, allocation); err != nil {
+				writeErr <- err
+				return
+			}
+		}
+	}()
+
+	var first *Snapshot
+	var firstPools, firstAllocations int
+	for i := 0; i < writes; i++ {
+		snapshot, err := store.Snapshot(ctx)
+		if err != nil {
+			t.Fatalf("Snapshot: %v", err)
+		}
+		if first == nil {
+			first, firstPools, firstAllocations = snapshot, len(snapshot.Pools), len(snapshot.Allocations)
+		}
+		pools := make(map[string]bool, len(snapshot.Pools))
+		for _, pool := range snapshot.Pools {
+			pools[pool.Name] = true
+		}
+		for _, allocation := range snapshot.Allocations {
+			if !pools[allocation.PoolName] {
+				t.Fatalf("snapshot has allocation %s of pool %s but not the pool", allocation.Key, allocation.PoolName)
+			}
+		}
+	}
+	wg.Wait()
+	close(writeErr)
+	if err := <-writeErr; err != nil {
+		t.Fatalf("writer: %v", err)
+	}
+
+	last, err := store.Snapshot(ctx)
+	if err != nil {
+		t.Fatalf("Snapshot: %v", err)
+	}
+	if len(last.Pools) != writes || len(last.Allocations) != writes {
+		t.Errorf("final snapshot has %d pools and %d allocations, want %d of each", len(last.Pools), len(last.Allocations), writes)
+	}
+	if len(first.Pools) != firstPools || len(first.Allocations) != firstAllocations {
+		t.Error("an earlier snapshot changed after later writes")
+	}
+}
+
+func TestFileStorage_DeletePoolWithAllocations(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ipam.json")
+	store, err := NewFileStorage(path)
+	if err != nil {
+		t.Fatalf("NewFileStorage: %v", err)
+	}
+
+	testDeletePoolWithAllocations(t, store, func() func() {
+		// a directory in place of the lock file fails the write
+		if err := os.Remove(path + ".lock"); err != nil {
+			t.Fatalf("Remove: %v", err)
+		}
+		if err := os.Mkdir(path+".lock", 0755); err != nil {
+			t.Fatalf("Mkdir: %v", err)
+		}
+		return func() {
+			if err := os.Remove(path + ".lock"); err != nil {
+				t.Fatalf("Remove: %v", err)
+			}
+		}
+	}, func() Storage {
+		reopened, err := NewFileStorage(path)
+		if err != nil {
+			t.Fatalf("NewFileStorage: %v", err)
+		}
+		return reopened
+	})
+}
+
+func TestFileStorage_ConcurrentWriters(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "ipam.json")
+
+	// every writer has its own view of the file, like separate processes sharing it
+	const writers, writes = 4, 10
+	var wg sync.WaitGroup
+	errs := make(chan error, writers)
+	for w := 0; w < writers; w++ {
+		store, err := NewFileStorage(path)
+		if err != nil {
+			t.Fatalf("NewFileStorage: %v", err)
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < writes; i++ {
+				allocation := &Allocation{
+					Key:           fmt.Sprintf("writer-%d-%d", w, i),
+					ID:            fmt.Sprintf("writer-%d-%d", w, i),
+					PoolName:      "blue",
+					AllocatedCIDR: fmt.Sprintf("10.%d.%d.0/24", w, i),
+					PrefixLength:  24,
+				}
+				// a conflict reloaded the latest file, so saving again applies the write on top of it
+				err := store.SaveAllocation(ctx, allocation)
+				for errors.Is(err, ErrConflict) {
+					err = store.SaveAllocation(ctx, allocation)
+				}
+				if err != nil {
+					errs <- err
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	if err := <-errs; err != nil {
+		t.Fatalf("SaveAllocation: %v", err)
+	}
+
+	reopened, err := NewFileStorage(path)
+	if err != nil {
+		t.Fatalf("NewFileStorage: %v", err)
+	}
+	allocations, err := reopened.ListAllocations(ctx)
+	if err != nil {
+		t.Fatalf("ListAllocations: %v", err)
+	}
+	if len(allocations) != writers*writes {
+		t.Errorf("file has %d allocations, want %d", len(allocations), writers*writes)
+	}
+	if leftover, _ := filepath.Glob(path + ".*.tmp"); len(leftover) != 0 {
+		t.Errorf("temporary files left behind: %v", leftover)
+	}
+}
+
+func TestFileStorage_LockMaxWait(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "ipam.json")
+
+	store, err := NewFileStorage(path)
+	if err != nil {
+		t.Fatalf("NewFileStorage: %v", err)
+	}
+	store.lockMaxWait = 100 * time.Millisecond
+
+	// another process writing the file holds the lock until it's released
+	unlock, err := lockFile(ctx, path+".lock", 0)
+	if err != nil {
+		t.Fatalf("lockFile: %v", err)
+	}
+
+	start := time.Now()
+	err = store.SavePool(ctx, &Pool{Name: "locked", CIDRs: []string{"10.0.0.0/24"}})
+	if !errors.Is(err, ErrLocked) {
+		t.Fatalf("SavePool error = %v, want ErrLocked", err)
+	}
+	if waited := time.Since(start); waited < 100*time.Millisecond {
+		t.Errorf("SavePool failed after %s, before the lock wait ran out", waited)
+	}
+
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	if err := store.SavePool(canceled, &Pool{Name: "canceled", CIDRs: []string{"10.0.0.0/24"}}); !errors.Is(err, context.Canceled) {
+		t.Fatalf("SavePool error = %v, want context.Canceled", err)
+	}
+
+	unlock()
+	if err := store.SavePool(ctx, &Pool{Name: "released", CIDRs: []string{"10.0.0.0/24"}}); err != nil {
+		t.Fatalf("SavePool after the lock was released: %v", err)
+	}
+}
+
+// testDeletePoolWithAllocations deletes a pool with allocations while breakWrites has made the store's
+// writes fail, and checks the reopened store still has the pool and every allocation of it. Once the
+// writes are restored the delete removes them all and leaves other pools alone.
+func testDeletePoolWithAllocations(t *testing.T, store Storage, breakWrites func() (restore func()), reopen func() Storage) {
+	t.Helper()
+	ctx := context.Background()
+
+	for _, pool := range []*Pool{
+		{Name: "blue", CIDRs: []string{"10.0.0.0/16"}},
+		{Name: "green", CIDRs: []string{"10.1.0.0/16"}},
+	} {
+		if err := store.SavePool(ctx, pool); err != nil {
+			t.Fatalf("SavePool: %v", err)
+		}
+	}
+	for _, allocation := range []*Allocation{
+		{Key: "blue-web", ID: "web", PoolName: "blue", AllocatedCIDR: "10.0.0.0/24", PrefixLength: 24},
+		{Key: "blue-db", ID: "db", PoolName: "blue", AllocatedCIDR: "10.0.1.0/24", PrefixLength: 24},
+		{Key: "green-web", ID: "web", PoolName: "green", AllocatedCIDR: "10.1.0.0/24", PrefixLength: 24},
+	} {
+		if err := store.SaveAllocation(ctx, allocation); err != nil {
+			t.Fatalf("SaveAllocation: %v", err)
+		}
+	}
+
+	check := func(store Storage, wantBlue bool, wantBlueAllocations int) {
+		t.Helper()
+		_, err := store.GetPool(ctx, "blue")
+		if wantBlue != (err == nil) {
+			t.Errorf("GetPool blue error = %v, want the pool to exist: %t", err, wantBlue)
+		}
+		blue, err := store.ListAllocationsByPool(ctx, "blue")
+		if err != nil {
+			t.Fatalf("ListAllocationsByPool: %v", err)
+		}
+		if len(blue) != wantBlueAllocations {
+			t.Errorf("pool blue has %d allocations, want %d", len(blue), wantBlueAllocations)
+		}
+		if _, err := store.GetPool(ctx, "green"); err != nil {
+			t.Errorf("GetPool green: %v", err)
+		}
+		green, err := store.ListAllocationsByPool(ctx, "green")
+		if err != nil {
+			t.Fatalf("ListAllocationsByPool: %v", err)
+		}
+		if len(green) != 1 {
+			t.Errorf("pool green has %d allocations, want 1", len(green))
+		}
+	}
+
+	restore := breakWrites()
+	if err := store.DeletePoolWithAllocations(ctx, "blue"); err == nil {
+		t.Fatal("DeletePoolWithAllocations succeeded with failing writes, want an error")
+	}
+	restore()
+	store = reopen()
+	check(store, true, 2)
+
+	if err := store.DeletePoolWithAllocations(ctx, "blue"); err != nil {
+		t.Fatalf("DeletePoolWithAllocations: %v", err)
+	}
+	check(store, false, 0)
+	check(reopen(), false, 0)
+
+	if err := store.DeletePoolWithAllocations(ctx, "blue"); !errors.Is(err, ErrPoolNotFound) {
+		t.Errorf("DeletePoolWithAllocations of a missing pool error = %v, want ErrPoolNotFound", err)
+	}
+}
@@ -2,50 +2,302 @@ package storage
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-log/tflog"
 )
 
 var (
-	ErrNotFound = errors.New("not found")
+	ErrNotFound     = errors.New("not found")
+	ErrAccessDenied = errors.New("access denied")
+
+	// ErrPoolNotFound and ErrAllocationNotFound say which kind of record is missing. Both wrap
+	// ErrNotFound, so callers that don't care can keep checking for that.
+	ErrPoolNotFound       = fmt.Errorf("pool %w", ErrNotFound)
+	ErrAllocationNotFound = fmt.Errorf("allocation %w", ErrNotFound)
+
+	// ErrReadOnly is returned when the backend rejects a write it would accept a read for, e.g. a bucket
+	// policy denying uploads or a read-only database replica. Reads keep working.
+	ErrReadOnly = errors.New("storage is read-only")
+
+	// ErrLocked is returned when another process held the backend's lock for longer than the configured
+	// lock wait, e.g. a long transaction on a shared SQLite database.
+	ErrLocked = errors.New("storage is locked by another process")
+
+	// ErrConflict is returned when a write loses a race with another writer. The backend
+	// reloads the latest data before returning it, so the caller can re-check and retry.
+	ErrConflict = errors.New("storage was modified concurrently")
+
+	// ErrStaleRevision is returned by SavePool when the pool was changed since the revision the write was based on.
+	ErrStaleRevision = errors.New("pool was modified concurrently")
 )
 
 type Pool struct {
 	Name  string   `json:"name"`
 	CIDRs []string `json:"cidrs"`
+
+	// "start-end" address ranges that aren't CIDR aligned, allocated from alongside the CIDRs
+	Ranges []string `json:"ranges,omitempty"`
+
+	// allowed allocation prefix lengths, 0 means no limit
+	MinPrefixLength int `json:"min_prefix_length,omitempty"`
+	MaxPrefixLength int `json:"max_prefix_length,omitempty"`
+	// prefix length of allocations that don't ask for one, 0 when they have to
+	DefaultPrefixLength int `json:"default_prefix_length,omitempty"`
+
+	// blocks of the supernet left out of the CIDRs. Never allocated, only kept so a request for one
+	// of them is rejected as reserved instead of as outside the pool
+	ReservedCIDRs []string `json:"reserved_cidrs,omitempty"`
+
+	// supernet the pool grows into when it runs out of space, in blocks of AutoExpandPrefixLength.
+	// Empty when the pool doesn't grow
+	AutoExpandCIDR         string `json:"auto_expand_cidr,omitempty"`
+	AutoExpandPrefixLength int    `json:"auto_expand_prefix_length,omitempty"`
+	// blocks of AutoExpandCIDR added to the pool when it ran out of space. Kept apart from CIDRs, which
+	// follow the configuration
+	ExpandedCIDRs []string `json:"expanded_cidrs,omitempty"`
+
+	// never hand out the first and last block of each IPv4 CIDR, its all-zeros and all-ones subnets
+	SkipBoundarySubnets bool `json:"skip_boundary_subnets,omitempty"`
+
+	// how a free block is picked for new allocations, "first_fit" or "best_fit". Empty for a pool saved
+	// before strategies were added, which allocates first fit
+	Strategy string `json:"strategy,omitempty"`
+
+	// fill order of the CIDRs, keyed by CIDR. Lower priorities are allocated from first, and CIDRs
+	// without a priority count as 0
+	CIDRPriorities map[string]int `json:"cidr_priorities,omitempty"`
+
+	// incremented on every save, used to reject writes based on a stale read of the pool
+	Revision int64 `json:"revision,omitempty"`
 }
 
 type Allocation struct {
+	// internal key the allocation is stored under, generated when it's first saved so it never changes.
+	// Empty for an allocation saved before keys were added, which is stored under its id, see StorageKey
+	Key string `json:"key,omitempty"`
+	// user-facing label, unique within the pool
 	ID            string `json:"id"`
 	PoolName      string `json:"pool_name"`
 	AllocatedCIDR string `json:"allocated_cidr"`
 	PrefixLength  int    `json:"prefix_length"`
+	Alignment     int    `json:"alignment,omitempty"` // 0 means no alignment constraint
+
+	// every block of an allocation made of several non-contiguous CIDRs, with AllocatedCIDR set to the
+	// first of them. Empty for an allocation of a single CIDR
+	AllocatedCIDRs []string `json:"allocated_cidrs,omitempty"`
+
+	// set when the user asked for a specific CIDR instead of the next free block
+	RequestedCIDR string `json:"requested_cidr,omitempty"`
+	// the requested CIDR was recorded even though it overlaps other allocations in the pool
+	AllowOverlap bool `json:"allow_overlap,omitempty"`
+	// restricts the search to this one of the pool's CIDRs, empty searches them all
+	FromCIDR string `json:"from_cidr,omitempty"`
+	// team or person accountable for the allocation, for chargeback. Empty when not set
+	Owner string `json:"owner,omitempty"`
+	// free-form context attached by whoever provisioned the allocation, e.g. CMDB references. Stored and
+	// returned as is, nothing in the provider reads it
+	Metadata map[string]string `json:"metadata,omitempty"`
+	// set on the allocation of a reservation, the pool created to hand out the reserved block
+	ReservedPool string `json:"reserved_pool,omitempty"`
+
+	// lease of an allocation with a TTL, zero when it never expires. Expired allocations are
+	// only reported, releasing them is left to whoever manages them
+	TTLSeconds int64     `json:"ttl_seconds,omitempty"`
+	ExpiresAt  time.Time `json:"expires_at,omitzero"`
+
+	// when the allocation was released with soft delete enabled. It's kept until it's purged but no
+	// longer holds its CIDR or id, see softDeleteStorage. Zero while the allocation is in use
+	DeletedAt time.Time `json:"deleted_at,omitzero"`
+}
+
+// StorageKey returns the key the allocation is stored under. An allocation without a key is stored
+// under its id, as every allocation was before keys were added.
+func (a *Allocation) StorageKey() string {
+	if a.Key != "" {
+		return a.Key
+	}
+	return a.ID
+}
+
+// CIDRs returns every block the allocation holds.
+func (a *Allocation) CIDRs() []string {
+	if len(a.AllocatedCIDRs) > 0 {
+		return a.AllocatedCIDRs
+	}
+	return []string{a.AllocatedCIDR}
+}
+
+// Deleted reports whether the allocation was soft deleted.
+func (a *Allocation) Deleted() bool {
+	return !a.DeletedAt.IsZero()
+}
+
+// Expired reports whether the allocation has a TTL that ran out before now.
+func (a *Allocation) Expired(now time.Time) bool {
+	return !a.ExpiresAt.IsZero() && !now.Before(a.ExpiresAt)
 }
 
 type Storage interface {
 	// pool operations
 	GetPool(ctx context.Context, name string) (*Pool, error)
 	ListPools(ctx context.Context) ([]Pool, error)
+	// SavePool only writes the pool if pool.Revision matches the stored revision (0 for a new pool),
+	// otherwise it returns ErrStaleRevision. On success pool.Revision is set to the new revision.
 	SavePool(ctx context.Context, pool *Pool) error
 	DeletePool(ctx context.Context, name string) error
+	// DeletePoolWithAllocations deletes the pool together with every allocation of it, in a single write
+	// where the backend supports it: the JSON blob backends rewrite their object once and SQLite uses a
+	// transaction, so a failure leaves the pool and all of its allocations in place. ErrPoolNotFound is
+	// returned when the pool doesn't exist.
+	DeletePoolWithAllocations(ctx context.Context, name string) error
 
-	// allocation operations
-	GetAllocation(ctx context.Context, id string) (*Allocation, error)
+	// allocation operations, by the key the allocation is stored under
+	GetAllocation(ctx context.Context, key string) (*Allocation, error)
 	ListAllocations(ctx context.Context) ([]Allocation, error)
 	ListAllocationsByPool(ctx context.Context, poolName string) ([]Allocation, error)
+	// SaveAllocation stores the allocation under its StorageKey, replacing the allocation already stored
+	// there. On success allocation.Key is set to that key.
 	SaveAllocation(ctx context.Context, allocation *Allocation) error
-	DeleteAllocation(ctx context.Context, id string) error
+	DeleteAllocation(ctx context.Context, key string) error
+
+	// Snapshot returns every pool and allocation as they were at a single point in time. Listing pools
+	// and then allocations can see a write that happened in between, e.g. an allocation of a pool that
+	// wasn't listed, so totals computed from the two may not add up. See Snapshot for the guarantee.
+	Snapshot(ctx context.Context) (*Snapshot, error)
+
+	// Ping checks the backend is reachable with the configured settings, without reading or
+	// writing any pools or allocations. ErrAccessDenied is returned for rejected credentials.
+	Ping(ctx context.Context) error
 
+	// Begin batches the writes that follow until Commit. The JSON blob backends rewrite their whole
+	// object on every write, so they only make the changes in memory, where reads see them, and upload
+	// them once on Commit. Backends with cheap writes of single items keep writing them right away.
+	// Batching is internal to the package: the sharded backend batches each shard while it moves pools
+	// between shards, and syncing a fallback batches the copy. The provider doesn't batch its own
+	// writes, as a store-wide batch would take in the writes of operations running in parallel.
+	Begin(ctx context.Context) error
+	// Commit writes the changes made since Begin and stops batching. When another writer saved in the
+	// meantime the changes are applied again on top of its data, failing with ErrConflict or
+	// ErrStaleRevision for the ones that no longer apply, e.g. an allocation that now overlaps another.
+	Commit(ctx context.Context) error
+
+	// Close writes any changes still held in memory to the backend and releases its resources. The
+	// provider calls it when Terraform shuts the provider down. Calling Close again returns nil.
 	Close() error
 }
 
+// Snapshot is a copy of the storage contents taken at a single point in time. No write is ever partly
+// in it: every allocation in a snapshot was saved before it was taken, along with every pool and
+// allocation saved before that allocation. With sharded storage this holds for each pool's allocations
+// on their own, since every pool's shard is a separate object. Later writes never change a snapshot.
+type Snapshot struct {
+	Pools       []Pool
+	Allocations []Allocation
+}
+
+// Pool returns the pool of the snapshot with the name, or nil when there's none.
+func (s *Snapshot) Pool(name string) *Pool {
+	for i := range s.Pools {
+		if s.Pools[i].Name == name {
+			return &s.Pools[i]
+		}
+	}
+	return nil
+}
+
+// AllocationsByPool returns the allocations of the snapshot in the pool.
+func (s *Snapshot) AllocationsByPool(poolName string) []Allocation {
+	allocations := make([]Allocation, 0)
+	for _, allocation := range s.Allocations {
+		if allocation.PoolName == poolName {
+			allocations = append(allocations, allocation)
+		}
+	}
+	return allocations
+}
+
+// nextPoolRevision checks a pool write against the currently stored pool, which is nil if the
+// pool doesn't exist yet, and returns the revision to store the pool with.
+func nextPoolRevision(existing *Pool, pool *Pool) (int64, error) {
+	var current int64
+	if existing != nil {
+		current = existing.Revision
+	}
+	if pool.Revision != current {
+		return 0, fmt.Errorf("pool %s is at revision %d but the write was based on revision %d: %w", pool.Name, current, pool.Revision, ErrStaleRevision)
+	}
+	return current + 1, nil
+}
+
 type Config struct {
-	Type string // "file", "azure_blob", "aws_s3"
+	Type string // "file", "azure_blob", "aws_s3", "sqlite", "state"
+
+	// Prepended to the blob/object key of the azure_blob and aws_s3 backends
+	KeyPrefix string
+
+	// Write the file, azure_blob and aws_s3 data without indentation to keep large stores small
+	CompactJSON bool
+
+	// Content-Type and Cache-Control headers of the azure_blob and aws_s3 data objects. The content type
+	// defaults to application/json, and no Cache-Control is set when empty
+	ContentType  string
+	CacheControl string
+
+	// Keep each pool's allocations in their own object next to the file, azure_blob or aws_s3
+	// data, so writers to different pools don't conflict. Pools stay in the main object
+	Sharded bool
+
+	// Keeps the pools and allocations apart from those of other namespaces in the same backend, e.g.
+	// one per environment, by storing them under "<namespace>/". Everything is visible when empty
+	Namespace string
+
+	// Bounds every storage operation, including the initial load. 0 means no timeout
+	OperationTimeout time.Duration
+
+	// Log every storage operation at debug level with its key and duration
+	LogOperations bool
+
+	// How long a write waits for a lock another process holds before failing with ErrLocked. Only the
+	// file and sqlite backends lock, the others detect concurrent writes with conditional writes instead.
+	// 0 keeps the default of 5s
+	LockMaxWait time.Duration
+
+	// How many times the azure_blob and aws_s3 backends retry a failed read (loading the data, checking
+	// the bucket or container) or write (uploading the data or audit log). Reads are idempotent and safe
+	// to retry hard, while a retried write is conditional and may need a caller-level retry anyway.
+	// 0 keeps the SDK's default and a negative number disables retries
+	ReadMaxRetries  int
+	WriteMaxRetries int
+
+	// Keep released allocations for this long instead of deleting them, so they can be looked up and
+	// recovered. Disabled when 0
+	SoftDeleteRetention time.Duration
+
+	// Optional JSON lines audit log of allocation changes. A blob/object key for the azure_blob
+	// and aws_s3 backends, a file path for the file and sqlite backends. Disabled when empty.
+	AuditObjectKey string
+
+	// Open the backend for reads only, as the reader package does: the file and sqlite backends create
+	// no directories or files, and the sqlite schema isn't created
+	ReadOnly bool
 
 	// File backend config
 	FilePath string
 
+	// SQLite backend config
+	SQLitePath string
+
 	// Azure Blob Storage config
 	AzureConnectionString string
+	AzureAccountURL       string // Optional: authenticates with Azure AD when there's no connection string
 	AzureContainerName    string
 	AzureBlobName         string
 
@@ -56,19 +308,222 @@ type Config struct {
 	S3AccessKeyID     string // Optional: uses default credential chain if empty
 	S3SecretAccessKey string // Optional: required if S3AccessKeyID is provided
 	S3SessionToken    string // Optional: for temporary credentials
+	S3Profile         string // Optional: shared config profile, used when no access key is provided
+	S3RoleARN         string // Optional: role assumed with the credentials for access to the bucket
+	S3RoleSessionName string // Optional: session name of the assumed role
+	S3ExternalID      string // Optional: external id required by the role's trust policy
+	S3SSE             string // Optional: server-side encryption of written objects, AES256, aws:kms or aws:kms:dsse
+	S3KMSKeyID        string // Optional: KMS key for aws:kms encryption, the AWS managed key when empty
 	S3EndpointURL     string // Optional: for S3 compatible services like MinIO or LocalStack
 	S3SkipTLSVerify   bool   // Optional: skip TLS certificate verification
+
+	// Backends every write is mirrored to, read from in order when this one is unavailable. Each is
+	// configured like this one, apart from the settings that apply to the store as a whole: the audit
+	// log, namespace, operation timeout and their own fallbacks are ignored
+	Fallbacks []Config
+}
+
+// ResolveKeys expands environment variables like ${IPAM_ENV} in the storage
+// file paths and object keys, and prepends KeyPrefix to the blob backend keys.
+// Local file paths are made absolute, see resolveLocalPath.
+func (c *Config) ResolveKeys() {
+	c.FilePath = resolveLocalPath(c.FilePath)
+	c.SQLitePath = resolveLocalPath(c.SQLitePath)
+	c.AzureBlobName = resolveObjectKey(c.KeyPrefix, c.AzureBlobName)
+	c.S3ObjectKey = resolveObjectKey(c.KeyPrefix, c.S3ObjectKey)
+
+	for i := range c.Fallbacks {
+		c.Fallbacks[i].ResolveKeys()
+	}
+
+	if c.AuditObjectKey != "" {
+		switch c.Type {
+		case "azure_blob", "aws_s3":
+			c.AuditObjectKey = resolveObjectKey(c.KeyPrefix, c.AuditObjectKey)
+		default:
+			c.AuditObjectKey = resolveLocalPath(c.AuditObjectKey)
+		}
+	}
+}
+
+// resolveLocalPath replaces ${module_path} with the root module directory, expands environment
+// variables and makes a relative path absolute against the root module directory. Terraform starts
+// the provider in the root module directory, also with -chdir, so that's the working directory, and
+// a relative path means the same file whichever module configures the provider. Empty stays empty,
+// so the backend picks its default.
+func resolveLocalPath(p string) string {
+	if p == "" {
+		return p
+	}
+
+	moduleDir, err := os.Getwd()
+	if err != nil {
+		// the path is left relative, which the backend resolves against the same directory anyway
+		return os.ExpandEnv(p)
+	}
+	p = os.ExpandEnv(strings.ReplaceAll(p, "${module_path}", moduleDir))
+	if !filepath.IsAbs(p) {
+		p = filepath.Join(moduleDir, p)
+	}
+	return p
+}
+
+func resolveObjectKey(prefix, key string) string {
+	if key == "" {
+		key = "ipam-storage.json"
+	}
+	key = os.ExpandEnv(key)
+
+	if prefix == "" {
+		return key
+	}
+	return path.Join(os.ExpandEnv(prefix), key)
+}
+
+// migrateAllocationKeys sets the key of allocations the JSON blob backends loaded from data written before
+// keys were added. Those are stored under their id, which becomes their key, and it's written with them
+// on the next save.
+func migrateAllocationKeys(allocations map[string]*Allocation) {
+	for key, allocation := range allocations {
+		if allocation.Key == "" {
+			allocation.Key = key
+		}
+	}
+}
+
+// defaultLockMaxWait is how long a write waits for another process's lock on the file or sqlite backend
+// by default
+const defaultLockMaxWait = 5 * time.Second
+
+// default Content-Type of the objects the azure_blob and aws_s3 backends upload their data to
+const defaultContentType = "application/json"
+
+// objectHeaders are the HTTP headers the JSON blob backends upload their data with, so it's served and
+// shown as JSON by the object store instead of application/octet-stream. An empty content type means
+// defaultContentType.
+type objectHeaders struct {
+	contentType  string
+	cacheControl string
+}
+
+// marshalData encodes the data of the JSON blob backends, indented unless compact is set.
+func marshalData(v any, compact bool) ([]byte, error) {
+	if compact {
+		return json.Marshal(v)
+	}
+	return json.MarshalIndent(v, "", "  ")
 }
 
 func Factory(ctx context.Context, config *Config) (Storage, error) {
+	if config.OperationTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, config.OperationTimeout)
+		defer cancel()
+	}
+
+	var backend Storage
+	var err error
+	if len(config.Fallbacks) > 0 {
+		backend, err = newMultiStorage(ctx, config)
+	} else {
+		backend, err = openBackend(ctx, config)
+	}
+	if err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			return nil, fmt.Errorf("initializing storage timed out after %s: %w", config.OperationTimeout, err)
+		}
+		return nil, err
+	}
+
+	if config.AuditObjectKey != "" {
+		backend, err = newAuditedStorage(backend, config.AuditObjectKey)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if config.Namespace != "" {
+		backend, err = newNamespacedStorage(backend, config.Namespace)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if config.OperationTimeout > 0 {
+		backend = &timeoutStorage{Storage: backend, timeout: config.OperationTimeout}
+	}
+
+	// outside the timeout, so a timed out operation is logged with the time it waited
+	if config.LogOperations {
+		backend = &loggingStorage{Storage: backend}
+	}
+
+	// outermost, so the provider can find the SoftDeleter
+	if config.SoftDeleteRetention > 0 {
+		backend = &softDeleteStorage{Storage: backend, retention: config.SoftDeleteRetention}
+	}
+	return backend, nil
+}
+
+// openBackend opens the configured backend, sharded when the config asks for it.
+func openBackend(ctx context.Context, config *Config) (Storage, error) {
+	backend, err := newBackend(ctx, config)
+	if err != nil {
+		return nil, err
+	}
+	if config.Sharded {
+		return newShardedStorage(backend)
+	}
+	return backend, nil
+}
+
+func newBackend(ctx context.Context, config *Config) (Storage, error) {
 	switch config.Type {
 	case "file", "": // default to file
-		return NewFileStorage(config.FilePath)
+		filePath := config.FilePath
+		if filePath == "" && config.ReadOnly {
+			var err error
+			if filePath, err = defaultStoragePath("ipam-storage.json", false); err != nil {
+				return nil, err
+			}
+		}
+		fs, err := NewFileStorage(filePath)
+		if err != nil {
+			return nil, err
+		}
+		fs.compactJSON = config.CompactJSON
+		fs.lockMaxWait = config.LockMaxWait
+		tflog.Debug(ctx, "Using storage file", map[string]any{
+			"file_path": fs.filePath,
+		})
+		return fs, nil
 	case "azure_blob":
-		return NewAzureBlobStorage(config.AzureConnectionString, config.AzureContainerName, config.AzureBlobName)
+		abs, err := NewAzureBlobStorage(ctx, config.AzureConnectionString, config.AzureAccountURL, config.AzureContainerName, config.AzureBlobName,
+			config.ReadMaxRetries, config.WriteMaxRetries)
+		if err != nil {
+			return nil, err
+		}
+		abs.compactJSON = config.CompactJSON
+		abs.headers = objectHeaders{contentType: config.ContentType, cacheControl: config.CacheControl}
+		return abs, nil
 	case "aws_s3":
-		return NewS3Storage(config.S3Region, config.S3BucketName, config.S3ObjectKey,
-			config.S3AccessKeyID, config.S3SecretAccessKey, config.S3SessionToken, config.S3EndpointURL, config.S3SkipTLSVerify)
+		s3s, err := NewS3Storage(ctx, config.S3Region, config.S3BucketName, config.S3ObjectKey,
+			config.S3AccessKeyID, config.S3SecretAccessKey, config.S3SessionToken, config.S3Profile,
+			config.S3RoleARN, config.S3RoleSessionName, config.S3ExternalID, config.S3EndpointURL, config.S3SkipTLSVerify,
+			config.ReadMaxRetries, config.WriteMaxRetries)
+		if err != nil {
+			return nil, err
+		}
+		s3s.compactJSON = config.CompactJSON
+		s3s.headers = objectHeaders{contentType: config.ContentType, cacheControl: config.CacheControl}
+		if err := s3s.setS3Encryption(config.S3SSE, config.S3KMSKeyID); err != nil {
+			return nil, err
+		}
+		return s3s, nil
+	case "sqlite":
+		return openSQLiteStorage(config.SQLitePath, config.LockMaxWait, config.ReadOnly)
+	case "state":
+		return NewStateStorage(), nil
 	default:
 		return nil, errors.New("unknown storage type")
 	}
@@ -0,0 +1,127 @@
+package storage
+
+import (
+	"context"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+)
+
+// loggingStorage logs every operation of the wrapped backend at debug level, with the pool name or
+// allocation key it's for and how long it took, to help find out which storage calls slow an apply.
+type loggingStorage struct {
+	Storage
+}
+
+// run calls fn and logs the operation with the fields, its duration and the error it failed with.
+func (ls *loggingStorage) run(ctx context.Context, operation string, fields map[string]any, fn func() error) error {
+	start := time.Now()
+	err := fn()
+
+	if fields == nil {
+		fields = map[string]any{}
+	}
+	fields["operation"] = operation
+	fields["duration_ms"] = time.Since(start).Milliseconds()
+	if err != nil {
+		fields["error"] = err.Error()
+	}
+	tflog.Debug(ctx, "storage operation", fields)
+	return err
+}
+
+func (ls *loggingStorage) GetPool(ctx context.Context, name string) (*Pool, error) {
+	var pool *Pool
+	err := ls.run(ctx, "get pool", map[string]any{"pool_name": name}, func() error {
+		var err error
+		pool, err = ls.Storage.GetPool(ctx, name)
+		return err
+	})
+	return pool, err
+}
+
+func (ls *loggingStorage) ListPools(ctx context.Context) ([]Pool, error) {
+	var pools []Pool
+	err := ls.run(ctx, "list pools", nil, func() error {
+		var err error
+		pools, err = ls.Storage.ListPools(ctx)
+		return err
+	})
+	return pools, err
+}
+
+func (ls *loggingStorage) SavePool(ctx context.Context, pool *Pool) error {
+	return ls.run(ctx, "save pool", map[string]any{"pool_name": pool.Name}, func() error {
+		return ls.Storage.SavePool(ctx, pool)
+	})
+}
+
+func (ls *loggingStorage) DeletePool(ctx context.Context, name string) error {
+	return ls.run(ctx, "delete pool", map[string]any{"pool_name": name}, func() error {
+		return ls.Storage.DeletePool(ctx, name)
+	})
+}
+
+func (ls *loggingStorage) DeletePoolWithAllocations(ctx context.Context, name string) error {
+	return ls.run(ctx, "delete pool with allocations", map[string]any{"pool_name": name}, func() error {
+		return ls.Storage.DeletePoolWithAllocations(ctx, name)
+	})
+}
+
+func (ls *loggingStorage) GetAllocation(ctx context.Context, key string) (*Allocation, error) {
+	var allocation *Allocation
+	err := ls.run(ctx, "get allocation", map[string]any{"key": key}, func() error {
+		var err error
+		allocation, err = ls.Storage.GetAllocation(ctx, key)
+		return err
+	})
+	return allocation, err
+}
+
+func (ls *loggingStorage) ListAllocations(ctx context.Context) ([]Allocation, error) {
+	var allocations []Allocation
+	err := ls.run(ctx, "list allocations", nil, func() error {
+		var err error
+		allocations, err = ls.Storage.ListAllocations(ctx)
+		return err
+	})
+	return allocations, err
+}
+
+func (ls *loggingStorage) ListAllocationsByPool(ctx context.Context, poolName string) ([]Allocation, error) {
+	var allocations []Allocation
+	err := ls.run(ctx, "list allocations", map[string]any{"pool_name": poolName}, func() error {
+		var err error
+		allocations, err = ls.Storage.ListAllocationsByPool(ctx, poolName)
+		return err
+	})
+	return allocations, err
+}
+
+func (ls *loggingStorage) SaveAllocation(ctx context.Context, allocation *Allocation) error {
+	return ls.run(ctx, "save allocation", map[string]any{"key": allocation.StorageKey()}, func() error {
+		return ls.Storage.SaveAllocation(ctx, allocation)
+	})
+}
+
+func (ls *loggingStorage) DeleteAllocation(ctx context.Context, key string) error {
+	return ls.run(ctx, "delete allocation", map[string]any{"key": key}, func() error {
+		return ls.Storage.DeleteAllocation(ctx, key)
+	})
+}
+
+func (ls *loggingStorage) Snapshot(ctx context.Context) (*Snapshot, error) {
+	var snapshot *Snapshot
+	err := ls.run(ctx, "snapshot", nil, func() error {
+		var err error
+		snapshot, err = ls.Storage.Snapshot(ctx)
+		return err
+	})
+	return snapshot, err
+}
+
+func (ls *loggingStorage) Ping(ctx context.Context) error {
+	return ls.run(ctx, "ping", nil, func() error {
+		return ls.Storage.Ping(ctx)
+	})
+}
@@ -0,0 +1,57 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-log/tflogtest"
+)
+
+func TestLoggingStorage(t *testing.T) {
+	var output bytes.Buffer
+	ctx := tflogtest.RootLogger(context.Background(), &output)
+
+	store, err := Factory(ctx, &Config{
+		Type:          "file",
+		FilePath:      filepath.Join(t.TempDir(), "ipam.json"),
+		LogOperations: true,
+	})
+	if err != nil {
+		t.Fatalf("Factory: %v", err)
+	}
+
+	if err := store.SavePool(ctx, &Pool{Name: "blue", CIDRs: []string{"10.0.0.0/16"}}); err != nil {
+		t.Fatalf("SavePool: %v", err)
+	}
+	if _, err := store.GetAllocation(ctx, "missing"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("GetAllocation error = %v, want ErrNotFound", err)
+	}
+
+	entries, err := tflogtest.MultilineJSONDecode(&output)
+	if err != nil {
+		t.Fatalf("decoding log entries: %v", err)
+	}
+	var operations []map[string]any
+	for _, entry := range entries {
+		if entry["@message"] == "storage operation" {
+			operations = append(operations, entry)
+		}
+	}
+	if len(operations) != 2 {
+		t.Fatalf("logged %d storage operations, want 2: %v", len(operations), entries)
+	}
+
+	saved, got := operations[0], operations[1]
+	if saved["@level"] != "debug" || saved["operation"] != "save pool" || saved["pool_name"] != "blue" || saved["error"] != nil {
+		t.Errorf("save entry = %v, want a debug entry for pool blue without an error", saved)
+	}
+	if _, ok := saved["duration_ms"]; !ok {
+		t.Errorf("save entry = %v, want its duration_ms", saved)
+	}
+	if got["operation"] != "get allocation" || got["key"] != "missing" || got["error"] == nil {
+		t.Errorf("get entry = %v, want the key and error of the failed get", got)
+	}
+}
@@ -0,0 +1,398 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+)
+
+// multiStorage puts a primary backend in front of fallbacks, e.g. an S3 bucket mirrored to a local file,
+// so reads keep working when the primary can't be reached.
+//
+// The primary is the source of truth. A write goes to it first, and when it fails the error is returned
+// without touching the fallbacks, so they never hold anything the primary doesn't. A write the primary
+// accepted is mirrored to every fallback on a best-effort basis: a fallback that fails it is missing the
+// change, so it's no longer used until the provider starts again and syncs it with the primary. Reads go
+// to the first backend that answers, in order. When the primary couldn't be opened, writes fail with
+// ErrReadOnly.
+type multiStorage struct {
+	mu sync.Mutex
+	// the primary first, then the fallbacks in the configured order
+	backends []*multiBackend
+}
+
+type multiBackend struct {
+	name    string
+	storage Storage // nil when it couldn't be opened
+	err     error   // why the backend is no longer used, nil while it is
+}
+
+func newMultiStorage(ctx context.Context, config *Config) (Storage, error) {
+	ms := &multiStorage{}
+
+	primary := &multiBackend{name: fmt.Sprintf("primary %s storage", backendType(config))}
+	primary.storage, primary.err = openBackend(ctx, config)
+	if primary.err != nil {
+		tflog.Warn(ctx, "Primary storage backend could not be opened, reading from the fallbacks", map[string]any{
+			"backend": primary.name,
+			"error":   primary.err.Error(),
+		})
+	}
+	ms.backends = append(ms.backends, primary)
+
+	for i := range config.Fallbacks {
+		fallbackConfig := config.Fallbacks[i]
+		fallback := &multiBackend{name: fmt.Sprintf("fallback %d %s storage", i+1, backendType(&fallbackConfig))}
+		fallback.storage, fallback.err = openBackend(ctx, &fallbackConfig)
+		// a fallback is only read from when it holds what the primary does
+		if fallback.err == nil && primary.err == nil {
+			fallback.err = syncFallback(ctx, primary.storage, fallback.storage)
+		}
+		if fallback.err != nil {
+			tflog.Warn(ctx, "Fallback storage backend is not used", map[string]any{
+				"backend": fallback.name,
+				"error":   fallback.err.Error(),
+			})
+		}
+		ms.backends = append(ms.backends, fallback)
+	}
+
+	if primary.err != nil && len(ms.available()) == 0 {
+		ms.Close()
+		return nil, primary.err
+	}
+	return ms, nil
+}
+
+func backendType(config *Config) string {
+	if config.Type == "" {
+		return "file"
+	}
+	return config.Type
+}
+
+// available returns the backends still in use, the primary first.
+func (ms *multiStorage) available() []*multiBackend {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	var backends []*multiBackend
+	for _, backend := range ms.backends {
+		if backend.err == nil {
+			backends = append(backends, backend)
+		}
+	}
+	return backends
+}
+
+// drop stops using a fallback that missed a change of the primary.
+func (ms *multiStorage) drop(ctx context.Context, backend *multiBackend, err error) {
+	ms.mu.Lock()
+	backend.err = err
+	ms.mu.Unlock()
+
+	tflog.Warn(ctx, "Fallback storage backend is out of sync with the primary and no longer used until the provider restarts", map[string]any{
+		"backend": backend.name,
+		"error":   err.Error(),
+	})
+}
+
+// read runs fn against the backends in order until one answers. Not found is an answer, since the
+// backend holds everything the primary does.
+func (ms *multiStorage) read(ctx context.Context, fn func(Storage) error) error {
+	var errs []error
+	for _, backend := range ms.available() {
+		err := fn(backend.storage)
+		if err == nil || errors.Is(err, ErrNotFound) {
+			return err
+		}
+		tflog.Warn(ctx, "Storage backend failed a read, trying the next one", map[string]any{
+			"backend": backend.name,
+			"error":   err.Error(),
+		})
+		errs = append(errs, fmt.Errorf("%s: %w", backend.name, err))
+	}
+	if len(errs) == 0 {
+		return ms.primaryErr()
+	}
+	return errors.Join(errs...)
+}
+
+// primaryErr returns why writes can't go to the primary, or nil when they can.
+func (ms *multiStorage) primaryErr() error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	primary := ms.backends[0]
+	if primary.err != nil {
+		return fmt.Errorf("%s is unavailable, only reads fall back (%v): %w", primary.name, primary.err, ErrReadOnly)
+	}
+	return nil
+}
+
+// write makes a change on the primary and then mirrors it to the fallbacks, dropping those that fail.
+func (ms *multiStorage) write(ctx context.Context, primaryWrite func(Storage) error, mirror func(Storage) error) error {
+	if err := ms.primaryErr(); err != nil {
+		return err
+	}
+
+	backends := ms.available()
+	if err := primaryWrite(backends[0].storage); err != nil {
+		return err
+	}
+	for _, fallback := range backends[1:] {
+		if err := mirror(fallback.storage); err != nil {
+			ms.drop(ctx, fallback, err)
+		}
+	}
+	return nil
+}
+
+func (ms *multiStorage) GetPool(ctx context.Context, name string) (*Pool, error) {
+	var pool *Pool
+	err := ms.read(ctx, func(backend Storage) error {
+		var err error
+		pool, err = backend.GetPool(ctx, name)
+		return err
+	})
+	return pool, err
+}
+
+func (ms *multiStorage) ListPools(ctx context.Context) ([]Pool, error) {
+	var pools []Pool
+	err := ms.read(ctx, func(backend Storage) error {
+		var err error
+		pools, err = backend.ListPools(ctx)
+		return err
+	})
+	return pools, err
+}
+
+func (ms *multiStorage) SavePool(ctx context.Context, pool *Pool) error {
+	return ms.write(ctx, func(primary Storage) error {
+		return primary.SavePool(ctx, pool)
+	}, func(fallback Storage) error {
+		return mirrorPool(ctx, fallback, *pool)
+	})
+}
+
+func (ms *multiStorage) DeletePool(ctx context.Context, name string) error {
+	return ms.write(ctx, func(primary Storage) error {
+		return primary.DeletePool(ctx, name)
+	}, func(fallback Storage) error {
+		return ignoreNotFound(fallback.DeletePool(ctx, name))
+	})
+}
+
+func (ms *multiStorage) DeletePoolWithAllocations(ctx context.Context, name string) error {
+	return ms.write(ctx, func(primary Storage) error {
+		return primary.DeletePoolWithAllocations(ctx, name)
+	}, func(fallback Storage) error {
+		return ignoreNotFound(fallback.DeletePoolWithAllocations(ctx, name))
+	})
+}
+
+func (ms *multiStorage) GetAllocation(ctx context.Context, key string) (*Allocation, error) {
+	var allocation *Allocation
+	err := ms.read(ctx, func(backend Storage) error {
+		var err error
+		allocation, err = backend.GetAllocation(ctx, key)
+		return err
+	})
+	return allocation, err
+}
+
+func (ms *multiStorage) ListAllocations(ctx context.Context) ([]Allocation, error) {
+	var allocations []Allocation
+	err := ms.read(ctx, func(backend Storage) error {
+		var err error
+		allocations, err = backend.ListAllocations(ctx)
+		return err
+	})
+	return allocations, err
+}
+
+func (ms *multiStorage) ListAllocationsByPool(ctx context.Context, poolName string) ([]Allocation, error) {
+	var allocations []Allocation
+	err := ms.read(ctx, func(backend Storage) error {
+		var err error
+		allocations, err = backend.ListAllocationsByPool(ctx, poolName)
+		return err
+	})
+	return allocations, err
+}
+
+func (ms *multiStorage) Snapshot(ctx context.Context) (*Snapshot, error) {
+	var snapshot *Snapshot
+	err := ms.read(ctx, func(backend Storage) error {
+		var err error
+		snapshot, err = backend.Snapshot(ctx)
+		return err
+	})
+	return snapshot, err
+}
+
+func (ms *multiStorage) SaveAllocation(ctx context.Context, allocation *Allocation) error {
+	return ms.write(ctx, func(primary Storage) error {
+		return primary.SaveAllocation(ctx, allocation)
+	}, func(fallback Storage) error {
+		mirrored := *allocation
+		return fallback.SaveAllocation(ctx, &mirrored)
+	})
+}
+
+func (ms *multiStorage) DeleteAllocation(ctx context.Context, key string) error {
+	return ms.write(ctx, func(primary Storage) error {
+		return primary.DeleteAllocation(ctx, key)
+	}, func(fallback Storage) error {
+		return ignoreNotFound(fallback.DeleteAllocation(ctx, key))
+	})
+}
+
+// Ping succeeds when any backend in use answers, so plans keep working while the primary is down.
+func (ms *multiStorage) Ping(ctx context.Context) error {
+	return ms.read(ctx, func(backend Storage) error {
+		return backend.Ping(ctx)
+	})
+}
+
+// Begin and Commit do nothing, writes through the fallbacks are never batched: each is made on the primary
+// and mirrored to the fallbacks before the call returns. syncFallback batches a single fallback on its own.
+func (ms *multiStorage) Begin(ctx context.Context) error {
+	return nil
+}
+
+func (ms *multiStorage) Commit(ctx context.Context) error {
+	return nil
+}
+
+func (ms *multiStorage) Close() error {
+	var errs []error
+	for _, backend := range ms.backends {
+		if backend.storage != nil {
+			if err := backend.storage.Close(); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", backend.name, err))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// appendAudit writes the audit log next to the primary's data, like the changes it records.
+func (ms *multiStorage) appendAudit(ctx context.Context, key string, line []byte) error {
+	if err := ms.primaryErr(); err != nil {
+		return err
+	}
+	appender, ok := ms.backends[0].storage.(auditAppender)
+	if !ok {
+		return fmt.Errorf("storage backend %T doesn't support an audit log", ms.backends[0].storage)
+	}
+	return appender.appendAudit(ctx, key, line)
+}
+
+// syncFallback makes a fallback hold the same pools and allocations as the primary, in a single batch.
+func syncFallback(ctx context.Context, primary, fallback Storage) error {
+	if err := fallback.Begin(ctx); err != nil {
+		return err
+	}
+	if err := copyData(ctx, primary, fallback); err != nil {
+		return errors.Join(err, fallback.Commit(ctx))
+	}
+	return fallback.Commit(ctx)
+}
+
+// copyData saves the pools and allocations of from that to lacks or holds differently, and deletes those
+// from doesn't have. Stale allocations go first, so one saved in their place doesn't clash with them.
+func copyData(ctx context.Context, from, to Storage) error {
+	pools, err := from.ListPools(ctx)
+	if err != nil {
+		return err
+	}
+	existingPools, err := to.ListPools(ctx)
+	if err != nil {
+		return err
+	}
+	stalePools := make(map[string]Pool, len(existingPools))
+	for _, pool := range existingPools {
+		stalePools[pool.Name] = pool
+	}
+	for _, pool := range pools {
+		existing, ok := stalePools[pool.Name]
+		delete(stalePools, pool.Name)
+		// revisions are counted by each backend on its own
+		existing.Revision = pool.Revision
+		if ok && sameData(existing, pool) {
+			continue
+		}
+		if err := mirrorPool(ctx, to, pool); err != nil {
+			return err
+		}
+	}
+
+	allocations, err := from.ListAllocations(ctx)
+	if err != nil {
+		return err
+	}
+	existingAllocations, err := to.ListAllocations(ctx)
+	if err != nil {
+		return err
+	}
+	wanted := make(map[string]Allocation, len(allocations))
+	for _, allocation := range allocations {
+		wanted[allocation.StorageKey()] = allocation
+	}
+	for _, existing := range existingAllocations {
+		allocation, ok := wanted[existing.StorageKey()]
+		if !ok {
+			if err := to.DeleteAllocation(ctx, existing.StorageKey()); err != nil {
+				return err
+			}
+			continue
+		}
+		if sameData(existing, allocation) {
+			delete(wanted, existing.StorageKey())
+		}
+	}
+	for _, allocation := range wanted {
+		if err := to.SaveAllocation(ctx, &allocation); err != nil {
+			return err
+		}
+	}
+
+	for name := range stalePools {
+		if err := to.DeletePool(ctx, name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// mirrorPool saves a pool the primary saved to a fallback, on top of the fallback's own revision of it.
+func mirrorPool(ctx context.Context, fallback Storage, pool Pool) error {
+	pool.Revision = 0
+	existing, err := fallback.GetPool(ctx, pool.Name)
+	if err == nil {
+		pool.Revision = existing.Revision
+	} else if !errors.Is(err, ErrNotFound) {
+		return err
+	}
+	return fallback.SavePool(ctx, &pool)
+}
+
+// sameData reports whether two pools or allocations are stored the same way.
+func sameData(a, b any) bool {
+	aJSON, aErr := json.Marshal(a)
+	bJSON, bErr := json.Marshal(b)
+	return aErr == nil && bErr == nil && string(aJSON) == string(bJSON)
+}
+
+func ignoreNotFound(err error) error {
+	if errors.Is(err, ErrNotFound) {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,117 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// unreachableStorage fails every read, like a backend that stopped responding.
+type unreachableStorage struct {
+	Storage
+}
+
+var errUnreachable = errors.New("connection refused")
+
+func (unreachableStorage) GetPool(ctx context.Context, name string) (*Pool, error) {
+	return nil, errUnreachable
+}
+
+func (unreachableStorage) ListAllocationsByPool(ctx context.Context, poolName string) ([]Allocation, error) {
+	return nil, errUnreachable
+}
+
+func TestMultiStorage_PrimaryDownReadFallback(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	config := &Config{
+		Type:      "file",
+		FilePath:  filepath.Join(dir, "primary.json"),
+		Fallbacks: []Config{{Type: "file", FilePath: filepath.Join(dir, "mirror.json")}},
+	}
+
+	// data the primary held before the mirror was configured is synced to it on start
+	primary, err := NewFileStorage(config.FilePath)
+	if err != nil {
+		t.Fatalf("NewFileStorage: %v", err)
+	}
+	if err := primary.SavePool(ctx, &Pool{Name: "blue", CIDRs: []string{"10.0.0.0/16"}}); err != nil {
+		t.Fatalf("SavePool: %v", err)
+	}
+
+	store, err := Factory(ctx, config)
+	if err != nil {
+		t.Fatalf("Factory: %v", err)
+	}
+	if err := store.SaveAllocation(ctx, &Allocation{Key: "web-key", ID: "web", PoolName: "blue", AllocatedCIDR: "10.0.0.0/24", PrefixLength: 24}); err != nil {
+		t.Fatalf("SaveAllocation: %v", err)
+	}
+	if err := store.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	// the primary can't be loaded anymore
+	if err := os.WriteFile(config.FilePath, []byte("{not json"), 0644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+
+	store, err = Factory(ctx, config)
+	if err != nil {
+		t.Fatalf("Factory with the primary down: %v", err)
+	}
+	defer store.Close()
+
+	if err := store.Ping(ctx); err != nil {
+		t.Errorf("Ping with the primary down: %v", err)
+	}
+	if _, err := store.GetPool(ctx, "blue"); err != nil {
+		t.Errorf("GetPool from the fallback: %v", err)
+	}
+	if allocation, err := store.GetAllocation(ctx, "web-key"); err != nil || allocation.AllocatedCIDR != "10.0.0.0/24" {
+		t.Errorf("GetAllocation from the fallback = %v, %v, want 10.0.0.0/24", allocation, err)
+	}
+	if _, err := store.GetPool(ctx, "missing"); !errors.Is(err, ErrPoolNotFound) {
+		t.Errorf("GetPool of a missing pool error = %v, want %v", err, ErrPoolNotFound)
+	}
+
+	// writes would leave the fallback ahead of the primary
+	if err := store.SavePool(ctx, &Pool{Name: "green", CIDRs: []string{"10.1.0.0/16"}}); !errors.Is(err, ErrReadOnly) {
+		t.Errorf("SavePool with the primary down error = %v, want %v", err, ErrReadOnly)
+	}
+
+	// everything down fails with the primary's error
+	if err := os.WriteFile(config.Fallbacks[0].FilePath, []byte("{not json"), 0644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	if _, err := Factory(ctx, config); err == nil {
+		t.Error("Factory with every backend down succeeded")
+	}
+}
+
+func TestMultiStorage_UnreachablePrimaryRead(t *testing.T) {
+	ctx := context.Background()
+
+	fallback, err := NewFileStorage(filepath.Join(t.TempDir(), "mirror.json"))
+	if err != nil {
+		t.Fatalf("NewFileStorage: %v", err)
+	}
+	if err := fallback.SavePool(ctx, &Pool{Name: "blue", CIDRs: []string{"10.0.0.0/16"}}); err != nil {
+		t.Fatalf("SavePool: %v", err)
+	}
+
+	store := &multiStorage{backends: []*multiBackend{
+		{name: "primary", storage: unreachableStorage{Storage: fallback}},
+		{name: "fallback", storage: fallback},
+	}}
+	if _, err := store.GetPool(ctx, "blue"); err != nil {
+		t.Errorf("GetPool with the primary unreachable: %v", err)
+	}
+
+	// a fallback missing a write is no longer read from
+	store.drop(ctx, store.backends[1], errors.New("mirror write failed"))
+	if _, err := store.GetPool(ctx, "blue"); !errors.Is(err, errUnreachable) {
+		t.Errorf("GetPool without a fallback error = %v, want %v", err, errUnreachable)
+	}
+}
@@ -0,0 +1,153 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// namespacedStorage keeps the pools and allocations of one namespace apart from the rest of the
+// wrapped backend by storing their names, keys and ids as "<namespace>/<name>". Lookups and listings
+// only see the entries of the namespace, with the prefix removed, so e.g. dev and prod can each have a
+// pool called "web" in the same bucket.
+type namespacedStorage struct {
+	Storage
+	prefix string
+}
+
+func newNamespacedStorage(backend Storage, namespace string) (Storage, error) {
+	if strings.Contains(namespace, "/") {
+		return nil, fmt.Errorf("namespace %q can't contain '/'", namespace)
+	}
+	return &namespacedStorage{Storage: backend, prefix: namespace + "/"}, nil
+}
+
+func (ns *namespacedStorage) key(name string) string {
+	return ns.prefix + name
+}
+
+// strip removes the namespace from a stored key, reporting false for keys outside the namespace.
+func (ns *namespacedStorage) strip(key string) (string, bool) {
+	return strings.CutPrefix(key, ns.prefix)
+}
+
+func (ns *namespacedStorage) GetPool(ctx context.Context, name string) (*Pool, error) {
+	pool, err := ns.Storage.GetPool(ctx, ns.key(name))
+	if err != nil {
+		return nil, err
+	}
+	pool.Name = name
+	return pool, nil
+}
+
+func (ns *namespacedStorage) ListPools(ctx context.Context) ([]Pool, error) {
+	stored, err := ns.Storage.ListPools(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	pools := make([]Pool, 0, len(stored))
+	for _, pool := range stored {
+		if name, ok := ns.strip(pool.Name); ok {
+			pool.Name = name
+			pools = append(pools, pool)
+		}
+	}
+	return pools, nil
+}
+
+func (ns *namespacedStorage) SavePool(ctx context.Context, pool *Pool) error {
+	stored := *pool
+	stored.Name = ns.key(pool.Name)
+	if err := ns.Storage.SavePool(ctx, &stored); err != nil {
+		return err
+	}
+	pool.Revision = stored.Revision
+	return nil
+}
+
+func (ns *namespacedStorage) DeletePool(ctx context.Context, name string) error {
+	return ns.Storage.DeletePool(ctx, ns.key(name))
+}
+
+func (ns *namespacedStorage) DeletePoolWithAllocations(ctx context.Context, name string) error {
+	return ns.Storage.DeletePoolWithAllocations(ctx, ns.key(name))
+}
+
+func (ns *namespacedStorage) GetAllocation(ctx context.Context, key string) (*Allocation, error) {
+	allocation, err := ns.Storage.GetAllocation(ctx, ns.key(key))
+	if err != nil {
+		return nil, err
+	}
+	return ns.unwrapAllocation(*allocation), nil
+}
+
+func (ns *namespacedStorage) ListAllocations(ctx context.Context) ([]Allocation, error) {
+	stored, err := ns.Storage.ListAllocations(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	allocations := make([]Allocation, 0, len(stored))
+	for _, allocation := range stored {
+		if _, ok := ns.strip(allocation.PoolName); ok {
+			allocations = append(allocations, *ns.unwrapAllocation(allocation))
+		}
+	}
+	return allocations, nil
+}
+
+func (ns *namespacedStorage) ListAllocationsByPool(ctx context.Context, poolName string) ([]Allocation, error) {
+	stored, err := ns.Storage.ListAllocationsByPool(ctx, ns.key(poolName))
+	if err != nil {
+		return nil, err
+	}
+
+	allocations := make([]Allocation, 0, len(stored))
+	for _, allocation := range stored {
+		allocations = append(allocations, *ns.unwrapAllocation(allocation))
+	}
+	return allocations, nil
+}
+
+func (ns *namespacedStorage) Snapshot(ctx context.Context) (*Snapshot, error) {
+	stored, err := ns.Storage.Snapshot(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	snapshot := &Snapshot{Pools: make([]Pool, 0, len(stored.Pools)), Allocations: make([]Allocation, 0, len(stored.Allocations))}
+	for _, pool := range stored.Pools {
+		if name, ok := ns.strip(pool.Name); ok {
+			pool.Name = name
+			snapshot.Pools = append(snapshot.Pools, pool)
+		}
+	}
+	for _, allocation := range stored.Allocations {
+		if _, ok := ns.strip(allocation.PoolName); ok {
+			snapshot.Allocations = append(snapshot.Allocations, *ns.unwrapAllocation(allocation))
+		}
+	}
+	return snapshot, nil
+}
+
+func (ns *namespacedStorage) SaveAllocation(ctx context.Context, allocation *Allocation) error {
+	allocation.Key = allocation.StorageKey()
+	stored := *allocation
+	stored.Key = ns.key(allocation.Key)
+	stored.ID = ns.key(allocation.ID)
+	stored.PoolName = ns.key(allocation.PoolName)
+	return ns.Storage.SaveAllocation(ctx, &stored)
+}
+
+func (ns *namespacedStorage) DeleteAllocation(ctx context.Context, key string) error {
+	return ns.Storage.DeleteAllocation(ctx, ns.key(key))
+}
+
+// unwrapAllocation returns the allocation with the namespace removed from its key, id and pool name.
+func (ns *namespacedStorage) unwrapAllocation(allocation Allocation) *Allocation {
+	allocation.Key, _ = ns.strip(allocation.Key)
+	allocation.ID, _ = ns.strip(allocation.ID)
+	allocation.PoolName, _ = ns.strip(allocation.PoolName)
+	return &allocation
+}
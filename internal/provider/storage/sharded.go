@@ -0,0 +1,321 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"path"
+	"strings"
+	"sync"
+)
+
+// shardOpener is implemented by the JSON blob backends, which can open another object of the same
+// store to hold the allocations of one pool.
+type shardOpener interface {
+	openShard(ctx context.Context, poolName string) (Storage, error)
+}
+
+// shardedStorage keeps the allocations of each pool in their own object of the wrapped backend, so
+// writers to different pools never rewrite the same object. Pools stay in the wrapped backend's
+// object, along with any allocations saved before the store was sharded. Those are still read and
+// move to their pool's shard the next time they're saved.
+type shardedStorage struct {
+	Storage
+	opener shardOpener
+
+	mu     sync.Mutex
+	shards map[string]Storage
+
+	// set between Begin and Commit, so shards opened in between batch their writes too
+	batching bool
+}
+
+func newShardedStorage(backend Storage) (Storage, error) {
+	opener, ok := backend.(shardOpener)
+	if !ok {
+		return nil, fmt.Errorf("storage backend %T doesn't support sharding", backend)
+	}
+	return &shardedStorage{Storage: backend, opener: opener, shards: make(map[string]Storage)}, nil
+}
+
+// shardObjectKey returns the key of a pool's shard next to the store's key, e.g.
+// "ipam-storage/allocations/my-pool.json" for "ipam-storage.json". The pool name is escaped
+// so every pool gets a single object, whatever characters its name has.
+func shardObjectKey(key, poolName string) string {
+	ext := path.Ext(key)
+	base := strings.TrimSuffix(key, ext)
+	if ext == "" {
+		ext = ".json"
+	}
+	return base + "/allocations/" + url.PathEscape(poolName) + ext
+}
+
+// shard returns the backend holding the allocations of a pool, loading it on first use.
+func (ss *shardedStorage) shard(ctx context.Context, poolName string) (Storage, error) {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+
+	if shard, ok := ss.shards[poolName]; ok {
+		return shard, nil
+	}
+
+	shard, err := ss.opener.openShard(ctx, poolName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open allocations of pool %s: %w", poolName, err)
+	}
+	if ss.batching {
+		if err := shard.Begin(ctx); err != nil {
+			return nil, fmt.Errorf("failed to batch writes to allocations of pool %s: %w", poolName, err)
+		}
+	}
+	ss.shards[poolName] = shard
+	return shard, nil
+}
+
+// locate finds an allocation and the backend holding it, checking the unsharded object first and then
+// the shard of every pool.
+func (ss *shardedStorage) locate(ctx context.Context, key string) (*Allocation, Storage, error) {
+	allocation, err := ss.Storage.GetAllocation(ctx, key)
+	if err == nil {
+		return allocation, ss.Storage, nil
+	}
+	if !errors.Is(err, ErrNotFound) {
+		return nil, nil, err
+	}
+
+	pools, err := ss.Storage.ListPools(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, pool := range pools {
+		shard, err := ss.shard(ctx, pool.Name)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		allocation, err := shard.GetAllocation(ctx, key)
+		if err == nil {
+			return allocation, shard, nil
+		}
+		if !errors.Is(err, ErrNotFound) {
+			return nil, nil, err
+		}
+	}
+
+	return nil, nil, ErrAllocationNotFound
+}
+
+func (ss *shardedStorage) GetAllocation(ctx context.Context, key string) (*Allocation, error) {
+	allocation, _, err := ss.locate(ctx, key)
+	return allocation, err
+}
+
+func (ss *shardedStorage) ListAllocations(ctx context.Context) ([]Allocation, error) {
+	allocations, err := ss.Storage.ListAllocations(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	pools, err := ss.Storage.ListPools(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, pool := range pools {
+		shard, err := ss.shard(ctx, pool.Name)
+		if err != nil {
+			return nil, err
+		}
+
+		shardAllocations, err := shard.ListAllocations(ctx)
+		if err != nil {
+			return nil, err
+		}
+		allocations = append(allocations, shardAllocations...)
+	}
+
+	return allocations, nil
+}
+
+func (ss *shardedStorage) ListAllocationsByPool(ctx context.Context, poolName string) ([]Allocation, error) {
+	allocations, err := ss.Storage.ListAllocationsByPool(ctx, poolName)
+	if err != nil {
+		return nil, err
+	}
+
+	shard, err := ss.shard(ctx, poolName)
+	if err != nil {
+		return nil, err
+	}
+
+	shardAllocations, err := shard.ListAllocations(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return append(allocations, shardAllocations...), nil
+}
+
+// Snapshot takes a snapshot of the wrapped backend's object and then one of every pool's shard. The
+// shards are separate objects, so each pool's allocations are a point-in-time copy of their own.
+func (ss *shardedStorage) Snapshot(ctx context.Context) (*Snapshot, error) {
+	snapshot, err := ss.Storage.Snapshot(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, pool := range snapshot.Pools {
+		shard, err := ss.shard(ctx, pool.Name)
+		if err != nil {
+			return nil, err
+		}
+
+		shardSnapshot, err := shard.Snapshot(ctx)
+		if err != nil {
+			return nil, err
+		}
+		snapshot.Allocations = append(snapshot.Allocations, shardSnapshot.Allocations...)
+	}
+
+	return snapshot, nil
+}
+
+func (ss *shardedStorage) SaveAllocation(ctx context.Context, allocation *Allocation) error {
+	target, err := ss.shard(ctx, allocation.PoolName)
+	if err != nil {
+		return err
+	}
+
+	// an allocation that's already in its pool's shard is a plain update
+	allocation.Key = allocation.StorageKey()
+	if _, err := target.GetAllocation(ctx, allocation.Key); err == nil {
+		return target.SaveAllocation(ctx, allocation)
+	} else if !errors.Is(err, ErrNotFound) {
+		return err
+	}
+
+	// otherwise it's new, moving between pools, or still in the unsharded object
+	_, previous, err := ss.locate(ctx, allocation.Key)
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		return err
+	}
+
+	if err := target.SaveAllocation(ctx, allocation); err != nil {
+		return err
+	}
+
+	if previous != nil {
+		if err := previous.DeleteAllocation(ctx, allocation.Key); err != nil && !errors.Is(err, ErrNotFound) {
+			return fmt.Errorf("saved allocation %s to pool %s but failed to remove its previous copy: %w", allocation.ID, allocation.PoolName, err)
+		}
+	}
+	return nil
+}
+
+func (ss *shardedStorage) DeleteAllocation(ctx context.Context, key string) error {
+	_, holder, err := ss.locate(ctx, key)
+	if err != nil {
+		return err
+	}
+	return holder.DeleteAllocation(ctx, key)
+}
+
+// DeletePoolWithAllocations deletes the allocations in the pool's shard in one write of the shard, and
+// then the pool with any allocations still in the wrapped backend's object in one write of that. The
+// two objects can't be written together, so a failure of the second leaves the pool without the
+// allocations of its shard.
+func (ss *shardedStorage) DeletePoolWithAllocations(ctx context.Context, name string) error {
+	if _, err := ss.Storage.GetPool(ctx, name); err != nil {
+		return err
+	}
+
+	shard, err := ss.shard(ctx, name)
+	if err != nil {
+		return err
+	}
+	if err := ss.clearShard(ctx, shard); err != nil {
+		return fmt.Errorf("failed to delete allocations of pool %s: %w", name, err)
+	}
+	return ss.Storage.DeletePoolWithAllocations(ctx, name)
+}
+
+// clearShard deletes every allocation of a shard, batching the deletes into a single write unless the
+// shard's writes are already batched until Commit.
+func (ss *shardedStorage) clearShard(ctx context.Context, shard Storage) error {
+	allocations, err := shard.ListAllocations(ctx)
+	if err != nil || len(allocations) == 0 {
+		return err
+	}
+
+	ss.mu.Lock()
+	batching := ss.batching
+	ss.mu.Unlock()
+	if !batching {
+		if err := shard.Begin(ctx); err != nil {
+			return err
+		}
+	}
+
+	var errs []error
+	for _, allocation := range allocations {
+		if err := shard.DeleteAllocation(ctx, allocation.StorageKey()); err != nil && !errors.Is(err, ErrNotFound) {
+			errs = append(errs, err)
+		}
+	}
+	if !batching {
+		errs = append(errs, shard.Commit(ctx))
+	}
+	return errors.Join(errs...)
+}
+
+// Begin batches the writes to the wrapped backend and to every shard, including the ones opened later.
+func (ss *shardedStorage) Begin(ctx context.Context) error {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+
+	ss.batching = true
+	for poolName, shard := range ss.shards {
+		if err := shard.Begin(ctx); err != nil {
+			return fmt.Errorf("failed to batch writes to allocations of pool %s: %w", poolName, err)
+		}
+	}
+	return ss.Storage.Begin(ctx)
+}
+
+// Commit writes the batched changes of every shard and then of the wrapped backend.
+func (ss *shardedStorage) Commit(ctx context.Context) error {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+
+	ss.batching = false
+	var errs []error
+	for poolName, shard := range ss.shards {
+		if err := shard.Commit(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("failed to commit allocations of pool %s: %w", poolName, err))
+		}
+	}
+	errs = append(errs, ss.Storage.Commit(ctx))
+	return errors.Join(errs...)
+}
+
+func (ss *shardedStorage) Close() error {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+
+	var errs []error
+	for _, shard := range ss.shards {
+		errs = append(errs, shard.Close())
+	}
+	// forget the closed shards, so closing again only closes the wrapped backend, which returns nil
+	ss.shards = make(map[string]Storage)
+	errs = append(errs, ss.Storage.Close())
+	return errors.Join(errs...)
+}
+
+// appendAudit writes the audit log through the wrapped backend, so it stays a single log for the whole store.
+func (ss *shardedStorage) appendAudit(ctx context.Context, key string, line []byte) error {
+	appender, ok := ss.Storage.(auditAppender)
+	if !ok {
+		return fmt.Errorf("storage backend %T doesn't support an audit log", ss.Storage)
+	}
+	return appender.appendAudit(ctx, key, line)
+}
@@ -0,0 +1,127 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// SoftDeleter is implemented by the storage when soft delete is enabled with Config.SoftDeleteRetention.
+type SoftDeleter interface {
+	// ListDeletedAllocations returns the soft deleted allocations that haven't been purged yet.
+	ListDeletedAllocations(ctx context.Context) ([]Allocation, error)
+	// PurgeAllocation deletes a soft deleted allocation for good. ErrAllocationNotFound is returned for an
+	// allocation that isn't soft deleted.
+	PurgeAllocation(ctx context.Context, key string) error
+	// Retention is how long soft deleted allocations are kept before they're due to be purged.
+	Retention() time.Duration
+}
+
+// softDeleteStorage keeps released allocations instead of deleting them, as a record of what was released
+// and to recover from an accidental release. DeleteAllocation saves the allocation again with DeletedAt set,
+// and lookups and listings leave it out, so its CIDR and id are free to be allocated again. The backends
+// leave soft deleted allocations out of their own uniqueness checks too.
+type softDeleteStorage struct {
+	Storage
+	retention time.Duration
+}
+
+func (sd *softDeleteStorage) GetAllocation(ctx context.Context, key string) (*Allocation, error) {
+	allocation, err := sd.Storage.GetAllocation(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	if allocation.Deleted() {
+		return nil, ErrAllocationNotFound
+	}
+	return allocation, nil
+}
+
+func (sd *softDeleteStorage) ListAllocations(ctx context.Context) ([]Allocation, error) {
+	allocations, err := sd.Storage.ListAllocations(ctx)
+	return inUse(allocations), err
+}
+
+func (sd *softDeleteStorage) ListAllocationsByPool(ctx context.Context, poolName string) ([]Allocation, error) {
+	allocations, err := sd.Storage.ListAllocationsByPool(ctx, poolName)
+	return inUse(allocations), err
+}
+
+func (sd *softDeleteStorage) Snapshot(ctx context.Context) (*Snapshot, error) {
+	snapshot, err := sd.Storage.Snapshot(ctx)
+	if err != nil {
+		return nil, err
+	}
+	snapshot.Allocations = inUse(snapshot.Allocations)
+	return snapshot, nil
+}
+
+func (sd *softDeleteStorage) DeleteAllocation(ctx context.Context, key string) error {
+	allocation, err := sd.GetAllocation(ctx, key)
+	if err != nil {
+		return err
+	}
+	allocation.DeletedAt = time.Now().UTC()
+	return sd.Storage.SaveAllocation(ctx, allocation)
+}
+
+// DeletePoolWithAllocations soft deletes the pool's allocations one by one and then deletes the pool, so
+// unlike the backends' own delete it isn't a single write. A failure part way leaves the pool in place with
+// some of its allocations released, which can be recovered since they're kept.
+func (sd *softDeleteStorage) DeletePoolWithAllocations(ctx context.Context, name string) error {
+	if _, err := sd.Storage.GetPool(ctx, name); err != nil {
+		return err
+	}
+
+	allocations, err := sd.ListAllocationsByPool(ctx, name)
+	if err != nil {
+		return err
+	}
+	for _, allocation := range allocations {
+		if err := sd.DeleteAllocation(ctx, allocation.StorageKey()); err != nil && !errors.Is(err, ErrNotFound) {
+			return err
+		}
+	}
+	return sd.Storage.DeletePool(ctx, name)
+}
+
+func (sd *softDeleteStorage) ListDeletedAllocations(ctx context.Context) ([]Allocation, error) {
+	allocations, err := sd.Storage.ListAllocations(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	deleted := make([]Allocation, 0)
+	for _, allocation := range allocations {
+		if allocation.Deleted() {
+			deleted = append(deleted, allocation)
+		}
+	}
+	return deleted, nil
+}
+
+func (sd *softDeleteStorage) PurgeAllocation(ctx context.Context, key string) error {
+	allocation, err := sd.Storage.GetAllocation(ctx, key)
+	if err != nil {
+		return err
+	}
+	if !allocation.Deleted() {
+		return ErrAllocationNotFound
+	}
+	return sd.Storage.DeleteAllocation(ctx, key)
+}
+
+func (sd *softDeleteStorage) Retention() time.Duration {
+	return sd.retention
+}
+
+// inUse returns the allocations that aren't soft deleted.
+func inUse(allocations []Allocation) []Allocation {
+	kept := allocations[:0:0]
+	for _, allocation := range allocations {
+		if !allocation.Deleted() {
+			kept = append(kept, allocation)
+		}
+	}
+	return kept
+}
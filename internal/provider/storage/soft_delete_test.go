@@ -0,0 +1,118 @@
+package storage
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestSoftDeleteStorage(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	auditPath := filepath.Join(dir, "audit.jsonl")
+
+	store, err := Factory(ctx, &Config{
+		Type:                "file",
+		FilePath:            filepath.Join(dir, "ipam.json"),
+		AuditObjectKey:      auditPath,
+		SoftDeleteRetention: time.Hour,
+	})
+	if err != nil {
+		t.Fatalf("Factory: %v", err)
+	}
+	softDeleter, ok := store.(SoftDeleter)
+	if !ok {
+		t.Fatalf("storage %T isn't a SoftDeleter", store)
+	}
+	if softDeleter.Retention() != time.Hour {
+		t.Errorf("Retention = %s, want 1h", softDeleter.Retention())
+	}
+
+	released := Allocation{Key: "released", ID: "web", PoolName: "blue", AllocatedCIDR: "10.0.0.0/24", PrefixLength: 24}
+	if err := store.SaveAllocation(ctx, &released); err != nil {
+		t.Fatalf("SaveAllocation: %v", err)
+	}
+	if err := store.DeleteAllocation(ctx, released.Key); err != nil {
+		t.Fatalf("DeleteAllocation: %v", err)
+	}
+
+	// the released allocation is gone for everything but the deleted listing
+	if _, err := store.GetAllocation(ctx, released.Key); !errors.Is(err, ErrAllocationNotFound) {
+		t.Errorf("GetAllocation of a deleted allocation error = %v, want %v", err, ErrAllocationNotFound)
+	}
+	if allocations, err := store.ListAllocationsByPool(ctx, "blue"); err != nil || len(allocations) != 0 {
+		t.Errorf("ListAllocationsByPool = %v, %v, want none", allocations, err)
+	}
+	if snapshot, err := store.Snapshot(ctx); err != nil || len(snapshot.Allocations) != 0 {
+		t.Errorf("Snapshot = %+v, %v, want no allocations", snapshot, err)
+	}
+	if err := store.DeleteAllocation(ctx, released.Key); !errors.Is(err, ErrAllocationNotFound) {
+		t.Errorf("DeleteAllocation of a deleted allocation error = %v, want %v", err, ErrAllocationNotFound)
+	}
+	deleted, err := softDeleter.ListDeletedAllocations(ctx)
+	if err != nil {
+		t.Fatalf("ListDeletedAllocations: %v", err)
+	}
+	if len(deleted) != 1 || deleted[0].Key != released.Key || deleted[0].DeletedAt.IsZero() {
+		t.Fatalf("ListDeletedAllocations = %+v, want the released allocation with its deletion time", deleted)
+	}
+
+	// its CIDR and id can be allocated again
+	reused := Allocation{Key: "reused", ID: "web", PoolName: "blue", AllocatedCIDR: "10.0.0.0/24", PrefixLength: 24}
+	if err := store.SaveAllocation(ctx, &reused); err != nil {
+		t.Fatalf("SaveAllocation of the released CIDR: %v", err)
+	}
+
+	if err := softDeleter.PurgeAllocation(ctx, reused.Key); !errors.Is(err, ErrAllocationNotFound) {
+		t.Errorf("PurgeAllocation of an allocation in use error = %v, want %v", err, ErrAllocationNotFound)
+	}
+	if err := softDeleter.PurgeAllocation(ctx, released.Key); err != nil {
+		t.Fatalf("PurgeAllocation: %v", err)
+	}
+	if deleted, err := softDeleter.ListDeletedAllocations(ctx); err != nil || len(deleted) != 0 {
+		t.Errorf("ListDeletedAllocations after purging = %v, %v, want none", deleted, err)
+	}
+
+	// deleting the pool with its allocations releases them as well, so they're kept too
+	if err := store.SavePool(ctx, &Pool{Name: "blue", CIDRs: []string{"10.0.0.0/16"}}); err != nil {
+		t.Fatalf("SavePool: %v", err)
+	}
+	if err := store.DeletePoolWithAllocations(ctx, "blue"); err != nil {
+		t.Fatalf("DeletePoolWithAllocations: %v", err)
+	}
+	if _, err := store.GetPool(ctx, "blue"); !errors.Is(err, ErrPoolNotFound) {
+		t.Errorf("GetPool of the deleted pool error = %v, want %v", err, ErrPoolNotFound)
+	}
+	deleted, err = softDeleter.ListDeletedAllocations(ctx)
+	if err != nil {
+		t.Fatalf("ListDeletedAllocations: %v", err)
+	}
+	if len(deleted) != 1 || deleted[0].Key != reused.Key {
+		t.Errorf("ListDeletedAllocations after deleting the pool = %+v, want the allocation of the pool", deleted)
+	}
+
+	f, err := os.Open(auditPath)
+	if err != nil {
+		t.Fatalf("Open audit log: %v", err)
+	}
+	defer f.Close()
+	var actions []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var entry AuditEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			t.Fatalf("Unmarshal audit entry: %v", err)
+		}
+		actions = append(actions, entry.Action+" "+entry.Key)
+	}
+	want := []string{"allocate released", "release released", "allocate reused", "purge released", "release reused"}
+	if !slices.Equal(actions, want) {
+		t.Errorf("audit log = %v, want %v", actions, want)
+	}
+}
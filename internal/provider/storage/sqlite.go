@@ -0,0 +1,565 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+type SQLiteStorage struct {
+	db *sql.DB
+}
+
+const sqliteSchema = `
+CREATE TABLE IF NOT EXISTS pools (
+	name              TEXT PRIMARY KEY,
+	cidrs             TEXT NOT NULL,
+	ranges            TEXT NOT NULL DEFAULT '[]',
+	min_prefix_length INTEGER NOT NULL DEFAULT 0,
+	max_prefix_length INTEGER NOT NULL DEFAULT 0,
+	revision          INTEGER NOT NULL DEFAULT 0,
+	cidr_priorities   TEXT NOT NULL DEFAULT '{}',
+	reserved_cidrs    TEXT NOT NULL DEFAULT '[]',
+	auto_expand_cidr  TEXT NOT NULL DEFAULT '',
+	auto_expand_prefix_length INTEGER NOT NULL DEFAULT 0,
+	expanded_cidrs    TEXT NOT NULL DEFAULT '[]',
+	skip_boundary_subnets INTEGER NOT NULL DEFAULT 0,
+	strategy          TEXT NOT NULL DEFAULT '',
+	default_prefix_length INTEGER NOT NULL DEFAULT 0
+);
+
+-- allocations are keyed by their storage key, ids only have to be unique within a pool
+CREATE TABLE IF NOT EXISTS allocations (
+	key             TEXT PRIMARY KEY,
+	id              TEXT NOT NULL,
+	pool_name       TEXT NOT NULL,
+	allocated_cidr  TEXT NOT NULL,
+	prefix_length   INTEGER NOT NULL,
+	alignment       INTEGER NOT NULL DEFAULT 0,
+	requested_cidr  TEXT NOT NULL DEFAULT '',
+	allow_overlap   INTEGER NOT NULL DEFAULT 0,
+	from_cidr       TEXT NOT NULL DEFAULT '',
+	owner           TEXT NOT NULL DEFAULT '',
+	metadata        TEXT NOT NULL DEFAULT '{}',
+	ttl_seconds     INTEGER NOT NULL DEFAULT 0,
+	expires_at      INTEGER NOT NULL DEFAULT 0,
+	allocated_cidrs TEXT NOT NULL DEFAULT '[]',
+	reserved_pool   TEXT NOT NULL DEFAULT '',
+	deleted_at      INTEGER NOT NULL DEFAULT 0
+);
+
+-- a CIDR can only be claimed once per pool, unless the allocation explicitly allows overlaps. Soft deleted
+-- allocations have released theirs
+CREATE UNIQUE INDEX IF NOT EXISTS allocations_pool_cidr ON allocations (pool_name, allocated_cidr) WHERE allow_overlap = 0 AND deleted_at = 0;
+-- ids are labels unique within their pool
+CREATE UNIQUE INDEX IF NOT EXISTS allocations_pool_id ON allocations (pool_name, id) WHERE deleted_at = 0;
+`
+
+const poolColumns = `name, cidrs, ranges, min_prefix_length, max_prefix_length, revision, cidr_priorities, reserved_cidrs, auto_expand_cidr, auto_expand_prefix_length, expanded_cidrs, skip_boundary_subnets, strategy, default_prefix_length`
+
+// expires_at and deleted_at are stored as unix seconds, 0 for an allocation that never expires or isn't deleted
+const allocationColumns = `key, id, pool_name, allocated_cidr, prefix_length, alignment, requested_cidr, allow_overlap, from_cidr, owner, metadata, ttl_seconds, expires_at, allocated_cidrs, reserved_pool, deleted_at`
+
+// NewSQLiteStorage creates a new SQLite Storage backend
+// filePath: Path to the SQLite database file, created if it doesn't exist (defaults to .terraform/ipam-storage.db)
+//
+// The database runs in WAL mode and every mutation is its own transaction, so several
+// processes on the same host can share the file safely.
+func NewSQLiteStorage(filePath string, lockMaxWait time.Duration) (*SQLiteStorage, error) {
+	return openSQLiteStorage(filePath, lockMaxWait, false)
+}
+
+// openSQLiteStorage opens the database like NewSQLiteStorage, or when readOnly is set opens an existing
+// database read-only, without creating the file, its directory or its schema.
+func openSQLiteStorage(filePath string, lockMaxWait time.Duration, readOnly bool) (*SQLiteStorage, error) {
+	if filePath == "" {
+		var err error
+		if filePath, err = defaultStoragePath("ipam-storage.db", !readOnly); err != nil {
+			return nil, err
+		}
+	}
+
+	if lockMaxWait <= 0 {
+		lockMaxWait = defaultLockMaxWait
+	}
+
+	if readOnly {
+		// the journal mode is the database's own, set by the provider when it created it
+		dsn := fmt.Sprintf("file:%s?mode=ro&_pragma=busy_timeout(%d)", filePath, lockMaxWait.Milliseconds())
+		db, err := sql.Open("sqlite", dsn)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open sqlite database: %w", err)
+		}
+		return &SQLiteStorage{db: db}, nil
+	}
+
+	// immediate transactions take the write lock up front, so concurrent writers wait on the
+	// busy timeout instead of failing when they try to upgrade a read lock
+	dsn := fmt.Sprintf("file:%s?_pragma=journal_mode(WAL)&_pragma=busy_timeout(%d)&_txlock=immediate", filePath, lockMaxWait.Milliseconds())
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open sqlite database: %w", err)
+	}
+
+	if _, err := db.Exec(sqliteSchema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to create sqlite schema: %w", err)
+	}
+
+	return &SQLiteStorage{db: db}, nil
+}
+
+func (ss *SQLiteStorage) GetPool(ctx context.Context, name string) (*Pool, error) {
+	row := ss.db.QueryRowContext(ctx,
+		`SELECT `+poolColumns+` FROM pools WHERE name = ?`, name)
+
+	pool, err := scanPool(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrPoolNotFound
+	}
+	return pool, err
+}
+
+func (ss *SQLiteStorage) ListPools(ctx context.Context) ([]Pool, error) {
+	return queryPools(ctx, ss.db)
+}
+
+// queryPools reads every pool through the database or a transaction.
+func queryPools(ctx context.Context, db sqliteQuerier) ([]Pool, error) {
+	rows, err := db.QueryContext(ctx, `SELECT `+poolColumns+` FROM pools`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query pools: %w", err)
+	}
+	defer rows.Close()
+
+	pools := make([]Pool, 0)
+	for rows.Next() {
+		pool, err := scanPool(rows)
+		if err != nil {
+			return nil, err
+		}
+		pools = append(pools, *pool)
+	}
+
+	return pools, rows.Err()
+}
+
+func (ss *SQLiteStorage) SavePool(ctx context.Context, pool *Pool) error {
+	cidrs, err := json.Marshal(pool.CIDRs)
+	if err != nil {
+		return fmt.Errorf("failed to marshal pool cidrs: %w", err)
+	}
+
+	ranges, err := json.Marshal(pool.Ranges)
+	if err != nil {
+		return fmt.Errorf("failed to marshal pool ranges: %w", err)
+	}
+
+	priorities, err := json.Marshal(pool.CIDRPriorities)
+	if err != nil {
+		return fmt.Errorf("failed to marshal pool cidr priorities: %w", err)
+	}
+
+	reserved, err := json.Marshal(pool.ReservedCIDRs)
+	if err != nil {
+		return fmt.Errorf("failed to marshal pool reserved cidrs: %w", err)
+	}
+
+	expanded, err := json.Marshal(pool.ExpandedCIDRs)
+	if err != nil {
+		return fmt.Errorf("failed to marshal pool expanded cidrs: %w", err)
+	}
+
+	tx, err := ss.db.BeginTx(ctx, nil)
+	if err != nil {
+		return sqliteWriteError("failed to begin transaction", err)
+	}
+	defer tx.Rollback()
+
+	var existing *Pool
+	var currentRevision int64
+	err = tx.QueryRowContext(ctx, `SELECT revision FROM pools WHERE name = ?`, pool.Name).Scan(&currentRevision)
+	if err == nil {
+		existing = &Pool{Name: pool.Name, Revision: currentRevision}
+	} else if !errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("failed to read pool revision: %w", err)
+	}
+
+	revision, err := nextPoolRevision(existing, pool)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO pools (name, cidrs, ranges, min_prefix_length, max_prefix_length, revision, cidr_priorities, reserved_cidrs,
+			auto_expand_cidr, auto_expand_prefix_length, expanded_cidrs, skip_boundary_subnets, strategy, default_prefix_length)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (name) DO UPDATE SET
+			cidrs = excluded.cidrs,
+			ranges = excluded.ranges,
+			min_prefix_length = excluded.min_prefix_length,
+			max_prefix_length = excluded.max_prefix_length,
+			revision = excluded.revision,
+			cidr_priorities = excluded.cidr_priorities,
+			reserved_cidrs = excluded.reserved_cidrs,
+			auto_expand_cidr = excluded.auto_expand_cidr,
+			auto_expand_prefix_length = excluded.auto_expand_prefix_length,
+			expanded_cidrs = excluded.expanded_cidrs,
+			skip_boundary_subnets = excluded.skip_boundary_subnets,
+			strategy = excluded.strategy,
+			default_prefix_length = excluded.default_prefix_length`,
+		pool.Name, string(cidrs), string(ranges), pool.MinPrefixLength, pool.MaxPrefixLength, revision, string(priorities), string(reserved),
+		pool.AutoExpandCIDR, pool.AutoExpandPrefixLength, string(expanded), pool.SkipBoundarySubnets, pool.Strategy, pool.DefaultPrefixLength)
+	if err != nil {
+		return sqliteWriteError("failed to save pool", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return sqliteWriteError("failed to commit pool", err)
+	}
+
+	pool.Revision = revision
+	return nil
+}
+
+// sqliteErrorCode returns the extended result code of an error returned by the sqlite driver.
+func sqliteErrorCode(err error) (int, bool) {
+	var sqliteErr *sqlite.Error
+	if !errors.As(err, &sqliteErr) {
+		return 0, false
+	}
+	return sqliteErr.Code(), true
+}
+
+func isUniqueConstraintError(err error) bool {
+	code, ok := sqliteErrorCode(err)
+	return ok && code == sqlite3.SQLITE_CONSTRAINT_UNIQUE
+}
+
+// isReadOnlyError and isBusyError match on the primary result code, the low byte of the extended one.
+func isReadOnlyError(err error) bool {
+	code, ok := sqliteErrorCode(err)
+	return ok && (code&0xff == sqlite3.SQLITE_READONLY || code&0xff == sqlite3.SQLITE_PERM)
+}
+
+func isBusyError(err error) bool {
+	code, ok := sqliteErrorCode(err)
+	return ok && (code&0xff == sqlite3.SQLITE_BUSY || code&0xff == sqlite3.SQLITE_LOCKED)
+}
+
+// sqliteWriteError wraps a failed write, reporting a read-only database, e.g. a replica or a file
+// opened without write permission, as ErrReadOnly, and a lock that wasn't released in time as ErrLocked.
+func sqliteWriteError(msg string, err error) error {
+	if isReadOnlyError(err) {
+		return fmt.Errorf("%s: %w: %w", msg, ErrReadOnly, err)
+	}
+	if isBusyError(err) {
+		return fmt.Errorf("%s: %w: %w", msg, ErrLocked, err)
+	}
+	return fmt.Errorf("%s: %w", msg, err)
+}
+
+func (ss *SQLiteStorage) DeletePool(ctx context.Context, name string) error {
+	result, err := ss.db.ExecContext(ctx, `DELETE FROM pools WHERE name = ?`, name)
+	if err != nil {
+		return sqliteWriteError("failed to delete pool", err)
+	}
+	return requireRowAffected(result, ErrPoolNotFound)
+}
+
+// DeletePoolWithAllocations deletes the pool's allocations and then the pool in one transaction.
+func (ss *SQLiteStorage) DeletePoolWithAllocations(ctx context.Context, name string) error {
+	tx, err := ss.db.BeginTx(ctx, nil)
+	if err != nil {
+		return sqliteWriteError("failed to begin transaction", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM allocations WHERE pool_name = ?`, name); err != nil {
+		return sqliteWriteError("failed to delete allocations", err)
+	}
+	result, err := tx.ExecContext(ctx, `DELETE FROM pools WHERE name = ?`, name)
+	if err != nil {
+		return sqliteWriteError("failed to delete pool", err)
+	}
+	if err := requireRowAffected(result, ErrPoolNotFound); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return sqliteWriteError("failed to commit transaction", err)
+	}
+	return nil
+}
+
+func (ss *SQLiteStorage) GetAllocation(ctx context.Context, key string) (*Allocation, error) {
+	row := ss.db.QueryRowContext(ctx,
+		`SELECT `+allocationColumns+` FROM allocations WHERE key = ?`, key)
+
+	allocation, err := scanAllocation(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrAllocationNotFound
+	}
+	return allocation, err
+}
+
+func (ss *SQLiteStorage) ListAllocations(ctx context.Context) ([]Allocation, error) {
+	return ss.queryAllocations(ctx,
+		`SELECT `+allocationColumns+` FROM allocations`)
+}
+
+func (ss *SQLiteStorage) ListAllocationsByPool(ctx context.Context, poolName string) ([]Allocation, error) {
+	return ss.queryAllocations(ctx,
+		`SELECT `+allocationColumns+` FROM allocations WHERE pool_name = ?`, poolName)
+}
+
+// Snapshot reads the pools and allocations in one read transaction, which sees the database as it was
+// when the first of them was read whatever other processes write in the meantime.
+func (ss *SQLiteStorage) Snapshot(ctx context.Context) (*Snapshot, error) {
+	tx, err := ss.db.BeginTx(ctx, &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	pools, err := queryPools(ctx, tx)
+	if err != nil {
+		return nil, err
+	}
+	allocations, err := queryAllocations(ctx, tx, `SELECT `+allocationColumns+` FROM allocations`)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Snapshot{Pools: pools, Allocations: allocations}, nil
+}
+
+func (ss *SQLiteStorage) SaveAllocation(ctx context.Context, allocation *Allocation) error {
+	var expiresAt, deletedAt int64
+	if !allocation.ExpiresAt.IsZero() {
+		expiresAt = allocation.ExpiresAt.Unix()
+	}
+	if allocation.Deleted() {
+		deletedAt = allocation.DeletedAt.Unix()
+	}
+	allocatedCIDRs, err := json.Marshal(allocation.AllocatedCIDRs)
+	if err != nil {
+		return fmt.Errorf("failed to marshal allocated cidrs: %w", err)
+	}
+	metadata, err := json.Marshal(allocation.Metadata)
+	if err != nil {
+		return fmt.Errorf("failed to marshal metadata: %w", err)
+	}
+
+	key := allocation.StorageKey()
+
+	tx, err := ss.db.BeginTx(ctx, nil)
+	if err != nil {
+		return sqliteWriteError("failed to begin transaction", err)
+	}
+	defer tx.Rollback()
+
+	// the unique index only catches another writer claiming the very same CIDR, so a claim overlapping
+	// one made since the provider searched the pool is checked for while the transaction holds the lock
+	if !allocation.AllowOverlap && !allocation.Deleted() {
+		if err := checkSQLiteOverlap(ctx, tx, key, allocation); err != nil {
+			return err
+		}
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO allocations (`+allocationColumns+`)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (key) DO UPDATE SET
+			id = excluded.id,
+			pool_name = excluded.pool_name,
+			allocated_cidr = excluded.allocated_cidr,
+			prefix_length = excluded.prefix_length,
+			alignment = excluded.alignment,
+			requested_cidr = excluded.requested_cidr,
+			allow_overlap = excluded.allow_overlap,
+			from_cidr = excluded.from_cidr,
+			owner = excluded.owner,
+			metadata = excluded.metadata,
+			ttl_seconds = excluded.ttl_seconds,
+			expires_at = excluded.expires_at,
+			allocated_cidrs = excluded.allocated_cidrs,
+			reserved_pool = excluded.reserved_pool,
+			deleted_at = excluded.deleted_at`,
+		key, allocation.ID, allocation.PoolName, allocation.AllocatedCIDR, allocation.PrefixLength, allocation.Alignment,
+		allocation.RequestedCIDR, allocation.AllowOverlap, allocation.FromCIDR, allocation.Owner, string(metadata), allocation.TTLSeconds, expiresAt,
+		string(allocatedCIDRs), allocation.ReservedPool, deletedAt)
+	if err != nil {
+		// another writer claimed the same CIDR or id in the pool first
+		if isUniqueConstraintError(err) {
+			return ErrConflict
+		}
+		return sqliteWriteError("failed to save allocation", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return sqliteWriteError("failed to commit allocation", err)
+	}
+
+	allocation.Key = key
+	return nil
+}
+
+// checkSQLiteOverlap returns ErrConflict when another allocation of the pool overlaps one of the allocation's
+// CIDRs. Saving an allocation again without changing its pool or CIDRs isn't checked, so an overlap that was
+// allowed when it was claimed doesn't block later updates.
+func checkSQLiteOverlap(ctx context.Context, tx *sql.Tx, key string, allocation *Allocation) error {
+	allocations, err := queryAllocations(ctx, tx,
+		`SELECT `+allocationColumns+` FROM allocations WHERE pool_name = ? AND deleted_at = 0`, allocation.PoolName)
+	if err != nil {
+		return err
+	}
+
+	byKey := make(map[string]*Allocation, len(allocations))
+	for i := range allocations {
+		byKey[allocations[i].StorageKey()] = &allocations[i]
+	}
+	if existing, ok := byKey[key]; ok && slices.Equal(existing.CIDRs(), allocation.CIDRs()) {
+		return nil
+	}
+
+	if overlappingAllocation(byKey, allocation) != nil {
+		return ErrConflict
+	}
+	return nil
+}
+
+func (ss *SQLiteStorage) DeleteAllocation(ctx context.Context, key string) error {
+	result, err := ss.db.ExecContext(ctx, `DELETE FROM allocations WHERE key = ?`, key)
+	if err != nil {
+		return sqliteWriteError("failed to delete allocation", err)
+	}
+	return requireRowAffected(result, ErrAllocationNotFound)
+}
+
+func (ss *SQLiteStorage) Ping(ctx context.Context) error {
+	var one int
+	if err := ss.db.QueryRowContext(ctx, `SELECT 1`).Scan(&one); err != nil {
+		return fmt.Errorf("failed to query sqlite database: %w", err)
+	}
+	return nil
+}
+
+// Begin and Commit do nothing, every change is a single row write that's committed right away.
+func (ss *SQLiteStorage) Begin(ctx context.Context) error {
+	return nil
+}
+
+func (ss *SQLiteStorage) Commit(ctx context.Context) error {
+	return nil
+}
+
+func (ss *SQLiteStorage) Close() error {
+	// every change is committed before the call that made it returns, and closing the database again returns nil
+	return ss.db.Close()
+}
+
+func (ss *SQLiteStorage) queryAllocations(ctx context.Context, query string, args ...any) ([]Allocation, error) {
+	return queryAllocations(ctx, ss.db, query, args...)
+}
+
+// queryAllocations runs an allocations query through the database or a transaction.
+func queryAllocations(ctx context.Context, db sqliteQuerier, query string, args ...any) ([]Allocation, error) {
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query allocations: %w", err)
+	}
+	defer rows.Close()
+
+	allocations := make([]Allocation, 0)
+	for rows.Next() {
+		allocation, err := scanAllocation(rows)
+		if err != nil {
+			return nil, err
+		}
+		allocations = append(allocations, *allocation)
+	}
+
+	return allocations, rows.Err()
+}
+
+// sqliteQuerier is satisfied by both *sql.DB and *sql.Tx.
+type sqliteQuerier interface {
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+}
+
+// rowScanner is satisfied by both *sql.Row and *sql.Rows.
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanPool(row rowScanner) (*Pool, error) {
+	var pool Pool
+	var cidrs, ranges, priorities, reserved, expanded string
+	if err := row.Scan(&pool.Name, &cidrs, &ranges, &pool.MinPrefixLength, &pool.MaxPrefixLength, &pool.Revision, &priorities, &reserved,
+		&pool.AutoExpandCIDR, &pool.AutoExpandPrefixLength, &expanded, &pool.SkipBoundarySubnets, &pool.Strategy, &pool.DefaultPrefixLength); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal([]byte(cidrs), &pool.CIDRs); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal cidrs of pool %s: %w", pool.Name, err)
+	}
+	if err := json.Unmarshal([]byte(ranges), &pool.Ranges); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal ranges of pool %s: %w", pool.Name, err)
+	}
+	if err := json.Unmarshal([]byte(priorities), &pool.CIDRPriorities); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal cidr priorities of pool %s: %w", pool.Name, err)
+	}
+	if err := json.Unmarshal([]byte(reserved), &pool.ReservedCIDRs); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal reserved cidrs of pool %s: %w", pool.Name, err)
+	}
+	if err := json.Unmarshal([]byte(expanded), &pool.ExpandedCIDRs); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal expanded cidrs of pool %s: %w", pool.Name, err)
+	}
+	return &pool, nil
+}
+
+func scanAllocation(row rowScanner) (*Allocation, error) {
+	var allocation Allocation
+	var expiresAt, deletedAt int64
+	var allocatedCIDRs, metadata string
+	if err := row.Scan(&allocation.Key, &allocation.ID, &allocation.PoolName, &allocation.AllocatedCIDR, &allocation.PrefixLength, &allocation.Alignment,
+		&allocation.RequestedCIDR, &allocation.AllowOverlap, &allocation.FromCIDR, &allocation.Owner, &metadata, &allocation.TTLSeconds, &expiresAt,
+		&allocatedCIDRs, &allocation.ReservedPool, &deletedAt); err != nil {
+		return nil, err
+	}
+	if expiresAt != 0 {
+		allocation.ExpiresAt = time.Unix(expiresAt, 0).UTC()
+	}
+	if deletedAt != 0 {
+		allocation.DeletedAt = time.Unix(deletedAt, 0).UTC()
+	}
+	if err := json.Unmarshal([]byte(allocatedCIDRs), &allocation.AllocatedCIDRs); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal allocated cidrs of allocation %s: %w", allocation.ID, err)
+	}
+	if err := json.Unmarshal([]byte(metadata), &allocation.Metadata); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal metadata of allocation %s: %w", allocation.ID, err)
+	}
+	return &allocation, nil
+}
+
+func requireRowAffected(result sql.Result, notFound error) error {
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return notFound
+	}
+	return nil
+}
+
+func (ss *SQLiteStorage) appendAudit(ctx context.Context, key string, line []byte) error {
+	return appendAuditFile(key, line)
+}
@@ -0,0 +1,179 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestSQLiteStorage_LockMaxWait(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "ipam.db")
+
+	holder, err := NewSQLiteStorage(path, 0)
+	if err != nil {
+		t.Fatalf("NewSQLiteStorage: %v", err)
+	}
+	waiter, err := NewSQLiteStorage(path, 100*time.Millisecond)
+	if err != nil {
+		t.Fatalf("NewSQLiteStorage: %v", err)
+	}
+
+	// an open transaction holds the write lock until it's rolled back
+	tx, err := holder.db.BeginTx(ctx, nil)
+	if err != nil {
+		t.Fatalf("BeginTx: %v", err)
+	}
+
+	start := time.Now()
+	err = waiter.SavePool(ctx, &Pool{Name: "locked", CIDRs: []string{"10.0.0.0/24"}})
+	if !errors.Is(err, ErrLocked) {
+		t.Fatalf("SavePool error = %v, want ErrLocked", err)
+	}
+	if waited := time.Since(start); waited < 100*time.Millisecond {
+		t.Errorf("SavePool failed after %s, before the lock wait ran out", waited)
+	}
+
+	if err := tx.Rollback(); err != nil {
+		t.Fatalf("Rollback: %v", err)
+	}
+	if err := waiter.SavePool(ctx, &Pool{Name: "locked", CIDRs: []string{"10.0.0.0/24"}}); err != nil {
+		t.Fatalf("SavePool after the lock was released: %v", err)
+	}
+}
+
+func TestSQLiteStorage_SoftDeletedAllocationsReleaseCIDR(t *testing.T) {
+	ctx := context.Background()
+
+	ss, err := NewSQLiteStorage(filepath.Join(t.TempDir(), "ipam.db"), 0)
+	if err != nil {
+		t.Fatalf("NewSQLiteStorage: %v", err)
+	}
+	defer ss.Close()
+	store := &softDeleteStorage{Storage: ss, retention: time.Hour}
+
+	released := Allocation{Key: "released", ID: "web", PoolName: "blue", AllocatedCIDR: "10.0.0.0/24", PrefixLength: 24}
+	if err := store.SaveAllocation(ctx, &released); err != nil {
+		t.Fatalf("SaveAllocation: %v", err)
+	}
+	if err := store.DeleteAllocation(ctx, released.Key); err != nil {
+		t.Fatalf("DeleteAllocation: %v", err)
+	}
+
+	// the unique indexes only cover allocations in use
+	reused := Allocation{Key: "reused", ID: "web", PoolName: "blue", AllocatedCIDR: "10.0.0.0/24", PrefixLength: 24}
+	if err := store.SaveAllocation(ctx, &reused); err != nil {
+		t.Fatalf("SaveAllocation of the released CIDR and id: %v", err)
+	}
+	clash := Allocation{Key: "clash", ID: "other", PoolName: "blue", AllocatedCIDR: "10.0.0.0/24", PrefixLength: 24}
+	if err := store.SaveAllocation(ctx, &clash); !errors.Is(err, ErrConflict) {
+		t.Errorf("SaveAllocation of a CIDR in use error = %v, want %v", err, ErrConflict)
+	}
+
+	stored, err := ss.GetAllocation(ctx, released.Key)
+	if err != nil {
+		t.Fatalf("GetAllocation of the deleted allocation: %v", err)
+	}
+	if !stored.Deleted() {
+		t.Error("deleted allocation was stored without its deletion time")
+	}
+}
+
+func TestSQLiteStorage_OverlappingClaims(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "ipam.db")
+
+	// two processes sharing the database, each having searched the pool before the other claimed
+	first, err := NewSQLiteStorage(path, 0)
+	if err != nil {
+		t.Fatalf("NewSQLiteStorage: %v", err)
+	}
+	defer first.Close()
+	second, err := NewSQLiteStorage(path, 0)
+	if err != nil {
+		t.Fatalf("NewSQLiteStorage: %v", err)
+	}
+	defer second.Close()
+
+	claimed := Allocation{Key: "web", ID: "web", PoolName: "blue", AllocatedCIDR: "10.0.0.0/24", PrefixLength: 24}
+	if err := first.SaveAllocation(ctx, &claimed); err != nil {
+		t.Fatalf("SaveAllocation: %v", err)
+	}
+
+	tests := map[string]struct {
+		allocation Allocation
+		want       error
+	}{
+		"nested":                {allocation: Allocation{Key: "db", ID: "db", PoolName: "blue", AllocatedCIDR: "10.0.0.128/25", PrefixLength: 25}, want: ErrConflict},
+		"containing":            {allocation: Allocation{Key: "db", ID: "db", PoolName: "blue", AllocatedCIDR: "10.0.0.0/23", PrefixLength: 23}, want: ErrConflict},
+		"one of several blocks": {allocation: Allocation{Key: "db", ID: "db", PoolName: "blue", AllocatedCIDR: "10.0.2.0/25", AllocatedCIDRs: []string{"10.0.2.0/25", "10.0.0.0/25"}, PrefixLength: 25}, want: ErrConflict},
+		"same id":               {allocation: Allocation{Key: "db", ID: "web", PoolName: "blue", AllocatedCIDR: "10.0.1.0/24", PrefixLength: 24}, want: ErrConflict},
+		"overlap allowed":       {allocation: Allocation{Key: "db", ID: "db", PoolName: "blue", AllocatedCIDR: "10.0.0.128/25", PrefixLength: 25, AllowOverlap: true}},
+		"other pool":            {allocation: Allocation{Key: "db", ID: "db", PoolName: "green", AllocatedCIDR: "10.0.0.128/25", PrefixLength: 25}},
+		"next to it":            {allocation: Allocation{Key: "db", ID: "db", PoolName: "blue", AllocatedCIDR: "10.0.1.0/24", PrefixLength: 24}},
+		"saved again unchanged": {allocation: claimed},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			allocation := test.allocation
+			err := second.SaveAllocation(ctx, &allocation)
+			if !errors.Is(err, test.want) || (test.want == nil && err != nil) {
+				t.Fatalf("SaveAllocation error = %v, want %v", err, test.want)
+			}
+			if err == nil && allocation.Key != claimed.Key {
+				if err := second.DeleteAllocation(ctx, allocation.Key); err != nil {
+					t.Fatalf("DeleteAllocation: %v", err)
+				}
+			}
+		})
+	}
+}
+
+func TestSQLiteStorage_AllocationMetadata(t *testing.T) {
+	ss, err := NewSQLiteStorage(filepath.Join(t.TempDir(), "ipam.db"), 0)
+	if err != nil {
+		t.Fatalf("NewSQLiteStorage: %v", err)
+	}
+	defer ss.Close()
+	testAllocationMetadataRoundTrip(t, ss)
+}
+
+func TestSQLiteStorage_Snapshot(t *testing.T) {
+	ss, err := NewSQLiteStorage(filepath.Join(t.TempDir(), "ipam.db"), 0)
+	if err != nil {
+		t.Fatalf("NewSQLiteStorage: %v", err)
+	}
+	defer ss.Close()
+	testSnapshotConsistent(t, ss)
+}
+
+func TestSQLiteStorage_DeletePoolWithAllocations(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ipam.db")
+	ss, err := NewSQLiteStorage(path, 0)
+	if err != nil {
+		t.Fatalf("NewSQLiteStorage: %v", err)
+	}
+	defer ss.Close()
+
+	testDeletePoolWithAllocations(t, ss, func() func() {
+		// the pool delete fails after the allocations were deleted in the same transaction
+		if _, err := ss.db.Exec(`CREATE TRIGGER fail_pool_delete BEFORE DELETE ON pools BEGIN SELECT RAISE(ABORT, 'simulated failure'); END`); err != nil {
+			t.Fatalf("create trigger: %v", err)
+		}
+		return func() {
+			if _, err := ss.db.Exec(`DROP TRIGGER fail_pool_delete`); err != nil {
+				t.Fatalf("drop trigger: %v", err)
+			}
+		}
+	}, func() Storage {
+		reopened, err := NewSQLiteStorage(path, 0)
+		if err != nil {
+			t.Fatalf("NewSQLiteStorage: %v", err)
+		}
+		t.Cleanup(func() { reopened.Close() })
+		return reopened
+	})
+}
@@ -0,0 +1,81 @@
+package storage
+
+import (
+	"context"
+	"errors"
+)
+
+// ErrStateStorage is returned by every operation of the "state" storage type. Its data lives in
+// tfipam_registry resources in Terraform state, so there's no backend for pools and allocations.
+var ErrStateStorage = errors.New("storage_type is 'state', pools and allocations are kept in tfipam_registry resources instead of a storage backend")
+
+// StateStorage is the backend of the "state" storage type. It holds nothing and rejects every
+// operation, so resources that need a shared backend fail with an explanation instead of
+// silently writing to a default file.
+type StateStorage struct{}
+
+func NewStateStorage() *StateStorage {
+	return &StateStorage{}
+}
+
+func (ss *StateStorage) GetPool(ctx context.Context, name string) (*Pool, error) {
+	return nil, ErrStateStorage
+}
+
+func (ss *StateStorage) ListPools(ctx context.Context) ([]Pool, error) {
+	return nil, ErrStateStorage
+}
+
+func (ss *StateStorage) SavePool(ctx context.Context, pool *Pool) error {
+	return ErrStateStorage
+}
+
+func (ss *StateStorage) DeletePool(ctx context.Context, name string) error {
+	return ErrStateStorage
+}
+
+func (ss *StateStorage) DeletePoolWithAllocations(ctx context.Context, name string) error {
+	return ErrStateStorage
+}
+
+func (ss *StateStorage) GetAllocation(ctx context.Context, key string) (*Allocation, error) {
+	return nil, ErrStateStorage
+}
+
+func (ss *StateStorage) ListAllocations(ctx context.Context) ([]Allocation, error) {
+	return nil, ErrStateStorage
+}
+
+func (ss *StateStorage) ListAllocationsByPool(ctx context.Context, poolName string) ([]Allocation, error) {
+	return nil, ErrStateStorage
+}
+
+func (ss *StateStorage) Snapshot(ctx context.Context) (*Snapshot, error) {
+	return nil, ErrStateStorage
+}
+
+func (ss *StateStorage) SaveAllocation(ctx context.Context, allocation *Allocation) error {
+	return ErrStateStorage
+}
+
+func (ss *StateStorage) DeleteAllocation(ctx context.Context, key string) error {
+	return ErrStateStorage
+}
+
+// Ping succeeds since there's nothing to reach, rejecting operations is left to the operations themselves.
+func (ss *StateStorage) Ping(ctx context.Context) error {
+	return nil
+}
+
+// Begin and Commit succeed, there are no writes to batch.
+func (ss *StateStorage) Begin(ctx context.Context) error {
+	return nil
+}
+
+func (ss *StateStorage) Commit(ctx context.Context) error {
+	return nil
+}
+
+func (ss *StateStorage) Close() error {
+	return nil
+}
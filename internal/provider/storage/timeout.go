@@ -0,0 +1,122 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// timeoutStorage bounds every operation of the wrapped backend, so a hung request to a remote
+// backend fails with an error instead of blocking the apply indefinitely.
+type timeoutStorage struct {
+	Storage
+	timeout time.Duration
+}
+
+// run calls fn with a context derived from ctx that expires after the timeout.
+func (ts *timeoutStorage) run(ctx context.Context, operation string, fn func(ctx context.Context) error) error {
+	ctx, cancel := context.WithTimeout(ctx, ts.timeout)
+	defer cancel()
+
+	err := fn(ctx)
+	if err != nil && ctx.Err() == context.DeadlineExceeded {
+		return fmt.Errorf("storage operation %s timed out after %s: %w", operation, ts.timeout, err)
+	}
+	return err
+}
+
+func (ts *timeoutStorage) GetPool(ctx context.Context, name string) (*Pool, error) {
+	var pool *Pool
+	err := ts.run(ctx, "get pool", func(ctx context.Context) error {
+		var err error
+		pool, err = ts.Storage.GetPool(ctx, name)
+		return err
+	})
+	return pool, err
+}
+
+func (ts *timeoutStorage) ListPools(ctx context.Context) ([]Pool, error) {
+	var pools []Pool
+	err := ts.run(ctx, "list pools", func(ctx context.Context) error {
+		var err error
+		pools, err = ts.Storage.ListPools(ctx)
+		return err
+	})
+	return pools, err
+}
+
+func (ts *timeoutStorage) SavePool(ctx context.Context, pool *Pool) error {
+	return ts.run(ctx, "save pool", func(ctx context.Context) error {
+		return ts.Storage.SavePool(ctx, pool)
+	})
+}
+
+func (ts *timeoutStorage) DeletePool(ctx context.Context, name string) error {
+	return ts.run(ctx, "delete pool", func(ctx context.Context) error {
+		return ts.Storage.DeletePool(ctx, name)
+	})
+}
+
+func (ts *timeoutStorage) DeletePoolWithAllocations(ctx context.Context, name string) error {
+	return ts.run(ctx, "delete pool with allocations", func(ctx context.Context) error {
+		return ts.Storage.DeletePoolWithAllocations(ctx, name)
+	})
+}
+
+func (ts *timeoutStorage) GetAllocation(ctx context.Context, key string) (*Allocation, error) {
+	var allocation *Allocation
+	err := ts.run(ctx, "get allocation", func(ctx context.Context) error {
+		var err error
+		allocation, err = ts.Storage.GetAllocation(ctx, key)
+		return err
+	})
+	return allocation, err
+}
+
+func (ts *timeoutStorage) ListAllocations(ctx context.Context) ([]Allocation, error) {
+	var allocations []Allocation
+	err := ts.run(ctx, "list allocations", func(ctx context.Context) error {
+		var err error
+		allocations, err = ts.Storage.ListAllocations(ctx)
+		return err
+	})
+	return allocations, err
+}
+
+func (ts *timeoutStorage) ListAllocationsByPool(ctx context.Context, poolName string) ([]Allocation, error) {
+	var allocations []Allocation
+	err := ts.run(ctx, "list allocations", func(ctx context.Context) error {
+		var err error
+		allocations, err = ts.Storage.ListAllocationsByPool(ctx, poolName)
+		return err
+	})
+	return allocations, err
+}
+
+func (ts *timeoutStorage) SaveAllocation(ctx context.Context, allocation *Allocation) error {
+	return ts.run(ctx, "save allocation", func(ctx context.Context) error {
+		return ts.Storage.SaveAllocation(ctx, allocation)
+	})
+}
+
+func (ts *timeoutStorage) DeleteAllocation(ctx context.Context, key string) error {
+	return ts.run(ctx, "delete allocation", func(ctx context.Context) error {
+		return ts.Storage.DeleteAllocation(ctx, key)
+	})
+}
+
+func (ts *timeoutStorage) Snapshot(ctx context.Context) (*Snapshot, error) {
+	var snapshot *Snapshot
+	err := ts.run(ctx, "snapshot", func(ctx context.Context) error {
+		var err error
+		snapshot, err = ts.Storage.Snapshot(ctx)
+		return err
+	})
+	return snapshot, err
+}
+
+func (ts *timeoutStorage) Ping(ctx context.Context) error {
+	return ts.run(ctx, "ping", func(ctx context.Context) error {
+		return ts.Storage.Ping(ctx)
+	})
+}
@@ -0,0 +1,188 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/go-uuid"
+	"github.com/hashicorp/terraform-plugin-framework/action"
+	"github.com/hashicorp/terraform-plugin-framework/action/schema"
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+
+	"terraform-provider-tfipam/internal/provider/storage"
+)
+
+var _ action.Action = &StorageSelftestAction{}
+var _ action.ActionWithConfigure = &StorageSelftestAction{}
+
+func NewStorageSelftestAction() action.Action {
+	return &StorageSelftestAction{}
+}
+
+// StorageSelftestAction writes, reads back and deletes a sentinel pool, so operators can check the
+// storage configuration's credentials and permissions before relying on it.
+type StorageSelftestAction struct {
+	provider *IpamProvider
+}
+
+// selftestPoolPrefix starts the name of every sentinel pool, which is never the name of a real pool
+// since the rest of it is a fresh UUID.
+const selftestPoolPrefix = "tfipam-selftest-"
+
+// selftestPoolCIDR is the CIDR of the sentinel pool, from TEST-NET-1 so it can't be mistaken for a real one.
+const selftestPoolCIDR = "192.0.2.0/24"
+
+// steps of a selftest, in the order they run
+const (
+	selftestSave          = "save"
+	selftestGet           = "get"
+	selftestDelete        = "delete"
+	selftestVerifyDeleted = "verify_deleted"
+)
+
+// selftestStep is the outcome of one storage operation of a selftest.
+type selftestStep struct {
+	Step       string `json:"step"`
+	OK         bool   `json:"ok"`
+	DurationMS int64  `json:"duration_ms"`
+	Error      string `json:"error,omitempty"`
+
+	err error
+}
+
+// selftestReport is the structured result of a selftest, sent to Terraform as JSON.
+type selftestReport struct {
+	StorageType string         `json:"storage_type"`
+	Sentinel    string         `json:"sentinel"`
+	OK          bool           `json:"ok"`
+	Steps       []selftestStep `json:"steps"`
+}
+
+func (a *StorageSelftestAction) Metadata(ctx context.Context, req action.MetadataRequest, resp *action.MetadataResponse) {
+	resp.TypeName = req.ProviderTypeName + "_storage_selftest"
+}
+
+func (a *StorageSelftestAction) Schema(ctx context.Context, req action.SchemaRequest, resp *action.SchemaResponse) {
+	resp.Schema = schema.Schema{
+		MarkdownDescription: "IPAM storage selftest action that saves, reads back and deletes a sentinel pool to check the storage backend's credentials and permissions",
+	}
+}
+
+func (a *StorageSelftestAction) Configure(ctx context.Context, req action.ConfigureRequest, resp *action.ConfigureResponse) {
+	if req.ProviderData == nil {
+		return
+	}
+
+	provider, ok := req.ProviderData.(*IpamProvider)
+	if !ok {
+		resp.Diagnostics.AddError(
+			"Unexpected Action Configure Type",
+			fmt.Sprintf("Expected *IpamProvider, got: %T", req.ProviderData),
+		)
+		return
+	}
+
+	a.provider = provider
+}
+
+func (a *StorageSelftestAction) Invoke(ctx context.Context, req action.InvokeRequest, resp *action.InvokeResponse) {
+	suffix, err := uuid.GenerateUUID()
+	if err != nil {
+		resp.Diagnostics.AddError(
+			"Failed to Generate Sentinel Name",
+			fmt.Sprintf("Could not generate a UUID for the sentinel pool: %s", err),
+		)
+		return
+	}
+
+	report := selftestStorage(ctx, a.provider.storage, selftestPoolPrefix+suffix)
+	report.StorageType = a.provider.storageType
+
+	message, err := json.Marshal(report)
+	if err != nil {
+		resp.Diagnostics.AddError(
+			"Failed to Encode Report",
+			fmt.Sprintf("Could not encode the storage selftest report: %s", err),
+		)
+		return
+	}
+	resp.SendProgress(action.InvokeProgressEvent{Message: string(message)})
+
+	tflog.Info(ctx, "ran storage selftest", map[string]any{
+		"storage_type": report.StorageType,
+		"sentinel":     report.Sentinel,
+		"ok":           report.OK,
+	})
+
+	if report.OK {
+		return
+	}
+	failed := report.Steps[slices.IndexFunc(report.Steps, func(step selftestStep) bool { return !step.OK })]
+	detail := fmt.Sprintf("The %s step of the %s storage backend selftest failed: %s", failed.Step, report.StorageType, failed.err)
+	if failed.Step != selftestSave {
+		// a sentinel that was saved may still be there when a later step failed
+		detail += fmt.Sprintf("\n\nThe sentinel pool %s may have been left in storage. It holds no allocations and can be deleted.", report.Sentinel)
+	}
+	addStorageWriteError(&resp.Diagnostics, "Storage Selftest Failed", detail, failed.err)
+}
+
+// selftestStorage saves a pool with the sentinel name, reads it back, deletes it and checks it's gone.
+// Once the pool is saved it's deleted even if reading it back failed, and the steps stop at the first
+// other failure.
+func selftestStorage(ctx context.Context, store storage.Storage, sentinel string) selftestReport {
+	report := selftestReport{Sentinel: sentinel, Steps: []selftestStep{}}
+
+	run := func(step string, fn func() error) bool {
+		start := time.Now()
+		err := fn()
+		result := selftestStep{Step: step, OK: err == nil, DurationMS: time.Since(start).Milliseconds(), err: err}
+		if err != nil {
+			result.Error = err.Error()
+		}
+		report.Steps = append(report.Steps, result)
+		return err == nil
+	}
+
+	saved := run(selftestSave, func() error {
+		return store.SavePool(ctx, &storage.Pool{Name: sentinel, CIDRs: []string{selftestPoolCIDR}})
+	})
+	if !saved {
+		return report
+	}
+
+	read := run(selftestGet, func() error {
+		pool, err := store.GetPool(ctx, sentinel)
+		if err != nil {
+			return err
+		}
+		if pool.Name != sentinel || !slices.Equal(pool.CIDRs, []string{selftestPoolCIDR}) {
+			return fmt.Errorf("read back pool %s with CIDRs %s, want pool %s with CIDRs %s",
+				pool.Name, strings.Join(pool.CIDRs, ","), sentinel, selftestPoolCIDR)
+		}
+		return nil
+	})
+
+	deleted := run(selftestDelete, func() error {
+		return store.DeletePool(ctx, sentinel)
+	})
+	if !read || !deleted {
+		return report
+	}
+
+	report.OK = run(selftestVerifyDeleted, func() error {
+		_, err := store.GetPool(ctx, sentinel)
+		if errors.Is(err, storage.ErrNotFound) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		return fmt.Errorf("pool %s can still be read after it was deleted", sentinel)
+	})
+	return report
+}
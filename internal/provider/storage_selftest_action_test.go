@@ -0,0 +1,91 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-testing/helper/resource"
+	"github.com/hashicorp/terraform-plugin-testing/tfversion"
+
+	"terraform-provider-tfipam/internal/provider/storage"
+)
+
+func TestAccStorageSelftestAction_Basic(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		TerraformVersionChecks: []tfversion.TerraformVersionCheck{
+			tfversion.SkipBelow(tfversion.Version1_14_0),
+		},
+		PreCheck:                 func() { testAccPreCheck(t) },
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: `
+action "tfipam_storage_selftest" "test" {}
+
+resource "terraform_data" "trigger" {
+  input = "selftest"
+
+  lifecycle {
+    action_trigger {
+      events  = [after_create]
+      actions = [action.tfipam_storage_selftest.test]
+    }
+  }
+}
+`,
+			},
+		},
+	})
+}
+
+// readOnlyStorage rejects pool writes the way a backend with read-only credentials does.
+type readOnlyStorage struct {
+	storage.Storage
+}
+
+func (s readOnlyStorage) SavePool(ctx context.Context, pool *storage.Pool) error {
+	return storage.ErrReadOnly
+}
+
+func TestSelftestStorage(t *testing.T) {
+	ctx := context.Background()
+	store, err := storage.NewFileStorage(filepath.Join(t.TempDir(), "ipam.json"))
+	if err != nil {
+		t.Fatalf("NewFileStorage: %v", err)
+	}
+
+	report := selftestStorage(ctx, store, selftestPoolPrefix+"ok")
+	if !report.OK {
+		t.Fatalf("selftest failed: %+v", report.Steps)
+	}
+	var steps []string
+	for _, step := range report.Steps {
+		steps = append(steps, step.Step)
+	}
+	want := []string{selftestSave, selftestGet, selftestDelete, selftestVerifyDeleted}
+	if len(steps) != len(want) {
+		t.Fatalf("ran steps %v, want %v", steps, want)
+	}
+	for i := range want {
+		if steps[i] != want[i] {
+			t.Fatalf("ran steps %v, want %v", steps, want)
+		}
+	}
+	pools, err := store.ListPools(ctx)
+	if err != nil {
+		t.Fatalf("ListPools: %v", err)
+	}
+	if len(pools) != 0 {
+		t.Errorf("selftest left %d pool(s) in storage", len(pools))
+	}
+
+	report = selftestStorage(ctx, readOnlyStorage{store}, selftestPoolPrefix+"read-only")
+	if report.OK {
+		t.Fatal("selftest of read-only storage passed, want it to fail")
+	}
+	if len(report.Steps) != 1 || report.Steps[0].Step != selftestSave || !errors.Is(report.Steps[0].err, storage.ErrReadOnly) {
+		t.Errorf("got steps %+v, want a failed save", report.Steps)
+	}
+}
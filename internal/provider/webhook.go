@@ -0,0 +1,119 @@
+package provider
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+
+	"terraform-provider-tfipam/internal/provider/storage"
+)
+
+// how long a webhook request may take before it's given up on
+const allocationWebhookTimeout = 10 * time.Second
+
+// actions an allocation webhook is sent for
+const (
+	allocationWebhookCreate = "create"
+	allocationWebhookDelete = "delete"
+)
+
+// allocationWebhook POSTs the allocations created and deleted by the provider to allocation_webhook_url,
+// so systems like DNS or monitoring can follow IPAM changes.
+type allocationWebhook struct {
+	url string
+	// whether a failed request fails the operation instead of only warning
+	strict bool
+	client *http.Client
+}
+
+// allocationWebhookPayload is the JSON body of a webhook request.
+type allocationWebhookPayload struct {
+	Action   string   `json:"action"`
+	ID       string   `json:"id"`
+	Key      string   `json:"key"`
+	PoolName string   `json:"pool_name"`
+	CIDR     string   `json:"cidr"`
+	CIDRs    []string `json:"cidrs"`
+	Time     string   `json:"time"`
+}
+
+func newAllocationWebhook(url string, strict bool) *allocationWebhook {
+	return &allocationWebhook{url: url, strict: strict, client: &http.Client{Timeout: allocationWebhookTimeout}}
+}
+
+// send POSTs the payload, failing on anything but a 2xx response.
+func (w *allocationWebhook) send(ctx context.Context, payload allocationWebhookPayload) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to encode payload: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	// drain so the connection can be reused
+	_, _ = io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook responded with %s", resp.Status)
+	}
+	return nil
+}
+
+// notifyAllocation sends an allocation change to the webhook when one is configured. It's called once
+// the change is saved to storage, so a failed request can't undo it: it adds a warning, or an error in
+// strict mode.
+func (p *IpamProvider) notifyAllocation(ctx context.Context, diags *diag.Diagnostics, action string, allocation *storage.Allocation) {
+	webhook := p.allocationWebhook
+	if webhook == nil {
+		return
+	}
+
+	payload := allocationWebhookPayload{
+		Action:   action,
+		ID:       allocation.ID,
+		Key:      allocation.StorageKey(),
+		PoolName: allocation.PoolName,
+		CIDR:     allocation.AllocatedCIDR,
+		CIDRs:    allocation.CIDRs(),
+		Time:     time.Now().UTC().Format(time.RFC3339),
+	}
+	err := webhook.send(ctx, payload)
+	if err == nil {
+		tflog.Debug(ctx, "sent allocation webhook", map[string]any{
+			"action":    action,
+			"id":        allocation.ID,
+			"pool_name": allocation.PoolName,
+		})
+		return
+	}
+
+	detail := fmt.Sprintf("The %s of allocation %s (%s) in pool %s was saved to storage, but sending it to the allocation webhook failed: %s",
+		action, allocation.ID, allocation.AllocatedCIDR, allocation.PoolName, err)
+	if webhook.strict {
+		diags.AddError("Allocation Webhook Failed", detail)
+		return
+	}
+	tflog.Warn(ctx, "allocation webhook failed", map[string]any{
+		"action":    action,
+		"id":        allocation.ID,
+		"pool_name": allocation.PoolName,
+		"error":     err.Error(),
+	})
+	diags.AddWarning("Allocation Webhook Failed", detail)
+}
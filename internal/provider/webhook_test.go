@@ -0,0 +1,152 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-testing/helper/resource"
+	"github.com/hashicorp/terraform-plugin-testing/terraform"
+
+	"terraform-provider-tfipam/internal/provider/storage"
+)
+
+// webhookStub is an HTTP server recording the allocation webhook payloads it's sent.
+type webhookStub struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	payloads []allocationWebhookPayload
+}
+
+// newWebhookStub starts a stub that answers every request with the status.
+func newWebhookStub(t *testing.T, status int) *webhookStub {
+	stub := &webhookStub{}
+	stub.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload allocationWebhookPayload
+		if r.Method != http.MethodPost || r.Header.Get("Content-Type") != "application/json" {
+			t.Errorf("webhook got a %s request with content type %q, want a JSON POST", r.Method, r.Header.Get("Content-Type"))
+		}
+		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+			t.Errorf("webhook payload isn't JSON: %v", err)
+		}
+		stub.mu.Lock()
+		stub.payloads = append(stub.payloads, payload)
+		stub.mu.Unlock()
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(stub.Close)
+	return stub
+}
+
+// received returns the actions and ids of the payloads sent so far, e.g. "create web".
+func (s *webhookStub) received() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var received []string
+	for _, payload := range s.payloads {
+		received = append(received, payload.Action+" "+payload.ID)
+	}
+	return received
+}
+
+func TestNotifyAllocation(t *testing.T) {
+	allocation := &storage.Allocation{Key: "web-key", ID: "web", PoolName: "blue", AllocatedCIDR: "10.0.0.0/24"}
+
+	t.Run("sent", func(t *testing.T) {
+		stub := newWebhookStub(t, http.StatusNoContent)
+		p := &IpamProvider{allocationWebhook: newAllocationWebhook(stub.URL, false)}
+
+		var diags diag.Diagnostics
+		p.notifyAllocation(context.Background(), &diags, allocationWebhookCreate, allocation)
+		if diags.HasError() || diags.WarningsCount() > 0 {
+			t.Fatalf("notifyAllocation diagnostics = %v, want none", diags)
+		}
+		if len(stub.payloads) != 1 {
+			t.Fatalf("webhook got %d requests, want 1", len(stub.payloads))
+		}
+		got := stub.payloads[0]
+		if got.Action != "create" || got.ID != "web" || got.Key != "web-key" || got.PoolName != "blue" || got.CIDR != "10.0.0.0/24" {
+			t.Errorf("payload = %+v, want the created allocation", got)
+		}
+		if len(got.CIDRs) != 1 || got.CIDRs[0] != "10.0.0.0/24" || got.Time == "" {
+			t.Errorf("payload = %+v, want its CIDRs and time", got)
+		}
+	})
+
+	tests := map[string]struct {
+		strict       bool
+		wantError    bool
+		wantWarnings int
+	}{
+		"failure warns":        {strict: false, wantWarnings: 1},
+		"strict failure fails": {strict: true, wantError: true},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			stub := newWebhookStub(t, http.StatusInternalServerError)
+			p := &IpamProvider{allocationWebhook: newAllocationWebhook(stub.URL, test.strict)}
+
+			var diags diag.Diagnostics
+			p.notifyAllocation(context.Background(), &diags, allocationWebhookDelete, allocation)
+			if diags.HasError() != test.wantError || diags.WarningsCount() != test.wantWarnings {
+				t.Errorf("notifyAllocation diagnostics = %v, want error %t and %d warning(s)", diags, test.wantError, test.wantWarnings)
+			}
+		})
+	}
+
+	t.Run("not configured", func(t *testing.T) {
+		var diags diag.Diagnostics
+		(&IpamProvider{}).notifyAllocation(context.Background(), &diags, allocationWebhookCreate, allocation)
+		if len(diags) != 0 {
+			t.Errorf("notifyAllocation diagnostics = %v, want none", diags)
+		}
+	})
+}
+
+func TestAccAllocationResource_Webhook(t *testing.T) {
+	stub := newWebhookStub(t, http.StatusOK)
+
+	resource.Test(t, resource.TestCase{
+		PreCheck:                 func() { testAccPreCheck(t) },
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		CheckDestroy: func(*terraform.State) error {
+			if got := fmt.Sprint(stub.received()); got != "[create webhook-alloc delete webhook-alloc]" {
+				return fmt.Errorf("webhook received %s, want the allocation's create and delete", got)
+			}
+			return nil
+		},
+		Steps: []resource.TestStep{
+			{
+				Config: fmt.Sprintf(`
+provider "tfipam" {
+  allocation_webhook_url = %q
+}
+
+resource "tfipam_pool" "test" {
+  name  = "webhook-pool"
+  cidrs = ["10.0.0.0/16"]
+}
+
+resource "tfipam_allocation" "test" {
+  id            = "webhook-alloc"
+  pool_name     = tfipam_pool.test.name
+  prefix_length = 24
+}
+`, stub.URL),
+				Check: func(*terraform.State) error {
+					if got := fmt.Sprint(stub.received()); got != "[create webhook-alloc]" {
+						return fmt.Errorf("webhook received %s, want the allocation's create", got)
+					}
+					return nil
+				},
+			},
+		},
+	})
+}
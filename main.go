@@ -3,10 +3,12 @@ package main
 import (
 	"context"
 	"flag"
+	"io"
 	"log"
 
 	"terraform-provider-tfipam/internal/provider"
 
+	tfprovider "github.com/hashicorp/terraform-plugin-framework/provider"
 	"github.com/hashicorp/terraform-plugin-framework/providerserver"
 )
 
@@ -27,7 +29,15 @@ func main() {
 		Debug:   debug,
 	}
 
-	err := providerserver.Serve(context.Background(), provider.New(version), opts)
+	// keep hold of the provider so its storage and metrics server can be closed once Terraform shuts it down
+	ipamProvider := provider.New(version)()
+	err := providerserver.Serve(context.Background(), func() tfprovider.Provider { return ipamProvider }, opts)
+
+	if closer, ok := ipamProvider.(io.Closer); ok {
+		if closeErr := closer.Close(); closeErr != nil {
+			log.Printf("failed to close provider: %s", closeErr)
+		}
+	}
 
 	if err != nil {
 		log.Fatal(err.Error())
@@ -0,0 +1,134 @@
+// Package reader gives Go programs read-only access to the pools and allocations the tfipam
+// provider keeps in its storage backend, e.g. for reporting tools built around the same data
+// file or bucket. It opens the backend the same way the provider does, but only exposes lookups
+// so an external tool can't change the data Terraform relies on.
+package reader
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"terraform-provider-tfipam/internal/provider/storage"
+)
+
+// Pool, Allocation and Config are the structs the provider stores and is configured with.
+type (
+	Pool       = storage.Pool
+	Allocation = storage.Allocation
+	Config     = storage.Config
+)
+
+// ErrNotFound is returned when a pool or allocation doesn't exist. ErrPoolNotFound and
+// ErrAllocationNotFound say which one, and both match ErrNotFound with errors.Is.
+var (
+	ErrNotFound           = storage.ErrNotFound
+	ErrPoolNotFound       = storage.ErrPoolNotFound
+	ErrAllocationNotFound = storage.ErrAllocationNotFound
+)
+
+// Reader looks up pools and allocations in a storage backend.
+type Reader struct {
+	storage storage.Storage
+}
+
+// Open connects to the storage backend described by config, using the same defaults as the
+// provider. Environment variables and the key prefix are resolved on a copy, so config is left as is.
+// The backend is opened read-only: nothing is created, so a reader can run next to the
+// provider. Options that only affect writes, the audit log, soft delete retention and fallbacks that
+// are kept in sync with the backend, are rejected.
+//
+// The file, azure_blob and aws_s3 backends load their data once here and answer every lookup from it,
+// so a Reader of them is a snapshot taken at Open. Open a new Reader to see later changes. The sqlite
+// backend reads the database on every lookup.
+func Open(ctx context.Context, config *Config) (*Reader, error) {
+	switch {
+	case config.AuditObjectKey != "":
+		return nil, errors.New("the audit log is written on changes and can't be used by a reader")
+	case config.SoftDeleteRetention > 0:
+		return nil, errors.New("soft delete retention applies to deletes and can't be used by a reader")
+	case len(config.Fallbacks) > 0:
+		return nil, errors.New("fallbacks are synced with the backend when it's opened and can't be used by a reader")
+	}
+
+	resolved := *config
+	resolved.ResolveKeys()
+	resolved.ReadOnly = true
+
+	backend, err := storage.Factory(ctx, &resolved)
+	if err != nil {
+		return nil, err
+	}
+	return &Reader{storage: backend}, nil
+}
+
+func (r *Reader) GetPool(ctx context.Context, name string) (*Pool, error) {
+	return r.storage.GetPool(ctx, name)
+}
+
+func (r *Reader) ListPools(ctx context.Context) ([]Pool, error) {
+	return r.storage.ListPools(ctx)
+}
+
+// GetAllocation returns the allocation stored under the key, the generated Key of the allocation rather
+// than its ID. Allocations stored before they had keys are stored under their ID. Use GetAllocationByID
+// to look an allocation up by the id it was given in Terraform.
+//
+// GetAllocation, GetAllocationByID, ListAllocations and ListAllocationsByPool leave out allocations the
+// provider soft deleted, which it keeps around as a record but no longer holds a CIDR.
+func (r *Reader) GetAllocation(ctx context.Context, key string) (*Allocation, error) {
+	allocation, err := r.storage.GetAllocation(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	if allocation.Deleted() {
+		return nil, ErrAllocationNotFound
+	}
+	return allocation, nil
+}
+
+// GetAllocationByID returns the pool's allocation with the id. Ids are only unique within a pool, so
+// the pool is needed to find it.
+func (r *Reader) GetAllocationByID(ctx context.Context, poolName string, id string) (*Allocation, error) {
+	allocations, err := r.ListAllocationsByPool(ctx, poolName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list allocations: %w", err)
+	}
+	for i := range allocations {
+		if allocations[i].ID == id {
+			return &allocations[i], nil
+		}
+	}
+	return nil, fmt.Errorf("allocation %s of pool %s: %w", id, poolName, ErrAllocationNotFound)
+}
+
+func (r *Reader) ListAllocations(ctx context.Context) ([]Allocation, error) {
+	allocations, err := r.storage.ListAllocations(ctx)
+	return inUse(allocations), err
+}
+
+func (r *Reader) ListAllocationsByPool(ctx context.Context, poolName string) ([]Allocation, error) {
+	allocations, err := r.storage.ListAllocationsByPool(ctx, poolName)
+	return inUse(allocations), err
+}
+
+func inUse(allocations []Allocation) []Allocation {
+	kept := allocations[:0:0]
+	for _, allocation := range allocations {
+		if !allocation.Deleted() {
+			kept = append(kept, allocation)
+		}
+	}
+	return kept
+}
+
+// Ping checks the backend is still reachable, e.g. for a health endpoint of a long running tool. It
+// doesn't refresh the data, a long running tool reopens the Reader to see changes made since Open.
+func (r *Reader) Ping(ctx context.Context) error {
+	return r.storage.Ping(ctx)
+}
+
+// Close releases the backend, e.g. the SQLite database handle.
+func (r *Reader) Close() error {
+	return r.storage.Close()
+}
@@ -0,0 +1,521 @@
+package reader
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"terraform-provider-tfipam/internal/provider/storage"
+)
+
+func TestReader_File(t *testing.T) {
+	ctx := context.Background()
+	filePath := filepath.Join(t.TempDir(), "ipam-storage.json")
+
+	// write the data the way the provider would
+	fs, err := storage.NewFileStorage(filePath)
+	if err != nil {
+		t.Fatalf("failed to create file storage: %s", err)
+	}
+	if err := fs.SavePool(ctx, &storage.Pool{Name: "reader-pool", CIDRs: []string{"10.0.0.0/16"}}); err != nil {
+		t.Fatalf("failed to save pool: %s", err)
+	}
+	if err := fs.SaveAllocation(ctx, &storage.Allocation{ID: "reader-alloc", PoolName: "reader-pool", AllocatedCIDR: "10.0.0.0/24", PrefixLength: 24}); err != nil {
+		t.Fatalf("failed to save allocation: %s", err)
+	}
+
+	r, err := Open(ctx, &Config{Type: "file", FilePath: filePath})
+	if err != nil {
+		t.Fatalf("failed to open reader: %s", err)
+	}
+	defer r.Close()
+
+	pools, err := r.ListPools(ctx)
+	if err != nil {
+		t.Fatalf("failed to list pools: %s", err)
+	}
+	if len(pools) != 1 || pools[0].Name != "reader-pool" || pools[0].Revision != 1 {
+		t.Fatalf("unexpected pools: %+v", pools)
+	}
+
+	allocations, err := r.ListAllocationsByPool(ctx, "reader-pool")
+	if err != nil {
+		t.Fatalf("failed to list allocations: %s", err)
+	}
+	if len(allocations) != 1 || allocations[0].AllocatedCIDR != "10.0.0.0/24" {
+		t.Fatalf("unexpected allocations: %+v", allocations)
+	}
+
+	if _, err := r.GetAllocation(ctx, "missing-alloc"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound for a missing allocation, got %v", err)
+	}
+}
+
+func TestReader_AllocationKeys(t *testing.T) {
+	ctx := context.Background()
+	filePath := filepath.Join(t.TempDir(), "ipam-storage.json")
+
+	// the provider stores allocations under a generated key, and ids only have to be unique within a pool
+	fs, err := storage.NewFileStorage(filePath)
+	if err != nil {
+		t.Fatalf("failed to create file storage: %s", err)
+	}
+	for _, allocation := range []*storage.Allocation{
+		{Key: "6f1c2b0e-blue", ID: "web", PoolName: "blue", AllocatedCIDR: "10.0.0.0/24", PrefixLength: 24},
+		{Key: "9a4d7e3f-green", ID: "web", PoolName: "green", AllocatedCIDR: "10.1.0.0/24", PrefixLength: 24},
+	} {
+		if err := fs.SaveAllocation(ctx, allocation); err != nil {
+			t.Fatalf("failed to save allocation: %s", err)
+		}
+	}
+
+	r, err := Open(ctx, &Config{Type: "file", FilePath: filePath})
+	if err != nil {
+		t.Fatalf("failed to open reader: %s", err)
+	}
+	defer r.Close()
+
+	allocation, err := r.GetAllocation(ctx, "9a4d7e3f-green")
+	if err != nil {
+		t.Fatalf("failed to get allocation by key: %s", err)
+	}
+	if allocation.ID != "web" || allocation.PoolName != "green" {
+		t.Fatalf("unexpected allocation for the key: %+v", allocation)
+	}
+	if _, err := r.GetAllocation(ctx, "web"); !errors.Is(err, ErrAllocationNotFound) {
+		t.Fatalf("expected ErrAllocationNotFound getting an allocation by its id as key, got %v", err)
+	}
+
+	allocation, err = r.GetAllocationByID(ctx, "blue", "web")
+	if err != nil {
+		t.Fatalf("failed to get allocation by id: %s", err)
+	}
+	if allocation.Key != "6f1c2b0e-blue" || allocation.AllocatedCIDR != "10.0.0.0/24" {
+		t.Fatalf("unexpected allocation for the id: %+v", allocation)
+	}
+	if _, err := r.GetAllocationByID(ctx, "blue", "db"); !errors.Is(err, ErrAllocationNotFound) {
+		t.Fatalf("expected ErrAllocationNotFound for a missing id, got %v", err)
+	}
+}
+
+func TestReader_NotFoundErrors(t *testing.T) {
+	ctx := context.Background()
+
+	r, err := Open(ctx, &Config{Type: "file", FilePath: filepath.Join(t.TempDir(), "ipam-storage.json")})
+	if err != nil {
+		t.Fatalf("failed to open reader: %s", err)
+	}
+	defer r.Close()
+
+	_, err = r.GetPool(ctx, "missing-pool")
+	if !errors.Is(err, ErrPoolNotFound) || !errors.Is(err, ErrNotFound) || errors.Is(err, ErrAllocationNotFound) {
+		t.Fatalf("expected ErrPoolNotFound for a missing pool, got %v", err)
+	}
+
+	_, err = r.GetAllocation(ctx, "missing-alloc")
+	if !errors.Is(err, ErrAllocationNotFound) || !errors.Is(err, ErrNotFound) || errors.Is(err, ErrPoolNotFound) {
+		t.Fatalf("expected ErrAllocationNotFound for a missing allocation, got %v", err)
+	}
+}
+
+func TestReader_PingMissingDirectory(t *testing.T) {
+	ctx := context.Background()
+
+	r, err := Open(ctx, &Config{Type: "file", FilePath: filepath.Join(t.TempDir(), "missing", "ipam-storage.json")})
+	if err != nil {
+		t.Fatalf("failed to open reader: %s", err)
+	}
+	defer r.Close()
+
+	if err := r.Ping(ctx); err == nil {
+		t.Fatal("expected ping to fail for a storage file in a missing directory")
+	}
+}
+
+func TestReader_Sharded(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	filePath := filepath.Join(dir, "ipam-storage.json")
+
+	// an allocation saved before the store was sharded
+	fs, err := storage.NewFileStorage(filePath)
+	if err != nil {
+		t.Fatalf("failed to create file storage: %s", err)
+	}
+	if err := fs.SavePool(ctx, &storage.Pool{Name: "pool-a", CIDRs: []string{"10.0.0.0/16"}}); err != nil {
+		t.Fatalf("failed to save pool: %s", err)
+	}
+	if err := fs.SaveAllocation(ctx, &storage.Allocation{ID: "legacy-alloc", PoolName: "pool-a", AllocatedCIDR: "10.0.0.0/24", PrefixLength: 24}); err != nil {
+		t.Fatalf("failed to save allocation: %s", err)
+	}
+
+	sharded, err := storage.Factory(ctx, &storage.Config{Type: "file", FilePath: filePath, Sharded: true})
+	if err != nil {
+		t.Fatalf("failed to create sharded storage: %s", err)
+	}
+	if err := sharded.SavePool(ctx, &storage.Pool{Name: "pool-b", CIDRs: []string{"10.1.0.0/16"}}); err != nil {
+		t.Fatalf("failed to save pool: %s", err)
+	}
+	if err := sharded.SaveAllocation(ctx, &storage.Allocation{ID: "new-alloc", PoolName: "pool-b", AllocatedCIDR: "10.1.0.0/24", PrefixLength: 24}); err != nil {
+		t.Fatalf("failed to save allocation: %s", err)
+	}
+
+	// moving the legacy allocation to another pool takes it out of the main file
+	if err := sharded.SaveAllocation(ctx, &storage.Allocation{ID: "legacy-alloc", PoolName: "pool-b", AllocatedCIDR: "10.1.1.0/24", PrefixLength: 24}); err != nil {
+		t.Fatalf("failed to move allocation: %s", err)
+	}
+	sharded.Close()
+
+	if _, err := os.Stat(filepath.Join(dir, "ipam-storage", "allocations", "pool-b.json")); err != nil {
+		t.Fatalf("expected a shard file for pool-b: %s", err)
+	}
+
+	r, err := Open(ctx, &Config{Type: "file", FilePath: filePath, Sharded: true})
+	if err != nil {
+		t.Fatalf("failed to open reader: %s", err)
+	}
+	defer r.Close()
+
+	allocations, err := r.ListAllocations(ctx)
+	if err != nil {
+		t.Fatalf("failed to list allocations: %s", err)
+	}
+	if len(allocations) != 2 {
+		t.Fatalf("expected 2 allocations across the shards, got %+v", allocations)
+	}
+
+	allocation, err := r.GetAllocation(ctx, "legacy-alloc")
+	if err != nil {
+		t.Fatalf("failed to get moved allocation: %s", err)
+	}
+	if allocation.PoolName != "pool-b" || allocation.AllocatedCIDR != "10.1.1.0/24" {
+		t.Fatalf("unexpected moved allocation: %+v", allocation)
+	}
+
+	if allocations, _ := r.ListAllocationsByPool(ctx, "pool-a"); len(allocations) != 0 {
+		t.Fatalf("expected pool-a to be empty after the move, got %+v", allocations)
+	}
+}
+
+func TestReader_OwnerRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	configs := map[string]*Config{
+		"file":   {Type: "file", FilePath: filepath.Join(dir, "ipam-storage.json")},
+		"sqlite": {Type: "sqlite", SQLitePath: filepath.Join(dir, "ipam-storage.db")},
+	}
+	for name, config := range configs {
+		t.Run(name, func(t *testing.T) {
+			backend, err := storage.Factory(ctx, config)
+			if err != nil {
+				t.Fatalf("failed to create %s storage: %s", name, err)
+			}
+			if err := backend.SaveAllocation(ctx, &storage.Allocation{ID: "owned-alloc", PoolName: "owned-pool", AllocatedCIDR: "10.0.0.0/24", PrefixLength: 24, Owner: "team-network"}); err != nil {
+				t.Fatalf("failed to save allocation: %s", err)
+			}
+			backend.Close()
+
+			r, err := Open(ctx, config)
+			if err != nil {
+				t.Fatalf("failed to open reader: %s", err)
+			}
+			defer r.Close()
+
+			allocation, err := r.GetAllocation(ctx, "owned-alloc")
+			if err != nil {
+				t.Fatalf("failed to get allocation: %s", err)
+			}
+			if allocation.Owner != "team-network" {
+				t.Fatalf("expected owner team-network, got %q", allocation.Owner)
+			}
+		})
+	}
+}
+
+func TestReader_ReadOnlyFile(t *testing.T) {
+	if os.Geteuid() == 0 {
+		t.Skip("root can write to read-only directories")
+	}
+
+	ctx := context.Background()
+	dir := t.TempDir()
+	filePath := filepath.Join(dir, "ipam-storage.json")
+
+	fs, err := storage.NewFileStorage(filePath)
+	if err != nil {
+		t.Fatalf("failed to create file storage: %s", err)
+	}
+	if err := fs.SavePool(ctx, &storage.Pool{Name: "read-only-pool", CIDRs: []string{"10.0.0.0/16"}}); err != nil {
+		t.Fatalf("failed to save pool: %s", err)
+	}
+
+	// the storage file is replaced through a temp file next to it, so a read-only directory rejects every write
+	if err := os.Chmod(dir, 0555); err != nil {
+		t.Fatalf("failed to make directory read-only: %s", err)
+	}
+	defer os.Chmod(dir, 0755)
+
+	err = fs.SaveAllocation(ctx, &storage.Allocation{ID: "read-only-alloc", PoolName: "read-only-pool", AllocatedCIDR: "10.0.0.0/24", PrefixLength: 24})
+	if !errors.Is(err, storage.ErrReadOnly) {
+		t.Fatalf("expected ErrReadOnly saving to a read-only directory, got %v", err)
+	}
+
+	// the rejected allocation isn't left behind in memory
+	if _, err := fs.GetAllocation(ctx, "read-only-alloc"); !errors.Is(err, storage.ErrNotFound) {
+		t.Fatalf("expected the rejected allocation to be dropped, got %v", err)
+	}
+
+	r, err := Open(ctx, &Config{Type: "file", FilePath: filePath})
+	if err != nil {
+		t.Fatalf("failed to open reader: %s", err)
+	}
+	defer r.Close()
+
+	pools, err := r.ListPools(ctx)
+	if err != nil {
+		t.Fatalf("failed to list pools from read-only storage: %s", err)
+	}
+	if len(pools) != 1 || pools[0].Name != "read-only-pool" {
+		t.Fatalf("unexpected pools: %+v", pools)
+	}
+}
+
+func TestReader_OpensReadOnly(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	t.Chdir(dir)
+
+	// nothing is created for a store the provider hasn't written yet
+	for _, config := range []*Config{
+		{Type: "file"},
+		{Type: "sqlite"},
+		{Type: "sqlite", SQLitePath: filepath.Join(dir, "ipam.db")},
+	} {
+		r, err := Open(ctx, config)
+		if err != nil {
+			t.Fatalf("failed to open %s reader: %s", config.Type, err)
+		}
+		r.ListPools(ctx)
+		r.Close()
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+		t.Fatalf("expected opening readers to leave the directory empty, found %d entries", len(entries))
+	}
+
+	// an existing database is read as is and can't be written through the reader's connection
+	sqlitePath := filepath.Join(dir, "ipam.db")
+	backend, err := storage.NewSQLiteStorage(sqlitePath, 0)
+	if err != nil {
+		t.Fatalf("failed to create sqlite storage: %s", err)
+	}
+	if err := backend.SavePool(ctx, &storage.Pool{Name: "read-only-pool", CIDRs: []string{"10.0.0.0/16"}}); err != nil {
+		t.Fatalf("failed to save pool: %s", err)
+	}
+	defer backend.Close()
+
+	r, err := Open(ctx, &Config{Type: "sqlite", SQLitePath: sqlitePath})
+	if err != nil {
+		t.Fatalf("failed to open reader: %s", err)
+	}
+	defer r.Close()
+	if _, err := r.GetPool(ctx, "read-only-pool"); err != nil {
+		t.Fatalf("failed to get pool: %s", err)
+	}
+	if err := r.storage.DeletePool(ctx, "read-only-pool"); !errors.Is(err, storage.ErrReadOnly) {
+		t.Fatalf("expected ErrReadOnly writing through the reader, got %v", err)
+	}
+}
+
+func TestReader_RejectsWriteOptions(t *testing.T) {
+	ctx := context.Background()
+	filePath := filepath.Join(t.TempDir(), "ipam-storage.json")
+
+	for name, config := range map[string]*Config{
+		"audit log":   {Type: "file", FilePath: filePath, AuditObjectKey: filePath + ".audit"},
+		"soft delete": {Type: "file", FilePath: filePath, SoftDeleteRetention: time.Hour},
+		"fallbacks":   {Type: "file", FilePath: filePath, Fallbacks: []Config{{Type: "file", FilePath: filePath + ".fallback"}}},
+	} {
+		if _, err := Open(ctx, config); err == nil {
+			t.Errorf("expected opening a reader with %s to fail", name)
+		}
+	}
+}
+
+func TestReader_SoftDeletedAllocations(t *testing.T) {
+	ctx := context.Background()
+	filePath := filepath.Join(t.TempDir(), "ipam-storage.json")
+
+	backend, err := storage.Factory(ctx, &storage.Config{Type: "file", FilePath: filePath, SoftDeleteRetention: time.Hour})
+	if err != nil {
+		t.Fatalf("failed to create storage: %s", err)
+	}
+	for _, id := range []string{"kept-alloc", "released-alloc"} {
+		if err := backend.SaveAllocation(ctx, &storage.Allocation{ID: id, PoolName: "soft-pool", AllocatedCIDR: "10.0.0.0/24", PrefixLength: 24, AllowOverlap: true}); err != nil {
+			t.Fatalf("failed to save allocation: %s", err)
+		}
+	}
+	if err := backend.DeleteAllocation(ctx, "released-alloc"); err != nil {
+		t.Fatalf("failed to delete allocation: %s", err)
+	}
+	backend.Close()
+
+	r, err := Open(ctx, &Config{Type: "file", FilePath: filePath})
+	if err != nil {
+		t.Fatalf("failed to open reader: %s", err)
+	}
+	defer r.Close()
+
+	allocations, err := r.ListAllocationsByPool(ctx, "soft-pool")
+	if err != nil {
+		t.Fatalf("failed to list allocations: %s", err)
+	}
+	if len(allocations) != 1 || allocations[0].ID != "kept-alloc" {
+		t.Fatalf("expected only the allocation in use, got %+v", allocations)
+	}
+	if _, err := r.GetAllocation(ctx, "released-alloc"); !errors.Is(err, ErrAllocationNotFound) {
+		t.Fatalf("expected ErrAllocationNotFound for a soft deleted allocation, got %v", err)
+	}
+}
+
+func TestReader_CloseTwice(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	for _, config := range []*Config{
+		{Type: "file", FilePath: filepath.Join(dir, "ipam-storage.json"), Sharded: true, AuditObjectKey: filepath.Join(dir, "audit.jsonl")},
+		{Type: "sqlite", SQLitePath: filepath.Join(dir, "ipam.db")},
+	} {
+		backend, err := storage.Factory(ctx, config)
+		if err != nil {
+			t.Fatalf("failed to create %s storage: %s", config.Type, err)
+		}
+		if err := backend.SavePool(ctx, &storage.Pool{Name: "close-pool", CIDRs: []string{"10.0.0.0/16"}}); err != nil {
+			t.Fatalf("failed to save pool: %s", err)
+		}
+		if err := backend.SaveAllocation(ctx, &storage.Allocation{ID: "close-alloc", PoolName: "close-pool", AllocatedCIDR: "10.0.0.0/24", PrefixLength: 24}); err != nil {
+			t.Fatalf("failed to save allocation: %s", err)
+		}
+
+		if err := backend.Close(); err != nil {
+			t.Fatalf("failed to close %s storage: %s", config.Type, err)
+		}
+		if err := backend.Close(); err != nil {
+			t.Fatalf("expected closing %s storage again to return nil, got %s", config.Type, err)
+		}
+
+		// everything saved before closing is in the backend, read without the audit log that only records writes
+		readerConfig := *config
+		readerConfig.AuditObjectKey = ""
+		r, err := Open(ctx, &readerConfig)
+		if err != nil {
+			t.Fatalf("failed to open reader: %s", err)
+		}
+		if _, err := r.GetAllocation(ctx, "close-alloc"); err != nil {
+			t.Fatalf("expected the allocation saved before closing %s storage, got %s", config.Type, err)
+		}
+		r.Close()
+	}
+}
+
+func TestReader_Namespace(t *testing.T) {
+	ctx := context.Background()
+	filePath := filepath.Join(t.TempDir(), "ipam-storage.json")
+
+	// both environments have a pool called web in the same file
+	for namespace, cidr := range map[string]string{"dev": "10.0.0.0/16", "prod": "10.1.0.0/16"} {
+		backend, err := storage.Factory(ctx, &storage.Config{Type: "file", FilePath: filePath, Namespace: namespace})
+		if err != nil {
+			t.Fatalf("failed to create %s storage: %s", namespace, err)
+		}
+		if err := backend.SavePool(ctx, &storage.Pool{Name: "web", CIDRs: []string{cidr}}); err != nil {
+			t.Fatalf("failed to save %s pool: %s", namespace, err)
+		}
+		if err := backend.SaveAllocation(ctx, &storage.Allocation{ID: "web-0", PoolName: "web", AllocatedCIDR: cidr, PrefixLength: 16}); err != nil {
+			t.Fatalf("failed to save %s allocation: %s", namespace, err)
+		}
+		backend.Close()
+	}
+
+	r, err := Open(ctx, &Config{Type: "file", FilePath: filePath, Namespace: "prod"})
+	if err != nil {
+		t.Fatalf("failed to open reader: %s", err)
+	}
+	defer r.Close()
+
+	pool, err := r.GetPool(ctx, "web")
+	if err != nil {
+		t.Fatalf("failed to get pool: %s", err)
+	}
+	if pool.Name != "web" || pool.CIDRs[0] != "10.1.0.0/16" {
+		t.Fatalf("expected the prod pool, got %+v", pool)
+	}
+
+	pools, err := r.ListPools(ctx)
+	if err != nil {
+		t.Fatalf("failed to list pools: %s", err)
+	}
+	if len(pools) != 1 || pools[0].Name != "web" {
+		t.Fatalf("expected only the prod pool, got %+v", pools)
+	}
+
+	allocations, err := r.ListAllocations(ctx)
+	if err != nil {
+		t.Fatalf("failed to list allocations: %s", err)
+	}
+	if len(allocations) != 1 || allocations[0].ID != "web-0" || allocations[0].PoolName != "web" || allocations[0].AllocatedCIDR != "10.1.0.0/16" {
+		t.Fatalf("expected only the prod allocation, got %+v", allocations)
+	}
+
+	// without a namespace every entry is visible under its stored name
+	all, err := Open(ctx, &Config{Type: "file", FilePath: filePath})
+	if err != nil {
+		t.Fatalf("failed to open reader: %s", err)
+	}
+	defer all.Close()
+
+	if _, err := all.GetPool(ctx, "dev/web"); err != nil {
+		t.Fatalf("expected the dev pool under its stored name: %s", err)
+	}
+}
+
+func TestReader_RelativeFilePath(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	fs, err := storage.NewFileStorage(filepath.Join(dir, "ipam-storage.json"))
+	if err != nil {
+		t.Fatalf("failed to create file storage: %s", err)
+	}
+	if err := fs.SavePool(ctx, &storage.Pool{Name: "relative-pool", CIDRs: []string{"10.0.0.0/16"}}); err != nil {
+		t.Fatalf("failed to save pool: %s", err)
+	}
+
+	// Terraform runs the provider in the root module directory
+	t.Chdir(dir)
+	moduleDir, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("failed to get working directory: %s", err)
+	}
+
+	for _, filePath := range []string{"ipam-storage.json", "${module_path}/ipam-storage.json", filepath.Join(moduleDir, "ipam-storage.json")} {
+		config := &Config{Type: "file", FilePath: filePath}
+		resolved := *config
+		resolved.ResolveKeys()
+		if resolved.FilePath != filepath.Join(moduleDir, "ipam-storage.json") {
+			t.Fatalf("expected %s to resolve to the root module directory, got %s", filePath, resolved.FilePath)
+		}
+
+		r, err := Open(ctx, config)
+		if err != nil {
+			t.Fatalf("failed to open reader with %s: %s", filePath, err)
+		}
+		if _, err := r.GetPool(ctx, "relative-pool"); err != nil {
+			t.Fatalf("expected the pool to be found with %s, got %v", filePath, err)
+		}
+		r.Close()
+	}
+}